@@ -10,15 +10,23 @@
 //	DATABASE_URL=postgres://... adminctl create  -email you@example.com -first Имя -last Фамилия
 //	DATABASE_URL=postgres://... adminctl promote -email you@example.com [-role admin]
 //	DATABASE_URL=postgres://... adminctl list
+//	DATABASE_URL=postgres://... adminctl mfa-reset -email user@example.com [-passkeys]
+//	DATABASE_URL=postgres://... adminctl audit-verify
 //
 // The password is never taken from a flag (flags leak into shell history and
 // `ps`): it is read from the terminal without echo, or from the ADMIN_PASSWORD
 // environment variable for unattended provisioning.
+//
+// What this tool changes goes into the audit log like any change made in the
+// panel, with "adminctl:" and the shell user as the actor — the shell is
+// trusted, not invisible. audit-verify walks that log's hash chain and prints
+// its head, the value to keep alongside an export.
 package main
 
 import (
 	"bufio"
 	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
@@ -26,10 +34,12 @@ import (
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"golang.org/x/crypto/bcrypt"
 	"golang.org/x/term"
 	"shanraq.org/pkg/modules/auth"
+	"shanraq.org/pkg/modules/jobs"
 )
 
 const usage = `adminctl — staff account management
@@ -37,6 +47,8 @@ const usage = `adminctl — staff account management
   adminctl create  -email <e-mail> -first <Имя> -last <Фамилия> [-middle <Отчество>] [-role admin]
   adminctl promote -email <e-mail> [-role admin]
   adminctl list
+  adminctl mfa-reset -email <e-mail> [-passkeys]
+  adminctl audit-verify
 
 Environment:
   DATABASE_URL    required, PostgreSQL DSN
@@ -70,6 +82,10 @@ func main() {
 		cmdPromote(ctx, store, os.Args[2:])
 	case "list":
 		cmdList(ctx, pool)
+	case "mfa-reset":
+		cmdMFAReset(ctx, store, jobs.NewStore(pool), os.Args[2:])
+	case "audit-verify":
+		cmdAuditVerify(ctx, store)
 	default:
 		fmt.Fprint(os.Stderr, usage)
 		os.Exit(2)
@@ -122,6 +138,7 @@ func cmdCreate(ctx context.Context, store *auth.Store, args []string) {
 		}
 		fail("create: %v", err)
 	}
+	audit(ctx, store, "user.create", user.ID.String(), map[string]string{"email": normEmail, "role": *role})
 	fmt.Printf("created %s (%s) with role %s\n", normEmail, user.ID, *role)
 }
 
@@ -145,6 +162,7 @@ func cmdPromote(ctx context.Context, store *auth.Store, args []string) {
 	if !found {
 		fail("no account with that e-mail")
 	}
+	audit(ctx, store, "user.role", normEmail, map[string]string{"role": *role})
 	fmt.Printf("%s is now %s\n", normEmail, *role)
 }
 
@@ -173,6 +191,89 @@ func cmdList(ctx context.Context, pool *pgxpool.Pool) {
 	}
 }
 
+// cmdMFAReset is for someone locked out of their second factor with no
+// recovery code left: it forgets their authenticator app and recovery codes,
+// signs every session out, and queues an e-mail telling them so — the mail is
+// the owner's chance to notice if the reset was not theirs to ask for.
+//
+// Check who is asking before running it. It undoes the very protection the
+// second factor is there to give.
+func cmdMFAReset(ctx context.Context, store *auth.Store, queue *jobs.Store, args []string) {
+	fs := flag.NewFlagSet("mfa-reset", flag.ExitOnError)
+	email := fs.String("email", "", "e-mail of the locked-out account")
+	passkeys := fs.Bool("passkeys", false, "remove the account's passkeys too")
+	_ = fs.Parse(args)
+
+	normEmail, ok := auth.NormalizeEmail(*email)
+	if !ok {
+		fail("invalid e-mail")
+	}
+	user, err := store.FindByEmail(ctx, normEmail)
+	if err != nil {
+		if errors.Is(err, auth.ErrNotFound) {
+			fail("no account with that e-mail")
+		}
+		fail("find account: %v", err)
+	}
+	had, err := store.ResetMFA(ctx, user.ID, *passkeys)
+	if err != nil {
+		fail("reset: %v", err)
+	}
+	audit(ctx, store, "user.mfa_reset", user.ID.String(), map[string]bool{"passkeys": *passkeys})
+	if !had {
+		fmt.Printf("%s had no second factor enrolled; sessions were signed out anyway\n", normEmail)
+	}
+
+	payload, err := json.Marshal(auth.MFAResetNotice{Email: normEmail})
+	if err != nil {
+		fail("encode notice: %v", err)
+	}
+	if _, err := queue.Enqueue(ctx, jobs.Job{
+		ID:          uuid.New(),
+		UserID:      user.ID,
+		Name:        auth.JobMFAResetNotice,
+		Payload:     payload,
+		RunAt:       time.Now(),
+		MaxAttempts: 5,
+	}); err != nil {
+		// The reset stands; only the mail is missing. Say so loudly rather
+		// than undo it, and let the operator tell the user another way.
+		fail("second factor reset, but the notice could not be queued: %v", err)
+	}
+	fmt.Printf("second factor of %s reset; every session signed out; notice e-mail queued\n", normEmail)
+}
+
+// cmdAuditVerify checks the audit log's hash chain end to end.
+func cmdAuditVerify(ctx context.Context, store *auth.Store) {
+	check, err := store.VerifyAudit(ctx)
+	if err != nil {
+		fail("verify: %v", err)
+	}
+	fmt.Printf("%d entries, head %s\n", check.Entries, check.Head)
+	if check.BrokenAt != 0 {
+		fail("chain broken at entry %d: it or the one before it was altered or removed", check.BrokenAt)
+	}
+	fmt.Println("chain intact")
+}
+
+// audit records what the command did. Like the panel's own writes it does not
+// undo the change when the log cannot be written; it only says so.
+func audit(ctx context.Context, store *auth.Store, action, target string, after any) {
+	raw, err := json.Marshal(after)
+	if err == nil {
+		_, err = store.AppendAudit(ctx, auth.AuditEntry{
+			ActorEmail: "adminctl:" + os.Getenv("USER"),
+			Action:     action,
+			TargetType: "user",
+			TargetID:   target,
+			After:      raw,
+		})
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "adminctl: warning: audit log not written: %v\n", err)
+	}
+}
+
 func isStaffRole(r string) bool { return r == "admin" || r == "director" }
 
 // readPassword takes the password from ADMIN_PASSWORD when set (unattended
@@ -43,9 +43,14 @@ func main() {
 		panic(err)
 	}
 
+	// Workers are woken by a notification when a job is enqueued; the poll is
+	// the safety net for retries and jobs that come due later.
 	const (
 		jobWorkers     = 4
-		jobPollSeconds = 2 * time.Second
+		jobPollSeconds = 10 * time.Second
+		// Model calls run in a queue of their own, one at a time, so a burst
+		// of translations cannot take the workers mail and events need.
+		aiJobWorkers = 1
 	)
 
 	tenantResolver := jobs.AuthTenantResolver()
@@ -55,6 +60,9 @@ func main() {
 	if cfg.Auth.MFA.TOTP.Enabled {
 		authOpts = append(authOpts, auth.WithTOTP(cfg.Auth.MFA.TOTP.Issuer))
 	}
+	if wa := cfg.Auth.MFA.WebAuthn; wa.Enabled {
+		authOpts = append(authOpts, auth.WithWebAuthn(wa.RPID, wa.RPName, wa.Origins))
+	}
 	// Wire the SMS gateway used for phone verification. With no provider set the
 	// client is nil and codes are dev-logged (never sent); a named-but-misconfigured
 	// provider fails fast so a broken production deploy is caught at boot.
@@ -63,17 +71,11 @@ func main() {
 	} else if on {
 		authOpts = append(authOpts, auth.WithSMSSender(smsClient))
 	}
-	// The JSON signup endpoint must obey the same registration switch as the
-	// browser form. The articles module owns the service flags and is built
-	// further down, so the gate is a closure resolved at request time rather
-	// than at construction: by the time anyone can POST, both modules exist.
-	var articlesModule *articles.Module
-	authOpts = append(authOpts, auth.WithSignupGate(func() error {
-		if articlesModule == nil {
-			return nil
-		}
-		return articlesModule.RegistrationGate()
-	}))
+	// The JSON signup endpoint obeys the same registration switch as the
+	// browser form, and sessions are labelled with the analytics' User-Agent
+	// families and GeoIP country, without a closure here: auth finds the
+	// articles module, which owns both, on the runtime as its auth.SignupGate
+	// and auth.SessionDescriber.
 	authModule := auth.New(authOpts...)
 	apiKeyModule := apikeys.New(
 		apikeys.WithHTTPMiddleware(authModule.RequireRoles("user", "operator", "admin")),
@@ -81,29 +83,33 @@ func main() {
 
 	jobModule := jobs.New(
 		jobs.WithWorkerCount(jobWorkers),
+		jobs.WithQueue(ai.Queue, jobs.QueueConfig{Workers: aiJobWorkers}),
 		jobs.WithPollInterval(jobPollSeconds),
 		jobs.WithTenantResolver(tenantResolver),
 		// Staff only. Enqueue takes an arbitrary job name and payload, and among
 		// the registered handlers are ones that spend money and touch other
 		// people's content: ai_translate rewrites the translations of any
 		// article id it is given, syndicate_telegram re-posts to the channel.
-		// A plain reader could mint an API key and drive both, so the role list
-		// no longer includes "user".
+		// A plain reader could mint an API key and drive both, so looking at
+		// the queue takes jobs.view, which readers do not hold, and each
+		// action takes its own permission on top (WithPermissionGuard below).
 		jobs.WithHTTPMiddleware(
 			apiKeyModule.RequireAPIKey(),
-			authModule.RequireRoles("operator", "admin"),
+			authModule.RequirePermission(auth.PermJobsView),
 		),
 		// The operator console reaches the same queue with the credential a
 		// browser actually has. LoadSession turns the cookie into claims, which
-		// RequireRoles then holds to the same staff roles and to the same check
-		// that the account still backs them; SameOriginOnly is there because a
-		// cookie-authed POST is a CSRF surface and retry/cancel/enqueue are
-		// POSTs.
+		// RequirePermission then holds to the same permission and to the same
+		// check that the account still backs its roles; SameOriginOnly is there
+		// because a cookie-authed POST is a CSRF surface and
+		// retry/cancel/enqueue are POSTs.
 		jobs.WithConsoleMiddleware(
 			authModule.LoadSession,
 			auth.SameOriginOnly,
-			authModule.RequireRoles("operator", "admin"),
+			authModule.RequirePermission(auth.PermJobsView),
 		),
+		jobs.WithPermissionGuard(authModule.RequirePermission),
+		jobs.WithAudit(authModule.Audit),
 	)
 	aiModule := ai.New()
 	aiModule.RegisterJobs(jobModule)
@@ -111,6 +117,16 @@ func main() {
 	syndicateModule := syndicate.New(notifierModule)
 	syndicateModule.RegisterJobs(jobModule)
 
+	// Queued by `adminctl mfa-reset`, which has the database but not the
+	// relay.
+	jobModule.Handle(auth.JobMFAResetNotice, func(ctx context.Context, _ *shanraq.Runtime, job jobs.Job) error {
+		var notice auth.MFAResetNotice
+		if err := job.Decode(&notice); err != nil {
+			return err
+		}
+		return authModule.SendMFAResetNotice(ctx, notice.Email)
+	})
+
 	jobModule.Handle("send_welcome_email", func(ctx context.Context, rt *shanraq.Runtime, job jobs.Job) error {
 		var payload struct {
 			Email string `json:"email"`
@@ -126,7 +142,11 @@ func main() {
 		return nil
 	})
 
-	app := shanraq.New(cfg)
+	// SIGHUP (and POST /admin/config/reload) re-reads the same file and
+	// environment; see docs/CONFIGURATION.md for what can change live.
+	app := shanraq.New(cfg, shanraq.WithConfigReload(func() (config.Config, error) {
+		return config.Load(configPath)
+	}))
 	app.Register(migrations.New())
 	app.Register(telemetry.New())
 	app.Register(health.New())
@@ -137,16 +157,17 @@ func main() {
 	app.Register(aiModule)
 	app.Register(syndicateModule)
 	mediaModule := media.New(authModule)
+	mediaModule.RegisterJobs(jobModule)
 	app.Register(mediaModule)
-	articlesModule = articles.New(authModule, aiModule, syndicateModule, mediaModule, notifierModule)
+	articlesModule := articles.New(authModule, aiModule, mediaModule, notifierModule)
 	articlesModule.RegisterJobs(jobModule)
 	app.Register(articlesModule)
-	app.Register(webui.New(jobWorkers, jobPollSeconds,
+	app.Register(webui.New(jobWorkers+aiJobWorkers, jobPollSeconds,
 		webui.WithTenantResolver(func(r *http.Request) (uuid.UUID, bool) {
 			return tenantResolver(r)
 		}),
 		// The operator console is staff-only (global job metrics + error text).
-		webui.WithAuthGuard(authModule.RequireSession("/studio/login", "admin", "operator")),
+		webui.WithAuthGuard(authModule.RequireSessionPermission("/studio/login", auth.PermConsoleView)),
 	))
 
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
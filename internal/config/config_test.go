@@ -1,8 +1,10 @@
 package config
 
 import (
+	"os"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoadDefaults(t *testing.T) {
@@ -97,3 +99,123 @@ func TestBootstrapAdminFromEnv(t *testing.T) {
 		t.Fatalf("bootstrap not bound from env: %+v", cfg.Bootstrap)
 	}
 }
+
+func TestDiffReportsPathsAndRestartOnly(t *testing.T) {
+	running, err := Load("")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if d := Diff(running, running); len(d) != 0 {
+		t.Fatalf("identical configs differ: %v", d.Paths())
+	}
+
+	next := running
+	next.Logging.Level = "debug"
+	next.Server.TrustedProxies = []string{"127.0.0.1/32"}
+	next.Syndicate.Telegram.ChatID = "@shanraq"
+	d := Diff(running, next)
+	if got := strings.Join(d.Paths(), ","); got != "server.trusted_proxies,logging.level,syndicate.telegram.chat_id" {
+		t.Fatalf("paths = %s", got)
+	}
+	if len(d.RestartOnly()) != 0 {
+		t.Fatalf("reloadable settings reported as restart-only: %v", d.RestartOnly())
+	}
+	if !d.Touches("syndicate") || !d.Touches("logging.level") || d.Touches("logging.mode") || d.Touches("sync") {
+		t.Fatalf("Touches misreads %v", d.Paths())
+	}
+
+	next.Database.MaxConns++
+	next.Auth.TokenSecret = "another-secret-that-is-long-enough-0000"
+	next.Syndicate.BaseURL = "https://example.org"
+	got := strings.Join(Diff(running, next).RestartOnly(), ",")
+	if got != "database.max_conns,auth.token_secret,syndicate.base_url" {
+		t.Fatalf("restart-only = %s", got)
+	}
+}
+
+// The auth rate limits are live, the store they are counted in is not, and
+// neither is the rest of the auth section.
+func TestDiffRateLimitsAreLive(t *testing.T) {
+	var running Config
+	next := running
+	next.Auth.RateLimit.Rules = map[string]RateLimitRule{"signin": {Limit: 3, Window: time.Minute}}
+	next.Auth.RateLimit.Lockout.After = 5
+	if d := Diff(running, next); !d.Touches("auth.rate_limit") || len(d.RestartOnly()) != 0 {
+		t.Fatalf("rate-limit rules: paths %v, restart-only %v", d.Paths(), d.RestartOnly())
+	}
+	next.Auth.RateLimit.Store = "memory"
+	next.Auth.MFA.WebAuthn.RPID = "shanraq.org"
+	if got := strings.Join(Diff(running, next).RestartOnly(), ","); got != "auth.mfa.webauthn.rp_id,auth.rate_limit.store" {
+		t.Fatalf("restart-only = %s", got)
+	}
+}
+
+func TestDiffTreatsEmptyListsAlike(t *testing.T) {
+	a := Config{Server: ServerConfig{TrustedProxies: nil}}
+	b := Config{Server: ServerConfig{TrustedProxies: []string{}}}
+	if d := Diff(a, b); len(d) != 0 {
+		t.Fatalf("nil and empty lists differ: %v", d.Paths())
+	}
+}
+
+func TestJobRetentionFromFile(t *testing.T) {
+	path := t.TempDir() + "/config.yaml"
+	yaml := "jobs:\n  retention:\n    failed: 48h\n    jobs:\n      maintenance_restore:\n        done: 1h\n      ai_translate:\n        failed: -1s\n"
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	r := cfg.Jobs.Retention
+	if r.Done != 168*time.Hour || r.Failed != 48*time.Hour || !r.Archive {
+		t.Fatalf("retention = %+v, want the defaults with failed overridden", r)
+	}
+	if r.Jobs["maintenance_restore"].Done != time.Hour || r.Jobs["ai_translate"].Failed != -time.Second {
+		t.Fatalf("per-name rules = %+v", r.Jobs)
+	}
+}
+
+// Origins come from the environment as a comma-separated list, and one with a
+// path is refused: the browser reports a bare origin, so it could never match.
+func TestWebAuthnOriginsFromEnv(t *testing.T) {
+	t.Setenv("SHANRAQ_AUTH_MFA_WEBAUTHN_ENABLED", "true")
+	t.Setenv("SHANRAQ_AUTH_MFA_WEBAUTHN_ORIGINS", "https://shanraq.org,https://www.shanraq.org")
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if got := cfg.Auth.MFA.WebAuthn.Origins; len(got) != 2 || got[1] != "https://www.shanraq.org" {
+		t.Fatalf("origins = %q", got)
+	}
+
+	t.Setenv("SHANRAQ_AUTH_MFA_WEBAUTHN_ORIGINS", "https://shanraq.org/studio")
+	if _, err := Load(""); err == nil || !strings.Contains(err.Error(), "auth.mfa.webauthn.origins") {
+		t.Fatalf("an origin with a path was accepted: %v", err)
+	}
+}
+
+func TestRateLimitFromFile(t *testing.T) {
+	path := t.TempDir() + "/config.yaml"
+	yaml := "auth:\n  rate_limit:\n    rules:\n      signin:\n        limit: 3\n        window: 5m\n    lockout:\n      after: 2\n"
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	rl := cfg.Auth.RateLimit
+	if rl.Store != "postgres" || rl.Lockout.After != 2 || rl.Lockout.Base != time.Minute || rl.Lockout.Max != time.Hour {
+		t.Fatalf("rate limit = %+v, want the defaults with lockout.after overridden", rl)
+	}
+	if r := rl.Rules["signin"]; r.Limit != 3 || r.Window != 5*time.Minute {
+		t.Fatalf("signin rule = %+v", r)
+	}
+
+	t.Setenv("SHANRAQ_AUTH_RATE_LIMIT_STORE", "redis")
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "auth.rate_limit.store") {
+		t.Fatalf("an unknown store was accepted: %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Change is one setting that differs between two configurations.
+type Change struct {
+	// Path is the dotted key as it appears in the config file
+	// ("syndicate.telegram.chat_id").
+	Path string
+	// Restart marks a setting tagged reload:"restart": it cannot take effect
+	// without restarting the process.
+	Restart bool
+}
+
+// Changes is the result of Diff, in struct declaration order.
+type Changes []Change
+
+// Touches reports whether any change is at key or beneath it, so a module can
+// ask about its own section ("syndicate") or a single key
+// ("logging.level").
+func (c Changes) Touches(key string) bool {
+	for _, ch := range c {
+		if ch.Path == key || strings.HasPrefix(ch.Path, key+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// RestartOnly returns the paths of the changes that need a restart.
+func (c Changes) RestartOnly() []string {
+	var out []string
+	for _, ch := range c {
+		if ch.Restart {
+			out = append(out, ch.Path)
+		}
+	}
+	return out
+}
+
+// Paths returns every changed path.
+func (c Changes) Paths() []string {
+	out := make([]string, len(c))
+	for i, ch := range c {
+		out[i] = ch.Path
+	}
+	return out
+}
+
+// Diff lists the settings that differ between running and next. It reports
+// paths only, never values: the config holds secrets, and a diff ends up in
+// logs and in the reload endpoint's answer.
+func Diff(running, next Config) Changes {
+	var out Changes
+	diffStruct(reflect.ValueOf(running), reflect.ValueOf(next), "", false, &out)
+	return out
+}
+
+func diffStruct(a, b reflect.Value, prefix string, restart bool, out *Changes) {
+	t := a.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("mapstructure"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+		fieldRestart := restart || f.Tag.Get("reload") == "restart"
+		av, bv := a.Field(i), b.Field(i)
+		if f.Type.Kind() == reflect.Struct {
+			diffStruct(av, bv, path, fieldRestart, out)
+			continue
+		}
+		// A missing list and an empty one mean the same thing in a config file.
+		if f.Type.Kind() == reflect.Slice && av.Len() == 0 && bv.Len() == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(av.Interface(), bv.Interface()) {
+			*out = append(*out, Change{Path: path, Restart: fieldRestart})
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package httpserver
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// BearerAuthorized reports whether r carries "Authorization: Bearer <token>"
+// for the given token, compared in constant time. An empty token never
+// matches; callers decide what an unconfigured token means for them.
+func BearerAuthorized(r *http.Request, token string) bool {
+	if token == "" {
+		return false
+	}
+	const prefix = "Bearer "
+	got := r.Header.Get("Authorization")
+	return len(got) > len(prefix) && strings.EqualFold(got[:len(prefix)], prefix) &&
+		subtle.ConstantTimeCompare([]byte(got[len(prefix):]), []byte(token)) == 1
+}
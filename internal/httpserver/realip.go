@@ -4,6 +4,7 @@ import (
 	"net"
 	"net/http"
 	"strings"
+	"sync/atomic"
 )
 
 // parseTrustedProxies turns the configured list of CIDRs or bare IPs into
@@ -71,20 +72,38 @@ func realClientIP(r *http.Request, trusted []*net.IPNet) string {
 	return ""
 }
 
+// proxyList is the parsed trusted-proxy list behind an atomic pointer, so a
+// config reload can replace it while requests are in flight.
+type proxyList struct {
+	nets atomic.Pointer[[]*net.IPNet]
+}
+
+func newProxyList(entries []string) *proxyList {
+	p := &proxyList{}
+	p.set(entries)
+	return p
+}
+
+func (p *proxyList) set(entries []string) {
+	nets := parseTrustedProxies(entries)
+	p.nets.Store(&nets)
+}
+
 // trustedRealIP rewrites r.RemoteAddr to the resolved client IP when it can be
 // trusted. Unlike chi's middleware.RealIP, it does not believe forwarded
 // headers from arbitrary clients. With no trusted proxies configured it is a
 // no-op and the real TCP peer is preserved.
 func trustedRealIP(entries []string) func(http.Handler) http.Handler {
-	trusted := parseTrustedProxies(entries)
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if len(trusted) > 0 {
-				if ip := realClientIP(r, trusted); ip != "" {
-					r.RemoteAddr = ip
-				}
+	return newProxyList(entries).middleware
+}
+
+func (p *proxyList) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if trusted := *p.nets.Load(); len(trusted) > 0 {
+			if ip := realClientIP(r, trusted); ip != "" {
+				r.RemoteAddr = ip
 			}
-			next.ServeHTTP(w, r)
-		})
-	}
+		}
+		next.ServeHTTP(w, r)
+	})
 }
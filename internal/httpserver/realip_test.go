@@ -58,3 +58,21 @@ func TestTrustedRealIPMiddleware(t *testing.T) {
 		t.Errorf("trusted proxy XFF should set RemoteAddr, got %q", seen)
 	}
 }
+
+func TestTrustedProxiesReplacedLive(t *testing.T) {
+	var seen string
+	capture := http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) { seen = r.RemoteAddr })
+	proxies := newProxyList(nil)
+	h := proxies.middleware(capture)
+
+	h.ServeHTTP(httptest.NewRecorder(), req("127.0.0.1:3000", "203.0.113.9", ""))
+	if seen != "127.0.0.1:3000" {
+		t.Fatalf("before reload the proxy must not be believed, got %q", seen)
+	}
+
+	proxies.set([]string{"127.0.0.1"})
+	h.ServeHTTP(httptest.NewRecorder(), req("127.0.0.1:3000", "203.0.113.9", ""))
+	if seen != "203.0.113.9" {
+		t.Fatalf("after reload the proxy should be believed, got %q", seen)
+	}
+}
@@ -15,10 +15,11 @@ import (
 
 // Server wraps chi.Router with lifecycle hooks.
 type Server struct {
-	cfg    config.ServerConfig
-	router chi.Router
-	http   *http.Server
-	logger *zap.Logger
+	cfg     config.ServerConfig
+	router  chi.Router
+	http    *http.Server
+	logger  *zap.Logger
+	proxies *proxyList
 }
 
 // New instantiates the HTTP server with default middlewares wired up.
@@ -39,7 +40,8 @@ func New(cfg config.ServerConfig, logger *zap.Logger) *Server {
 	// reads r.RemoteAddr and must not look at the headers again: a second reader
 	// that parses X-Forwarded-For itself will take the client's own end of the
 	// chain, which is the forgeable one.
-	r.Use(trustedRealIP(cfg.TrustedProxies))
+	proxies := newProxyList(cfg.TrustedProxies)
+	r.Use(proxies.middleware)
 	// An empty list behind a reverse proxy means every request appears to come
 	// from the proxy: one address for the whole world, so rate limits collapse
 	// onto a single bucket and the country panel goes blank. This deployment
@@ -54,10 +56,20 @@ func New(cfg config.ServerConfig, logger *zap.Logger) *Server {
 	r.Use(conditionalGet)
 	r.Use(requestLogger(logger))
 
+	// Built here rather than in Start: Shutdown is called from the goroutine
+	// running the application, and it must see the same server Start serves.
 	return &Server{
-		cfg:    cfg,
-		router: r,
-		logger: logger,
+		cfg:     cfg,
+		router:  r,
+		logger:  logger,
+		proxies: proxies,
+		http: &http.Server{
+			Addr:         cfg.Address,
+			Handler:      r,
+			ReadTimeout:  cfg.ReadTimeout,
+			WriteTimeout: cfg.WriteTimeout,
+			IdleTimeout:  cfg.IdleTimeout,
+		},
 	}
 }
 
@@ -65,16 +77,15 @@ func (s *Server) Router() chi.Router {
 	return s.router
 }
 
-// Start begins serving HTTP traffic until the context is canceled.
-func (s *Server) Start(ctx context.Context) error {
-	s.http = &http.Server{
-		Addr:         s.cfg.Address,
-		Handler:      s.router,
-		ReadTimeout:  s.cfg.ReadTimeout,
-		WriteTimeout: s.cfg.WriteTimeout,
-		IdleTimeout:  s.cfg.IdleTimeout,
-	}
+// SetTrustedProxies replaces the trusted-proxy list for requests that arrive
+// from now on.
+func (s *Server) SetTrustedProxies(entries []string) {
+	s.proxies.set(entries)
+}
 
+// Start begins serving HTTP traffic until the context is canceled or Shutdown
+// is called; after Shutdown it returns nil.
+func (s *Server) Start(ctx context.Context) error {
 	errCh := make(chan error, 1)
 	go func() {
 		s.logger.Info("http server starting", zap.String("addr", s.cfg.Address))
@@ -87,7 +98,11 @@ func (s *Server) Start(ctx context.Context) error {
 
 	select {
 	case <-ctx.Done():
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		timeout := s.cfg.ShutdownTimeout
+		if timeout <= 0 {
+			timeout = 10 * time.Second
+		}
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
 		defer cancel()
 		_ = s.http.Shutdown(shutdownCtx)
 		return ctx.Err()
@@ -96,11 +111,9 @@ func (s *Server) Start(ctx context.Context) error {
 	}
 }
 
-// Shutdown gracefully stops the HTTP server.
+// Shutdown stops accepting connections and waits for in-flight requests to
+// finish, or for ctx to expire, whichever comes first.
 func (s *Server) Shutdown(ctx context.Context) error {
-	if s.http == nil {
-		return nil
-	}
 	return s.http.Shutdown(ctx)
 }
 
@@ -10,11 +10,14 @@ import (
 	"go.uber.org/zap/zapcore"
 )
 
-// Build constructs a zap.Logger configured for the runtime environment.
-func Build(cfg config.Logging) (*zap.Logger, error) {
-	level, err := parseLevel(cfg.Level)
+// Build constructs a zap.Logger configured for the runtime environment. The
+// returned level is live: setting it changes what the logger (and every
+// logger derived from it) emits, which is how a config reload changes the
+// log level without a restart.
+func Build(cfg config.Logging) (*zap.Logger, zap.AtomicLevel, error) {
+	level, err := ParseLevel(cfg.Level)
 	if err != nil {
-		return nil, fmt.Errorf("parse log level: %w", err)
+		return nil, zap.AtomicLevel{}, fmt.Errorf("parse log level: %w", err)
 	}
 
 	var zapCfg zap.Config
@@ -30,12 +33,13 @@ func Build(cfg config.Logging) (*zap.Logger, error) {
 
 	logger, err := zapCfg.Build()
 	if err != nil {
-		return nil, fmt.Errorf("build logger: %w", err)
+		return nil, zap.AtomicLevel{}, fmt.Errorf("build logger: %w", err)
 	}
-	return logger, nil
+	return logger, zapCfg.Level, nil
 }
 
-func parseLevel(level string) (zapcore.Level, error) {
+// ParseLevel maps a configured level name to a zap level.
+func ParseLevel(level string) (zapcore.Level, error) {
 	switch strings.ToLower(level) {
 	case "debug":
 		return zap.DebugLevel, nil
@@ -0,0 +1,94 @@
+// Package events is the catalogue of domain events modules exchange over the
+// runtime's event bus (shanraq.EventBus).
+//
+// It lives apart from the modules that publish them on purpose. The syndicate
+// module reacts to an article being published, but it cannot import articles —
+// articles already imports syndicate — and it should not have to: what it needs
+// is the fact, not the module that produced it. Every type here is a plain
+// value with a stable wire name, because durable subscribers receive it as
+// JSON out of the job queue, possibly after a deploy.
+//
+// Events carry identifiers, not snapshots. A subscriber that needs the title
+// or the slug reads it when it runs, so a delivery retried an hour later sees
+// the article as it is then, not as it was.
+package events
+
+import "github.com/google/uuid"
+
+// Wire names. Changing one orphans every delivery already queued under it.
+const (
+	NameArticlePublished   = "article.published"
+	NameArticleUnpublished = "article.unpublished"
+	NameListingCreated     = "listing.created"
+	NameListingExpired     = "listing.expired"
+	NamePaymentPaid        = "payment.paid"
+	NameUserDeleted        = "user.deleted"
+	NameCommentCreated     = "comment.created"
+)
+
+// ArticlePublished: an article became visible to readers — by its author, by
+// staff, or by a moderator's approval.
+type ArticlePublished struct {
+	ArticleID uuid.UUID `json:"article_id"`
+	AuthorID  uuid.UUID `json:"author_id"`
+}
+
+func (ArticlePublished) EventName() string { return NameArticlePublished }
+
+// Why an article stopped being public.
+const (
+	UnpublishedByAuthor    = "author"
+	UnpublishedByModerator = "moderator"
+	UnpublishedByReaders   = "readers"
+)
+
+// ArticleUnpublished: a published article left public view. Reason is one of
+// the Unpublished* constants.
+type ArticleUnpublished struct {
+	ArticleID uuid.UUID `json:"article_id"`
+	Reason    string    `json:"reason"`
+}
+
+func (ArticleUnpublished) EventName() string { return NameArticleUnpublished }
+
+// ListingCreated: a classified listing was submitted.
+type ListingCreated struct {
+	ListingID uuid.UUID `json:"listing_id"`
+	AuthorID  uuid.UUID `json:"author_id"`
+}
+
+func (ListingCreated) EventName() string { return NameListingCreated }
+
+// ListingExpired: a listing outlived its window and was deleted with its
+// data. The row is gone by the time subscribers run; only the ids remain.
+type ListingExpired struct {
+	ListingID uuid.UUID `json:"listing_id"`
+	AuthorID  uuid.UUID `json:"author_id"`
+}
+
+func (ListingExpired) EventName() string { return NameListingExpired }
+
+// PaymentPaid: a provider confirmed a payment and what it paid for was
+// activated. Published once per payment, however often the webhook repeats.
+type PaymentPaid struct {
+	PaymentID uuid.UUID `json:"payment_id"`
+}
+
+func (PaymentPaid) EventName() string { return NamePaymentPaid }
+
+// UserDeleted: an account and everything cascading from it were erased.
+// ByAdmin tells a right-to-erasure request from a moderation removal.
+type UserDeleted struct {
+	UserID  uuid.UUID `json:"user_id"`
+	ByAdmin bool      `json:"by_admin"`
+}
+
+func (UserDeleted) EventName() string { return NameUserDeleted }
+
+// CommentCreated: a reader commented on a published article.
+type CommentCreated struct {
+	ArticleID uuid.UUID `json:"article_id"`
+	AuthorID  uuid.UUID `json:"author_id"`
+}
+
+func (CommentCreated) EventName() string { return NameCommentCreated }
@@ -17,6 +17,7 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -56,6 +57,9 @@ type Module struct {
 
 	settings *Settings
 
+	// pinned, when set by WithCompleter, replaces every provider client.
+	pinned Completer
+
 	// Active snapshot, guarded by mu. applySettings is the only writer.
 	mu             sync.RWMutex
 	completer      Completer
@@ -65,9 +69,25 @@ type Module struct {
 	maxTokens      int
 }
 
+// Option customizes the module.
+type Option func(*Module)
+
+// WithCompleter makes c answer every request, whichever provider the settings
+// name, and keeps the assistant switched on: a test that hands the module a
+// fake wants it used. The shanraqtest harness passes its FakeAI here.
+func WithCompleter(c Completer) Option {
+	return func(m *Module) {
+		m.pinned = c
+	}
+}
+
 // New returns an unconfigured module; Init reads config and builds the clients.
-func New() *Module {
-	return &Module{providers: map[string]Completer{}, keyPresent: map[string]bool{}}
+func New(opts ...Option) *Module {
+	m := &Module{providers: map[string]Completer{}, keyPresent: map[string]bool{}}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
 }
 
 func (m *Module) Name() string { return "ai" }
@@ -84,11 +104,11 @@ func (m *Module) Init(ctx context.Context, rt *shanraq.Runtime) error {
 	// Build one client per backend that has a key. Keys come from config first,
 	// then the conventional env vars; they are never persisted anywhere.
 	if key := firstNonEmpty(cfg.Providers.Anthropic.APIKey, envKey("ANTHROPIC_API_KEY"), cfg.APIKey); key != "" {
-		m.providers[ProviderAnthropic] = newClaudeCompleter(key)
+		m.providers[ProviderAnthropic] = &observed{Completer: newClaudeCompleter(key), code: ProviderAnthropic}
 		m.keyPresent[ProviderAnthropic] = true
 	}
 	if key := firstNonEmpty(cfg.Providers.OpenAI.APIKey, envKey("OPENAI_API_KEY")); key != "" {
-		m.providers[ProviderOpenAI] = newOpenAICompleter(key, cfg.Providers.OpenAI.BaseURL)
+		m.providers[ProviderOpenAI] = &observed{Completer: newOpenAICompleter(key, cfg.Providers.OpenAI.BaseURL), code: ProviderOpenAI}
 		m.keyPresent[ProviderOpenAI] = true
 	}
 	if key := firstNonEmpty(cfg.Providers.Kimi.APIKey, envKey("KIMI_API_KEY", "MOONSHOT_API_KEY")); key != "" {
@@ -96,7 +116,7 @@ func (m *Module) Init(ctx context.Context, rt *shanraq.Runtime) error {
 		if strings.TrimSpace(base) == "" {
 			base = "https://api.moonshot.ai/v1"
 		}
-		m.providers[ProviderKimi] = newOpenAICompleter(key, base)
+		m.providers[ProviderKimi] = &observed{Completer: newOpenAICompleter(key, base), code: ProviderKimi}
 		m.keyPresent[ProviderKimi] = true
 	}
 
@@ -142,6 +162,9 @@ func (m *Module) applySettings(st AISettings) {
 	c := m.providers[st.Provider]
 	m.completer = c
 	m.enabled = st.Enabled && c != nil
+	if m.pinned != nil {
+		m.completer, m.enabled = m.pinned, true
+	}
 }
 
 // Enabled reports whether the assistant can serve requests right now.
@@ -208,9 +231,11 @@ func (m *Module) moderateClient() (Completer, string) {
 	return m.completer, model
 }
 
-// RegisterJobs attaches the async translation handler to the job queue.
+// RegisterJobs attaches the async translation handler to the job queue. A
+// provider that is down tends to stay down for a while, so retries start a
+// minute out rather than at the queue's default.
 func (m *Module) RegisterJobs(j *jobs.Module) {
-	j.Handle(JobTranslate, m.handleTranslateJob)
+	j.Handle(JobTranslate, m.handleTranslateJob, jobs.WithBackoff(time.Minute, time.Hour))
 }
 
 // ---- admin panel API ----
@@ -333,4 +358,5 @@ func envKey(names ...string) string {
 var _ interface {
 	shanraq.Module
 	shanraq.InitializerModule
+	shanraq.HealthChecker
 } = (*Module)(nil)
@@ -0,0 +1,80 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"shanraq.org/pkg/shanraq"
+)
+
+// observed wraps a provider client and remembers how its last call ended, so
+// a revoked key shows up on /readyz instead of in the first author's failed
+// translation. It records outcomes only; the provider call is untouched.
+type observed struct {
+	Completer
+	code string
+
+	mu      sync.Mutex
+	lastErr error
+	lastAt  time.Time
+	okAt    time.Time
+}
+
+func (o *observed) Complete(ctx context.Context, req Request) (string, error) {
+	out, err := o.Completer.Complete(ctx, req)
+	// The caller giving up is not the provider failing.
+	if errors.Is(err, context.Canceled) {
+		return out, err
+	}
+	o.mu.Lock()
+	o.lastAt = time.Now()
+	if err != nil {
+		o.lastErr = err
+	} else {
+		o.lastErr = nil
+		o.okAt = o.lastAt
+	}
+	o.mu.Unlock()
+	return out, err
+}
+
+// status reports when the last call ended, when one last succeeded, and the
+// last call's error if it failed.
+func (o *observed) status() (at, okAt time.Time, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.lastAt, o.okAt, o.lastErr
+}
+
+// HealthChecks reports the active provider's last error. It does not call the
+// provider: a probe every few seconds would cost money and, on the accounts
+// this runs against, eat the per-minute request budget the real work needs.
+func (m *Module) HealthChecks() []shanraq.HealthCheck {
+	return []shanraq.HealthCheck{{
+		Name: "ai",
+		Run: func(context.Context) (string, error) {
+			m.mu.RLock()
+			c, enabled := m.completer, m.enabled
+			m.mu.RUnlock()
+			if !enabled || c == nil {
+				return "disabled", nil
+			}
+			o, ok := c.(*observed)
+			if !ok {
+				return "active", nil
+			}
+			at, okAt, lastErr := o.status()
+			switch {
+			case at.IsZero():
+				return o.code + " · no calls yet", nil
+			case lastErr != nil:
+				return fmt.Sprintf("%s · failing since %s", o.code, at.Format(time.RFC3339)), lastErr
+			default:
+				return fmt.Sprintf("%s · last success %s", o.code, okAt.Format(time.RFC3339)), nil
+			}
+		},
+	}}
+}
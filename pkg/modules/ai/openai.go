@@ -233,6 +233,10 @@ func (e rateLimited) Error() string {
 	return "openai: rate limited (" + strings.TrimSpace(e.body) + ")"
 }
 
+// RetryAfter is the provider's own estimate, which the job queue honours when
+// it schedules the job's next attempt (jobs.RetryAfterError).
+func (e rateLimited) RetryAfter() time.Duration { return e.retryAfter }
+
 func (c *openaiCompleter) complete(ctx context.Context, req Request) (string, error) {
 	maxTokens := req.MaxTokens
 	if maxTokens <= 0 {
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"time"
 	"unicode"
 
 	"github.com/google/uuid"
@@ -18,6 +19,11 @@ import (
 // JobTranslate is the queue job name for asynchronous AI translation.
 const JobTranslate = "ai_translate"
 
+// Queue is the job queue for work that calls the model. Those jobs take
+// minutes and the provider meters them, so they get a pool of their own
+// rather than the workers every other job waits for.
+const Queue = "ai"
+
 // allLangs mirrors the article language set (kept local to avoid importing the
 // articles package, which would create an import cycle).
 var allLangs = []string{"kz", "ru", "en"}
@@ -45,6 +51,29 @@ func EnqueuePayload(articleID uuid.UUID) (json.RawMessage, error) {
 	return json.Marshal(TranslatePayload{ArticleID: articleID.String()})
 }
 
+// TranslateJob builds the translate job for an article. It carries the
+// article as its unique key: clicking "translate" again while a run is still
+// waiting for a worker gets that run, not a second bill from the model. A
+// click while one is running queues another — the author may have edited
+// since it read the article.
+func TranslateJob(articleID, authorID uuid.UUID) (jobs.Job, error) {
+	payload, err := EnqueuePayload(articleID)
+	if err != nil {
+		return jobs.Job{}, err
+	}
+	return jobs.Job{
+		ID:          uuid.New(),
+		UserID:      authorID,
+		Name:        JobTranslate,
+		Payload:     payload,
+		RunAt:       time.Now(),
+		MaxAttempts: 3,
+		UniqueKey:   "article:" + articleID.String(),
+		UniqueScope: jobs.ScopePending,
+		Queue:       Queue,
+	}, nil
+}
+
 // handleTranslateJob translates an article from its original language into the
 // remaining languages, writing AI versions that don't clobber human ones.
 func (m *Module) handleTranslateJob(ctx context.Context, _ *shanraq.Runtime, job jobs.Job) error {
@@ -71,6 +100,8 @@ func (m *Module) handleTranslateJob(ctx context.Context, _ *shanraq.Runtime, job
 		return nil
 	}
 
+	run := &translateRun{}
+	ctx = context.WithValue(ctx, translateRunKey{}, run)
 	for _, target := range allLangs {
 		if target == origLang {
 			continue
@@ -80,6 +111,7 @@ func (m *Module) handleTranslateJob(ctx context.Context, _ *shanraq.Runtime, job
 			return err
 		}
 		if human {
+			run.Kept = append(run.Kept, target)
 			continue // never overwrite a human-authored version
 		}
 		out, err := m.translateContent(ctx, origLang, target, src)
@@ -89,9 +121,38 @@ func (m *Module) handleTranslateJob(ctx context.Context, _ *shanraq.Runtime, job
 		if err := m.saveAITranslation(ctx, id, target, out); err != nil {
 			return err
 		}
+		run.Translated = append(run.Translated, target)
 		m.log.Info("ai translated article", zap.String("article_id", payload.ArticleID), zap.String("lang", target))
 	}
-	return nil
+	return jobs.SetResult(ctx, run)
+}
+
+// translateRun is what a translation job has done so far. It is the job's
+// result when it finishes, and the first half of every progress line while
+// it runs.
+type translateRun struct {
+	// Translated are the languages written by this run, Kept the ones left
+	// alone because a person wrote them.
+	Translated []string `json:"translated"`
+	Kept       []string `json:"kept,omitempty"`
+}
+
+type translateRunKey struct{}
+
+// reportBlocks tells the job's watchers where the body translation into lang
+// has got: "kz done, en 3/7 blocks". Called outside handleTranslateJob, as
+// the tests call translateContent, it only keeps the lease.
+func reportBlocks(ctx context.Context, lang string, done, total int) error {
+	run, ok := ctx.Value(translateRunKey{}).(*translateRun)
+	if !ok {
+		return jobs.Heartbeat(ctx)
+	}
+	var msg strings.Builder
+	for _, l := range run.Translated {
+		fmt.Fprintf(&msg, "%s done, ", l)
+	}
+	fmt.Fprintf(&msg, "%s %d/%d blocks", lang, done, total)
+	return jobs.ReportProgress(ctx, jobs.Progress{Message: msg.String(), Done: done, Total: total})
 }
 
 // translateContent translates title, summary, and body from one language to
@@ -210,6 +271,13 @@ func (m *Module) translateBody(ctx context.Context, c Completer, model string, t
 			glossary = excerptForContext(strings.Join(out, "\n\n"))
 		}
 		start = end
+		// A long article runs to dozens of requests; each finished batch is
+		// reported to the author waiting on it, and is proof of life that
+		// keeps the job's lease. A lost lease means another worker has the
+		// job now, and two translations would race to save.
+		if err := reportBlocks(ctx, to, start, len(blocks)); err != nil {
+			return "", err
+		}
 	}
 	m.log.Info("translated in batches", zap.String("from", from), zap.String("to", to),
 		zap.Int("paragraphs", len(blocks)), zap.Int("requests", requests))
@@ -0,0 +1,179 @@
+package articles
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+	"shanraq.org/pkg/modules/auth"
+)
+
+// Access: what the signed-in account may do in the panel, asked by permission
+// name, and /admin/access, where root administrators edit what each role
+// holds. The panel used to carry its own role lists — admin and director for
+// users, director and manager for finance, director and editor for moderation
+// — which is exactly what the auth module's permissions replace.
+
+// can reports whether the signed-in account holds perm. Without the auth
+// module nobody holds anything.
+func (m *Module) can(r *http.Request, perm string) bool {
+	claims, ok := auth.ClaimsFromContext(r.Context())
+	if !ok || m.auth == nil {
+		return false
+	}
+	return m.auth.Can(r.Context(), claims, perm)
+}
+
+// permit refuses a request without perm. The panel's group guard has already
+// checked the session and admin.view; this narrows one route, and answers
+// 403 rather than the login redirect because the caller is signed in.
+func (m *Module) permit(perm string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !m.can(r, perm) {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// actorIsRoot reports whether the signed-in account holds a root role.
+// users.manage can be granted to any role, and without this a holder of it
+// could make themselves director with one dropdown — or delete the director.
+func actorIsRoot(r *http.Request) bool {
+	claims, ok := auth.ClaimsFromContext(r.Context())
+	if !ok {
+		return false
+	}
+	for _, role := range append([]string{claims.PrimaryRole}, claims.Roles...) {
+		if auth.IsRootRole(role) {
+			return true
+		}
+	}
+	return false
+}
+
+// assignableRoles lists the roles the panel can hand out: every defined role
+// but admin, which is the seeded superuser. The built-in list stands in when
+// the roles cannot be read.
+func (m *Module) assignableRoles(ctx context.Context) []string {
+	if m.auth == nil {
+		return assignableRoles
+	}
+	defs, err := m.auth.RoleDefs(ctx)
+	if err != nil || len(defs) == 0 {
+		return assignableRoles
+	}
+	out := make([]string, 0, len(defs))
+	for _, d := range defs {
+		if d.Name != "admin" {
+			out = append(out, d.Name)
+		}
+	}
+	return out
+}
+
+// adminAccessPage is /admin/access.
+type adminAccessPage struct {
+	Base
+	Roles       []auth.RoleDef
+	Permissions []string
+	Notice      string
+	Error       string
+}
+
+func (m *Module) handleAdminAccess(w http.ResponseWriter, r *http.Request) {
+	lang := m.resolveLang(w, r)
+	page := adminAccessPage{
+		Base:        m.base(r, T(lang, "acc.title"), lang),
+		Permissions: grantablePermissions(),
+	}
+	switch q := r.URL.Query(); {
+	case q.Get("ok") != "":
+		page.Notice = T(lang, "acc.ok."+q.Get("ok"))
+	case q.Get("err") != "":
+		page.Error = T(lang, "acc.err."+q.Get("err"))
+	}
+	roles, err := m.auth.RoleDefs(r.Context())
+	if err != nil {
+		m.rt.Logger.Error("list roles", zap.Error(err))
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	page.Roles = roles
+	m.render(w, "admin_access", page)
+}
+
+// grantablePermissions is the catalog less roles.manage, which only the fixed
+// roles hold.
+func grantablePermissions() []string {
+	out := make([]string, 0, len(auth.AllPermissions))
+	for _, p := range auth.AllPermissions {
+		if p != auth.PermRolesManage {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// handleAdminRoleCreate adds a role with the ticked permissions.
+func (m *Module) handleAdminRoleCreate(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	err := m.auth.CreateRole(r.Context(), r.FormValue("name"), r.FormValue("description"), r.Form["perm"])
+	if err != nil {
+		m.accessFailed(w, r, "create role", err)
+		return
+	}
+	m.auth.Audit(r, auth.AuditRecord{Action: "role.create", TargetType: "role",
+		TargetID: strings.ToLower(strings.TrimSpace(r.FormValue("name"))),
+		After:    map[string]any{"description": r.FormValue("description"), "permissions": r.Form["perm"]}})
+	http.Redirect(w, r, "/admin/access?ok=created", http.StatusSeeOther)
+}
+
+// handleAdminRolePermissions replaces what one role may do.
+func (m *Module) handleAdminRolePermissions(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	role := chi.URLParam(r, "role")
+	var before []string
+	if defs, err := m.auth.RoleDefs(r.Context()); err == nil {
+		for _, d := range defs {
+			if d.Name == role {
+				before = d.Permissions
+			}
+		}
+	}
+	if err := m.auth.SetRolePermissions(r.Context(), role, r.Form["perm"]); err != nil {
+		m.accessFailed(w, r, "set role permissions", err)
+		return
+	}
+	m.auth.Audit(r, auth.AuditRecord{Action: "role.permissions", TargetType: "role", TargetID: role,
+		Before: map[string]any{"permissions": before}, After: map[string]any{"permissions": r.Form["perm"]}})
+	http.Redirect(w, r, "/admin/access?ok=saved", http.StatusSeeOther)
+}
+
+// accessFailed sends the editor back with the reason an edit was refused.
+func (m *Module) accessFailed(w http.ResponseWriter, r *http.Request, what string, err error) {
+	code := ""
+	switch {
+	case errors.Is(err, auth.ErrRoleFixed):
+		code = "fixed"
+	case errors.Is(err, auth.ErrRoleExists):
+		code = "exists"
+	case errors.Is(err, auth.ErrRoleName):
+		code = "name"
+	case errors.Is(err, auth.ErrUnknownPermission):
+		code = "perm"
+	case errors.Is(err, auth.ErrRoleNotFound):
+		http.NotFound(w, r)
+		return
+	default:
+		m.rt.Logger.Error(what, zap.Error(err))
+		code = "failed"
+	}
+	http.Redirect(w, r, "/admin/access?err="+code, http.StatusSeeOther)
+}
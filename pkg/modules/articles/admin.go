@@ -2,6 +2,7 @@ package articles
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -15,27 +16,18 @@ import (
 	"go.uber.org/zap"
 	"shanraq.org/pkg/modules/ai"
 	"shanraq.org/pkg/modules/auth"
+	"shanraq.org/pkg/shanraq"
 )
 
-// Staff roles that may open the admin dashboard.
-var adminRoles = []string{"admin", "director", "manager", "editor"}
-
-// Roles the dashboard can assign (admin is the seeded superuser, not assignable here).
+// Roles the dashboard can assign when the role definitions cannot be read
+// (admin is the seeded superuser, not assignable here).
 var assignableRoles = []string{"director", "manager", "editor", "user"}
 
-func canManageUsers(c *auth.Claims) bool { return c != nil && c.HasAnyRole("admin", "director") }
-
 // canAuthorAsStaff reports whether the viewer is project leadership (the CEO /
 // directors) who may author and publish articles under their own name WITHOUT
 // email/phone verification and regardless of the staged-launch submission gate.
 // Their identity is already established by their staff account.
 func canAuthorAsStaff(c *auth.Claims) bool { return c != nil && c.HasAnyRole("admin", "director") }
-func canViewFinance(c *auth.Claims) bool {
-	return c != nil && c.HasAnyRole("admin", "director", "manager")
-}
-func canModerate(c *auth.Claims) bool {
-	return c != nil && c.HasAnyRole("admin", "director", "editor")
-}
 
 // AdminStore aggregates cross-cutting analytics for the control panel.
 type AdminStore struct{ db *pgxpool.Pool }
@@ -209,7 +201,10 @@ func (s *AdminStore) HideComment(ctx context.Context, id uuid.UUID) error {
 // AdminPage backs the dashboard template.
 type AdminPage struct {
 	Base
-	Stats          AdminStats
+	Stats AdminStats
+	// Can holds the viewer's permissions; the section flags below are read
+	// from it.
+	Can            map[string]bool
 	CanManageUsers bool
 	CanFinance     bool
 	CanModerate    bool
@@ -247,6 +242,16 @@ type AdminPage struct {
 	PendingOrgs   []OrgAuthor
 }
 
+// CanAny reports whether the viewer holds any of perms.
+func (p AdminPage) CanAny(perms ...string) bool {
+	for _, perm := range perms {
+		if p.Can[perm] {
+			return true
+		}
+	}
+	return false
+}
+
 func (m *Module) handleAdmin(w http.ResponseWriter, r *http.Request) {
 	lang := m.resolveLang(w, r)
 	claims, _ := auth.ClaimsFromContext(r.Context())
@@ -258,7 +263,8 @@ func (m *Module) handleAdmin(w http.ResponseWriter, r *http.Request) {
 	}
 	page := AdminPage{Base: m.base(r, T(lang, "admin.title"), lang)}
 	page.Stats = stats
-	if canModerate(claims) {
+	page.Can = m.auth.PermissionsOf(r.Context(), claims)
+	if page.CanAny(auth.PermCommentsHide, auth.PermArticlesDecide, auth.PermAppealsResolve) {
 		if ap, err := m.mods.OpenAppeals(r.Context(), 50); err == nil {
 			page.Appeals = ap
 		} else {
@@ -282,11 +288,10 @@ func (m *Module) handleAdmin(w http.ResponseWriter, r *http.Request) {
 	}
 	page.Guests = m.guestAnalytics(r.Context(), lang)
 	page.SourcesSince = analyticsSince
-	page.CanManageUsers = canManageUsers(claims)
-	page.CanFinance = canViewFinance(claims)
-	page.CanModerate = canModerate(claims)
-	page.AssignRoles = assignableRoles
-	if canManageUsers(claims) {
+	page.CanManageUsers = page.Can[auth.PermUsersManage]
+	page.CanFinance = page.Can[auth.PermFinanceView]
+	page.CanModerate = page.CanAny(auth.PermCommentsHide, auth.PermArticlesDecide, auth.PermAppealsResolve)
+	if page.CanManageUsers {
 		// The account register. Loaded only for those allowed to act on it —
 		// names and addresses are not something the rest of the panel needs.
 		page.UserSearch = strings.TrimSpace(r.URL.Query().Get("uq"))
@@ -295,13 +300,20 @@ func (m *Module) handleAdmin(w http.ResponseWriter, r *http.Request) {
 		} else {
 			m.rt.Logger.Error("list users", zap.Error(err))
 		}
+		page.AssignRoles = m.assignableRoles(r.Context())
+	}
+	if page.Can[auth.PermServicesManage] {
 		page.Services = m.flags.All()
 		page.ServiceStates = []string{svcOn, svcInviteOnly, svcMaintenance, svcOff}
 		page.Site = m.flags.SiteFlag()
-		if m.ai != nil {
-			page.AI = m.ai.AdminView()
-		}
+	}
+	if page.Can[auth.PermAIConfigure] && m.ai != nil {
+		page.AI = m.ai.AdminView()
+	}
+	if page.Can[auth.PermPaymentsConfigure] {
 		page.Payments = m.paymentsAdminView()
+	}
+	if page.Can[auth.PermPartnersDecide] {
 		if pend, err := m.reagents.Pending(r.Context(), 100); err == nil {
 			page.PendingAgents = pend
 		} else {
@@ -322,15 +334,14 @@ func (m *Module) handleAdmin(w http.ResponseWriter, r *http.Request) {
 }
 
 func (m *Module) handleAdminAssignRole(w http.ResponseWriter, r *http.Request) {
-	claims, _ := auth.ClaimsFromContext(r.Context())
-	if !canManageUsers(claims) {
+	if !m.can(r, auth.PermUsersManage) {
 		http.Error(w, "forbidden", http.StatusForbidden)
 		return
 	}
 	_ = r.ParseForm()
 	email := strings.TrimSpace(r.FormValue("email"))
 	role := strings.TrimSpace(strings.ToLower(r.FormValue("role")))
-	if email == "" || !contains(assignableRoles, role) {
+	if email == "" || !contains(m.assignableRoles(r.Context()), role) {
 		http.Redirect(w, r, "/admin", http.StatusSeeOther)
 		return
 	}
@@ -355,6 +366,8 @@ func (m *Module) handleAdminAssignRole(w http.ResponseWriter, r *http.Request) {
 		m.rt.Logger.Error("assign role: find user", zap.Error(err))
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
+	case !actorIsRoot(r) && (auth.IsRootRole(role) || auth.IsRootRole(target.Role)):
+		msg = "user_root_only"
 	default:
 		if err := m.users.SetRoleByID(r.Context(), target.ID, role); err != nil {
 			if errors.Is(err, auth.ErrLastAdmin) {
@@ -364,6 +377,9 @@ func (m *Module) handleAdminAssignRole(w http.ResponseWriter, r *http.Request) {
 				http.Error(w, "internal error", http.StatusInternalServerError)
 				return
 			}
+		} else {
+			m.auth.Audit(r, auth.AuditRecord{Action: "user.role", TargetType: "user", TargetID: target.ID.String(),
+				Before: map[string]any{"role": target.Role}, After: map[string]any{"role": role}})
 		}
 	}
 	http.Redirect(w, r, "/admin?ok="+msg, http.StatusSeeOther)
@@ -374,7 +390,7 @@ func (m *Module) handleAdminAssignRole(w http.ResponseWriter, r *http.Request) {
 // (or held back during the beta) without a redeploy.
 func (m *Module) handleAdminServiceFlag(w http.ResponseWriter, r *http.Request) {
 	claims, _ := auth.ClaimsFromContext(r.Context())
-	if !canManageUsers(claims) {
+	if !m.can(r, auth.PermServicesManage) {
 		http.Error(w, "forbidden", http.StatusForbidden)
 		return
 	}
@@ -400,6 +416,7 @@ func (m *Module) handleAdminServiceFlag(w http.ResponseWriter, r *http.Request)
 			until = &t
 		}
 	}
+	before := m.flags.Flag(code)
 	if err := m.flags.Set(r.Context(), code, status,
 		strings.TrimSpace(r.FormValue("message_kz")),
 		strings.TrimSpace(r.FormValue("message_ru")),
@@ -408,6 +425,8 @@ func (m *Module) handleAdminServiceFlag(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
+	m.auth.Audit(r, auth.AuditRecord{Action: "service.set", TargetType: "service", TargetID: code,
+		Before: before, After: m.flags.Flag(code)})
 	http.Redirect(w, r, "/admin?ok=svc_set", http.StatusSeeOther)
 }
 
@@ -416,7 +435,7 @@ func (m *Module) handleAdminServiceFlag(w http.ResponseWriter, r *http.Request)
 // config; this only records which provider/model to use, taking effect at once.
 func (m *Module) handleAdminAI(w http.ResponseWriter, r *http.Request) {
 	claims, _ := auth.ClaimsFromContext(r.Context())
-	if !canManageUsers(claims) {
+	if !m.can(r, auth.PermAIConfigure) {
 		http.Error(w, "forbidden", http.StatusForbidden)
 		return
 	}
@@ -441,20 +460,58 @@ func (m *Module) handleAdminAI(w http.ResponseWriter, r *http.Request) {
 			by = &id
 		}
 	}
+	cur := m.ai.AdminView()
+	before := ai.AISettings{Enabled: cur.Enabled, ReviewCheck: cur.ReviewCheck, AutoTranslate: cur.AutoTranslate,
+		ModerateModel: cur.ModerateModel, Provider: cur.Provider, TranslateModel: cur.TranslateModel, MaxTokens: cur.MaxTokens}
 	if err := m.ai.UpdateSettings(r.Context(), in, by); err != nil {
 		m.rt.Logger.Warn("update ai settings", zap.Error(err))
 		http.Redirect(w, r, "/admin?ok=ai_bad", http.StatusSeeOther)
 		return
 	}
+	m.auth.Audit(r, auth.AuditRecord{Action: "ai.settings", TargetType: "settings", TargetID: "ai", Before: before, After: in})
 	http.Redirect(w, r, "/admin?ok=ai_set", http.StatusSeeOther)
 }
 
+// handleAdminConfigReload re-reads the configuration file and applies it, the
+// same as sending the process SIGHUP. Only the seeded admin may: a reload can
+// swap mail relays and channel credentials. The answer names what changed,
+// never the values.
+func (m *Module) handleAdminConfigReload(w http.ResponseWriter, r *http.Request) {
+	claims, _ := auth.ClaimsFromContext(r.Context())
+	if claims == nil || !m.can(r, auth.PermConfigReload) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	changes, err := m.rt.ReloadConfig(r.Context())
+	m.rt.Logger.Info("config reload requested",
+		zap.String("by", claims.Subject), zap.Strings("changed", changes.Paths()), zap.Error(err))
+	out := map[string]any{"status": "ok", "changed": changes.Paths()}
+	status := http.StatusOK
+	switch {
+	case errors.Is(err, shanraq.ErrReloadDisabled):
+		out["status"], status = "disabled", http.StatusNotImplemented
+	case errors.Is(err, shanraq.ErrRestartRequired):
+		out["status"], status = "restart_required", http.StatusConflict
+		out["restart_required"] = changes.RestartOnly()
+	case err != nil:
+		out["status"], status = "error", http.StatusUnprocessableEntity
+	}
+	if err != nil {
+		out["error"] = err.Error()
+	}
+	m.auth.Audit(r, auth.AuditRecord{Action: "config.reload", TargetType: "settings", TargetID: "config",
+		After: map[string]any{"status": out["status"], "changed": changes.Paths()}})
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(out)
+}
+
 // handleAdminAgentDecide approves or rejects a real-estate agent profile. Only
 // a verified agent gets the public "Agent" badge and page, so this is the trust
 // gate for the whole feature.
 func (m *Module) handleAdminAgentDecide(w http.ResponseWriter, r *http.Request) {
 	claims, _ := auth.ClaimsFromContext(r.Context())
-	if !canManageUsers(claims) {
+	if !m.can(r, auth.PermPartnersDecide) {
 		http.Error(w, "forbidden", http.StatusForbidden)
 		return
 	}
@@ -483,12 +540,14 @@ func (m *Module) handleAdminAgentDecide(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
+	m.auth.Audit(r, auth.AuditRecord{Action: "agent.decide", TargetType: "user", TargetID: uid.String(),
+		After: map[string]any{"status": status, "reason": reason}})
 	http.Redirect(w, r, "/admin?ok=agent_set", http.StatusSeeOther)
 }
 
 func (m *Module) handleAdminHideComment(w http.ResponseWriter, r *http.Request) {
 	claims, _ := auth.ClaimsFromContext(r.Context())
-	if !canModerate(claims) {
+	if claims == nil || !m.can(r, auth.PermCommentsHide) {
 		http.Error(w, "forbidden", http.StatusForbidden)
 		return
 	}
@@ -502,6 +561,8 @@ func (m *Module) handleAdminHideComment(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
+	m.auth.Audit(r, auth.AuditRecord{Action: "comment.hide", TargetType: "comment", TargetID: id.String(),
+		After: map[string]any{"reason": r.FormValue("reason")}})
 	// A hidden comment used to disappear with no trace and no way for its
 	// author to learn why. Record the decision so it can be read and contested.
 	reason := strings.TrimSpace(r.FormValue("reason"))
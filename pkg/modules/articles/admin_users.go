@@ -9,6 +9,7 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
+	"shanraq.org/pkg/events"
 	"shanraq.org/pkg/modules/auth"
 )
 
@@ -20,7 +21,7 @@ import (
 // allowed to manage accounts at all.
 func (m *Module) adminActor(r *http.Request) (uuid.UUID, bool) {
 	claims, _ := auth.ClaimsFromContext(r.Context())
-	if !canManageUsers(claims) {
+	if claims == nil || !m.can(r, auth.PermUsersManage) {
 		return uuid.Nil, false
 	}
 	id, err := uuid.Parse(claims.Subject)
@@ -47,6 +48,34 @@ func (m *Module) adminUserAction(w http.ResponseWriter, r *http.Request) (target
 	return target, actor, true
 }
 
+// adminTarget loads the account an action is about, writing the response
+// itself when it cannot. What it returns is also the "before" of the audit
+// entry.
+func (m *Module) adminTarget(w http.ResponseWriter, r *http.Request, target uuid.UUID) (auth.AdminUser, bool) {
+	u, err := m.users.GetAdminUser(r.Context(), target)
+	switch {
+	case errors.Is(err, auth.ErrUserNotFound):
+		http.NotFound(w, r)
+		return u, false
+	case err != nil:
+		m.rt.Logger.Error("admin load user", zap.Error(err))
+		http.Redirect(w, r, backToUsers(r, "user_failed"), http.StatusSeeOther)
+		return u, false
+	}
+	return u, true
+}
+
+// mayActOnRoot refuses, writing the response itself, a role change or delete
+// that a non-root administrator may not make: handing out a root role, or
+// touching an account that holds one. newRole is empty for a delete.
+func (m *Module) mayActOnRoot(w http.ResponseWriter, r *http.Request, u auth.AdminUser, newRole string) bool {
+	if actorIsRoot(r) || (!auth.IsRootRole(newRole) && !auth.IsRootRole(u.Role)) {
+		return true
+	}
+	http.Redirect(w, r, backToUsers(r, "user_root_only"), http.StatusSeeOther)
+	return false
+}
+
 // backToUsers returns to the account register, preserving the search that was
 // open so a correction made from a filtered list does not throw the list away.
 func backToUsers(r *http.Request, notice string) string {
@@ -84,6 +113,10 @@ func (m *Module) handleAdminUserUpdate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	verified := r.FormValue("verified") == "on"
+	before, ok := m.adminTarget(w, r, target)
+	if !ok {
+		return
+	}
 	if err := m.users.UpdateUserByAdmin(r.Context(), target, first, last, middle, verified); err != nil {
 		if errors.Is(err, auth.ErrUserNotFound) {
 			http.NotFound(w, r)
@@ -93,6 +126,9 @@ func (m *Module) handleAdminUserUpdate(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, backToUsers(r, "user_failed"), http.StatusSeeOther)
 		return
 	}
+	m.auth.Audit(r, auth.AuditRecord{Action: "user.update", TargetType: "user", TargetID: target.String(),
+		Before: map[string]any{"first_name": before.First, "last_name": before.Last, "middle_name": before.Middle, "verified": before.Verified},
+		After:  map[string]any{"first_name": first, "last_name": last, "middle_name": middle, "verified": verified}})
 	http.Redirect(w, r, backToUsers(r, "user_saved"), http.StatusSeeOther)
 }
 
@@ -103,10 +139,14 @@ func (m *Module) handleAdminUserRole(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	role := strings.ToLower(strings.TrimSpace(r.FormValue("role")))
-	if !contains(assignableRoles, role) {
+	if !contains(m.assignableRoles(r.Context()), role) {
 		http.Redirect(w, r, backToUsers(r, "user_failed"), http.StatusSeeOther)
 		return
 	}
+	before, ok := m.adminTarget(w, r, target)
+	if !ok || !m.mayActOnRoot(w, r, before, role) {
+		return
+	}
 	// Demoting yourself locks you out of the panel you are standing in, and the
 	// only way back is a database console. Refuse rather than let one careless
 	// dropdown end administrative access to the site.
@@ -126,6 +166,8 @@ func (m *Module) handleAdminUserRole(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+	m.auth.Audit(r, auth.AuditRecord{Action: "user.role", TargetType: "user", TargetID: target.String(),
+		Before: map[string]any{"role": before.Role}, After: map[string]any{"role": role}})
 	http.Redirect(w, r, backToUsers(r, "role_set"), http.StatusSeeOther)
 }
 
@@ -143,6 +185,10 @@ func (m *Module) handleAdminUserDelete(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, backToUsers(r, "user_self_delete"), http.StatusSeeOther)
 		return
 	}
+	before, ok := m.adminTarget(w, r, target)
+	if !ok || !m.mayActOnRoot(w, r, before, "") {
+		return
+	}
 	if err := m.users.DeleteUserByAdmin(r.Context(), target); err != nil {
 		switch {
 		case errors.Is(err, auth.ErrUserNotFound):
@@ -155,5 +201,8 @@ func (m *Module) handleAdminUserDelete(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+	m.auth.Audit(r, auth.AuditRecord{Action: "user.delete", TargetType: "user", TargetID: target.String(),
+		Before: map[string]any{"email": before.Email, "role": before.Role, "first_name": before.First, "last_name": before.Last}})
+	m.announce(r.Context(), events.UserDeleted{UserID: target, ByAdmin: true})
 	http.Redirect(w, r, backToUsers(r, "user_deleted"), http.StatusSeeOther)
 }
@@ -504,16 +504,15 @@ func (m *Module) handleTrack(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// metricsFlushLoop persists buffered counters periodically and once more on
-// shutdown, so the last partial window is not lost.
+// metricsFlushLoop persists buffered counters periodically. The last partial
+// window is written by Stop, which the application waits for; a final flush
+// here raced the process exit and usually lost.
 func (m *Module) metricsFlushLoop(ctx context.Context) {
 	t := time.NewTicker(30 * time.Second)
 	defer t.Stop()
 	for {
 		select {
 		case <-ctx.Done():
-			// Detach from the cancelled request context for the final write.
-			m.metrics.Flush(context.Background())
 			return
 		case <-t.C:
 			m.metrics.Flush(ctx)
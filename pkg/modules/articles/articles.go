@@ -8,13 +8,14 @@ import (
 	"strings"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
 	"go.uber.org/zap"
+	"shanraq.org/pkg/events"
 	"shanraq.org/pkg/modules/ai"
 	"shanraq.org/pkg/modules/auth"
 	"shanraq.org/pkg/modules/jobs"
 	"shanraq.org/pkg/modules/media"
 	"shanraq.org/pkg/modules/ratings"
-	"shanraq.org/pkg/modules/syndicate"
 	"shanraq.org/pkg/shanraq"
 	"shanraq.org/pkg/transport/validate"
 )
@@ -51,6 +52,7 @@ type Module struct {
 	content       *ContentStore
 	predictions   *PredictionStore
 	tariffs       *TariffStore
+	exports       *DataExportStore
 	metrics       *Metrics
 	geoip         *geoIP
 	excludeEmails map[string]bool
@@ -58,7 +60,6 @@ type Module struct {
 	jobs          *jobs.Store
 	auth          *auth.Module
 	ai            *ai.Module
-	syndicate     *syndicate.Module
 	media         *media.Module
 	mailer        Mailer
 	tmpl          *template.Template
@@ -67,14 +68,36 @@ type Module struct {
 }
 
 // New builds the articles module. It depends on auth (browser sessions), ai
-// (writing assistant), syndicate (publish → external channels), and a mailer
-// (listing expiry reminders).
-func New(authModule *auth.Module, aiModule *ai.Module, syndicateModule *syndicate.Module, mediaModule *media.Module, mailer Mailer) *Module {
-	return &Module{auth: authModule, ai: aiModule, syndicate: syndicateModule, media: mediaModule, mailer: mailer}
+// (writing assistant), media (uploads) and a mailer (listing expiry
+// reminders). What happens elsewhere when an article is published — Telegram,
+// IndexNow — is not its business: it publishes events (see package events) and
+// whoever cares subscribes.
+func New(authModule *auth.Module, aiModule *ai.Module, mediaModule *media.Module, mailer Mailer) *Module {
+	return &Module{auth: authModule, ai: aiModule, media: mediaModule, mailer: mailer}
 }
 
 func (m *Module) Name() string { return "articles" }
 
+// DependsOn names the modules handed to New, so they are initialized before
+// this one and still running while it stops. The mailer counts only when it
+// is itself a module; anything else satisfying Mailer has no lifecycle.
+func (m *Module) DependsOn() []string {
+	var deps []string
+	if m.auth != nil {
+		deps = append(deps, m.auth.Name())
+	}
+	if m.ai != nil {
+		deps = append(deps, m.ai.Name())
+	}
+	if m.media != nil {
+		deps = append(deps, m.media.Name())
+	}
+	if mod, ok := m.mailer.(shanraq.Module); ok {
+		deps = append(deps, mod.Name())
+	}
+	return deps
+}
+
 // Init parses templates and wires the store.
 func (m *Module) Init(ctx context.Context, rt *shanraq.Runtime) error {
 	m.rt = rt
@@ -85,6 +108,7 @@ func (m *Module) Init(ctx context.Context, rt *shanraq.Runtime) error {
 	m.refs = NewReferralStore(rt.DB)
 	m.reagents = NewAgentStore(rt.DB)
 	m.pay = NewPaymentStore(rt.DB)
+	m.exports = NewDataExportStore(rt.DB)
 	// Which acquirer is live (and whether payments are on) is a runtime choice in
 	// the admin panel — secrets stay in config. Defaults come from config so a
 	// fresh DB has a starting point; the effective provider is built per request
@@ -254,6 +278,12 @@ func (m *Module) browserRoutes(r chi.Router) {
 	// Studio auth pages (public).
 	r.Get("/studio/login", m.handleLoginPage)
 	r.Post("/studio/login", m.handleLoginSubmit)
+	r.Post("/studio/login/passkey/options", m.handlePasskeyOptions)
+	r.Post("/studio/login/passkey", m.handlePasskeyLogin)
+	r.Get("/studio/login/email", m.handleEmailSignInPage)
+	r.Post("/studio/login/email", m.handleEmailSignInSubmit)
+	r.Get("/studio/login/email/confirm", m.handleEmailSignInLink)
+	r.Post("/studio/login/email/confirm", m.handleEmailSignInConfirm)
 	r.Get("/studio/register", m.handleRegisterPage)
 	r.Post("/studio/register", m.handleRegisterSubmit)
 	r.Post("/studio/logout", m.handleLogout)
@@ -270,6 +300,13 @@ func (m *Module) browserRoutes(r chi.Router) {
 		r.Post("/studio/avatar", m.handleAvatarUpload)
 		r.Post("/studio/avatar/delete", m.handleAvatarDelete)
 		r.Post("/studio/delete", m.handleDeleteAccount)
+		r.Post("/studio/export", m.handleDataExportRequest)
+		r.Get("/studio/export/{token}", m.handleDataExportDownload)
+		r.Post("/studio/passkeys/options", m.handlePasskeyRegisterOptions)
+		r.Post("/studio/passkeys", m.handlePasskeyRegister)
+		r.Post("/studio/passkeys/{id}/delete", m.handlePasskeyDelete)
+		r.Post("/studio/sessions/{id}/revoke", m.handleSessionRevoke)
+		r.Post("/studio/sessions/revoke-others", m.handleSessionsRevokeOthers)
 		r.Get("/studio/author", m.handleAuthorVerifyPage)
 		r.Post("/studio/author/name", m.handleAuthorName)
 		r.Post("/studio/author/phone", m.handleAuthorPhone)
@@ -288,6 +325,7 @@ func (m *Module) browserRoutes(r chi.Router) {
 		r.Post("/studio/a/{id}/delete", m.handleDeleteDraft)
 		r.Post("/studio/a/{id}/translate", m.handleTranslate)
 		r.Get("/studio/a/{id}/translate/status", m.handleTranslateStatus)
+		r.Get("/studio/a/{id}/translate/stream", m.handleTranslateStream)
 		r.Get("/favorites", m.handleFavorites)
 		// Advertiser cabinet (Phase 0b MVP — order capture, billing later).
 		r.Get("/agent", m.handleAgentCabinet)
@@ -300,31 +338,59 @@ func (m *Module) browserRoutes(r chi.Router) {
 		r.Post("/listings/{id}/report", m.handleListingReport)
 	})
 
-	// Admin control panel (staff roles only).
+	// Admin control panel. The group lets in whoever may see the dashboard;
+	// each route that changes something asks for its own permission.
 	r.Group(func(r chi.Router) {
-		r.Use(m.auth.RequireSession("/studio/login", adminRoles...))
+		r.Use(m.auth.RequireSessionPermission("/studio/login", auth.PermAdminView))
 		r.Get("/admin", m.handleAdmin)
-		r.Post("/admin/roles", m.handleAdminAssignRole)
-		r.Post("/admin/users/{id}", m.handleAdminUserUpdate)
-		r.Post("/admin/users/{id}/role", m.handleAdminUserRole)
-		r.Post("/admin/users/{id}/delete", m.handleAdminUserDelete)
-		r.Post("/admin/services", m.handleAdminServiceFlag)
-		r.Post("/admin/ai", m.handleAdminAI)
-		r.Post("/admin/agents/{id}/decide", m.handleAdminAgentDecide)
-		r.Post("/admin/orgs/{id}/decide", m.handleOrgDecide)
-		r.Post("/admin/comments/{id}/hide", m.handleAdminHideComment)
-		r.Post("/admin/appeals/{id}/resolve", m.handleAdminResolveAppeal)
-		r.Post("/admin/articles/{id}/decide", m.handleAdminDecideArticle)
-		r.Get("/admin/predictions", m.handleAdminPredictions)
-		r.Get("/admin/predictions/{id}", m.handleAdminPredictions)
-		r.Post("/admin/predictions", m.handleAdminPredictionSave)
-		r.Post("/admin/predictions/{id}/delete", m.handleAdminPredictionDelete)
-		r.Get("/admin/pages", m.handleAdminPages)
-		r.Get("/admin/pages/{key}", m.handleAdminPageEdit)
-		r.Post("/admin/pages/{key}", m.handleAdminPageSave)
-		r.Post("/admin/payments", m.handleAdminPayments)
-		r.Get("/admin/tariffs", m.handleAdminTariffs)
-		r.Post("/admin/tariffs", m.handleAdminTariffsSave)
+		r.Group(func(r chi.Router) {
+			r.Use(m.permit(auth.PermUsersManage))
+			r.Post("/admin/roles", m.handleAdminAssignRole)
+			r.Get("/admin/users/{id}", m.handleAdminUserPage)
+			r.Post("/admin/users/{id}", m.handleAdminUserUpdate)
+			r.Post("/admin/users/{id}/role", m.handleAdminUserRole)
+			r.Post("/admin/users/{id}/delete", m.handleAdminUserDelete)
+			r.Post("/admin/users/{id}/sessions/{sid}/revoke", m.handleAdminSessionRevoke)
+			r.Post("/admin/users/{id}/sessions/revoke", m.handleAdminSessionsRevoke)
+			r.Post("/admin/users/{id}/export", m.handleAdminUserExport)
+			r.Get("/admin/throttles", m.handleAdminThrottles)
+			r.Post("/admin/throttles/unblock", m.handleAdminUnthrottle)
+		})
+		r.Group(func(r chi.Router) {
+			r.Use(m.permit(auth.PermRolesManage))
+			r.Get("/admin/access", m.handleAdminAccess)
+			r.Post("/admin/access", m.handleAdminRoleCreate)
+			r.Post("/admin/access/{role}", m.handleAdminRolePermissions)
+		})
+		r.Group(func(r chi.Router) {
+			r.Use(m.permit(auth.PermAuditView))
+			r.Get("/admin/audit", m.handleAdminAudit)
+			r.Get("/admin/audit/export", m.handleAdminAuditExport)
+		})
+		r.With(m.permit(auth.PermServicesManage)).Post("/admin/services", m.handleAdminServiceFlag)
+		r.With(m.permit(auth.PermAIConfigure)).Post("/admin/ai", m.handleAdminAI)
+		r.With(m.permit(auth.PermConfigReload)).Post("/admin/config/reload", m.handleAdminConfigReload)
+		r.With(m.permit(auth.PermPartnersDecide)).Post("/admin/agents/{id}/decide", m.handleAdminAgentDecide)
+		r.With(m.permit(auth.PermPartnersDecide)).Post("/admin/orgs/{id}/decide", m.handleOrgDecide)
+		r.With(m.permit(auth.PermCommentsHide)).Post("/admin/comments/{id}/hide", m.handleAdminHideComment)
+		r.With(m.permit(auth.PermAppealsResolve)).Post("/admin/appeals/{id}/resolve", m.handleAdminResolveAppeal)
+		r.With(m.permit(auth.PermArticlesDecide)).Post("/admin/articles/{id}/decide", m.handleAdminDecideArticle)
+		r.Group(func(r chi.Router) {
+			r.Use(m.permit(auth.PermPredictionsEdit))
+			r.Get("/admin/predictions", m.handleAdminPredictions)
+			r.Get("/admin/predictions/{id}", m.handleAdminPredictions)
+			r.Post("/admin/predictions", m.handleAdminPredictionSave)
+			r.Post("/admin/predictions/{id}/delete", m.handleAdminPredictionDelete)
+		})
+		r.Group(func(r chi.Router) {
+			r.Use(m.permit(auth.PermPagesEdit))
+			r.Get("/admin/pages", m.handleAdminPages)
+			r.Get("/admin/pages/{key}", m.handleAdminPageEdit)
+			r.Post("/admin/pages/{key}", m.handleAdminPageSave)
+		})
+		r.With(m.permit(auth.PermPaymentsConfigure)).Post("/admin/payments", m.handleAdminPayments)
+		r.With(m.permit(auth.PermTariffsEdit)).Get("/admin/tariffs", m.handleAdminTariffs)
+		r.With(m.permit(auth.PermTariffsEdit)).Post("/admin/tariffs", m.handleAdminTariffsSave)
 	})
 }
 
@@ -336,9 +402,35 @@ func (m *Module) render(w http.ResponseWriter, name string, data any) {
 	}
 }
 
+// announce publishes a domain event. Subscribers are side effects of a change
+// the caller has already committed, so a failure is logged and never undoes it.
+//
+// A published article still being translated holds back its durable
+// reactions — the Telegram post above all — until the translation has
+// finished, so they go out with every language. They go out if it fails, too:
+// the article is published either way.
+func (m *Module) announce(ctx context.Context, ev shanraq.Event) {
+	if pub, ok := ev.(events.ArticlePublished); ok {
+		if job, ok := m.latestTranslation(ctx, pub.ArticleID.String()); ok {
+			switch job.Status {
+			case "pending", "retry", "running", "blocked":
+				ctx = jobs.WithAfter(ctx, jobs.After{Jobs: []uuid.UUID{job.ID}, OnFailure: jobs.RunAfterFailure})
+			}
+		}
+	}
+	if err := m.rt.Events.Publish(ctx, ev); err != nil {
+		m.rt.Logger.Warn("publish event", zap.String("event", ev.EventName()), zap.Error(err))
+	}
+}
+
 var _ interface {
 	shanraq.Module
 	shanraq.RouterModule
 	shanraq.InitializerModule
 	shanraq.StarterModule
+	shanraq.StopperModule
+	shanraq.DependentModule
+	shanraq.HealthChecker
+	auth.SignupGate
+	auth.SessionDescriber
 } = (*Module)(nil)
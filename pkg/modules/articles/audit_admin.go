@@ -0,0 +1,149 @@
+package articles
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"shanraq.org/pkg/modules/auth"
+)
+
+// /admin/audit: the audit log the auth module keeps of every privileged
+// action, searchable by who, what, which target and when, and downloadable for
+// a legal request. Walking the hash chain reads the whole table, so the page
+// does it only when asked; the export always does, because the head hash it
+// writes down is what a later export is compared against.
+
+// auditFilter is the search form as typed, kept to fill the form back in.
+type auditFilter struct {
+	Actor  string `json:"actor,omitempty"`
+	Action string `json:"action,omitempty"`
+	Target string `json:"target,omitempty"`
+	From   string `json:"from,omitempty"`
+	To     string `json:"to,omitempty"`
+}
+
+// query turns the form into a search. Dates are whole days in UTC, To
+// included; one that does not parse is ignored rather than refused.
+func (f auditFilter) query() auth.AuditQuery {
+	q := auth.AuditQuery{Actor: f.Actor, Action: f.Action, Target: f.Target}
+	if d := parseDate(f.From); d != nil {
+		q.From = *d
+	}
+	if d := parseDate(f.To); d != nil {
+		q.To = d.AddDate(0, 0, 1)
+	}
+	return q
+}
+
+// values is the filter as a query string, for the export link.
+func (f auditFilter) values() url.Values {
+	v := url.Values{}
+	for k, s := range map[string]string{"actor": f.Actor, "action": f.Action, "target": f.Target, "from": f.From, "to": f.To} {
+		if s != "" {
+			v.Set(k, s)
+		}
+	}
+	return v
+}
+
+func auditFilterFrom(q url.Values) auditFilter {
+	return auditFilter{
+		Actor:  strings.TrimSpace(q.Get("actor")),
+		Action: strings.TrimSpace(q.Get("action")),
+		Target: strings.TrimSpace(q.Get("target")),
+		From:   strings.TrimSpace(q.Get("from")),
+		To:     strings.TrimSpace(q.Get("to")),
+	}
+}
+
+// adminAuditPage is /admin/audit.
+type adminAuditPage struct {
+	Base
+	Filter    auditFilter
+	Entries   []auth.AuditEntry
+	ExportURL string
+	// Check is set when the chain was walked for this page.
+	Check *auth.AuditCheck
+	Error string
+}
+
+func (m *Module) handleAdminAudit(w http.ResponseWriter, r *http.Request) {
+	lang := m.resolveLang(w, r)
+	f := auditFilterFrom(r.URL.Query())
+	page := adminAuditPage{
+		Base:      m.base(r, T(lang, "aud.title"), lang),
+		Filter:    f,
+		ExportURL: "/admin/audit/export",
+	}
+	if v := f.values(); len(v) > 0 {
+		page.ExportURL += "?" + v.Encode()
+	}
+	entries, err := m.auth.AuditLog(r.Context(), f.query())
+	if err != nil {
+		m.rt.Logger.Error("list audit log", zap.Error(err))
+		page.Error = T(lang, "aud.err")
+	}
+	page.Entries = entries
+	if r.URL.Query().Get("verify") == "1" {
+		check, err := m.auth.VerifyAudit(r.Context())
+		if err != nil {
+			m.rt.Logger.Error("verify audit log", zap.Error(err))
+			page.Error = T(lang, "aud.err")
+		} else {
+			page.Check = &check
+		}
+	}
+	m.render(w, "admin_audit", page)
+}
+
+// auditExport is the downloaded file. Check covers the whole log, not just
+// the entries the filter kept: its head is the fingerprint of the log as it
+// stood, and each entry's prev_hash and hash let the recipient check the run
+// they were given.
+type auditExport struct {
+	ExportedAt time.Time         `json:"exported_at"`
+	ExportedBy string            `json:"exported_by"`
+	Filter     auditFilter       `json:"filter"`
+	Check      auth.AuditCheck   `json:"check"`
+	Entries    []auth.AuditEntry `json:"entries"`
+}
+
+// handleAdminAuditExport downloads the entries matching the filter as JSON.
+// The export is itself an entry: who took a copy of the log is part of it.
+func (m *Module) handleAdminAuditExport(w http.ResponseWriter, r *http.Request) {
+	f := auditFilterFrom(r.URL.Query())
+	q := f.query()
+	q.Limit = auth.AuditExportLimit
+	entries, err := m.auth.AuditLog(r.Context(), q)
+	if err != nil {
+		m.rt.Logger.Error("export audit log", zap.Error(err))
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	check, err := m.auth.VerifyAudit(r.Context())
+	if err != nil {
+		m.rt.Logger.Error("verify audit log", zap.Error(err))
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if entries == nil {
+		entries = []auth.AuditEntry{}
+	}
+	out := auditExport{ExportedAt: time.Now().UTC(), Filter: f, Check: check, Entries: entries}
+	if claims, ok := auth.ClaimsFromContext(r.Context()); ok {
+		out.ExportedBy = claims.Email
+	}
+	m.auth.Audit(r, auth.AuditRecord{Action: "audit.export", TargetType: "audit",
+		After: map[string]any{"filter": f, "entries": len(entries), "head": check.Head}})
+
+	name := "audit-export-" + out.ExportedAt.Format("20060102-150405") + ".json"
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(out)
+}
@@ -0,0 +1,29 @@
+package articles
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+// The dates are whole days and To is included; the export link carries only
+// what was filled in.
+func TestAuditFilter(t *testing.T) {
+	f := auditFilterFrom(url.Values{"action": {" user. "}, "from": {"2026-03-01"}, "to": {"2026-03-31"}, "target": {""}})
+	q := f.query()
+	if q.Action != "user." || q.Target != "" {
+		t.Fatalf("query = %+v", q)
+	}
+	if want := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC); !q.From.Equal(want) {
+		t.Errorf("From = %v, want %v", q.From, want)
+	}
+	if want := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC); !q.To.Equal(want) {
+		t.Errorf("To = %v, want the day after the last one asked for (%v)", q.To, want)
+	}
+	if got := f.values().Encode(); got != "action=user.&from=2026-03-01&to=2026-03-31" {
+		t.Errorf("values = %q", got)
+	}
+	if q := auditFilterFrom(url.Values{"from": {"March"}}).query(); !q.From.IsZero() {
+		t.Errorf("an unreadable date filtered from %v", q.From)
+	}
+}
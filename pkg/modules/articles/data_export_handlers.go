@@ -0,0 +1,274 @@
+package articles
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"shanraq.org/pkg/modules/auth"
+	"shanraq.org/pkg/modules/jobs"
+	"shanraq.org/pkg/shanraq"
+)
+
+const (
+	// JobDataExport builds one requested archive and mails its link.
+	JobDataExport = "data_export"
+	// JobDataExportPurge deletes the archives whose links have expired.
+	JobDataExportPurge = "data_export_purge"
+)
+
+// errExportUnavailable is an export asked for on a site that cannot deliver
+// one: no job queue to build it or no mailer to send the link.
+var errExportUnavailable = errors.New("data export unavailable")
+
+type dataExportPayload struct {
+	ExportID string `json:"export_id"`
+}
+
+// requestDataExport records the request and queues the build.
+func (m *Module) requestDataExport(ctx context.Context, userID uuid.UUID, requestedBy *uuid.UUID, sendTo string) error {
+	if m.jobs == nil || m.mailer == nil || m.exports == nil {
+		return errExportUnavailable
+	}
+	e, err := m.exports.Request(ctx, userID, requestedBy, sendTo)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(dataExportPayload{ExportID: e.ID.String()})
+	if err == nil {
+		_, err = m.jobs.Enqueue(ctx, jobs.Job{
+			ID:          uuid.New(),
+			UserID:      userID,
+			Name:        JobDataExport,
+			Payload:     payload,
+			RunAt:       time.Now(),
+			MaxAttempts: 3,
+		})
+	}
+	if err != nil {
+		// Unqueued, the request would hold the account's daily export until
+		// it expired.
+		if ferr := m.exports.Fail(ctx, e.ID); ferr != nil {
+			m.rt.Logger.Warn("fail data export", zap.Error(ferr))
+		}
+		return fmt.Errorf("enqueue data export: %w", err)
+	}
+	return nil
+}
+
+// handleDataExportRequest is "Download my data" in /studio/profile. The link
+// goes to the account's own address, so a borrowed session alone gets nobody
+// the archive.
+func (m *Module) handleDataExportRequest(w http.ResponseWriter, r *http.Request) {
+	authorID, ok := m.authorID(r)
+	claims, _ := auth.ClaimsFromContext(r.Context())
+	if !ok || claims == nil {
+		http.Redirect(w, r, "/studio/login", http.StatusSeeOther)
+		return
+	}
+	code := "export_queued"
+	if err := m.requestDataExport(r.Context(), authorID, nil, claims.Email); err != nil {
+		switch {
+		case errors.Is(err, ErrExportRecent):
+			code = "export_recent"
+		default:
+			m.rt.Logger.Error("request data export", zap.Error(err))
+			code = "export_failed"
+		}
+	}
+	http.Redirect(w, r, "/studio/profile?ok="+code, http.StatusSeeOther)
+}
+
+// handleAdminUserExport answers a data-subject request received some other
+// way — a letter, a lawyer. The archive is the account's, but the link goes
+// to the administrator asking, who passes it on through whatever channel the
+// request came by.
+func (m *Module) handleAdminUserExport(w http.ResponseWriter, r *http.Request) {
+	target, actor, ok := m.adminUserAction(w, r)
+	if !ok {
+		return
+	}
+	if _, ok := m.adminTarget(w, r, target); !ok {
+		return
+	}
+	claims, _ := auth.ClaimsFromContext(r.Context())
+	back := "/admin/users/" + target.String()
+	if err := m.requestDataExport(r.Context(), target, &actor, claims.Email); err != nil {
+		m.rt.Logger.Error("request data export", zap.Error(err))
+		http.Redirect(w, r, back+"?ok=export_failed", http.StatusSeeOther)
+		return
+	}
+	m.auth.Audit(r, auth.AuditRecord{Action: "user.export", TargetType: "user", TargetID: target.String(),
+		After: map[string]string{"send_to": claims.Email}})
+	http.Redirect(w, r, back+"?ok=export_queued", http.StatusSeeOther)
+}
+
+// handleDataExportDownload serves the archive behind a mailed link to the
+// account it holds or the administrator who asked for it. Anyone else — and
+// an expired link — is sent to their own profile, where they can ask for one
+// of their own.
+func (m *Module) handleDataExportDownload(w http.ResponseWriter, r *http.Request) {
+	viewer, ok := m.authorID(r)
+	if !ok {
+		http.Redirect(w, r, "/studio/login", http.StatusSeeOther)
+		return
+	}
+	e, archive, err := m.exports.Open(r.Context(), hashExportToken(chi.URLParam(r, "token")))
+	if err == nil && viewer != e.UserID && (e.RequestedBy == nil || *e.RequestedBy != viewer) {
+		err = ErrExportGone
+	}
+	if err != nil {
+		if !errors.Is(err, ErrExportGone) {
+			m.rt.Logger.Error("open data export", zap.Error(err))
+		}
+		http.Redirect(w, r, "/studio/profile?ok=export_gone", http.StatusSeeOther)
+		return
+	}
+	if err := m.exports.Downloaded(r.Context(), e.ID); err != nil {
+		m.rt.Logger.Warn("note data export download", zap.Error(err))
+	}
+	name := "shanraq-data-" + e.CreatedAt.UTC().Format("20060102") + ".zip"
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = w.Write(archive)
+}
+
+// handleDataExportJob builds the archive, stores it and mails the link. A
+// build that keeps failing marks the request failed so the account may ask
+// again; a link that cannot be mailed does the same, since nobody else will
+// ever see it.
+func (m *Module) handleDataExportJob(ctx context.Context, _ *shanraq.Runtime, job jobs.Job) error {
+	var payload dataExportPayload
+	if err := job.Decode(&payload); err != nil {
+		return fmt.Errorf("decode payload: %w", err)
+	}
+	id, err := uuid.Parse(payload.ExportID)
+	if err != nil {
+		return fmt.Errorf("bad export id: %w", err)
+	}
+	e, err := m.exports.Get(ctx, id)
+	if errors.Is(err, ErrExportGone) {
+		return nil // purged, or the account deleted meanwhile
+	}
+	if err != nil {
+		return err
+	}
+	if e.Status != "pending" {
+		return nil
+	}
+
+	archive, err := m.buildDataExport(ctx, e.UserID)
+	if err != nil {
+		if job.Attempts >= job.MaxAttempts {
+			if ferr := m.exports.Fail(ctx, e.ID); ferr != nil {
+				m.rt.Logger.Warn("fail data export", zap.Error(ferr))
+			}
+		}
+		return fmt.Errorf("build data export: %w", err)
+	}
+	token, err := newExportToken()
+	if err != nil {
+		return err
+	}
+	expires, err := m.exports.Ready(ctx, e.ID, archive, hashExportToken(token))
+	if errors.Is(err, ErrExportGone) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	link := strings.TrimRight(m.rt.Config.PublicBase(), "/") + "/studio/export/" + token
+	subject, body := m.dataExportEmail(ctx, e, link, expires)
+	if err := m.mailer.Send(ctx, e.SendTo, subject, body); err != nil {
+		m.rt.Logger.Error("data export link not sent", zap.String("to", e.SendTo), zap.Error(err))
+		if ferr := m.exports.Fail(ctx, e.ID); ferr != nil {
+			m.rt.Logger.Warn("fail data export", zap.Error(ferr))
+		}
+	}
+	return nil
+}
+
+// buildDataExport reads every section for the account and zips it, with the
+// account's sessions, passkeys and sign-in history as the auth module
+// describes them — the raw rows carry token and key material that is
+// nobody's business to copy.
+func (m *Module) buildDataExport(ctx context.Context, userID uuid.UUID) ([]byte, error) {
+	files, err := m.exports.Sections(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := m.users.ListSessions(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("export sessions: %w", err)
+	}
+	passkeys, err := m.users.ListPasskeys(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("export passkeys: %w", err)
+	}
+	signins, err := m.users.ListSignIns(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("export sign-ins: %w", err)
+	}
+	files = append(files, exportFile{name: "security.json", data: map[string]any{
+		"sessions": sessions,
+		"passkeys": passkeys,
+		"signins":  signins,
+	}})
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		names = append(names, f.name)
+	}
+	files = append(files, exportFile{name: "export.json", data: map[string]any{
+		"account":      userID.String(),
+		"generated_at": time.Now().UTC(),
+		"files":        names,
+		"omitted":      "password hash, session and passkey secrets, and the identities of other people: moderators, referred accounts, reviewers",
+	}})
+	return buildArchive(files)
+}
+
+func (m *Module) dataExportEmail(ctx context.Context, e DataExport, link string, expires time.Time) (string, string) {
+	until := expires.UTC().Format("02.01.2006 15:04")
+	if e.RequestedBy == nil {
+		return "Ваши данные готовы к скачиванию — Shanraq.org", fmt.Sprintf(
+			"Здравствуйте!\n\nАрхив со всеми данными вашего аккаунта готов. "+
+				"Скачать его можно до %s (UTC), войдя в аккаунт:\n%s\n\n"+
+				"Если вы не запрашивали архив, смените пароль и завершите чужие сеансы в профиле.\n\n— Shanraq.org",
+			until, link)
+	}
+	subject := "the account"
+	if u, err := m.users.GetByID(ctx, e.UserID.String()); err == nil {
+		subject = u.Email
+	}
+	return "Архив данных по запросу субъекта — Shanraq.org", fmt.Sprintf(
+		"Архив данных аккаунта %s, запрошенный вами, готов. "+
+			"Ссылка действует до %s (UTC) и открывается только под вашим аккаунтом:\n%s\n\n— Shanraq.org",
+		subject, until, link)
+}
+
+// newExportToken is the secret part of a download link.
+func newExportToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("export token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashExportToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,151 @@
+package articles
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestBuildArchiveRoundTrip(t *testing.T) {
+	archive, err := buildArchive([]exportFile{
+		{name: "profile.json", data: []map[string]any{{"email": "a@b.c", "last_name": "Баймурза"}}},
+		{name: "favorites.json", data: []map[string]any{}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zr.File) != 2 || zr.File[0].Name != "profile.json" || zr.File[1].Name != "favorites.json" {
+		t.Fatalf("files = %v", zr.File)
+	}
+	f, err := zr.File[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var rows []map[string]string
+	if err := json.NewDecoder(f).Decode(&rows); err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0]["last_name"] != "Баймурза" {
+		t.Errorf("profile.json = %v", rows)
+	}
+	// An empty section is an empty list, not null: "nothing held" is an answer.
+	g, _ := zr.File[1].Open()
+	defer g.Close()
+	if b, _ := io.ReadAll(g); strings.TrimSpace(string(b)) != "[]" {
+		t.Errorf("favorites.json = %q", b)
+	}
+}
+
+func TestExportRowOmitsAndSpellsUUIDs(t *testing.T) {
+	id := uuid.New()
+	fields := []pgconn.FieldDescription{{Name: "id"}, {Name: "actor_id"}, {Name: "reason"}}
+	row := exportRow(fields, []any{[16]byte(id), [16]byte(uuid.New()), "spam"}, []string{"actor_id"})
+	if len(row) != 2 || row["id"] != id.String() || row["reason"] != "spam" {
+		t.Errorf("row = %v", row)
+	}
+}
+
+// Every section is one JSON file of rows picked by the account's id, and the
+// account's main records are all there.
+func TestDataExportSectionsAreTheAccounts(t *testing.T) {
+	seen := map[string]bool{}
+	for _, sec := range dataExportSections {
+		if seen[sec.file] {
+			t.Errorf("%s listed twice", sec.file)
+		}
+		seen[sec.file] = true
+		if !strings.HasSuffix(sec.file, ".json") || !strings.Contains(sec.query, "$1") {
+			t.Errorf("%s: %q", sec.file, sec.query)
+		}
+	}
+	for _, f := range []string{"profile.json", "articles.json", "listings.json", "comments.json", "moderation_actions.json", "appeals.json"} {
+		if !seen[f] {
+			t.Errorf("no %s", f)
+		}
+	}
+}
+
+func TestExportTokens(t *testing.T) {
+	a, err := newExportToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := newExportToken()
+	if a == b || len(a) < 40 {
+		t.Errorf("tokens %q, %q", a, b)
+	}
+	if h := hashExportToken(a); h == a || len(h) != 64 || h != hashExportToken(a) {
+		t.Errorf("hash(%q) = %q", a, h)
+	}
+}
+
+// Against the real schema: every section query runs, a self-service request
+// waits a day, an administrator's does not, and the link opens only for the
+// account or the administrator who asked.
+func TestDataExportFlow(t *testing.T) {
+	app := newTestApp(t)
+	ctx := context.Background()
+	m := app.module()
+	owner := app.createUser("export-owner-"+uuid.NewString()[:8]+"@example.com", "Secret123!")
+	other := "export-other-" + uuid.NewString()[:8] + "@example.com"
+	app.createUser(other, "Secret123!")
+	app.seedArticle(owner, "published")
+	app.seedListing(owner)
+
+	archive, err := m.buildDataExport(ctx, owner)
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil || len(zr.File) != len(dataExportSections)+2 {
+		t.Fatalf("archive of %d files, %v", len(zr.File), err)
+	}
+
+	e, err := m.exports.Request(ctx, owner, nil, "owner@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.exports.Request(ctx, owner, nil, "owner@example.com"); !errors.Is(err, ErrExportRecent) {
+		t.Errorf("second request the same day: %v", err)
+	}
+	admin := app.createUser("export-admin-"+uuid.NewString()[:8]+"@example.com", "Secret123!")
+	if _, err := m.exports.Request(ctx, owner, &admin, "admin@example.com"); err != nil {
+		t.Errorf("an administrator's request was held to the cooldown: %v", err)
+	}
+
+	token, _ := newExportToken()
+	if _, err := m.exports.Ready(ctx, e.ID, archive, hashExportToken(token)); err != nil {
+		t.Fatal(err)
+	}
+	if w := app.do(http.MethodGet, "/studio/export/"+token, nil, withCookie(app.login(other, "Secret123!"))); w.Header().Get("Location") != "/studio/profile?ok=export_gone" {
+		t.Errorf("another account got %d %s", w.Code, w.Header().Get("Location"))
+	}
+	if _, _, err := m.exports.Open(ctx, hashExportToken(token)); err != nil {
+		t.Errorf("open: %v", err)
+	}
+	if _, _, err := m.exports.Open(ctx, hashExportToken("guess")); !errors.Is(err, ErrExportGone) {
+		t.Errorf("open with a wrong token: %v", err)
+	}
+
+	app.exec(`UPDATE data_exports SET expires_at = NOW() - interval '1 minute' WHERE user_id = $1`, owner)
+	if _, _, err := m.exports.Open(ctx, hashExportToken(token)); !errors.Is(err, ErrExportGone) {
+		t.Errorf("open after expiry: %v", err)
+	}
+	if n, err := m.exports.Purge(ctx); err != nil || n < 2 {
+		t.Errorf("purge = %d, %v", n, err)
+	}
+}
@@ -0,0 +1,308 @@
+package articles
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go.uber.org/zap"
+	"shanraq.org/pkg/modules/auth"
+)
+
+// Sign-in by e-mail code, for the readers who only want to comment or keep
+// favourites and gave up at the password. The address is asked for, a link
+// and a six-digit code are mailed (auth.Module.IssueEmailSignIn), and either
+// one brings the reader to the same confirm step. An address with an account
+// signs in there; one without finishes registering — real name, place and
+// the same consent as /studio/register — and signs in as the new account.
+//
+// The link lands on a page with a button rather than signing in on GET: mail
+// scanners open every link in a message, and one that spent the token would
+// leave the reader with a dead link.
+
+// EmailSignInPage backs the e-mail sign-in steps.
+type EmailSignInPage struct {
+	Base
+	// Mode is the step: start (the address), code (the code it was sent),
+	// link (the mailed link's confirm button) or finish (the rest of
+	// registration, for an address with no account).
+	Mode   string
+	Email  string
+	Token  string // the mailed link's token, carried by link and finish
+	Code   string // the typed code, carried by finish
+	First  string
+	Last   string
+	Middle string
+	// PlaceID, Ref and Next ride along as on the register form.
+	PlaceID string
+	Ref     string
+	Next    string
+	Error   string
+	Notice  string
+}
+
+func (m *Module) emailSignInPage(r *http.Request, lang, mode string) EmailSignInPage {
+	title := "esi.title"
+	if mode == "finish" {
+		title = "esi.finish_title"
+	}
+	return EmailSignInPage{
+		Base: m.base(r, T(lang, title), lang), Mode: mode,
+		Email: strings.TrimSpace(r.FormValue("email")),
+		Ref:   strings.TrimSpace(r.FormValue("ref")),
+		Next:  safeNext(r.FormValue("next")),
+	}
+}
+
+func (m *Module) handleEmailSignInPage(w http.ResponseWriter, r *http.Request) {
+	lang := m.resolveLang(w, r)
+	m.render(w, "email_signin", m.emailSignInPage(r, lang, "start"))
+}
+
+// handleEmailSignInSubmit mails the link and code. Whatever the address, the
+// reader is sent on to the code step with the same words, so the form tells
+// nobody which addresses have accounts.
+func (m *Module) handleEmailSignInSubmit(w http.ResponseWriter, r *http.Request) {
+	lang := m.resolveLang(w, r)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "bad form", http.StatusBadRequest)
+		return
+	}
+	page := m.emailSignInPage(r, lang, "start")
+	if !m.auth.AllowAuthAttempt(r, "email_signin", page.Email) {
+		page.Error = T(lang, "form.err_rate_limit")
+		m.render(w, "email_signin", page)
+		return
+	}
+	if _, ok := auth.NormalizeEmail(page.Email); !ok {
+		page.Error = T(lang, "form.err_email_invalid")
+		m.render(w, "email_signin", page)
+		return
+	}
+	if err := m.auth.IssueEmailSignIn(r.Context(), page.Email, m.emailSignInConfirmURL(page)); err != nil {
+		m.rt.Logger.Error("issue email sign-in", zap.Error(err))
+		page.Error = T(lang, "esi.err_send")
+		m.render(w, "email_signin", page)
+		return
+	}
+	page.Mode = "code"
+	page.Notice = T(lang, "esi.sent")
+	m.render(w, "email_signin", page)
+}
+
+// emailSignInConfirmURL is the page the mailed link opens, carrying the
+// page's next and ref along.
+func (m *Module) emailSignInConfirmURL(page EmailSignInPage) string {
+	q := url.Values{}
+	if page.Next != "" {
+		q.Set("next", page.Next)
+	}
+	if page.Ref != "" {
+		q.Set("ref", page.Ref)
+	}
+	confirm := strings.TrimRight(m.rt.Config.PublicBase(), "/") + "/studio/login/email/confirm"
+	if len(q) > 0 {
+		confirm += "?" + q.Encode()
+	}
+	return confirm
+}
+
+// stepUpByEmail is the password form's second step for a staff account
+// signing in from a new country (auth.Module.NeedsStepUp) that has no passkey
+// to ask for: a code is mailed to the account's address, and the code step
+// signs in as it would from the e-mail form. The password alone opens
+// nothing.
+func (m *Module) stepUpByEmail(w http.ResponseWriter, r *http.Request, lang string, user auth.User) {
+	page := m.emailSignInPage(r, lang, "code")
+	page.Email = user.Email
+	if err := m.auth.IssueStepUp(r, user, m.emailSignInConfirmURL(page)); err != nil {
+		m.rt.Logger.Error("issue step-up code", zap.String("user_id", user.ID.String()), zap.Error(err))
+		m.render(w, "form", FormPage{
+			Base:  m.base(r, T(lang, "form.login_title"), lang),
+			Mode:  "login",
+			Email: user.Email,
+			Error: T(lang, "esi.err_send"),
+			Next:  page.Next,
+		})
+		return
+	}
+	m.rt.Logger.Info("studio login stepped up", zap.String("user_id", user.ID.String()))
+	page.Notice = T(lang, "esi.step_up")
+	m.render(w, "email_signin", page)
+}
+
+// handleEmailSignInLink is where the mailed link lands: the address it was
+// sent to and a button. Looking the token up does not spend it.
+func (m *Module) handleEmailSignInLink(w http.ResponseWriter, r *http.Request) {
+	lang := m.resolveLang(w, r)
+	page := m.emailSignInPage(r, lang, "link")
+	page.Token = strings.TrimSpace(r.URL.Query().Get("token"))
+	proof, err := m.auth.CheckEmailSignIn(r.Context(), page.Token)
+	if err != nil {
+		if !errors.Is(err, auth.ErrEmailSignInInvalid) {
+			m.rt.Logger.Error("check email sign-in", zap.Error(err))
+		}
+		page.Mode, page.Token, page.Error = "start", "", T(lang, "esi.err_link")
+		m.render(w, "email_signin", page)
+		return
+	}
+	page.Email = proof.Email
+	m.render(w, "email_signin", page)
+}
+
+// handleEmailSignInConfirm takes the link's token or the typed code. An
+// account is signed in — through its passkey first when it has one, as after
+// a password — and an address without one is asked for the rest of
+// registration, the proof riding along until the account exists.
+func (m *Module) handleEmailSignInConfirm(w http.ResponseWriter, r *http.Request) {
+	lang := m.resolveLang(w, r)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "bad form", http.StatusBadRequest)
+		return
+	}
+	page := m.emailSignInPage(r, lang, "code")
+	page.Token = strings.TrimSpace(r.FormValue("token"))
+	page.Code = strings.TrimSpace(r.FormValue("code"))
+	fail := func(msg string) {
+		if page.Token != "" {
+			page.Mode, page.Token = "start", ""
+		}
+		page.Code, page.Error = "", msg
+		m.render(w, "email_signin", page)
+	}
+
+	if !m.auth.AllowAuthAttempt(r, "email_code", page.Email) {
+		fail(T(lang, "form.err_rate_limit"))
+		return
+	}
+	var (
+		proof auth.EmailSignIn
+		err   error
+	)
+	if page.Token != "" {
+		proof, err = m.auth.CheckEmailSignIn(r.Context(), page.Token)
+	} else {
+		proof, err = m.auth.CheckEmailCode(r.Context(), page.Email, page.Code)
+	}
+	if err != nil {
+		if !errors.Is(err, auth.ErrEmailSignInInvalid) {
+			m.rt.Logger.Error("check email sign-in", zap.Error(err))
+		}
+		if page.Token != "" {
+			fail(T(lang, "esi.err_link"))
+		} else {
+			fail(T(lang, "esi.err_code"))
+		}
+		return
+	}
+	page.Email = proof.Email
+
+	if proof.User == nil {
+		m.finishEmailRegistration(w, r, lang, page, proof)
+		return
+	}
+	// The same door as the password form: a second factor it cannot present
+	// closes it, and a passkey is asked for before any session opens.
+	if m.auth.MFAEnabled() && !m.auth.PasskeyMFA() {
+		fail(T(lang, "form.err_mfa_web"))
+		return
+	}
+	user, err := m.auth.UseEmailSignIn(r.Context(), proof)
+	if err != nil {
+		if !errors.Is(err, auth.ErrEmailSignInInvalid) {
+			m.rt.Logger.Error("use email sign-in", zap.Error(err))
+		}
+		fail(T(lang, "esi.err_code"))
+		return
+	}
+	if m.auth.PasskeyMFA() {
+		challenge, err := m.auth.PasskeyChallenge(r.Context(), user)
+		switch {
+		case err == nil:
+			m.passkeyStep(w, r, lang, user.Email, challenge)
+			return
+		case !errors.Is(err, auth.ErrMFANotEnrolled):
+			m.rt.Logger.Error("passkey challenge", zap.String("user_id", user.ID.String()), zap.Error(err))
+			fail(T(lang, "form.err_passkey"))
+			return
+		}
+	}
+	if err := m.auth.StartSession(w, r, user); err != nil {
+		m.rt.Logger.Error("start session", zap.String("user_id", user.ID.String()), zap.Error(err))
+		fail(T(lang, "form.err_session"))
+		return
+	}
+	m.rt.Logger.Info("studio login (email code)", zap.String("user_id", user.ID.String()))
+	http.Redirect(w, r, afterAuth(r), http.StatusSeeOther)
+}
+
+// finishEmailRegistration creates the account for a proven address once the
+// reader has given what /studio/register asks for bar the password, and
+// until then shows the form asking for it.
+func (m *Module) finishEmailRegistration(w http.ResponseWriter, r *http.Request, lang string, page EmailSignInPage, proof auth.EmailSignIn) {
+	page.Mode = "finish"
+	page.Base = m.base(r, T(lang, "esi.finish_title"), lang)
+	page.First = auth.NormalizePersonName(r.FormValue("first_name"))
+	page.Last = auth.NormalizePersonName(r.FormValue("last_name"))
+	page.Middle = auth.NormalizePersonName(r.FormValue("middle_name"))
+	page.PlaceID = strings.TrimSpace(r.FormValue("geo_node_id"))
+	regFail := func(msg string) {
+		page.Error = msg
+		m.render(w, "email_signin", page)
+	}
+	if msg := m.registrationClosed(r, lang, page.Ref); msg != "" {
+		page.Mode, page.Token, page.Code = "start", "", ""
+		regFail(msg)
+		return
+	}
+	if r.FormValue("finish") == "" {
+		m.render(w, "email_signin", page) // first arrival: nothing to check yet
+		return
+	}
+
+	// KZ online-platform law: registration cannot complete without explicit
+	// consent to the Terms and Privacy Policy — by code as by password.
+	if r.FormValue("consent") != "on" {
+		regFail(T(lang, "form.err_consent"))
+		return
+	}
+	if err := auth.ValidatePersonName(page.First); err != nil {
+		regFail(T(lang, "form.err_first_name"))
+		return
+	}
+	if err := auth.ValidatePersonName(page.Last); err != nil {
+		regFail(T(lang, "form.err_last_name"))
+		return
+	}
+	if err := auth.ValidateOptionalPersonName(page.Middle); err != nil {
+		regFail(T(lang, "form.err_middle_name"))
+		return
+	}
+	if !m.auth.AllowAuthAttempt(r, "signup", proof.Email) {
+		regFail(T(lang, "form.err_rate_limit"))
+		return
+	}
+	user, err := m.auth.RegisterEmail(r.Context(), proof, page.First, page.Last, page.Middle)
+	if err != nil {
+		page.Mode, page.Token, page.Code = "start", "", ""
+		switch {
+		case errors.Is(err, auth.ErrEmailSignInInvalid):
+			regFail(T(lang, "esi.err_link"))
+		case errors.Is(err, auth.ErrEmailExists):
+			regFail(T(lang, "esi.err_taken"))
+		default:
+			m.rt.Logger.Error("register by email code", zap.Error(err))
+			regFail(T(lang, "form.err_generic"))
+		}
+		return
+	}
+	m.welcomeAccount(r, user.ID, page.PlaceID, page.Ref)
+	if err := m.auth.StartSession(w, r, user); err != nil {
+		m.rt.Logger.Error("start session (register)", zap.String("user_id", user.ID.String()), zap.Error(err))
+		http.Redirect(w, r, "/studio/login/email", http.StatusSeeOther)
+		return
+	}
+	m.rt.Logger.Info("studio register (email code)", zap.String("user_id", user.ID.String()))
+	http.Redirect(w, r, afterAuth(r), http.StatusSeeOther)
+}
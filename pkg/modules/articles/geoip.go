@@ -1,11 +1,14 @@
 package articles
 
 import (
+	"context"
+	"fmt"
 	"net"
 	"net/http"
 	"strings"
 
 	maxminddb "github.com/oschwald/maxminddb-golang"
+	"shanraq.org/pkg/shanraq"
 )
 
 // geoIP resolves a visitor's IP to an analytics label using local MaxMind-format
@@ -73,6 +76,30 @@ func openGeoIP(countryPath, asnPath string) *geoIP {
 	return g
 }
 
+// HealthChecks reports a configured GeoIP database that did not open. The site
+// runs fine without one, which is exactly why a broken mount went unnoticed:
+// the country panel simply stayed empty.
+func (m *Module) HealthChecks() []shanraq.HealthCheck {
+	return []shanraq.HealthCheck{{
+		Name: "geoip",
+		Run: func(context.Context) (string, error) {
+			cfg := m.rt.Config.Analytics
+			switch {
+			case strings.TrimSpace(cfg.GeoIPDB) == "":
+				return "disabled", nil
+			case m.geoip == nil:
+				return "", fmt.Errorf("country database %q failed to open", cfg.GeoIPDB)
+			case strings.TrimSpace(cfg.GeoIPASNDB) != "" && !m.geoip.hasASN():
+				return "country only", fmt.Errorf("asn database %q failed to open", cfg.GeoIPASNDB)
+			}
+			if m.geoip.hasASN() {
+				return "country + asn", nil
+			}
+			return "country", nil
+		},
+	}}
+}
+
 // hasASN reports whether the datacenter/VPN filter is active.
 func (g *geoIP) hasASN() bool { return g != nil && g.ar != nil }
 
@@ -13,6 +13,7 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
+	"shanraq.org/pkg/events"
 	"shanraq.org/pkg/modules/ai"
 	"shanraq.org/pkg/modules/auth"
 	"shanraq.org/pkg/modules/jobs"
@@ -121,7 +122,7 @@ func (m *Module) base(r *http.Request, title, lang string) Base {
 		SubMsg:    subMsg,
 		SubBad:    subBad,
 		Authed:    authed,
-		IsStaff:   authed && claims.HasAnyRole(adminRoles...),
+		IsStaff:   authed && m.can(r, auth.PermAdminView),
 		CanAuthor: canAuthorAsStaff(claims),
 		Avatar:    avatar,
 		ShowLangs: true,
@@ -189,11 +190,7 @@ func (m *Module) serviceViews(r *http.Request, lang string) map[string]ServiceVi
 // isInvited reports whether the current viewer may use invite_only functions:
 // staff always, plus any user who joined through an invite link.
 func (m *Module) isInvited(r *http.Request) bool {
-	claims, ok := auth.ClaimsFromContext(r.Context())
-	if !ok {
-		return false
-	}
-	if claims.HasAnyRole(adminRoles...) {
+	if m.can(r, auth.PermAdminView) {
 		return true
 	}
 	if id, ok := m.authorID(r); ok {
@@ -885,6 +882,7 @@ func (m *Module) handleComment(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
+	m.announce(r.Context(), events.CommentCreated{ArticleID: a.ID, AuthorID: userID})
 	http.Redirect(w, r, backTo, http.StatusSeeOther)
 }
 
@@ -1006,6 +1004,12 @@ type FormPage struct {
 	// error, so a rejected password does not also cost the reader their
 	// place selection.
 	PlaceID string
+
+	// Passkeys offers the "sign in with a passkey" button on the login form.
+	Passkeys bool
+	// PasskeyOptions is the challenge of the passkey step (Mode "passkey"),
+	// as the JSON the browser script reads.
+	PasskeyOptions string
 }
 
 // safeNext returns a post-login destination taken from the request, or "" if it
@@ -1051,6 +1055,7 @@ func (m *Module) handleLoginPage(w http.ResponseWriter, r *http.Request) {
 	if page.Next == "/listings/new" && page.Notice == "" {
 		page.Notice = T(lang, "form.next_listing")
 	}
+	page.Passkeys = m.auth.PasskeysEnabled()
 	m.render(w, "form", page)
 }
 
@@ -1083,13 +1088,12 @@ func (m *Module) handleLoginSubmit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// A second factor the main entrance ignores is not a second factor. This
-	// form has no challenge step, so when MFA is configured it must refuse
-	// rather than hand out a session that skipped it. Today TOTP is off and
-	// nobody sees this; the check exists so that turning it on cannot silently
-	// leave the browser door open. Checked before the password so a closed door
+	// A second factor the main entrance ignores is not a second factor. The
+	// one challenge step this form has is the passkey's, so when another
+	// factor is configured — TOTP — it must refuse rather than hand out a
+	// session that skipped it. Checked before the password so a closed door
 	// cannot be used to probe which e-mails exist.
-	if m.auth.MFAEnabled() {
+	if m.auth.MFAEnabled() && !m.auth.PasskeyMFA() {
 		m.render(w, "form", FormPage{
 			Base:  m.base(r, T(lang, "form.login_title"), lang),
 			Mode:  "login",
@@ -1099,18 +1103,55 @@ func (m *Module) handleLoginSubmit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, token, err := m.auth.LoginPassword(r.Context(), email, password)
+	user, _, err := m.auth.LoginPassword(r.Context(), email, password)
 	if err != nil {
+		m.render(w, "form", FormPage{
+			Base:     m.base(r, T(lang, "form.login_title"), lang),
+			Mode:     "login",
+			Email:    email,
+			Error:    T(lang, "form.err_credentials"),
+			Next:     safeNext(r.FormValue("next")),
+			Passkeys: m.auth.PasskeysEnabled(),
+		})
+		return
+	}
+	// The password was right. An account with a passkey is asked for it now,
+	// before any session is opened; one without signs in as it always has.
+	if m.auth.PasskeyMFA() {
+		challenge, err := m.auth.PasskeyChallenge(r.Context(), user)
+		switch {
+		case err == nil:
+			m.passkeyStep(w, r, lang, email, challenge)
+			return
+		case !errors.Is(err, auth.ErrMFANotEnrolled):
+			m.rt.Logger.Error("passkey challenge", zap.String("user_id", user.ID.String()), zap.Error(err))
+			m.render(w, "form", FormPage{
+				Base:  m.base(r, T(lang, "form.login_title"), lang),
+				Mode:  "login",
+				Email: email,
+				Error: T(lang, "form.err_passkey"),
+				Next:  safeNext(r.FormValue("next")),
+			})
+			return
+		}
+	}
+	// No passkey was asked for. A staff account from a country it has not
+	// signed in from proves the inbox as well before any session opens.
+	if m.auth.NeedsStepUp(r, user) {
+		m.stepUpByEmail(w, r, lang, user)
+		return
+	}
+	if err := m.auth.StartSession(w, r, user); err != nil {
+		m.rt.Logger.Error("start session", zap.String("user_id", user.ID.String()), zap.Error(err))
 		m.render(w, "form", FormPage{
 			Base:  m.base(r, T(lang, "form.login_title"), lang),
 			Mode:  "login",
 			Email: email,
-			Error: T(lang, "form.err_credentials"),
+			Error: T(lang, "form.err_session"),
 			Next:  safeNext(r.FormValue("next")),
 		})
 		return
 	}
-	auth.SetSessionCookie(w, r, token, m.auth.SessionTTL())
 	m.rt.Logger.Info("studio login", zap.String("user_id", user.ID.String()))
 	http.Redirect(w, r, afterAuth(r), http.StatusSeeOther)
 }
@@ -1137,19 +1178,7 @@ func (m *Module) handleRegisterSubmit(w http.ResponseWriter, r *http.Request) {
 			PlaceID: place,
 		})
 	}
-	switch m.flags.Flag(SvcRegistration).Status {
-	case svcOn:
-		// open to everyone
-	case svcInviteOnly:
-		if _, ok := m.refs.ReferrerByCode(r.Context(), ref); !ok {
-			regFail(T(lang, "form.err_invite_only"))
-			return
-		}
-	default: // maintenance | off
-		msg := m.flags.Flag(SvcRegistration).Message(lang)
-		if msg == "" {
-			msg = T(lang, "svc.closed_note")
-		}
+	if msg := m.registrationClosed(r, lang, ref); msg != "" {
 		regFail(msg)
 		return
 	}
@@ -1199,18 +1228,7 @@ func (m *Module) handleRegisterSubmit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, token, err := m.auth.RegisterPassword(r.Context(), email, password, first, last, middle)
-	if err == nil && place != "" {
-		// Best-effort: a place that will not parse or will not save must never
-		// cost somebody the account they have just created. They can set it
-		// again from the profile, and until they do their feed carries only
-		// what was written for everyone.
-		if id, perr := uuid.Parse(place); perr == nil {
-			if serr := m.geo.SetUserPlace(r.Context(), user.ID, &id); serr != nil {
-				m.rt.Logger.Warn("save place on register", zap.Error(serr))
-			}
-		}
-	}
+	user, _, err := m.auth.RegisterPassword(r.Context(), email, password, first, last, middle)
 	if err != nil {
 		msg := T(lang, "form.err_generic")
 		if errors.Is(err, auth.ErrEmailExists) {
@@ -1221,37 +1239,80 @@ func (m *Module) handleRegisterSubmit(w http.ResponseWriter, r *http.Request) {
 		regFail(msg)
 		return
 	}
+	m.welcomeAccount(r, user.ID, place, ref)
+	// Send the email-verification link (best effort).
+	if err := m.auth.IssueEmailVerification(r.Context(), user.ID, user.Email); err != nil {
+		m.rt.Logger.Warn("issue email verification (web)", zap.String("user_id", user.ID.String()), zap.Error(err))
+	}
+	if err := m.auth.StartSession(w, r, user); err != nil {
+		// The account exists; only the session failed. Send them to sign in
+		// rather than back to a form that would now say the e-mail is taken.
+		m.rt.Logger.Error("start session (register)", zap.String("user_id", user.ID.String()), zap.Error(err))
+		http.Redirect(w, r, "/studio/login", http.StatusSeeOther)
+		return
+	}
+	m.rt.Logger.Info("studio register", zap.String("user_id", user.ID.String()))
+	http.Redirect(w, r, afterAuth(r), http.StatusSeeOther)
+}
+
+// registrationClosed is why a new account cannot be made right now, in lang,
+// or "" when it can. An invite-only site lets in a valid invite code (ref).
+func (m *Module) registrationClosed(r *http.Request, lang, ref string) string {
+	switch m.flags.Flag(SvcRegistration).Status {
+	case svcOn:
+		return "" // open to everyone
+	case svcInviteOnly:
+		if _, ok := m.refs.ReferrerByCode(r.Context(), ref); !ok {
+			return T(lang, "form.err_invite_only")
+		}
+		return ""
+	default: // maintenance | off
+		msg := m.flags.Flag(SvcRegistration).Message(lang)
+		if msg == "" {
+			msg = T(lang, "svc.closed_note")
+		}
+		return msg
+	}
+}
+
+// welcomeAccount does what every new account gets from the web forms, the
+// password one and the e-mail code one alike. All of it is best-effort: none
+// of it may cost somebody the account they have just created.
+func (m *Module) welcomeAccount(r *http.Request, userID uuid.UUID, place, ref string) {
+	// A place that will not parse or will not save can be set again from the
+	// profile, and until it is the feed carries only what was written for
+	// everyone.
+	if place != "" {
+		if id, err := uuid.Parse(place); err == nil {
+			if err := m.geo.SetUserPlace(r.Context(), userID, &id); err != nil {
+				m.rt.Logger.Warn("save place on register", zap.Error(err))
+			}
+		}
+	}
 	// Where this account registered from, resolved once from the request IP.
 	// The admin register needs a country beside each name, and this is the only
 	// moment it can be known without following anyone around: no per-visit
 	// history is kept, and an unresolvable address simply leaves it blank.
 	if m.geoip != nil {
 		if cc := m.geoip.country(clientIP(r)); cc != "" {
-			if err := m.users.SetSignupCountry(r.Context(), user.ID, cc); err != nil {
+			if err := m.users.SetSignupCountry(r.Context(), userID, cc); err != nil {
 				m.rt.Logger.Warn("signup country", zap.Error(err))
 			}
 		}
 	}
 	// Record the consent the checkbox represents (append-only proof).
-	if err := m.auth.RecordConsent(r.Context(), r, user.ID, "web"); err != nil {
-		m.rt.Logger.Error("record consent (web)", zap.String("user_id", user.ID.String()), zap.Error(err))
-	}
-	// Send the email-verification link (best effort).
-	if err := m.auth.IssueEmailVerification(r.Context(), user.ID, user.Email); err != nil {
-		m.rt.Logger.Warn("issue email verification (web)", zap.String("user_id", user.ID.String()), zap.Error(err))
+	if err := m.auth.RecordConsent(r.Context(), r, userID, "web"); err != nil {
+		m.rt.Logger.Error("record consent (web)", zap.String("user_id", userID.String()), zap.Error(err))
 	}
 	// Referral capture: if the registration carried an invite code, link the
-	// new user to their referrer. Best-effort — a bad code must not fail signup.
-	if code := strings.TrimSpace(r.FormValue("ref")); code != "" {
+	// new user to their referrer. A bad code must not fail signup.
+	if code := strings.TrimSpace(ref); code != "" {
 		if referrer, ok := m.refs.ReferrerByCode(r.Context(), code); ok {
-			if err := m.refs.RecordReferral(r.Context(), referrer, user.ID); err != nil {
+			if err := m.refs.RecordReferral(r.Context(), referrer, userID); err != nil {
 				m.rt.Logger.Warn("record referral", zap.Error(err))
 			}
 		}
 	}
-	auth.SetSessionCookie(w, r, token, m.auth.SessionTTL())
-	m.rt.Logger.Info("studio register", zap.String("user_id", user.ID.String()))
-	http.Redirect(w, r, afterAuth(r), http.StatusSeeOther)
 }
 
 // afterAuth is where a fresh session lands: the destination the user was
@@ -1269,7 +1330,7 @@ func afterAuth(r *http.Request) string {
 }
 
 func (m *Module) handleLogout(w http.ResponseWriter, r *http.Request) {
-	auth.ClearSessionCookie(w, r)
+	m.auth.EndSession(w, r)
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
@@ -1434,6 +1495,9 @@ type EditorPage struct {
 	// tabs with no way to learn that the job failed three times.
 	TranslateState string
 	TranslateError string
+	// TranslateProgress is the running job's own account of how far it has
+	// got — "kz done, en 3/7 blocks" — so the hourglass is not all there is.
+	TranslateProgress string
 
 	// TranslationIssues lists, per language, what the translation lost against
 	// the original — counted, not read. An author who does not know the target
@@ -1464,8 +1528,37 @@ func (m *Module) handleTranslateStatus(w http.ResponseWriter, r *http.Request) {
 		http.NotFound(w, r)
 		return
 	}
-	state, msg := m.translationRun(r.Context(), id.String())
-	writeJSONObj(w, map[string]any{"state": state, "error": msg})
+	job, _ := m.latestTranslation(r.Context(), id.String())
+	state, msg := translationState(job)
+	writeJSONObj(w, map[string]any{"state": state, "error": msg, "progress": translationProgress(job)})
+}
+
+// handleTranslateStream streams the article's latest translation job to the
+// editor as it runs (see jobs.StreamJob), so the author watches it move
+// through the languages instead of polling for the end of it.
+func (m *Module) handleTranslateStream(w http.ResponseWriter, r *http.Request) {
+	authorID, ok := m.authorID(r)
+	if !ok {
+		http.Error(w, "auth required", http.StatusUnauthorized)
+		return
+	}
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if _, err := m.store.GetByID(r.Context(), id, authorID); err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	job, ok := m.latestTranslation(r.Context(), id.String())
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	// Ownership is settled above: the job is the translation of an article
+	// this author owns, whoever's name it was enqueued under.
+	jobs.StreamJob(w, r, m.jobs, job.ID, nil)
 }
 
 // otherLangs lists every language except the original, in the site's order.
@@ -1479,35 +1572,52 @@ func otherLangs(original string) []string {
 	return out
 }
 
-// translationRun reports how the most recent translation of this article ended.
-// Best-effort: the editor renders with or without it.
-func (m *Module) translationRun(ctx context.Context, articleID string) (state, msg string) {
-	if m.rt == nil || m.rt.DB == nil {
-		return "", ""
+// latestTranslation finds the most recent translation job of this article,
+// with its progress. Best-effort: the editor renders with or without it.
+func (m *Module) latestTranslation(ctx context.Context, articleID string) (jobs.Job, bool) {
+	if m.rt == nil || m.rt.DB == nil || m.jobs == nil {
+		return jobs.Job{}, false
 	}
-	var status string
-	var lastErr *string
+	var id uuid.UUID
 	err := m.rt.DB.QueryRow(ctx, `
-		SELECT status::text, last_error FROM job_queue
+		SELECT id FROM job_queue
 		 WHERE name = $1 AND payload->>'article_id' = $2
-		 ORDER BY created_at DESC LIMIT 1`, ai.JobTranslate, articleID).Scan(&status, &lastErr)
+		 ORDER BY created_at DESC LIMIT 1`, ai.JobTranslate, articleID).Scan(&id)
 	if err != nil {
-		return "", ""
+		return jobs.Job{}, false
 	}
-	switch status {
+	job, err := m.jobs.Get(ctx, id, nil)
+	if err != nil {
+		return jobs.Job{}, false
+	}
+	return job, true
+}
+
+// translationState reports how a translation job stands: "running", "done",
+// "failed" with its error, or empty when there is no job.
+func translationState(job jobs.Job) (state, msg string) {
+	switch job.Status {
 	case "pending", "running", "retry":
 		return "running", ""
 	case "done":
 		return "done", ""
 	case "failed":
-		if lastErr != nil {
-			return "failed", *lastErr
+		if job.LastError != nil {
+			return "failed", *job.LastError
 		}
 		return "failed", ""
 	}
 	return "", ""
 }
 
+// translationProgress is the running job's last progress line, if any.
+func translationProgress(job jobs.Job) string {
+	if job.Progress == nil || job.Status != "running" {
+		return ""
+	}
+	return job.Progress.Message
+}
+
 // aiNotice maps an ?ai= redirect flag to a localized message.
 func aiNotice(lang, flag string) string {
 	// Translation is the only AI action an author can start now: the co-editor
@@ -1576,7 +1686,10 @@ func (m *Module) handleEditorEdit(w http.ResponseWriter, r *http.Request) {
 	page.Status = a.Status
 	page.OriginalLang = a.OriginalLang
 	page.TargetLangs = otherLangs(a.OriginalLang)
-	page.TranslateState, page.TranslateError = m.translationRun(r.Context(), a.ID.String())
+	if job, ok := m.latestTranslation(r.Context(), a.ID.String()); ok {
+		page.TranslateState, page.TranslateError = translationState(job)
+		page.TranslateProgress = translationProgress(job)
+	}
 	if orig, ok := a.Translations[a.OriginalLang]; ok {
 		issues := map[string][]TranslationIssue{}
 		for _, l := range otherLangs(a.OriginalLang) {
@@ -1652,20 +1765,14 @@ func (m *Module) handleTranslate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	payload, err := ai.EnqueuePayload(id)
+	job, err := ai.TranslateJob(id, authorID)
 	if err != nil {
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
-	job := jobs.Job{
-		ID:          uuid.New(),
-		UserID:      authorID,
-		Name:        ai.JobTranslate,
-		Payload:     payload,
-		RunAt:       time.Now(),
-		MaxAttempts: 3,
-	}
-	if err := m.jobs.Enqueue(r.Context(), job); err != nil {
+	// A translation already waiting for this article is reused; either way
+	// the editor's status poll follows the article, not the job ID.
+	if _, err := m.jobs.Enqueue(r.Context(), job); err != nil {
 		m.rt.Logger.Error("enqueue translate", zap.Error(err))
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
@@ -1888,9 +1995,7 @@ func (m *Module) handlePublish(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "internal error", http.StatusInternalServerError)
 			return
 		}
-		if err := m.syndicate.EnqueuePublish(r.Context(), m.jobs, id); err != nil {
-			m.rt.Logger.Warn("enqueue publish", zap.Error(err))
-		}
+		m.announce(r.Context(), events.ArticlePublished{ArticleID: id, AuthorID: authorID})
 		http.Redirect(w, r, "/studio?ok=published", http.StatusSeeOther)
 		return
 	}
@@ -1915,9 +2020,7 @@ func (m *Module) handlePublish(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "internal error", http.StatusInternalServerError)
 			return
 		}
-		if err := m.syndicate.EnqueuePublish(r.Context(), m.jobs, id); err != nil {
-			m.rt.Logger.Warn("enqueue publish", zap.Error(err))
-		}
+		m.announce(r.Context(), events.ArticlePublished{ArticleID: id, AuthorID: authorID})
 		http.Redirect(w, r, "/studio?ok=published", http.StatusSeeOther)
 		return
 	}
@@ -1934,9 +2037,7 @@ func (m *Module) handlePublish(w http.ResponseWriter, r *http.Request) {
 	}
 	switch {
 	case published:
-		if err := m.syndicate.EnqueuePublish(r.Context(), m.jobs, id); err != nil {
-			m.rt.Logger.Warn("enqueue publish", zap.Error(err))
-		}
+		m.announce(r.Context(), events.ArticlePublished{ArticleID: id, AuthorID: authorID})
 		http.Redirect(w, r, "/studio?ok=published", http.StatusSeeOther)
 	case blocking > 0:
 		http.Redirect(w, r, "/studio/moderation?ok=returned", http.StatusSeeOther)
@@ -2041,13 +2142,10 @@ func (m *Module) transition(w http.ResponseWriter, r *http.Request, status, redi
 		return
 	}
 
-	// On publish, push the article out to external channels (Telegram) for
-	// block-resilience. Best-effort: a syndication failure must not block the
-	// publish itself.
 	if status == "published" {
-		if err := m.syndicate.EnqueuePublish(r.Context(), m.jobs, id); err != nil {
-			m.rt.Logger.Warn("enqueue syndication", zap.Error(err))
-		}
+		m.announce(r.Context(), events.ArticlePublished{ArticleID: id, AuthorID: authorID})
+	} else {
+		m.announce(r.Context(), events.ArticleUnpublished{ArticleID: id, Reason: events.UnpublishedByAuthor})
 	}
 
 	http.Redirect(w, r, redirect, http.StatusSeeOther)
@@ -73,7 +73,7 @@ func newTestApp(t *testing.T, authOpts ...auth.Option) *testApp {
 	aiM := ai.New()
 	synM := syndicate.New(mailer)
 	mediaM := media.New(authM)
-	arts := New(authM, aiM, synM, mediaM, mailer)
+	arts := New(authM, aiM, mediaM, mailer)
 
 	for _, m := range []shanraq.InitializerModule{authM, aiM, synM, mediaM, arts} {
 		if err := m.Init(ctx, rt); err != nil {
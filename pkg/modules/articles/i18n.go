@@ -1231,26 +1231,114 @@ var messages = map[string]map[string]string{
 	"admin.role_set":          {"kz": "Рөл тағайындалды.", "ru": "Роль назначена.", "en": "Role assigned."},
 
 	// Account register.
-	"admin.users_title":    {"kz": "Тіркелген қолданушылар", "ru": "Зарегистрированные пользователи", "en": "Registered users"},
-	"admin.users_search":   {"kz": "Аты, тегі немесе email бойынша іздеу", "ru": "Поиск по имени, фамилии или email", "en": "Search by name, surname or email"},
-	"admin.users_find":     {"kz": "Іздеу", "ru": "Найти", "en": "Search"},
-	"admin.users_empty":    {"kz": "Ешкім табылмады.", "ru": "Никого не найдено.", "en": "Nobody found."},
-	"admin.u_name":         {"kz": "Аты-жөні", "ru": "Имя и фамилия", "en": "Name"},
-	"admin.u_email":        {"kz": "Email", "ru": "Email", "en": "Email"},
-	"admin.u_country":      {"kz": "Ел", "ru": "Страна", "en": "Country"},
-	"admin.u_role":         {"kz": "Рөл", "ru": "Роль", "en": "Role"},
-	"admin.u_registered":   {"kz": "Күні", "ru": "Дата", "en": "Date"},
-	"admin.u_activity":     {"kz": "Мақала / хабарландыру / пікір", "ru": "Статьи / объявления / комментарии", "en": "Articles / listings / comments"},
-	"admin.u_verified":     {"kz": "Email расталған", "ru": "Email подтверждён", "en": "Email verified"},
-	"admin.u_edit":         {"kz": "Өңдеу", "ru": "Редактировать", "en": "Edit"},
-	"admin.u_save":         {"kz": "Сақтау", "ru": "Сохранить", "en": "Save"},
-	"admin.u_delete":       {"kz": "Жою", "ru": "Удалить", "en": "Delete"},
-	"admin.u_first":        {"kz": "Аты", "ru": "Имя", "en": "First name"},
-	"admin.u_last":         {"kz": "Тегі", "ru": "Фамилия", "en": "Surname"},
-	"admin.u_middle":       {"kz": "Әкесінің аты", "ru": "Отчество", "en": "Patronymic"},
-	"admin.u_unknown":      {"kz": "белгісіз", "ru": "неизвестно", "en": "unknown"},
-	"admin.u_last_admin":   {"kz": "Соңғы әкімші", "ru": "Последний админ", "en": "Last admin"},
-	"admin.u_email_locked": {"kz": "Email логин болғандықтан өзгертілмейді — иесі профилінде ауыстырады.", "ru": "Email не меняется здесь: это логин, его меняет владелец в профиле.", "en": "The e-mail is the login and is not editable here — its owner changes it in their profile."},
+	"admin.users_title":                  {"kz": "Тіркелген қолданушылар", "ru": "Зарегистрированные пользователи", "en": "Registered users"},
+	"admin.users_search":                 {"kz": "Аты, тегі немесе email бойынша іздеу", "ru": "Поиск по имени, фамилии или email", "en": "Search by name, surname or email"},
+	"admin.users_find":                   {"kz": "Іздеу", "ru": "Найти", "en": "Search"},
+	"admin.users_empty":                  {"kz": "Ешкім табылмады.", "ru": "Никого не найдено.", "en": "Nobody found."},
+	"admin.u_name":                       {"kz": "Аты-жөні", "ru": "Имя и фамилия", "en": "Name"},
+	"admin.u_email":                      {"kz": "Email", "ru": "Email", "en": "Email"},
+	"admin.u_country":                    {"kz": "Ел", "ru": "Страна", "en": "Country"},
+	"admin.u_role":                       {"kz": "Рөл", "ru": "Роль", "en": "Role"},
+	"admin.u_registered":                 {"kz": "Күні", "ru": "Дата", "en": "Date"},
+	"admin.u_activity":                   {"kz": "Мақала / хабарландыру / пікір", "ru": "Статьи / объявления / комментарии", "en": "Articles / listings / comments"},
+	"admin.u_verified":                   {"kz": "Email расталған", "ru": "Email подтверждён", "en": "Email verified"},
+	"admin.u_edit":                       {"kz": "Өңдеу", "ru": "Редактировать", "en": "Edit"},
+	"admin.u_save":                       {"kz": "Сақтау", "ru": "Сохранить", "en": "Save"},
+	"admin.u_delete":                     {"kz": "Жою", "ru": "Удалить", "en": "Delete"},
+	"admin.u_first":                      {"kz": "Аты", "ru": "Имя", "en": "First name"},
+	"admin.u_last":                       {"kz": "Тегі", "ru": "Фамилия", "en": "Surname"},
+	"admin.u_middle":                     {"kz": "Әкесінің аты", "ru": "Отчество", "en": "Patronymic"},
+	"admin.u_unknown":                    {"kz": "белгісіз", "ru": "неизвестно", "en": "unknown"},
+	"admin.u_last_admin":                 {"kz": "Соңғы әкімші", "ru": "Последний админ", "en": "Last admin"},
+	"admin.u_email_locked":               {"kz": "Email логин болғандықтан өзгертілмейді — иесі профилінде ауыстырады.", "ru": "Email не меняется здесь: это логин, его меняет владелец в профиле.", "en": "The e-mail is the login and is not editable here — its owner changes it in their profile."},
+	"admin.u_sessions_note":              {"kz": "Аккаунт кірілген құрылғылар: түрі, браузер, кірген елі. Аккаунт ұрланғандай көрінсе, осыдан бастаңыз.", "ru": "Где выполнен вход в аккаунт: устройство, браузер, страна входа. Если аккаунт похож на угнанный — начинайте отсюда.", "en": "Where the account is signed in: device, browser, country of sign-in. If the account looks taken over, start here."},
+	"admin.u_sessions_revoke":            {"kz": "Барлық құрылғыдан шығару", "ru": "Выйти на всех устройствах", "en": "Sign out everywhere"},
+	"admin.u_sessions_revoke_confirm_js": {"kz": "Аккаунтты барлық құрылғыдан шығару керек пе?", "ru": "Завершить все сеансы аккаунта?", "en": "End every session of this account?"},
+	"admin.u_sessions_revoked":           {"kz": "Аккаунттың барлық сеанстары аяқталды.", "ru": "Все сеансы аккаунта завершены.", "en": "Every session of the account has ended."},
+	"thr.nav":                            {"kz": "Шектеулер", "ru": "Ограничения", "en": "Throttled keys"},
+	"thr.title":                          {"kz": "Шектелген кілттер", "ru": "Ограниченные ключи", "en": "Throttled keys"},
+	"thr.intro":                          {"kz": "Кіру, тіркелу, құпиясөзді қалпына келтіру мен жүктеу әрекеттерінің шегіне жеткен мекенжайлар мен аккаунттар. Бірнеше рет қатарынан бас тартылған кілт уақытша бұғатталады, әр жолы ұзағырақ.", "ru": "Адреса и аккаунты, упёршиеся в лимит входа, регистрации, сброса пароля или загрузок. Ключ, получивший несколько отказов подряд, временно блокируется — каждый раз дольше.", "en": "Addresses and accounts that hit the limit on sign-in, sign-up, password reset or uploads. A key refused several times in a row is locked out for a while, longer each time."},
+	"thr.key":                            {"kz": "Кілт", "ru": "Ключ", "en": "Key"},
+	"thr.action":                         {"kz": "Әрекет", "ru": "Действие", "en": "Action"},
+	"thr.refusals":                       {"kz": "Қатарынан бас тарту", "ru": "Отказов подряд", "en": "Refusals in a row"},
+	"thr.until":                          {"kz": "Дейін шектелген", "ru": "Ограничен до", "en": "Throttled until"},
+	"thr.locked":                         {"kz": "бұғатталған", "ru": "заблокирован", "en": "locked out"},
+	"thr.unblock":                        {"kz": "Бұғаттан шығару", "ru": "Снять ограничение", "en": "Unblock"},
+	"thr.unblocked":                      {"kz": "Шектеу алынды.", "ru": "Ограничение снято.", "en": "The limit has been lifted."},
+	"thr.none":                           {"kz": "Қазір ешкім шектелмеген.", "ru": "Сейчас никто не ограничен.", "en": "Nobody is being throttled right now."},
+	"admin.user_root_only":               {"kz": "Әкімші немесе директор рөлін тек әкімші мен директор береді және өзгертеді.", "ru": "Роли администратора и директора выдаёт и меняет только администратор или директор.", "en": "Only an administrator or director can grant the administrator and director roles, or change an account that holds one."},
+	"acc.nav":                            {"kz": "Рөлдер мен құқықтар", "ru": "Роли и права", "en": "Roles and permissions"},
+	"acc.title":                          {"kz": "Рөлдер мен құқықтар", "ru": "Роли и права", "en": "Roles and permissions"},
+	"acc.intro":                          {"kz": "Рөл — құқықтар жиыны. Өзгеріс осы серверде бірден, қалғандарында жарты минут ішінде күшіне енеді.", "ru": "Роль — это набор прав. Изменения действуют на этом сервере сразу, на остальных — в течение полуминуты.", "en": "A role is a set of permissions. Changes apply on this server at once and on the others within half a minute."},
+	"acc.holders":                        {"kz": "аккаунт", "ru": "аккаунтов", "en": "accounts"},
+	"acc.fixed":                          {"kz": "Бұл рөлді өзгертуге болмайды.", "ru": "Эту роль изменить нельзя.", "en": "This role cannot be changed."},
+	"acc.save":                           {"kz": "Сақтау", "ru": "Сохранить", "en": "Save"},
+	"acc.new":                            {"kz": "Жаңа рөл", "ru": "Новая роль", "en": "New role"},
+	"acc.name":                           {"kz": "Атауы (латын әріптері, сандар, _)", "ru": "Название (латиница, цифры, _)", "en": "Name (lowercase letters, digits, _)"},
+	"acc.description":                    {"kz": "Сипаттама", "ru": "Описание", "en": "Description"},
+	"acc.create":                         {"kz": "Рөл құру", "ru": "Создать роль", "en": "Create role"},
+	"acc.ok.saved":                       {"kz": "Рөл құқықтары сақталды.", "ru": "Права роли сохранены.", "en": "The role's permissions were saved."},
+	"acc.ok.created":                     {"kz": "Рөл құрылды.", "ru": "Роль создана.", "en": "The role was created."},
+	"acc.err.fixed":                      {"kz": "Әкімші мен директор рөлдері өзгертілмейді.", "ru": "Роли администратора и директора не редактируются.", "en": "The administrator and director roles cannot be edited."},
+	"acc.err.exists":                     {"kz": "Мұндай рөл бар.", "ru": "Такая роль уже есть.", "en": "A role with this name already exists."},
+	"acc.err.name":                       {"kz": "Атауы — 2–32 кіші латын әрпі, сан немесе _.", "ru": "Название — 2–32 строчные латинские буквы, цифры или _.", "en": "Names are 2–32 lowercase letters, digits or underscores."},
+	"acc.err.perm":                       {"kz": "Белгісіз құқық.", "ru": "Неизвестное право.", "en": "Unknown permission."},
+	"acc.err.failed":                     {"kz": "Сақталмады.", "ru": "Не удалось сохранить.", "en": "Could not save."},
+	"prof.no_password":                   {"kz": "Аккаунт поштаға келген кодпен кіреді, құпиясөзі жоқ. Мұны растау үшін алдымен құпиясөз орнатыңыз.", "ru": "Аккаунт входит по коду с почты, пароля у него нет. Чтобы подтвердить это действие, сначала задайте пароль.", "en": "This account signs in by e-mail code and has no password. To confirm this, set one first."},
+	"prof.set_password":                  {"kz": "Құпиясөз орнату", "ru": "Задать пароль", "en": "Set a password"},
+	"prof.export":                        {"kz": "Менің деректерім", "ru": "Мои данные", "en": "My data"},
+	"prof.export_note":                   {"kz": "Аккаунтыңыз туралы сайтта сақталған барлық деректер — профиль, келісімдер, мақалалар мен жобалар, хабарландырулар, пікірлер, дауыстар, таңдаулылар, шақырулар, төлемдер, модерация мен апелляциялар — JSON файлдары бар ZIP мұрағатына жиналады. Жүктеу сілтемесі поштаңызға келеді және бір тәулік жұмыс істейді.", "ru": "Все данные о вашем аккаунте, которые хранит сайт, — профиль, согласия, статьи и черновики, объявления, комментарии, голоса, избранное, приглашения, платежи, модерация и апелляции — собираются в ZIP-архив с JSON-файлами. Ссылка на скачивание придёт на почту и действует сутки.", "en": "Everything the site holds about your account — profile, consents, articles and drafts, listings, comments, votes, favourites, invitations, payments, moderation and appeals — is gathered into a ZIP of JSON files. The download link arrives by e-mail and works for a day."},
+	"prof.export_btn":                    {"kz": "Деректерімді жүктеу", "ru": "Скачать мои данные", "en": "Download my data"},
+	"prof.export_pending":                {"kz": "Мұрағат жиналып жатыр — сілтеме поштаға келеді.", "ru": "Архив собирается — ссылка придёт на почту.", "en": "The archive is being built — the link will arrive by e-mail."},
+	"prof.export_ready":                  {"kz": "Мұрағат дайын, сілтеме поштаңызға жіберілді. Ол жұмыс істейтін мерзім:", "ru": "Архив готов, ссылка отправлена на почту. Она действует до", "en": "The archive is ready and the link was e-mailed. It works until"},
+	"prof.export_queued":                 {"kz": "Сұрау қабылданды. Мұрағат дайын болғанда, сілтеме поштаға келеді.", "ru": "Запрос принят. Когда архив будет готов, ссылка придёт на почту.", "en": "Request received. When the archive is ready, the link will arrive by e-mail."},
+	"prof.export_recent":                 {"kz": "Мұрағатты тәулігіне бір рет сұрауға болады.", "ru": "Архив можно запросить раз в сутки.", "en": "An archive can be requested once a day."},
+	"prof.export_failed":                 {"kz": "Мұрағатты қазір жинау мүмкін емес. Кейінірек қайталаңыз.", "ru": "Сейчас собрать архив не удалось. Попробуйте позже.", "en": "The archive could not be requested right now. Please try again later."},
+	"prof.export_gone":                   {"kz": "Бұл сілтеменің мерзімі өтті немесе ол басқа аккаунтқа арналған. Жаңа мұрағат сұраңыз.", "ru": "Ссылка устарела или предназначена другому аккаунту. Запросите новый архив.", "en": "That link has expired or belongs to another account. Request a new archive."},
+	"admin.u_export":                     {"kz": "Деректер субъектісінің сұрауы", "ru": "Запрос субъекта данных", "en": "Data-subject request"},
+	"admin.u_export_note":                {"kz": "Аккаунттың барлық деректерінің мұрағаты. Сілтеме иесіне емес, сізге жіберіледі және тек сіздің аккаунтыңызбен ашылады; әрекет аудит журналына жазылады.", "ru": "Архив всех данных аккаунта. Ссылка придёт не владельцу, а вам, и откроется только под вашим аккаунтом; действие записывается в журнал аудита.", "en": "An archive of all the account's data. The link goes to you, not the owner, and opens only for your account; the action is recorded in the audit log."},
+	"admin.u_export_btn":                 {"kz": "Мұрағатты сұрау", "ru": "Запросить архив", "en": "Request the archive"},
+	"admin.u_export_queued":              {"kz": "Мұрағат жиналып жатыр — сілтеме сіздің поштаңызға келеді.", "ru": "Архив собирается — ссылка придёт на вашу почту.", "en": "The archive is being built — the link will arrive in your inbox."},
+	"aud.nav":                            {"kz": "Аудит журналы", "ru": "Журнал аудита", "en": "Audit log"},
+	"aud.title":                          {"kz": "Аудит журналы", "ru": "Журнал аудита", "en": "Audit log"},
+	"aud.intro":                          {"kz": "Панельдегі және adminctl арқылы жасалған әрбір әкімшілік әрекет: кім, не, неге, бұрын және кейін қандай болды. Жазбаларды өзгертуге де, жоюға да болмайды.", "ru": "Каждое административное действие в панели и через adminctl: кто, что, над чем, как было и как стало. Записи нельзя ни изменить, ни удалить.", "en": "Every privileged action taken in the panel or through adminctl: who, what, on which target, before and after. Entries can be neither changed nor deleted."},
+	"aud.actor":                          {"kz": "Кім", "ru": "Кто", "en": "Actor"},
+	"aud.action":                         {"kz": "Әрекет", "ru": "Действие", "en": "Action"},
+	"aud.target":                         {"kz": "Нысан", "ru": "Объект", "en": "Target"},
+	"aud.from":                           {"kz": "Бастап", "ru": "С", "en": "From"},
+	"aud.to":                             {"kz": "Дейін", "ru": "По", "en": "To"},
+	"aud.when":                           {"kz": "Қашан", "ru": "Когда", "en": "When"},
+	"aud.change":                         {"kz": "Өзгеріс", "ru": "Изменение", "en": "Change"},
+	"aud.origin":                         {"kz": "IP және сұрау", "ru": "IP и запрос", "en": "IP and request"},
+	"aud.search":                         {"kz": "Іздеу", "ru": "Найти", "en": "Search"},
+	"aud.verify":                         {"kz": "Тізбекті тексеру", "ru": "Проверить цепочку", "en": "Verify the chain"},
+	"aud.export":                         {"kz": "JSON жүктеу", "ru": "Выгрузить JSON", "en": "Export JSON"},
+	"aud.none":                           {"kz": "Жазба жоқ.", "ru": "Записей нет.", "en": "No entries."},
+	"aud.err":                            {"kz": "Журналды оқу мүмкін болмады.", "ru": "Не удалось прочитать журнал.", "en": "The log could not be read."},
+	"aud.intact":                         {"kz": "Тізбек бүтін", "ru": "Цепочка цела", "en": "The chain is intact"},
+	"aud.broken":                         {"kz": "Тізбек үзілген: жазба өзгертілген немесе жойылған, бастап", "ru": "Цепочка нарушена: запись изменена или удалена, начиная с", "en": "The chain is broken: an entry was altered or removed, starting at"},
+	"perm.admin.view":                    {"kz": "Басқару панелі мен аналитика", "ru": "Панель управления и аналитика", "en": "Admin panel and analytics"},
+	"perm.users.manage":                  {"kz": "Аккаунттар, сеанстар, шектеулер", "ru": "Аккаунты, сеансы, ограничения", "en": "Accounts, sessions, throttled keys"},
+	"perm.roles.manage":                  {"kz": "Рөлдер мен құқықтар", "ru": "Роли и права", "en": "Roles and permissions"},
+	"perm.finance.view":                  {"kz": "Қаржы", "ru": "Финансы", "en": "Finance"},
+	"perm.payments.configure":            {"kz": "Төлем провайдері", "ru": "Платёжный провайдер", "en": "Payment provider"},
+	"perm.tariffs.edit":                  {"kz": "Тарифтер", "ru": "Тарифы", "en": "Tariffs"},
+	"perm.comments.hide":                 {"kz": "Пікірлерді жасыру", "ru": "Скрытие комментариев", "en": "Hide comments"},
+	"perm.articles.decide":               {"kz": "Мақалаларды тексеру", "ru": "Проверка статей", "en": "Review articles"},
+	"perm.appeals.resolve":               {"kz": "Шағымдарды қарау", "ru": "Разбор апелляций", "en": "Resolve appeals"},
+	"perm.partners.decide":               {"kz": "Агенттер мен ұйымдарды растау", "ru": "Проверка агентов и организаций", "en": "Verify agents and organisations"},
+	"perm.services.manage":               {"kz": "Сервистерді қосу және өшіру", "ru": "Включение и отключение сервисов", "en": "Service switches"},
+	"perm.ai.configure":                  {"kz": "ЖИ баптаулары", "ru": "Настройки ИИ", "en": "AI settings"},
+	"perm.pages.edit":                    {"kz": "Ақпараттық беттер", "ru": "Информационные страницы", "en": "Content pages"},
+	"perm.predictions.edit":              {"kz": "Болжамдар", "ru": "Прогнозы", "en": "Predictions"},
+	"perm.config.reload":                 {"kz": "Конфигурацияны қайта жүктеу", "ru": "Перезагрузка конфигурации", "en": "Reload configuration"},
+	"perm.console.view":                  {"kz": "Оператор консолі", "ru": "Консоль оператора", "en": "Operator console"},
+	"perm.jobs.view":                     {"kz": "Тапсырмаларды қарау", "ru": "Просмотр задач", "en": "View jobs"},
+	"perm.jobs.enqueue":                  {"kz": "Тапсырма қосу", "ru": "Постановка задач", "en": "Enqueue jobs"},
+	"perm.jobs.retry":                    {"kz": "Тапсырмаларды қайталау және тоқтату", "ru": "Повтор и отмена задач", "en": "Retry, cancel and replay jobs"},
+	"perm.jobs.purge":                    {"kz": "Аяқталған тапсырмаларды тазалау", "ru": "Очистка завершённых задач", "en": "Purge finished jobs"},
+	"perm.jobs.schedule":                 {"kz": "Кесте", "ru": "Расписание задач", "en": "Job schedules"},
+	"perm.audit.view":                    {"kz": "Аудит журналы", "ru": "Журнал аудита", "en": "Audit log"},
 	"admin.u_delete_confirm": {
 		"kz": "Аккаунтты жою керек пе? Оның барлық мақалалары, хабарландырулары мен пікірлері бірге жойылады. Қайтару мүмкін емес.",
 		"ru": "Удалить аккаунт? Вместе с ним удаляются все его статьи, объявления и комментарии. Отменить нельзя.",
@@ -1438,6 +1526,33 @@ var messages = map[string]map[string]string{
 	"form.err_invite_only":   {"kz": "Тіркелу шақыру бойынша. Тіркелу үшін шақыру сілтемесі қажет.", "ru": "Регистрация по приглашению. Для регистрации нужна пригласительная ссылка.", "en": "Registration is invite-only. You need an invite link to sign up."},
 	"form.err_rate_limit":    {"kz": "Тым көп әрекет. Біраздан соң қайталап көріңіз.", "ru": "Слишком много попыток. Попробуйте немного позже.", "en": "Too many attempts. Please try again in a moment."},
 	"form.verified_ok":       {"kz": "Email расталды. Енді кіре аласыз.", "ru": "Email подтверждён. Теперь вы можете войти.", "en": "Email verified. You can now sign in."},
+	"form.passkey_title":     {"kz": "Кіру кілтімен растаңыз", "ru": "Подтвердите ключом доступа", "en": "Confirm with your passkey"},
+	"form.passkey_sub":       {"kz": "Құпиясөз дұрыс. Енді аккаунтқа тіркелген кілтті қолданыңыз: телефон, ноутбук немесе қауіпсіздік кілті.", "ru": "Пароль верный. Теперь подтвердите вход ключом, привязанным к аккаунту: телефоном, ноутбуком или электронным ключом.", "en": "Your password is right. Now confirm with a passkey registered to the account: a phone, a laptop or a security key."},
+	"form.passkey_use":       {"kz": "Кілтті қолдану", "ru": "Использовать ключ", "en": "Use passkey"},
+	"form.passkey_back":      {"kz": "Басынан бастау", "ru": "Начать заново", "en": "Start over"},
+	"form.email_signin":      {"kz": "Құпиясөзсіз, поштаға кодпен кіру", "ru": "Войти без пароля — по коду на почту", "en": "Sign in without a password — by e-mail code"},
+	"form.email_register":    {"kz": "Құпиясөзсіз, поштаға кодпен тіркелу", "ru": "Зарегистрироваться без пароля — по коду на почту", "en": "Register without a password — by e-mail code"},
+	"esi.title":              {"kz": "Поштамен кіру", "ru": "Вход по почте", "en": "Sign in by e-mail"},
+	"esi.sub":                {"kz": "Поштаңызға сілтеме мен 6 таңбалы код жібереміз — құпиясөз қажет емес. Аккаунт болмаса, сол код арқылы тіркеле аласыз.", "ru": "Мы пришлём на почту ссылку и 6-значный код — пароль не нужен. Если аккаунта ещё нет, по тому же коду можно зарегистрироваться.", "en": "We'll e-mail you a link and a 6-digit code — no password needed. If you have no account yet, the same code registers one."},
+	"esi.send":               {"kz": "Код жіберу", "ru": "Прислать код", "en": "Send the code"},
+	"esi.sent":               {"kz": "Мекенжай дұрыс болса, сілтеме мен код жолда. Олар 15 минут жұмыс істейді.", "ru": "Если адрес верный, ссылка и код уже в пути. Они действуют 15 минут.", "en": "If the address is right, the link and code are on their way. They work for 15 minutes."},
+	"esi.step_up":            {"kz": "Бұл аккаунтқа жаңа елден кіріп жатырсыз. Құпиясөз дұрыс, бірақ кіруді растау үшін аккаунт поштасына код жібердік.", "ru": "Вы входите в этот аккаунт из новой страны. Пароль верный, но для входа нужен код — мы отправили его на почту аккаунта.", "en": "You are signing in to this account from a new country. The password is right, but signing in also takes the code we have sent to the account's e-mail."},
+	"esi.code_for":           {"kz": "Хаттан алынған кодты енгізіңіз, мекенжай:", "ru": "Введите код из письма, отправленного на", "en": "Enter the code from the e-mail sent to"},
+	"esi.code":               {"kz": "Хаттағы код", "ru": "Код из письма", "en": "Code from the e-mail"},
+	"esi.continue":           {"kz": "Жалғастыру", "ru": "Продолжить", "en": "Continue"},
+	"esi.resend":             {"kz": "Жаңа код жіберу", "ru": "Прислать новый код", "en": "Send a new code"},
+	"esi.link_as":            {"kz": "Мына мекенжаймен жалғастыру:", "ru": "Продолжить с адресом", "en": "Continue as"},
+	"esi.with_password":      {"kz": "Құпиясөзбен кіру", "ru": "Войти с паролем", "en": "Sign in with a password"},
+	"esi.finish_title":       {"kz": "Тіркелуді аяқтау", "ru": "Завершение регистрации", "en": "Finish registering"},
+	"esi.finish_sub":         {"kz": "Мекенжай расталды. Аккаунт ашу үшін атыңызды көрсетіңіз — құпиясөз қажет емес.", "ru": "Адрес подтверждён. Укажите имя, чтобы создать аккаунт, — пароль не нужен.", "en": "The address is confirmed. Give your name to create the account — no password needed."},
+	"esi.err_code":           {"kz": "Код қате немесе мерзімі өтті. Қайта енгізіңіз немесе жаңасын сұраңыз.", "ru": "Код неверный или устарел. Попробуйте ещё раз или запросите новый.", "en": "The code is wrong or has expired. Try again or ask for a new one."},
+	"esi.err_link":           {"kz": "Сілтеменің мерзімі өтті немесе ол пайдаланылды. Жаңа код сұраңыз.", "ru": "Ссылка устарела или уже использована. Запросите новый код.", "en": "The link has expired or was already used. Ask for a new code."},
+	"esi.err_send":           {"kz": "Хатты қазір жіберу мүмкін болмады. Кейінірек қайталаңыз немесе құпиясөзбен кіріңіз.", "ru": "Сейчас не удалось отправить письмо. Попробуйте позже или войдите с паролем.", "en": "The e-mail could not be sent right now. Try again later or sign in with a password."},
+	"esi.err_taken":          {"kz": "Бұл мекенжаймен аккаунт бар. Кіру үшін жаңа код сұраңыз.", "ru": "С этим адресом уже есть аккаунт. Запросите новый код, чтобы войти.", "en": "An account with this address already exists. Ask for a new code to sign in."},
+	"form.passkey_or":        {"kz": "немесе", "ru": "или", "en": "or"},
+	"form.passkey_signin":    {"kz": "Кіру кілтімен кіру", "ru": "Войти с ключом доступа", "en": "Sign in with a passkey"},
+	"form.err_passkey":       {"kz": "Кілтпен растау сәтсіз аяқталды. Қайталап көріңіз.", "ru": "Не удалось подтвердить ключом. Попробуйте ещё раз.", "en": "The passkey could not be confirmed. Please try again."},
+	"form.err_session":       {"kz": "Кіру сәтсіз аяқталды. Қайталап көріңіз.", "ru": "Не удалось войти. Попробуйте ещё раз.", "en": "Could not sign you in. Please try again."},
 	"author.verify_title":    {"kz": "Автор ретінде расталу", "ru": "Подтверждение автора", "en": "Author verification"},
 	"author.verify_intro":    {"kz": "Мақалалар автордың нақты аты-жөнімен жарияланады. Бүркеншік аттарға тыйым салынған. Автор болу үшін нақты атыңызды көрсетіп, телефоныңызды бір рет растаңыз.", "ru": "Статьи публикуются под настоящими именем и фамилией автора. Псевдонимы запрещены. Чтобы публиковать статьи, укажите настоящее имя и один раз подтвердите телефон.", "en": "Articles are published under the author's real first and last name. Pseudonyms are not allowed. To publish, provide your real name and verify your phone once."},
 	"author.need_publish":    {"kz": "Мақаланы жариялау үшін алдымен автор ретінде расталыңыз.", "ru": "Чтобы опубликовать статью, сначала пройдите подтверждение автора.", "en": "To publish an article, complete author verification first."},
@@ -1490,107 +1605,139 @@ var messages = map[string]map[string]string{
 	"studio.new":   {"kz": "Жаңа мақала", "ru": "Новая статья", "en": "New story"},
 
 	// User cabinet — profile, avatar, account deletion.
-	"prof.title":             {"kz": "Профиль", "ru": "Профиль", "en": "Profile"},
-	"prof.avatar":            {"kz": "Аватар", "ru": "Аватар", "en": "Avatar"},
-	"prof.avatar_note":       {"kz": "Аватарда автордың нақты фотосуреті болуы тиіс — логотип, мультсурет немесе бөгде адам емес. Бұл оқырмандардың сенімін арттырады.", "ru": "На аватаре должна быть реальная фотография автора — не логотип, не рисунок и не чужое фото. Это повышает доверие читателей.", "en": "Your avatar must be a real photo of you — not a logo, drawing, or someone else's picture. It builds readers' trust."},
-	"prof.avatar_change":     {"kz": "Аватар", "ru": "Аватар", "en": "Avatar"},
-	"prof.avatar_upload":     {"kz": "Жүктеу", "ru": "Загрузить", "en": "Upload"},
-	"prof.avatar_remove":     {"kz": "Фотоны жою", "ru": "Удалить фото", "en": "Remove photo"},
-	"prof.place_saved":       {"kz": "Орналасқан жеріңіз сақталды.", "ru": "Место сохранено.", "en": "Your location has been saved."},
-	"prof.place_bad":         {"kz": "Орналасқан жерді сақтау мүмкін болмады, қайталап көріңіз.", "ru": "Не удалось сохранить место, попробуйте ещё раз.", "en": "Could not save your location; please try again."},
-	"prof.place":             {"kz": "Қай жерде тұрасыз", "ru": "Где вы живёте", "en": "Where you live"},
-	"prof.place_note":        {"kz": "Облыс, қала немесе кент. Жергілікті хабарландырулар мен материалдарды жеткізу үшін керек. Бос қалдырсаңыз — бәрін көресіз.", "ru": "Область, город или посёлок. Нужно, чтобы доводить до вас местное. Оставите пустым — будете видеть всё.", "en": "Region, city or village. It lets us bring you what is local. Leave it empty and you see everything."},
-	"prof.place_current":     {"kz": "Қазір таңдалған", "ru": "Сейчас выбрано", "en": "Currently set to"},
-	"prof.place_save":        {"kz": "Сақтау", "ru": "Сохранить", "en": "Save"},
-	"org.title":              {"kz": "Ұйым атынан жариялау", "ru": "Публикация от имени организации", "en": "Publishing as an organisation"},
-	"org.intro":              {"kz": "Аккаунт адамға тиесілі, ұйым — сол аккаунттың құқығы. Мақаланың астында ұйым тұрады, ал жауапкершілік сізде қалады: модерация журналында сіздің атыңыз жазылады.", "ru": "Аккаунт принадлежит человеку, организация — это право аккаунта. В подписи статьи стоит организация, а ответственность остаётся на вас: в журнале модерации записано ваше имя.", "en": "The account belongs to a person; the organisation is a right attached to it. The byline shows the organisation while responsibility stays with you: the moderation ledger records your name."},
-	"org.name":               {"kz": "Ұйым атауы", "ru": "Название организации", "en": "Organisation name"},
-	"org.name_ph":            {"kz": "Мысалы: «Қашар» ТКШ", "ru": "Например: ЖКХ «Качарец»", "en": "For example: Kachar utilities"},
-	"org.name_hint":          {"kz": "Ресми құжаттардағыдай толық атауы. Тексеруден өткенше бұл атау ешжерде көрсетілмейді.", "ru": "Полное название, как в официальных документах. До проверки это название нигде не показывается.", "en": "The full name as it appears in official documents. Until it is verified the name is shown nowhere."},
-	"org.kind":               {"kz": "Ұйым түрі", "ru": "Вид организации", "en": "Kind of organisation"},
-	"org.kind_hint":          {"kz": "Оқырман хабарламаның ресми, коммуналдық немесе коммерциялық екенін бірден түсінуі үшін.", "ru": "Чтобы читатель сразу понимал, официальное это сообщение, коммунальное или коммерческое.", "en": "So a reader can tell at once whether a notice is official, communal or commercial."},
-	"org.kind_akimat":        {"kz": "Әкімдік", "ru": "Акимат", "en": "Akimat"},
-	"org.kind_utility":       {"kz": "ТКШ, ПИК, коммуналдық қызмет", "ru": "ЖКХ, КСК, коммунальная служба", "en": "Utilities or building management"},
-	"org.kind_company":       {"kz": "Компания, ЖШС", "ru": "Компания, ТОО", "en": "Company"},
-	"org.kind_school":        {"kz": "Мектеп, білім беру ұйымы", "ru": "Школа, учебное заведение", "en": "School or college"},
-	"org.kind_clinic":        {"kz": "Емхана, аурухана", "ru": "Поликлиника, больница", "en": "Clinic or hospital"},
-	"org.kind_public":        {"kz": "Қоғамдық ұйым", "ru": "Общественная организация", "en": "Public organisation"},
-	"org.bin":                {"kz": "БСН", "ru": "БИН", "en": "BIN"},
-	"org.bin_hint":           {"kz": "Он екі сан. Модератор оны ашық тізілімнен тексереді — растаудың негізгі жолы осы.", "ru": "Двенадцать цифр. Модератор сверит их с открытым реестром — это основной способ подтверждения.", "en": "Twelve digits. A moderator checks them against the public register, which is the main way this is confirmed."},
-	"org.place":              {"kz": "Ұйымның аумағы", "ru": "Территория организации", "en": "The organisation's territory"},
-	"org.place_hint":         {"kz": "Ұйым өз аумағы және оның ішіндегі жерлер үшін жариялайды. Қостанай әкімдігі Алматы үшін жаза алмайды.", "ru": "Организация публикует для своей территории и того, что внутри неё. Акимат Костаная не сможет публиковать для Алматы.", "en": "An organisation publishes for its own territory and anything inside it. The Kostanay akimat cannot publish for Almaty."},
-	"org.contact":            {"kz": "Байланыс", "ru": "Контакт для проверки", "en": "Contact for verification"},
-	"org.contact_hint":       {"kz": "Телефон немесе ресми пошта. Әкімдік үшін — gov.kz доменіндегі мекенжай.", "ru": "Телефон или официальная почта. Для акимата — адрес в домене gov.kz.", "en": "A phone number or official e-mail. For an akimat, an address in the gov.kz domain."},
-	"org.about":              {"kz": "Ұйым туралы", "ru": "Об организации", "en": "About the organisation"},
-	"org.submit":             {"kz": "Өтінім жіберу", "ru": "Отправить заявку", "en": "Submit application"},
-	"org.submit_hint":        {"kz": "Өтінімді адам қарайды. Тексеруден өткенше мақалаларыңызға әдеттегідей өз атыңыз қойылады.", "ru": "Заявку рассматривает человек. До проверки ваши статьи подписываются вашим именем, как обычно.", "en": "A person reviews the application. Until it is verified your articles carry your own name, as usual."},
-	"org.applied":            {"kz": "Өтінім жіберілді.", "ru": "Заявка отправлена.", "en": "Application submitted."},
-	"org.st_pending":         {"kz": "Өтінім қаралуда.", "ru": "Заявка на рассмотрении.", "en": "Your application is being reviewed."},
-	"org.st_verified":        {"kz": "Расталды", "ru": "Подтверждено", "en": "Verified"},
-	"org.st_rejected":        {"kz": "Өтінім қабылданбады", "ru": "Заявка отклонена", "en": "Application rejected"},
-	"org.err_name":           {"kz": "Ұйым атауын жазыңыз.", "ru": "Укажите название организации.", "en": "Please give the organisation name."},
-	"org.err_bin":            {"kz": "БСН он екі саннан тұруы керек.", "ru": "БИН должен состоять из двенадцати цифр.", "en": "A BIN must be twelve digits."},
-	"org.err_save":           {"kz": "Сақтау мүмкін болмады, қайталап көріңіз.", "ru": "Не удалось сохранить, попробуйте ещё раз.", "en": "Could not save; please try again."},
-	"org.queue":              {"kz": "Ұйымдардың өтінімдері", "ru": "Заявки организаций", "en": "Organisation applications"},
-	"org.applicant":          {"kz": "Өтініш беруші", "ru": "Заявитель", "en": "Applicant"},
-	"org.verify":             {"kz": "Растау", "ru": "Подтвердить", "en": "Verify"},
-	"org.reject":             {"kz": "Қабылдамау", "ru": "Отклонить", "en": "Reject"},
-	"org.reject_reason":      {"kz": "Себебі", "ru": "Причина отказа", "en": "Reason for refusal"},
-	"article.published_by":   {"kz": "жариялаған", "ru": "опубликовал", "en": "published by"},
-	"prof.identity":          {"kz": "Мәліметтер", "ru": "Данные аккаунта", "en": "Account details"},
-	"prof.name":              {"kz": "Аты-жөні", "ru": "Имя", "en": "Name"},
-	"prof.author_status":     {"kz": "Автор мәртебесі", "ru": "Статус автора", "en": "Author status"},
-	"prof.author_yes":        {"kz": "Расталған автор", "ru": "Автор подтверждён", "en": "Verified author"},
-	"prof.author_no":         {"kz": "Автор болу", "ru": "Стать автором", "en": "Become an author"},
-	"prof.danger":            {"kz": "Қауіпті аймақ", "ru": "Опасная зона", "en": "Danger zone"},
-	"prof.delete_note":       {"kz": "Аккаунтты жою — қайтарымсыз әрекет. Барлық мақалаларыңыз, хабарландыруларыңыз бен пікірлеріңіз біржола жойылады. Растау үшін құпия сөзді енгізіңіз.", "ru": "Удаление аккаунта необратимо. Все ваши статьи, объявления и комментарии будут удалены безвозвратно. Для подтверждения введите пароль.", "en": "Deleting your account is irreversible. All your articles, listings, and comments will be permanently removed. Enter your password to confirm."},
-	"prof.delete_password":   {"kz": "Растау үшін құпия сөз", "ru": "Пароль для подтверждения", "en": "Password to confirm"},
-	"prof.delete_btn":        {"kz": "Аккаунтты жою", "ru": "Удалить аккаунт", "en": "Delete account"},
-	"prof.delete_confirm_js": {"kz": "Сенімдісіз бе? Бұл әрекет қайтарылмайды.", "ru": "Вы уверены? Это действие необратимо.", "en": "Are you sure? This cannot be undone."},
-	"prof.bio":               {"kz": "Өмірбаян", "ru": "О себе", "en": "Bio"},
-	"prof.bio_note":          {"kz": "Автор бетінің жоғарғы жағында көрсетіледі. Өзіңіз, рөліңіз бен қызығушылықтарыңыз туралы қысқаша жазыңыз.", "ru": "Показывается вверху вашей страницы автора. Кратко расскажите о себе, своей роли и интересах.", "en": "Shown at the top of your author page. Briefly describe yourself, your role, and your interests."},
-	"prof.bio_ph":            {"kz": "Мысалы: Shanraq.org жобасының негізін қалаушы әрі CEO. Технологиялар, қоғам және мәдениет туралы жазамын.", "ru": "Например: Основатель и CEO проекта Shanraq.org. Пишу о технологиях, обществе и культуре.", "en": "e.g. Founder and CEO of Shanraq.org. I write about technology, society, and culture."},
-	"prof.bio_save":          {"kz": "Сақтау", "ru": "Сохранить", "en": "Save"},
-	"prof.bio_set":           {"kz": "Өмірбаян сақталды.", "ru": "Био сохранено.", "en": "Bio saved."},
-	"prof.bio_bad":           {"kz": "Өмірбаянды сақтау мүмкін болмады.", "ru": "Не удалось сохранить био.", "en": "Could not save the bio."},
-	"prof.can_publish":       {"kz": "Жоба басшысы ретінде сіз мақалаларды email/телефон растауынсыз жариялай аласыз.", "ru": "Как руководитель проекта вы можете публиковать статьи без подтверждения email и телефона.", "en": "As project leadership you can publish articles without email/phone verification."},
-	"prof.avatar_set":        {"kz": "Фото жаңартылды.", "ru": "Фото обновлено.", "en": "Photo updated."},
-	"prof.avatar_cleared":    {"kz": "Фото жойылды.", "ru": "Фото удалено.", "en": "Photo removed."},
-	"prof.avatar_bad":        {"kz": "Суретті жүктеу мүмкін болмады.", "ru": "Не удалось загрузить изображение.", "en": "Could not upload the image."},
-	"prof.avatar_big":        {"kz": "Файл тым үлкен.", "ru": "Файл слишком большой.", "en": "The file is too large."},
-	"prof.avatar_quota":      {"kz": "Сақтау орны толды. Жаңа сурет жүктер алдында бұрынғы файлдарды өшіріңіз.", "ru": "Место для файлов закончилось. Удалите старые файлы, прежде чем загружать новые.", "en": "You are out of storage. Delete some files before uploading more."},
-	"prof.del_badpass":       {"kz": "Құпия сөз қате.", "ru": "Неверный пароль.", "en": "Incorrect password."},
-	"prof.del_failed":        {"kz": "Аккаунтты жою мүмкін болмады.", "ru": "Не удалось удалить аккаунт.", "en": "Could not delete the account."},
-	"prof.del_lastadmin":     {"kz": "Сіз — сайттың жалғыз әкімшісісіз. Аккаунтты жою үшін алдымен басқа әкімшіні тағайындаңыз.", "ru": "Вы — единственный администратор сайта. Чтобы удалить аккаунт, сначала назначьте другого администратора.", "en": "You are the site's only administrator. Appoint another one before deleting this account."},
-	"studio.stat_total":      {"kz": "Барлық мақала", "ru": "Всего статей", "en": "Total stories"},
-	"studio.stat_published":  {"kz": "Жарияланған", "ru": "Опубликовано", "en": "Published"},
-	"studio.stat_drafts":     {"kz": "Жоба", "ru": "Черновики", "en": "Drafts"},
-	"studio.stat_views":      {"kz": "Барлық оқылым", "ru": "Всего просмотров", "en": "Total views"},
-	"studio.stat_karma":      {"kz": "Карма", "ru": "Карма", "en": "Karma"},
-	"studio.stat_karma_sub":  {"kz": "Оқырман бағасы", "ru": "Оценки читателей", "en": "Reader votes"},
-	"studio.stat_by_lang":    {"kz": "Тіл бойынша", "ru": "По языкам", "en": "By language"},
-	"studio.col_title":       {"kz": "Атауы", "ru": "Название", "en": "Title"},
-	"studio.col_status":      {"kz": "Күй", "ru": "Статус", "en": "Status"},
-	"studio.col_langs":       {"kz": "Тілдер", "ru": "Языки", "en": "Languages"},
-	"studio.col_views":       {"kz": "Оқылым", "ru": "Просмотры", "en": "Views"},
-	"studio.col_depth":       {"kz": "Оқу тереңдігі", "ru": "Дочитывания", "en": "Read depth"},
-	"studio.since":           {"kz": "Қаралымдар мен оқу тереңдігі нөлден саналады, есеп басталған күн —", "ru": "Просмотры и дочитывания считаются с нуля, отсчёт ведётся с", "en": "Views and read depth both count from zero, starting"},
-	"studio.depth_25":        {"kz": "Оқи бастады (ширегіне жетті)", "ru": "Начали читать (дошли до четверти)", "en": "Started reading (reached a quarter)"},
-	"studio.depth_50":        {"kz": "Жартысына жетті", "ru": "Дошли до середины", "en": "Reached halfway"},
-	"studio.depth_75":        {"kz": "Төрттен үшіне жетті", "ru": "Дошли до трёх четвертей", "en": "Reached three quarters"},
-	"studio.depth_100":       {"kz": "Соңына дейін оқыды", "ru": "Дочитали до конца", "en": "Finished the article"},
-	"studio.depth_of_views":  {"kz": "қаралымнан", "ru": "от просмотров", "en": "of views"},
-	"studio.depth_rate":      {"kz": "Бастағандардың ішінен соңына дейін оқығандар үлесі", "ru": "Доля дочитавших до конца среди тех, кто начал читать", "en": "Share of readers who started and then finished"},
-	"studio.depth_few":       {"kz": "Оқырман тым аз — пайыз әлі мағына бермейді", "ru": "Читателей пока слишком мало — процент ничего не значит", "en": "Too few readers yet for the percentage to mean anything"},
-	"studio.col_updated":     {"kz": "Жаңартылды", "ru": "Обновлено", "en": "Updated"},
-	"studio.st_published":    {"kz": "жарияланған", "ru": "опубликовано", "en": "published"},
-	"studio.st_draft":        {"kz": "жоба", "ru": "черновик", "en": "draft"},
-	"studio.st_archived":     {"kz": "мұрағат", "ru": "архив", "en": "archived"},
-	"studio.edit":            {"kz": "Өңдеу", "ru": "Редактировать", "en": "Edit"},
-	"studio.open":            {"kz": "Ашу", "ru": "Открыть", "en": "Open"},
-	"studio.hide":            {"kz": "Жасыру", "ru": "Скрыть", "en": "Hide"},
-	"studio.publish":         {"kz": "Жариялау", "ru": "Опубликовать", "en": "Publish"},
-	"studio.delete":          {"kz": "Жою", "ru": "Удалить", "en": "Delete"},
+	"prof.title":                             {"kz": "Профиль", "ru": "Профиль", "en": "Profile"},
+	"prof.avatar":                            {"kz": "Аватар", "ru": "Аватар", "en": "Avatar"},
+	"prof.avatar_note":                       {"kz": "Аватарда автордың нақты фотосуреті болуы тиіс — логотип, мультсурет немесе бөгде адам емес. Бұл оқырмандардың сенімін арттырады.", "ru": "На аватаре должна быть реальная фотография автора — не логотип, не рисунок и не чужое фото. Это повышает доверие читателей.", "en": "Your avatar must be a real photo of you — not a logo, drawing, or someone else's picture. It builds readers' trust."},
+	"prof.avatar_change":                     {"kz": "Аватар", "ru": "Аватар", "en": "Avatar"},
+	"prof.avatar_upload":                     {"kz": "Жүктеу", "ru": "Загрузить", "en": "Upload"},
+	"prof.avatar_remove":                     {"kz": "Фотоны жою", "ru": "Удалить фото", "en": "Remove photo"},
+	"prof.place_saved":                       {"kz": "Орналасқан жеріңіз сақталды.", "ru": "Место сохранено.", "en": "Your location has been saved."},
+	"prof.place_bad":                         {"kz": "Орналасқан жерді сақтау мүмкін болмады, қайталап көріңіз.", "ru": "Не удалось сохранить место, попробуйте ещё раз.", "en": "Could not save your location; please try again."},
+	"prof.place":                             {"kz": "Қай жерде тұрасыз", "ru": "Где вы живёте", "en": "Where you live"},
+	"prof.place_note":                        {"kz": "Облыс, қала немесе кент. Жергілікті хабарландырулар мен материалдарды жеткізу үшін керек. Бос қалдырсаңыз — бәрін көресіз.", "ru": "Область, город или посёлок. Нужно, чтобы доводить до вас местное. Оставите пустым — будете видеть всё.", "en": "Region, city or village. It lets us bring you what is local. Leave it empty and you see everything."},
+	"prof.place_current":                     {"kz": "Қазір таңдалған", "ru": "Сейчас выбрано", "en": "Currently set to"},
+	"prof.place_save":                        {"kz": "Сақтау", "ru": "Сохранить", "en": "Save"},
+	"org.title":                              {"kz": "Ұйым атынан жариялау", "ru": "Публикация от имени организации", "en": "Publishing as an organisation"},
+	"org.intro":                              {"kz": "Аккаунт адамға тиесілі, ұйым — сол аккаунттың құқығы. Мақаланың астында ұйым тұрады, ал жауапкершілік сізде қалады: модерация журналында сіздің атыңыз жазылады.", "ru": "Аккаунт принадлежит человеку, организация — это право аккаунта. В подписи статьи стоит организация, а ответственность остаётся на вас: в журнале модерации записано ваше имя.", "en": "The account belongs to a person; the organisation is a right attached to it. The byline shows the organisation while responsibility stays with you: the moderation ledger records your name."},
+	"org.name":                               {"kz": "Ұйым атауы", "ru": "Название организации", "en": "Organisation name"},
+	"org.name_ph":                            {"kz": "Мысалы: «Қашар» ТКШ", "ru": "Например: ЖКХ «Качарец»", "en": "For example: Kachar utilities"},
+	"org.name_hint":                          {"kz": "Ресми құжаттардағыдай толық атауы. Тексеруден өткенше бұл атау ешжерде көрсетілмейді.", "ru": "Полное название, как в официальных документах. До проверки это название нигде не показывается.", "en": "The full name as it appears in official documents. Until it is verified the name is shown nowhere."},
+	"org.kind":                               {"kz": "Ұйым түрі", "ru": "Вид организации", "en": "Kind of organisation"},
+	"org.kind_hint":                          {"kz": "Оқырман хабарламаның ресми, коммуналдық немесе коммерциялық екенін бірден түсінуі үшін.", "ru": "Чтобы читатель сразу понимал, официальное это сообщение, коммунальное или коммерческое.", "en": "So a reader can tell at once whether a notice is official, communal or commercial."},
+	"org.kind_akimat":                        {"kz": "Әкімдік", "ru": "Акимат", "en": "Akimat"},
+	"org.kind_utility":                       {"kz": "ТКШ, ПИК, коммуналдық қызмет", "ru": "ЖКХ, КСК, коммунальная служба", "en": "Utilities or building management"},
+	"org.kind_company":                       {"kz": "Компания, ЖШС", "ru": "Компания, ТОО", "en": "Company"},
+	"org.kind_school":                        {"kz": "Мектеп, білім беру ұйымы", "ru": "Школа, учебное заведение", "en": "School or college"},
+	"org.kind_clinic":                        {"kz": "Емхана, аурухана", "ru": "Поликлиника, больница", "en": "Clinic or hospital"},
+	"org.kind_public":                        {"kz": "Қоғамдық ұйым", "ru": "Общественная организация", "en": "Public organisation"},
+	"org.bin":                                {"kz": "БСН", "ru": "БИН", "en": "BIN"},
+	"org.bin_hint":                           {"kz": "Он екі сан. Модератор оны ашық тізілімнен тексереді — растаудың негізгі жолы осы.", "ru": "Двенадцать цифр. Модератор сверит их с открытым реестром — это основной способ подтверждения.", "en": "Twelve digits. A moderator checks them against the public register, which is the main way this is confirmed."},
+	"org.place":                              {"kz": "Ұйымның аумағы", "ru": "Территория организации", "en": "The organisation's territory"},
+	"org.place_hint":                         {"kz": "Ұйым өз аумағы және оның ішіндегі жерлер үшін жариялайды. Қостанай әкімдігі Алматы үшін жаза алмайды.", "ru": "Организация публикует для своей территории и того, что внутри неё. Акимат Костаная не сможет публиковать для Алматы.", "en": "An organisation publishes for its own territory and anything inside it. The Kostanay akimat cannot publish for Almaty."},
+	"org.contact":                            {"kz": "Байланыс", "ru": "Контакт для проверки", "en": "Contact for verification"},
+	"org.contact_hint":                       {"kz": "Телефон немесе ресми пошта. Әкімдік үшін — gov.kz доменіндегі мекенжай.", "ru": "Телефон или официальная почта. Для акимата — адрес в домене gov.kz.", "en": "A phone number or official e-mail. For an akimat, an address in the gov.kz domain."},
+	"org.about":                              {"kz": "Ұйым туралы", "ru": "Об организации", "en": "About the organisation"},
+	"org.submit":                             {"kz": "Өтінім жіберу", "ru": "Отправить заявку", "en": "Submit application"},
+	"org.submit_hint":                        {"kz": "Өтінімді адам қарайды. Тексеруден өткенше мақалаларыңызға әдеттегідей өз атыңыз қойылады.", "ru": "Заявку рассматривает человек. До проверки ваши статьи подписываются вашим именем, как обычно.", "en": "A person reviews the application. Until it is verified your articles carry your own name, as usual."},
+	"org.applied":                            {"kz": "Өтінім жіберілді.", "ru": "Заявка отправлена.", "en": "Application submitted."},
+	"org.st_pending":                         {"kz": "Өтінім қаралуда.", "ru": "Заявка на рассмотрении.", "en": "Your application is being reviewed."},
+	"org.st_verified":                        {"kz": "Расталды", "ru": "Подтверждено", "en": "Verified"},
+	"org.st_rejected":                        {"kz": "Өтінім қабылданбады", "ru": "Заявка отклонена", "en": "Application rejected"},
+	"org.err_name":                           {"kz": "Ұйым атауын жазыңыз.", "ru": "Укажите название организации.", "en": "Please give the organisation name."},
+	"org.err_bin":                            {"kz": "БСН он екі саннан тұруы керек.", "ru": "БИН должен состоять из двенадцати цифр.", "en": "A BIN must be twelve digits."},
+	"org.err_save":                           {"kz": "Сақтау мүмкін болмады, қайталап көріңіз.", "ru": "Не удалось сохранить, попробуйте ещё раз.", "en": "Could not save; please try again."},
+	"org.queue":                              {"kz": "Ұйымдардың өтінімдері", "ru": "Заявки организаций", "en": "Organisation applications"},
+	"org.applicant":                          {"kz": "Өтініш беруші", "ru": "Заявитель", "en": "Applicant"},
+	"org.verify":                             {"kz": "Растау", "ru": "Подтвердить", "en": "Verify"},
+	"org.reject":                             {"kz": "Қабылдамау", "ru": "Отклонить", "en": "Reject"},
+	"org.reject_reason":                      {"kz": "Себебі", "ru": "Причина отказа", "en": "Reason for refusal"},
+	"article.published_by":                   {"kz": "жариялаған", "ru": "опубликовал", "en": "published by"},
+	"prof.identity":                          {"kz": "Мәліметтер", "ru": "Данные аккаунта", "en": "Account details"},
+	"prof.name":                              {"kz": "Аты-жөні", "ru": "Имя", "en": "Name"},
+	"prof.author_status":                     {"kz": "Автор мәртебесі", "ru": "Статус автора", "en": "Author status"},
+	"prof.author_yes":                        {"kz": "Расталған автор", "ru": "Автор подтверждён", "en": "Verified author"},
+	"prof.author_no":                         {"kz": "Автор болу", "ru": "Стать автором", "en": "Become an author"},
+	"prof.danger":                            {"kz": "Қауіпті аймақ", "ru": "Опасная зона", "en": "Danger zone"},
+	"prof.delete_note":                       {"kz": "Аккаунтты жою — қайтарымсыз әрекет. Барлық мақалаларыңыз, хабарландыруларыңыз бен пікірлеріңіз біржола жойылады. Растау үшін құпия сөзді енгізіңіз.", "ru": "Удаление аккаунта необратимо. Все ваши статьи, объявления и комментарии будут удалены безвозвратно. Для подтверждения введите пароль.", "en": "Deleting your account is irreversible. All your articles, listings, and comments will be permanently removed. Enter your password to confirm."},
+	"prof.delete_password":                   {"kz": "Растау үшін құпия сөз", "ru": "Пароль для подтверждения", "en": "Password to confirm"},
+	"prof.delete_btn":                        {"kz": "Аккаунтты жою", "ru": "Удалить аккаунт", "en": "Delete account"},
+	"prof.delete_confirm_js":                 {"kz": "Сенімдісіз бе? Бұл әрекет қайтарылмайды.", "ru": "Вы уверены? Это действие необратимо.", "en": "Are you sure? This cannot be undone."},
+	"prof.passkeys":                          {"kz": "Кіру кілттері", "ru": "Ключи доступа", "en": "Passkeys"},
+	"prof.passkeys_note":                     {"kz": "Кілт телефонда, ноутбукта немесе USB-кілтте сақталады және кодсыз кіруге мүмкіндік береді. Бірнешеуін қосыңыз: біреуі жоғалса, аккаунт жабылып қалмайды.", "ru": "Ключ хранится в телефоне, ноутбуке или USB-ключе и позволяет входить без кодов. Добавьте несколько: потеря одного не закроет доступ к аккаунту.", "en": "A passkey lives on your phone, laptop or USB key and signs you in without codes. Add more than one, so losing one does not lock you out."},
+	"prof.passkeys_none":                     {"kz": "Әзірге кілт жоқ.", "ru": "Ключей пока нет.", "en": "No passkeys yet."},
+	"prof.passkey_synced":                    {"kz": "синхрондалады", "ru": "синхронизируется", "en": "synced"},
+	"prof.passkey_added_on":                  {"kz": "қосылған", "ru": "добавлен", "en": "added"},
+	"prof.passkey_used_on":                   {"kz": "соңғы рет", "ru": "последний вход", "en": "last used"},
+	"prof.passkey_name":                      {"kz": "Кілт атауы", "ru": "Название ключа", "en": "Passkey name"},
+	"prof.passkey_name_ph":                   {"kz": "Мысалы: жұмыс ноутбугы", "ru": "Например: рабочий ноутбук", "en": "e.g. Work laptop"},
+	"prof.passkey_add":                       {"kz": "Кілт қосу", "ru": "Добавить ключ", "en": "Add passkey"},
+	"prof.passkey_remove":                    {"kz": "Жою", "ru": "Удалить", "en": "Remove"},
+	"prof.passkey_remove_confirm_js":         {"kz": "Кілтті жою керек пе? Басқа құрылғылардағы барлық сеанстар аяқталады.", "ru": "Удалить ключ? Все сеансы на других устройствах будут завершены.", "en": "Remove this passkey? You will be signed out on every other device."},
+	"prof.passkey_added":                     {"kz": "Кілт қосылды.", "ru": "Ключ добавлен.", "en": "Passkey added."},
+	"prof.passkey_removed":                   {"kz": "Кілт жойылды. Басқа құрылғылардағы сеанстар аяқталды.", "ru": "Ключ удалён. Сеансы на других устройствах завершены.", "en": "Passkey removed. You have been signed out on other devices."},
+	"prof.passkey_password_wrong":            {"kz": "Құпиясөз дұрыс емес.", "ru": "Неверный пароль.", "en": "Wrong password."},
+	"prof.passkey_too_many":                  {"kz": "Кілттер тым көп. Жаңасын қоспас бұрын біреуін жойыңыз.", "ru": "Слишком много ключей. Удалите один, прежде чем добавлять новый.", "en": "Too many passkeys. Remove one before adding another."},
+	"prof.passkey_exists":                    {"kz": "Бұл кілт аккаунтқа тіркеліп қойған.", "ru": "Этот ключ уже привязан к аккаунту.", "en": "This passkey is already registered."},
+	"prof.sessions":                          {"kz": "Сеанстар", "ru": "Сеансы", "en": "Sessions"},
+	"prof.sessions_note":                     {"kz": "Аккаунтыңызға кірілген құрылғылар. Біреуі сіздікі болмаса — оны аяқтап, құпиясөзді ауыстырыңыз.", "ru": "Где выполнен вход в ваш аккаунт. Если какой-то сеанс не ваш — завершите его и смените пароль.", "en": "Where your account is signed in. If a session is not yours, end it and change your password."},
+	"prof.sessions_none":                     {"kz": "Белсенді сеанстар жоқ.", "ru": "Активных сеансов нет.", "en": "No active sessions."},
+	"prof.session_current":                   {"kz": "Осы құрылғы", "ru": "Это устройство", "en": "This device"},
+	"prof.session_started":                   {"kz": "Кіру", "ru": "Вход", "en": "Signed in"},
+	"prof.session_active":                    {"kz": "белсенді", "ru": "активность", "en": "last active"},
+	"prof.session_revoke":                    {"kz": "Аяқтау", "ru": "Завершить", "en": "End"},
+	"prof.session_revoke_confirm_js":         {"kz": "Бұл сеансты аяқтау керек пе? Сол құрылғыда қайта кіру қажет болады.", "ru": "Завершить этот сеанс? На том устройстве придётся войти заново.", "en": "End this session? That device will have to sign in again."},
+	"prof.sessions_revoke_others":            {"kz": "Қалғанының бәрін аяқтау", "ru": "Завершить все остальные", "en": "End all other sessions"},
+	"prof.sessions_revoke_others_confirm_js": {"kz": "Осыдан басқа барлық сеанстарды аяқтау керек пе?", "ru": "Завершить все сеансы, кроме этого?", "en": "End every session except this one?"},
+	"prof.session_revoked":                   {"kz": "Сеанс аяқталды.", "ru": "Сеанс завершён.", "en": "Session ended."},
+	"prof.sessions_revoked":                  {"kz": "Қалған сеанстар аяқталды.", "ru": "Остальные сеансы завершены.", "en": "All other sessions ended."},
+	"sess.dev_mobile":                        {"kz": "Телефон", "ru": "Телефон", "en": "Phone"},
+	"sess.dev_tablet":                        {"kz": "Планшет", "ru": "Планшет", "en": "Tablet"},
+	"sess.dev_desktop":                       {"kz": "Компьютер", "ru": "Компьютер", "en": "Computer"},
+	"sess.dev_other":                         {"kz": "Белгісіз құрылғы", "ru": "Неизвестное устройство", "en": "Unknown device"},
+	"prof.bio":                               {"kz": "Өмірбаян", "ru": "О себе", "en": "Bio"},
+	"prof.bio_note":                          {"kz": "Автор бетінің жоғарғы жағында көрсетіледі. Өзіңіз, рөліңіз бен қызығушылықтарыңыз туралы қысқаша жазыңыз.", "ru": "Показывается вверху вашей страницы автора. Кратко расскажите о себе, своей роли и интересах.", "en": "Shown at the top of your author page. Briefly describe yourself, your role, and your interests."},
+	"prof.bio_ph":                            {"kz": "Мысалы: Shanraq.org жобасының негізін қалаушы әрі CEO. Технологиялар, қоғам және мәдениет туралы жазамын.", "ru": "Например: Основатель и CEO проекта Shanraq.org. Пишу о технологиях, обществе и культуре.", "en": "e.g. Founder and CEO of Shanraq.org. I write about technology, society, and culture."},
+	"prof.bio_save":                          {"kz": "Сақтау", "ru": "Сохранить", "en": "Save"},
+	"prof.bio_set":                           {"kz": "Өмірбаян сақталды.", "ru": "Био сохранено.", "en": "Bio saved."},
+	"prof.bio_bad":                           {"kz": "Өмірбаянды сақтау мүмкін болмады.", "ru": "Не удалось сохранить био.", "en": "Could not save the bio."},
+	"prof.can_publish":                       {"kz": "Жоба басшысы ретінде сіз мақалаларды email/телефон растауынсыз жариялай аласыз.", "ru": "Как руководитель проекта вы можете публиковать статьи без подтверждения email и телефона.", "en": "As project leadership you can publish articles without email/phone verification."},
+	"prof.avatar_set":                        {"kz": "Фото жаңартылды.", "ru": "Фото обновлено.", "en": "Photo updated."},
+	"prof.avatar_cleared":                    {"kz": "Фото жойылды.", "ru": "Фото удалено.", "en": "Photo removed."},
+	"prof.avatar_bad":                        {"kz": "Суретті жүктеу мүмкін болмады.", "ru": "Не удалось загрузить изображение.", "en": "Could not upload the image."},
+	"prof.avatar_big":                        {"kz": "Файл тым үлкен.", "ru": "Файл слишком большой.", "en": "The file is too large."},
+	"prof.avatar_quota":                      {"kz": "Сақтау орны толды. Жаңа сурет жүктер алдында бұрынғы файлдарды өшіріңіз.", "ru": "Место для файлов закончилось. Удалите старые файлы, прежде чем загружать новые.", "en": "You are out of storage. Delete some files before uploading more."},
+	"prof.del_badpass":                       {"kz": "Құпия сөз қате.", "ru": "Неверный пароль.", "en": "Incorrect password."},
+	"prof.del_failed":                        {"kz": "Аккаунтты жою мүмкін болмады.", "ru": "Не удалось удалить аккаунт.", "en": "Could not delete the account."},
+	"prof.del_lastadmin":                     {"kz": "Сіз — сайттың жалғыз әкімшісісіз. Аккаунтты жою үшін алдымен басқа әкімшіні тағайындаңыз.", "ru": "Вы — единственный администратор сайта. Чтобы удалить аккаунт, сначала назначьте другого администратора.", "en": "You are the site's only administrator. Appoint another one before deleting this account."},
+	"studio.stat_total":                      {"kz": "Барлық мақала", "ru": "Всего статей", "en": "Total stories"},
+	"studio.stat_published":                  {"kz": "Жарияланған", "ru": "Опубликовано", "en": "Published"},
+	"studio.stat_drafts":                     {"kz": "Жоба", "ru": "Черновики", "en": "Drafts"},
+	"studio.stat_views":                      {"kz": "Барлық оқылым", "ru": "Всего просмотров", "en": "Total views"},
+	"studio.stat_karma":                      {"kz": "Карма", "ru": "Карма", "en": "Karma"},
+	"studio.stat_karma_sub":                  {"kz": "Оқырман бағасы", "ru": "Оценки читателей", "en": "Reader votes"},
+	"studio.stat_by_lang":                    {"kz": "Тіл бойынша", "ru": "По языкам", "en": "By language"},
+	"studio.col_title":                       {"kz": "Атауы", "ru": "Название", "en": "Title"},
+	"studio.col_status":                      {"kz": "Күй", "ru": "Статус", "en": "Status"},
+	"studio.col_langs":                       {"kz": "Тілдер", "ru": "Языки", "en": "Languages"},
+	"studio.col_views":                       {"kz": "Оқылым", "ru": "Просмотры", "en": "Views"},
+	"studio.col_depth":                       {"kz": "Оқу тереңдігі", "ru": "Дочитывания", "en": "Read depth"},
+	"studio.since":                           {"kz": "Қаралымдар мен оқу тереңдігі нөлден саналады, есеп басталған күн —", "ru": "Просмотры и дочитывания считаются с нуля, отсчёт ведётся с", "en": "Views and read depth both count from zero, starting"},
+	"studio.depth_25":                        {"kz": "Оқи бастады (ширегіне жетті)", "ru": "Начали читать (дошли до четверти)", "en": "Started reading (reached a quarter)"},
+	"studio.depth_50":                        {"kz": "Жартысына жетті", "ru": "Дошли до середины", "en": "Reached halfway"},
+	"studio.depth_75":                        {"kz": "Төрттен үшіне жетті", "ru": "Дошли до трёх четвертей", "en": "Reached three quarters"},
+	"studio.depth_100":                       {"kz": "Соңына дейін оқыды", "ru": "Дочитали до конца", "en": "Finished the article"},
+	"studio.depth_of_views":                  {"kz": "қаралымнан", "ru": "от просмотров", "en": "of views"},
+	"studio.depth_rate":                      {"kz": "Бастағандардың ішінен соңына дейін оқығандар үлесі", "ru": "Доля дочитавших до конца среди тех, кто начал читать", "en": "Share of readers who started and then finished"},
+	"studio.depth_few":                       {"kz": "Оқырман тым аз — пайыз әлі мағына бермейді", "ru": "Читателей пока слишком мало — процент ничего не значит", "en": "Too few readers yet for the percentage to mean anything"},
+	"studio.col_updated":                     {"kz": "Жаңартылды", "ru": "Обновлено", "en": "Updated"},
+	"studio.st_published":                    {"kz": "жарияланған", "ru": "опубликовано", "en": "published"},
+	"studio.st_draft":                        {"kz": "жоба", "ru": "черновик", "en": "draft"},
+	"studio.st_archived":                     {"kz": "мұрағат", "ru": "архив", "en": "archived"},
+	"studio.edit":                            {"kz": "Өңдеу", "ru": "Редактировать", "en": "Edit"},
+	"studio.open":                            {"kz": "Ашу", "ru": "Открыть", "en": "Open"},
+	"studio.hide":                            {"kz": "Жасыру", "ru": "Скрыть", "en": "Hide"},
+	"studio.publish":                         {"kz": "Жариялау", "ru": "Опубликовать", "en": "Publish"},
+	"studio.delete":                          {"kz": "Жою", "ru": "Удалить", "en": "Delete"},
 	"studio.delete_hint": {
 		"kz": "Тек жобаны жоюға болады. Жарияланғанды алдымен жасыру керек.",
 		"ru": "Удалить можно только черновик. Опубликованную статью сначала нужно скрыть.",
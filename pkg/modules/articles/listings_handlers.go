@@ -13,6 +13,7 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
+	"shanraq.org/pkg/events"
 )
 
 // ListingsPage backs the real-estate marketplace grid.
@@ -328,6 +329,7 @@ func (m *Module) handleListingCreate(w http.ResponseWriter, r *http.Request) {
 		m.listingFormFail(w, r, lang, "", in, T(lang, "re.err_save_failed"))
 		return
 	}
+	m.announce(r.Context(), events.ListingCreated{ListingID: id, AuthorID: authorID})
 	// A real listing is the rewardable action: if this author was invited,
 	// their referrer earns promotion credit now. Best-effort — a reward failure
 	// must not fail the listing.
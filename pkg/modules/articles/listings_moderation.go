@@ -35,9 +35,13 @@ type moderateListingPayload struct {
 	ListingID string `json:"listing_id"`
 }
 
-// RegisterJobs attaches the listing screening handler to the job queue.
+// RegisterJobs attaches the listing screening and data export handlers to the
+// job queue, and the module's recurring sweeps. The screening calls the AI
+// provider, so it backs off the way translation does.
 func (m *Module) RegisterJobs(j *jobs.Module) {
-	j.Handle(JobModerateListing, m.handleModerateListingJob)
+	j.Handle(JobModerateListing, m.handleModerateListingJob, jobs.WithBackoff(time.Minute, time.Hour))
+	j.Handle(JobDataExport, m.handleDataExportJob)
+	m.registerSchedules(j)
 }
 
 // enqueueListingScreening files a listing for background screening. Failures are
@@ -53,13 +57,18 @@ func (m *Module) enqueueListingScreening(ctx context.Context, listingID, authorI
 		m.rt.Logger.Warn("encode listing screening payload", zap.Error(err))
 		return
 	}
-	if err := m.jobs.Enqueue(ctx, jobs.Job{
+	// Keyed on the listing while it waits: the screening reads the listing as
+	// it is when it runs, so edits made before then need no second one.
+	if _, err := m.jobs.Enqueue(ctx, jobs.Job{
 		ID:          uuid.New(),
 		UserID:      authorID,
 		Name:        JobModerateListing,
 		Payload:     payload,
 		RunAt:       time.Now(),
 		MaxAttempts: 3,
+		UniqueKey:   "listing:" + listingID.String(),
+		UniqueScope: jobs.ScopePending,
+		Queue:       ai.Queue,
 	}); err != nil {
 		m.rt.Logger.Warn("enqueue listing screening", zap.Error(err))
 	}
@@ -4,114 +4,123 @@ import (
 	"context"
 	"fmt"
 	"strings"
-	"time"
 
 	"go.uber.org/zap"
+	"shanraq.org/pkg/events"
+	"shanraq.org/pkg/modules/jobs"
 	"shanraq.org/pkg/shanraq"
 )
 
-// reminderInterval is how often the expiry-reminder sweep runs.
-const reminderInterval = 6 * time.Hour
+// Recurring sweeps, run as scheduled jobs so they show in the console and
+// fire on one instance however many are serving.
+const (
+	// JobListingReminders mails owners whose free window ends within ~2 days.
+	JobListingReminders = "listing_reminders"
+	// JobListingPurge deletes listings past their window.
+	JobListingPurge = "listing_purge"
+	// JobAdHoldExpiry frees ad slots whose 30-minute payment hold lapsed. It
+	// runs often because a held slot the buyer abandoned should return to sale
+	// quickly.
+	JobAdHoldExpiry = "ad_hold_expiry"
+	// JobMaintenanceRestore brings the site back once a planned maintenance
+	// window passes, so it recovers on time even if nobody visits to trigger
+	// the guard.
+	JobMaintenanceRestore = "maintenance_restore"
+)
 
-// Start launches the background sweep that reminds owners about listings whose
-// free window is about to end (within ~2 days).
+// registerSchedules puts the sweeps on the queue. The purge runs a quarter of
+// an hour after the reminders, as the loop it replaced ran them back to back.
+func (m *Module) registerSchedules(j *jobs.Module) {
+	j.Schedule(JobListingReminders, "0 */6 * * *", func(ctx context.Context, _ *shanraq.Runtime, _ jobs.Job) error {
+		return m.sweepReminders(ctx)
+	})
+	j.Schedule(JobListingPurge, "15 */6 * * *", func(ctx context.Context, _ *shanraq.Runtime, _ jobs.Job) error {
+		return m.sweepExpired(ctx)
+	})
+	j.Schedule(JobAdHoldExpiry, "*/5 * * * *", func(ctx context.Context, _ *shanraq.Runtime, _ jobs.Job) error {
+		if m.pay == nil {
+			return nil
+		}
+		n, err := m.pay.ExpirePending(ctx)
+		if err != nil {
+			return fmt.Errorf("expire payment holds: %w", err)
+		}
+		if n > 0 {
+			m.rt.Logger.Info("released unpaid ad-slot holds", zap.Int("count", n))
+		}
+		return nil
+	})
+	j.Schedule(JobDataExportPurge, "40 * * * *", func(ctx context.Context, _ *shanraq.Runtime, _ jobs.Job) error {
+		n, err := m.exports.Purge(ctx)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			m.rt.Logger.Info("purged expired data exports", zap.Int("count", n))
+		}
+		return nil
+	})
+	j.Schedule(JobMaintenanceRestore, "* * * * *", func(ctx context.Context, _ *shanraq.Runtime, _ jobs.Job) error {
+		if m.flags == nil {
+			return nil
+		}
+		changed, err := m.flags.RestoreExpired(ctx)
+		if err != nil {
+			return fmt.Errorf("restore expired service flags: %w", err)
+		}
+		if changed {
+			m.rt.Logger.Info("site maintenance window ended — restored to service")
+		}
+		return nil
+	})
+}
+
+// Start launches what still runs on every instance: the audience counters and
+// the infobar cache live in this process's memory, so each instance has to
+// flush and refresh its own. Everything that acts on shared data is a
+// schedule; see registerSchedules.
 func (m *Module) Start(ctx context.Context, _ *shanraq.Runtime) error {
-	go m.reminderLoop(ctx)
-	go m.payExpiryLoop(ctx)         // release unpaid ad-slot holds
-	go m.maintenanceExpiryLoop(ctx) // auto-restore the site when its window ends
-	go m.metricsFlushLoop(ctx)      // persist buffered audience counters
+	go m.metricsFlushLoop(ctx) // persist buffered audience counters
 	if m.infobar != nil {
 		go m.infobar.Run(ctx) // background weather + exchange-rate refresher
 	}
 	return nil
 }
 
-// payExpiryLoop frees ad slots whose 30-minute payment hold lapsed. It runs
-// often because a held slot the buyer abandoned should return to sale quickly.
-func (m *Module) payExpiryLoop(ctx context.Context) {
-	t := time.NewTicker(5 * time.Minute)
-	defer t.Stop()
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-t.C:
-			if m.pay == nil {
-				continue
-			}
-			if n, err := m.pay.ExpirePending(ctx); err != nil {
-				m.rt.Logger.Warn("expire payment holds", zap.Error(err))
-			} else if n > 0 {
-				m.rt.Logger.Info("released unpaid ad-slot holds", zap.Int("count", n))
-			}
-		}
-	}
-}
-
-// maintenanceExpiryLoop brings the site back once a planned maintenance window
-// passes, so it recovers on time even if nobody visits to trigger the guard.
-func (m *Module) maintenanceExpiryLoop(ctx context.Context) {
-	t := time.NewTicker(time.Minute)
-	defer t.Stop()
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-t.C:
-			if m.flags == nil {
-				continue
-			}
-			if changed, err := m.flags.RestoreExpired(ctx); err != nil {
-				m.rt.Logger.Warn("restore expired service flags", zap.Error(err))
-			} else if changed {
-				m.rt.Logger.Info("site maintenance window ended — restored to service")
-			}
-		}
-	}
-}
-
-func (m *Module) reminderLoop(ctx context.Context) {
-	select { // let boot/migrations settle before the first sweep
-	case <-ctx.Done():
-		return
-	case <-time.After(30 * time.Second):
-	}
-	m.sweepReminders(ctx)
-	m.sweepExpired(ctx)
-	t := time.NewTicker(reminderInterval)
-	defer t.Stop()
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-t.C:
-			m.sweepReminders(ctx)
-			m.sweepExpired(ctx)
-		}
-	}
+// Stop writes the audience counters buffered since the last flush. The HTTP
+// server has drained by the time this runs, so nothing is still counting; a
+// deploy used to drop up to half a minute of them with the process.
+func (m *Module) Stop(ctx context.Context) error {
+	m.metrics.Flush(ctx)
+	return nil
 }
 
 // sweepExpired permanently deletes listings past their 21-day window and all
 // their data (owners were warned 2 days earlier by sweepReminders).
-func (m *Module) sweepExpired(ctx context.Context) {
-	n, err := m.listings.PurgeExpired(ctx)
+func (m *Module) sweepExpired(ctx context.Context) error {
+	purged, err := m.listings.PurgeExpired(ctx)
 	if err != nil {
-		m.rt.Logger.Error("listing purge sweep", zap.Error(err))
-		return
+		return fmt.Errorf("listing purge sweep: %w", err)
 	}
-	if n > 0 {
-		m.rt.Logger.Info("purged expired listings", zap.Int64("count", n))
+	if len(purged) > 0 {
+		m.rt.Logger.Info("purged expired listings", zap.Int("count", len(purged)))
 	}
+	for _, l := range purged {
+		m.announce(ctx, events.ListingExpired{ListingID: l.ID, AuthorID: l.AuthorID})
+	}
+	return nil
 }
 
-func (m *Module) sweepReminders(ctx context.Context) {
+// sweepReminders mails each owner once. A message that does not go out is
+// left unmarked and tried at the next run rather than failing the job: the
+// others have been sent, and a retry would only get to the same one sooner.
+func (m *Module) sweepReminders(ctx context.Context) error {
 	if m.mailer == nil {
-		return
+		return nil
 	}
 	due, err := m.listings.DueReminders(ctx)
 	if err != nil {
-		m.rt.Logger.Error("listing reminders sweep", zap.Error(err))
-		return
+		return fmt.Errorf("listing reminders sweep: %w", err)
 	}
 	base := strings.TrimRight(m.rt.Config.PublicBase(), "/")
 	for _, l := range due {
@@ -131,4 +140,5 @@ func (m *Module) sweepReminders(ctx context.Context) {
 			m.rt.Logger.Error("mark reminded", zap.Error(err))
 		}
 	}
+	return nil
 }
@@ -511,28 +511,48 @@ func (s *ListingStore) Facets(ctx context.Context) (ListingFacets, error) {
 // together with their dependent rows (reports, favorites) — enforcing the
 // "then all its data is deleted" policy. Runs from the background sweep, in one
 // transaction so an extend that lands mid-sweep can't orphan dependents.
-func (s *ListingStore) PurgeExpired(ctx context.Context) (int64, error) {
+// Returns what it deleted, so the sweep can tell anyone who kept a reference.
+func (s *ListingStore) PurgeExpired(ctx context.Context) ([]PurgedListing, error) {
 	tx, err := s.db.Begin(ctx)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 	defer tx.Rollback(ctx)
 
 	const expired = "expires_at < NOW()"
 	if _, err := tx.Exec(ctx, `DELETE FROM listing_reports WHERE listing_id IN (SELECT id FROM listings WHERE `+expired+`)`); err != nil {
-		return 0, fmt.Errorf("purge reports: %w", err)
+		return nil, fmt.Errorf("purge reports: %w", err)
 	}
 	if _, err := tx.Exec(ctx, `DELETE FROM favorites WHERE item_type = 'listing' AND item_id IN (SELECT id FROM listings WHERE `+expired+`)`); err != nil {
-		return 0, fmt.Errorf("purge favorites: %w", err)
+		return nil, fmt.Errorf("purge favorites: %w", err)
 	}
-	ct, err := tx.Exec(ctx, `DELETE FROM listings WHERE `+expired)
+	rows, err := tx.Query(ctx, `DELETE FROM listings WHERE `+expired+` RETURNING id, author_id`)
 	if err != nil {
-		return 0, fmt.Errorf("purge listings: %w", err)
+		return nil, fmt.Errorf("purge listings: %w", err)
+	}
+	var purged []PurgedListing
+	for rows.Next() {
+		var p PurgedListing
+		if err := rows.Scan(&p.ID, &p.AuthorID); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("purge listings: %w", err)
+		}
+		purged = append(purged, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("purge listings: %w", err)
 	}
 	if err := tx.Commit(ctx); err != nil {
-		return 0, err
+		return nil, err
 	}
-	return ct.RowsAffected(), nil
+	return purged, nil
+}
+
+// PurgedListing identifies a listing PurgeExpired deleted.
+type PurgedListing struct {
+	ID       uuid.UUID
+	AuthorID uuid.UUID
 }
 
 // GetByID loads a single published listing.
@@ -40,7 +40,7 @@ func (m *Module) maintenanceGuard(next http.Handler) http.Handler {
 			next.ServeHTTP(w, r)
 			return
 		}
-		if claims, ok := auth.ClaimsFromContext(r.Context()); ok && claims.HasAnyRole(adminRoles...) {
+		if m.can(r, auth.PermAdminView) {
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -49,9 +49,13 @@ func (m *Module) maintenanceGuard(next http.Handler) http.Handler {
 }
 
 // isMaintenanceExempt keeps the recovery surface reachable during a global
-// takedown: the admin panel (to switch back) and the login/logout it needs.
+// takedown: the admin panel (to switch back) and the login/logout it needs,
+// passkey step included — an administrator whose account has a passkey cannot
+// sign in without it — and the e-mail code step, which is where a staff
+// account without one is sent when it signs in from a new country.
 func isMaintenanceExempt(p string) bool {
-	if p == "/studio/login" || p == "/studio/logout" {
+	if p == "/studio/login" || p == "/studio/logout" ||
+		strings.HasPrefix(p, "/studio/login/passkey") || strings.HasPrefix(p, "/studio/login/email") {
 		return true
 	}
 	return p == "/admin" || strings.HasPrefix(p, "/admin/")
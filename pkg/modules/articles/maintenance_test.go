@@ -27,13 +27,14 @@ func TestValidServiceCode(t *testing.T) {
 }
 
 func TestIsMaintenanceExempt(t *testing.T) {
-	exempt := []string{"/studio/login", "/studio/logout", "/admin", "/admin/services", "/admin/roles"}
+	exempt := []string{"/studio/login", "/studio/logout", "/studio/login/passkey", "/studio/login/passkey/options",
+		"/studio/login/email", "/studio/login/email/confirm", "/admin", "/admin/services", "/admin/roles"}
 	for _, p := range exempt {
 		if !isMaintenanceExempt(p) {
 			t.Errorf("%s should be exempt (recovery route)", p)
 		}
 	}
-	blocked := []string{"/", "/read/x", "/listings", "/advertise", "/studio", "/adminx", "/studio/new"}
+	blocked := []string{"/", "/read/x", "/listings", "/advertise", "/studio", "/adminx", "/studio/new", "/studio/passkeys"}
 	for _, p := range blocked {
 		if isMaintenanceExempt(p) {
 			t.Errorf("%s should NOT be exempt", p)
@@ -11,6 +11,7 @@ import (
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 
+	"shanraq.org/pkg/events"
 	"shanraq.org/pkg/modules/auth"
 )
 
@@ -86,7 +87,7 @@ func (m *Module) handleFileAppeal(w http.ResponseWriter, r *http.Request) {
 // and writes the reversal into the ledger as its own entry.
 func (m *Module) handleAdminResolveAppeal(w http.ResponseWriter, r *http.Request) {
 	claims, _ := auth.ClaimsFromContext(r.Context())
-	if !canModerate(claims) {
+	if claims == nil || !m.can(r, auth.PermAppealsResolve) {
 		http.Error(w, "forbidden", http.StatusForbidden)
 		return
 	}
@@ -102,6 +103,8 @@ func (m *Module) handleAdminResolveAppeal(w http.ResponseWriter, r *http.Request
 		http.Redirect(w, r, "/admin?err=appeal", http.StatusSeeOther)
 		return
 	}
+	m.auth.Audit(r, auth.AuditRecord{Action: "appeal.resolve", TargetType: "appeal", TargetID: id.String(),
+		After: map[string]any{"uphold": uphold}})
 	http.Redirect(w, r, "/admin?ok=appeal_resolved", http.StatusSeeOther)
 }
 
@@ -111,7 +114,7 @@ func (m *Module) handleAdminResolveAppeal(w http.ResponseWriter, r *http.Request
 // way forward.
 func (m *Module) handleAdminDecideArticle(w http.ResponseWriter, r *http.Request) {
 	claims, _ := auth.ClaimsFromContext(r.Context())
-	if !canModerate(claims) {
+	if claims == nil || !m.can(r, auth.PermArticlesDecide) {
 		http.Error(w, "forbidden", http.StatusForbidden)
 		return
 	}
@@ -127,6 +130,8 @@ func (m *Module) handleAdminDecideArticle(w http.ResponseWriter, r *http.Request
 		http.Redirect(w, r, "/admin?err=decide", http.StatusSeeOther)
 		return
 	}
+	m.auth.Audit(r, auth.AuditRecord{Action: "article.decide", TargetType: "article", TargetID: id.String(),
+		After: map[string]any{"decision": decision}})
 	http.Redirect(w, r, "/admin?ok=decided", http.StatusSeeOther)
 }
 
@@ -175,6 +180,7 @@ func (m *Module) handleArticleReport(w http.ResponseWriter, r *http.Request) {
 		// and the ledger keeps them apart. The author sees it in their own
 		// moderation page and may appeal it once, like any other decision.
 		m.logReaderHide(r.Context(), a.ID, res)
+		m.announce(r.Context(), events.ArticleUnpublished{ArticleID: a.ID, Reason: events.UnpublishedByReaders})
 		http.Redirect(w, r, "/?lang="+lang+"&reported=hidden", http.StatusSeeOther)
 		return
 	}
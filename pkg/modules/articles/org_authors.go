@@ -360,7 +360,7 @@ func (m *Module) handleOrgApply(w http.ResponseWriter, r *http.Request) {
 // just a name in a table.
 func (m *Module) handleOrgDecide(w http.ResponseWriter, r *http.Request) {
 	claims, _ := auth.ClaimsFromContext(r.Context())
-	if !canManageUsers(claims) {
+	if !m.can(r, auth.PermPartnersDecide) {
 		http.Error(w, "forbidden", http.StatusForbidden)
 		return
 	}
@@ -389,5 +389,7 @@ func (m *Module) handleOrgDecide(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
+	m.auth.Audit(r, auth.AuditRecord{Action: "org.decide", TargetType: "user", TargetID: uid.String(),
+		After: map[string]any{"status": status, "reason": reason}})
 	http.Redirect(w, r, "/admin?ok=org_set", http.StatusSeeOther)
 }
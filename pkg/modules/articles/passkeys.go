@@ -0,0 +1,224 @@
+package articles
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"shanraq.org/pkg/modules/auth"
+)
+
+// Passkeys in the studio: signing in with one, the second step after a
+// password for an account that has one, and adding and removing them in the
+// profile. The ceremonies themselves run in the browser (static/js/passkeys.js),
+// which is why these endpoints speak JSON while the rest of the studio posts
+// forms; removal is an ordinary form.
+
+// maxPasskeyBody bounds a ceremony's JSON. An attestation with a certificate
+// chain is a few kilobytes; nothing legitimate comes near this.
+const maxPasskeyBody = 64 << 10
+
+// passkeyRequest is what the browser posts back from a ceremony. Credential is
+// the PublicKeyCredential as JSON, passed to the verifier untouched.
+type passkeyRequest struct {
+	ChallengeID string          `json:"challenge_id"`
+	Credential  json.RawMessage `json:"credential"`
+	Name        string          `json:"name"`
+	Password    string          `json:"password"`
+	Next        string          `json:"next"`
+}
+
+// handlePasskeyOptions opens a sign-in with a passkey alone.
+func (m *Module) handlePasskeyOptions(w http.ResponseWriter, r *http.Request) {
+	lang := m.resolveLang(w, r)
+	if !m.auth.PasskeysEnabled() {
+		http.NotFound(w, r)
+		return
+	}
+	if !m.auth.AllowAuthAttempt(r, "passkey", "") {
+		passkeyJSON(w, http.StatusTooManyRequests, map[string]string{"error": T(lang, "form.err_rate_limit")})
+		return
+	}
+	challenge, err := m.auth.BeginPasskeySignin(r.Context())
+	if err != nil {
+		m.rt.Logger.Error("begin passkey sign-in", zap.Error(err))
+		passkeyJSON(w, http.StatusInternalServerError, map[string]string{"error": T(lang, "form.err_passkey")})
+		return
+	}
+	passkeyJSON(w, http.StatusOK, challengeJSON(challenge))
+}
+
+// handlePasskeyLogin finishes a passkey sign-in, or the passkey step after a
+// password, and sets the session cookie.
+func (m *Module) handlePasskeyLogin(w http.ResponseWriter, r *http.Request) {
+	lang := m.resolveLang(w, r)
+	if !m.auth.PasskeysEnabled() {
+		http.NotFound(w, r)
+		return
+	}
+	if !m.auth.AllowAuthAttempt(r, "passkey", "") {
+		passkeyJSON(w, http.StatusTooManyRequests, map[string]string{"error": T(lang, "form.err_rate_limit")})
+		return
+	}
+	req, ok := decodePasskeyRequest(w, r)
+	if !ok {
+		return
+	}
+	user, _, err := m.auth.FinishPasskeySignin(r.Context(), req.ChallengeID, req.Credential)
+	if err != nil {
+		if !errors.Is(err, auth.ErrInvalidMFACode) {
+			m.rt.Logger.Error("finish passkey sign-in", zap.Error(err))
+		}
+		passkeyJSON(w, http.StatusUnauthorized, map[string]string{"error": T(lang, "form.err_passkey")})
+		return
+	}
+	if err := m.auth.StartSession(w, r, user); err != nil {
+		m.rt.Logger.Error("start session", zap.String("user_id", user.ID.String()), zap.Error(err))
+		passkeyJSON(w, http.StatusInternalServerError, map[string]string{"error": T(lang, "form.err_session")})
+		return
+	}
+	m.rt.Logger.Info("studio login", zap.String("user_id", user.ID.String()), zap.String("method", "passkey"))
+	next := safeNext(req.Next)
+	if next == "" {
+		next = "/studio"
+	}
+	passkeyJSON(w, http.StatusOK, map[string]string{"redirect": next})
+}
+
+// handlePasskeyRegisterOptions opens the registration of a passkey. The
+// password is asked for first, as for deleting the account: a session cookie
+// lifted from a shared computer must not be enough to leave a passkey of its
+// own on the account, which would outlive every password change.
+func (m *Module) handlePasskeyRegisterOptions(w http.ResponseWriter, r *http.Request) {
+	lang := m.resolveLang(w, r)
+	userID, ok := m.authorID(r)
+	if !ok || !m.auth.PasskeysEnabled() {
+		http.NotFound(w, r)
+		return
+	}
+	req, ok := decodePasskeyRequest(w, r)
+	if !ok {
+		return
+	}
+	if !m.auth.AllowAuthAttempt(r, "signin", userID.String()) {
+		passkeyJSON(w, http.StatusTooManyRequests, map[string]string{"error": T(lang, "form.err_rate_limit")})
+		return
+	}
+	if !m.auth.CheckPassword(r.Context(), userID, req.Password) {
+		passkeyJSON(w, http.StatusForbidden, map[string]string{"error": T(lang, "prof.passkey_password_wrong")})
+		return
+	}
+	challenge, err := m.auth.BeginPasskeyRegistration(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, auth.ErrTooManyPasskeys) {
+			passkeyJSON(w, http.StatusConflict, map[string]string{"error": T(lang, "prof.passkey_too_many")})
+			return
+		}
+		m.rt.Logger.Error("begin passkey registration", zap.Error(err))
+		passkeyJSON(w, http.StatusInternalServerError, map[string]string{"error": T(lang, "form.err_passkey")})
+		return
+	}
+	passkeyJSON(w, http.StatusOK, challengeJSON(challenge))
+}
+
+// handlePasskeyRegister stores the passkey the browser created.
+func (m *Module) handlePasskeyRegister(w http.ResponseWriter, r *http.Request) {
+	lang := m.resolveLang(w, r)
+	userID, ok := m.authorID(r)
+	if !ok || !m.auth.PasskeysEnabled() {
+		http.NotFound(w, r)
+		return
+	}
+	req, ok := decodePasskeyRequest(w, r)
+	if !ok {
+		return
+	}
+	key, err := m.auth.FinishPasskeyRegistration(r.Context(), userID, req.ChallengeID, req.Name, req.Credential)
+	switch {
+	case err == nil:
+	case errors.Is(err, auth.ErrPasskeyExists):
+		passkeyJSON(w, http.StatusConflict, map[string]string{"error": T(lang, "prof.passkey_exists")})
+		return
+	case errors.Is(err, auth.ErrInvalidMFACode):
+		passkeyJSON(w, http.StatusBadRequest, map[string]string{"error": T(lang, "form.err_passkey")})
+		return
+	default:
+		m.rt.Logger.Error("finish passkey registration", zap.Error(err))
+		passkeyJSON(w, http.StatusInternalServerError, map[string]string{"error": T(lang, "form.err_passkey")})
+		return
+	}
+	m.rt.Logger.Info("passkey registered", zap.String("user_id", userID.String()), zap.String("passkey_id", key.ID.String()))
+	passkeyJSON(w, http.StatusOK, map[string]string{"redirect": "/studio/profile?ok=passkey_added"})
+}
+
+// handlePasskeyDelete removes a passkey. Every other session of the account
+// ends with it; this browser is handed a fresh cookie in its own session and
+// stays signed in.
+func (m *Module) handlePasskeyDelete(w http.ResponseWriter, r *http.Request) {
+	userID, ok := m.authorID(r)
+	if !ok || !m.auth.PasskeysEnabled() {
+		http.NotFound(w, r)
+		return
+	}
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Redirect(w, r, "/studio/profile", http.StatusSeeOther)
+		return
+	}
+	claims, _ := auth.ClaimsFromContext(r.Context())
+	token, err := m.auth.RemovePasskey(r.Context(), userID, claims.Session(), id)
+	if err != nil {
+		if !errors.Is(err, auth.ErrPasskeyNotFound) {
+			m.rt.Logger.Error("remove passkey", zap.Error(err))
+		}
+		http.Redirect(w, r, "/studio/profile", http.StatusSeeOther)
+		return
+	}
+	auth.SetSessionCookie(w, r, token, m.auth.SessionTTL())
+	m.rt.Logger.Info("passkey removed", zap.String("user_id", userID.String()), zap.String("passkey_id", id.String()))
+	http.Redirect(w, r, "/studio/profile?ok=passkey_removed", http.StatusSeeOther)
+}
+
+// passkeyStep renders the login form's second step: the password was right
+// and the account has a passkey, so the browser is asked for it before any
+// cookie is set.
+func (m *Module) passkeyStep(w http.ResponseWriter, r *http.Request, lang, email string, challenge auth.MFAChallenge) {
+	options, err := json.Marshal(challengeJSON(challenge))
+	if err != nil {
+		m.rt.Logger.Error("encode passkey challenge", zap.Error(err))
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	m.render(w, "form", FormPage{
+		Base:           m.base(r, T(lang, "form.login_title"), lang),
+		Mode:           "passkey",
+		Email:          email,
+		Next:           safeNext(r.FormValue("next")),
+		PasskeyOptions: string(options),
+	})
+}
+
+func challengeJSON(c auth.MFAChallenge) map[string]any {
+	return map[string]any{"challenge_id": c.ID, "options": c.Data["webauthn_options"]}
+}
+
+func decodePasskeyRequest(w http.ResponseWriter, r *http.Request) (passkeyRequest, bool) {
+	var req passkeyRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPasskeyBody)).Decode(&req); err != nil {
+		passkeyJSON(w, http.StatusBadRequest, map[string]string{"error": "bad request"})
+		return req, false
+	}
+	req.ChallengeID = strings.TrimSpace(req.ChallengeID)
+	return req, true
+}
+
+func passkeyJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"go.uber.org/zap"
+	"shanraq.org/pkg/events"
 )
 
 // handlePaymentWebhook receives a provider's settlement callback. It never
@@ -25,11 +26,17 @@ func (m *Module) handlePaymentWebhook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if res.Paid {
-		if _, err := m.pay.MarkPaid(r.Context(), res.PaymentID); err != nil {
+		settled, err := m.pay.MarkPaid(r.Context(), res.PaymentID)
+		if err != nil {
 			m.rt.Logger.Error("mark paid", zap.Error(err))
 			http.Error(w, "internal error", http.StatusInternalServerError)
 			return
 		}
+		// Only the call that settled it announces it: a provider retrying the
+		// callback must not pay anyone twice downstream.
+		if settled {
+			m.announce(r.Context(), events.PaymentPaid{PaymentID: res.PaymentID})
+		}
 	}
 	// Providers expect a 200 to stop retrying.
 	w.WriteHeader(http.StatusOK)
@@ -202,7 +202,7 @@ func (m *Module) paymentsAdminView() paymentsAdminView {
 // config; this only records the choice, taking effect at once.
 func (m *Module) handleAdminPayments(w http.ResponseWriter, r *http.Request) {
 	claims, _ := auth.ClaimsFromContext(r.Context())
-	if !canManageUsers(claims) {
+	if !m.can(r, auth.PermPaymentsConfigure) {
 		http.Error(w, "forbidden", http.StatusForbidden)
 		return
 	}
@@ -226,11 +226,13 @@ func (m *Module) handleAdminPayments(w http.ResponseWriter, r *http.Request) {
 			by = &id
 		}
 	}
+	before := m.paySettings.Get()
 	if err := m.paySettings.Save(r.Context(), in, by); err != nil {
 		m.rt.Logger.Warn("update payment settings", zap.Error(err))
 		http.Redirect(w, r, "/admin?ok=pay_bad", http.StatusSeeOther)
 		return
 	}
-	m.rt.Logger.Info("payment settings updated", zap.Bool("enabled", in.Enabled), zap.String("provider", in.Provider))
+	m.auth.Audit(r, auth.AuditRecord{Action: "payments.settings", TargetType: "settings", TargetID: "payments",
+		Before: before, After: m.paySettings.Get()})
 	http.Redirect(w, r, "/admin?ok=pay_set", http.StatusSeeOther)
 }
@@ -74,8 +74,7 @@ type predArticleOption struct {
 
 func (m *Module) handleAdminPredictions(w http.ResponseWriter, r *http.Request) {
 	lang := m.resolveLang(w, r)
-	claims, _ := auth.ClaimsFromContext(r.Context())
-	if !canManageUsers(claims) {
+	if !m.can(r, auth.PermPredictionsEdit) {
 		http.Error(w, "forbidden", http.StatusForbidden)
 		return
 	}
@@ -135,8 +134,7 @@ func parseDate(s string) *time.Time {
 
 func (m *Module) handleAdminPredictionSave(w http.ResponseWriter, r *http.Request) {
 	lang := m.resolveLang(w, r)
-	claims, _ := auth.ClaimsFromContext(r.Context())
-	if !canManageUsers(claims) {
+	if !m.can(r, auth.PermPredictionsEdit) {
 		http.Error(w, "forbidden", http.StatusForbidden)
 		return
 	}
@@ -170,7 +168,8 @@ func (m *Module) handleAdminPredictionSave(w http.ResponseWriter, r *http.Reques
 	if parsed, err := uuid.Parse(r.FormValue("id")); err == nil {
 		id = parsed
 	}
-	if _, err := m.predictions.Save(r.Context(), id, in); err != nil {
+	saved, err := m.predictions.Save(r.Context(), id, in)
+	if err != nil {
 		if errors.Is(err, ErrPredictionEmpty) {
 			http.Redirect(w, r, "/admin/predictions?err=empty&lang="+lang, http.StatusSeeOther)
 			return
@@ -179,13 +178,13 @@ func (m *Module) handleAdminPredictionSave(w http.ResponseWriter, r *http.Reques
 		http.Error(w, "save failed", http.StatusInternalServerError)
 		return
 	}
+	m.auth.Audit(r, auth.AuditRecord{Action: "prediction.save", TargetType: "prediction", TargetID: saved.String(), After: in})
 	http.Redirect(w, r, "/admin/predictions?saved=1&lang="+lang, http.StatusSeeOther)
 }
 
 func (m *Module) handleAdminPredictionDelete(w http.ResponseWriter, r *http.Request) {
 	lang := m.resolveLang(w, r)
-	claims, _ := auth.ClaimsFromContext(r.Context())
-	if !canManageUsers(claims) {
+	if !m.can(r, auth.PermPredictionsEdit) {
 		http.Error(w, "forbidden", http.StatusForbidden)
 		return
 	}
@@ -199,5 +198,6 @@ func (m *Module) handleAdminPredictionDelete(w http.ResponseWriter, r *http.Requ
 		http.Error(w, "delete failed", http.StatusInternalServerError)
 		return
 	}
+	m.auth.Audit(r, auth.AuditRecord{Action: "prediction.delete", TargetType: "prediction", TargetID: id.String()})
 	http.Redirect(w, r, "/admin/predictions?saved=deleted&lang="+lang, http.StatusSeeOther)
 }
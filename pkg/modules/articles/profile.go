@@ -8,6 +8,7 @@ import (
 
 	"github.com/google/uuid"
 	"go.uber.org/zap"
+	"shanraq.org/pkg/events"
 	"shanraq.org/pkg/modules/auth"
 	"shanraq.org/pkg/modules/media"
 )
@@ -32,6 +33,20 @@ type ProfilePage struct {
 	// the site did before places existed.
 	PlaceID    string
 	PlaceLabel string
+
+	// PasskeysOn shows the passkeys card; Passkeys are the account's.
+	PasskeysOn bool
+	Passkeys   []auth.Passkey
+
+	// Sessions are where the account is signed in, this browser marked.
+	Sessions []sessionRow
+
+	// Export is the account's latest "Download my data" still on record.
+	Export *DataExport
+
+	// NoPassword is an account registered by e-mail code that has not set a
+	// password yet; what asks for one points it to the reset flow instead.
+	NoPassword bool
 }
 
 // handleProfile renders the user's profile & settings page.
@@ -47,6 +62,7 @@ func (m *Module) handleProfile(w http.ResponseWriter, r *http.Request) {
 
 	page.FirstName, page.LastName, page.PhoneVerified = m.auth.AuthorIdentity(r.Context(), authorID)
 	page.EmailVerified = m.auth.IsEmailVerified(r.Context(), authorID)
+	page.NoPassword = !m.auth.HasPassword(r.Context(), authorID)
 	card := m.auth.AuthorCard(r.Context(), authorID)
 	page.BioKZ, page.BioRU, page.BioEN = card.BioKZ, card.BioRU, card.BioEN
 	if claims, ok := auth.ClaimsFromContext(r.Context()); ok {
@@ -61,6 +77,26 @@ func (m *Module) handleProfile(w http.ResponseWriter, r *http.Request) {
 			page.PlaceLabel = label
 		}
 	}
+	if m.auth.PasskeysEnabled() {
+		page.PasskeysOn = true
+		if keys, err := m.auth.Passkeys(r.Context(), authorID); err != nil {
+			m.rt.Logger.Warn("list passkeys", zap.Error(err))
+		} else {
+			page.Passkeys = keys
+		}
+	}
+	if sessions, err := m.users.ListSessions(r.Context(), authorID); err != nil {
+		m.rt.Logger.Warn("list sessions", zap.Error(err))
+	} else {
+		page.Sessions = sessionRows(sessions, currentSession(r))
+	}
+	if m.exports != nil {
+		if e, err := m.exports.Latest(r.Context(), authorID); err != nil {
+			m.rt.Logger.Warn("latest data export", zap.Error(err))
+		} else {
+			page.Export = e
+		}
+	}
 	m.render(w, "studio_profile", page)
 }
 
@@ -174,6 +210,7 @@ func (m *Module) handleDeleteAccount(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, "/studio/profile?ok=del_failed", http.StatusSeeOther)
 		return
 	}
+	m.announce(r.Context(), events.UserDeleted{UserID: authorID})
 	auth.ClearSessionCookie(w, r)
 	http.Redirect(w, r, "/?ok=account_deleted", http.StatusSeeOther)
 }
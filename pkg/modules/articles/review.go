@@ -9,6 +9,7 @@ import (
 
 	"github.com/google/uuid"
 	"go.uber.org/zap"
+	"shanraq.org/pkg/events"
 )
 
 // ReviewRules are the publication rules an article is checked against. The
@@ -467,12 +468,13 @@ func (m *Module) DecideArticle(ctx context.Context, id uuid.UUID, decision strin
 	if err := tx.Commit(ctx); err != nil {
 		return err
 	}
-	// Syndication is a side effect, not part of the decision's integrity, so it
-	// runs after the commit and its failure does not undo the ruling.
-	if status == "published" && m.syndicate != nil {
-		if err := m.syndicate.EnqueuePublish(ctx, m.jobs, id); err != nil {
-			m.rt.Logger.Warn("enqueue publish after decision", zap.Error(err))
-		}
+	// Announced after the commit: what subscribers do is a side effect, not
+	// part of the decision's integrity, and cannot undo the ruling.
+	switch {
+	case status == "published":
+		m.announce(ctx, events.ArticlePublished{ArticleID: id, AuthorID: author})
+	case was == "published":
+		m.announce(ctx, events.ArticleUnpublished{ArticleID: id, Reason: events.UnpublishedByModerator})
 	}
 	return nil
 }
@@ -0,0 +1,226 @@
+package articles
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"shanraq.org/pkg/modules/auth"
+)
+
+// Sessions, as their owner and the administrators see them: where the account
+// is signed in, and a button to end any one of them. The sessions themselves
+// live in the auth module; what is here is the description of a client —
+// which is ours, because the User-Agent families and the GeoIP reader already
+// are — and the pages.
+
+// DescribeClient is the auth module's ClientDescriber: device class, browser
+// and OS family from the User-Agent, and the country of the address. The same
+// coarse buckets the analytics counts, for the same reason — a person needs
+// to tell "Chrome on Android, KZ" from "Safari on macOS, DE", and nothing
+// finer is worth keeping. Whether the address is a datacenter's rides along
+// for the sign-in alerts; without the ASN database it is always false.
+func (m *Module) DescribeClient(r *http.Request) auth.SessionClient {
+	ua, ip := r.UserAgent(), clientIP(r)
+	return auth.SessionClient{
+		Device:     deviceClass(ua),
+		Browser:    browserFamily(ua),
+		OS:         osFamily(ua),
+		Country:    m.geoip.country(ip),
+		Datacenter: m.geoip.isDatacenter(ip),
+	}
+}
+
+// sessionRow is a session ready for the table.
+type sessionRow struct {
+	auth.Session
+	Label   string // "Chrome · Android"; empty when neither is known
+	Device  string // i18n key of the device class
+	Current bool   // the session this page is being read in
+}
+
+// familyNames spells the browser and OS families the way people write them.
+var familyNames = map[string]string{
+	"chrome": "Chrome", "firefox": "Firefox", "safari": "Safari", "edge": "Edge",
+	"opera": "Opera", "samsung": "Samsung Internet", "yandex": "Yandex Browser",
+	"android": "Android", "ios": "iOS", "windows": "Windows", "macos": "macOS",
+	"chromeos": "ChromeOS", "linux": "Linux",
+}
+
+func sessionRows(sessions []auth.Session, current uuid.UUID) []sessionRow {
+	rows := make([]sessionRow, 0, len(sessions))
+	for _, s := range sessions {
+		var parts []string
+		for _, f := range []string{s.Client.Browser, s.Client.OS} {
+			if name, ok := familyNames[f]; ok {
+				parts = append(parts, name)
+			}
+		}
+		device := s.Client.Device
+		switch device {
+		case "mobile", "tablet", "desktop":
+		default:
+			device = "other"
+		}
+		rows = append(rows, sessionRow{
+			Session: s,
+			Label:   strings.Join(parts, " · "),
+			Device:  "sess.dev_" + device,
+			Current: current != uuid.Nil && s.ID == current,
+		})
+	}
+	return rows
+}
+
+// currentSession is the session the request's token belongs to.
+func currentSession(r *http.Request) uuid.UUID {
+	claims, _ := auth.ClaimsFromContext(r.Context())
+	return claims.Session()
+}
+
+// handleSessionRevoke ends one of the caller's sessions. Ending the one they
+// are using is signing out, and is treated as such.
+func (m *Module) handleSessionRevoke(w http.ResponseWriter, r *http.Request) {
+	userID, ok := m.authorID(r)
+	if !ok {
+		http.Redirect(w, r, "/studio/login", http.StatusSeeOther)
+		return
+	}
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Redirect(w, r, "/studio/profile#sessions", http.StatusSeeOther)
+		return
+	}
+	if err := m.users.RevokeSession(r.Context(), userID, id); err != nil {
+		if !errors.Is(err, auth.ErrSessionNotFound) {
+			m.rt.Logger.Error("revoke session", zap.Error(err))
+		}
+		http.Redirect(w, r, "/studio/profile#sessions", http.StatusSeeOther)
+		return
+	}
+	m.rt.Logger.Info("session revoked", zap.String("user_id", userID.String()), zap.String("session_id", id.String()))
+	if id == currentSession(r) {
+		auth.ClearSessionCookie(w, r)
+		http.Redirect(w, r, "/studio/login", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/studio/profile?ok=session_revoked#sessions", http.StatusSeeOther)
+}
+
+// handleSessionsRevokeOthers ends every session of the caller but this one.
+func (m *Module) handleSessionsRevokeOthers(w http.ResponseWriter, r *http.Request) {
+	userID, ok := m.authorID(r)
+	if !ok {
+		http.Redirect(w, r, "/studio/login", http.StatusSeeOther)
+		return
+	}
+	n, err := m.users.RevokeOtherSessions(r.Context(), userID, currentSession(r))
+	if err != nil {
+		m.rt.Logger.Error("revoke other sessions", zap.Error(err))
+		http.Redirect(w, r, "/studio/profile#sessions", http.StatusSeeOther)
+		return
+	}
+	m.rt.Logger.Info("other sessions revoked", zap.String("user_id", userID.String()), zap.Int("sessions", n))
+	http.Redirect(w, r, "/studio/profile?ok=sessions_revoked#sessions", http.StatusSeeOther)
+}
+
+// AdminUserPage is /admin/users/{id}: one account and where it is signed in.
+type AdminUserPage struct {
+	Base
+	User     auth.AdminUser
+	Sessions []sessionRow
+	Notice   string
+}
+
+// handleAdminUserPage shows one account's sessions, with the means to end
+// them — the first thing to reach for when an account looks taken over.
+func (m *Module) handleAdminUserPage(w http.ResponseWriter, r *http.Request) {
+	if _, ok := m.adminActor(r); !ok {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	target, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	user, err := m.users.GetAdminUser(r.Context(), target)
+	if err != nil {
+		if errors.Is(err, auth.ErrUserNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		m.rt.Logger.Error("admin get user", zap.Error(err))
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	lang := m.resolveLang(w, r)
+	page := AdminUserPage{Base: m.base(r, user.Email, lang), User: user}
+	switch r.URL.Query().Get("ok") {
+	case "session_revoked":
+		page.Notice = T(lang, "prof.session_revoked")
+	case "sessions_revoked":
+		page.Notice = T(lang, "admin.u_sessions_revoked")
+	case "export_queued":
+		page.Notice = T(lang, "admin.u_export_queued")
+	case "export_failed":
+		page.Notice = T(lang, "prof.export_failed")
+	}
+	sessions, err := m.users.ListSessions(r.Context(), target)
+	if err != nil {
+		m.rt.Logger.Warn("list sessions", zap.Error(err))
+	}
+	// The administrator's own session is marked only on their own page.
+	page.Sessions = sessionRows(sessions, currentSession(r))
+	m.render(w, "admin_user", page)
+}
+
+// handleAdminSessionRevoke ends one session of the account.
+func (m *Module) handleAdminSessionRevoke(w http.ResponseWriter, r *http.Request) {
+	target, _, ok := m.adminUserAction(w, r)
+	if !ok {
+		return
+	}
+	back := "/admin/users/" + target.String()
+	id, err := uuid.Parse(chi.URLParam(r, "sid"))
+	if err != nil {
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+	if err := m.users.RevokeSession(r.Context(), target, id); err != nil {
+		if !errors.Is(err, auth.ErrSessionNotFound) {
+			m.rt.Logger.Error("admin revoke session", zap.Error(err))
+		}
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+	m.auth.Audit(r, auth.AuditRecord{Action: "user.session_revoke", TargetType: "user", TargetID: target.String(),
+		After: map[string]any{"session_id": id.String()}})
+	http.Redirect(w, r, back+"?ok=session_revoked", http.StatusSeeOther)
+}
+
+// handleAdminSessionsRevoke signs the account out everywhere: every session
+// is revoked and auth_version bumped, so tokens from before sessions existed
+// go too.
+func (m *Module) handleAdminSessionsRevoke(w http.ResponseWriter, r *http.Request) {
+	target, _, ok := m.adminUserAction(w, r)
+	if !ok {
+		return
+	}
+	back := "/admin/users/" + target.String()
+	if _, err := m.users.RevokeOtherSessions(r.Context(), target, uuid.Nil); err != nil {
+		m.rt.Logger.Error("admin revoke sessions", zap.Error(err))
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+	if err := m.users.BumpAuthVersion(r.Context(), target); err != nil {
+		m.rt.Logger.Error("admin bump auth version", zap.Error(err))
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+	m.auth.Audit(r, auth.AuditRecord{Action: "user.sessions_revoke", TargetType: "user", TargetID: target.String()})
+	http.Redirect(w, r, back+"?ok=sessions_revoked", http.StatusSeeOther)
+}
@@ -0,0 +1,39 @@
+package articles
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"shanraq.org/pkg/modules/auth"
+)
+
+// A session is described in families and nothing finer; without a GeoIP
+// database the country is simply unknown.
+func TestDescribeClient(t *testing.T) {
+	r := httptest.NewRequest("GET", "/studio/login", nil)
+	r.Header.Set("User-Agent", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1")
+	got := (&Module{}).DescribeClient(r)
+	want := auth.SessionClient{Device: "mobile", Browser: "safari", OS: "ios"}
+	if got != want {
+		t.Fatalf("DescribeClient = %+v, want %+v", got, want)
+	}
+}
+
+func TestSessionRows(t *testing.T) {
+	current := uuid.New()
+	rows := sessionRows([]auth.Session{
+		{ID: current, Client: auth.SessionClient{Device: "desktop", Browser: "chrome", OS: "macos"}},
+		{ID: uuid.New(), Client: auth.SessionClient{Device: "", Browser: "other", OS: "windows"}},
+	}, current)
+	if rows[0].Label != "Chrome · macOS" || rows[0].Device != "sess.dev_desktop" || !rows[0].Current {
+		t.Errorf("row 0: %+v", rows[0])
+	}
+	if rows[1].Label != "Windows" || rows[1].Device != "sess.dev_other" || rows[1].Current {
+		t.Errorf("row 1: %+v", rows[1])
+	}
+	// A token from before sessions has no session; nothing is "this device".
+	if sessionRows([]auth.Session{{ID: uuid.Nil}}, uuid.Nil)[0].Current {
+		t.Error("a sessionless request marked a row as current")
+	}
+}
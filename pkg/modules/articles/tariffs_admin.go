@@ -75,8 +75,7 @@ func (m *Module) tariffsAdminView(lang string) tariffsAdminView {
 
 func (m *Module) handleAdminTariffs(w http.ResponseWriter, r *http.Request) {
 	lang := m.resolveLang(w, r)
-	claims, _ := auth.ClaimsFromContext(r.Context())
-	if !canManageUsers(claims) {
+	if !m.can(r, auth.PermTariffsEdit) {
 		http.Error(w, "forbidden", http.StatusForbidden)
 		return
 	}
@@ -93,7 +92,7 @@ func (m *Module) handleAdminTariffs(w http.ResponseWriter, r *http.Request) {
 
 func (m *Module) handleAdminTariffsSave(w http.ResponseWriter, r *http.Request) {
 	claims, _ := auth.ClaimsFromContext(r.Context())
-	if !canManageUsers(claims) {
+	if !m.can(r, auth.PermTariffsEdit) {
 		http.Error(w, "forbidden", http.StatusForbidden)
 		return
 	}
@@ -126,11 +125,16 @@ func (m *Module) handleAdminTariffsSave(w http.ResponseWriter, r *http.Request)
 			by = &id
 		}
 	}
+	before := make(map[string]int64, len(values))
+	for key := range values {
+		before[key] = tariffVal(key)
+	}
 	if err := m.tariffs.SaveMany(r.Context(), values, by); err != nil {
 		m.rt.Logger.Warn("save tariffs", zap.Error(err))
 		http.Redirect(w, r, "/admin/tariffs?err=1", http.StatusSeeOther)
 		return
 	}
-	m.rt.Logger.Info("tariffs updated", zap.Int("fields", len(values)))
+	m.auth.Audit(r, auth.AuditRecord{Action: "tariffs.save", TargetType: "settings", TargetID: "tariffs",
+		Before: before, After: values})
 	http.Redirect(w, r, "/admin/tariffs?ok=1", http.StatusSeeOther)
 }
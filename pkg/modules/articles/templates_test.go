@@ -8,7 +8,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/google/uuid"
 	"shanraq.org/pkg/modules/ai"
+	"shanraq.org/pkg/modules/auth"
 	"shanraq.org/web"
 )
 
@@ -47,7 +49,24 @@ func TestTemplatesExecute(t *testing.T) {
 			{"page", StaticPage{Base: base, Body: RenderMarkdown("# Hi\n\nText [guide](/guide)")}},
 			{"form", FormPage{Base: base, Mode: "login", Email: "a@b.c", Error: "err"}},
 			{"form", FormPage{Base: base, Mode: "register"}},
+			{"form", FormPage{Base: base, Mode: "login", Passkeys: true, Next: "/studio/new"}},
+			{"form", FormPage{Base: base, Mode: "passkey", PasskeyOptions: `{"challenge_id":"x","options":{}}`}},
+			{"studio_profile", ProfilePage{Base: base, PasskeysOn: true, Passkeys: []auth.Passkey{
+				{Name: "Phone", Synced: true, CreatedAt: time.Now(), LastUsedAt: &time.Time{}}, {Name: "Key", CreatedAt: time.Now()}}}},
+			{"studio_profile", ProfilePage{Base: base, Sessions: sessionRows([]auth.Session{
+				{ID: uuid.New(), Kind: auth.SessionBrowser, Client: auth.SessionClient{Device: "mobile", Browser: "chrome", OS: "android", Country: "KZ"}, StartedAt: now, LastUsedAt: now},
+				{ID: uuid.New(), Kind: auth.SessionAPI, StartedAt: now, LastUsedAt: now}}, uuid.Nil)}},
+			{"studio_profile", ProfilePage{Base: base, Export: &DataExport{Status: "pending", CreatedAt: now, ExpiresAt: now}}},
+			{"studio_profile", ProfilePage{Base: base, Export: &DataExport{Status: "ready", CreatedAt: now, ExpiresAt: now}}},
+			{"admin_user", AdminUserPage{Base: base, Notice: "N", User: auth.AdminUser{ID: uuid.New(), Email: "a@b.c", Last: "Баймурза", Role: "user", Country: "KZ", CreatedAt: now},
+				Sessions: sessionRows([]auth.Session{{ID: uuid.New(), Kind: auth.SessionBrowser, StartedAt: now, LastUsedAt: now}}, uuid.Nil)}},
+			{"admin_user", AdminUserPage{Base: base, User: auth.AdminUser{ID: uuid.New(), Email: "a@b.c"}}}, // no sessions
 			{"form", FormPage{Base: base, Mode: "register", Email: "a@b.c", Last: "Баймурза", First: "Даулет", Middle: "Абаевич", Ref: "abc23", Error: "err"}},
+			{"email_signin", EmailSignInPage{Base: base, Mode: "start", Next: "/listings/new", Error: "err"}},
+			{"email_signin", EmailSignInPage{Base: base, Mode: "code", Email: "a@b.c", Ref: "abc23", Notice: "N"}},
+			{"email_signin", EmailSignInPage{Base: base, Mode: "link", Email: "a@b.c", Token: "t"}},
+			{"email_signin", EmailSignInPage{Base: base, Mode: "finish", Email: "a@b.c", Code: "123456", Last: "Баймурза", Error: "err"}},
+			{"studio_profile", ProfilePage{Base: base, PasskeysOn: true, NoPassword: true}},
 			{"studio_dashboard", StudioPage{Base: base, Karma: 42, Stats: AuthorStats{
 				TotalArticles: 2, Published: 1, Drafts: 1, TotalViews: 10,
 				ViewsByLang: map[string]int64{LangRU: 7, LangKZ: 3, LangEN: 0},
@@ -87,13 +106,28 @@ func TestTemplatesExecute(t *testing.T) {
 				ID: "id", DealType: "sale", PropertyType: "apartment", Title: "Квартира", Price: 18000000, AgentID: "u1", AgentName: "Асан Серіков",
 				Images: []string{"/static/demo/rooms/living.svg"}}}}},
 			{"agent_public", AgentPublicPage{Base: base, Agent: &Agent{UserID: "u1", Name: "Асан", Status: agentVerified}}}, // no listings
-			{"admin", AdminPage{Base: base, Email: "a@b.c", Role: "admin", CanManageUsers: true, CanModerate: true, CanFinance: true,
+			{"admin", AdminPage{Base: base, Email: "a@b.c", Role: "admin", Can: allPerms(), CanManageUsers: true, CanModerate: true, CanFinance: true,
 				AssignRoles: assignableRoles, ServiceStates: []string{svcOn, svcMaintenance, svcOff},
 				Services:      []ServiceFlag{{Code: SvcAdOrders, TitleKey: "svc.ad_orders", Status: svcMaintenance}},
 				Site:          ServiceFlag{Code: SvcSite, TitleKey: "svc.site", Status: svcOn},
 				PendingAgents: []Agent{{UserID: "u1", Name: "Асан Серіков", Agency: "Дом", Phone: "+7 700", Email: "a@b.c", Status: agentPending}},
 				Payments:      paymentsAdminView{Enabled: true, Provider: PayProviderKaspi, ActiveReady: false, Providers: []paymentProviderStatus{{Code: PayProviderKaspi, Label: "Kaspi Pay", Implemented: false, IsActive: true}, {Code: PayProviderIoka, Label: "ioka", Implemented: false}}},
 				Stats:         AdminStats{Users: 3, Articles: 2}}},
+			{"admin_throttles", adminThrottlesPage{Base: base, Notice: "N", Items: []auth.Throttle{
+				{Action: "signin", Key: "203.0.113.9", KeyHash: "0011223344556677", Strikes: 6, LastRefused: now, LockedUntil: now.Add(time.Hour), BlockedUntil: now.Add(time.Hour)},
+				{Action: "password_reset_confirm", Key: "#8899aabbccddeeff", KeyHash: "8899aabbccddeeff", Strikes: 1, LastRefused: now, BlockedUntil: now.Add(time.Minute)}}}},
+			{"admin_throttles", adminThrottlesPage{Base: base}}, // nobody throttled
+			{"admin_access", adminAccessPage{Base: base, Notice: "N", Error: "E", Permissions: grantablePermissions(), Roles: []auth.RoleDef{
+				{Name: "admin", Fixed: true, Users: 1},
+				{Name: "director", Description: "D", Fixed: true, Permissions: []string{auth.PermAdminView, auth.PermRolesManage}},
+				{Name: "editor", Description: "E", Permissions: []string{auth.PermAdminView, auth.PermCommentsHide}, Users: 3}}}},
+			{"admin_access", adminAccessPage{Base: base, Permissions: grantablePermissions()}}, // no roles read
+			{"admin_audit", adminAuditPage{Base: base, Filter: auditFilter{Action: "user."}, ExportURL: "/admin/audit/export?action=user.",
+				Check: &auth.AuditCheck{Entries: 2, Head: "ab12"}, Entries: []auth.AuditEntry{
+					{ID: 2, At: now, ActorEmail: "a@b.c", Action: "user.role", TargetType: "user", TargetID: "u1",
+						Before: []byte(`{"role":"user"}`), After: []byte(`{"role":"editor"}`), IP: "203.0.113.9", RequestID: "host/abc-000001"},
+					{ID: 1, At: now, ActorEmail: "adminctl:root", Action: "user.create"}}}},
+			{"admin_audit", adminAuditPage{Base: base, Error: "E", Check: &auth.AuditCheck{Entries: 3, BrokenAt: 2}}}, // nothing found, chain broken
 			{"admin_pages", adminPagesList{Base: base, Items: []adminPageItem{{Key: "privacy", Name: "Конфиденциальность"}, {Key: "terms", Name: "Условия"}}}},
 			{"admin_page_edit", adminPageEditView{Base: base, Key: "privacy", Name: "Конфиденциальность", Notice: "N", LastEdited: "2026-07-28 10:00", LastEditor: "a@b.c", Langs: []adminPageLangView{
 				{Code: "kz", Label: "Қазақша", Title: "T", Body: "# Hi"},
@@ -181,7 +215,7 @@ func TestAdminGrowingListsScroll(t *testing.T) {
 		ml = append(ml, ModAction{Created: now, Action: "hide", TargetType: "comment", ReasonCode: "spam", ActorKind: "human"})
 	}
 	page := AdminPage{Base: Base{Lang: LangRU, Title: "T"}, Email: "a@b.c", Role: "admin",
-		CanManageUsers: true, CanModerate: true, CanFinance: true,
+		Can: allPerms(), CanManageUsers: true, CanModerate: true, CanFinance: true,
 		AssignRoles: assignableRoles, ServiceStates: []string{svcOn},
 		Stats:  AdminStats{Users: 3, Articles: 2, RecentComments: cs},
 		ModLog: ml,
@@ -476,6 +510,7 @@ func TestAdminStatusBadgesAreThemeable(t *testing.T) {
 	var sb strings.Builder
 	page := AdminPage{
 		Base:           Base{Lang: "ru", Title: "T"},
+		Can:            allPerms(),
 		CanManageUsers: true,
 		Services:       []ServiceFlag{{Code: "listing_promo", Status: svcInviteOnly}},
 		Site:           ServiceFlag{Code: "site", Status: svcOn},
@@ -570,7 +605,7 @@ func renderAdminFull(t *testing.T) string {
 	tmpl := buildTemplates(t)
 	rows := []GuestSimpleRow{{Title: "Прямые", N: 10, Pct: 100}}
 	page := AdminPage{Base: Base{Lang: LangRU, Title: "T"}, Email: "a@b.c", Role: "admin",
-		CanManageUsers: true, CanModerate: true, CanFinance: true,
+		Can: allPerms(), CanManageUsers: true, CanModerate: true, CanFinance: true,
 		AssignRoles: assignableRoles, ServiceStates: []string{svcOn},
 		Site:  ServiceFlag{Code: SvcSite, TitleKey: "svc.site", Status: svcOn},
 		Stats: AdminStats{Users: 1},
@@ -960,3 +995,12 @@ func TestEveryPhotoBoxIsSixteenNine(t *testing.T) {
 		}
 	}
 }
+
+// allPerms is an admin's view of the panel: every permission held.
+func allPerms() map[string]bool {
+	out := make(map[string]bool, len(auth.AllPermissions))
+	for _, p := range auth.AllPermissions {
+		out[p] = true
+	}
+	return out
+}
@@ -0,0 +1,62 @@
+package articles
+
+import (
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+	"shanraq.org/pkg/modules/auth"
+)
+
+// Throttled keys: who the auth rate limiter is turning away right now, and a
+// button to let one of them through. The usual caller is a reader who mistyped
+// their password into a lockout, or an office whose shared address a script
+// got throttled — both have to wait out a lockout that keeps doubling unless
+// someone lifts it.
+
+// adminThrottlesPage is /admin/throttles.
+type adminThrottlesPage struct {
+	Base
+	Items  []auth.Throttle
+	Notice string
+}
+
+func (m *Module) handleAdminThrottles(w http.ResponseWriter, r *http.Request) {
+	if _, ok := m.adminActor(r); !ok {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	lang := m.resolveLang(w, r)
+	page := adminThrottlesPage{Base: m.base(r, T(lang, "thr.title"), lang)}
+	if r.URL.Query().Get("ok") == "unblocked" {
+		page.Notice = T(lang, "thr.unblocked")
+	}
+	items, err := m.auth.Throttled(r.Context())
+	if err != nil {
+		m.rt.Logger.Error("list throttled keys", zap.Error(err))
+	}
+	page.Items = items
+	m.render(w, "admin_throttles", page)
+}
+
+// handleAdminUnthrottle lifts the limit and any lockout of one key.
+func (m *Module) handleAdminUnthrottle(w http.ResponseWriter, r *http.Request) {
+	if _, ok := m.adminActor(r); !ok {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	_ = r.ParseForm()
+	action := strings.TrimSpace(r.FormValue("action"))
+	keyHash := strings.TrimSpace(r.FormValue("key"))
+	if action == "" || keyHash == "" {
+		http.Redirect(w, r, "/admin/throttles", http.StatusSeeOther)
+		return
+	}
+	if err := m.auth.Unthrottle(r.Context(), action, keyHash); err != nil {
+		m.rt.Logger.Error("unthrottle", zap.Error(err))
+		http.Redirect(w, r, "/admin/throttles", http.StatusSeeOther)
+		return
+	}
+	m.auth.Audit(r, auth.AuditRecord{Action: "throttle.unblock", TargetType: "throttle", TargetID: action + ":" + keyHash})
+	http.Redirect(w, r, "/admin/throttles?ok=unblocked", http.StatusSeeOther)
+}
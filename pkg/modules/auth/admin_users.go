@@ -57,6 +57,12 @@ func isRootRole(role string) bool {
 	return false
 }
 
+// IsRootRole reports whether role is one of the roles that administer other
+// accounts. Their permissions cannot be edited, and only a holder of one may
+// hand one out or act on an account that holds one: users.manage alone would
+// otherwise be enough to promote oneself past it.
+func IsRootRole(role string) bool { return isRootRole(role) }
+
 // ListUsers returns accounts matching a search over name and e-mail, newest
 // first. An empty query returns everyone; limit is capped so a growing site
 // cannot turn the panel into a slow page.
@@ -0,0 +1,342 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// The audit log: one append-only record of every privileged action — who,
+// what, on which target, what it was before and after, from where.
+//
+// Privileged actions used to leave at most an updated_by column behind, on the
+// row they changed, overwritten by the next change. "Who changed the promote
+// price last Tuesday" had no answer once somebody changed it on Wednesday, and
+// a role change, an account deletion or a job cancel left nothing at all.
+//
+// Each entry carries the hash of the one before it, and its own hash covers
+// that and every field, so a row deleted or edited in the middle breaks the
+// chain from there on; VerifyAudit walks it. The table also refuses UPDATE and
+// DELETE outright. Neither stops someone with the database owner's password
+// from rewriting the whole chain, or from cutting its tail — but both leave a
+// head hash different from the one written into an earlier export, which is
+// what the export is for.
+//
+// Writing is best-effort after the action itself succeeded: a failed write is
+// logged as an error and the action stands. Holding an admin's change hostage
+// to the audit table would turn an audit outage into an admin outage.
+
+// AuditRecord is what a privileged action reports about itself. Before and
+// After are marshalled to JSON; when both are objects, only the keys that
+// differ are kept.
+type AuditRecord struct {
+	Action     string // dotted, e.g. "user.role", "tariffs.save"
+	TargetType string
+	TargetID   string
+	Before     any
+	After      any
+}
+
+// AuditEntry is one row of the audit log.
+type AuditEntry struct {
+	ID         int64           `json:"id"`
+	At         time.Time       `json:"at"`
+	ActorID    *uuid.UUID      `json:"actor_id,omitempty"`
+	ActorEmail string          `json:"actor_email"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type,omitempty"`
+	TargetID   string          `json:"target_id,omitempty"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	IP         string          `json:"ip,omitempty"`
+	RequestID  string          `json:"request_id,omitempty"`
+	PrevHash   string          `json:"prev_hash"`
+	Hash       string          `json:"hash"`
+}
+
+// AuditQuery filters the audit log. Empty fields match everything.
+type AuditQuery struct {
+	Actor  string // e-mail substring or exact actor id
+	Action string // prefix: "user." matches every account action
+	Target string // exact target id
+	From   time.Time
+	To     time.Time
+	// Limit caps the entries returned: auditListLimit when zero, and never
+	// more than AuditExportLimit.
+	Limit int
+}
+
+// AuditCheck is the outcome of walking the chain.
+type AuditCheck struct {
+	Entries int    `json:"entries"`
+	Head    string `json:"head"` // hash of the newest entry
+	// BrokenAt is the id of the first entry that does not follow from the one
+	// before it; zero when the chain is intact.
+	BrokenAt int64 `json:"broken_at,omitempty"`
+}
+
+// auditLock serialises appends: each entry names its predecessor, so two
+// written at once would both claim the same one.
+const auditLock = 5938204417
+
+// auditListLimit is one page of the log.
+const auditListLimit = 500
+
+// AuditExportLimit caps one export. A legal request names a person or a
+// period; one that matches more than this wants narrower filters, not a file
+// nobody can open.
+const AuditExportLimit = 100000
+
+// Audit records a privileged action taken by the signed-in caller of r.
+func (m *Module) Audit(r *http.Request, rec AuditRecord) {
+	if m == nil || m.store == nil || m.store.db == nil {
+		return
+	}
+	e := AuditEntry{
+		Action:     rec.Action,
+		TargetType: rec.TargetType,
+		TargetID:   rec.TargetID,
+		IP:         clientIdentifier(r),
+		RequestID:  middleware.GetReqID(r.Context()),
+	}
+	if claims, ok := ClaimsFromContext(r.Context()); ok {
+		if id, err := uuid.Parse(claims.Subject); err == nil {
+			e.ActorID = &id
+		}
+		e.ActorEmail = claims.Email
+	}
+	var err error
+	if e.Before, e.After, err = auditDiff(rec.Before, rec.After); err == nil {
+		_, err = m.store.AppendAudit(r.Context(), e)
+	}
+	if err != nil && m.rt != nil {
+		m.rt.Logger.Error("write audit entry", zap.String("action", rec.Action),
+			zap.String("target", rec.TargetID), zap.Error(err))
+	}
+}
+
+// AuditLog returns the entries matching q, newest first.
+func (m *Module) AuditLog(ctx context.Context, q AuditQuery) ([]AuditEntry, error) {
+	return m.store.ListAudit(ctx, q)
+}
+
+// VerifyAudit walks the whole chain.
+func (m *Module) VerifyAudit(ctx context.Context) (AuditCheck, error) {
+	return m.store.VerifyAudit(ctx)
+}
+
+// auditDiff marshals before and after, keeping only the differing keys when
+// both are JSON objects.
+func auditDiff(before, after any) (json.RawMessage, json.RawMessage, error) {
+	b, err := marshalAudit(before)
+	if err != nil {
+		return nil, nil, err
+	}
+	a, err := marshalAudit(after)
+	if err != nil {
+		return nil, nil, err
+	}
+	var bm, am map[string]any
+	if json.Unmarshal(b, &bm) != nil || json.Unmarshal(a, &am) != nil || bm == nil || am == nil {
+		return b, a, nil
+	}
+	for k, v := range bm {
+		if w, ok := am[k]; ok && reflect.DeepEqual(v, w) {
+			delete(bm, k)
+			delete(am, k)
+		}
+	}
+	if b, err = json.Marshal(bm); err != nil {
+		return nil, nil, err
+	}
+	if a, err = json.Marshal(am); err != nil {
+		return nil, nil, err
+	}
+	return b, a, nil
+}
+
+func marshalAudit(v any) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	if raw, ok := v.(json.RawMessage); ok {
+		return raw, nil
+	}
+	return json.Marshal(v)
+}
+
+// digest is the entry's hash: every field but the id, behind the previous
+// entry's hash. Each field is length-prefixed so no two entries can be made to
+// read the same by moving text from one field into the next.
+func (e AuditEntry) digest() string {
+	actor := ""
+	if e.ActorID != nil {
+		actor = e.ActorID.String()
+	}
+	h := sha256.New()
+	for _, f := range []string{
+		e.PrevHash, e.At.UTC().Format(time.RFC3339Nano), actor, e.ActorEmail,
+		e.Action, e.TargetType, e.TargetID, string(e.Before), string(e.After),
+		e.IP, e.RequestID,
+	} {
+		h.Write([]byte(strconv.Itoa(len(f)) + ":" + f + ";"))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// nullJSON stores an empty document as NULL. The columns are json rather than
+// jsonb because jsonb rewrites the text, and the hash is over the text.
+func nullJSON(b json.RawMessage) any {
+	if len(b) == 0 {
+		return nil
+	}
+	return string(b)
+}
+
+// AppendAudit adds e to the end of the chain, stamping its time and hashes.
+func (s *Store) AppendAudit(ctx context.Context, e AuditEntry) (AuditEntry, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return e, fmt.Errorf("begin audit tx: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, int64(auditLock)); err != nil {
+		return e, fmt.Errorf("lock audit log: %w", err)
+	}
+	err = tx.QueryRow(ctx, `SELECT hash FROM auth_audit_log ORDER BY id DESC LIMIT 1`).Scan(&e.PrevHash)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return e, fmt.Errorf("read audit head: %w", err)
+	}
+	// Postgres keeps microseconds; hashing more would not survive the round trip.
+	e.At = time.Now().UTC().Truncate(time.Microsecond)
+	e.Hash = e.digest()
+	if err := tx.QueryRow(ctx, `
+		INSERT INTO auth_audit_log
+			(at, actor_id, actor_email, action, target_type, target_id, before, after, ip, request_id, prev_hash, hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7::json, $8::json, $9, $10, $11, $12)
+		RETURNING id
+	`, e.At, e.ActorID, e.ActorEmail, e.Action, e.TargetType, e.TargetID,
+		nullJSON(e.Before), nullJSON(e.After), e.IP, e.RequestID, e.PrevHash, e.Hash).Scan(&e.ID); err != nil {
+		return e, fmt.Errorf("append audit entry: %w", err)
+	}
+	return e, tx.Commit(ctx)
+}
+
+const auditColumns = `id, at, actor_id, actor_email, action, target_type, target_id,
+	before::text, after::text, ip, request_id, prev_hash, hash`
+
+func scanAudit(row pgx.Row) (AuditEntry, error) {
+	var e AuditEntry
+	var before, after *string
+	if err := row.Scan(&e.ID, &e.At, &e.ActorID, &e.ActorEmail, &e.Action, &e.TargetType, &e.TargetID,
+		&before, &after, &e.IP, &e.RequestID, &e.PrevHash, &e.Hash); err != nil {
+		return e, err
+	}
+	if before != nil {
+		e.Before = json.RawMessage(*before)
+	}
+	if after != nil {
+		e.After = json.RawMessage(*after)
+	}
+	e.At = e.At.UTC()
+	return e, nil
+}
+
+// ListAudit returns the entries matching q, newest first.
+func (s *Store) ListAudit(ctx context.Context, q AuditQuery) ([]AuditEntry, error) {
+	var where []string
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	if actor := strings.TrimSpace(q.Actor); actor != "" {
+		if id, err := uuid.Parse(actor); err == nil {
+			where = append(where, "actor_id = "+arg(id))
+		} else {
+			where = append(where, "actor_email ILIKE "+arg("%"+escapeLike(actor)+"%"))
+		}
+	}
+	if action := strings.TrimSpace(q.Action); action != "" {
+		where = append(where, "action LIKE "+arg(escapeLike(action)+"%"))
+	}
+	if target := strings.TrimSpace(q.Target); target != "" {
+		where = append(where, "target_id = "+arg(target))
+	}
+	if !q.From.IsZero() {
+		where = append(where, "at >= "+arg(q.From))
+	}
+	if !q.To.IsZero() {
+		where = append(where, "at < "+arg(q.To))
+	}
+	limit := q.Limit
+	switch {
+	case limit <= 0:
+		limit = auditListLimit
+	case limit > AuditExportLimit:
+		limit = AuditExportLimit
+	}
+	sql := `SELECT ` + auditColumns + ` FROM auth_audit_log`
+	if len(where) > 0 {
+		sql += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	sql += ` ORDER BY id DESC LIMIT ` + arg(limit)
+
+	rows, err := s.db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list audit log: %w", err)
+	}
+	defer rows.Close()
+
+	var out []AuditEntry
+	for rows.Next() {
+		e, err := scanAudit(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan audit entry: %w", err)
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// escapeLike makes % and _ in a search term match themselves.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// VerifyAudit walks the chain from the first entry, recomputing every hash.
+func (s *Store) VerifyAudit(ctx context.Context) (AuditCheck, error) {
+	rows, err := s.db.Query(ctx, `SELECT `+auditColumns+` FROM auth_audit_log ORDER BY id`)
+	if err != nil {
+		return AuditCheck{}, fmt.Errorf("read audit log: %w", err)
+	}
+	defer rows.Close()
+
+	var check AuditCheck
+	for rows.Next() {
+		e, err := scanAudit(rows)
+		if err != nil {
+			return check, fmt.Errorf("scan audit entry: %w", err)
+		}
+		if check.BrokenAt == 0 && (e.PrevHash != check.Head || e.digest() != e.Hash) {
+			check.BrokenAt = e.ID
+		}
+		check.Entries++
+		check.Head = e.Hash
+	}
+	return check, rows.Err()
+}
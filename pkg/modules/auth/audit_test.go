@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestAuditDiffKeepsWhatChanged(t *testing.T) {
+	before, after, err := auditDiff(
+		map[string]any{"first": "Асан", "last": "Серіков", "verified": false},
+		map[string]any{"first": "Асан", "last": "Серікова", "verified": true},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(before) != `{"last":"Серіков","verified":false}` || string(after) != `{"last":"Серікова","verified":true}` {
+		t.Errorf("diff = %s → %s", before, after)
+	}
+
+	// Only two objects are diffed; anything else is kept whole.
+	before, after, err = auditDiff(nil, []string{"audit.view"})
+	if err != nil || before != nil || string(after) != `["audit.view"]` {
+		t.Errorf("diff(nil, list) = %s → %s, %v", before, after, err)
+	}
+}
+
+// Every field is under the hash: change any one and the entry no longer
+// matches it, and moving text between fields does not help.
+func TestAuditDigestCoversEveryField(t *testing.T) {
+	actor := uuid.New()
+	e := AuditEntry{
+		At: time.Date(2026, 3, 1, 9, 0, 0, 123456000, time.UTC), ActorID: &actor, ActorEmail: "a@b.c",
+		Action: "user.role", TargetType: "user", TargetID: "u1",
+		Before: json.RawMessage(`{"role":"user"}`), After: json.RawMessage(`{"role":"editor"}`),
+		IP: "203.0.113.9", RequestID: "r1", PrevHash: "00",
+	}
+	want := e.digest()
+	tamper := []func(*AuditEntry){
+		func(e *AuditEntry) { e.At = e.At.Add(time.Microsecond) },
+		func(e *AuditEntry) { other := uuid.New(); e.ActorID = &other },
+		func(e *AuditEntry) { e.ActorID = nil },
+		func(e *AuditEntry) { e.ActorEmail = "x@b.c" },
+		func(e *AuditEntry) { e.Action = "user.delete" },
+		func(e *AuditEntry) { e.TargetType = "role" },
+		func(e *AuditEntry) { e.TargetID = "u2" },
+		func(e *AuditEntry) { e.Before = json.RawMessage(`{"role":"admin"}`) },
+		func(e *AuditEntry) { e.After = nil },
+		func(e *AuditEntry) { e.IP = "" },
+		func(e *AuditEntry) { e.RequestID = "r2" },
+		func(e *AuditEntry) { e.PrevHash = "01" },
+		func(e *AuditEntry) { e.TargetType, e.TargetID = "useru1", "" },
+	}
+	for i, f := range tamper {
+		c := e
+		f(&c)
+		if c.digest() == want {
+			t.Errorf("change %d leaves the hash as it was", i)
+		}
+	}
+	if e.ID = 99; e.digest() != want {
+		t.Error("the id is the database's, not part of the hash")
+	}
+}
+
+// Against the real table: entries chain, the chain verifies, a search finds
+// them, and the table refuses to let one go.
+func TestAuditLogAppendOnly(t *testing.T) {
+	pool := revocationPool(t)
+	ctx := context.Background()
+	m := &Module{store: NewStore(pool)}
+	u := seedUser(t, pool, "director")
+	target := "t-" + uuid.NewString()[:8]
+
+	claims := &Claims{Email: u.Email, PrimaryRole: "director"}
+	claims.Subject = u.ID.String()
+	r := httptest.NewRequest("POST", "/admin/users", nil).WithContext(ContextWithClaims(ctx, claims))
+	m.Audit(r, AuditRecord{Action: "user.role", TargetType: "user", TargetID: target,
+		Before: map[string]string{"role": "user", "email": u.Email}, After: map[string]string{"role": "editor", "email": u.Email}})
+	m.Audit(r, AuditRecord{Action: "user.delete", TargetType: "user", TargetID: target})
+
+	got, err := m.AuditLog(ctx, AuditQuery{Target: target})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Action != "user.delete" || got[1].Action != "user.role" {
+		t.Fatalf("search by target = %+v", got)
+	}
+	if got[0].PrevHash != got[1].Hash {
+		t.Error("the newer entry does not name the older one")
+	}
+	if string(got[1].Before) != `{"role":"user"}` || got[1].ActorID == nil || *got[1].ActorID != u.ID {
+		t.Errorf("stored entry = %+v", got[1])
+	}
+	if byActor, err := m.AuditLog(ctx, AuditQuery{Actor: u.Email[:8], Action: "user.ro"}); err != nil || len(byActor) == 0 {
+		t.Errorf("search by actor and action prefix = %d entries, %v", len(byActor), err)
+	}
+
+	check, err := m.VerifyAudit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if check.BrokenAt != 0 || check.Head != got[0].Hash {
+		t.Errorf("check = %+v, want an intact chain ending at %s", check, got[0].Hash)
+	}
+
+	if _, err := pool.Exec(ctx, `DELETE FROM auth_audit_log WHERE id=$1`, got[0].ID); err == nil {
+		t.Error("an entry was deleted")
+	}
+	if _, err := pool.Exec(ctx, `UPDATE auth_audit_log SET action='x' WHERE id=$1`, got[0].ID); err == nil {
+		t.Error("an entry was edited")
+	}
+}
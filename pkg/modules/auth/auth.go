@@ -92,9 +92,15 @@ type Module struct {
 	sms         SMSSender
 	rateLimiter RateLimiter
 	mfaProvider MFAProvider
+	passkeys    *WebAuthnProvider
+	webauthnCfg *webauthnOptions
 	requireTOTP bool
 	totpIssuer  string
 	signupGate  func() error
+	// describeClient labels new sessions; see WithClientDescriber.
+	describeClient ClientDescriber
+	// perms caches the role definitions; see Can.
+	perms permissionCache
 }
 
 // WithSignupGate lets the host application decide whether the JSON signup
@@ -107,12 +113,22 @@ type Module struct {
 // registration closed in the admin panel, the site stayed shut to visitors and
 // open to anyone who could spell JSON.
 //
-// A nil gate keeps the old behaviour, so the module stays usable standalone; the
-// application wires the real check in main.go.
+// Without one, Init takes the gate of the registered module that is a
+// SignupGate — in this application the articles module, which owns the
+// service flags. With neither, signup is open, so the module stays usable
+// standalone.
 func WithSignupGate(gate func() error) Option {
 	return func(m *Module) { m.signupGate = gate }
 }
 
+// SignupGate is implemented by the module that decides whether accounts may
+// be created at all. RegistrationGate returns the reason they may not, or
+// nil.
+type SignupGate interface {
+	shanraq.Module
+	RegistrationGate() error
+}
+
 //go:embed templates/*.html
 var viewFiles embed.FS
 
@@ -155,7 +171,12 @@ func (m *Module) Init(ctx context.Context, rt *shanraq.Runtime) error {
 	m.views = tmpl
 	m.validator = validate.New()
 	if m.rateLimiter == nil {
-		m.rateLimiter = newMemoryRateLimiter(defaultRateLimitRules())
+		rules, lockout := rateLimitsFrom(rt.Config.Auth.RateLimit)
+		if rt.Config.Auth.RateLimit.Store == "memory" || rt.DB == nil {
+			m.rateLimiter = newMemoryRateLimiterWithLockout(rules, lockout)
+		} else {
+			m.rateLimiter = newPostgresRateLimiter(rt.DB, rules, lockout, rt.Logger)
+		}
 	}
 	if m.mfaProvider == nil && m.requireTOTP {
 		issuer := m.totpIssuer
@@ -164,6 +185,17 @@ func (m *Module) Init(ctx context.Context, rt *shanraq.Runtime) error {
 		}
 		m.mfaProvider = NewTOTPProvider(m.store, issuer, m.rt.Logger)
 	}
+	if err := m.initWebAuthn(); err != nil {
+		return err
+	}
+	for _, mod := range rt.Modules() {
+		if gate, ok := mod.(SignupGate); ok && m.signupGate == nil {
+			m.signupGate = gate.RegistrationGate
+		}
+		if d, ok := mod.(SessionDescriber); ok && m.describeClient == nil {
+			m.describeClient = d.DescribeClient
+		}
+	}
 	m.ensureBootstrapAdmin(ctx)
 	return nil
 }
@@ -227,7 +259,10 @@ func (m *Module) Routes(r chi.Router) {
 		r.Get("/password/confirm", m.renderPasswordConfirmPage)
 		r.Post("/password/confirm", m.handlePasswordResetConfirm)
 		r.Post("/mfa/verify", m.handleMFAVerify)
+		r.Post("/mfa/recovery-codes", m.handleRecoveryCodes)
 		r.Get("/verify", m.handleEmailVerify)
+		r.Get("/signin/disown", m.renderDisownPage)
+		r.Post("/signin/disown", m.handleDisownSignIn)
 	})
 }
 
@@ -338,11 +373,14 @@ func (m *Module) handleSignup(w http.ResponseWriter, r *http.Request) {
 		m.rt.Logger.Warn("issue email verification (api)", zap.String("user_id", user.ID.String()), zap.Error(err))
 	}
 
-	accessToken, refreshToken, err := m.issueTokenPair(ctx, user.ID, user)
+	client := m.clientOf(r)
+	accessToken, refreshToken, err := m.issueTokenPair(ctx, user.ID, user, RefreshToken{Client: client})
 	if err != nil {
 		respond.Error(w, http.StatusInternalServerError, err)
 		return
 	}
+	// The first row of the history, that later sign-ins are compared with.
+	m.noteSignIn(r.Context(), user, SessionAPI, client)
 
 	m.rt.Logger.Info("user signed up", zap.String("user_id", user.ID.String()))
 	m.writeTokenResponse(w, http.StatusCreated, user, accessToken, refreshToken)
@@ -390,14 +428,15 @@ func (m *Module) handleSignin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	accessToken, refreshToken, err := m.issueTokenPair(ctx, user.ID, user)
-	if err != nil {
-		respond.Error(w, http.StatusInternalServerError, err)
-		return
-	}
-
+	// The second factor is asked for before any token exists: tokens issued
+	// ahead of the challenge were a session nobody could use, listed among
+	// the account's own.
 	if m.mfaProvider != nil {
 		challenge, challengeErr := m.mfaProvider.Challenge(ctx, user)
+		if errors.Is(challengeErr, ErrMFANotEnrolled) {
+			m.signinTokens(w, r, user)
+			return
+		}
 		if challengeErr != nil {
 			if m.rt != nil {
 				m.rt.Logger.Error("mfa challenge", zap.Error(challengeErr))
@@ -426,6 +465,25 @@ func (m *Module) handleSignin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	m.signinTokens(w, r, user)
+}
+
+// signinTokens answers a password sign-in that needs no second factor. A
+// staff account signing in from a new country does need one, and the API has
+// none to offer it: it is refused, and signing in on the website once — where
+// a code is mailed — makes the country known.
+func (m *Module) signinTokens(w http.ResponseWriter, r *http.Request, user User) {
+	if m.NeedsStepUp(r, user) {
+		respond.Error(w, http.StatusForbidden, errors.New("sign-in from a new country: sign in on the website once to confirm it"))
+		return
+	}
+	client := m.clientOf(r)
+	accessToken, refreshToken, err := m.issueTokenPair(r.Context(), user.ID, user, RefreshToken{Client: client})
+	if err != nil {
+		respond.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	m.noteSignIn(r.Context(), user, SessionAPI, client)
 	m.writeTokenResponse(w, http.StatusOK, user, accessToken, refreshToken)
 }
 
@@ -471,13 +529,15 @@ func (m *Module) handleRefresh(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := m.store.RevokeRefreshToken(ctx, stored.ID); err != nil {
+	// The new token joins the old one's session before the old one is
+	// revoked: the other way round, the session would have no live row for a
+	// moment and its access tokens would be refused in between.
+	accessToken, refreshToken, err := m.issueTokenPair(ctx, stored.UserID, user, stored)
+	if err != nil {
 		respond.Error(w, http.StatusInternalServerError, err)
 		return
 	}
-
-	accessToken, refreshToken, err := m.issueTokenPair(ctx, stored.UserID, user)
-	if err != nil {
+	if err := m.store.RevokeRefreshToken(ctx, stored.ID); err != nil {
 		respond.Error(w, http.StatusInternalServerError, err)
 		return
 	}
@@ -508,7 +568,7 @@ func (m *Module) handleSignout(w http.ResponseWriter, r *http.Request) {
 
 	stored, err := m.store.GetRefreshToken(ctx, hashToken(token))
 	if err == nil {
-		_ = m.store.RevokeRefreshToken(ctx, stored.ID)
+		_ = m.store.RevokeSession(ctx, stored.UserID, stored.SessionID)
 	}
 
 	respond.JSON(w, http.StatusOK, map[string]string{"status": "signed_out"})
@@ -742,7 +802,10 @@ func (m *Module) authenticateRequest(r *http.Request) (*Claims, error) {
 	return claims, nil
 }
 
-func (m *Module) issueTokenPair(ctx context.Context, userID uuid.UUID, user User) (string, string, error) {
+// issueTokenPair signs an access token and a refresh token for user. The
+// refresh token is a new row of session: a zero SessionID starts a session
+// described by session.Client, and a stored row continues its own.
+func (m *Module) issueTokenPair(ctx context.Context, userID uuid.UUID, user User, session RefreshToken) (string, string, error) {
 	if len(user.Roles) == 0 {
 		role := strings.TrimSpace(strings.ToLower(user.Role))
 		if role == "" {
@@ -751,15 +814,24 @@ func (m *Module) issueTokenPair(ctx context.Context, userID uuid.UUID, user User
 		user.Role = role
 		user.Roles = []string{role}
 	}
-	accessToken, err := m.tokens.Generate(user)
+	refreshToken, err := generateSecureToken(refreshTokenSize)
 	if err != nil {
 		return "", "", err
 	}
-	refreshToken, err := generateSecureToken(refreshTokenSize)
+	row, err := m.store.InsertSessionToken(ctx, RefreshToken{
+		UserID:    userID,
+		TokenHash: hashToken(refreshToken),
+		ExpiresAt: time.Now().Add(refreshTokenTTL),
+		SessionID: session.SessionID,
+		Kind:      SessionAPI,
+		Client:    session.Client,
+		StartedAt: session.StartedAt,
+	})
 	if err != nil {
 		return "", "", err
 	}
-	if _, err := m.store.InsertRefreshToken(ctx, userID, hashToken(refreshToken), time.Now().Add(refreshTokenTTL)); err != nil {
+	accessToken, err := m.tokens.GenerateSession(user, row.SessionID)
+	if err != nil {
 		return "", "", err
 	}
 	if err := m.store.DeleteExpiredRefreshTokens(ctx, userID); err != nil {
@@ -772,7 +844,11 @@ func (m *Module) issueTokenPair(ctx context.Context, userID uuid.UUID, user User
 }
 
 func (m *Module) writeTokenResponse(w http.ResponseWriter, status int, user User, accessToken, refreshToken string) {
-	respond.JSON(w, status, map[string]any{
+	respond.JSON(w, status, m.tokenResponse(user, accessToken, refreshToken))
+}
+
+func (m *Module) tokenResponse(user User, accessToken, refreshToken string) map[string]any {
+	return map[string]any{
 		"access_token":  accessToken,
 		"refresh_token": refreshToken,
 		"token_type":    "Bearer",
@@ -784,7 +860,7 @@ func (m *Module) writeTokenResponse(w http.ResponseWriter, status int, user User
 			"role":                    user.Role,
 			"password_reset_required": user.PasswordResetRequired,
 		},
-	})
+	}
 }
 
 func generateSecureToken(size int) (string, error) {
@@ -870,20 +946,97 @@ func (m *Module) handleMFAVerify(w http.ResponseWriter, r *http.Request) {
 	}
 
 	user := result.User
-	accessToken, refreshToken, err := m.issueTokenPair(ctx, user.ID, user)
+	client := m.clientOf(r)
+	accessToken, refreshToken, err := m.issueTokenPair(ctx, user.ID, user, RefreshToken{Client: client})
 	if err != nil {
 		respond.Error(w, http.StatusInternalServerError, err)
 		return
 	}
+	m.noteSignIn(r.Context(), user, SessionAPI, client)
 	if m.rt != nil {
 		m.rt.Logger.Info("user passed MFA", zap.String("user_id", user.ID.String()))
 	}
-	m.writeTokenResponse(w, http.StatusOK, user, accessToken, refreshToken)
+	resp := m.tokenResponse(user, accessToken, refreshToken)
+	// The codes issued with a freshly confirmed app are in this response and
+	// nowhere else; the client must show them now.
+	if len(result.RecoveryCodes) > 0 {
+		resp["recovery_codes"] = result.RecoveryCodes
+	}
+	if result.RecoveryCodeUsed {
+		resp["recovery_codes_left"] = result.RecoveryCodesLeft
+	}
+	respond.JSON(w, http.StatusOK, resp)
+}
+
+// handleRecoveryCodes replaces the caller's recovery codes with a new set.
+// The password is asked for again: a stolen access token must not be enough
+// to mint a way past the second factor. With Accept: text/plain the codes come
+// back as a file to download — there is no second chance to fetch them.
+func (m *Module) handleRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	if _, ok := m.mfaProvider.(*TOTPProvider); !ok {
+		respond.Error(w, http.StatusNotFound, errors.New("recovery codes need TOTP"))
+		return
+	}
+	claims, err := m.authenticateRequest(r)
+	if err != nil {
+		respond.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+	var req struct {
+		Password string `json:"password"`
+	}
+	if err := respond.Decode(r, &req); err != nil {
+		respond.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	if !m.enforceRateLimit(r, "signin", true, claims.UserID) {
+		respond.Error(w, http.StatusTooManyRequests, errTooManyRequests)
+		return
+	}
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		respond.Error(w, http.StatusUnauthorized, errors.New("invalid credentials"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if !m.tokenStillValid(ctx, claims) {
+		respond.Error(w, http.StatusUnauthorized, ErrTokenRevoked)
+		return
+	}
+	if !m.CheckPassword(ctx, userID, req.Password) {
+		respond.Error(w, http.StatusForbidden, errors.New("invalid credentials"))
+		return
+	}
+	codes, err := m.RegenerateRecoveryCodes(ctx, userID)
+	if err != nil {
+		if errors.Is(err, ErrRecoveryCodesUnavailable) {
+			respond.Error(w, http.StatusConflict, err)
+			return
+		}
+		respond.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	if m.rt != nil {
+		m.rt.Logger.Info("recovery codes regenerated", zap.String("user_id", claims.UserID))
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	if strings.Contains(r.Header.Get("Accept"), "text/plain") {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="shanraq-recovery-codes.txt"`)
+		_, _ = fmt.Fprintf(w, "Shanraq.org recovery codes\n\nEach code signs you in once in place of the authenticator app.\n\n%s\n",
+			strings.Join(codes, "\n"))
+		return
+	}
+	respond.JSON(w, http.StatusOK, map[string]any{"recovery_codes": codes})
 }
 
 // AllowAuthAttempt applies the shared auth rate limiter to a browser (cookie)
 // flow so the studio login/register forms get the same protection as the API.
-// action is "signin" or "signup"; email scopes the limit per account. Returns
+// action is "signin", "signup" or "passkey"; email scopes the limit per account
+// (empty for a passkey, which names no account until it is verified). Returns
 // false when the caller should respond 429.
 func (m *Module) AllowAuthAttempt(r *http.Request, action, email string) bool {
 	return m.enforceRateLimit(r, action, true, strings.TrimSpace(strings.ToLower(email)))
@@ -1034,6 +1187,7 @@ func (m *Module) RequireRoles(roles ...string) func(http.Handler) http.Handler {
 					respond.Error(w, http.StatusUnauthorized, ErrTokenRevoked)
 					return
 				}
+				r = r.WithContext(contextVerified(r.Context()))
 			}
 			nextCtx := ContextWithClaims(r.Context(), claims)
 			next.ServeHTTP(w, r.WithContext(nextCtx))
@@ -1085,4 +1239,5 @@ var _ interface {
 	shanraq.Module
 	shanraq.RouterModule
 	shanraq.InitializerModule
+	shanraq.Reconfigurable
 } = (*Module)(nil)
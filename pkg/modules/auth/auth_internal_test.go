@@ -1,12 +1,18 @@
 package auth
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"shanraq.org/internal/config"
+	"shanraq.org/pkg/shanraq"
 )
 
 func TestNormalizeRoleSet(t *testing.T) {
@@ -116,3 +122,53 @@ func TestRequireRolesMiddlewareMissingToken(t *testing.T) {
 		t.Fatalf("expected 401 for missing token, got %d", rec.Code)
 	}
 }
+
+// gateModule stands in for the module that owns the registration switch and
+// the analytics.
+type gateModule struct{ err error }
+
+func (gateModule) Name() string              { return "gate" }
+func (g gateModule) RegistrationGate() error { return g.err }
+func (gateModule) DescribeClient(*http.Request) SessionClient {
+	return SessionClient{Device: "desktop", Country: "KZ"}
+}
+
+// Auth finds the signup gate and the session describer on the runtime: the
+// application registers the module that owns them and wires nothing by hand.
+func TestInitFindsSignupGate(t *testing.T) {
+	closed := errors.New("registration is closed")
+	mod := New()
+	app := shanraq.New(config.Config{})
+	app.Register(mod)
+	app.Register(gateModule{err: closed})
+	rt := &shanraq.Runtime{
+		Config: config.Config{Auth: config.AuthConfig{TokenSecret: "test-token-secret-that-is-long-enough-1234567890"}},
+		Logger: zap.NewNop(),
+		Router: chi.NewRouter(),
+	}
+	stop, err := app.Boot(context.Background(), rt)
+	if err != nil {
+		t.Fatalf("boot: %v", err)
+	}
+	defer stop()
+	if mod.signupGate == nil || !errors.Is(mod.signupGate(), closed) {
+		t.Fatal("auth did not take the registered module's signup gate")
+	}
+	if got := mod.clientOf(httptest.NewRequest(http.MethodGet, "/", nil)); got.Country != "KZ" {
+		t.Fatalf("client = %+v, want the registered module's description", got)
+	}
+
+	// A gate passed as an option wins over the one on the runtime.
+	own := New(WithSignupGate(func() error { return nil }))
+	app = shanraq.New(config.Config{})
+	app.Register(own)
+	app.Register(gateModule{err: closed})
+	stop, err = app.Boot(context.Background(), &shanraq.Runtime{Config: rt.Config, Logger: zap.NewNop(), Router: chi.NewRouter()})
+	if err != nil {
+		t.Fatalf("boot: %v", err)
+	}
+	defer stop()
+	if own.signupGate() != nil {
+		t.Fatal("the runtime's gate replaced the one passed as an option")
+	}
+}
@@ -0,0 +1,395 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Passwordless e-mail sign-in: one mail carries a link and a six-digit code,
+// and either proves the reader holds the inbox. Most readers only want to
+// comment or keep favourites, and the password step was where they gave up
+// signing up; an address they can already read is enough for that.
+//
+// It is built on the e-mail verification tokens rather than beside them — a
+// row with purpose 'signin' — and the mail goes out through deliverOrDevLink
+// like every other. The row names an address, not an account: an address
+// with no account yet is proven the same way, and the host application then
+// registers it (RegisterEmail) with whatever else registration asks for.
+//
+// A code is short enough to type off a phone, which also makes it short
+// enough to guess, so each row takes emailCodeAttempts wrong codes before it
+// is spent, a new mail replaces the previous row, and the host rate-limits
+// both the mails and the attempts per address. Only hashes are stored, the
+// code's keyed with the token secret: six digits hashed plainly could be
+// walked back from a copy of the table in a second.
+
+// ErrEmailSignInInvalid is a sign-in link or code that is unknown, expired,
+// already used, or spent by wrong guesses.
+var ErrEmailSignInInvalid = errors.New("email sign-in link or code invalid or expired")
+
+const (
+	// emailSignInTTL is how long a mailed link and code work. Long enough to
+	// switch to the mail app and back; short enough that a mail read later by
+	// someone else is no key.
+	emailSignInTTL = 15 * time.Minute
+	// emailCodeAttempts wrong codes spend the row.
+	emailCodeAttempts = 5
+	emailCodeDigits   = 6
+)
+
+// EmailSignIn is an address whose holder answered a sign-in mail. It is the
+// proof itself, not a session: UseEmailSignIn or RegisterEmail spend it.
+type EmailSignIn struct {
+	Email string
+	// User is the account with this address; nil when there is none yet and
+	// the proof can only register one.
+	User *User
+
+	id uuid.UUID
+}
+
+// IssueEmailSignIn mails a sign-in link and code to email. confirmURL is the
+// page the link opens; the link's token is added to its query. The answer is
+// the same whether or not the address has an account — only the mail says
+// which — so the form cannot be used to find out who is registered.
+func (m *Module) IssueEmailSignIn(ctx context.Context, email, confirmURL string) error {
+	email, ok := NormalizeEmail(email)
+	if !ok {
+		return ErrInvalidEmail
+	}
+	known := true
+	if _, err := m.store.FindByEmail(ctx, email); errors.Is(err, ErrNotFound) {
+		known = false
+	} else if err != nil {
+		return err
+	}
+	return m.issueEmailSignIn(ctx, email, confirmURL, func(code, link string) (string, string) {
+		return emailSignInMessage(code, link, known)
+	})
+}
+
+// issueEmailSignIn stores and mails a link and code for email, the mail's
+// words coming from message.
+func (m *Module) issueEmailSignIn(ctx context.Context, email, confirmURL string, message func(code, link string) (string, string)) error {
+	token, err := generateSecureToken(refreshTokenSize)
+	if err != nil {
+		return err
+	}
+	code, err := newEmailCode()
+	if err != nil {
+		return err
+	}
+	link, err := url.Parse(confirmURL)
+	if err != nil {
+		return fmt.Errorf("email sign-in url: %w", err)
+	}
+	q := link.Query()
+	q.Set("token", token)
+	link.RawQuery = q.Encode()
+
+	if err := m.store.CreateEmailSignIn(ctx, email, hashToken(token), m.emailCodeHash(email, code),
+		time.Now().Add(emailSignInTTL)); err != nil {
+		return err
+	}
+	subject, body := message(code, link.String())
+	return m.deliverOrDevLink(ctx, email, subject, body, link.String(), "email sign-in")
+}
+
+// CheckEmailSignIn resolves the token of a mailed link. The link is not spent
+// until the proof is used, so a mail scanner that opens every link does not
+// burn it — the page it opens must ask for a click.
+func (m *Module) CheckEmailSignIn(ctx context.Context, token string) (EmailSignIn, error) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return EmailSignIn{}, ErrEmailSignInInvalid
+	}
+	id, email, err := m.store.emailSignInByToken(ctx, hashToken(token))
+	if err != nil {
+		return EmailSignIn{}, err
+	}
+	return m.emailSignIn(ctx, id, email)
+}
+
+// CheckEmailCode resolves a code typed for email against the newest mail sent
+// to it. A wrong code counts against that mail.
+func (m *Module) CheckEmailCode(ctx context.Context, email, code string) (EmailSignIn, error) {
+	email, ok := NormalizeEmail(email)
+	code, valid := normalizeEmailCode(code)
+	if !ok || !valid {
+		return EmailSignIn{}, ErrEmailSignInInvalid
+	}
+	id, err := m.store.emailSignInByCode(ctx, email, m.emailCodeHash(email, code))
+	if err != nil {
+		return EmailSignIn{}, err
+	}
+	return m.emailSignIn(ctx, id, email)
+}
+
+func (m *Module) emailSignIn(ctx context.Context, id uuid.UUID, email string) (EmailSignIn, error) {
+	s := EmailSignIn{Email: email, id: id}
+	u, err := m.store.FindByEmail(ctx, email)
+	switch {
+	case err == nil:
+		s.User = &u
+	case !errors.Is(err, ErrNotFound):
+		return EmailSignIn{}, err
+	}
+	return s, nil
+}
+
+// UseEmailSignIn spends the proof for the account it names and marks the
+// address verified. The caller still owes the account its second factor
+// before starting a session, exactly as after a password.
+func (m *Module) UseEmailSignIn(ctx context.Context, s EmailSignIn) (User, error) {
+	if s.User == nil {
+		return User{}, ErrNotFound
+	}
+	if err := m.store.useEmailSignIn(ctx, s.id, s.Email); err != nil {
+		return User{}, err
+	}
+	return *s.User, nil
+}
+
+// RegisterEmail spends the proof to create an account for an address that
+// has none, with the person's real name and no password; the address is
+// verified by the proof itself. The names are validated here as in
+// RegisterPassword, so the rule holds whichever form asked.
+func (m *Module) RegisterEmail(ctx context.Context, s EmailSignIn, first, last, middle string) (User, error) {
+	if s.User != nil {
+		return User{}, ErrEmailExists
+	}
+	first, last, middle = NormalizePersonName(first), NormalizePersonName(last), NormalizePersonName(middle)
+	if err := ValidatePersonName(first); err != nil {
+		return User{}, err
+	}
+	if err := ValidatePersonName(last); err != nil {
+		return User{}, err
+	}
+	if err := ValidateOptionalPersonName(middle); err != nil {
+		return User{}, err
+	}
+	return m.store.registerEmailSignIn(ctx, s.id, s.Email, first, last, middle)
+}
+
+// HasPassword reports whether the account can sign in with a password. One
+// registered by e-mail code has none until it sets one through the reset
+// flow, and cannot confirm anything by password before that.
+func (m *Module) HasPassword(ctx context.Context, userID uuid.UUID) bool {
+	if m.store == nil {
+		return false
+	}
+	u, err := m.store.GetByID(ctx, userID.String())
+	return err == nil && u.PasswordHash != ""
+}
+
+func (m *Module) emailCodeHash(email, code string) string {
+	return emailCodeHash(m.rt.Config.Auth.TokenSecret, email, code)
+}
+
+// emailCodeHash keys the hash with the token secret and binds it to the
+// address, so the same code mailed to two people hashes differently.
+func emailCodeHash(secret, email, code string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(email + "\x00" + code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// newEmailCode is six uniformly random digits.
+func newEmailCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return "", fmt.Errorf("email code entropy: %w", err)
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// normalizeEmailCode drops the spaces and dashes people type into a code; ok
+// is false unless six digits remain.
+func normalizeEmailCode(code string) (string, bool) {
+	var b strings.Builder
+	for _, r := range code {
+		switch {
+		case r == ' ' || r == '-':
+			continue
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		default:
+			return "", false
+		}
+	}
+	if b.Len() != emailCodeDigits {
+		return "", false
+	}
+	return b.String(), true
+}
+
+func emailSignInMessage(code, link string, known bool) (string, string) {
+	if !known {
+		return "Your Shanraq.org registration code", fmt.Sprintf(
+			"Your code to create a Shanraq.org account with this address: %s\n\nOr open the link below:\n\n%s\n\n"+
+				"The code and the link work for 15 minutes. If you did not ask for them, you can ignore this message.",
+			code, link)
+	}
+	return "Your Shanraq.org sign-in code", fmt.Sprintf(
+		"Your code to sign in to Shanraq.org: %s\n\nOr open the link below:\n\n%s\n\n"+
+			"The code and the link work for 15 minutes. If you did not ask for them, someone typed your address "+
+			"into the sign-in form; without this mail they cannot get in, and you can ignore it.",
+		code, link)
+}
+
+// CreateEmailSignIn stores a sign-in mail for email, replacing any earlier one:
+// only the newest code works, and asking again does not add guesses.
+func (s *Store) CreateEmailSignIn(ctx context.Context, email, tokenHash, codeHash string, expiresAt time.Time) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin email sign-in tx: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck
+
+	if _, err := tx.Exec(ctx,
+		`DELETE FROM email_verification_tokens WHERE purpose = 'signin' AND email = $1`, email); err != nil {
+		return fmt.Errorf("replace email sign-in: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO email_verification_tokens (purpose, email, token_hash, code_hash, expires_at)
+		VALUES ('signin', $1, $2, $3, $4)`, email, tokenHash, codeHash, expiresAt); err != nil {
+		return fmt.Errorf("insert email sign-in: %w", err)
+	}
+	return tx.Commit(ctx)
+}
+
+func (s *Store) emailSignInByToken(ctx context.Context, tokenHash string) (uuid.UUID, string, error) {
+	var (
+		id    uuid.UUID
+		email string
+	)
+	err := s.db.QueryRow(ctx, `
+		SELECT id, email FROM email_verification_tokens
+		WHERE token_hash = $1 AND purpose = 'signin' AND used_at IS NULL AND expires_at > NOW()
+	`, tokenHash).Scan(&id, &email)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return uuid.Nil, "", ErrEmailSignInInvalid
+	}
+	if err != nil {
+		return uuid.Nil, "", fmt.Errorf("select email sign-in: %w", err)
+	}
+	return id, email, nil
+}
+
+// emailSignInByCode matches codeHash against the newest live mail to email,
+// counting a miss and spending the row on the last one allowed. The row is
+// locked from read to count: guesses sent in parallel would otherwise all read
+// it live before any miss was counted, and get far more than
+// emailCodeAttempts between them.
+func (s *Store) emailSignInByCode(ctx context.Context, email, codeHash string) (uuid.UUID, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("begin email code tx: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck
+
+	var (
+		id     uuid.UUID
+		stored string
+	)
+	err = tx.QueryRow(ctx, `
+		SELECT id, code_hash FROM email_verification_tokens
+		WHERE purpose = 'signin' AND email = $1 AND used_at IS NULL AND expires_at > NOW()
+		  AND attempts < $2
+		ORDER BY created_at DESC LIMIT 1
+		FOR UPDATE
+	`, email, emailCodeAttempts).Scan(&id, &stored)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return uuid.Nil, ErrEmailSignInInvalid
+	}
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("select email sign-in: %w", err)
+	}
+	if subtle.ConstantTimeCompare([]byte(stored), []byte(codeHash)) == 1 {
+		return id, tx.Commit(ctx)
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE email_verification_tokens
+		SET attempts = attempts + 1,
+		    used_at = CASE WHEN attempts + 1 >= $2 THEN NOW() ELSE used_at END
+		WHERE id = $1`, id, emailCodeAttempts); err != nil {
+		return uuid.Nil, fmt.Errorf("count email code attempt: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return uuid.Nil, fmt.Errorf("commit email code attempt: %w", err)
+	}
+	return uuid.Nil, ErrEmailSignInInvalid
+}
+
+// useEmailSignIn spends the row once — a second use, even a concurrent one,
+// finds it gone — and marks the address verified if an account has it.
+func (s *Store) useEmailSignIn(ctx context.Context, id uuid.UUID, email string) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin email sign-in tx: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE email_verification_tokens SET used_at = NOW()
+		WHERE id = $1 AND purpose = 'signin' AND used_at IS NULL AND expires_at > NOW()`, id)
+	if err != nil {
+		return fmt.Errorf("use email sign-in: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrEmailSignInInvalid
+	}
+	if _, err := tx.Exec(ctx,
+		`UPDATE auth_users SET email_verified_at = NOW() WHERE email = $1 AND email_verified_at IS NULL`, email); err != nil {
+		return fmt.Errorf("mark email verified: %w", err)
+	}
+	return tx.Commit(ctx)
+}
+
+// registerEmailSignIn spends the row and creates the account for its address,
+// verified, in one transaction: an account that cannot be created — the
+// address taken meanwhile, the database gone — leaves the proof unspent for
+// another try.
+func (s *Store) registerEmailSignIn(ctx context.Context, id uuid.UUID, email, first, last, middle string) (User, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return User{}, fmt.Errorf("begin email register tx: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE email_verification_tokens SET used_at = NOW()
+		WHERE id = $1 AND purpose = 'signin' AND used_at IS NULL AND expires_at > NOW()`, id)
+	if err != nil {
+		return User{}, fmt.Errorf("use email sign-in: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return User{}, ErrEmailSignInInvalid
+	}
+	primary, roles := normalizeRoleSet(defaultRoleName)
+	user, err := s.createUserTx(ctx, tx, email, "", first, last, middle, primary, roles)
+	if err != nil {
+		return User{}, err
+	}
+	if _, err := tx.Exec(ctx,
+		`UPDATE auth_users SET email_verified_at = NOW() WHERE id = $1`, user.ID); err != nil {
+		return User{}, fmt.Errorf("mark email verified: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return User{}, fmt.Errorf("commit email register: %w", err)
+	}
+	return user, nil
+}
@@ -0,0 +1,212 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"shanraq.org/internal/config"
+	"shanraq.org/pkg/shanraq"
+)
+
+func TestNormalizeEmailCode(t *testing.T) {
+	for in, want := range map[string]string{"123456": "123456", " 123 456 ": "123456", "123-456": "123456", "000000": "000000"} {
+		if got, ok := normalizeEmailCode(in); !ok || got != want {
+			t.Errorf("normalizeEmailCode(%q) = %q, %v", in, got, ok)
+		}
+	}
+	for _, in := range []string{"", "12345", "1234567", "12345a", "１２３４５６"} {
+		if _, ok := normalizeEmailCode(in); ok {
+			t.Errorf("normalizeEmailCode(%q) accepted", in)
+		}
+	}
+}
+
+func TestNewEmailCode(t *testing.T) {
+	seen := map[string]bool{}
+	for range 50 {
+		c, err := newEmailCode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := normalizeEmailCode(c); !ok {
+			t.Fatalf("code %q is not six digits", c)
+		}
+		seen[c] = true
+	}
+	if len(seen) < 45 {
+		t.Errorf("%d distinct codes out of 50", len(seen))
+	}
+}
+
+// The same code for two addresses, or under another secret, hashes apart.
+func TestEmailCodeHashIsKeyedAndBound(t *testing.T) {
+	h := emailCodeHash("secret", "a@b.kz", "123456")
+	if h != emailCodeHash("secret", "a@b.kz", "123456") {
+		t.Fatal("hash is not stable")
+	}
+	for _, other := range []string{
+		emailCodeHash("secret", "c@b.kz", "123456"),
+		emailCodeHash("other", "a@b.kz", "123456"),
+		emailCodeHash("secret", "a@b.kz", "123457"),
+		hashToken("123456"),
+	} {
+		if other == h {
+			t.Error("distinct inputs share a hash")
+		}
+	}
+}
+
+func TestEmailSignInMessage(t *testing.T) {
+	subject, body := emailSignInMessage("042917", "https://shanraq.org/x?token=t", true)
+	if !strings.Contains(subject, "sign-in") || !strings.Contains(body, "042917") || !strings.Contains(body, "token=t") {
+		t.Errorf("sign-in mail = %q / %q", subject, body)
+	}
+	if subject, _ := emailSignInMessage("042917", "l", false); !strings.Contains(subject, "registration") {
+		t.Errorf("registration mail subject = %q", subject)
+	}
+}
+
+// Against the real table: a code works once, wrong codes spend the mail, a
+// new mail replaces the old, and a proof registers only an unknown address.
+func TestEmailSignInFlow(t *testing.T) {
+	pool := revocationPool(t)
+	ctx := context.Background()
+	m := &Module{store: NewStore(pool), rt: &shanraq.Runtime{
+		Config: config.Config{Auth: config.AuthConfig{TokenSecret: "test-token-secret-that-is-long-enough-1234567890"}},
+		Logger: zap.NewNop(),
+	}}
+	u := seedUser(t, pool, "user")
+	issue := func(email, token, code string) {
+		t.Helper()
+		if err := m.store.CreateEmailSignIn(ctx, email, hashToken(token), m.emailCodeHash(email, code), time.Now().Add(emailSignInTTL)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	issue(u.Email, "tok-1", "111111")
+	if _, err := m.CheckEmailCode(ctx, u.Email, "222222"); !errors.Is(err, ErrEmailSignInInvalid) {
+		t.Errorf("wrong code: %v", err)
+	}
+	proof, err := m.CheckEmailCode(ctx, u.Email, "111 111")
+	if err != nil || proof.User == nil || proof.User.ID != u.ID {
+		t.Fatalf("right code = %+v, %v", proof, err)
+	}
+	if _, err := m.UseEmailSignIn(ctx, proof); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.UseEmailSignIn(ctx, proof); !errors.Is(err, ErrEmailSignInInvalid) {
+		t.Errorf("second use: %v", err)
+	}
+	if _, err := m.CheckEmailSignIn(ctx, "tok-1"); !errors.Is(err, ErrEmailSignInInvalid) {
+		t.Errorf("link after the code was used: %v", err)
+	}
+
+	issue(u.Email, "tok-2", "333333")
+	issue(u.Email, "tok-3", "444444")
+	if _, err := m.CheckEmailSignIn(ctx, "tok-2"); !errors.Is(err, ErrEmailSignInInvalid) {
+		t.Errorf("a replaced link still works: %v", err)
+	}
+	for range emailCodeAttempts {
+		_, _ = m.CheckEmailCode(ctx, u.Email, "999999")
+	}
+	if _, err := m.CheckEmailCode(ctx, u.Email, "444444"); !errors.Is(err, ErrEmailSignInInvalid) {
+		t.Errorf("the right code after %d wrong ones: %v", emailCodeAttempts, err)
+	}
+
+	fresh := "esi-" + uuid.NewString()[:8] + "@t.test"
+	t.Cleanup(func() { _, _ = pool.Exec(ctx, `DELETE FROM auth_users WHERE email = $1`, fresh) })
+	issue(fresh, "tok-4", "555555")
+	proof, err = m.CheckEmailSignIn(ctx, "tok-4")
+	if err != nil || proof.User != nil {
+		t.Fatalf("unknown address = %+v, %v", proof, err)
+	}
+	if _, err := m.UseEmailSignIn(ctx, proof); !errors.Is(err, ErrNotFound) {
+		t.Errorf("signing in with no account: %v", err)
+	}
+	created, err := m.RegisterEmail(ctx, proof, "Асан", "Серіков", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := m.store.IsEmailVerified(ctx, created.ID); !ok {
+		t.Error("an address proven by code is not marked verified")
+	}
+	if m.HasPassword(ctx, created.ID) || !m.HasPassword(ctx, u.ID) {
+		t.Error("HasPassword confuses the code account with the password one")
+	}
+	if _, err := m.RegisterEmail(ctx, proof, "Асан", "Серіков", ""); !errors.Is(err, ErrEmailSignInInvalid) {
+		t.Errorf("a proof registered twice: %v", err)
+	}
+}
+
+// Guesses sent together are counted as strictly as guesses sent one by one:
+// however many arrive at once, no more than emailCodeAttempts are compared.
+func TestEmailCodeGuessesInParallel(t *testing.T) {
+	pool := revocationPool(t)
+	ctx := context.Background()
+	m := &Module{store: NewStore(pool), rt: &shanraq.Runtime{
+		Config: config.Config{Auth: config.AuthConfig{TokenSecret: "test-token-secret-that-is-long-enough-1234567890"}},
+		Logger: zap.NewNop(),
+	}}
+	u := seedUser(t, pool, "user")
+	if err := m.store.CreateEmailSignIn(ctx, u.Email, hashToken("tok-par"), m.emailCodeHash(u.Email, "123456"),
+		time.Now().Add(emailSignInTTL)); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = m.CheckEmailCode(ctx, u.Email, fmt.Sprintf("%06d", 900000+i))
+		}()
+	}
+	wg.Wait()
+	var attempts int
+	if err := pool.QueryRow(ctx, `SELECT attempts FROM email_verification_tokens WHERE token_hash = $1`,
+		hashToken("tok-par")).Scan(&attempts); err != nil {
+		t.Fatal(err)
+	}
+	if attempts != emailCodeAttempts {
+		t.Errorf("%d guesses counted, want %d", attempts, emailCodeAttempts)
+	}
+	if _, err := m.CheckEmailCode(ctx, u.Email, "123456"); !errors.Is(err, ErrEmailSignInInvalid) {
+		t.Errorf("the right code after a parallel burst: %v", err)
+	}
+}
+
+// A registration that fails leaves the proof to try again with.
+func TestRegisterEmailKeepsProofOnFailure(t *testing.T) {
+	pool := revocationPool(t)
+	ctx := context.Background()
+	m := &Module{store: NewStore(pool), rt: &shanraq.Runtime{
+		Config: config.Config{Auth: config.AuthConfig{TokenSecret: "test-token-secret-that-is-long-enough-1234567890"}},
+		Logger: zap.NewNop(),
+	}}
+	fresh := "esi-" + uuid.NewString()[:8] + "@t.test"
+	t.Cleanup(func() { _, _ = pool.Exec(ctx, `DELETE FROM auth_users WHERE email = $1`, fresh) })
+	if err := m.store.CreateEmailSignIn(ctx, fresh, hashToken("tok-reg"), m.emailCodeHash(fresh, "654321"),
+		time.Now().Add(emailSignInTTL)); err != nil {
+		t.Fatal(err)
+	}
+	proof, err := m.CheckEmailSignIn(ctx, "tok-reg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The address is taken between the proof and the form.
+	if _, err := m.store.CreateUserNamed(ctx, fresh, "x", "Асан", "Серіков", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.RegisterEmail(ctx, proof, "Асан", "Серіков", ""); !errors.Is(err, ErrEmailExists) {
+		t.Fatalf("registering a taken address: %v", err)
+	}
+	if _, err := m.CheckEmailSignIn(ctx, "tok-reg"); err != nil {
+		t.Errorf("the proof was spent by a failed registration: %v", err)
+	}
+}
@@ -2,9 +2,17 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"time"
 )
 
+// ErrMFANotEnrolled is returned by Challenge when the user has registered no
+// authenticator with a provider whose factor is opt-in, such as passkeys. The
+// sign-in then completes on the password alone, as it did before the provider
+// was configured; a provider that enrols on first use, like TOTP, never
+// returns it.
+var ErrMFANotEnrolled = errors.New("no second factor enrolled")
+
 // MFAProvider allows the auth module to hand off multi-factor challenges.
 type MFAProvider interface {
 	// Challenge should initiate a second-factor flow for the given user (e.g. TOTP, SMS, WebAuthn)
@@ -28,4 +36,11 @@ type MFAChallenge struct {
 // MFAResult captures the user record issued by the MFA provider upon a successful verification.
 type MFAResult struct {
 	User User
+	// RecoveryCodes are issued the moment an authenticator app is confirmed.
+	// This is the only time they exist in the clear: show them, then forget.
+	RecoveryCodes []string
+	// RecoveryCodeUsed is set when the step was passed with a recovery code
+	// instead of the authenticator; RecoveryCodesLeft then says how many remain.
+	RecoveryCodeUsed  bool
+	RecoveryCodesLeft int
 }
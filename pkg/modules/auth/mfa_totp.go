@@ -99,6 +99,13 @@ func (p *TOTPProvider) Verify(ctx context.Context, challengeID, code string) (MF
 		return MFAResult{}, err
 	}
 
+	// A recovery code in place of the six digits. Only once the app is
+	// confirmed: before that no codes have been issued, and an unconfirmed
+	// secret is still being enrolled.
+	if normalized, ok := normalizeRecoveryCode(code); ok && record.Confirmed {
+		return p.verifyRecoveryCode(ctx, record, normalized)
+	}
+
 	valid, validateErr := totp.ValidateCustom(
 		strings.TrimSpace(code),
 		record.Secret,
@@ -117,8 +124,13 @@ func (p *TOTPProvider) Verify(ctx context.Context, challengeID, code string) (MF
 		return MFAResult{}, ErrInvalidMFACode
 	}
 
+	var codes []string
 	if !record.Confirmed {
-		if err := p.store.MarkTOTPConfirmed(ctx, record.ID); err != nil {
+		codes, err = GenerateRecoveryCodes()
+		if err != nil {
+			return MFAResult{}, err
+		}
+		if err := p.store.ConfirmTOTP(ctx, record, hashRecoveryCodes(codes)); err != nil {
 			if p.logger != nil {
 				p.logger.Warn("mark totp confirmed", zap.Error(err))
 			}
@@ -131,7 +143,28 @@ func (p *TOTPProvider) Verify(ctx context.Context, challengeID, code string) (MF
 		return MFAResult{}, fmt.Errorf("load user for mfa: %w", err)
 	}
 
-	return MFAResult{User: user}, nil
+	return MFAResult{User: user, RecoveryCodes: codes}, nil
+}
+
+// verifyRecoveryCode passes the step with one of the user's recovery codes,
+// spending it.
+func (p *TOTPProvider) verifyRecoveryCode(ctx context.Context, record MFATOTP, code string) (MFAResult, error) {
+	left, err := p.store.UseRecoveryCode(ctx, record.UserID, hashToken(code))
+	if errors.Is(err, errRecoveryCodeInvalid) {
+		return MFAResult{}, ErrInvalidMFACode
+	}
+	if err != nil {
+		return MFAResult{}, err
+	}
+	user, err := p.store.GetByID(ctx, record.UserID.String())
+	if err != nil {
+		return MFAResult{}, fmt.Errorf("load user for mfa: %w", err)
+	}
+	if p.logger != nil {
+		p.logger.Warn("mfa passed with a recovery code",
+			zap.String("user_id", user.ID.String()), zap.Int("codes_left", left))
+	}
+	return MFAResult{User: user, RecoveryCodeUsed: true, RecoveryCodesLeft: left}, nil
 }
 
 func (p *TOTPProvider) generateSecret(user User) (secret string, uri string, err error) {
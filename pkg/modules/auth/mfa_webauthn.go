@@ -0,0 +1,303 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// The purposes a WebAuthn ceremony is opened for. A challenge answers only
+// the purpose it was issued for: one handed out to register a passkey cannot
+// be replayed as a sign-in.
+const (
+	ceremonyRegister = "register"
+	// ceremonyLogin is the second step after a password, for a known user.
+	ceremonyLogin = "login"
+	// ceremonyPasskey is a sign-in with a passkey alone; the account is only
+	// known from the response.
+	ceremonyPasskey = "passkey"
+)
+
+// webauthnCeremonyTTL is how long a challenge may be answered. The browser
+// gives up on its own after about as long.
+const webauthnCeremonyTTL = 5 * time.Minute
+
+// WebAuthnProvider implements MFAProvider with passkeys and security keys.
+//
+// Unlike TOTP it does not enrol on first use: an authenticator can only be
+// registered from a signed-in profile, so Challenge answers ErrMFANotEnrolled
+// for an account that has none and the sign-in goes on without it. It also
+// offers what TOTP cannot, a sign-in with the passkey alone: the authenticator
+// proves possession and, with user verification required, the PIN or
+// fingerprint that unlocked it — two factors in one gesture.
+type WebAuthnProvider struct {
+	store  *Store
+	wa     *webauthn.WebAuthn
+	logger *zap.Logger
+}
+
+// NewWebAuthnProvider builds a provider for the relying party rpID, accepting
+// ceremonies from origins only.
+func NewWebAuthnProvider(store *Store, rpID, rpName string, origins []string, logger *zap.Logger) (*WebAuthnProvider, error) {
+	if rpName == "" {
+		rpName = "Shanraq"
+	}
+	wa, err := webauthn.New(&webauthn.Config{
+		RPID:          rpID,
+		RPDisplayName: rpName,
+		RPOrigins:     origins,
+		// Passkeys are asked for, not demanded: a security key without room
+		// for a resident credential still works as a second factor, just not
+		// for signing in on its own.
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			ResidentKey:      protocol.ResidentKeyRequirementPreferred,
+			UserVerification: protocol.VerificationPreferred,
+		},
+		// Attestation is not asked for. It would tell us the make of the
+		// authenticator, which nothing here decides on, at the price of a
+		// browser prompt about sharing it.
+		AttestationPreference: protocol.PreferNoAttestation,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("configure webauthn: %w", err)
+	}
+	return &WebAuthnProvider{store: store, wa: wa, logger: logger}, nil
+}
+
+// Challenge asks a user who signed in with a password for one of their
+// authenticators. The options for navigator.credentials.get are in
+// Data["webauthn_options"].
+func (p *WebAuthnProvider) Challenge(ctx context.Context, user User) (MFAChallenge, error) {
+	creds, err := p.store.webauthnCredentials(ctx, user.ID)
+	if err != nil {
+		return MFAChallenge{}, err
+	}
+	if len(creds) == 0 {
+		return MFAChallenge{}, ErrMFANotEnrolled
+	}
+	// Preferred rather than required: the password already was the
+	// knowledge factor, and a bare security key should still do here.
+	assertion, session, err := p.wa.BeginLogin(webauthnUser{user: user, creds: creds},
+		webauthn.WithUserVerification(protocol.VerificationPreferred))
+	if err != nil {
+		return MFAChallenge{}, fmt.Errorf("begin webauthn login: %w", err)
+	}
+	return p.open(ctx, &user.ID, ceremonyLogin, session, assertion)
+}
+
+// BeginPasskeySignin opens a sign-in with a passkey alone. The browser offers
+// whichever of its passkeys belong to this site; user verification is
+// required, as the passkey is the only factor asked for.
+func (p *WebAuthnProvider) BeginPasskeySignin(ctx context.Context) (MFAChallenge, error) {
+	assertion, session, err := p.wa.BeginDiscoverableLogin(
+		webauthn.WithUserVerification(protocol.VerificationRequired))
+	if err != nil {
+		return MFAChallenge{}, fmt.Errorf("begin passkey sign-in: %w", err)
+	}
+	return p.open(ctx, nil, ceremonyPasskey, session, assertion)
+}
+
+// Verify checks the assertion, the JSON the browser's
+// navigator.credentials.get resolved to, against the challenge it answers:
+// either a second step opened by Challenge or a passkey sign-in.
+func (p *WebAuthnProvider) Verify(ctx context.Context, challengeID, code string) (MFAResult, error) {
+	ceremony, err := p.take(ctx, challengeID)
+	if err != nil {
+		return MFAResult{}, err
+	}
+	parsed, err := protocol.ParseCredentialRequestResponseBytes([]byte(code))
+	if err != nil {
+		p.debug("parse webauthn assertion", err)
+		return MFAResult{}, ErrInvalidMFACode
+	}
+
+	var (
+		user User
+		cred *webauthn.Credential
+	)
+	switch {
+	case ceremony.purpose == ceremonyLogin && ceremony.userID != nil:
+		u, loadErr := p.loadUser(ctx, *ceremony.userID)
+		if loadErr != nil {
+			return MFAResult{}, loadErr
+		}
+		user = u.user
+		cred, err = p.wa.ValidateLogin(u, ceremony.session, parsed)
+	case ceremony.purpose == ceremonyPasskey:
+		var found webauthn.User
+		found, cred, err = p.wa.ValidatePasskeyLogin(func(_, userHandle []byte) (webauthn.User, error) {
+			id, idErr := uuid.FromBytes(userHandle)
+			if idErr != nil {
+				return nil, idErr
+			}
+			return p.loadUser(ctx, id)
+		}, ceremony.session, parsed)
+		if err == nil {
+			user = found.(webauthnUser).user
+		}
+	default:
+		return MFAResult{}, ErrInvalidMFACode
+	}
+	if err != nil {
+		p.debug("validate webauthn assertion", err)
+		return MFAResult{}, ErrInvalidMFACode
+	}
+	// A counter that went backwards means two authenticators answer for one
+	// credential: the key was copied. Synced passkeys keep theirs at zero and
+	// never trip this.
+	if cred.Authenticator.CloneWarning {
+		if p.logger != nil {
+			p.logger.Warn("webauthn signature counter went backwards; refusing a possibly cloned authenticator",
+				zap.String("user_id", user.ID.String()))
+		}
+		return MFAResult{}, ErrInvalidMFACode
+	}
+	if err := p.store.touchWebAuthnCredential(ctx, cred); err != nil {
+		return MFAResult{}, err
+	}
+	return MFAResult{User: user}, nil
+}
+
+// BeginRegistration opens the registration of another authenticator for
+// user. The ones already registered are excluded, so the browser says "this
+// one is already registered" instead of registering it twice.
+func (p *WebAuthnProvider) BeginRegistration(ctx context.Context, user User) (MFAChallenge, error) {
+	creds, err := p.store.webauthnCredentials(ctx, user.ID)
+	if err != nil {
+		return MFAChallenge{}, err
+	}
+	if len(creds) >= maxPasskeys {
+		return MFAChallenge{}, ErrTooManyPasskeys
+	}
+	exclude := make([]protocol.CredentialDescriptor, 0, len(creds))
+	for i := range creds {
+		exclude = append(exclude, creds[i].Descriptor())
+	}
+	creation, session, err := p.wa.BeginRegistration(webauthnUser{user: user, creds: creds},
+		webauthn.WithExclusions(exclude))
+	if err != nil {
+		return MFAChallenge{}, fmt.Errorf("begin webauthn registration: %w", err)
+	}
+	return p.open(ctx, &user.ID, ceremonyRegister, session, creation)
+}
+
+// FinishRegistration checks the attestation, the JSON the browser's
+// navigator.credentials.create resolved to, and stores the authenticator
+// under name. The challenge must have been opened for the same user.
+func (p *WebAuthnProvider) FinishRegistration(ctx context.Context, user User, challengeID, name string, response []byte) (Passkey, error) {
+	ceremony, err := p.take(ctx, challengeID)
+	if err != nil {
+		return Passkey{}, err
+	}
+	if ceremony.purpose != ceremonyRegister || ceremony.userID == nil || *ceremony.userID != user.ID {
+		return Passkey{}, ErrInvalidMFACode
+	}
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(response)
+	if err != nil {
+		p.debug("parse webauthn attestation", err)
+		return Passkey{}, ErrInvalidMFACode
+	}
+	u, err := p.loadUser(ctx, user.ID)
+	if err != nil {
+		return Passkey{}, err
+	}
+	cred, err := p.wa.CreateCredential(u, ceremony.session, parsed)
+	if err != nil {
+		p.debug("validate webauthn attestation", err)
+		return Passkey{}, ErrInvalidMFACode
+	}
+	return p.store.createPasskey(ctx, user.ID, passkeyName(name), cred)
+}
+
+// open stores the session half of a ceremony and hands back the other half.
+func (p *WebAuthnProvider) open(ctx context.Context, userID *uuid.UUID, purpose string, session *webauthn.SessionData, options any) (MFAChallenge, error) {
+	expires := time.Now().Add(webauthnCeremonyTTL)
+	id, err := p.store.openWebAuthnSession(ctx, userID, purpose, session, expires)
+	if err != nil {
+		return MFAChallenge{}, err
+	}
+	return MFAChallenge{
+		ID:        id.String(),
+		Channel:   "webauthn",
+		ExpiresAt: expires,
+		Data:      map[string]any{"webauthn_options": options},
+	}, nil
+}
+
+// take consumes the ceremony challengeID names. An unknown, expired or
+// already answered challenge is ErrInvalidMFACode, like a wrong TOTP code.
+func (p *WebAuthnProvider) take(ctx context.Context, challengeID string) (webauthnCeremony, error) {
+	id, err := uuid.Parse(strings.TrimSpace(challengeID))
+	if err != nil {
+		return webauthnCeremony{}, ErrInvalidMFACode
+	}
+	ceremony, err := p.store.takeWebAuthnSession(ctx, id)
+	if errors.Is(err, errWebAuthnSessionNotFound) {
+		return webauthnCeremony{}, ErrInvalidMFACode
+	}
+	return ceremony, err
+}
+
+func (p *WebAuthnProvider) loadUser(ctx context.Context, id uuid.UUID) (webauthnUser, error) {
+	user, err := p.store.GetByID(ctx, id.String())
+	if err != nil {
+		return webauthnUser{}, fmt.Errorf("load user for webauthn: %w", err)
+	}
+	creds, err := p.store.webauthnCredentials(ctx, id)
+	if err != nil {
+		return webauthnUser{}, err
+	}
+	return webauthnUser{user: user, creds: creds}, nil
+}
+
+// debug logs why a ceremony was refused. The caller only ever hears
+// ErrInvalidMFACode; the library's reason is for whoever reads the logs.
+func (p *WebAuthnProvider) debug(msg string, err error) {
+	if p.logger == nil {
+		return
+	}
+	fields := []zap.Field{zap.Error(err)}
+	var perr *protocol.Error
+	if errors.As(err, &perr) {
+		fields = append(fields, zap.String("details", perr.Details), zap.String("info", perr.DevInfo))
+	}
+	p.logger.Debug(msg, fields...)
+}
+
+// webauthnUser is a User as the WebAuthn library sees it. The user handle is
+// the account ID, so a passkey sign-in names its account without an e-mail
+// being typed; the e-mail is only the label the authenticator shows.
+type webauthnUser struct {
+	user  User
+	creds []webauthn.Credential
+}
+
+func (u webauthnUser) WebAuthnID() []byte {
+	id := u.user.ID
+	return id[:]
+}
+
+func (u webauthnUser) WebAuthnName() string {
+	if u.user.Email != "" {
+		return u.user.Email
+	}
+	return u.user.ID.String()
+}
+
+func (u webauthnUser) WebAuthnDisplayName() string { return u.WebAuthnName() }
+
+func (u webauthnUser) WebAuthnCredentials() []webauthn.Credential { return u.creds }
+
+// webauthnCeremony is a stored challenge, taken back for its answer.
+type webauthnCeremony struct {
+	userID  *uuid.UUID
+	purpose string
+	session webauthn.SessionData
+}
@@ -0,0 +1,381 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var (
+	// ErrPasskeysDisabled is returned by the passkey methods when WebAuthn is
+	// not configured.
+	ErrPasskeysDisabled = errors.New("passkeys are not enabled")
+	// ErrPasskeyNotFound is returned when removing a passkey the account does
+	// not have.
+	ErrPasskeyNotFound = errors.New("passkey not found")
+	// ErrPasskeyExists is returned when an authenticator is registered twice.
+	ErrPasskeyExists = errors.New("this authenticator is already registered")
+	// ErrTooManyPasskeys is returned when an account already has maxPasskeys.
+	ErrTooManyPasskeys = errors.New("too many passkeys registered")
+
+	errWebAuthnSessionNotFound = errors.New("webauthn session not found")
+)
+
+// maxPasskeys bounds the authenticators of one account. A phone, a laptop,
+// a spare key and a few replacements fit; a script registering keys by the
+// thousand, making every sign-in read them all, does not.
+const maxPasskeys = 10
+
+// maxPasskeyName is how long a passkey's label may be, in characters.
+const maxPasskeyName = 60
+
+// Passkey is a registered WebAuthn authenticator, as the profile lists it.
+type Passkey struct {
+	ID   uuid.UUID
+	Name string
+	// Synced reports a passkey the platform copies between the owner's
+	// devices (iCloud Keychain, Google Password Manager): losing the phone
+	// does not lose it.
+	Synced     bool
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+}
+
+// WithWebAuthn enables passkeys for the relying party rpID, named rpName in
+// the browser's prompts, accepting ceremonies from origins. An empty rpID or
+// origins list is taken from public_base_url.
+//
+// With no other second factor configured, passkeys become it: an account
+// that has registered one is asked for it after its password. With TOTP on
+// as well, TOTP stays the second step and passkeys are a sign-in of their own.
+func WithWebAuthn(rpID, rpName string, origins []string) Option {
+	return func(m *Module) {
+		m.webauthnCfg = &webauthnOptions{rpID: rpID, rpName: rpName, origins: origins}
+	}
+}
+
+type webauthnOptions struct {
+	rpID    string
+	rpName  string
+	origins []string
+}
+
+// initWebAuthn builds the provider from the options, filling the blanks from
+// the public base URL. A configuration the library refuses stops the boot: a
+// site that announces passkeys and cannot verify one is worse than no
+// passkeys.
+func (m *Module) initWebAuthn() error {
+	if m.webauthnCfg == nil {
+		return nil
+	}
+	base := m.rt.Config.PublicBase()
+	rpID, origins := m.webauthnCfg.rpID, m.webauthnCfg.origins
+	if rpID == "" {
+		u, err := url.Parse(base)
+		if err != nil || u.Hostname() == "" {
+			return fmt.Errorf("webauthn: no rp_id and no usable public_base_url (%q)", base)
+		}
+		rpID = u.Hostname()
+	}
+	if len(origins) == 0 {
+		origins = []string{base}
+	}
+	provider, err := NewWebAuthnProvider(m.store, rpID, m.webauthnCfg.rpName, origins, m.rt.Logger)
+	if err != nil {
+		return err
+	}
+	m.passkeys = provider
+	if m.mfaProvider == nil {
+		m.mfaProvider = provider
+	}
+	return nil
+}
+
+// PasskeysEnabled reports whether passkeys can be registered and signed in
+// with.
+func (m *Module) PasskeysEnabled() bool { return m.passkeys != nil }
+
+// PasskeyMFA reports whether the configured second factor is the passkey,
+// the one factor a browser form can ask for without the API's challenge
+// round trip. When MFAEnabled is true and this is false, the form must still
+// refuse.
+func (m *Module) PasskeyMFA() bool {
+	return m.passkeys != nil && m.mfaProvider == MFAProvider(m.passkeys)
+}
+
+// PasskeyChallenge asks user, who has just given the right password, for one
+// of their passkeys. It returns ErrMFANotEnrolled when they have none, and the
+// caller signs them in on the password alone.
+func (m *Module) PasskeyChallenge(ctx context.Context, user User) (MFAChallenge, error) {
+	if m.passkeys == nil {
+		return MFAChallenge{}, ErrPasskeysDisabled
+	}
+	return m.passkeys.Challenge(ctx, user)
+}
+
+// BeginPasskeySignin opens a sign-in with a passkey alone.
+func (m *Module) BeginPasskeySignin(ctx context.Context) (MFAChallenge, error) {
+	if m.passkeys == nil {
+		return MFAChallenge{}, ErrPasskeysDisabled
+	}
+	return m.passkeys.BeginPasskeySignin(ctx)
+}
+
+// FinishPasskeySignin verifies the browser's answer to a challenge from
+// PasskeyChallenge or BeginPasskeySignin and returns the user with a freshly
+// signed access token, as LoginPassword does.
+func (m *Module) FinishPasskeySignin(ctx context.Context, challengeID string, response []byte) (User, string, error) {
+	if m.passkeys == nil {
+		return User{}, "", ErrPasskeysDisabled
+	}
+	result, err := m.passkeys.Verify(ctx, challengeID, string(response))
+	if err != nil {
+		return User{}, "", err
+	}
+	token, err := m.tokens.Generate(result.User)
+	if err != nil {
+		return User{}, "", err
+	}
+	return result.User, token, nil
+}
+
+// Passkeys lists the account's passkeys, oldest first.
+func (m *Module) Passkeys(ctx context.Context, userID uuid.UUID) ([]Passkey, error) {
+	if m.passkeys == nil {
+		return nil, ErrPasskeysDisabled
+	}
+	return m.store.ListPasskeys(ctx, userID)
+}
+
+// BeginPasskeyRegistration opens the registration of another passkey. The
+// caller is expected to have asked for the password first: a stolen session
+// must not be able to leave a passkey of its own behind.
+func (m *Module) BeginPasskeyRegistration(ctx context.Context, userID uuid.UUID) (MFAChallenge, error) {
+	if m.passkeys == nil {
+		return MFAChallenge{}, ErrPasskeysDisabled
+	}
+	user, err := m.store.GetByID(ctx, userID.String())
+	if err != nil {
+		return MFAChallenge{}, err
+	}
+	return m.passkeys.BeginRegistration(ctx, user)
+}
+
+// FinishPasskeyRegistration stores the passkey the browser created in answer
+// to challengeID.
+func (m *Module) FinishPasskeyRegistration(ctx context.Context, userID uuid.UUID, challengeID, name string, response []byte) (Passkey, error) {
+	if m.passkeys == nil {
+		return Passkey{}, ErrPasskeysDisabled
+	}
+	user, err := m.store.GetByID(ctx, userID.String())
+	if err != nil {
+		return Passkey{}, err
+	}
+	return m.passkeys.FinishRegistration(ctx, user, challengeID, name, response)
+}
+
+// RemovePasskey deletes one of the account's passkeys and retires every
+// token the account holds: the passkey may be going because the phone it
+// lived on was lost, and whoever has the phone may have its sessions too. The
+// returned token replaces the caller's own, in the caller's session, so the
+// person who removed it is the one session that carries on.
+func (m *Module) RemovePasskey(ctx context.Context, userID, sessionID, id uuid.UUID) (string, error) {
+	if m.passkeys == nil {
+		return "", ErrPasskeysDisabled
+	}
+	if err := m.store.DeletePasskey(ctx, userID, id); err != nil {
+		return "", err
+	}
+	// The version bump refuses their access tokens; revoking the sessions
+	// stops their refresh tokens too, and takes them off the sessions list.
+	if _, err := m.store.RevokeOtherSessions(ctx, userID, sessionID); err != nil {
+		return "", err
+	}
+	user, err := m.store.GetByID(ctx, userID.String())
+	if err != nil {
+		return "", err
+	}
+	return m.renewBrowserSession(ctx, user, sessionID)
+}
+
+// passkeyName trims a label to something the profile can show; an empty one
+// is named by the date, which is how people tell two phones apart anyway.
+func passkeyName(name string) string {
+	name = strings.Join(strings.Fields(name), " ")
+	if utf8.RuneCountInString(name) > maxPasskeyName {
+		name = string([]rune(name)[:maxPasskeyName])
+	}
+	if name == "" {
+		name = "Passkey " + time.Now().UTC().Format("2006-01-02")
+	}
+	return name
+}
+
+// ListPasskeys returns the user's passkeys, oldest first.
+func (s *Store) ListPasskeys(ctx context.Context, userID uuid.UUID) ([]Passkey, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT id, name, COALESCE((credential->'flags'->>'backupEligible')::boolean, false), created_at, last_used_at
+		FROM auth_webauthn_credentials
+		WHERE user_id = $1
+		ORDER BY created_at
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list passkeys: %w", err)
+	}
+	defer rows.Close()
+
+	var out []Passkey
+	for rows.Next() {
+		var p Passkey
+		if err := rows.Scan(&p.ID, &p.Name, &p.Synced, &p.CreatedAt, &p.LastUsedAt); err != nil {
+			return nil, fmt.Errorf("scan passkey: %w", err)
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// DeletePasskey removes the user's passkey id and bumps auth_version in the
+// same transaction, so there is no moment where the passkey is gone and the
+// sessions it may have opened are not.
+func (s *Store) DeletePasskey(ctx context.Context, userID, id uuid.UUID) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin passkey removal: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck
+
+	tag, err := tx.Exec(ctx, `DELETE FROM auth_webauthn_credentials WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return fmt.Errorf("delete passkey: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrPasskeyNotFound
+	}
+	if _, err := tx.Exec(ctx,
+		`UPDATE auth_users SET auth_version = auth_version + 1, updated_at = now() WHERE id = $1`, userID); err != nil {
+		return fmt.Errorf("bump auth version: %w", err)
+	}
+	return tx.Commit(ctx)
+}
+
+func (s *Store) webauthnCredentials(ctx context.Context, userID uuid.UUID) ([]webauthn.Credential, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT credential FROM auth_webauthn_credentials WHERE user_id = $1 ORDER BY created_at
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("load webauthn credentials: %w", err)
+	}
+	defer rows.Close()
+
+	var out []webauthn.Credential
+	for rows.Next() {
+		var raw []byte
+		if err := rows.Scan(&raw); err != nil {
+			return nil, fmt.Errorf("scan webauthn credential: %w", err)
+		}
+		var cred webauthn.Credential
+		if err := json.Unmarshal(raw, &cred); err != nil {
+			return nil, fmt.Errorf("decode webauthn credential: %w", err)
+		}
+		out = append(out, cred)
+	}
+	return out, rows.Err()
+}
+
+func (s *Store) createPasskey(ctx context.Context, userID uuid.UUID, name string, cred *webauthn.Credential) (Passkey, error) {
+	raw, err := json.Marshal(cred)
+	if err != nil {
+		return Passkey{}, fmt.Errorf("encode webauthn credential: %w", err)
+	}
+	p := Passkey{ID: uuid.New(), Name: name, Synced: cred.Flags.BackupEligible}
+	err = s.db.QueryRow(ctx, `
+		INSERT INTO auth_webauthn_credentials (id, user_id, credential_id, name, credential)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at
+	`, p.ID, userID, cred.ID, name, raw).Scan(&p.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.ConstraintName == "auth_webauthn_credentials_credential_unique" {
+			return Passkey{}, ErrPasskeyExists
+		}
+		return Passkey{}, fmt.Errorf("insert passkey: %w", err)
+	}
+	return p, nil
+}
+
+// touchWebAuthnCredential stores what a sign-in changed about the
+// credential — its signature counter and backup state — and when it was used.
+func (s *Store) touchWebAuthnCredential(ctx context.Context, cred *webauthn.Credential) error {
+	raw, err := json.Marshal(cred)
+	if err != nil {
+		return fmt.Errorf("encode webauthn credential: %w", err)
+	}
+	if _, err := s.db.Exec(ctx, `
+		UPDATE auth_webauthn_credentials SET credential = $2, last_used_at = NOW() WHERE credential_id = $1
+	`, cred.ID, raw); err != nil {
+		return fmt.Errorf("update webauthn credential: %w", err)
+	}
+	return nil
+}
+
+// openWebAuthnSession stores a ceremony's session data. Expired ones are
+// cleared on the way: a passkey sign-in is opened without an account, so the
+// table would otherwise keep every challenge a visitor ever fetched.
+func (s *Store) openWebAuthnSession(ctx context.Context, userID *uuid.UUID, purpose string, session *webauthn.SessionData, expires time.Time) (uuid.UUID, error) {
+	if _, err := s.db.Exec(ctx, `DELETE FROM auth_webauthn_sessions WHERE expires_at < NOW()`); err != nil {
+		return uuid.Nil, fmt.Errorf("clear expired webauthn sessions: %w", err)
+	}
+	raw, err := json.Marshal(session)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("encode webauthn session: %w", err)
+	}
+	id := uuid.New()
+	if _, err := s.db.Exec(ctx, `
+		INSERT INTO auth_webauthn_sessions (id, user_id, purpose, session, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, id, userID, purpose, raw, expires); err != nil {
+		return uuid.Nil, fmt.Errorf("insert webauthn session: %w", err)
+	}
+	return id, nil
+}
+
+// takeWebAuthnSession deletes and returns a ceremony's session data. Taking
+// it is what makes a challenge single-use: a second answer finds nothing.
+func (s *Store) takeWebAuthnSession(ctx context.Context, id uuid.UUID) (webauthnCeremony, error) {
+	var (
+		c    webauthnCeremony
+		user pgtype.UUID
+		raw  []byte
+	)
+	err := s.db.QueryRow(ctx, `
+		DELETE FROM auth_webauthn_sessions
+		WHERE id = $1 AND expires_at > NOW()
+		RETURNING user_id, purpose, session
+	`, id).Scan(&user, &c.purpose, &raw)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return webauthnCeremony{}, errWebAuthnSessionNotFound
+		}
+		return webauthnCeremony{}, fmt.Errorf("take webauthn session: %w", err)
+	}
+	if user.Valid {
+		userID := uuid.UUID(user.Bytes)
+		c.userID = &userID
+	}
+	if err := json.Unmarshal(raw, &c.session); err != nil {
+		return webauthnCeremony{}, fmt.Errorf("decode webauthn session: %w", err)
+	}
+	return c, nil
+}
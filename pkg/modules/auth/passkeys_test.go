@@ -0,0 +1,251 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+)
+
+const testOrigin = "http://localhost:8080"
+
+// softKey is an authenticator in software: one ES256 credential, "none"
+// attestation, answering for localhost. Enough to run both ceremonies
+// through the real verifier without a browser.
+type softKey struct {
+	id    []byte
+	priv  *ecdsa.PrivateKey
+	count uint32
+}
+
+func newSoftKey(t *testing.T) *softKey {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return &softKey{id: id, priv: priv}
+}
+
+func b64(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+// authData is the authenticator data: the RP ID hash, the flags (user
+// present, user verified when uv) and the signature counter.
+func (k *softKey) authData(uv bool, extra byte) []byte {
+	rp := sha256.Sum256([]byte("localhost"))
+	flags := byte(0x01) | extra
+	if uv {
+		flags |= 0x04
+	}
+	k.count++
+	out := append(rp[:], flags)
+	return binary.BigEndian.AppendUint32(out, k.count)
+}
+
+func clientData(typ string, challenge []byte) []byte {
+	b, _ := json.Marshal(map[string]string{"type": typ, "challenge": b64(challenge), "origin": testOrigin})
+	return b
+}
+
+// attest answers navigator.credentials.create.
+func (k *softKey) attest(t *testing.T, challenge []byte) []byte {
+	t.Helper()
+	cose, err := webauthncbor.Marshal(map[int]any{
+		1: 2, 3: -7, -1: 1,
+		-2: k.priv.X.FillBytes(make([]byte, 32)),
+		-3: k.priv.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := k.authData(true, 0x40)
+	data = append(data, make([]byte, 16)...) // AAGUID
+	data = binary.BigEndian.AppendUint16(data, uint16(len(k.id)))
+	data = append(append(data, k.id...), cose...)
+	att, err := webauthncbor.Marshal(map[string]any{"fmt": "none", "attStmt": map[string]any{}, "authData": data})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := json.Marshal(map[string]any{
+		"id": b64(k.id), "rawId": b64(k.id), "type": "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    b64(clientData("webauthn.create", challenge)),
+			"attestationObject": b64(att),
+		},
+	})
+	return b
+}
+
+// assert answers navigator.credentials.get as the passkey of userHandle.
+func (k *softKey) assert(t *testing.T, challenge, userHandle []byte, uv bool) []byte {
+	t.Helper()
+	data := k.authData(uv, 0)
+	cdj := clientData("webauthn.get", challenge)
+	sum := sha256.Sum256(cdj)
+	digest := sha256.Sum256(append(append([]byte{}, data...), sum[:]...))
+	sig, err := ecdsa.SignASN1(rand.Reader, k.priv, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := json.Marshal(map[string]any{
+		"id": b64(k.id), "rawId": b64(k.id), "type": "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    b64(cdj),
+			"authenticatorData": b64(data),
+			"signature":         b64(sig),
+			"userHandle":        b64(userHandle),
+		},
+	})
+	return b
+}
+
+func testProvider(t *testing.T, store *Store) *WebAuthnProvider {
+	t.Helper()
+	p, err := NewWebAuthnProvider(store, "localhost", "", []string{testOrigin}, nil)
+	if err != nil {
+		t.Fatalf("provider: %v", err)
+	}
+	return p
+}
+
+// A passkey registered for an account signs that account in on its own, and
+// only with user verification: with the passkey as the only factor, a key
+// that was merely touched must not be enough.
+func TestPasskeyCeremonies(t *testing.T) {
+	p := testProvider(t, nil)
+	user := User{ID: uuid.New(), Email: "pk@t.test"}
+	key := newSoftKey(t)
+
+	creation, session, err := p.wa.BeginRegistration(webauthnUser{user: user})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := creation.Response.AuthenticatorSelection.ResidentKey; got != protocol.ResidentKeyRequirementPreferred {
+		t.Fatalf("resident key = %q, want preferred", got)
+	}
+	if string(creation.Response.User.ID.(protocol.URLEncodedBase64)) != string(user.ID[:]) {
+		t.Fatalf("user handle is not the account ID")
+	}
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(key.attest(t, creation.Response.Challenge))
+	if err != nil {
+		t.Fatalf("parse attestation: %v", err)
+	}
+	cred, err := p.wa.CreateCredential(webauthnUser{user: user}, *session, parsed)
+	if err != nil {
+		t.Fatalf("create credential: %v", err)
+	}
+
+	login := func(uv bool) (webauthn.User, error) {
+		assertion, session, err := p.wa.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed, err := protocol.ParseCredentialRequestResponseBytes(key.assert(t, assertion.Response.Challenge, user.ID[:], uv))
+		if err != nil {
+			t.Fatalf("parse assertion: %v", err)
+		}
+		found, _, err := p.wa.ValidatePasskeyLogin(func(_, handle []byte) (webauthn.User, error) {
+			id, err := uuid.FromBytes(handle)
+			if err != nil || id != user.ID {
+				return nil, errors.New("unknown user")
+			}
+			return webauthnUser{user: user, creds: []webauthn.Credential{*cred}}, nil
+		}, *session, parsed)
+		return found, err
+	}
+	found, err := login(true)
+	if err != nil {
+		t.Fatalf("passkey login: %v", err)
+	}
+	if found.(webauthnUser).user.ID != user.ID {
+		t.Fatalf("signed in as %v, want %v", found.(webauthnUser).user.ID, user.ID)
+	}
+	if _, err := login(false); err == nil {
+		t.Fatal("a passkey sign-in without user verification was accepted")
+	}
+}
+
+func TestPasskeyName(t *testing.T) {
+	if got := passkeyName("  Work \n laptop "); got != "Work laptop" {
+		t.Fatalf("got %q", got)
+	}
+	if got := passkeyName(strings.Repeat("ж", 100)); len([]rune(got)) != maxPasskeyName {
+		t.Fatalf("name not cut to %d runes: %d", maxPasskeyName, len([]rune(got)))
+	}
+	if got := passkeyName(""); !strings.HasPrefix(got, "Passkey ") {
+		t.Fatalf("empty name gave %q", got)
+	}
+}
+
+// Through the store: register, sign in once per challenge, and removing the
+// passkey ends every token issued before — but only the owner can remove it.
+func TestPasskeyLifecycle(t *testing.T) {
+	pool := revocationPool(t)
+	ctx := context.Background()
+	store := NewStore(pool)
+	p := testProvider(t, store)
+	user := seedUser(t, pool, "user")
+	other := seedUser(t, pool, "user")
+	key := newSoftKey(t)
+
+	if _, err := p.Challenge(ctx, user); !errors.Is(err, ErrMFANotEnrolled) {
+		t.Fatalf("challenge without passkeys: %v, want ErrMFANotEnrolled", err)
+	}
+
+	reg, err := p.BeginRegistration(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	creation := reg.Data["webauthn_options"].(*protocol.CredentialCreation)
+	if _, err := p.FinishRegistration(ctx, other, reg.ID, "", key.attest(t, creation.Response.Challenge)); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("registration finished by another account: %v", err)
+	}
+	reg, _ = p.BeginRegistration(ctx, user)
+	creation = reg.Data["webauthn_options"].(*protocol.CredentialCreation)
+	pk, err := p.FinishRegistration(ctx, user, reg.ID, "Phone", key.attest(t, creation.Response.Challenge))
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	signin, err := p.BeginPasskeySignin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertion := signin.Data["webauthn_options"].(*protocol.CredentialAssertion)
+	response := string(key.assert(t, assertion.Response.Challenge, user.ID[:], true))
+	res, err := p.Verify(ctx, signin.ID, response)
+	if err != nil || res.User.ID != user.ID {
+		t.Fatalf("passkey sign-in: %v %v", res.User.ID, err)
+	}
+	if _, err := p.Verify(ctx, signin.ID, response); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("a challenge was answered twice: %v", err)
+	}
+
+	if err := store.DeletePasskey(ctx, other.ID, pk.ID); !errors.Is(err, ErrPasskeyNotFound) {
+		t.Fatalf("another account removed the passkey: %v", err)
+	}
+	if err := store.DeletePasskey(ctx, user.ID, pk.ID); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	var version int
+	if err := pool.QueryRow(ctx, `SELECT auth_version FROM auth_users WHERE id=$1`, user.ID).Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	if version <= user.AuthVersion {
+		t.Fatalf("auth_version not bumped: %d -> %d", user.AuthVersion, version)
+	}
+}
@@ -0,0 +1,404 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+	"shanraq.org/pkg/transport/respond"
+)
+
+// Permissions: what a role may do, by name, instead of which role names a
+// route happens to list.
+//
+// Every guard used to be a role list — RequireRoles("operator", "admin"), the
+// admin panel's admin/director/manager/editor, a handler's own
+// HasAnyRole("admin", "director") — so the only way to let a moderator hide
+// comments was a role that also reached tariffs, payments and the AI
+// settings. A role is now a set of permissions kept in auth_role_permissions,
+// and a route asks for the permission it needs. The sets are edited in the
+// admin panel by root administrators.
+//
+// Two roles are not editable. admin holds every permission, including ones
+// added after its row was seeded, so the site can never lose the means to
+// grant them back; director holds what was seeded for it. roles.manage is
+// theirs alone: a role that can edit roles can give itself anything, so it
+// would be root in all but name.
+
+// Named permissions. The catalog is AllPermissions.
+const (
+	PermAdminView         = "admin.view"         // open /admin and its analytics
+	PermUsersManage       = "users.manage"       // the account register, sessions, throttled keys
+	PermRolesManage       = "roles.manage"       // edit what each role may do; root only
+	PermAuditView         = "audit.view"         // the audit log and its export
+	PermFinanceView       = "finance.view"       // the finance panel
+	PermPaymentsConfigure = "payments.configure" // the payment provider switch
+	PermTariffsEdit       = "tariffs.edit"       // prices of ads and promotions
+	PermCommentsHide      = "comments.hide"      // hide a comment
+	PermArticlesDecide    = "articles.decide"    // approve or refuse an article under review
+	PermAppealsResolve    = "appeals.resolve"    // answer an appeal against moderation
+	PermPartnersDecide    = "partners.decide"    // verify agents and organisations
+	PermServicesManage    = "services.manage"    // maintenance switches per service
+	PermAIConfigure       = "ai.configure"       // AI provider and models
+	PermPagesEdit         = "pages.edit"         // policy and content pages
+	PermPredictionsEdit   = "predictions.edit"   // the forecast ledger
+	PermConfigReload      = "config.reload"      // re-read the configuration
+	PermConsoleView       = "console.view"       // the operator console
+	PermJobsView          = "jobs.view"          // list and inspect jobs
+	PermJobsEnqueue       = "jobs.enqueue"       // enqueue a job
+	PermJobsRetry         = "jobs.retry"         // retry, cancel and replay jobs
+	PermJobsPurge         = "jobs.purge"         // purge finished jobs
+	PermJobsSchedule      = "jobs.schedule"      // change and run schedules
+)
+
+// AllPermissions lists every permission, in the order the role editor shows
+// them.
+var AllPermissions = []string{
+	PermAdminView, PermUsersManage, PermRolesManage, PermAuditView,
+	PermFinanceView, PermPaymentsConfigure, PermTariffsEdit,
+	PermCommentsHide, PermArticlesDecide, PermAppealsResolve, PermPartnersDecide,
+	PermServicesManage, PermAIConfigure, PermPagesEdit, PermPredictionsEdit, PermConfigReload,
+	PermConsoleView, PermJobsView, PermJobsEnqueue, PermJobsRetry, PermJobsPurge, PermJobsSchedule,
+}
+
+// superRole holds every permission whatever its row says.
+const superRole = "admin"
+
+// permissionCacheTTL bounds how long an instance acts on role definitions it
+// has not re-read. Edits made on this instance apply at once; those made on
+// another within this long.
+const permissionCacheTTL = 30 * time.Second
+
+var (
+	// ErrUnknownPermission is returned when a role is given a permission not
+	// in AllPermissions.
+	ErrUnknownPermission = errors.New("unknown permission")
+	// ErrRoleFixed is returned when editing admin or director.
+	ErrRoleFixed = errors.New("this role's permissions cannot be changed")
+	// ErrRoleExists is returned when creating a role whose name is taken.
+	ErrRoleExists = errors.New("a role with this name already exists")
+	// ErrRoleNotFound is returned when editing a role that does not exist.
+	ErrRoleNotFound = errors.New("role not found")
+	// ErrRoleName is returned for a role name that is not a short lowercase
+	// identifier.
+	ErrRoleName = errors.New("role names are 2-32 lowercase letters, digits or underscores")
+)
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{1,31}$`)
+
+// RoleDef is a role as the role editor shows it.
+type RoleDef struct {
+	Name        string
+	Description string
+	Permissions []string
+	// Fixed roles (admin, director) cannot be edited.
+	Fixed bool
+	// Users counts the accounts holding the role.
+	Users int
+}
+
+// Has reports whether the role holds perm.
+func (d RoleDef) Has(perm string) bool {
+	return d.Name == superRole || slices.Contains(d.Permissions, perm)
+}
+
+func knownPermission(perm string) bool { return slices.Contains(AllPermissions, perm) }
+
+// permissionCache is the role definitions as last read.
+type permissionCache struct {
+	mu     sync.Mutex
+	byRole map[string]map[string]bool
+	loaded time.Time
+}
+
+// rolePermissions returns the role definitions, re-reading them when the
+// cache is older than permissionCacheTTL. A failed read keeps serving what
+// was read before; with nothing read yet, nobody but admin holds anything.
+func (m *Module) rolePermissions(ctx context.Context) map[string]map[string]bool {
+	m.perms.mu.Lock()
+	defer m.perms.mu.Unlock()
+	if m.perms.byRole != nil && time.Since(m.perms.loaded) < permissionCacheTTL {
+		return m.perms.byRole
+	}
+	if m.store == nil || m.store.db == nil {
+		return m.perms.byRole
+	}
+	defs, err := m.store.ListRoleDefs(ctx)
+	if err != nil {
+		if m.rt != nil {
+			m.rt.Logger.Warn("load role permissions", zap.Error(err))
+		}
+		return m.perms.byRole
+	}
+	byRole := make(map[string]map[string]bool, len(defs))
+	for _, d := range defs {
+		set := make(map[string]bool, len(d.Permissions))
+		for _, p := range d.Permissions {
+			set[p] = true
+		}
+		byRole[d.Name] = set
+	}
+	m.perms.byRole, m.perms.loaded = byRole, time.Now()
+	return byRole
+}
+
+func (m *Module) forgetRolePermissions() {
+	m.perms.mu.Lock()
+	m.perms.loaded = time.Time{}
+	m.perms.mu.Unlock()
+}
+
+// Can reports whether the holder of claims has perm through any of their
+// roles. It trusts the roles in the token; the guards below also confirm the
+// account still backs them.
+func (m *Module) Can(ctx context.Context, claims *Claims, perm string) bool {
+	if claims == nil {
+		return false
+	}
+	if claims.HasAnyRole(superRole) {
+		return true
+	}
+	byRole := m.rolePermissions(ctx)
+	for _, role := range append([]string{claims.PrimaryRole}, claims.Roles...) {
+		if byRole[role][perm] {
+			return true
+		}
+	}
+	return false
+}
+
+// PermissionsOf is every permission claims hold, for pages that show or hide
+// what the viewer may use.
+func (m *Module) PermissionsOf(ctx context.Context, claims *Claims) map[string]bool {
+	out := make(map[string]bool)
+	for _, perm := range AllPermissions {
+		if m.Can(ctx, claims, perm) {
+			out[perm] = true
+		}
+	}
+	return out
+}
+
+// RequirePermission guards an API route: the caller must hold perm, and the
+// account must still back the token it came with. Answers 401 and 403 as
+// RequireRoles does.
+func (m *Module) RequirePermission(perm string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := ClaimsFromContext(r.Context())
+			if !ok {
+				var err error
+				claims, err = m.authenticateRequest(r)
+				if err != nil {
+					respond.Error(w, http.StatusUnauthorized, err)
+					return
+				}
+			}
+			if !m.Can(r.Context(), claims, perm) {
+				respond.Error(w, http.StatusForbidden, fmt.Errorf("missing permission %s", perm))
+				return
+			}
+			if !verifiedInContext(r.Context()) && !m.tokenStillValid(r.Context(), claims) {
+				respond.Error(w, http.StatusUnauthorized, ErrTokenRevoked)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(contextVerified(ContextWithClaims(r.Context(), claims))))
+		})
+	}
+}
+
+// RequireSessionPermission is RequirePermission for pages signed in by
+// cookie: a visitor without a session, or without perm, is sent to loginPath,
+// as RequireSession does.
+func (m *Module) RequireSessionPermission(loginPath, perm string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := ClaimsFromContext(r.Context())
+			if !ok {
+				claims, ok = m.claimsFromCookie(r)
+			}
+			if !ok {
+				http.Redirect(w, r, loginPath+"?reason=session_expired", http.StatusSeeOther)
+				return
+			}
+			if !m.Can(r.Context(), claims, perm) {
+				http.Redirect(w, r, loginPath, http.StatusSeeOther)
+				return
+			}
+			if !verifiedInContext(r.Context()) && !m.tokenStillValid(r.Context(), claims) {
+				ClearSessionCookie(w, r)
+				http.Redirect(w, r, loginPath+"?reason=session_expired", http.StatusSeeOther)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(contextVerified(ContextWithClaims(r.Context(), claims))))
+		})
+	}
+}
+
+// verifiedContextKey marks a request whose token a guard has already held to
+// the account, so a second guard on the same route does not ask again.
+const verifiedContextKey contextKey = "shanraq/auth.verified"
+
+func contextVerified(ctx context.Context) context.Context {
+	return context.WithValue(ctx, verifiedContextKey, true)
+}
+
+func verifiedInContext(ctx context.Context) bool {
+	v, _ := ctx.Value(verifiedContextKey).(bool)
+	return v
+}
+
+// RoleDefs lists the roles with their permissions and holders.
+func (m *Module) RoleDefs(ctx context.Context) ([]RoleDef, error) {
+	return m.store.ListRoleDefs(ctx)
+}
+
+// SetRolePermissions replaces what role may do. It applies on this instance
+// at once and on the others within permissionCacheTTL.
+func (m *Module) SetRolePermissions(ctx context.Context, role string, perms []string) error {
+	if err := m.store.SetRolePermissions(ctx, role, perms); err != nil {
+		return err
+	}
+	m.forgetRolePermissions()
+	return nil
+}
+
+// CreateRole adds a role with the given permissions.
+func (m *Module) CreateRole(ctx context.Context, name, description string, perms []string) error {
+	if err := m.store.CreateRole(ctx, name, description, perms); err != nil {
+		return err
+	}
+	m.forgetRolePermissions()
+	return nil
+}
+
+// ListRoleDefs returns every role, fixed ones first, then by name.
+func (s *Store) ListRoleDefs(ctx context.Context) ([]RoleDef, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT r.name, COALESCE(r.description, ''),
+		       COALESCE((SELECT array_agg(p.permission ORDER BY p.permission)
+		                 FROM auth_role_permissions p WHERE p.role_id = r.id), '{}'),
+		       (SELECT count(*) FROM auth_user_roles ur WHERE ur.role_id = r.id)
+		FROM auth_roles r
+		ORDER BY r.name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("list roles: %w", err)
+	}
+	defer rows.Close()
+
+	var out []RoleDef
+	for rows.Next() {
+		var d RoleDef
+		if err := rows.Scan(&d.Name, &d.Description, &d.Permissions, &d.Users); err != nil {
+			return nil, fmt.Errorf("scan role: %w", err)
+		}
+		d.Fixed = isRootRole(d.Name)
+		out = append(out, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Fixed && !out[j].Fixed })
+	return out, nil
+}
+
+// cleanPermissions validates perms for an editable role and drops repeats.
+func cleanPermissions(perms []string) ([]string, error) {
+	var out []string
+	for _, p := range perms {
+		p = strings.TrimSpace(p)
+		if p == "" || slices.Contains(out, p) {
+			continue
+		}
+		if !knownPermission(p) || p == PermRolesManage {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownPermission, p)
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// SetRolePermissions replaces the permissions of an editable role.
+func (s *Store) SetRolePermissions(ctx context.Context, role string, perms []string) error {
+	role = strings.TrimSpace(strings.ToLower(role))
+	if isRootRole(role) {
+		return ErrRoleFixed
+	}
+	clean, err := cleanPermissions(perms)
+	if err != nil {
+		return err
+	}
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin role permissions tx: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck
+
+	var roleID uuid.UUID
+	err = tx.QueryRow(ctx, `SELECT id FROM auth_roles WHERE name = $1`, role).Scan(&roleID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrRoleNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("find role: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM auth_role_permissions WHERE role_id = $1`, roleID); err != nil {
+		return fmt.Errorf("clear role permissions: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO auth_role_permissions (role_id, permission)
+		SELECT $1, unnest($2::text[])
+	`, roleID, clean); err != nil {
+		return fmt.Errorf("set role permissions: %w", err)
+	}
+	return tx.Commit(ctx)
+}
+
+// CreateRole adds an editable role.
+func (s *Store) CreateRole(ctx context.Context, name, description string, perms []string) error {
+	name = strings.TrimSpace(strings.ToLower(name))
+	if !roleNamePattern.MatchString(name) {
+		return ErrRoleName
+	}
+	clean, err := cleanPermissions(perms)
+	if err != nil {
+		return err
+	}
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin create role tx: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck
+
+	var roleID uuid.UUID
+	err = tx.QueryRow(ctx, `
+		INSERT INTO auth_roles (name, description)
+		VALUES ($1, NULLIF($2, ''))
+		ON CONFLICT (name) DO NOTHING
+		RETURNING id
+	`, name, strings.TrimSpace(description)).Scan(&roleID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrRoleExists
+	}
+	if err != nil {
+		return fmt.Errorf("create role: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO auth_role_permissions (role_id, permission)
+		SELECT $1, unnest($2::text[])
+	`, roleID, clean); err != nil {
+		return fmt.Errorf("set role permissions: %w", err)
+	}
+	return tx.Commit(ctx)
+}
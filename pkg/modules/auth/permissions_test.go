@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// moduleWithRoles is a module whose role definitions are already read, so
+// Can never reaches for a store.
+func moduleWithRoles(byRole map[string]map[string]bool) *Module {
+	m := &Module{}
+	m.perms.byRole, m.perms.loaded = byRole, time.Now()
+	return m
+}
+
+func TestCanReadsRoleDefinitions(t *testing.T) {
+	m := moduleWithRoles(map[string]map[string]bool{
+		"editor":  {PermAdminView: true, PermCommentsHide: true},
+		"support": {PermUsersManage: true},
+	})
+	ctx := context.Background()
+	editor := &Claims{PrimaryRole: "editor", Roles: []string{"editor"}}
+	both := &Claims{PrimaryRole: "user", Roles: []string{"user", "editor", "support"}}
+	admin := &Claims{PrimaryRole: "admin", Roles: []string{"admin"}}
+
+	cases := []struct {
+		name   string
+		claims *Claims
+		perm   string
+		want   bool
+	}{
+		{"a held permission", editor, PermCommentsHide, true},
+		{"one the role lacks", editor, PermTariffsEdit, false},
+		{"through a secondary role", both, PermUsersManage, true},
+		{"admin holds what no row names", admin, PermConfigReload, true},
+		{"nobody signed in", nil, PermAdminView, false},
+		{"a role with no definition", &Claims{PrimaryRole: "user"}, PermAdminView, false},
+	}
+	for _, c := range cases {
+		if got := m.Can(ctx, c.claims, c.perm); got != c.want {
+			t.Errorf("%s: Can(%s) = %v, want %v", c.name, c.perm, got, c.want)
+		}
+	}
+	if got := m.PermissionsOf(ctx, editor); !got[PermAdminView] || !got[PermCommentsHide] || got[PermUsersManage] {
+		t.Errorf("PermissionsOf(editor) = %v", got)
+	}
+}
+
+// A refusal is decided before the database is asked anything, and a request
+// already verified by an outer guard is not verified twice.
+func TestRequirePermission(t *testing.T) {
+	m := moduleWithRoles(map[string]map[string]bool{"editor": {PermCommentsHide: true}})
+	editor := &Claims{PrimaryRole: "editor", Roles: []string{"editor"}}
+	editor.Subject = uuid.NewString()
+	guarded := func(perm string) http.Handler {
+		return m.RequirePermission(perm)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		}))
+	}
+	call := func(perm string, verified bool) int {
+		ctx := ContextWithClaims(context.Background(), editor)
+		if verified {
+			ctx = contextVerified(ctx)
+		}
+		rec := httptest.NewRecorder()
+		guarded(perm).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/jobs", nil).WithContext(ctx))
+		return rec.Code
+	}
+
+	if got := call(PermJobsPurge, true); got != http.StatusForbidden {
+		t.Errorf("a permission the role lacks: %d, want 403", got)
+	}
+	if got := call(PermCommentsHide, true); got != http.StatusTeapot {
+		t.Errorf("a held permission on a verified request: %d, want the handler", got)
+	}
+	// No store to confirm the account against: refuse rather than trust the
+	// token's roles.
+	if got := call(PermCommentsHide, false); got != http.StatusUnauthorized {
+		t.Errorf("an unverified token with nothing to check it against: %d, want 401", got)
+	}
+}
+
+func TestCleanPermissions(t *testing.T) {
+	got, err := cleanPermissions([]string{PermAdminView, " " + PermAdminView, "", PermJobsView})
+	if err != nil || len(got) != 2 || got[0] != PermAdminView || got[1] != PermJobsView {
+		t.Fatalf("cleanPermissions = %v, %v", got, err)
+	}
+	for _, bad := range []string{"admin.everything", PermRolesManage} {
+		if _, err := cleanPermissions([]string{bad}); !errors.Is(err, ErrUnknownPermission) {
+			t.Errorf("cleanPermissions(%q) err = %v, want ErrUnknownPermission", bad, err)
+		}
+	}
+}
+
+func TestRoleDefHas(t *testing.T) {
+	if !(RoleDef{Name: "admin"}).Has(PermJobsPurge) {
+		t.Error("admin must hold every permission whatever its row says")
+	}
+	editor := RoleDef{Name: "editor", Permissions: []string{PermCommentsHide}}
+	if !editor.Has(PermCommentsHide) || editor.Has(PermUsersManage) {
+		t.Errorf("editor.Has is wrong for %v", editor.Permissions)
+	}
+}
+
+// Against the real tables: an edit applies on this instance at once, root
+// roles cannot be edited, and a taken name is refused.
+func TestRoleEditsApply(t *testing.T) {
+	pool := revocationPool(t)
+	ctx := context.Background()
+	m := &Module{store: NewStore(pool)}
+	name := "t_" + uuid.NewString()[:8]
+	t.Cleanup(func() { _, _ = pool.Exec(ctx, `DELETE FROM auth_roles WHERE name=$1`, name) })
+
+	if err := m.CreateRole(ctx, name, "test", []string{PermAdminView}); err != nil {
+		t.Fatal(err)
+	}
+	claims := &Claims{PrimaryRole: name, Roles: []string{name}}
+	if !m.Can(ctx, claims, PermAdminView) || m.Can(ctx, claims, PermJobsView) {
+		t.Fatal("a new role does not hold exactly what it was created with")
+	}
+	if err := m.SetRolePermissions(ctx, name, []string{PermJobsView}); err != nil {
+		t.Fatal(err)
+	}
+	if m.Can(ctx, claims, PermAdminView) || !m.Can(ctx, claims, PermJobsView) {
+		t.Fatal("an edit did not apply on the instance that made it")
+	}
+
+	if err := m.SetRolePermissions(ctx, "director", nil); !errors.Is(err, ErrRoleFixed) {
+		t.Errorf("editing director: %v, want ErrRoleFixed", err)
+	}
+	if err := m.CreateRole(ctx, name, "", nil); !errors.Is(err, ErrRoleExists) {
+		t.Errorf("creating a taken name: %v, want ErrRoleExists", err)
+	}
+	if err := m.SetRolePermissions(ctx, "nope_"+name, nil); !errors.Is(err, ErrRoleNotFound) {
+		t.Errorf("editing a missing role: %v, want ErrRoleNotFound", err)
+	}
+}
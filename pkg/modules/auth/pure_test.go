@@ -1,7 +1,9 @@
 package auth
 
 import (
+	"context"
 	"encoding/base64"
+	"net"
 	"net/http/httptest"
 	"net/url"
 	"strings"
@@ -9,7 +11,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
-	"golang.org/x/time/rate"
+	"shanraq.org/internal/config"
 )
 
 // ---- token / request helpers ----
@@ -79,38 +81,38 @@ func TestClientIdentifier(t *testing.T) {
 
 func TestMemoryRateLimiterBurstAndKeys(t *testing.T) {
 	rl := newMemoryRateLimiter(map[string]rateLimitRule{
-		// One token per hour, burst of 2: two immediate calls, then blocked.
-		"otp": {limit: rate.Every(time.Hour), burst: 2},
+		// Two per hour: two immediate calls, then blocked.
+		"otp": {limit: 2, window: time.Hour},
 	})
 	if !rl.Allow("otp", "user-a") || !rl.Allow("otp", "user-a") {
 		t.Fatal("first two calls within burst must be allowed")
 	}
 	if rl.Allow("otp", "user-a") {
-		t.Error("third call must be denied (burst exhausted)")
+		t.Error("third call must be denied (limit reached)")
 	}
-	// A different key has its own bucket.
+	// A different key has its own count.
 	if !rl.Allow("otp", "user-b") {
 		t.Error("a different key must not be affected")
 	}
-	// An empty key collapses to a single shared "anonymous" bucket.
+	// An empty key collapses to a single shared "anonymous" key.
 	if !rl.Allow("otp", "") {
 		t.Error("first anonymous call allowed")
 	}
 }
 
 func TestMemoryRateLimiterDefaultsAndUnlimited(t *testing.T) {
-	// Unknown action falls back to the "default" rule (burst 20).
+	// Unknown action falls back to the "default" rule (20 per 10s).
 	rl := newMemoryRateLimiter(nil)
 	for i := 0; i < 20; i++ {
 		if !rl.Allow("totally-unknown-action", "k") {
-			t.Fatalf("call %d under default burst should be allowed", i+1)
+			t.Fatalf("call %d under the default limit should be allowed", i+1)
 		}
 	}
 	if rl.Allow("totally-unknown-action", "k") {
-		t.Error("21st call should exceed the default burst")
+		t.Error("21st call should exceed the default limit")
 	}
 	// A rule with a non-positive limit means unlimited.
-	rl2 := newMemoryRateLimiter(map[string]rateLimitRule{"free": {limit: 0, burst: 0}})
+	rl2 := newMemoryRateLimiter(map[string]rateLimitRule{"free": {limit: 0}})
 	for i := 0; i < 100; i++ {
 		if !rl2.Allow("free", "k") {
 			t.Fatal("a zero-limit rule must always allow")
@@ -125,8 +127,185 @@ func TestDefaultRateLimitRules(t *testing.T) {
 			t.Errorf("default rules missing %q", action)
 		}
 	}
-	if rules["signup"].burst != 3 {
-		t.Errorf("signup burst = %d, want 3", rules["signup"].burst)
+	if rules["signup"].limit != 3 {
+		t.Errorf("signup limit = %d, want 3", rules["signup"].limit)
+	}
+}
+
+// The window slides: half-way into the next window, half of the previous
+// one's requests still count.
+func TestSlidingWindowWeighsThePreviousWindow(t *testing.T) {
+	rl := newMemoryRateLimiter(map[string]rateLimitRule{"x": {limit: 10, window: time.Minute}})
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 10; i++ {
+		if !rl.allowAt(start.Add(time.Duration(i)*time.Second), "x", "k") {
+			t.Fatalf("request %d refused", i+1)
+		}
+	}
+	if rl.allowAt(start.Add(59*time.Second), "x", "k") {
+		t.Fatal("eleventh request in the window allowed")
+	}
+	// 30s into the next window: 10 × 0.5 still count, so five more fit.
+	mid := start.Add(90 * time.Second)
+	for i := 0; i < 5; i++ {
+		if !rl.allowAt(mid, "x", "k") {
+			t.Fatalf("request %d after the window moved was refused", i+1)
+		}
+	}
+	if rl.allowAt(mid, "x", "k") {
+		t.Fatal("the previous window was forgotten at once: its edge can be burst across")
+	}
+}
+
+// A key that keeps hitting the limit is locked out, for twice as long each
+// time, up to the cap; a quiet stretch as long as the cap forgives it.
+func TestLockoutEscalates(t *testing.T) {
+	rule := rateLimitRule{limit: 1, window: time.Minute}
+	lock := lockoutRule{after: 3, base: time.Minute, max: 10 * time.Minute}
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	var st rateState
+
+	if decide(now, rule, lock, &st) != rateAllowed {
+		t.Fatal("first request refused")
+	}
+	for i := 1; i <= 2; i++ {
+		if decide(now, rule, lock, &st) != rateRefused || !st.lockedUntil.IsZero() {
+			t.Fatalf("refusal %d locked the key out before the threshold", i)
+		}
+	}
+	if decide(now, rule, lock, &st) != rateRefused || !st.lockedUntil.Equal(now.Add(time.Minute)) {
+		t.Fatalf("third refusal: locked until %v, want a minute", st.lockedUntil)
+	}
+	if decide(now.Add(30*time.Second), rule, lock, &st) != rateLocked || st.strikes != 3 {
+		t.Fatal("a request inside the lockout was not refused, or counted as a strike")
+	}
+	want := []time.Duration{2 * time.Minute, 4 * time.Minute, 8 * time.Minute, 10 * time.Minute, 10 * time.Minute}
+	for _, d := range want {
+		now = st.lockedUntil
+		decide(now, rule, lock, &st)
+		if !st.lockedUntil.Equal(now.Add(d)) {
+			t.Fatalf("strike %d: locked for %v, want %v", st.strikes, st.lockedUntil.Sub(now), d)
+		}
+	}
+
+	now = st.lockedUntil.Add(11 * time.Minute)
+	st.prev, st.cur = 0, 1
+	if decide(now, rule, lock, &st) != rateRefused || st.strikes != 1 || !st.lockedUntil.Before(now) {
+		t.Fatalf("strikes not forgiven after a quiet stretch: %d, locked until %v", st.strikes, st.lockedUntil)
+	}
+}
+
+func TestRateLimitsFromConfig(t *testing.T) {
+	rules, lock := rateLimitsFrom(config.RateLimitConfig{
+		Rules: map[string]config.RateLimitRule{
+			"signin":  {Limit: 3, Window: 5 * time.Minute},
+			"webhook": {Limit: 100, Window: time.Minute},
+		},
+		Lockout: config.LockoutConfig{After: 4, Base: time.Minute, Max: time.Hour},
+	})
+	if rules["signin"] != (rateLimitRule{limit: 3, window: 5 * time.Minute}) {
+		t.Errorf("signin = %+v, want the configured rule", rules["signin"])
+	}
+	if rules["webhook"].limit != 100 || rules["signup"].limit != 3 {
+		t.Errorf("a configured action was not added, or a default was lost: %+v", rules)
+	}
+	if lock != (lockoutRule{after: 4, base: time.Minute, max: time.Hour}) {
+		t.Errorf("lockout = %+v", lock)
+	}
+}
+
+// A reload that tightens the sign-in limit and turns lockouts on applies to the
+// next request, without a restart and without forgetting the counts so far.
+func TestReconfigureSwapsRateLimits(t *testing.T) {
+	var running config.Config
+	rules, lock := rateLimitsFrom(running.Auth.RateLimit)
+	rl := newMemoryRateLimiterWithLockout(rules, lock)
+	m := &Module{rateLimiter: rl}
+	for i := 0; i < 2; i++ {
+		if !m.rateLimiter.Allow("signin", "203.0.113.7") {
+			t.Fatalf("sign-in %d refused under the default limit", i+1)
+		}
+	}
+
+	next := running
+	next.Auth.RateLimit.Rules = map[string]config.RateLimitRule{"signin": {Limit: 2, Window: time.Minute}}
+	next.Auth.RateLimit.Lockout = config.LockoutConfig{After: 1, Base: time.Hour, Max: time.Hour}
+	if err := m.Reconfigure(context.Background(), next, config.Diff(running, next)); err != nil {
+		t.Fatalf("reconfigure: %v", err)
+	}
+	if m.rateLimiter.Allow("signin", "203.0.113.7") {
+		t.Fatal("a third sign-in was allowed under the new limit of two")
+	}
+	list, _ := rl.Throttled(context.Background())
+	if len(list) != 1 || !list[0].Locked() {
+		t.Fatalf("the new lockout was not applied: %+v", list)
+	}
+
+	// A reload that leaves the rate limits alone leaves them alone.
+	other := next
+	other.Logging.Level = "debug"
+	rl.setLimits(defaultRateLimitRules(), lockoutRule{})
+	if err := m.Reconfigure(context.Background(), other, config.Diff(next, other)); err != nil {
+		t.Fatalf("reconfigure: %v", err)
+	}
+	if ruleFor(rl.rules, "signin").limit != 8 {
+		t.Fatalf("an unrelated reload replaced the rules: %+v", rl.rules["signin"])
+	}
+}
+
+// Only an address is locked out. An e-mail or an account, which anyone can
+// name, is held to its window however often it is refused: a stranger's burst
+// must not lock its owner out of sign-in.
+func TestOnlyAddressesAreLockedOut(t *testing.T) {
+	lock := lockoutRule{after: 2, base: time.Minute, max: time.Hour}
+	rl := newMemoryRateLimiterWithLockout(map[string]rateLimitRule{"signin": {limit: 1, window: time.Minute}}, lock)
+	now := time.Now()
+	for _, key := range []string{"reader@example.kz", uuid.NewString(), "203.0.113.9", "2001:db8::1"} {
+		for i := 0; i < 20; i++ {
+			rl.allowAt(now.Add(time.Duration(i)*time.Second), "signin", key)
+		}
+		e := rl.entries["signin:"+key]
+		if e == nil || e.strikes < lock.after {
+			t.Fatalf("%s: entry = %+v, want its refusals counted", key, e)
+		}
+		if ip := net.ParseIP(key) != nil; ip == e.lockedUntil.IsZero() {
+			t.Errorf("%s: locked until %v after %d strikes", key, e.lockedUntil, e.strikes)
+		}
+	}
+	if lockoutFor("reader@example.kz", lock) != (lockoutRule{}) || lockoutFor("203.0.113.9", lock) != lock {
+		t.Error("lockoutFor applies the lockout to the wrong keys")
+	}
+}
+
+// Addresses and e-mails are shown to the administrator; a reset token, which
+// is a secret, is not.
+func TestThrottleLabel(t *testing.T) {
+	for _, key := range []string{"203.0.113.9", "2001:db8::1", "203.0.113.7", uuid.NewString()} {
+		if got := throttleLabel(key); got != key {
+			t.Errorf("throttleLabel(%q) = %q", key, got)
+		}
+	}
+	if got := throttleLabel("s3cr3t-reset-token"); got != "#"+hashKey("s3cr3t-reset-token") {
+		t.Errorf("a secret key was shown as %q", got)
+	}
+}
+
+func TestMemoryRateLimiterThrottledAndUnblock(t *testing.T) {
+	ctx := context.Background()
+	rl := newMemoryRateLimiter(map[string]rateLimitRule{"signin": {limit: 1, window: time.Hour}})
+	rl.Allow("signin", "203.0.113.7")
+	if rl.Allow("signin", "203.0.113.7") {
+		t.Fatal("second sign-in allowed")
+	}
+	list, _ := rl.Throttled(ctx)
+	if len(list) != 1 || list[0].Key != "203.0.113.7" || list[0].Strikes != 1 {
+		t.Fatalf("throttled = %+v", list)
+	}
+	if err := rl.Unblock(ctx, "signin", list[0].KeyHash); err != nil {
+		t.Fatal(err)
+	}
+	if !rl.Allow("signin", "203.0.113.7") {
+		t.Fatal("the key is still refused after it was unblocked")
 	}
 }
 
@@ -1,10 +1,15 @@
 package auth
 
 import (
+	"context"
+	"net"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
-	"golang.org/x/time/rate"
+	"github.com/google/uuid"
+	"shanraq.org/internal/config"
 )
 
 // RateLimiter controls how frequently sensitive auth operations can run.
@@ -12,85 +17,307 @@ type RateLimiter interface {
 	Allow(action, key string) bool
 }
 
+// limitSetter is implemented by the built-in limiters, whose rules a config
+// reload replaces (see Module.Reconfigure).
+type limitSetter interface {
+	setLimits(rules map[string]rateLimitRule, lock lockoutRule)
+}
+
+// ThrottleAdmin is implemented by limiters that can say whom they are turning
+// away and be told to stop. Both built-in limiters are; one supplied through
+// WithRateLimiter need not be, and the admin view is then empty.
+type ThrottleAdmin interface {
+	Throttled(ctx context.Context) ([]Throttle, error)
+	Unblock(ctx context.Context, action, keyHash string) error
+}
+
+// Throttled lists the keys the rate limiter is refusing right now; nil when
+// the limiter cannot tell.
+func (m *Module) Throttled(ctx context.Context) ([]Throttle, error) {
+	admin, ok := m.rateLimiter.(ThrottleAdmin)
+	if !ok {
+		return nil, nil
+	}
+	return admin.Throttled(ctx)
+}
+
+// Unthrottle lets the key (by its hash, as Throttled reports it) through for
+// the action again, lockout and all.
+func (m *Module) Unthrottle(ctx context.Context, action, keyHash string) error {
+	admin, ok := m.rateLimiter.(ThrottleAdmin)
+	if !ok {
+		return nil
+	}
+	return admin.Unblock(ctx, action, keyHash)
+}
+
+// Reconfigure applies new rate-limit rules and lockout on reload, to a
+// built-in limiter; one supplied through WithRateLimiter keeps its own.
+func (m *Module) Reconfigure(_ context.Context, cfg config.Config, changes config.Changes) error {
+	if !changes.Touches("auth.rate_limit") {
+		return nil
+	}
+	if setter, ok := m.rateLimiter.(limitSetter); ok {
+		setter.setLimits(rateLimitsFrom(cfg.Auth.RateLimit))
+	}
+	return nil
+}
+
+// Throttle is a key the limiter is currently refusing for an action.
+type Throttle struct {
+	Action string
+	// Key is what is limited — an address, an e-mail, an account — or, for
+	// keys that are secrets themselves (a reset token), the start of its
+	// hash. KeyHash is what Unblock takes, and what the rate-limit warnings
+	// in the log carry.
+	Key     string
+	KeyHash string
+	// Strikes counts the refusals in a row; LockedUntil is zero unless they
+	// have earned a lockout. BlockedUntil is when the key is let through
+	// again if it stops trying, at the latest.
+	Strikes      int
+	LastRefused  time.Time
+	LockedUntil  time.Time
+	BlockedUntil time.Time
+}
+
+// Locked reports whether the key is serving a lockout, as opposed to merely
+// being over its window's limit.
+func (t Throttle) Locked() bool { return t.LockedUntil.After(time.Now()) }
+
+// rateLimitRule allows limit requests in any window. A limit of zero or less
+// means unlimited.
 type rateLimitRule struct {
-	limit rate.Limit
-	burst int
+	limit  int
+	window time.Duration
 }
 
 func defaultRateLimitRules() map[string]rateLimitRule {
 	return map[string]rateLimitRule{
-		"default":                {limit: rate.Every(500 * time.Millisecond), burst: 20}, // ~120 req/min
-		"signin":                 {limit: rate.Every(time.Minute / 8), burst: 5},         // 8 attempts/min
-		"signup":                 {limit: rate.Every(time.Minute / 3), burst: 3},         // 3 attempts/min
-		"password_reset":         {limit: rate.Every(time.Minute / 4), burst: 3},         // 4 attempts/min
-		"password_reset_confirm": {limit: rate.Every(time.Minute / 4), burst: 5},         // 4 attempts/min
-		"mfa_verify":             {limit: rate.Every(time.Minute / 6), burst: 6},         // 10 attempts/min approx
-		// A listing carries up to fifteen photos, so the burst has to clear one
-		// form in one go; the refill is what stops a script from doing it all
-		// night. This bounds the rate, not the total — a storage quota is the
-		// other half and does not live here.
-		"media_upload": {limit: rate.Every(3 * time.Second), burst: 20}, // 20 at once, then 20/min
+		"default":                {limit: 20, window: 10 * time.Second}, // ~120 req/min
+		"signin":                 {limit: 8, window: time.Minute},
+		"signup":                 {limit: 3, window: time.Minute},
+		"password_reset":         {limit: 4, window: time.Minute},
+		"password_reset_confirm": {limit: 5, window: time.Minute},
+		"mfa_verify":             {limit: 6, window: time.Minute},
+		// A sign-in mail costs a delivery and lands in someone's inbox, so
+		// an address gets three per five minutes; the codes typed back are
+		// also held by the per-mail attempt count.
+		"email_signin": {limit: 3, window: 5 * time.Minute},
+		"email_code":   {limit: 6, window: time.Minute},
+		// A passkey sign-in fetches a challenge and answers it: two requests
+		// per attempt, where a password costs one.
+		"passkey": {limit: 16, window: time.Minute}, // 8 attempts/min
+		// A listing carries up to fifteen photos, so the limit has to clear
+		// one form in one go; the window is what stops a script from doing it
+		// all night. This bounds the rate, not the total — a storage quota is
+		// the other half and does not live here.
+		"media_upload": {limit: 20, window: time.Minute},
+	}
+}
+
+// lockoutRule escalates against keys that keep hitting the limit: from the
+// after-th refusal in a row on, each refusal locks the key out for base,
+// doubled per refusal since and capped at max. after of zero disables it.
+type lockoutRule struct {
+	after int
+	base  time.Duration
+	max   time.Duration
+}
+
+// lockoutFor is the lockout that applies to key: lock for an address, none
+// for anything else. An e-mail, an account ID or a token is a key a stranger
+// can name and spend for its owner; were it locked out, a burst of attempts
+// from anywhere would shut the owner out of their own sign-in for an hour.
+// Those keys keep the sliding window alone, and the strikes still show in
+// the admin view.
+func lockoutFor(key string, lock lockoutRule) lockoutRule {
+	if net.ParseIP(key) == nil {
+		return lockoutRule{}
 	}
+	return lock
 }
 
+// duration is the lockout earned by the strikes-th refusal in a row.
+func (l lockoutRule) duration(strikes int) time.Duration {
+	d := l.base
+	for i := l.after; i < strikes && d < l.max; i++ {
+		d *= 2
+	}
+	if d > l.max {
+		d = l.max
+	}
+	return d
+}
+
+// rateLimitsFrom is the configured rules laid over the built-in ones, and the
+// lockout.
+func rateLimitsFrom(cfg config.RateLimitConfig) (map[string]rateLimitRule, lockoutRule) {
+	rules := defaultRateLimitRules()
+	for action, r := range cfg.Rules {
+		rules[action] = rateLimitRule{limit: r.Limit, window: r.Window}
+	}
+	return rules, lockoutRule{after: cfg.Lockout.After, base: cfg.Lockout.Base, max: cfg.Lockout.Max}
+}
+
+func ruleFor(rules map[string]rateLimitRule, action string) rateLimitRule {
+	if rule, ok := rules[action]; ok {
+		return rule
+	}
+	return rules["default"]
+}
+
+// rateState is what a limiter remembers of one (action, key): the requests
+// let through in the current and the previous window, and the refusals.
+type rateState struct {
+	prev, cur    int
+	strikes      int
+	lastStrike   time.Time
+	lockedUntil  time.Time
+	blockedUntil time.Time
+}
+
+type rateOutcome int
+
+const (
+	rateAllowed rateOutcome = iota
+	rateRefused             // over the window's limit; a strike was recorded
+	rateLocked              // inside a lockout; nothing was recorded
+)
+
+// decide applies one request at now to st, which holds the counts of the
+// window now falls in and the one before it.
+//
+// The window slides: the previous window's count is weighted by how much of
+// it still overlaps the last window-length of time. Unlike a fixed window it
+// has no edge to burst across, and unlike a log of timestamps it costs two
+// counters per key. Refused requests are not counted — a person who was
+// turned away gets through again as soon as the window moves on — but they
+// are what lockouts are made of, and a key that keeps going is locked out
+// for longer each time.
+func decide(now time.Time, rule rateLimitRule, lock lockoutRule, st *rateState) rateOutcome {
+	if now.Before(st.lockedUntil) {
+		return rateLocked
+	}
+	elapsed := float64(now.Sub(now.Truncate(rule.window))) / float64(rule.window)
+	if float64(st.prev)*(1-elapsed)+float64(st.cur)+1 <= float64(rule.limit) {
+		st.cur++
+		return rateAllowed
+	}
+
+	forgive := lock.max
+	if forgive <= 0 {
+		forgive = rule.window
+	}
+	if !st.lastStrike.IsZero() && now.Sub(st.lastStrike) > forgive {
+		st.strikes = 0
+	}
+	st.strikes++
+	st.lastStrike = now
+	st.blockedUntil = now.Add(rule.window)
+	if lock.after > 0 && st.strikes >= lock.after {
+		st.lockedUntil = now.Add(lock.duration(st.strikes))
+		if st.lockedUntil.After(st.blockedUntil) {
+			st.blockedUntil = st.lockedUntil
+		}
+	}
+	return rateRefused
+}
+
+// throttleLabel is how a key is shown to an administrator: addresses, e-mails
+// and IDs as they are, anything else — a reset token, say — only by its hash.
+func throttleLabel(key string) string {
+	if net.ParseIP(key) != nil || strings.Contains(key, "@") || key == "anonymous" {
+		return key
+	}
+	if _, err := uuid.Parse(key); err == nil {
+		return key
+	}
+	return "#" + hashKey(key)
+}
+
+type memoryEntry struct {
+	rateState
+	action      string
+	key         string
+	windowStart time.Time
+	lastSeen    time.Time
+}
+
+// memoryRateLimiter keeps the counters in the process: each instance has its
+// own, and a restart forgets them. It is the store for development, and the
+// fallback of the Postgres limiter while the database cannot be reached.
 type memoryRateLimiter struct {
 	mu          sync.Mutex
 	rules       map[string]rateLimitRule
-	buckets     map[string]*rate.Limiter
-	lastSeen    map[string]time.Time
+	lockout     lockoutRule
+	entries     map[string]*memoryEntry
 	ttl         time.Duration
 	nextCleanup time.Time
 }
 
-func newMemoryRateLimiter(rules map[string]rateLimitRule) RateLimiter {
+func newMemoryRateLimiter(rules map[string]rateLimitRule) *memoryRateLimiter {
+	return newMemoryRateLimiterWithLockout(rules, lockoutRule{})
+}
+
+func newMemoryRateLimiterWithLockout(rules map[string]rateLimitRule, lock lockoutRule) *memoryRateLimiter {
 	if len(rules) == 0 {
 		rules = defaultRateLimitRules()
 	}
 	return &memoryRateLimiter{
-		rules:    rules,
-		buckets:  make(map[string]*rate.Limiter),
-		lastSeen: make(map[string]time.Time),
-		ttl:      30 * time.Minute,
+		rules:   rules,
+		lockout: lock,
+		entries: make(map[string]*memoryEntry),
+		ttl:     30 * time.Minute,
 	}
 }
 
 func (m *memoryRateLimiter) Allow(action, key string) bool {
+	return m.allowAt(time.Now(), action, key)
+}
+
+func (m *memoryRateLimiter) allowAt(now time.Time, action, key string) bool {
 	if key == "" {
 		key = "anonymous"
 	}
-
-	now := time.Now()
 	composite := action + ":" + key
 
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	rule, ok := m.rules[action]
-	if !ok {
-		rule = m.rules["default"]
-	}
+	rule := ruleFor(m.rules, action)
 	if rule.limit <= 0 {
 		return true
 	}
-	if rule.burst <= 0 {
-		rule.burst = 1
-	}
 
-	limiter, ok := m.buckets[composite]
+	e, ok := m.entries[composite]
 	if !ok {
-		limiter = rate.NewLimiter(rule.limit, rule.burst)
-		m.buckets[composite] = limiter
+		e = &memoryEntry{action: action, key: key}
+		m.entries[composite] = e
 	}
-	m.lastSeen[composite] = now
-
-	allowed := limiter.Allow()
-	if allowed {
-		m.maybeCleanupLocked(now)
-		return true
+	start := now.Truncate(rule.window)
+	if !e.windowStart.Equal(start) {
+		if e.windowStart.Add(rule.window).Equal(start) {
+			e.prev = e.cur
+		} else {
+			e.prev = 0
+		}
+		e.cur = 0
+		e.windowStart = start
 	}
+	e.lastSeen = now
 
+	allowed := decide(now, rule, lockoutFor(key, m.lockout), &e.rateState) == rateAllowed
 	m.maybeCleanupLocked(now)
-	return false
+	return allowed
+}
+
+// setLimits replaces the rules and the lockout; the next request is judged by
+// them. Counts already recorded stand unless their action's window changed.
+func (m *memoryRateLimiter) setLimits(rules map[string]rateLimitRule, lock lockoutRule) {
+	m.mu.Lock()
+	m.rules, m.lockout = rules, lock
+	m.mu.Unlock()
 }
 
 func (m *memoryRateLimiter) maybeCleanupLocked(now time.Time) {
@@ -101,11 +328,51 @@ func (m *memoryRateLimiter) maybeCleanupLocked(now time.Time) {
 		return
 	}
 	cutoff := now.Add(-m.ttl)
-	for key, seen := range m.lastSeen {
-		if seen.Before(cutoff) {
-			delete(m.lastSeen, key)
-			delete(m.buckets, key)
+	for key, e := range m.entries {
+		if e.lastSeen.Before(cutoff) && !e.lockedUntil.After(now) {
+			delete(m.entries, key)
 		}
 	}
 	m.nextCleanup = now.Add(m.ttl)
 }
+
+// Throttled lists the keys this process is refusing, the longest-blocked
+// first.
+func (m *memoryRateLimiter) Throttled(context.Context) ([]Throttle, error) {
+	now := time.Now()
+	m.mu.Lock()
+	var out []Throttle
+	for _, e := range m.entries {
+		if !e.blockedUntil.After(now) {
+			continue
+		}
+		t := Throttle{
+			Action:       e.action,
+			Key:          throttleLabel(e.key),
+			KeyHash:      hashKey(e.key),
+			Strikes:      e.strikes,
+			LastRefused:  e.lastStrike,
+			BlockedUntil: e.blockedUntil,
+		}
+		if e.lockedUntil.After(now) {
+			t.LockedUntil = e.lockedUntil
+		}
+		out = append(out, t)
+	}
+	m.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].BlockedUntil.After(out[j].BlockedUntil) })
+	return out, nil
+}
+
+// Unblock forgets everything about the key for the action: its counts, its
+// strikes and its lockout.
+func (m *memoryRateLimiter) Unblock(_ context.Context, action, keyHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for composite, e := range m.entries {
+		if e.action == action && hashKey(e.key) == keyHash {
+			delete(m.entries, composite)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,263 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// The Postgres limiter: the same sliding windows and lockouts as the memory
+// one, kept in auth_rate_counters and auth_rate_lockouts so that every
+// instance counts against one budget and a deploy resets nothing.
+//
+// Each decision is one short transaction holding a per-key advisory lock, so
+// two instances deciding about the same key take turns instead of both
+// reading "one left". Keys are stored as hashKey — the same hash the
+// rate-limit warnings log — and only lockout rows carry a readable label, for
+// the admin view.
+
+// rateLimitQueryTimeout bounds one decision. Allow has no context of its own,
+// and a sign-in should not wait on a slow database to learn whether it may be
+// tried.
+const rateLimitQueryTimeout = 2 * time.Second
+
+// rateLimitCleanupInterval is how often an instance deletes counters and
+// lockouts nobody needs any more.
+const rateLimitCleanupInterval = 10 * time.Minute
+
+type postgresRateLimiter struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+	// fallback decides while the database cannot: limits then hold per
+	// instance, which is less than promised but more than none.
+	fallback *memoryRateLimiter
+
+	mu          sync.Mutex
+	rules       map[string]rateLimitRule
+	lockout     lockoutRule
+	nextCleanup time.Time
+}
+
+func newPostgresRateLimiter(db *pgxpool.Pool, rules map[string]rateLimitRule, lock lockoutRule, logger *zap.Logger) *postgresRateLimiter {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &postgresRateLimiter{
+		db:       db,
+		rules:    rules,
+		lockout:  lock,
+		logger:   logger,
+		fallback: newMemoryRateLimiterWithLockout(rules, lock),
+	}
+}
+
+func (p *postgresRateLimiter) Allow(action, key string) bool {
+	if key == "" {
+		key = "anonymous"
+	}
+	rules, lock := p.limits()
+	rule := ruleFor(rules, action)
+	if rule.limit <= 0 {
+		return true
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), rateLimitQueryTimeout)
+	defer cancel()
+
+	outcome, err := p.decide(ctx, time.Now(), action, key, rule, lockoutFor(key, lock))
+	if err != nil {
+		p.logger.Warn("rate limiter: database unavailable, deciding in memory", zap.Error(err))
+		return p.fallback.Allow(action, key)
+	}
+	p.maybeCleanup(ctx)
+	return outcome == rateAllowed
+}
+
+// limits is the rules and the lockout in force.
+func (p *postgresRateLimiter) limits() (map[string]rateLimitRule, lockoutRule) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.rules, p.lockout
+}
+
+// setLimits replaces the rules and the lockout, the fallback's too. The
+// counters in the database stand, keyed by window start; an action whose
+// window changed finds none for its new windows and starts afresh.
+func (p *postgresRateLimiter) setLimits(rules map[string]rateLimitRule, lock lockoutRule) {
+	p.mu.Lock()
+	p.rules, p.lockout = rules, lock
+	p.mu.Unlock()
+	p.fallback.setLimits(rules, lock)
+}
+
+func (p *postgresRateLimiter) decide(ctx context.Context, now time.Time, action, key string, rule rateLimitRule, lock lockoutRule) (rateOutcome, error) {
+	keyHash := hashKey(key)
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtextextended($1, 0))`, action+":"+keyHash); err != nil {
+		return 0, fmt.Errorf("lock rate key: %w", err)
+	}
+
+	var st rateState
+	var lockedUntil *time.Time
+	err = tx.QueryRow(ctx, `
+		SELECT strikes, last_strike_at, locked_until, blocked_until
+		FROM auth_rate_lockouts
+		WHERE action = $1 AND key_hash = $2
+	`, action, keyHash).Scan(&st.strikes, &st.lastStrike, &lockedUntil, &st.blockedUntil)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return 0, fmt.Errorf("load rate lockout: %w", err)
+	}
+	if lockedUntil != nil {
+		st.lockedUntil = *lockedUntil
+	}
+
+	start := now.Truncate(rule.window)
+	rows, err := tx.Query(ctx, `
+		SELECT window_start, hits
+		FROM auth_rate_counters
+		WHERE action = $1 AND key_hash = $2 AND window_start >= $3
+	`, action, keyHash, start.Add(-rule.window))
+	if err != nil {
+		return 0, fmt.Errorf("load rate counters: %w", err)
+	}
+	for rows.Next() {
+		var at time.Time
+		var hits int
+		if err := rows.Scan(&at, &hits); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scan rate counter: %w", err)
+		}
+		if at.Equal(start) {
+			st.cur = hits
+		} else if at.Equal(start.Add(-rule.window)) {
+			st.prev = hits
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("load rate counters: %w", err)
+	}
+
+	outcome := decide(now, rule, lock, &st)
+	switch outcome {
+	case rateAllowed:
+		_, err = tx.Exec(ctx, `
+			INSERT INTO auth_rate_counters (action, key_hash, window_start, hits)
+			VALUES ($1, $2, $3, 1)
+			ON CONFLICT (action, key_hash, window_start) DO UPDATE SET hits = auth_rate_counters.hits + 1
+		`, action, keyHash, start)
+	case rateRefused:
+		var locked *time.Time
+		if !st.lockedUntil.IsZero() {
+			locked = &st.lockedUntil
+		}
+		_, err = tx.Exec(ctx, `
+			INSERT INTO auth_rate_lockouts (action, key_hash, key_label, strikes, last_strike_at, locked_until, blocked_until)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (action, key_hash) DO UPDATE SET
+				strikes = EXCLUDED.strikes,
+				last_strike_at = EXCLUDED.last_strike_at,
+				locked_until = EXCLUDED.locked_until,
+				blocked_until = EXCLUDED.blocked_until
+		`, action, keyHash, throttleLabel(key), st.strikes, st.lastStrike, locked, st.blockedUntil)
+	case rateLocked:
+		return outcome, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("record rate decision: %w", err)
+	}
+	return outcome, tx.Commit(ctx)
+}
+
+// maybeCleanup deletes, at most once per rateLimitCleanupInterval, counters
+// too old to be anyone's previous window and lockouts whose strikes are
+// forgiven.
+func (p *postgresRateLimiter) maybeCleanup(ctx context.Context) {
+	now := time.Now()
+	p.mu.Lock()
+	if now.Before(p.nextCleanup) {
+		p.mu.Unlock()
+		return
+	}
+	p.nextCleanup = now.Add(rateLimitCleanupInterval)
+	rules, lock := p.rules, p.lockout
+	p.mu.Unlock()
+
+	var longest time.Duration
+	for _, rule := range rules {
+		if rule.window > longest {
+			longest = rule.window
+		}
+	}
+	forgive := lock.max
+	if forgive < longest {
+		forgive = longest
+	}
+	if _, err := p.db.Exec(ctx, `DELETE FROM auth_rate_counters WHERE window_start < $1`, now.Add(-2*longest)); err != nil {
+		p.logger.Warn("rate limiter: delete old counters", zap.Error(err))
+	}
+	if _, err := p.db.Exec(ctx, `DELETE FROM auth_rate_lockouts WHERE blocked_until < $1 AND last_strike_at < $1`, now.Add(-forgive)); err != nil {
+		p.logger.Warn("rate limiter: delete forgiven lockouts", zap.Error(err))
+	}
+}
+
+// Throttled lists the keys being refused across every instance, the
+// longest-blocked first.
+func (p *postgresRateLimiter) Throttled(ctx context.Context) ([]Throttle, error) {
+	rows, err := p.db.Query(ctx, `
+		SELECT action, key_hash, key_label, strikes, last_strike_at, locked_until, blocked_until
+		FROM auth_rate_lockouts
+		WHERE blocked_until > NOW()
+		ORDER BY blocked_until DESC
+		LIMIT 500
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("list throttled keys: %w", err)
+	}
+	defer rows.Close()
+
+	var out []Throttle
+	for rows.Next() {
+		var t Throttle
+		var locked *time.Time
+		if err := rows.Scan(&t.Action, &t.KeyHash, &t.Key, &t.Strikes, &t.LastRefused, &locked, &t.BlockedUntil); err != nil {
+			return nil, fmt.Errorf("scan throttled key: %w", err)
+		}
+		if locked != nil && locked.After(time.Now()) {
+			t.LockedUntil = *locked
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+// Unblock forgets the key's counts, strikes and lockout for the action, here
+// and in the fallback.
+func (p *postgresRateLimiter) Unblock(ctx context.Context, action, keyHash string) error {
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck
+
+	if _, err := tx.Exec(ctx, `DELETE FROM auth_rate_lockouts WHERE action = $1 AND key_hash = $2`, action, keyHash); err != nil {
+		return fmt.Errorf("unblock rate key: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM auth_rate_counters WHERE action = $1 AND key_hash = $2`, action, keyHash); err != nil {
+		return fmt.Errorf("unblock rate key: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	return p.fallback.Unblock(ctx, action, keyHash)
+}
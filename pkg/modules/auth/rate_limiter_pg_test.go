@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Two instances share one budget — the reason the limiter moved into the
+// database — and a lockout one of them imposes is lifted for both by an
+// unblock.
+func TestPostgresRateLimiterIsSharedAndUnblocks(t *testing.T) {
+	pool := revocationPool(t)
+	ctx := context.Background()
+	rules := map[string]rateLimitRule{"signin": {limit: 3, window: time.Hour}}
+	lock := lockoutRule{after: 2, base: time.Minute, max: time.Hour}
+	a := newPostgresRateLimiter(pool, rules, lock, nil)
+	b := newPostgresRateLimiter(pool, rules, lock, nil)
+	// Only an address is locked out; a random one keeps runs apart.
+	id := uuid.New()
+	key := net.IP(id[:]).String()
+	t.Cleanup(func() { _ = a.Unblock(ctx, "signin", hashKey(key)) })
+
+	if !a.Allow("signin", key) || !b.Allow("signin", key) || !a.Allow("signin", key) {
+		t.Fatal("the first three sign-ins across two instances were refused")
+	}
+	if b.Allow("signin", key) || a.Allow("signin", key) {
+		t.Fatal("a fourth sign-in was allowed: the instances count separately")
+	}
+
+	list, err := b.Throttled(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found *Throttle
+	for i := range list {
+		if list[i].Action == "signin" && list[i].KeyHash == hashKey(key) {
+			found = &list[i]
+		}
+	}
+	if found == nil || found.Key != key || found.Strikes != 2 || !found.Locked() {
+		t.Fatalf("throttled entry = %+v, want a locked key after two strikes", found)
+	}
+
+	if err := b.Unblock(ctx, "signin", found.KeyHash); err != nil {
+		t.Fatal(err)
+	}
+	if !a.Allow("signin", key) {
+		t.Fatal("the key is still refused after it was unblocked")
+	}
+}
@@ -0,0 +1,254 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Recovery codes are the way back in for someone whose authenticator app went
+// with their phone. Ten are issued when TOTP is confirmed, each good once, and
+// the set can be replaced from a signed-in session; nothing but their hashes
+// is stored, so the response that hands them out is the only copy there is.
+
+// JobMFAResetNotice is the job that tells a user their second factor was
+// reset by an operator (adminctl mfa-reset). It is queued rather than sent by
+// the command so the mail goes out through the running app's relay, with its
+// retries, and adminctl needs nothing but the database.
+const JobMFAResetNotice = "auth_mfa_reset_notice"
+
+// MFAResetNotice is the payload of JobMFAResetNotice.
+type MFAResetNotice struct {
+	Email string `json:"email"`
+}
+
+// ErrRecoveryCodesUnavailable is returned when recovery codes are asked for
+// an account that has no confirmed authenticator app to recover.
+var ErrRecoveryCodesUnavailable = errors.New("recovery codes need a confirmed authenticator app")
+
+// errRecoveryCodeInvalid means the code is unknown or already spent.
+var errRecoveryCodeInvalid = errors.New("recovery code not found")
+
+const (
+	recoveryCodeCount = 10
+	// recoveryCodeLen characters of a 32-letter alphabet are 80 bits: enough
+	// that the stored SHA-256 hashes cannot be walked back offline, which a
+	// short numeric code could.
+	recoveryCodeLen = 16
+)
+
+// recoveryAlphabet is Crockford's base32: no I, L, O or U, so a code read off
+// paper survives the usual misreadings (see normalizeRecoveryCode).
+const recoveryAlphabet = "0123456789abcdefghjkmnpqrstvwxyz"
+
+// GenerateRecoveryCodes returns a fresh set of codes, formatted for reading
+// aloud or writing down: xxxx-xxxx-xxxx-xxxx.
+func GenerateRecoveryCodes() ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	buf := make([]byte, recoveryCodeLen)
+	for range recoveryCodeCount {
+		if _, err := rand.Read(buf); err != nil {
+			return nil, fmt.Errorf("recovery code entropy: %w", err)
+		}
+		var b strings.Builder
+		for i, c := range buf {
+			if i > 0 && i%4 == 0 {
+				b.WriteByte('-')
+			}
+			b.WriteByte(recoveryAlphabet[c&31])
+		}
+		codes = append(codes, b.String())
+	}
+	return codes, nil
+}
+
+// normalizeRecoveryCode reduces what was typed to the form that is hashed:
+// case, dashes and spaces do not matter, and O, I and L are read as the
+// digits they are mistaken for. ok is false when the input cannot be a
+// recovery code at all — a six-digit TOTP code, for one.
+func normalizeRecoveryCode(code string) (string, bool) {
+	var b strings.Builder
+	for _, r := range strings.ToLower(code) {
+		switch {
+		case r == '-' || r == ' ':
+			continue
+		case r == 'o':
+			r = '0'
+		case r == 'i' || r == 'l':
+			r = '1'
+		}
+		if !strings.ContainsRune(recoveryAlphabet, r) {
+			return "", false
+		}
+		b.WriteRune(r)
+	}
+	if b.Len() != recoveryCodeLen {
+		return "", false
+	}
+	return b.String(), true
+}
+
+func hashRecoveryCodes(codes []string) []string {
+	hashes := make([]string, 0, len(codes))
+	for _, c := range codes {
+		n, _ := normalizeRecoveryCode(c)
+		hashes = append(hashes, hashToken(n))
+	}
+	return hashes
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes with a new set
+// and returns it. Every code issued before stops working.
+func (m *Module) RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	record, err := m.store.GetTOTPByUser(ctx, userID)
+	if errors.Is(err, ErrMFATOTPNotFound) || (err == nil && !record.Confirmed) {
+		return nil, ErrRecoveryCodesUnavailable
+	}
+	if err != nil {
+		return nil, err
+	}
+	codes, err := GenerateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := m.store.ReplaceRecoveryCodes(ctx, userID, hashRecoveryCodes(codes)); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// SendMFAResetNotice tells the owner of email that their second factor was
+// reset. It is the handler behind JobMFAResetNotice.
+func (m *Module) SendMFAResetNotice(ctx context.Context, email string) error {
+	link := "/studio/login"
+	if m.rt != nil {
+		link = m.rt.Config.PublicBase() + link
+	}
+	subject := "Two-factor authentication was reset"
+	body := fmt.Sprintf("The second factor on your Shanraq.org account was reset by an administrator on %s, "+
+		"and every session of the account was signed out.\n\n"+
+		"Sign in with your password as usual; where a second factor is required you will be asked to set one up again:\n\n%s\n\n"+
+		"If you did not ask for this, reply to this message straight away.",
+		time.Now().UTC().Format("2 January 2006 15:04 UTC"), link)
+	return m.deliverOrDevLink(ctx, email, subject, body, link, "mfa reset notice")
+}
+
+// ReplaceRecoveryCodes stores hashes as the user's recovery codes, dropping
+// any the user had.
+func (s *Store) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, hashes []string) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin recovery codes: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, hashes); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx pgx.Tx, userID uuid.UUID, hashes []string) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM auth_mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("delete recovery codes: %w", err)
+	}
+	for _, h := range hashes {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO auth_mfa_recovery_codes (id, user_id, code_hash) VALUES ($1, $2, $3)
+		`, uuid.New(), userID, h); err != nil {
+			return fmt.Errorf("insert recovery code: %w", err)
+		}
+	}
+	return nil
+}
+
+// ConfirmTOTP marks the secret confirmed and issues its first recovery codes
+// in one transaction: an authenticator app is never left confirmed without a
+// way back should it be lost.
+func (s *Store) ConfirmTOTP(ctx context.Context, record MFATOTP, hashes []string) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin totp confirmation: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE auth_mfa_totp
+		SET confirmed_at = NOW(), updated_at = NOW()
+		WHERE id = $1
+	`, record.ID); err != nil {
+		return fmt.Errorf("mark totp confirmed: %w", err)
+	}
+	if err := replaceRecoveryCodes(ctx, tx, record.UserID, hashes); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// UseRecoveryCode spends the user's code with the given hash and returns how
+// many are left. Spending is a single UPDATE, so two requests racing with the
+// same code cannot both get in.
+func (s *Store) UseRecoveryCode(ctx context.Context, userID uuid.UUID, hash string) (int, error) {
+	tag, err := s.db.Exec(ctx, `
+		UPDATE auth_mfa_recovery_codes
+		SET used_at = NOW()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`, userID, hash)
+	if err != nil {
+		return 0, fmt.Errorf("use recovery code: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return 0, errRecoveryCodeInvalid
+	}
+	return s.RecoveryCodesLeft(ctx, userID)
+}
+
+// RecoveryCodesLeft counts the user's unspent recovery codes.
+func (s *Store) RecoveryCodesLeft(ctx context.Context, userID uuid.UUID) (int, error) {
+	var n int
+	if err := s.db.QueryRow(ctx,
+		`SELECT COUNT(*) FROM auth_mfa_recovery_codes WHERE user_id = $1 AND used_at IS NULL`, userID).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count recovery codes: %w", err)
+	}
+	return n, nil
+}
+
+// ResetMFA is the operator's way out for someone locked out of their second
+// factor: the authenticator app and its recovery codes are forgotten — and,
+// with passkeys, the passkeys too — and auth_version is bumped, all at once.
+// The next sign-in enrols afresh. It reports whether there was anything to
+// reset.
+func (s *Store) ResetMFA(ctx context.Context, userID uuid.UUID, passkeys bool) (bool, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("begin mfa reset: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck
+
+	var removed int64
+	stmts := []string{
+		`DELETE FROM auth_mfa_totp WHERE user_id = $1`,
+		`DELETE FROM auth_mfa_recovery_codes WHERE user_id = $1`,
+	}
+	if passkeys {
+		stmts = append(stmts, `DELETE FROM auth_webauthn_credentials WHERE user_id = $1`)
+	}
+	for _, q := range stmts {
+		tag, err := tx.Exec(ctx, q, userID)
+		if err != nil {
+			return false, fmt.Errorf("reset mfa: %w", err)
+		}
+		removed += tag.RowsAffected()
+	}
+	if _, err := tx.Exec(ctx,
+		`UPDATE auth_users SET auth_version = auth_version + 1, updated_at = now() WHERE id = $1`, userID); err != nil {
+		return false, fmt.Errorf("bump auth version: %w", err)
+	}
+	return removed > 0, tx.Commit(ctx)
+}
@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+)
+
+func TestRecoveryCodesShape(t *testing.T) {
+	codes, err := GenerateRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("got %d codes, want %d", len(codes), recoveryCodeCount)
+	}
+	seen := map[string]bool{}
+	for _, c := range codes {
+		if len(c) != 19 || strings.Count(c, "-") != 3 {
+			t.Fatalf("code %q is not xxxx-xxxx-xxxx-xxxx", c)
+		}
+		n, ok := normalizeRecoveryCode(c)
+		if !ok || seen[n] {
+			t.Fatalf("code %q does not normalise or repeats", c)
+		}
+		seen[n] = true
+	}
+}
+
+// What people do to a code on its way from paper to a form: capitals, lost
+// dashes, an O for a zero. None of it may cost them the code; and a TOTP code
+// must never be mistaken for one.
+func TestNormalizeRecoveryCode(t *testing.T) {
+	want, _ := normalizeRecoveryCode("0123-4567-89ab-cdef")
+	for _, typed := range []string{"0123-4567-89AB-CDEF", "o123 4567 89ab cdef", "0123456789abcdef"} {
+		if got, ok := normalizeRecoveryCode(typed); !ok || got != want {
+			t.Errorf("%q normalised to %q, %v", typed, got, ok)
+		}
+	}
+	if n, _ := normalizeRecoveryCode("1l1i-0000-0000-0000"); n != "1111000000000000" {
+		t.Errorf("I and L not read as 1: %q", n)
+	}
+	for _, bad := range []string{"123456", "0123-4567-89ab-cdeu", "0123-4567-89ab-cdef0"} {
+		if _, ok := normalizeRecoveryCode(bad); ok {
+			t.Errorf("%q accepted as a recovery code", bad)
+		}
+	}
+}
+
+// The lost-phone path end to end: confirming the app hands out codes, a code
+// passes the step once and only once, and an operator reset forgets the app
+// and signs the account out.
+func TestRecoveryCodesAndReset(t *testing.T) {
+	pool := revocationPool(t)
+	ctx := context.Background()
+	store := NewStore(pool)
+	p := NewTOTPProvider(store, "Test", nil)
+	user := seedUser(t, pool, "user")
+
+	ch, err := p.Challenge(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := totp.GenerateCode(ch.Secret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := p.Verify(ctx, ch.ID, code)
+	if err != nil {
+		t.Fatalf("confirm: %v", err)
+	}
+	if len(res.RecoveryCodes) != recoveryCodeCount {
+		t.Fatalf("confirmation issued %d recovery codes", len(res.RecoveryCodes))
+	}
+
+	spent := res.RecoveryCodes[0]
+	res, err = p.Verify(ctx, ch.ID, strings.ToUpper(spent))
+	if err != nil || !res.RecoveryCodeUsed || res.RecoveryCodesLeft != recoveryCodeCount-1 {
+		t.Fatalf("recovery code: %+v %v", res, err)
+	}
+	if _, err := p.Verify(ctx, ch.ID, spent); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("a recovery code worked twice: %v", err)
+	}
+
+	had, err := store.ResetMFA(ctx, user.ID, false)
+	if err != nil || !had {
+		t.Fatalf("reset: %v %v", had, err)
+	}
+	if _, err := store.GetTOTPByUser(ctx, user.ID); !errors.Is(err, ErrMFATOTPNotFound) {
+		t.Fatalf("authenticator survived the reset: %v", err)
+	}
+	if left, _ := store.RecoveryCodesLeft(ctx, user.ID); left != 0 {
+		t.Fatalf("%d recovery codes survived the reset", left)
+	}
+	var version int
+	if err := pool.QueryRow(ctx, `SELECT auth_version FROM auth_users WHERE id=$1`, user.ID).Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	if version <= user.AuthVersion {
+		t.Fatalf("auth_version not bumped: %d -> %d", user.AuthVersion, version)
+	}
+}
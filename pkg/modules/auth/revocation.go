@@ -52,9 +52,10 @@ func (m *Module) SessionStillValid(ctx context.Context, claims *Claims) bool {
 //
 // Only privileged requests pay for this. Reading the site is authorised by the
 // signature alone, as before; the lookup happens where the answer can actually
-// matter — when a role is being relied upon.
+// matter — when a role is being relied upon. Without a database there is no
+// account to ask, and no token speaks for one.
 func (m *Module) tokenStillValid(ctx context.Context, claims *Claims) bool {
-	if claims == nil || m.store == nil {
+	if claims == nil || m.store == nil || m.store.db == nil {
 		return false
 	}
 	id, err := uuid.Parse(claims.Subject)
@@ -69,7 +70,13 @@ func (m *Module) tokenStillValid(ctx context.Context, claims *Claims) bool {
 	// as valid against version 1 keeps everyone signed in across the deploy;
 	// the first bump on an account ends that grace for it.
 	if claims.AuthVersion == 0 {
-		return current == 1
+		if current != 1 {
+			return false
+		}
+	} else if claims.AuthVersion != current {
+		return false
 	}
-	return claims.AuthVersion == current
+	// The account still stands behind the token; the session it was issued to
+	// must too, or "sign out that device" would only take effect at expiry.
+	return m.sessionStillLive(ctx, id, claims)
 }
@@ -334,6 +334,7 @@ func (m *Module) RequireSession(loginPath string, roles ...string) func(http.Han
 					http.Redirect(w, r, loginPath+"?reason=session_expired", http.StatusSeeOther)
 					return
 				}
+				r = r.WithContext(contextVerified(r.Context()))
 			}
 			next.ServeHTTP(w, r.WithContext(ContextWithClaims(r.Context(), claims)))
 		})
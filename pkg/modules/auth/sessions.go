@@ -0,0 +1,322 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"shanraq.org/pkg/shanraq"
+)
+
+// Sessions: where an account is signed in, and a way to end one of them.
+//
+// The refresh-token table always knew every API sign-in, but nobody could see
+// it, and the only kill switch was BumpAuthVersion — which signs out every
+// device at once, the one in the owner's hand included. A session is now a
+// family of refresh-token rows sharing session_id: rotation adds a row to the
+// family and retires the one before it. Browser sign-ins open a session too,
+// whose single row can be revoked but never refreshed. Every token carries its
+// session as the "sid" claim, and tokenStillValid refuses a token whose
+// session has no live row left.
+
+// Session kinds, as stored in auth_refresh_tokens.kind.
+const (
+	SessionAPI     = "api"
+	SessionBrowser = "browser"
+)
+
+// sessionTouchInterval bounds how often a session's last_used_at is written.
+// "Last active a minute ago" is all the list needs, and a write on every
+// studio request would be paid for by every studio request.
+const sessionTouchInterval = time.Minute
+
+// ErrSessionNotFound is returned when a session to revoke is not one of the
+// account's live sessions.
+var ErrSessionNotFound = errors.New("session not found")
+
+// SessionClient is the coarse description of the client that opened a
+// session: enough for its owner to tell their phone from their laptop, not
+// enough to follow anyone. The User-Agent and the address it is derived from
+// are never stored.
+type SessionClient struct {
+	Device  string // mobile | tablet | desktop | other
+	Browser string // browser family, e.g. chrome
+	OS      string // OS family, e.g. android
+	Country string // ISO 3166-1 alpha-2; empty when unknown
+	// Datacenter is whether the address belongs to a hosting or VPN network.
+	// Sign-in alerts compare it (see signin_alerts.go); sessions do not keep it.
+	Datacenter bool
+}
+
+// ClientDescriber derives a SessionClient from a request.
+type ClientDescriber func(r *http.Request) SessionClient
+
+// WithClientDescriber sets how sessions describe their client. The User-Agent
+// families and the GeoIP reader live with the host application's analytics;
+// without this option Init takes DescribeClient from the registered module
+// that is a SessionDescriber, and with neither, sessions are listed as an
+// unknown device.
+func WithClientDescriber(fn ClientDescriber) Option {
+	return func(m *Module) { m.describeClient = fn }
+}
+
+// SessionDescriber is implemented by the module that can tell a request's
+// device, browser, OS and country.
+type SessionDescriber interface {
+	shanraq.Module
+	DescribeClient(r *http.Request) SessionClient
+}
+
+// Session is one place an account is signed in.
+type Session struct {
+	ID         uuid.UUID
+	Kind       string
+	Client     SessionClient
+	StartedAt  time.Time
+	LastUsedAt time.Time
+	ExpiresAt  time.Time
+}
+
+// Session returns the session the token belongs to, or uuid.Nil for a token
+// issued outside one.
+func (c *Claims) Session() uuid.UUID {
+	if c == nil {
+		return uuid.Nil
+	}
+	id, err := uuid.Parse(c.SessionID)
+	if err != nil {
+		return uuid.Nil
+	}
+	return id
+}
+
+func (m *Module) clientOf(r *http.Request) SessionClient {
+	if m.describeClient == nil || r == nil {
+		return SessionClient{}
+	}
+	return m.describeClient(r)
+}
+
+// StartSession signs user in to this browser: it opens a session, described
+// from r, and sets the cookie holding the session's access token. The sign-in
+// joins the account's history, and its owner is mailed when it is new.
+func (m *Module) StartSession(w http.ResponseWriter, r *http.Request, user User) error {
+	secret, err := generateSecureToken(refreshTokenSize)
+	if err != nil {
+		return err
+	}
+	// The secret is thrown away: the cookie carries the access token, and a
+	// browser session is never refreshed. The row exists to be listed and
+	// revoked.
+	client := m.clientOf(r)
+	row, err := m.store.InsertSessionToken(r.Context(), RefreshToken{
+		UserID:    user.ID,
+		TokenHash: hashToken(secret),
+		ExpiresAt: time.Now().Add(m.SessionTTL()),
+		SessionID: uuid.New(),
+		Kind:      SessionBrowser,
+		Client:    client,
+	})
+	if err != nil {
+		return err
+	}
+	if err := m.store.DeleteExpiredRefreshTokens(r.Context(), user.ID); err != nil && m.rt != nil {
+		m.rt.Logger.Warn("delete expired refresh tokens", zap.Error(err))
+	}
+	token, err := m.tokens.GenerateSession(user, row.SessionID)
+	if err != nil {
+		return err
+	}
+	SetSessionCookie(w, r, token, m.SessionTTL())
+	m.noteSignIn(r.Context(), user, SessionBrowser, client)
+	return nil
+}
+
+// EndSession signs this browser out: the session behind the cookie is revoked
+// and the cookie cleared. A cookie that no longer parses is simply cleared.
+func (m *Module) EndSession(w http.ResponseWriter, r *http.Request) {
+	if claims, ok := m.claimsFromCookie(r); ok {
+		if uid, err := uuid.Parse(claims.Subject); err == nil && claims.Session() != uuid.Nil {
+			if err := m.store.RevokeSession(r.Context(), uid, claims.Session()); err != nil &&
+				!errors.Is(err, ErrSessionNotFound) && m.rt != nil {
+				m.rt.Logger.Warn("revoke session on sign-out", zap.Error(err))
+			}
+		}
+	}
+	ClearSessionCookie(w, r)
+}
+
+// renewBrowserSession signs a fresh access token for user in the browser
+// session sessionID, pushing the session's expiry out to match the new
+// cookie. uuid.Nil — a cookie from before sessions — gets a token outside
+// any session, as before.
+func (m *Module) renewBrowserSession(ctx context.Context, user User, sessionID uuid.UUID) (string, error) {
+	if sessionID != uuid.Nil {
+		if err := m.store.extendSession(ctx, user.ID, sessionID, time.Now().Add(m.SessionTTL())); err != nil {
+			return "", err
+		}
+	}
+	return m.tokens.GenerateSession(user, sessionID)
+}
+
+// sessionStillLive is the session half of tokenStillValid: the token's
+// session must have a row that is neither revoked nor expired. last_used_at
+// is brought up to date on the way, at most once per sessionTouchInterval.
+func (m *Module) sessionStillLive(ctx context.Context, userID uuid.UUID, claims *Claims) bool {
+	if claims.SessionID == "" {
+		// Issued before sessions existed; the auth_version check is all
+		// such a token ever had, and it expires on its own.
+		return true
+	}
+	sid := claims.Session()
+	if sid == uuid.Nil {
+		return false
+	}
+	lastUsed, ok := m.store.liveSession(ctx, userID, sid)
+	if !ok {
+		return false
+	}
+	if time.Since(lastUsed) > sessionTouchInterval {
+		if err := m.store.touchSession(ctx, userID, sid); err != nil && m.rt != nil {
+			m.rt.Logger.Warn("touch session", zap.Error(err))
+		}
+	}
+	return true
+}
+
+// InsertSessionToken stores a refresh-token row of the session rt names.
+// StartedAt zero means the row starts the session.
+func (s *Store) InsertSessionToken(ctx context.Context, rt RefreshToken) (RefreshToken, error) {
+	if rt.SessionID == uuid.Nil {
+		rt.SessionID = uuid.New()
+	}
+	if rt.Kind == "" {
+		rt.Kind = SessionAPI
+	}
+	if rt.StartedAt.IsZero() {
+		rt.StartedAt = time.Now()
+	}
+	err := s.db.QueryRow(ctx, `
+		INSERT INTO auth_refresh_tokens
+			(user_id, token_hash, expires_at, session_id, kind, device, browser, os, country, started_at, last_used_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW())
+		RETURNING id, created_at, last_used_at
+	`, rt.UserID, rt.TokenHash, rt.ExpiresAt, rt.SessionID, rt.Kind,
+		rt.Client.Device, rt.Client.Browser, rt.Client.OS, rt.Client.Country, rt.StartedAt,
+	).Scan(&rt.ID, &rt.CreatedAt, &rt.LastUsedAt)
+	if err != nil {
+		return RefreshToken{}, fmt.Errorf("insert refresh token: %w", err)
+	}
+	return rt, nil
+}
+
+// ListSessions returns the account's live sessions, most recently used first.
+func (s *Store) ListSessions(ctx context.Context, userID uuid.UUID) ([]Session, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT session_id, kind, device, browser, os, country, started_at, last_used_at, expires_at
+		FROM (
+			SELECT DISTINCT ON (session_id)
+				session_id, kind, device, browser, os, country, started_at, last_used_at, expires_at
+			FROM auth_refresh_tokens
+			WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+			ORDER BY session_id, created_at DESC
+		) live
+		ORDER BY last_used_at DESC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var out []Session
+	for rows.Next() {
+		var ss Session
+		if err := rows.Scan(&ss.ID, &ss.Kind, &ss.Client.Device, &ss.Client.Browser, &ss.Client.OS,
+			&ss.Client.Country, &ss.StartedAt, &ss.LastUsedAt, &ss.ExpiresAt); err != nil {
+			return nil, fmt.Errorf("scan session: %w", err)
+		}
+		out = append(out, ss)
+	}
+	return out, rows.Err()
+}
+
+// RevokeSession ends one of the account's sessions. Its refresh token stops
+// refreshing and its access tokens are refused wherever tokenStillValid asks.
+func (s *Store) RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) error {
+	tag, err := s.db.Exec(ctx, `
+		UPDATE auth_refresh_tokens
+		SET revoked_at = NOW()
+		WHERE user_id = $1 AND session_id = $2 AND revoked_at IS NULL
+	`, userID, sessionID)
+	if err != nil {
+		return fmt.Errorf("revoke session: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeOtherSessions ends every session of the account except keep, and
+// reports how many there were. keep may be uuid.Nil to end them all.
+func (s *Store) RevokeOtherSessions(ctx context.Context, userID, keep uuid.UUID) (int, error) {
+	var n int
+	err := s.db.QueryRow(ctx, `
+		WITH revoked AS (
+			UPDATE auth_refresh_tokens
+			SET revoked_at = NOW()
+			WHERE user_id = $1 AND session_id <> $2 AND revoked_at IS NULL
+			RETURNING session_id
+		)
+		SELECT COUNT(DISTINCT session_id) FROM revoked
+	`, userID, keep).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("revoke other sessions: %w", err)
+	}
+	return n, nil
+}
+
+// liveSession reports whether the session has a live row, and when it was
+// last used. Errors count as "not live", as in authVersionOf.
+func (s *Store) liveSession(ctx context.Context, userID, sessionID uuid.UUID) (time.Time, bool) {
+	if s == nil || s.db == nil {
+		return time.Time{}, false
+	}
+	var lastUsed time.Time
+	err := s.db.QueryRow(ctx, `
+		SELECT last_used_at
+		FROM auth_refresh_tokens
+		WHERE user_id = $1 AND session_id = $2 AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY created_at DESC
+		LIMIT 1
+	`, userID, sessionID).Scan(&lastUsed)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return lastUsed, true
+}
+
+func (s *Store) touchSession(ctx context.Context, userID, sessionID uuid.UUID) error {
+	_, err := s.db.Exec(ctx, `
+		UPDATE auth_refresh_tokens
+		SET last_used_at = NOW()
+		WHERE user_id = $1 AND session_id = $2 AND revoked_at IS NULL
+	`, userID, sessionID)
+	return err
+}
+
+func (s *Store) extendSession(ctx context.Context, userID, sessionID uuid.UUID, until time.Time) error {
+	_, err := s.db.Exec(ctx, `
+		UPDATE auth_refresh_tokens
+		SET expires_at = GREATEST(expires_at, $3), last_used_at = NOW()
+		WHERE user_id = $1 AND session_id = $2 AND revoked_at IS NULL
+	`, userID, sessionID, until)
+	if err != nil {
+		return fmt.Errorf("extend session: %w", err)
+	}
+	return nil
+}
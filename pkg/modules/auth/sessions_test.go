@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestSessionClaim(t *testing.T) {
+	tokens := NewTokenService("test-token-secret-that-is-long-enough-1234567890", time.Hour)
+	user := User{ID: uuid.New(), Email: "s@t.test", Role: "user"}
+	sid := uuid.New()
+
+	signed, err := tokens.GenerateSession(user, sid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := tokens.Parse(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Session() != sid {
+		t.Fatalf("sid %q, want %s", claims.SessionID, sid)
+	}
+
+	signed, _ = tokens.Generate(user)
+	claims, _ = tokens.Parse(signed)
+	if claims.SessionID != "" || claims.Session() != uuid.Nil {
+		t.Fatalf("a sessionless token carries sid %q", claims.SessionID)
+	}
+	if (*Claims)(nil).Session() != uuid.Nil {
+		t.Fatal("nil claims name a session")
+	}
+}
+
+// The point of the whole panel: ending a session from somewhere else must
+// refuse that session's tokens straight away, not when they expire, and must
+// leave the session doing the ending alone.
+func TestSessionsRevokeOneAndOthers(t *testing.T) {
+	pool := revocationPool(t)
+	ctx := context.Background()
+	store := NewStore(pool)
+	mod := &Module{
+		store:  store,
+		tokens: NewTokenService("test-token-secret-that-is-long-enough-1234567890", time.Hour),
+		describeClient: func(*http.Request) SessionClient {
+			return SessionClient{Device: "mobile", Browser: "chrome", OS: "android", Country: "KZ"}
+		},
+	}
+	user := seedUser(t, pool, "user")
+
+	rec := httptest.NewRecorder()
+	if err := mod.StartSession(rec, httptest.NewRequest(http.MethodPost, "/studio/login", nil), user); err != nil {
+		t.Fatalf("start session: %v", err)
+	}
+	var cookie *http.Cookie
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == SessionCookieName {
+			cookie = c
+		}
+	}
+	if cookie == nil {
+		t.Fatal("no session cookie set")
+	}
+	browser, err := mod.tokens.Parse(cookie.Value)
+	if err != nil || browser.Session() == uuid.Nil {
+		t.Fatalf("browser token: %+v %v", browser, err)
+	}
+
+	access, _, err := mod.issueTokenPair(ctx, user.ID, user, RefreshToken{Client: SessionClient{Device: "desktop"}})
+	if err != nil {
+		t.Fatalf("issue api session: %v", err)
+	}
+	api, _ := mod.tokens.Parse(access)
+
+	sessions, err := store.ListSessions(ctx, user.ID)
+	if err != nil || len(sessions) != 2 {
+		t.Fatalf("sessions: %+v %v", sessions, err)
+	}
+	for _, s := range sessions {
+		if s.ID == browser.Session() && (s.Kind != SessionBrowser || s.Client.Country != "KZ" || s.Client.Browser != "chrome") {
+			t.Fatalf("browser session recorded as %+v", s)
+		}
+	}
+	if !mod.tokenStillValid(ctx, browser) || !mod.tokenStillValid(ctx, api) {
+		t.Fatal("fresh sessions refused")
+	}
+
+	if err := store.RevokeSession(ctx, user.ID, api.Session()); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if mod.tokenStillValid(ctx, api) {
+		t.Fatal("a revoked session's access token still passes")
+	}
+	if err := store.RevokeSession(ctx, user.ID, api.Session()); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("revoking twice: %v", err)
+	}
+
+	if _, _, err := mod.issueTokenPair(ctx, user.ID, user, RefreshToken{}); err != nil {
+		t.Fatal(err)
+	}
+	n, err := store.RevokeOtherSessions(ctx, user.ID, browser.Session())
+	if err != nil || n != 1 {
+		t.Fatalf("revoke others: %d %v", n, err)
+	}
+	if !mod.tokenStillValid(ctx, browser) {
+		t.Fatal("the kept session was signed out")
+	}
+	if sessions, _ := store.ListSessions(ctx, user.ID); len(sessions) != 1 || sessions[0].ID != browser.Session() {
+		t.Fatalf("left after revoke-others: %+v", sessions)
+	}
+}
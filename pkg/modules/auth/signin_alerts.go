@@ -0,0 +1,411 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// Sign-in alerts: every successful sign-in is compared with where the account
+// signed in from over the last signInHistory, and one from a country, a
+// device or a datacenter network it has not used is mailed to the owner with
+// a "this wasn't me" link.
+//
+// The session list already showed "Chrome on Android, KZ", but only to
+// someone who went looking, and a stolen password signs in from somewhere the
+// owner never goes. The mail goes to the address on the account, which the
+// password alone does not give; the link in it signs out everything, clears
+// the password, and hands the owner a reset — so whoever had the password is
+// out, and stays out.
+//
+// Staff accounts are asked for more. A new country is where a stolen staff
+// password would first show up, and an alert read the next morning is too
+// late for an account that can hide comments or change tariffs: NeedsStepUp
+// tells the sign-in form to ask for a second factor — the passkey, or else a
+// code mailed to the account — before any session opens.
+//
+// Nothing is compared on the first sign-in a history holds: there is nothing
+// to compare with, and every new account would be mailed about itself.
+
+// ErrSignInDisownInvalid is a "this wasn't me" link that is unknown, used, or
+// past signInDisownTTL.
+var ErrSignInDisownInvalid = errors.New("sign-in alert link invalid or expired")
+
+// Signals: what was new about a sign-in.
+const (
+	SignalCountry    = "country"
+	SignalDevice     = "device"
+	SignalDatacenter = "datacenter"
+)
+
+const (
+	// signInHistory is how far back a sign-in is compared, and how long its
+	// row is kept. Long enough to cover the trip home twice a year; short
+	// enough that a laptop sold last spring is a new device again.
+	signInHistory = 90 * 24 * time.Hour
+	// signInHistoryLimit bounds the rows read per comparison.
+	signInHistoryLimit = 500
+	// signInDisownTTL is how long the alert's link works. A mail is not
+	// always read the day it arrives; a week-old alert is still worth acting
+	// on, an older one is history.
+	signInDisownTTL = 7 * 24 * time.Hour
+)
+
+// SignIn is one successful sign-in, as kept in the history.
+type SignIn struct {
+	ID      uuid.UUID
+	At      time.Time
+	Kind    string // SessionBrowser or SessionAPI
+	Client  SessionClient
+	Signals []string
+	// Disowned is when the owner followed the alert's link; nil otherwise.
+	Disowned *time.Time
+}
+
+// signInSignals reports what about client the history has not seen: a
+// country, a device (class, browser and OS together), or a datacenter
+// network when every earlier sign-in came from somewhere else. An empty
+// history reports nothing, and so does a field the describer left unknown.
+// A sign-in its owner disowned knows nothing: the intruder's country and
+// device must stay new to the account, not become its own.
+func signInSignals(history []SignIn, client SessionClient) []string {
+	if len(history) == 0 {
+		return nil
+	}
+	newCountry := client.Country != ""
+	newDevice := client.Device != "" || client.Browser != "" || client.OS != ""
+	newDatacenter := client.Datacenter
+	for _, h := range history {
+		if h.Disowned != nil {
+			continue
+		}
+		if h.Client.Country == client.Country {
+			newCountry = false
+		}
+		if h.Client.Device == client.Device && h.Client.Browser == client.Browser && h.Client.OS == client.OS {
+			newDevice = false
+		}
+		if h.Client.Datacenter {
+			newDatacenter = false
+		}
+	}
+	var signals []string
+	if newCountry {
+		signals = append(signals, SignalCountry)
+	}
+	if newDevice {
+		signals = append(signals, SignalDevice)
+	}
+	if newDatacenter {
+		signals = append(signals, SignalDatacenter)
+	}
+	return signals
+}
+
+// noteSignIn records a successful sign-in and, when something about it is
+// new, mails the owner. It never fails the sign-in: the owner is signed in
+// either way, and a history or mail outage is logged, not shown.
+func (m *Module) noteSignIn(ctx context.Context, user User, kind string, client SessionClient) {
+	if m.store == nil || m.store.db == nil {
+		return
+	}
+	history, err := m.store.ListSignIns(ctx, user.ID)
+	if err != nil {
+		m.warn("load sign-in history", err)
+		return
+	}
+	signals := signInSignals(history, client)
+	var token, tokenHash string
+	if len(signals) > 0 {
+		if token, err = generateSecureToken(refreshTokenSize); err != nil {
+			m.warn("sign-in alert token", err)
+			return
+		}
+		tokenHash = hashToken(token)
+	}
+	if err := m.store.insertSignIn(ctx, user.ID, kind, client, signals, tokenHash); err != nil {
+		m.warn("record sign-in", err)
+		return
+	}
+	if token == "" {
+		return
+	}
+	link := strings.TrimRight(m.rt.Config.PublicBase(), "/") + "/auth/signin/disown?token=" + token
+	subject, body := signInAlertMessage(client, signals, time.Now(), link)
+	if err := m.deliverOrDevLink(ctx, user.Email, subject, body, link, "sign-in alert"); err != nil {
+		m.warn("send sign-in alert", err)
+		return
+	}
+	m.rt.Logger.Info("sign-in alert sent", zap.String("user_id", user.ID.String()), zap.Strings("signals", signals))
+}
+
+func (m *Module) warn(msg string, err error) {
+	if m.rt != nil {
+		m.rt.Logger.Warn(msg, zap.Error(err))
+	}
+}
+
+// NeedsStepUp reports whether user, having given a password from r, must
+// also pass a second factor before a session opens: a staff account — one
+// holding any permission — signing in from a country its history does not
+// have. An unknown country or an empty history asks nothing; a history that
+// cannot be read asks, since the answer guards a staff account.
+func (m *Module) NeedsStepUp(r *http.Request, user User) bool {
+	if m.store == nil || m.store.db == nil || !m.isStaff(r.Context(), user) {
+		return false
+	}
+	country := m.clientOf(r).Country
+	if country == "" {
+		return false
+	}
+	history, err := m.store.ListSignIns(r.Context(), user.ID)
+	if err != nil {
+		m.warn("load sign-in history", err)
+		return true
+	}
+	return slices.Contains(signInSignals(history, SessionClient{Country: country}), SignalCountry)
+}
+
+// IssueStepUp mails user the code that completes a sign-in NeedsStepUp
+// stopped, through the same link-and-code row as IssueEmailSignIn; the code
+// step then signs in as from the e-mail form. The mail says why it came: the
+// password was right, so a reader who did not ask for it has lost it.
+func (m *Module) IssueStepUp(r *http.Request, user User, confirmURL string) error {
+	country := m.clientOf(r).Country
+	return m.issueEmailSignIn(r.Context(), user.Email, confirmURL, func(code, link string) (string, string) {
+		return stepUpMessage(code, link, country)
+	})
+}
+
+func (m *Module) isStaff(ctx context.Context, user User) bool {
+	return len(m.PermissionsOf(ctx, ClaimsForUser(user))) > 0
+}
+
+// signInAlertMessage is the alert mail. It names the client the way the
+// session list does and says what was new about it.
+func signInAlertMessage(client SessionClient, signals []string, at time.Time, link string) (string, string) {
+	var from, label []string
+	for _, f := range []string{client.Browser, client.OS} {
+		if f != "" {
+			label = append(label, titleWord(f))
+		}
+	}
+	if len(label) > 0 {
+		from = append(from, strings.Join(label, " on "))
+	}
+	if client.Device != "" {
+		from = append(from, client.Device)
+	}
+	if client.Country != "" {
+		from = append(from, "country "+client.Country)
+	}
+	if client.Datacenter {
+		from = append(from, "through a hosting or VPN network")
+	}
+	where := "an unknown device"
+	if len(from) > 0 {
+		where = strings.Join(from, ", ")
+	}
+	var news []string
+	for _, s := range signals {
+		switch s {
+		case SignalCountry:
+			news = append(news, "a country")
+		case SignalDevice:
+			news = append(news, "a device")
+		case SignalDatacenter:
+			news = append(news, "a hosting or VPN network")
+		}
+	}
+	return "New sign-in to your Shanraq.org account", fmt.Sprintf(
+		"Your Shanraq.org account was signed in to at %s (UTC) from %s.\n\n"+
+			"It is %s this account has not signed in from in the last 90 days. If it was you, there is nothing to do.\n\n"+
+			"If it was not, open the link below within 7 days. It signs the account out everywhere, clears the password, "+
+			"and lets you set a new one:\n\n%s\n\n"+
+			"Then look through the passkeys in your profile and remove any you do not recognise.",
+		at.UTC().Format("02.01.2006 15:04"), where, strings.Join(news, " and "), link)
+}
+
+func stepUpMessage(code, link, country string) (string, string) {
+	return "Confirm your Shanraq.org sign-in", fmt.Sprintf(
+		"Your account was just signed in to with its password from a country it has not used before (%s). "+
+			"To finish signing in, enter this code: %s\n\nOr open the link below:\n\n%s\n\n"+
+			"The code and the link work for 15 minutes. If this was not you, your password is known to someone else: "+
+			"do not use the code, and set a new password through \"Forgot password\" now.",
+		country, code, link)
+}
+
+// titleWord spells a family the way the alert prints it: "chrome" → "Chrome".
+func titleWord(s string) string {
+	if s == "" {
+		return ""
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// renderDisownPage is where the alert's link lands: what the button will do,
+// and the button. Nothing happens on GET — mail scanners open every link.
+func (m *Module) renderDisownPage(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimSpace(r.URL.Query().Get("token"))
+	m.renderTemplate(w, http.StatusOK, "signin_disown", templateData{Token: token})
+}
+
+// handleDisownSignIn answers "this wasn't me": every session and refresh
+// token of the account is revoked, its password is cleared so the one that
+// leaked no longer signs in, and the owner — who proved the inbox by
+// following the link — is sent straight to set a new one.
+func (m *Module) handleDisownSignIn(w http.ResponseWriter, r *http.Request) {
+	// The same budget as the reset form: both take a mailed token and hand
+	// over the account.
+	if !m.enforceRateLimit(r, "password_reset_confirm", true) {
+		m.renderTemplate(w, http.StatusTooManyRequests, "signin_disown", templateData{Error: "Too many attempts. Please try again later."})
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		m.renderTemplate(w, http.StatusBadRequest, "signin_disown", templateData{Error: "Invalid form submission."})
+		return
+	}
+	token := strings.TrimSpace(r.FormValue("token"))
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	userID, err := m.DisownSignIn(ctx, token)
+	if err != nil {
+		if !errors.Is(err, ErrSignInDisownInvalid) {
+			m.rt.Logger.Error("disown sign-in", zap.Error(err))
+			m.renderTemplate(w, http.StatusInternalServerError, "signin_disown", templateData{Token: token, Error: "Something went wrong. Please try again."})
+			return
+		}
+		m.renderTemplate(w, http.StatusBadRequest, "signin_disown", templateData{Error: "This link is invalid, already used, or more than 7 days old."})
+		return
+	}
+	m.rt.Logger.Warn("sign-in disowned: sessions revoked, password cleared", zap.String("user_id", userID.String()))
+
+	reset, err := generateSecureToken(refreshTokenSize)
+	if err == nil {
+		_, err = m.store.CreatePasswordReset(ctx, userID, hashToken(reset), time.Now().Add(passwordResetTTL))
+	}
+	if err != nil {
+		// The account is already safe; only the shortcut to a new password
+		// is missing, and the reset form is the long way round.
+		m.rt.Logger.Error("password reset after disown", zap.Error(err))
+		m.renderTemplate(w, http.StatusOK, "signin_disown", templateData{
+			Message: "Every session has been signed out and the password cleared. Set a new one through \"Forgot password\".",
+		})
+		return
+	}
+	http.Redirect(w, r, "/auth/password/confirm?token="+reset, http.StatusSeeOther)
+}
+
+// DisownSignIn spends an alert's link: it revokes the account's sessions and
+// clears its password, and returns the account.
+func (m *Module) DisownSignIn(ctx context.Context, token string) (uuid.UUID, error) {
+	if token == "" {
+		return uuid.Nil, ErrSignInDisownInvalid
+	}
+	return m.store.disownSignIn(ctx, hashToken(token), time.Now().Add(-signInDisownTTL))
+}
+
+// ListSignIns returns the account's sign-ins of the last signInHistory,
+// newest first.
+func (s *Store) ListSignIns(ctx context.Context, userID uuid.UUID) ([]SignIn, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT id, at, kind, device, browser, os, country, datacenter, signals, disowned_at
+		FROM auth_signins
+		WHERE user_id = $1 AND at > $2
+		ORDER BY at DESC
+		LIMIT $3
+	`, userID, time.Now().Add(-signInHistory), signInHistoryLimit)
+	if err != nil {
+		return nil, fmt.Errorf("list sign-ins: %w", err)
+	}
+	defer rows.Close()
+
+	var out []SignIn
+	for rows.Next() {
+		var si SignIn
+		if err := rows.Scan(&si.ID, &si.At, &si.Kind, &si.Client.Device, &si.Client.Browser, &si.Client.OS,
+			&si.Client.Country, &si.Client.Datacenter, &si.Signals, &si.Disowned); err != nil {
+			return nil, fmt.Errorf("scan sign-in: %w", err)
+		}
+		out = append(out, si)
+	}
+	return out, rows.Err()
+}
+
+// insertSignIn records a sign-in and drops the account's rows older than
+// signInHistory. tokenHash is the alert link's, or empty when none was sent.
+func (s *Store) insertSignIn(ctx context.Context, userID uuid.UUID, kind string, c SessionClient, signals []string, tokenHash string) error {
+	if signals == nil {
+		signals = []string{}
+	}
+	var hash any
+	if tokenHash != "" {
+		hash = tokenHash
+	}
+	if _, err := s.db.Exec(ctx, `
+		INSERT INTO auth_signins (user_id, kind, device, browser, os, country, datacenter, signals, alert_token_hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, userID, kind, c.Device, c.Browser, c.OS, c.Country, c.Datacenter, signals, hash); err != nil {
+		return fmt.Errorf("insert sign-in: %w", err)
+	}
+	if _, err := s.db.Exec(ctx, `DELETE FROM auth_signins WHERE user_id = $1 AND at < $2`,
+		userID, time.Now().Add(-signInHistory)); err != nil {
+		return fmt.Errorf("prune sign-ins: %w", err)
+	}
+	return nil
+}
+
+// disownSignIn marks the sign-in whose alert link hashes to tokenHash as not
+// the owner's — once, and only for an alert sent after since — and in the
+// same transaction signs the account out everywhere and clears its password.
+// auth_version is bumped with it, so cookies are refused as well as refresh
+// tokens.
+func (s *Store) disownSignIn(ctx context.Context, tokenHash string, since time.Time) (uuid.UUID, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("begin disown tx: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck
+
+	var userID uuid.UUID
+	err = tx.QueryRow(ctx, `
+		UPDATE auth_signins SET disowned_at = NOW()
+		WHERE alert_token_hash = $1 AND disowned_at IS NULL AND at > $2
+		RETURNING user_id
+	`, tokenHash, since).Scan(&userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return uuid.Nil, ErrSignInDisownInvalid
+	}
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("disown sign-in: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE auth_users
+		SET password_hash = '',
+		    password_reset_required = TRUE,
+		    auth_version = auth_version + 1,
+		    updated_at = NOW()
+		WHERE id = $1
+	`, userID); err != nil {
+		return uuid.Nil, fmt.Errorf("clear password: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE auth_refresh_tokens SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`, userID); err != nil {
+		return uuid.Nil, fmt.Errorf("revoke user tokens: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return uuid.Nil, fmt.Errorf("commit disown: %w", err)
+	}
+	return userID, nil
+}
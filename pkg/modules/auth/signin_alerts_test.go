@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"shanraq.org/internal/config"
+	"shanraq.org/pkg/shanraq"
+)
+
+func TestSignInSignals(t *testing.T) {
+	home := SessionClient{Device: "desktop", Browser: "chrome", OS: "windows", Country: "KZ"}
+	phone := SessionClient{Device: "mobile", Browser: "safari", OS: "ios", Country: "KZ"}
+	history := []SignIn{{Client: home}, {Client: phone}}
+
+	for _, tc := range []struct {
+		name   string
+		client SessionClient
+		want   []string
+	}{
+		{"the laptop again", home, nil},
+		{"the phone abroad", SessionClient{Device: "mobile", Browser: "safari", OS: "ios", Country: "TR"}, []string{SignalCountry}},
+		{"a new browser at home", SessionClient{Device: "desktop", Browser: "firefox", OS: "windows", Country: "KZ"}, []string{SignalDevice}},
+		{"through a VPN", SessionClient{Device: "desktop", Browser: "chrome", OS: "windows", Country: "NL", Datacenter: true},
+			[]string{SignalCountry, SignalDatacenter}},
+		{"no country known", SessionClient{Device: "desktop", Browser: "chrome", OS: "windows"}, nil},
+		{"no describer", SessionClient{}, nil},
+	} {
+		if got := signInSignals(history, tc.client); !slices.Equal(got, tc.want) {
+			t.Errorf("%s: signals = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	if got := signInSignals(nil, SessionClient{Country: "US", Datacenter: true}); got != nil {
+		t.Errorf("the first sign-in reported %v", got)
+	}
+	vpn := append(history, SignIn{Client: SessionClient{Device: "desktop", Browser: "chrome", OS: "windows", Country: "NL", Datacenter: true}})
+	if got := signInSignals(vpn, SessionClient{Device: "desktop", Browser: "chrome", OS: "windows", Country: "DE", Datacenter: true}); !slices.Equal(got, []string{SignalCountry}) {
+		t.Errorf("a VPN user's VPN was reported as new: %v", got)
+	}
+
+	// The intruder's sign-in, once disowned, does not make their country
+	// and device the account's.
+	when := time.Now()
+	intruder := SessionClient{Device: "desktop", Browser: "firefox", OS: "linux", Country: "RU"}
+	disowned := append(slices.Clone(history), SignIn{Client: intruder, Disowned: &when})
+	if got := signInSignals(disowned, intruder); !slices.Equal(got, []string{SignalCountry, SignalDevice}) {
+		t.Errorf("after a disowned sign-in, its client again reports %v", got)
+	}
+}
+
+func TestSignInAlertMessage(t *testing.T) {
+	at := time.Date(2025, 11, 8, 9, 30, 0, 0, time.UTC)
+	subject, body := signInAlertMessage(
+		SessionClient{Device: "mobile", Browser: "chrome", OS: "android", Country: "TR", Datacenter: true},
+		[]string{SignalCountry, SignalDatacenter}, at, "https://shanraq.org/auth/signin/disown?token=t")
+	if !strings.Contains(subject, "sign-in") {
+		t.Errorf("subject = %q", subject)
+	}
+	for _, want := range []string{"08.11.2025 09:30", "Chrome on Android", "mobile", "country TR", "a country and a hosting or VPN network", "token=t"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body lacks %q:\n%s", want, body)
+		}
+	}
+	if _, body := signInAlertMessage(SessionClient{OS: "linux"}, []string{SignalDevice}, at, "l"); !strings.Contains(body, "from Linux.") {
+		t.Errorf("a lone OS is not named on its own:\n%s", body)
+	}
+	if _, body := stepUpMessage("042917", "l", "DE"); !strings.Contains(body, "042917") || !strings.Contains(body, "(DE)") {
+		t.Errorf("step-up mail:\n%s", body)
+	}
+}
+
+// Against the real tables: a sign-in from somewhere new is recorded with its
+// signals, a staff account abroad is stepped up and a reader is not, and the
+// alert's link signs the account out and clears its password, once.
+func TestSignInAlertFlow(t *testing.T) {
+	pool := revocationPool(t)
+	ctx := context.Background()
+	country := "KZ"
+	m := &Module{store: NewStore(pool), rt: &shanraq.Runtime{
+		Config: config.Config{Auth: config.AuthConfig{TokenSecret: "test-token-secret-that-is-long-enough-1234567890"}},
+		Logger: zap.NewNop(),
+	}, describeClient: func(*http.Request) SessionClient {
+		return SessionClient{Device: "desktop", Browser: "chrome", OS: "windows", Country: country}
+	}}
+	req := httptest.NewRequest(http.MethodPost, "/studio/login", nil)
+	staff, reader := seedUser(t, pool, "admin"), seedUser(t, pool, "user")
+
+	for _, u := range []User{staff, reader} {
+		if m.NeedsStepUp(req, u) {
+			t.Errorf("%s stepped up with no history", u.Role)
+		}
+		m.noteSignIn(ctx, u, SessionBrowser, m.clientOf(req))
+	}
+	country = "TR"
+	if !m.NeedsStepUp(req, staff) {
+		t.Error("staff from a new country was not stepped up")
+	}
+	if m.NeedsStepUp(req, reader) {
+		t.Error("a reader from a new country was stepped up")
+	}
+	m.noteSignIn(ctx, reader, SessionBrowser, m.clientOf(req))
+	history, err := m.store.ListSignIns(ctx, reader.ID)
+	if err != nil || len(history) != 2 {
+		t.Fatalf("history = %+v, %v", history, err)
+	}
+	if !slices.Equal(history[0].Signals, []string{SignalCountry}) || len(history[1].Signals) != 0 {
+		t.Errorf("signals = %v then %v", history[0].Signals, history[1].Signals)
+	}
+
+	if err := m.store.insertSignIn(ctx, reader.ID, SessionAPI, SessionClient{Country: "US"},
+		[]string{SignalCountry}, hashToken("alert-1")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.store.InsertSessionToken(ctx, RefreshToken{UserID: reader.ID, TokenHash: hashToken("rt-" + reader.ID.String()),
+		ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := m.DisownSignIn(ctx, "alert-1"); err != nil || got != reader.ID {
+		t.Fatalf("disown = %v, %v", got, err)
+	}
+	after, err := m.store.GetByID(ctx, reader.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.PasswordHash != "" || !after.PasswordResetRequired || after.AuthVersion <= reader.AuthVersion {
+		t.Errorf("account after disown = %+v", after)
+	}
+	var live int
+	if err := pool.QueryRow(ctx, `SELECT COUNT(*) FROM auth_refresh_tokens WHERE user_id = $1 AND revoked_at IS NULL`,
+		reader.ID).Scan(&live); err != nil || live != 0 {
+		t.Errorf("%d live tokens after disown (%v)", live, err)
+	}
+	if _, err := m.DisownSignIn(ctx, "alert-1"); !errors.Is(err, ErrSignInDisownInvalid) {
+		t.Errorf("second disown: %v", err)
+	}
+}
@@ -94,9 +94,22 @@ func (s *Store) CreateUserNamed(ctx context.Context, email, hash, first, last, m
 	}
 	defer tx.Rollback(ctx) //nolint:errcheck
 
+	u, err := s.createUserTx(ctx, tx, email, hash, first, last, middle, primaryRole, normalizedRoles)
+	if err != nil {
+		return User{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return User{}, fmt.Errorf("commit user: %w", err)
+	}
+	return u, nil
+}
+
+// createUserTx is CreateUserNamed inside tx, for callers that must create the
+// account together with something else or not at all.
+func (s *Store) createUserTx(ctx context.Context, tx pgx.Tx, email, hash, first, last, middle, primaryRole string, normalizedRoles []string) (User, error) {
 	userID := uuid.New()
 	var createdAt time.Time
-	err = tx.QueryRow(ctx, `
+	err := tx.QueryRow(ctx, `
 		INSERT INTO auth_users (id, email, password_hash, role, first_name, last_name, middle_name)
 		VALUES ($1, $2, $3, $4, $5, $6, $7)
 		RETURNING created_at
@@ -125,10 +138,6 @@ func (s *Store) CreateUserNamed(ctx context.Context, email, hash, first, last, m
 		}
 	}
 
-	if err := tx.Commit(ctx); err != nil {
-		return User{}, fmt.Errorf("commit user: %w", err)
-	}
-
 	return User{
 		ID:           userID,
 		Email:        email,
@@ -204,36 +213,38 @@ func (s *Store) GetByID(ctx context.Context, id string) (User, error) {
 	return u, nil
 }
 
-// RefreshToken represents a persisted refresh token.
+// RefreshToken represents a persisted refresh token: one row of a session (see
+// sessions.go). Rotation adds a row with the same SessionID, StartedAt and
+// Client.
 type RefreshToken struct {
-	ID        uuid.UUID
-	UserID    uuid.UUID
-	TokenHash string
-	ExpiresAt time.Time
-	CreatedAt time.Time
-	RevokedAt *time.Time
-}
-
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	TokenHash  string
+	ExpiresAt  time.Time
+	CreatedAt  time.Time
+	RevokedAt  *time.Time
+	SessionID  uuid.UUID
+	Kind       string
+	Client     SessionClient
+	StartedAt  time.Time
+	LastUsedAt time.Time
+}
+
+// InsertRefreshToken stores a refresh token that starts a session of its own.
 func (s *Store) InsertRefreshToken(ctx context.Context, userID uuid.UUID, tokenHash string, expiresAt time.Time) (RefreshToken, error) {
-	var rt RefreshToken
-	err := s.db.QueryRow(ctx, `
-		INSERT INTO auth_refresh_tokens (user_id, token_hash, expires_at)
-		VALUES ($1, $2, $3)
-		RETURNING id, user_id, token_hash, expires_at, created_at, revoked_at
-	`, userID, tokenHash, expiresAt).Scan(&rt.ID, &rt.UserID, &rt.TokenHash, &rt.ExpiresAt, &rt.CreatedAt, &rt.RevokedAt)
-	if err != nil {
-		return RefreshToken{}, fmt.Errorf("insert refresh token: %w", err)
-	}
-	return rt, nil
+	return s.InsertSessionToken(ctx, RefreshToken{UserID: userID, TokenHash: tokenHash, ExpiresAt: expiresAt})
 }
 
 func (s *Store) GetRefreshToken(ctx context.Context, tokenHash string) (RefreshToken, error) {
 	var rt RefreshToken
 	err := s.db.QueryRow(ctx, `
-		SELECT id, user_id, token_hash, expires_at, created_at, revoked_at
+		SELECT id, user_id, token_hash, expires_at, created_at, revoked_at,
+		       session_id, kind, device, browser, os, country, started_at, last_used_at
 		FROM auth_refresh_tokens
 		WHERE token_hash = $1
-	`, tokenHash).Scan(&rt.ID, &rt.UserID, &rt.TokenHash, &rt.ExpiresAt, &rt.CreatedAt, &rt.RevokedAt)
+	`, tokenHash).Scan(&rt.ID, &rt.UserID, &rt.TokenHash, &rt.ExpiresAt, &rt.CreatedAt, &rt.RevokedAt,
+		&rt.SessionID, &rt.Kind, &rt.Client.Device, &rt.Client.Browser, &rt.Client.OS, &rt.Client.Country,
+		&rt.StartedAt, &rt.LastUsedAt)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return RefreshToken{}, ErrRefreshNotFound
@@ -282,7 +293,7 @@ func (s *Store) ConsumeEmailVerification(ctx context.Context, tokenHash string)
 	)
 	err = tx.QueryRow(ctx,
 		`SELECT id, user_id FROM email_verification_tokens
-		 WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()`,
+		 WHERE token_hash = $1 AND purpose = 'verify' AND used_at IS NULL AND expires_at > NOW()`,
 		tokenHash).Scan(&id, &userID)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -660,6 +671,9 @@ func (s *Store) RevokeUserTokens(ctx context.Context, userID uuid.UUID) error {
 	return nil
 }
 
+// TrimActiveRefreshTokens keeps the account's newest keep API refresh tokens
+// and deletes the rest. Browser sessions are not counted: they end when their
+// cookie does.
 func (s *Store) TrimActiveRefreshTokens(ctx context.Context, userID uuid.UUID, keep int) error {
 	if keep <= 0 {
 		return nil
@@ -669,7 +683,7 @@ func (s *Store) TrimActiveRefreshTokens(ctx context.Context, userID uuid.UUID, k
 		WHERE id IN (
 			SELECT id
 			FROM auth_refresh_tokens
-			WHERE user_id = $1 AND revoked_at IS NULL
+			WHERE user_id = $1 AND revoked_at IS NULL AND kind = 'api'
 			ORDER BY created_at DESC
 			OFFSET $2
 		)
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 // TokenService issues and validates JWT tokens.
@@ -25,6 +26,10 @@ type Claims struct {
 	// a mismatch means the account was demoted, deleted or had its password
 	// changed since, and the token is spent.
 	AuthVersion int `json:"av,omitempty"`
+	// SessionID names the session (see sessions.go) the token was issued to.
+	// Revoking that session refuses the token on every check that asks, long
+	// before it expires. Tokens minted before sessions existed carry none.
+	SessionID string `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -39,6 +44,12 @@ func NewTokenService(secret string, ttl time.Duration) *TokenService {
 }
 
 func (s *TokenService) Generate(user User) (string, error) {
+	return s.GenerateSession(user, uuid.Nil)
+}
+
+// GenerateSession signs a token for user that belongs to the session
+// sessionID. uuid.Nil leaves the token outside any session, as Generate does.
+func (s *TokenService) GenerateSession(user User, sessionID uuid.UUID) (string, error) {
 	primary, roles := normalizeClaimRoles(user)
 	claims := Claims{
 		UserID:      user.ID.String(),
@@ -53,6 +64,9 @@ func (s *TokenService) Generate(user User) (string, error) {
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
+	if sessionID != uuid.Nil {
+		claims.SessionID = sessionID.String()
+	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	signed, err := token.SignedString(s.secret)
@@ -3,14 +3,26 @@ package health
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
+	"shanraq.org/internal/httpserver"
 	"shanraq.org/pkg/shanraq"
 )
 
+const (
+	// readinessBudget bounds a whole /readyz answer; checkTimeout bounds each
+	// check inside it. Probes run every few seconds, and a probe that waits on
+	// an SMTP handshake for half a minute is a failed probe regardless.
+	readinessBudget = 3 * time.Second
+	checkTimeout    = 2 * time.Second
+)
+
 // Module exposes canonical health and readiness endpoints.
 type Module struct {
 	rt *shanraq.Runtime
@@ -41,6 +53,24 @@ func (m *Module) Routes(r chi.Router) {
 	r.Get("/readyz", m.handleReadiness)
 }
 
+// HealthChecks contributes the one check every instance needs: the database.
+// It is the only critical one in the tree — without it no page renders.
+func (m *Module) HealthChecks() []shanraq.HealthCheck {
+	return []shanraq.HealthCheck{{
+		Name:     "database",
+		Critical: true,
+		Run: func(ctx context.Context) (string, error) {
+			if m.rt == nil || m.rt.DB == nil {
+				return "", errors.New("database not configured")
+			}
+			stat := m.rt.DB.Stat()
+			detail := fmt.Sprintf("%d total · %d idle · %d in-use",
+				stat.TotalConns(), stat.IdleConns(), stat.AcquiredConns())
+			return detail, m.rt.DB.Ping(ctx)
+		},
+	}}
+}
+
 func (m *Module) handleLiveness(w http.ResponseWriter, _ *http.Request) {
 	writeJSON(w, http.StatusOK, map[string]string{
 		"status":      "ok",
@@ -48,25 +78,79 @@ func (m *Module) handleLiveness(w http.ResponseWriter, _ *http.Request) {
 	})
 }
 
-func (m *Module) handleReadiness(w http.ResponseWriter, _ *http.Request) {
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+// handleReadiness answers with one status for the whole instance and one word
+// per check. ?verbose=1 adds details, errors and timings; those can carry
+// driver errors and host names, so the verbose view sits behind the same
+// bearer token as /metrics.
+func (m *Module) handleReadiness(w http.ResponseWriter, r *http.Request) {
+	verbose := r.URL.Query().Get("verbose") != ""
+	if verbose && !m.detailAllowed(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), readinessBudget)
 	defer cancel()
-	if err := m.rt.DB.Ping(ctx); err != nil {
-		// Log the detail; do not leak the raw driver error (host, DSN hints) to
-		// an unauthenticated caller.
-		m.rt.Logger.Warn("readiness check failed", zap.Error(err))
-		writeJSON(w, http.StatusServiceUnavailable, map[string]string{
-			"status": "degraded",
-			"error":  "database unavailable",
+	results := m.rt.CheckHealth(ctx, checkTimeout)
+	status, code := Summarize(results)
+
+	for _, res := range results {
+		if !res.OK {
+			m.rt.Logger.Warn("readiness check failed",
+				zap.String("check", res.Name),
+				zap.Bool("critical", res.Critical),
+				zap.String("error", res.Error))
+		}
+	}
+
+	if verbose {
+		writeJSON(w, code, map[string]any{
+			"status": status,
+			"checks": results,
 		})
 		return
 	}
-
-	writeJSON(w, http.StatusOK, map[string]string{
-		"status": "ready",
+	checks := make(map[string]string, len(results))
+	for _, res := range results {
+		checks[res.Name] = "ok"
+		if !res.OK {
+			checks[res.Name] = "fail"
+		}
+	}
+	writeJSON(w, code, map[string]any{
+		"status": status,
+		"checks": checks,
 	})
 }
 
+// Summarize folds check results into the instance status and HTTP code: any
+// critical failure is "unavailable" (503), any other failure "degraded" (200,
+// still in rotation), otherwise "ready".
+func Summarize(results []shanraq.HealthResult) (string, int) {
+	status, code := "ready", http.StatusOK
+	for _, res := range results {
+		if res.OK {
+			continue
+		}
+		if res.Critical {
+			return "unavailable", http.StatusServiceUnavailable
+		}
+		status = "degraded"
+	}
+	return status, code
+}
+
+// detailAllowed mirrors the /metrics guard: with a token configured it must be
+// presented; without one the detail is served outside production only.
+func (m *Module) detailAllowed(r *http.Request) bool {
+	token := strings.TrimSpace(m.rt.Config.Telemetry.MetricsToken)
+	if token == "" {
+		return !strings.EqualFold(m.rt.Config.Environment, "production")
+	}
+	return httpserver.BearerAuthorized(r, token)
+}
+
 func writeJSON(w http.ResponseWriter, status int, body any) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -77,4 +161,5 @@ var _ interface {
 	shanraq.Module
 	shanraq.RouterModule
 	shanraq.InitializerModule
+	shanraq.HealthChecker
 } = (*Module)(nil)
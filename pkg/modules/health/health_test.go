@@ -0,0 +1,60 @@
+package health
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"shanraq.org/internal/config"
+	"shanraq.org/pkg/shanraq"
+)
+
+func TestSummarize(t *testing.T) {
+	ok := shanraq.HealthResult{Name: "database", Critical: true, OK: true}
+	soft := shanraq.HealthResult{Name: "smtp", Error: "dial tcp: refused"}
+	hard := shanraq.HealthResult{Name: "database", Critical: true, Error: "ping: timeout"}
+
+	cases := []struct {
+		name    string
+		results []shanraq.HealthResult
+		status  string
+		code    int
+	}{
+		{"no checks", nil, "ready", http.StatusOK},
+		{"all ok", []shanraq.HealthResult{ok}, "ready", http.StatusOK},
+		{"non-critical failure", []shanraq.HealthResult{ok, soft}, "degraded", http.StatusOK},
+		{"critical failure", []shanraq.HealthResult{soft, hard}, "unavailable", http.StatusServiceUnavailable},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			status, code := Summarize(tc.results)
+			if status != tc.status || code != tc.code {
+				t.Fatalf("Summarize = %q/%d, want %q/%d", status, code, tc.status, tc.code)
+			}
+		})
+	}
+}
+
+func TestVerboseReadinessNeedsMetricsToken(t *testing.T) {
+	cfg := config.Config{Environment: "production"}
+	cfg.Telemetry.MetricsToken = "s3cret"
+	m := &Module{rt: &shanraq.Runtime{Config: cfg}}
+
+	req := httptest.NewRequest(http.MethodGet, "/readyz?verbose=1", nil)
+	if m.detailAllowed(req) {
+		t.Fatal("verbose readiness served without the token")
+	}
+	req.Header.Set("Authorization", "Bearer s3cret")
+	if !m.detailAllowed(req) {
+		t.Fatal("verbose readiness refused with the right token")
+	}
+
+	m.rt.Config.Telemetry.MetricsToken = ""
+	if m.detailAllowed(httptest.NewRequest(http.MethodGet, "/readyz?verbose=1", nil)) {
+		t.Fatal("tokenless verbose readiness must stay closed in production")
+	}
+	m.rt.Config.Environment = "development"
+	if !m.detailAllowed(httptest.NewRequest(http.MethodGet, "/readyz?verbose=1", nil)) {
+		t.Fatal("tokenless verbose readiness should be open in development")
+	}
+}
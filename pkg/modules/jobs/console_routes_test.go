@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/go-chi/chi/v5"
+	"shanraq.org/pkg/modules/auth"
 	"shanraq.org/pkg/shanraq"
 )
 
@@ -39,6 +40,21 @@ func TestConsoleRoutesAllSitBehindTheGuard(t *testing.T) {
 		{http.MethodPost, "/console/jobs"},
 		{http.MethodPost, "/console/jobs/6f1c1e3e-0000-0000-0000-000000000000/retry"},
 		{http.MethodPost, "/console/jobs/6f1c1e3e-0000-0000-0000-000000000000/cancel"},
+		{http.MethodGet, "/console/jobs/export"},
+		{http.MethodGet, "/console/jobs/archive"},
+		{http.MethodGet, "/console/jobs/queues"},
+		{http.MethodGet, "/console/jobs/workflows"},
+		{http.MethodGet, "/console/jobs/workflows/6f1c1e3e-0000-0000-0000-000000000000"},
+		{http.MethodPost, "/console/jobs/replay"},
+		{http.MethodPost, "/console/jobs/purge"},
+		{http.MethodGet, "/console/jobs/6f1c1e3e-0000-0000-0000-000000000000/attempts"},
+		{http.MethodGet, "/console/jobs/6f1c1e3e-0000-0000-0000-000000000000"},
+		{http.MethodGet, "/console/jobs/6f1c1e3e-0000-0000-0000-000000000000/stream"},
+		{http.MethodGet, "/console/jobs/schedules"},
+		{http.MethodPut, "/console/jobs/schedules/media_sweep"},
+		{http.MethodPost, "/console/jobs/schedules/media_sweep/enable"},
+		{http.MethodPost, "/console/jobs/schedules/media_sweep/disable"},
+		{http.MethodPost, "/console/jobs/schedules/media_sweep/run"},
 	} {
 		before := guarded
 		rec := httptest.NewRecorder()
@@ -64,3 +80,39 @@ func TestConsoleRoutesAreAbsentWithoutAGuard(t *testing.T) {
 		}
 	}
 }
+
+// Looking at the queue and acting on it are separate permissions: each route
+// that changes something asks the permission guard for its own.
+func TestActionRoutesAskForTheirPermission(t *testing.T) {
+	var asked string
+	m := &Module{rt: &shanraq.Runtime{}}
+	WithConsoleMiddleware(func(next http.Handler) http.Handler { return next })(m)
+	WithPermissionGuard(func(perm string) func(http.Handler) http.Handler {
+		return func(http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				asked = perm
+				w.WriteHeader(http.StatusForbidden)
+			})
+		}
+	})(m)
+	r := mount(m)
+
+	for _, c := range []struct{ method, path, perm string }{
+		{http.MethodPost, "/console/jobs", auth.PermJobsEnqueue},
+		{http.MethodPost, "/jobs/6f1c1e3e-0000-0000-0000-000000000000/retry", auth.PermJobsRetry},
+		{http.MethodPost, "/console/jobs/6f1c1e3e-0000-0000-0000-000000000000/cancel", auth.PermJobsRetry},
+		{http.MethodPost, "/console/jobs/replay", auth.PermJobsRetry},
+		{http.MethodPost, "/console/jobs/purge", auth.PermJobsPurge},
+		{http.MethodPut, "/console/jobs/schedules/media_sweep", auth.PermJobsSchedule},
+		{http.MethodPost, "/console/jobs/schedules/media_sweep/enable", auth.PermJobsSchedule},
+		{http.MethodPost, "/console/jobs/schedules/media_sweep/disable", auth.PermJobsSchedule},
+		{http.MethodPost, "/jobs/schedules/media_sweep/run", auth.PermJobsSchedule},
+	} {
+		asked = ""
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(c.method, c.path, nil))
+		if rec.Code != http.StatusForbidden || asked != c.perm {
+			t.Errorf("%s %s asked for %q (answered %d), want %q", c.method, c.path, asked, rec.Code, c.perm)
+		}
+	}
+}
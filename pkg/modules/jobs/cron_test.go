@@ -0,0 +1,57 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	// A Wednesday.
+	from := time.Date(2025, 11, 5, 10, 17, 30, 0, time.UTC)
+	for _, c := range []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2025, 11, 5, 10, 18, 0, 0, time.UTC)},
+		{"*/5 * * * *", time.Date(2025, 11, 5, 10, 20, 0, 0, time.UTC)},
+		{"15 */6 * * *", time.Date(2025, 11, 5, 12, 15, 0, 0, time.UTC)},
+		{"0 4 * * 1", time.Date(2025, 11, 10, 4, 0, 0, 0, time.UTC)},
+		{"0 0 1 1 *", time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2025, 11, 6, 0, 0, 0, 0, time.UTC)},
+		{"0 9 * * 7", time.Date(2025, 11, 9, 9, 0, 0, 0, time.UTC)},
+		{"0 9 1-5 * *", time.Date(2025, 12, 1, 9, 0, 0, 0, time.UTC)},
+		// Both day fields restricted: either one will do, as in cron.
+		{"0 0 13 * 5", time.Date(2025, 11, 7, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"@every 6h", time.Date(2025, 11, 5, 16, 17, 30, 0, time.UTC)},
+	} {
+		spec, err := parseSpec(c.spec)
+		if err != nil {
+			t.Fatalf("%q: %v", c.spec, err)
+		}
+		if got := spec.next(from); !got.Equal(c.want) {
+			t.Errorf("%q: next = %s, want %s", c.spec, got, c.want)
+		}
+	}
+}
+
+func TestCronNextNever(t *testing.T) {
+	spec, err := parseSpec("0 0 30 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := spec.next(time.Now()); !got.IsZero() {
+		t.Fatalf("February 30th came round at %s", got)
+	}
+}
+
+func TestCronRejectsBadSpecs(t *testing.T) {
+	for _, spec := range []string{
+		"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *",
+		"5-1 * * * *", "*/0 * * * *", "a * * * *", "@every 10s", "@fortnightly",
+	} {
+		if _, err := parseSpec(spec); err == nil {
+			t.Errorf("%q parsed", spec)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"shanraq.org/pkg/modules/auth"
+	"shanraq.org/pkg/transport/respond"
+)
+
+// exportLimit bounds one export. An outage that left more dead letters than
+// this is exported a filter at a time.
+const exportLimit = 5000
+
+// listFilters reads the filters the list, the export and the bulk actions
+// share: status, name, error (a substring of the last error) and since (RFC
+// 3339 or a date).
+func listFilters(q url.Values) (ListOptions, error) {
+	opts := ListOptions{
+		Status: strings.TrimSpace(q.Get("status")),
+		Name:   strings.TrimSpace(q.Get("name")),
+		Error:  strings.TrimSpace(q.Get("error")),
+	}
+	if raw := strings.TrimSpace(q.Get("since")); raw != "" {
+		since, err := parseSince(raw)
+		if err != nil {
+			return ListOptions{}, err
+		}
+		opts.Since = since
+	}
+	return opts, nil
+}
+
+func parseSince(raw string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, raw); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("since: want RFC 3339 or YYYY-MM-DD, got %q", raw)
+}
+
+func (m *Module) handleAttempts(w http.ResponseWriter, r *http.Request) {
+	jobID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, errors.New("invalid job id"))
+		return
+	}
+	var tenantPtr *uuid.UUID
+	if tenantID, ok := m.resolveTenant(r); ok {
+		tenantPtr = &tenantID
+	}
+	attempts, err := m.store.Attempts(r.Context(), jobID, tenantPtr)
+	if err != nil {
+		respond.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	if attempts == nil {
+		attempts = []Attempt{}
+	}
+	respond.JSON(w, http.StatusOK, attempts)
+}
+
+// bulkFilter reads a bulk action's body. A status is always set — "failed"
+// unless the caller says otherwise — so an empty body replays the dead
+// letters rather than everything the action is allowed to touch.
+func (m *Module) bulkFilter(r *http.Request) (ListOptions, error) {
+	var req bulkRequest
+	if err := respond.Decode(r, &req); err != nil && !errors.Is(err, io.EOF) {
+		return ListOptions{}, err
+	}
+	opts := ListOptions{
+		Status: strings.TrimSpace(req.Status),
+		Name:   strings.TrimSpace(req.Name),
+		Error:  strings.TrimSpace(req.Error),
+	}
+	if raw := strings.TrimSpace(req.Since); raw != "" {
+		since, err := parseSince(raw)
+		if err != nil {
+			return ListOptions{}, err
+		}
+		opts.Since = since
+	}
+	if opts.Status == "" {
+		opts.Status = "failed"
+	}
+	if tenantID, ok := m.resolveTenant(r); ok {
+		opts.UserID = &tenantID
+	}
+	return opts, nil
+}
+
+// handleReplay re-queues the failed (or cancelled) jobs matching the filter:
+// after an outage, "every ai_translate that failed since 09:00".
+func (m *Module) handleReplay(w http.ResponseWriter, r *http.Request) {
+	opts, err := m.bulkFilter(r)
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	n, err := m.store.Replay(r.Context(), opts)
+	if err != nil {
+		m.bulkError(w, err)
+		return
+	}
+	m.record(r, auth.AuditRecord{Action: "job.replay", TargetType: "jobs", After: bulkAudit(opts, n)})
+	respond.JSON(w, http.StatusOK, map[string]int{"replayed": n})
+}
+
+// handlePurge deletes the finished jobs matching the filter, history and all.
+func (m *Module) handlePurge(w http.ResponseWriter, r *http.Request) {
+	opts, err := m.bulkFilter(r)
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	n, err := m.store.Purge(r.Context(), opts)
+	if err != nil {
+		m.bulkError(w, err)
+		return
+	}
+	m.record(r, auth.AuditRecord{Action: "job.purge", TargetType: "jobs", After: bulkAudit(opts, n)})
+	respond.JSON(w, http.StatusOK, map[string]int{"purged": n})
+}
+
+// bulkAudit is what the audit log keeps of a bulk action: the filter it was
+// given and how many jobs it touched.
+func bulkAudit(opts ListOptions, n int) map[string]any {
+	out := map[string]any{"status": opts.Status, "jobs": n}
+	if opts.Name != "" {
+		out["name"] = opts.Name
+	}
+	if opts.Error != "" {
+		out["error"] = opts.Error
+	}
+	if !opts.Since.IsZero() {
+		out["since"] = opts.Since
+	}
+	return out
+}
+
+// handleExport downloads the jobs matching the filter, with their attempts,
+// as one JSON array — for the post-mortem, or to keep before a purge.
+func (m *Module) handleExport(w http.ResponseWriter, r *http.Request) {
+	opts, err := listFilters(r.URL.Query())
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	if opts.Status == "" {
+		opts.Status = "failed"
+	}
+	if tenantID, ok := m.resolveTenant(r); ok {
+		opts.UserID = &tenantID
+	}
+	jobs, err := m.store.Export(r.Context(), opts, exportLimit)
+	if err != nil {
+		respond.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	if jobs == nil {
+		jobs = []ExportedJob{}
+	}
+	name := "jobs-export-" + time.Now().UTC().Format("20060102-150405") + ".json"
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	respond.JSON(w, http.StatusOK, jobs)
+}
+
+func (m *Module) bulkError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrBulkStatus) {
+		respond.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	respond.Error(w, http.StatusInternalServerError, err)
+}
@@ -0,0 +1,156 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	pgxmock "github.com/pashagolub/pgxmock/v4"
+)
+
+func TestStoreReplayMatchesFilters(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("pgxmock: %v", err)
+	}
+	defer mock.Close()
+
+	since := time.Date(2025, 11, 8, 9, 0, 0, 0, time.UTC)
+	mock.ExpectExec(`UPDATE job_queue\s+SET status = CASE WHEN EXISTS.*attempts = 0.*WHERE status::text = ANY\(\$1\) AND status = \$2 AND name = \$3 AND updated_at >= \$4`).
+		WithArgs(replayable, "failed", "ai_translate", since).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 7))
+	mock.ExpectExec(`SELECT pg_notify\(\$1, \$2\)`).
+		WithArgs(notifyChannel, "").
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+
+	n, err := newStoreWithPool(mock).Replay(context.Background(), ListOptions{
+		Status: "failed",
+		Name:   "ai_translate",
+		Since:  since,
+	})
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if n != 7 {
+		t.Fatalf("replayed %d, want 7", n)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// Replaying running jobs would run them twice; purging pending ones would
+// lose work nobody has done yet. Neither reaches the database.
+func TestStoreBulkRefusesLiveStatuses(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("pgxmock: %v", err)
+	}
+	defer mock.Close()
+	store := newStoreWithPool(mock)
+
+	if _, err := store.Replay(context.Background(), ListOptions{Status: "running"}); !errors.Is(err, ErrBulkStatus) {
+		t.Fatalf("replay running: err = %v", err)
+	}
+	if _, err := store.Purge(context.Background(), ListOptions{Status: "pending"}); !errors.Is(err, ErrBulkStatus) {
+		t.Fatalf("purge pending: err = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestStoreListErrorFilterIsLiteral(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("pgxmock: %v", err)
+	}
+	defer mock.Close()
+
+	mock.ExpectQuery(`FROM job_queue WHERE last_error ILIKE '%' \|\| \$1 \|\| '%' ORDER BY`).
+		WithArgs(`100\% quota`, 50, 0).
+		WillReturnRows(pgxmock.NewRows([]string{
+			"id", "user_id", "name", "payload", "run_at", "attempts", "max_attempts", "status", "last_error", "created_at", "updated_at", "queue", "priority", "workflow_id",
+		}))
+
+	if _, err := newStoreWithPool(mock).List(context.Background(), ListOptions{Error: "100% quota"}); err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestStoreCancelIsNotAFailure(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("pgxmock: %v", err)
+	}
+	defer mock.Close()
+
+	id := uuid.New()
+	mock.ExpectExec(`UPDATE job_queue\s+SET status = 'cancelled'.*AND status IN \('pending', 'retry', 'running', 'blocked'\)`).
+		WithArgs(id, "operator").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	if err := newStoreWithPool(mock).Cancel(context.Background(), id, "operator", nil); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestStoreExportCarriesAttempts(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("pgxmock: %v", err)
+	}
+	defer mock.Close()
+
+	now := time.Now()
+	a, b := uuid.New(), uuid.New()
+	timeout, quota := "timeout", "quota exceeded"
+	mock.ExpectQuery(`FROM job_queue WHERE status = \$1 ORDER BY updated_at DESC LIMIT \$2`).
+		WithArgs("failed", 10).
+		WillReturnRows(pgxmock.NewRows([]string{
+			"id", "user_id", "name", "payload", "run_at", "attempts", "max_attempts", "status", "last_error", "created_at", "updated_at", "queue", "priority", "workflow_id",
+		}).
+			AddRow(a, nil, "ai_translate", []byte(`{}`), now, 2, 2, "failed", &quota, now, now, DefaultQueue, 0, nil).
+			AddRow(b, nil, "ai_translate", []byte(`{}`), now, 1, 1, "failed", &timeout, now, now, DefaultQueue, 0, nil))
+	mock.ExpectQuery(`FROM job_attempts\s+WHERE job_id = ANY\(\$1\)`).
+		WithArgs([]uuid.UUID{a, b}).
+		WillReturnRows(pgxmock.NewRows([]string{"job_id", "attempt", "outcome", "error", "started_at", "duration_ms"}).
+			AddRow(a, 1, OutcomeRetry, &timeout, now, int64(30000)).
+			AddRow(b, 1, OutcomeFailed, &timeout, now, int64(30000)).
+			AddRow(a, 2, OutcomeFailed, &quota, now, int64(120)))
+
+	out, err := newStoreWithPool(mock).Export(context.Background(), ListOptions{Status: "failed"}, 10)
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if len(out) != 2 || len(out[0].History) != 2 || len(out[1].History) != 1 {
+		t.Fatalf("export = %+v", out)
+	}
+	if *out[0].History[0].Error != "timeout" || *out[0].History[1].Error != "quota exceeded" {
+		t.Fatalf("history out of order: %+v", out[0].History)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestReplayRejectsBadSince(t *testing.T) {
+	m, _ := testWorker(t)
+	rec := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/jobs/replay", strings.NewReader(`{"since":"yesterday"}`))
+	mount(m).ServeHTTP(rec, r)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d (%s)", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,69 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"shanraq.org/pkg/shanraq"
+)
+
+// JobEventDelivery carries one domain event to one durable subscriber. Each
+// subscriber gets its own job, so a Telegram outage retries the Telegram post
+// and nothing else.
+const JobEventDelivery = "event_delivery"
+
+// eventMaxAttempts gives a durable subscriber a little more patience than the
+// default: the upstreams they talk to (Telegram, webhooks) have bad minutes.
+const eventMaxAttempts = 5
+
+// EnqueueEvent makes the queue the event bus's durable transport. A keyed
+// delivery becomes a unique key scoped to its subscriber — one subscriber's
+// dedupe must not swallow another's delivery of the same event. A publisher
+// that set WithAfter on ctx has its deliveries wait for those jobs.
+func (m *Module) EnqueueEvent(ctx context.Context, d shanraq.EventDelivery) error {
+	payload, err := json.Marshal(d)
+	if err != nil {
+		return fmt.Errorf("encode event delivery: %w", err)
+	}
+	job := Job{
+		ID:          uuid.New(),
+		Name:        JobEventDelivery,
+		Payload:     payload,
+		RunAt:       time.Now(),
+		MaxAttempts: eventMaxAttempts,
+	}
+	if d.Key != "" {
+		job.UniqueKey = d.Subscriber + ":" + d.Event + ":" + d.Key
+		job.UniqueScope = ScopeActive
+		if d.Window > 0 {
+			job.UniqueScope, job.UniqueFor = ScopeWindow, d.Window
+		}
+	}
+	if after, ok := afterFromContext(ctx); ok {
+		job.After, job.AfterFailure = after.Jobs, after.OnFailure
+	}
+	_, err = m.store.Enqueue(ctx, job)
+	return err
+}
+
+func (m *Module) handleEventDelivery(ctx context.Context, rt *shanraq.Runtime, job Job) error {
+	var d shanraq.EventDelivery
+	if err := job.Decode(&d); err != nil {
+		return Permanent(fmt.Errorf("decode event delivery: %w", err))
+	}
+	err := rt.Events.Deliver(ctx, d)
+	if errors.Is(err, shanraq.ErrUnknownSubscriber) {
+		// Queued before a deploy that removed the subscriber. Retrying cannot
+		// bring it back; finish the job instead of burning its attempts.
+		rt.Logger.Warn("event delivery dropped", zap.String("event", d.Event), zap.String("subscriber", d.Subscriber))
+		return nil
+	}
+	return err
+}
+
+var _ shanraq.EventQueue = (*Module)(nil)
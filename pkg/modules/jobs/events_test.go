@@ -0,0 +1,66 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	pgxmock "github.com/pashagolub/pgxmock/v4"
+	"go.uber.org/zap"
+	"shanraq.org/pkg/shanraq"
+)
+
+type deliveredEvent struct {
+	N int `json:"n"`
+}
+
+func (deliveredEvent) EventName() string { return "test.event" }
+
+func TestEventDeliveryThroughQueue(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("pgxmock: %v", err)
+	}
+	defer mock.Close()
+
+	rt := &shanraq.Runtime{Logger: zap.NewNop(), Events: shanraq.NewEventBus(zap.NewNop())}
+	m := New()
+	m.rt = rt
+	m.store = newStoreWithPool(mock)
+	rt.Events.UseQueue(m)
+
+	var got []int
+	shanraq.SubscribeDurable(rt.Events, "test.sink", func(_ context.Context, ev deliveredEvent) error {
+		got = append(got, ev.N)
+		return nil
+	})
+
+	mock.ExpectExec("INSERT INTO job_queue").
+		WithArgs(pgxmock.AnyArg(), nil, JobEventDelivery, pgxmock.AnyArg(), pgxmock.AnyArg(), eventMaxAttempts, DefaultQueue, 0, nil).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec(`SELECT pg_notify`).
+		WithArgs(notifyChannel, DefaultQueue).
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+
+	if err := rt.Events.Publish(context.Background(), deliveredEvent{N: 7}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+
+	stored, _ := json.Marshal(shanraq.EventDelivery{Event: "test.event", Subscriber: "test.sink", Payload: json.RawMessage(`{"n":7}`)})
+	if err := m.handleEventDelivery(context.Background(), rt, Job{Name: JobEventDelivery, Payload: stored}); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+	if len(got) != 1 || got[0] != 7 {
+		t.Fatalf("subscriber saw %v, want [7]", got)
+	}
+
+	// A delivery for a subscriber removed since it was queued finishes the job
+	// rather than retrying something that can never succeed.
+	stale, _ := json.Marshal(shanraq.EventDelivery{Event: "test.event", Subscriber: "gone", Payload: json.RawMessage(`{}`)})
+	if err := m.handleEventDelivery(context.Background(), rt, Job{Name: JobEventDelivery, Payload: stale}); err != nil {
+		t.Fatalf("stale delivery should be dropped, got %v", err)
+	}
+}
@@ -4,10 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -17,6 +20,8 @@ import (
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
+	"shanraq.org/internal/config"
+	"shanraq.org/pkg/modules/auth"
 	"shanraq.org/pkg/shanraq"
 	"shanraq.org/pkg/transport/respond"
 	"shanraq.org/pkg/transport/validate"
@@ -27,18 +32,52 @@ type Handler func(context.Context, *shanraq.Runtime, Job) error
 
 // Module adds HTTP endpoints and background workers for the job queue.
 type Module struct {
-	rt             *shanraq.Runtime
-	store          *Store
-	workerCount    int
-	pollInterval   time.Duration
-	handlers       map[string]Handler
+	rt           *shanraq.Runtime
+	store        *Store
+	workerCount  int
+	pollInterval time.Duration
+	handlers     map[string]Handler
+	policies     map[string]RetryPolicy
+	// schedules maps each recurring job to the spec its code registered.
+	schedules      map[string]string
 	tenantResolver TenantResolver
 	httpMiddleware []func(http.Handler) http.Handler
 	// consoleMiddleware guards the browser-facing mount of the same endpoints;
 	// empty means the console mount is not registered at all.
 	consoleMiddleware []func(http.Handler) http.Handler
-	validator         *validate.Validator
-	tracer            trace.Tracer
+	// permission guards single routes of both mounts; see WithPermissionGuard.
+	permission func(perm string) func(http.Handler) http.Handler
+	// audit records the operator actions; see WithAudit.
+	audit     func(r *http.Request, rec auth.AuditRecord)
+	validator *validate.Validator
+	tracer    trace.Tracer
+
+	// stopping is closed by Stop so workers finish the job in hand and claim
+	// nothing new; cancelWork aborts the job in hand once the drain deadline
+	// has passed. workers tracks the loops so Stop can wait for them.
+	stopping   chan struct{}
+	stopOnce   sync.Once
+	mu         sync.Mutex
+	cancelWork context.CancelFunc
+	workers    sync.WaitGroup
+
+	// lastPoll is the unix-nano time any worker last asked for a job; the
+	// health check reads it to tell an idle queue from a wedged one.
+	lastPoll atomic.Int64
+
+	// dial opens the listener's connection; nil (no database, as in tests)
+	// leaves the workers polling. The listener wakes workers through their
+	// pool's wake channel, and listening says whether it currently can.
+	dial      func(ctx context.Context) (listenerConn, error)
+	listening atomic.Bool
+
+	// queues are the named queues given a pool by WithQueue; pools are the
+	// worker pools, the default one first.
+	queues map[string]QueueConfig
+	pools  []*pool
+
+	// retention is what the pruner keeps, swapped on a config reload.
+	retention atomic.Pointer[config.JobRetentionConfig]
 }
 
 // JobContext key used for context values.
@@ -48,6 +87,10 @@ type jobContextKey struct{}
 type JobContext struct {
 	WorkerIndex int
 	Attempts    int
+
+	heartbeat func(context.Context) error
+	progress  func(context.Context, Progress) error
+	result    func(context.Context, json.RawMessage) error
 }
 
 // InfoFromContext extracts metadata about the running job from the context.
@@ -59,7 +102,7 @@ func InfoFromContext(ctx context.Context) (JobContext, bool) {
 // Option customizes the jobs module.
 type Option func(*Module)
 
-// WithWorkerCount overrides the default worker parallelism.
+// WithWorkerCount sizes the default queue's pool (see WithQueue).
 func WithWorkerCount(n int) Option {
 	return func(m *Module) {
 		if n > 0 {
@@ -68,7 +111,11 @@ func WithWorkerCount(n int) Option {
 	}
 }
 
-// WithPollInterval sets the fetch cadence for workers.
+// WithPollInterval sets how often an idle worker looks for due jobs on its
+// own. Workers are woken when a job is enqueued, so this is the safety net:
+// it picks up retries and jobs enqueued to run later once they come due, and
+// anything whose notification was lost. While the listener is down workers
+// poll every two seconds, or at this interval if it is shorter.
 func WithPollInterval(d time.Duration) Option {
 	return func(m *Module) {
 		if d > 0 {
@@ -107,16 +154,45 @@ func WithConsoleMiddleware(mw ...func(http.Handler) http.Handler) Option {
 	}
 }
 
-// New creates a jobs module with sane defaults (2 workers, 1s poll interval).
+// WithPermissionGuard asks guard for the middleware that lets a request
+// through only with the named permission, and puts it on each route that
+// needs more than looking: auth.PermJobsEnqueue on enqueue,
+// auth.PermJobsRetry on retry, cancel and replay, auth.PermJobsPurge on purge,
+// auth.PermJobsSchedule on changing and running schedules. The mounts' own
+// middleware decides who may look at all. Without a guard, whoever passes the
+// mount's middleware may do everything, as before.
+func WithPermissionGuard(guard func(perm string) func(http.Handler) http.Handler) Option {
+	return func(m *Module) {
+		m.permission = guard
+	}
+}
+
+// WithAudit hands each operator action — retry, cancel, replay, purge and
+// every schedule change — to record once it has succeeded; auth.Module.Audit
+// is the usual one. Enqueue is left out: it is also how the site's own code
+// and API clients queue work, and the log would drown in it.
+func WithAudit(record func(r *http.Request, rec auth.AuditRecord)) Option {
+	return func(m *Module) {
+		m.audit = record
+	}
+}
+
+// New creates a jobs module with sane defaults (2 workers, 10s safety-net
+// poll).
 func New(opts ...Option) *Module {
 	m := &Module{
 		workerCount:  2,
-		pollInterval: time.Second,
+		pollInterval: 10 * time.Second,
 		handlers:     map[string]Handler{},
+		policies:     map[string]RetryPolicy{},
+		schedules:    map[string]string{},
+		queues:       map[string]QueueConfig{},
+		stopping:     make(chan struct{}),
 	}
 	for _, opt := range opts {
 		opt(m)
 	}
+	m.buildPools()
 	return m
 }
 
@@ -124,16 +200,18 @@ func (m *Module) Name() string {
 	return "jobs"
 }
 
-// Handle registers a handler for the named job.
-func (m *Module) Handle(name string, handler Handler) {
+// Handle registers a handler for the named job. Options shape how its
+// failures are retried; without them DefaultRetryPolicy applies.
+func (m *Module) Handle(name string, handler Handler, opts ...HandleOption) {
 	m.handlers[name] = handler
+	m.policies[name] = newRetryPolicy(opts)
 }
 
 // HandleFunc registers a handler function without requiring direct runtime access.
-func (m *Module) HandleFunc(name string, fn func(context.Context, Job) error) {
+func (m *Module) HandleFunc(name string, fn func(context.Context, Job) error, opts ...HandleOption) {
 	m.Handle(name, func(ctx context.Context, _ *shanraq.Runtime, job Job) error {
 		return fn(ctx, job)
-	})
+	}, opts...)
 }
 
 // Init wires runtime dependencies.
@@ -142,6 +220,14 @@ func (m *Module) Init(_ context.Context, rt *shanraq.Runtime) error {
 	m.store = NewStore(rt.DB)
 	m.validator = validate.New()
 	m.tracer = otel.Tracer("shanraq.org/jobs")
+	if rt.DB != nil {
+		m.dial = m.dialListener
+	}
+	m.Handle(JobEventDelivery, m.handleEventDelivery, WithBackoff(30*time.Second, time.Hour))
+	retention := rt.Config.Jobs.Retention
+	m.retention.Store(&retention)
+	m.Schedule(JobPrune, pruneSpec, m.handlePrune)
+	rt.Events.UseQueue(m)
 	return nil
 }
 
@@ -155,10 +241,7 @@ func (m *Module) Routes(r chi.Router) {
 		for _, mw := range m.httpMiddleware {
 			r.Use(mw)
 		}
-		r.Post("/", m.handleEnqueue)
-		r.Get("/", m.handleList)
-		r.Post("/{id}/retry", m.handleRetry)
-		r.Post("/{id}/cancel", m.handleCancel)
+		m.queueRoutes(r)
 	})
 
 	// The same handlers, behind whatever the caller put in front of the console.
@@ -169,15 +252,49 @@ func (m *Module) Routes(r chi.Router) {
 			for _, mw := range m.consoleMiddleware {
 				r.Use(mw)
 			}
-			r.Post("/", m.handleEnqueue)
-			r.Get("/", m.handleList)
-			r.Post("/{id}/retry", m.handleRetry)
-			r.Post("/{id}/cancel", m.handleCancel)
+			m.queueRoutes(r)
 		})
 	}
 }
 
-// Start launches worker goroutines consuming jobs until ctx cancels.
+// queueRoutes are mounted at /jobs and again at /console/jobs.
+func (m *Module) queueRoutes(r chi.Router) {
+	r.With(m.requires(auth.PermJobsEnqueue)).Post("/", m.handleEnqueue)
+	r.Get("/", m.handleList)
+	r.Get("/export", m.handleExport)
+	r.Get("/archive", m.handleArchive)
+	r.Get("/queues", m.handleQueues)
+	r.Get("/workflows", m.handleWorkflows)
+	r.Get("/workflows/{id}", m.handleWorkflow)
+	r.With(m.requires(auth.PermJobsRetry)).Post("/replay", m.handleReplay)
+	r.With(m.requires(auth.PermJobsPurge)).Post("/purge", m.handlePurge)
+	r.Get("/{id}", m.handleGet)
+	r.Get("/{id}/stream", m.handleStream)
+	r.Get("/{id}/attempts", m.handleAttempts)
+	r.With(m.requires(auth.PermJobsRetry)).Post("/{id}/retry", m.handleRetry)
+	r.With(m.requires(auth.PermJobsRetry)).Post("/{id}/cancel", m.handleCancel)
+	m.scheduleRoutes(r)
+}
+
+// record reports an operator action to the audit hook, if there is one.
+func (m *Module) record(r *http.Request, rec auth.AuditRecord) {
+	if m.audit != nil {
+		m.audit(r, rec)
+	}
+}
+
+// requires is the permission guard for perm, or nothing without one.
+func (m *Module) requires(perm string) func(http.Handler) http.Handler {
+	if m.permission == nil {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	return m.permission(perm)
+}
+
+// Start launches worker goroutines consuming jobs until ctx cancels, the
+// listener that wakes them when a job is enqueued, the reaper that recovers
+// jobs from workers that died holding them, the scheduler that enqueues
+// recurring jobs as they come due, and the loop behind the queue gauges.
 func (m *Module) Start(ctx context.Context, rt *shanraq.Runtime) error {
 	if m.store == nil {
 		return errors.New("jobs store uninitialized")
@@ -186,43 +303,201 @@ func (m *Module) Start(ctx context.Context, rt *shanraq.Runtime) error {
 		m.rt = rt
 	}
 
-	for i := 0; i < m.workerCount; i++ {
-		go m.workerLoop(ctx, i)
+	workCtx, cancel := context.WithCancel(ctx)
+	m.mu.Lock()
+	m.cancelWork = cancel
+	m.mu.Unlock()
+	if m.dial != nil {
+		m.workers.Add(1)
+		go func() {
+			defer m.workers.Done()
+			m.listenLoop(workCtx)
+		}()
+	}
+	for _, p := range m.pools {
+		for i := range p.workers {
+			m.workers.Add(1)
+			go func() {
+				defer m.workers.Done()
+				m.workerLoop(workCtx, p, i)
+			}()
+		}
+	}
+	m.workers.Add(1)
+	go func() {
+		defer m.workers.Done()
+		m.reaperLoop(workCtx)
+	}()
+	m.workers.Add(1)
+	go func() {
+		defer m.workers.Done()
+		m.gaugeLoop(workCtx)
+	}()
+	if len(m.schedules) > 0 {
+		m.workers.Add(1)
+		go func() {
+			defer m.workers.Done()
+			m.schedulerLoop(workCtx)
+		}()
 	}
 
 	<-ctx.Done()
 	return ctx.Err()
 }
 
-func (m *Module) workerLoop(ctx context.Context, idx int) {
-	ticker := time.NewTicker(m.pollInterval)
-	defer ticker.Stop()
+// Stop tells the workers to claim nothing more and waits for the jobs already
+// running. A job still running at the deadline has its context cancelled; the
+// worker then records it for retry, so it is not left in "running" with nobody
+// working on it.
+func (m *Module) Stop(ctx context.Context) error {
+	m.stopOnce.Do(func() { close(m.stopping) })
+	done := make(chan struct{})
+	go func() {
+		m.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		m.mu.Lock()
+		if m.cancelWork != nil {
+			m.cancelWork()
+		}
+		m.mu.Unlock()
+		return fmt.Errorf("jobs drain: %w", ctx.Err())
+	}
+}
+
+// workerLoop sleeps until it is woken or its idle poll comes round, then works
+// through the due jobs until there are none left.
+func (m *Module) workerLoop(ctx context.Context, p *pool, idx int) {
+	timer := time.NewTimer(m.idlePoll())
+	defer timer.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-ticker.C:
-			job, err := m.store.ClaimNextJob(ctx)
-			if err != nil {
-				if errors.Is(err, ErrNoJobs) {
-					continue
-				}
+		case <-m.stopping:
+			return
+		case <-p.wake:
+		case <-timer.C:
+		}
+		m.drain(ctx, p, idx)
+		timer.Reset(m.idlePoll())
+	}
+}
+
+// drain claims and runs the pool's jobs until none is due or the module is
+// stopping. A worker that has just finished one looks for the next at once
+// rather than sleeping on a queue that still has work in it. A rate-limited
+// pool waits for its turn before each claim.
+func (m *Module) drain(ctx context.Context, p *pool, idx int) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-m.stopping:
+			return
+		default:
+		}
+		if p.pacer != nil && !p.pacer.wait(ctx, m.stopping) {
+			return
+		}
+		m.lastPoll.Store(time.Now().UnixNano())
+		job, err := m.store.ClaimNextJob(ctx, p.from, p.ids[idx], m.leaseFor)
+		if err != nil {
+			if !errors.Is(err, ErrNoJobs) {
 				m.rt.Logger.Error("claim job", zap.Error(err))
-				continue
 			}
-			m.processJob(ctx, job, idx)
+			return
+		}
+		m.processJob(ctx, job, idx)
+	}
+}
+
+// RunDue runs every job that is due, one after another in the caller's
+// goroutine, and returns how many ran — jobs enqueued by those jobs included,
+// and those of schedules that have come due.
+// A failure is recorded as a retry after the delay its handler's RetryPolicy
+// gives (DefaultRetryPolicy's starts at fifteen seconds), so a failing job
+// runs once per call rather than until it gives up. It is for tests, which
+// would otherwise wait on the workers' poll; the application never calls it.
+func (m *Module) RunDue(ctx context.Context) (int, error) {
+	if m.store == nil {
+		return 0, errors.New("jobs store uninitialized")
+	}
+	if len(m.schedules) > 0 {
+		if err := m.syncSchedules(ctx); err != nil {
+			return 0, err
+		}
+		if _, err := m.fireSchedules(ctx); err != nil {
+			return 0, err
+		}
+	}
+	ran := 0
+	for {
+		job, err := m.store.ClaimNextJob(ctx, QueueFilter{}, workerID(DefaultQueue, 0), m.leaseFor)
+		if errors.Is(err, ErrNoJobs) {
+			return ran, nil
+		}
+		if err != nil {
+			return ran, err
 		}
+		m.processJob(ctx, job, 0)
+		ran++
 	}
 }
 
+// maxQueueLag is how long a due job may wait before the queue reports itself
+// degraded. Generous, because a burst of translations legitimately occupies
+// every worker for a few minutes.
+const maxQueueLag = 5 * time.Minute
+
+// HealthChecks reports whether the workers are polling and keeping up.
+func (m *Module) HealthChecks() []shanraq.HealthCheck {
+	return []shanraq.HealthCheck{{
+		Name: "jobs",
+		Run: func(ctx context.Context) (string, error) {
+			if m.store == nil {
+				return "", errors.New("jobs store uninitialized")
+			}
+			lag, err := m.store.Lag(ctx)
+			if err != nil {
+				return "", err
+			}
+			mode := "polling"
+			if m.listening.Load() {
+				mode = "listening"
+			}
+			detail := fmt.Sprintf("%d due · oldest %s · %d workers %s", lag.Due, lag.Oldest.Round(time.Second), m.totalWorkers(), mode)
+			if last := m.lastPoll.Load(); last > 0 {
+				stale := max(time.Minute, 10*m.pollInterval)
+				if since := time.Since(time.Unix(0, last)); since > stale {
+					return detail, fmt.Errorf("no worker has polled for %s", since.Round(time.Second))
+				}
+			}
+			if lag.Oldest > maxQueueLag {
+				return detail, fmt.Errorf("oldest due job has waited %s", lag.Oldest.Round(time.Second))
+			}
+			return detail, nil
+		},
+	}}
+}
+
 func (m *Module) processJob(ctx context.Context, job Job, workerIdx int) {
 	var span trace.Span
 	if m.tracer != nil {
-		ctx, span = m.tracer.Start(ctx, "jobs.process",
+		// The attempt is a span in the trace that enqueued the job — the
+		// publish request behind a translation, say — however much later
+		// it runs and on whichever instance.
+		ctx, span = m.tracer.Start(withTraceContext(ctx, job.traceContext), "jobs.process",
+			trace.WithSpanKind(trace.SpanKindConsumer),
 			trace.WithAttributes(
 				attribute.String("jobs.id", job.ID.String()),
 				attribute.String("jobs.name", job.Name),
+				attribute.String("jobs.queue", job.Queue),
 				attribute.Int("jobs.worker_index", workerIdx),
 				attribute.Int("jobs.attempt", job.Attempts+1),
 			),
@@ -230,10 +505,55 @@ func (m *Module) processJob(ctx context.Context, job Job, workerIdx int) {
 		defer span.End()
 	}
 
+	// The outcome is written even when the handler was cut off by shutdown:
+	// a job whose context was cancelled mid-run still has to leave "running".
+	record := context.WithoutCancel(ctx)
+
+	// Every attempt goes into job_attempts, so a dead letter shows each error
+	// it met on the way, not only the last.
+	started := time.Now()
+	history := func(outcome string, err error) {
+		a := Attempt{
+			JobID:      job.ID,
+			Attempt:    job.Attempts,
+			Outcome:    outcome,
+			StartedAt:  started,
+			DurationMS: time.Since(started).Milliseconds(),
+		}
+		if err != nil {
+			msg := err.Error()
+			a.Error = &msg
+		}
+		if err := m.store.RecordAttempt(record, a); err != nil {
+			m.rt.Logger.Warn("record job attempt", zap.String("job_id", job.ID.String()), zap.Error(err))
+		}
+		observe(job, outcome, time.Since(started))
+	}
+
+	// The row is not ours any more: the reaper has recorded this attempt and
+	// requeued the job, another worker has claimed it since, or an operator
+	// cancelled it. Writing an outcome, an attempt or a workflow step now
+	// would overwrite theirs, and run the job's successors twice.
+	leaseLost := func() {
+		m.rt.Logger.Warn("job lease lost", zap.String("job_id", job.ID.String()), zap.String("name", job.Name))
+		observe(job, outcomeLeaseLost, time.Since(started))
+		if span != nil && span.IsRecording() {
+			span.SetAttributes(attribute.String("jobs.status", "lease_lost"))
+			span.SetStatus(codes.Error, "lease lost")
+		}
+	}
+
 	handler, ok := m.handlers[job.Name]
 	if !ok {
 		m.rt.Logger.Warn("job handler missing", zap.String("name", job.Name))
-		_ = m.store.MarkFailed(ctx, job.ID, "handler missing")
+		if err := m.store.MarkFailed(record, job.ID, job.LockedBy, "handler missing"); errors.Is(err, ErrLeaseLost) {
+			leaseLost()
+			return
+		} else if err != nil {
+			m.rt.Logger.Error("mark failed", zap.Error(err))
+		}
+		history(OutcomeFailed, errors.New("handler missing"))
+		m.advance(record, job)
 		if span != nil && span.IsRecording() {
 			span.SetAttributes(attribute.String("jobs.status", "handler_missing"))
 			span.SetStatus(codes.Error, "handler missing")
@@ -247,20 +567,42 @@ func (m *Module) processJob(ctx context.Context, job Job, workerIdx int) {
 	ctxWithMeta := context.WithValue(ctx, jobContextKey{}, JobContext{
 		WorkerIndex: workerIdx,
 		Attempts:    job.Attempts,
+		heartbeat:   m.heartbeat(job),
+		progress:    m.reportProgress(job),
+		result:      m.reportResult(job),
 	})
 
 	if err := handler(ctxWithMeta, m.rt, input); err != nil {
+		if errors.Is(err, ErrLeaseLost) {
+			leaseLost()
+			return
+		}
 		m.rt.Logger.Warn("job errored", zap.String("job_id", job.ID.String()), zap.String("name", job.Name), zap.Error(err))
 		if span != nil && span.IsRecording() {
 			span.RecordError(err)
 			span.SetAttributes(attribute.String("jobs.status", "error"))
 			span.SetStatus(codes.Error, err.Error())
 		}
-		if job.Attempts >= job.MaxAttempts {
-			_ = m.store.MarkFailed(ctx, job.ID, err.Error())
+		policy, ok := m.policies[job.Name]
+		if !ok {
+			policy = DefaultRetryPolicy
+		}
+		if job.Attempts >= policy.maxAttempts(job) || !policy.retryable(err) {
+			if markErr := m.store.MarkFailed(record, job.ID, job.LockedBy, err.Error()); errors.Is(markErr, ErrLeaseLost) {
+				leaseLost()
+				return
+			} else if markErr != nil {
+				m.rt.Logger.Error("mark failed", zap.Error(markErr))
+			}
+			history(OutcomeFailed, err)
+			m.advance(record, job)
 			return
 		}
-		if err := m.store.MarkRetry(ctx, job.ID, err.Error(), nil); err != nil {
+		delay := policy.delay(job.Attempts, err)
+		if err := m.store.MarkRetry(record, job.ID, job.LockedBy, err.Error(), delay, nil); errors.Is(err, ErrLeaseLost) {
+			leaseLost()
+			return
+		} else if err != nil {
 			m.rt.Logger.Error("mark retry", zap.Error(err))
 			if span != nil && span.IsRecording() {
 				span.RecordError(err)
@@ -269,10 +611,14 @@ func (m *Module) processJob(ctx context.Context, job Job, workerIdx int) {
 			span.SetAttributes(attribute.String("jobs.status", "retry"))
 			span.SetStatus(codes.Ok, "scheduled retry")
 		}
+		history(OutcomeRetry, err)
 		return
 	}
 
-	if err := m.store.MarkDone(ctx, job.ID); err != nil {
+	if err := m.store.MarkDone(record, job.ID, job.LockedBy); errors.Is(err, ErrLeaseLost) {
+		leaseLost()
+		return
+	} else if err != nil {
 		m.rt.Logger.Error("mark done", zap.Error(err))
 		if span != nil && span.IsRecording() {
 			span.RecordError(err)
@@ -280,6 +626,8 @@ func (m *Module) processJob(ctx context.Context, job Job, workerIdx int) {
 		}
 		return
 	}
+	history(OutcomeDone, nil)
+	m.advance(record, job)
 	m.rt.Logger.Info("job completed", zap.String("job_id", job.ID.String()), zap.String("name", job.Name))
 	if span != nil && span.IsRecording() {
 		span.SetAttributes(attribute.String("jobs.status", "success"))
@@ -299,6 +647,17 @@ func (m *Module) handleEnqueue(w http.ResponseWriter, r *http.Request) {
 		respond.Error(w, http.StatusBadRequest, err)
 		return
 	}
+	var uniqueFor time.Duration
+	if req.UniqueScope == string(ScopeWindow) {
+		d, err := time.ParseDuration(req.UniqueFor)
+		if err != nil || d <= 0 {
+			if fields == nil {
+				fields = map[string]string{}
+			}
+			fields["unique_for"] = "a positive duration such as 24h is required with the window scope"
+		}
+		uniqueFor = d
+	}
 	if len(fields) > 0 {
 		respond.Validation(w, fields)
 		return
@@ -328,15 +687,34 @@ func (m *Module) handleEnqueue(w http.ResponseWriter, r *http.Request) {
 		Payload:     payload,
 		RunAt:       runAt,
 		MaxAttempts: maxAttempts,
+		UniqueKey:   strings.TrimSpace(req.UniqueKey),
+		UniqueScope: UniqueScope(req.UniqueScope),
+		UniqueFor:   uniqueFor,
+		Queue:       strings.TrimSpace(req.Queue),
+		Priority:    req.Priority,
 	}
 	if tenantID, ok := m.resolveTenant(r); ok {
 		job.UserID = tenantID
+		// Keys are per tenant: one user's key must neither block another's
+		// job nor hand them its ID.
+		if job.UniqueKey != "" {
+			job.UniqueKey = "user:" + tenantID.String() + ":" + job.UniqueKey
+		}
 	}
 
-	if err := m.store.Enqueue(r.Context(), job); err != nil {
+	queued, err := m.store.Enqueue(r.Context(), job)
+	if err != nil {
 		respond.Error(w, http.StatusInternalServerError, err)
 		return
 	}
+	if queued.ID != job.ID {
+		respond.JSON(w, http.StatusOK, map[string]any{
+			"id":        queued.ID.String(),
+			"status":    queued.Status,
+			"duplicate": true,
+		})
+		return
+	}
 
 	respond.JSON(w, http.StatusAccepted, map[string]string{
 		"id": job.ID.String(),
@@ -346,21 +724,18 @@ func (m *Module) handleEnqueue(w http.ResponseWriter, r *http.Request) {
 func (m *Module) handleList(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	query := r.URL.Query()
-	status := query.Get("status")
-	limit, _ := strconv.Atoi(query.Get("limit"))
-	offset, _ := strconv.Atoi(query.Get("offset"))
-
-	var tenantPtr *uuid.UUID
+	opts, err := listFilters(query)
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	opts.Limit, _ = strconv.Atoi(query.Get("limit"))
+	opts.Offset, _ = strconv.Atoi(query.Get("offset"))
 	if tenantID, ok := m.resolveTenant(r); ok {
-		tenantPtr = &tenantID
+		opts.UserID = &tenantID
 	}
 
-	jobs, err := m.store.List(ctx, ListOptions{
-		Status: status,
-		Limit:  limit,
-		Offset: offset,
-		UserID: tenantPtr,
-	})
+	jobs, err := m.store.List(ctx, opts)
 	if err != nil {
 		respond.Error(w, http.StatusInternalServerError, err)
 		return
@@ -379,9 +754,14 @@ func (m *Module) handleRetry(w http.ResponseWriter, r *http.Request) {
 		tenantPtr = &tenantID
 	}
 	if err := m.store.MarkPending(r.Context(), jobID, tenantPtr); err != nil {
+		if errors.Is(err, ErrDuplicateJob) {
+			respond.Error(w, http.StatusConflict, err)
+			return
+		}
 		respond.Error(w, http.StatusInternalServerError, err)
 		return
 	}
+	m.record(r, auth.AuditRecord{Action: "job.retry", TargetType: "job", TargetID: jobID.String()})
 	respond.JSON(w, http.StatusOK, map[string]string{"status": "queued"})
 }
 
@@ -406,6 +786,13 @@ func (m *Module) handleCancel(w http.ResponseWriter, r *http.Request) {
 		respond.Error(w, http.StatusInternalServerError, err)
 		return
 	}
+	m.record(r, auth.AuditRecord{Action: "job.cancel", TargetType: "job", TargetID: jobID.String(),
+		After: map[string]string{"reason": body.Reason}})
+	// Whatever waited for the job is failed or skipped now rather than at
+	// the next sweep.
+	if err := m.store.Advance(r.Context(), []uuid.UUID{jobID}); err != nil {
+		m.rt.Logger.Warn("advance workflow", zap.String("job_id", jobID.String()), zap.Error(err))
+	}
 	respond.JSON(w, http.StatusOK, map[string]string{"status": "cancelled"})
 }
 
@@ -435,4 +822,7 @@ var _ interface {
 	shanraq.RouterModule
 	shanraq.InitializerModule
 	shanraq.StarterModule
+	shanraq.StopperModule
+	shanraq.HealthChecker
+	shanraq.Reconfigurable
 } = (*Module)(nil)
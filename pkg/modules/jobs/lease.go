@@ -0,0 +1,110 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// DefaultLease is how long a claimed job may run without a heartbeat before
+// the reaper takes its worker for dead. Most handlers finish well inside it
+// and never need to call Heartbeat; ones that can run longer either call it
+// as they make progress or ask for more with WithLease.
+const DefaultLease = 5 * time.Minute
+
+// reapInterval is how often each instance looks for expired leases. Reaping
+// is one idempotent statement, so every instance running it costs nothing
+// but the query.
+const reapInterval = 30 * time.Second
+
+// ErrLeaseLost is returned by Heartbeat when the job is no longer this
+// worker's: the reaper gave it up for lost, or an operator cancelled it. The
+// handler should stop — another worker may already be running the job.
+var ErrLeaseLost = errors.New("jobs: lease lost")
+
+// WithLease sets how long one attempt of the handler's jobs may go without a
+// heartbeat.
+func WithLease(d time.Duration) HandleOption {
+	return func(p *RetryPolicy) {
+		p.Lease = d
+	}
+}
+
+// lease is the policy's lease or the default.
+func (p RetryPolicy) lease() time.Duration {
+	if p.Lease > 0 {
+		return p.Lease
+	}
+	return DefaultLease
+}
+
+// leaseFor is the lease a claimed job of the given name gets.
+func (m *Module) leaseFor(name string) time.Duration {
+	return m.policies[name].lease()
+}
+
+// Heartbeat renews the running job's lease for another full term. Call it
+// between the steps of long work; it is a no-op outside a job. It returns
+// ErrLeaseLost when the job is no longer this worker's. Any other failure —
+// the database blinking — is logged and swallowed: the lease still has time
+// left, and aborting a long job over it would waste what was done.
+func Heartbeat(ctx context.Context) error {
+	jc, ok := InfoFromContext(ctx)
+	if !ok || jc.heartbeat == nil {
+		return nil
+	}
+	return jc.heartbeat(ctx)
+}
+
+// workerID names a worker in locked_by, and in the error a reaped job gets:
+// the host and process, so "worker lost" points at the machine that lost it,
+// then the pool and the worker's number in it.
+func workerID(queue string, idx int) string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s:%d/%s/%d", host, os.Getpid(), queue, idx)
+}
+
+// heartbeat builds the JobContext hook for a claimed job.
+func (m *Module) heartbeat(job Job) func(context.Context) error {
+	lease := m.leaseFor(job.Name)
+	return func(ctx context.Context) error {
+		err := m.store.ExtendLease(ctx, job.ID, job.LockedBy, lease)
+		if err == nil || errors.Is(err, ErrLeaseLost) {
+			return err
+		}
+		m.rt.Logger.Warn("job heartbeat", zap.String("job_id", job.ID.String()), zap.Error(err))
+		return nil
+	}
+}
+
+// reaperLoop returns jobs whose worker stopped renewing its lease — killed,
+// wedged, lost its network — to the queue, and sweeps the blocked jobs of
+// every workflow along the way.
+func (m *Module) reaperLoop(ctx context.Context) {
+	ticker := time.NewTicker(reapInterval)
+	defer ticker.Stop()
+	for {
+		if n, err := m.store.ReapExpiredLeases(ctx); err != nil {
+			m.rt.Logger.Error("reap job leases", zap.Error(err))
+		} else if n > 0 {
+			m.rt.Logger.Warn("jobs returned from lost workers", zap.Int("count", n))
+		}
+		if err := m.store.Advance(ctx, nil); err != nil {
+			m.rt.Logger.Error("advance workflows", zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-m.stopping:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	pgxmock "github.com/pashagolub/pgxmock/v4"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestClaimNextJobTakesHandlersLease(t *testing.T) {
+	m, mock := testWorker(t)
+	m.HandleFunc("slow", func(context.Context, Job) error { return nil }, WithLease(10*time.Minute))
+	id, now := uuid.New(), time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM job_queue\\s+WHERE status IN \\('pending', 'retry'\\).*AND queue <> ALL\\(\\$1\\)\\s+ORDER BY priority DESC, run_at").
+		WithArgs([]string{}).
+		WillReturnRows(pgxmock.NewRows(append(jobColumns, "trace_context")).
+			AddRow(id, nil, "slow", []byte(`{}`), now, 0, 3, "pending", nil, now, now, DefaultQueue, 0, nil, nil))
+	mock.ExpectExec("SET status = 'running',\\s+attempts = attempts \\+ 1,\\s+locked_by = \\$2,\\s+locked_until = NOW\\(\\) \\+ make_interval\\(secs => \\$3\\)").
+		WithArgs(id, "host:1/0", float64(600)).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectCommit()
+
+	job, err := m.store.ClaimNextJob(context.Background(), QueueFilter{}, "host:1/0", m.leaseFor)
+	if err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	if job.LockedBy != "host:1/0" || job.Attempts != 1 {
+		t.Fatalf("claimed %+v", job)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestHeartbeatRenewsLease(t *testing.T) {
+	m, mock := testWorker(t)
+	m.HandleFunc("long", func(ctx context.Context, _ Job) error {
+		return Heartbeat(ctx)
+	})
+	job := Job{ID: uuid.New(), Name: "long", Attempts: 1, MaxAttempts: 3, LockedBy: "host:1/0"}
+
+	mock.ExpectExec("UPDATE job_queue\\s+SET locked_until = NOW\\(\\) \\+ make_interval\\(secs => \\$3\\)\\s+WHERE id = \\$1\\s+AND locked_by = \\$2").
+		WithArgs(job.ID, "host:1/0", DefaultLease.Seconds()).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectExec("UPDATE job_queue\\s+SET status = 'done'").
+		WithArgs(job.ID, "host:1/0").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectExec("INSERT INTO job_attempts").
+		WithArgs(job.ID, 1, OutcomeDone, pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	m.processJob(context.Background(), job, 0)
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// A worker whose job was reaped from under it must not write an outcome: the
+// reaper has recorded the attempt, and another worker may be running the job.
+func TestLostLeaseLeavesJobAlone(t *testing.T) {
+	m, mock := testWorker(t)
+	var heartbeat error
+	m.HandleFunc("long", func(ctx context.Context, _ Job) error {
+		heartbeat = Heartbeat(ctx)
+		return heartbeat
+	})
+	job := Job{ID: uuid.New(), Name: "long", Attempts: 1, MaxAttempts: 3, LockedBy: "host:1/0"}
+
+	mock.ExpectExec("SET locked_until").
+		WithArgs(job.ID, "host:1/0", DefaultLease.Seconds()).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+
+	m.processJob(context.Background(), job, 0)
+	if !errors.Is(heartbeat, ErrLeaseLost) {
+		t.Fatalf("heartbeat = %v, want ErrLeaseLost", heartbeat)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// A worker that outlives its lease finds, when it comes to write its outcome,
+// the job reaped and claimed by another worker. It writes nothing: not the
+// outcome, not an attempt, not a step of the workflow, all of which are the
+// new owner's to write.
+func TestReclaimedJobKeepsNewOwnersOutcome(t *testing.T) {
+	m, mock := testWorker(t)
+	core, logs := observer.New(zap.DebugLevel)
+	m.rt.Logger = zap.New(core)
+	job := Job{ID: uuid.New(), Name: "long", Attempts: 1, MaxAttempts: 3, LockedBy: "host:1/0", WorkflowID: uuid.New()}
+	m.HandleFunc("long", func(ctx context.Context, _ Job) error {
+		// The handler stalls past its lease: the reaper requeues the job
+		// and host:2 claims it before the handler returns.
+		if _, err := m.store.ReapExpiredLeases(ctx); err != nil {
+			return err
+		}
+		_, err := m.store.ClaimNextJob(ctx, QueueFilter{}, "host:2/0", m.leaseFor)
+		return err
+	})
+	now := time.Now()
+
+	mock.ExpectQuery("WITH expired AS").
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectExec("SELECT pg_notify").
+		WithArgs(notifyChannel, "").
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM job_queue\\s+WHERE status IN \\('pending', 'retry'\\)").
+		WithArgs([]string{}).
+		WillReturnRows(pgxmock.NewRows(append(jobColumns, "trace_context")).
+			AddRow(job.ID, nil, "long", []byte(`{}`), now, 1, 3, "retry", nil, now, now, DefaultQueue, 0, nil, nil))
+	mock.ExpectExec("SET status = 'running'").
+		WithArgs(job.ID, "host:2/0", DefaultLease.Seconds()).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectCommit()
+	mock.ExpectExec("UPDATE job_queue\\s+SET status = 'done'.*AND status = 'running'\\s+AND locked_by = \\$2").
+		WithArgs(job.ID, "host:1/0").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+
+	m.processJob(context.Background(), job, 0)
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+	if logs.FilterMessage("job lease lost").Len() != 1 {
+		t.Errorf("lost lease not logged: %v", logs.All())
+	}
+	// pgxmock answers a call it does not expect with an error, which the
+	// worker would log: none means no attempt and no advance was written.
+	for _, msg := range []string{"record job attempt", "advance workflow", "mark done"} {
+		if logs.FilterMessage(msg).Len() != 0 {
+			t.Errorf("the first worker tried to %s after losing its lease", msg)
+		}
+	}
+}
+
+func TestHeartbeatOutsideJobIsNoop(t *testing.T) {
+	if err := Heartbeat(context.Background()); err != nil {
+		t.Fatalf("heartbeat outside a job = %v", err)
+	}
+}
+
+func TestReapExpiredLeases(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("pgxmock: %v", err)
+	}
+	defer mock.Close()
+
+	mock.ExpectQuery("WITH expired AS \\(.*WHERE status = 'running'\\s+AND locked_until < NOW\\(\\).*INSERT INTO job_attempts").
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectExec("SELECT pg_notify").
+		WithArgs(notifyChannel, "").
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+
+	n, err := newStoreWithPool(mock).ReapExpiredLeases(context.Background())
+	if err != nil {
+		t.Fatalf("reap: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("reaped %d, want 2", n)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
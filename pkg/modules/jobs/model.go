@@ -24,8 +24,71 @@ type Job struct {
 	LastError   *string         `json:"last_error,omitempty"`
 	CreatedAt   time.Time       `json:"created_at"`
 	UpdatedAt   time.Time       `json:"updated_at"`
+	// Queue is the worker pool the job waits for (DefaultQueue when empty);
+	// among due jobs of a queue the higher Priority runs first.
+	Queue    string `json:"queue"`
+	Priority int    `json:"priority"`
+	// LockedBy is the worker holding the job while it runs; ClaimNextJob
+	// sets it, and the lease behind it is renewed by Heartbeat.
+	LockedBy string `json:"locked_by,omitempty"`
+
+	// UniqueKey makes the job one of a kind: while another job of the same
+	// name holds the key (see UniqueScope), Store.Enqueue returns that job
+	// instead of queueing a second. Empty means no key — every enqueue is a
+	// new job, as before.
+	UniqueKey   string      `json:"unique_key,omitempty"`
+	UniqueScope UniqueScope `json:"unique_scope,omitempty"`
+	// UniqueFor is how long a ScopeWindow key is held from enqueue, whatever
+	// becomes of the job. Ignored for the other scopes.
+	UniqueFor time.Duration `json:"-"`
+
+	// After are the jobs this one waits for: it is blocked until they have
+	// all finished, and AfterFailure says what it does if one of them
+	// failed. Set them to enqueue a dependent job (see Workflow); only
+	// GetWorkflow reads them back. WorkflowID is the workflow the job is in.
+	After        []uuid.UUID  `json:"after,omitempty"`
+	AfterFailure AfterFailure `json:"after_failure,omitempty"`
+	WorkflowID   uuid.UUID    `json:"workflow_id,omitzero"`
+
+	// traceContext is the W3C trace context the job was enqueued in, read
+	// back only by the claim.
+	traceContext []byte
+
+	// Progress and Result are what the handler reported through
+	// ReportProgress and SetResult. Only Store.Get reads them: a list of
+	// jobs has no use for every job's result.
+	Progress *Progress       `json:"progress,omitempty"`
+	Result   json.RawMessage `json:"result,omitempty"`
 }
 
+// Progress is how far a running job says it has got. Message is for people —
+// "kz done, en 3/7 blocks" — and Done of Total, when the handler can count,
+// is for a progress bar.
+type Progress struct {
+	Message string    `json:"message"`
+	Done    int       `json:"done,omitempty"`
+	Total   int       `json:"total,omitempty"`
+	At      time.Time `json:"at"`
+}
+
+// UniqueScope says how long a job holds its UniqueKey. A job name should use
+// one scope throughout: keys are unique per scope, so a pending-scoped and an
+// active-scoped job with the same key do not see each other.
+type UniqueScope string
+
+const (
+	// ScopePending holds the key until a worker picks the job up. A second
+	// request while the first is running queues a fresh run — for work whose
+	// input may have changed since the running one read it.
+	ScopePending UniqueScope = "pending"
+	// ScopeActive holds the key until the job finishes, one way or the other.
+	// It is the default when a key is set.
+	ScopeActive UniqueScope = "active"
+	// ScopeWindow holds the key for UniqueFor after enqueue, even once the
+	// job is done: "at most one digest per subscriber per day".
+	ScopeWindow UniqueScope = "window"
+)
+
 // Decode unmarshals the job payload into the provided destination.
 func (j Job) Decode(dest any) error {
 	if len(j.Payload) == 0 {
@@ -39,12 +102,92 @@ type enqueueRequest struct {
 	Payload     map[string]any `json:"payload"`
 	RunAt       *time.Time     `json:"run_at"`
 	MaxAttempts int            `json:"max_attempts" validate:"omitempty,min=1,max=25"`
+	Queue       string         `json:"queue" validate:"omitempty,max=64"`
+	Priority    int            `json:"priority" validate:"omitempty,min=-100,max=100"`
+	UniqueKey   string         `json:"unique_key" validate:"omitempty,max=200"`
+	UniqueScope string         `json:"unique_scope" validate:"omitempty,oneof=pending active window"`
+	// UniqueFor is a Go duration ("24h"), required with the window scope.
+	UniqueFor string `json:"unique_for"`
 }
 
 // ListOptions defines optional filters for job queries.
 type ListOptions struct {
 	Status string
+	// Name is the exact job name ("ai_translate").
+	Name string
+	// Error matches part of the last error, ignoring case.
+	Error string
+	// Since keeps jobs last touched at or after it.
+	Since  time.Time
 	Limit  int
 	Offset int
 	UserID *uuid.UUID
 }
+
+// Attempt is one run of a job, as job_attempts records it.
+type Attempt struct {
+	JobID      uuid.UUID `json:"job_id"`
+	Attempt    int       `json:"attempt"`
+	Outcome    string    `json:"outcome"`
+	Error      *string   `json:"error,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMS int64     `json:"duration_ms"`
+}
+
+// Attempt outcomes.
+const (
+	OutcomeDone   = "done"
+	OutcomeRetry  = "retry"
+	OutcomeFailed = "failed"
+)
+
+// ExportedJob is a dead letter as the export writes it: the job and every
+// attempt at it.
+type ExportedJob struct {
+	Job
+	History []Attempt `json:"attempts"`
+}
+
+// bulkRequest selects the jobs a bulk action applies to. The same filters as
+// the list, so what the operator looked at is what they act on.
+type bulkRequest struct {
+	Status string `json:"status"`
+	Name   string `json:"name"`
+	Error  string `json:"error"`
+	// Since is RFC 3339 or a date, as in the list's query string.
+	Since string `json:"since"`
+}
+
+// ErrBulkStatus is returned when a bulk action names a status it may not
+// touch.
+var ErrBulkStatus = errors.New("status not allowed for this action")
+
+// ErrDuplicateJob is returned when sending a job back to the queue would give
+// it a unique key another live job already holds.
+var ErrDuplicateJob = errors.New("another job holds this job's unique key")
+
+// ErrScheduleNotFound is returned for a schedule name with no row.
+var ErrScheduleNotFound = errors.New("schedule not found")
+
+// ErrJobNotFound is returned for a job that does not exist or is not the
+// caller's.
+var ErrJobNotFound = errors.New("job not found")
+
+// ScheduleInfo mirrors a job_schedules row, with the status of the job it last
+// enqueued.
+type ScheduleInfo struct {
+	Name        string     `json:"name"`
+	Spec        string     `json:"spec"`
+	DefaultSpec string     `json:"default_spec"`
+	Enabled     bool       `json:"enabled"`
+	NextRunAt   time.Time  `json:"next_run_at"`
+	LastRunAt   *time.Time `json:"last_run_at,omitempty"`
+	LastJobID   *uuid.UUID `json:"last_job_id,omitempty"`
+	LastStatus  *string    `json:"last_status,omitempty"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+type scheduleUpdateRequest struct {
+	// Spec replaces the schedule; empty restores the one the code registered.
+	Spec string `json:"spec"`
+}
@@ -0,0 +1,125 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+)
+
+// notifyChannel is the Postgres channel the store notifies when a job becomes
+// ready, with the job's queue as the payload; an empty payload is for every
+// queue.
+const notifyChannel = "shanraq_jobs"
+
+// fallbackPollInterval is how often workers poll while the listener is down.
+// It is what every worker did all the time before there was a listener.
+const fallbackPollInterval = 2 * time.Second
+
+// listenerConn is the part of *pgx.Conn the listener uses, so tests can stand
+// in for the connection.
+type listenerConn interface {
+	WaitForNotification(ctx context.Context) (*pgconn.Notification, error)
+	Close(ctx context.Context) error
+}
+
+// dialListener opens the listener's own connection with the pool's settings.
+// It is not taken from the pool: it blocks for as long as the process runs,
+// and a pool of ten would quietly become a pool of nine.
+func (m *Module) dialListener(ctx context.Context) (listenerConn, error) {
+	conn, err := pgx.ConnectConfig(ctx, m.rt.DB.Config().ConnConfig.Copy())
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Exec(ctx, "LISTEN "+notifyChannel); err != nil {
+		conn.Close(context.WithoutCancel(ctx))
+		return nil, err
+	}
+	return conn, nil
+}
+
+// listenLoop relays notifications to the workers for as long as it can keep a
+// connection. When the connection drops the workers fall back to polling, and
+// the loop reconnects with a growing pause; once it is back it wakes every
+// worker, since whatever was enqueued in between notified nobody.
+func (m *Module) listenLoop(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-m.stopping:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	pause := time.Second
+	for {
+		conn, err := m.dial(ctx)
+		if err == nil {
+			pause = time.Second
+			m.listening.Store(true)
+			m.wakeAll()
+			err = m.relay(ctx, conn)
+			m.listening.Store(false)
+			closeCtx, done := context.WithTimeout(context.WithoutCancel(ctx), time.Second)
+			conn.Close(closeCtx)
+			done()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		m.rt.Logger.Warn("job listener down, polling every "+fallbackPollInterval.String(), zap.Error(err))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(pause):
+		}
+		pause = min(2*pause, time.Minute)
+	}
+}
+
+// relay wakes one worker of the notified queue's pool per notification, or
+// every worker for an empty payload, until the connection fails.
+func (m *Module) relay(ctx context.Context, conn listenerConn) error {
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		if n.Payload == "" {
+			m.wakeAll()
+			continue
+		}
+		m.poolFor(n.Payload).wakeOne()
+	}
+}
+
+// wakeOne hands a wake-up to one idle worker. When every slot is taken the
+// workers are all busy or about to look anyway, and the signal is dropped.
+func (p *pool) wakeOne() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (m *Module) wakeAll() {
+	for _, p := range m.pools {
+		for range cap(p.wake) {
+			p.wakeOne()
+		}
+	}
+}
+
+// idlePoll is how long a worker with nothing to do waits before looking for
+// itself: the safety-net interval while notifications arrive, the old polling
+// cadence while they cannot.
+func (m *Module) idlePoll() time.Duration {
+	if m.listening.Load() {
+		return m.pollInterval
+	}
+	return min(m.pollInterval, fallbackPollInterval)
+}
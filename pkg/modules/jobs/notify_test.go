@@ -0,0 +1,122 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+	"shanraq.org/pkg/shanraq"
+)
+
+// fakeListener hands out the queued notifications, then fails as a dropped
+// connection does.
+type fakeListener struct {
+	pending int
+	closed  bool
+}
+
+func (f *fakeListener) WaitForNotification(context.Context) (*pgconn.Notification, error) {
+	if f.pending == 0 {
+		return nil, errors.New("conn closed")
+	}
+	f.pending--
+	return &pgconn.Notification{Channel: notifyChannel, Payload: DefaultQueue}, nil
+}
+
+func (f *fakeListener) Close(context.Context) error {
+	f.closed = true
+	return nil
+}
+
+// A burst of notifications wakes each worker once; the rest would only wake
+// workers that are already on their way to the queue.
+func TestRelayWakesAtMostEveryWorker(t *testing.T) {
+	m := New(WithWorkerCount(2))
+	if err := m.relay(context.Background(), &fakeListener{pending: 5}); err == nil {
+		t.Fatal("relay returned nil after the connection failed")
+	}
+	if len(m.pools[0].wake) != 2 {
+		t.Fatalf("%d wake-ups pending, want 2", len(m.pools[0].wake))
+	}
+}
+
+func TestIdlePollFallsBackWhileListenerIsDown(t *testing.T) {
+	m := New(WithPollInterval(30 * time.Second))
+	if got := m.idlePoll(); got != fallbackPollInterval {
+		t.Fatalf("idle poll without a listener = %s, want %s", got, fallbackPollInterval)
+	}
+	m.listening.Store(true)
+	if got := m.idlePoll(); got != 30*time.Second {
+		t.Fatalf("idle poll while listening = %s, want the safety net", got)
+	}
+	// An interval shorter than the fallback is kept either way.
+	m = New(WithPollInterval(500 * time.Millisecond))
+	if got := m.idlePoll(); got != 500*time.Millisecond {
+		t.Fatalf("idle poll = %s", got)
+	}
+}
+
+// The listener reconnects after a drop, and wakes every worker when it is
+// back: whatever was enqueued in between notified nobody.
+func TestListenLoopReconnectsAndWakesWorkers(t *testing.T) {
+	m := New(WithWorkerCount(3))
+	m.rt = &shanraq.Runtime{Logger: zap.NewNop()}
+	var conns []*fakeListener
+	m.dial = func(context.Context) (listenerConn, error) {
+		if len(conns) == 2 {
+			m.stopOnce.Do(func() { close(m.stopping) })
+			return nil, errors.New("shutting down")
+		}
+		conns = append(conns, &fakeListener{})
+		return conns[len(conns)-1], nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		m.listenLoop(context.Background())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("listenLoop did not return after Stop")
+	}
+	if len(conns) != 2 || !conns[0].closed || !conns[1].closed {
+		t.Fatalf("connections = %+v, want two, both closed", conns)
+	}
+	if len(m.pools[0].wake) != 3 || m.listening.Load() {
+		t.Fatalf("wake-ups = %d, listening = %v", len(m.pools[0].wake), m.listening.Load())
+	}
+}
+
+func TestWorkerClaimsWhenWoken(t *testing.T) {
+	m, mock := testWorker(t)
+	m.pollInterval = time.Hour
+	m.listening.Store(true)
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM job_queue").WithArgs([]string{}).WillReturnError(pgx.ErrNoRows)
+	mock.ExpectRollback()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		m.workerLoop(ctx, m.pools[0], 0)
+		close(done)
+	}()
+	m.pools[0].wakeOne()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for mock.ExpectationsWereMet() != nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("worker did not claim after a wake-up: %v", mock.ExpectationsWereMet())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+}
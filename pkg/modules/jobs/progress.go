@@ -0,0 +1,192 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"shanraq.org/pkg/transport/respond"
+)
+
+// ReportProgress records how far the running job has got, for the console,
+// GET /jobs/{id} and anyone streaming the job. It renews the job's lease as
+// Heartbeat does, so a handler that reports progress need not also beat. It
+// is a no-op outside a job, and like Heartbeat it returns ErrLeaseLost when
+// the job is no longer this worker's and swallows any other failure: a
+// progress line that did not make it is not worth failing the job over.
+func ReportProgress(ctx context.Context, p Progress) error {
+	jc, ok := InfoFromContext(ctx)
+	if !ok || jc.progress == nil {
+		return nil
+	}
+	return jc.progress(ctx, p)
+}
+
+// SetResult records what the job produced, as JSON, on the job row. The last
+// call wins; a retry starts without one. It is meant for what the people
+// waiting on the job want to know — which languages were translated, how
+// many rows a sweep removed — not for data other code depends on: jobs are
+// purged. It is a no-op outside a job, and returns ErrLeaseLost like
+// ReportProgress; any other failure is returned too, since the caller asked
+// for the result to be kept.
+func SetResult(ctx context.Context, v any) error {
+	jc, ok := InfoFromContext(ctx)
+	if !ok || jc.result == nil {
+		return nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("jobs: encode result: %w", err)
+	}
+	return jc.result(ctx, raw)
+}
+
+// reportProgress builds the JobContext hook for a claimed job's progress.
+func (m *Module) reportProgress(job Job) func(context.Context, Progress) error {
+	lease := m.leaseFor(job.Name)
+	return func(ctx context.Context, p Progress) error {
+		if p.At.IsZero() {
+			p.At = time.Now().UTC()
+		}
+		err := m.store.SetProgress(ctx, job.ID, job.LockedBy, p, lease)
+		if err == nil || errors.Is(err, ErrLeaseLost) {
+			return err
+		}
+		m.rt.Logger.Warn("job progress", zap.String("job_id", job.ID.String()), zap.Error(err))
+		return nil
+	}
+}
+
+// reportResult builds the JobContext hook for a claimed job's result.
+func (m *Module) reportResult(job Job) func(context.Context, json.RawMessage) error {
+	return func(ctx context.Context, raw json.RawMessage) error {
+		return m.store.SetResult(ctx, job.ID, job.LockedBy, raw)
+	}
+}
+
+// handleGet answers GET /jobs/{id}: the job with its progress and result.
+func (m *Module) handleGet(w http.ResponseWriter, r *http.Request) {
+	jobID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, errors.New("invalid job id"))
+		return
+	}
+	var tenantPtr *uuid.UUID
+	if tenantID, ok := m.resolveTenant(r); ok {
+		tenantPtr = &tenantID
+	}
+	job, err := m.store.Get(r.Context(), jobID, tenantPtr)
+	if errors.Is(err, ErrJobNotFound) {
+		respond.Error(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		respond.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	respond.JSON(w, http.StatusOK, job)
+}
+
+// handleStream answers GET /jobs/{id}/stream with the job as server-sent
+// events (see StreamJob).
+func (m *Module) handleStream(w http.ResponseWriter, r *http.Request) {
+	jobID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, errors.New("invalid job id"))
+		return
+	}
+	var tenantPtr *uuid.UUID
+	if tenantID, ok := m.resolveTenant(r); ok {
+		tenantPtr = &tenantID
+	}
+	StreamJob(w, r, m.store, jobID, tenantPtr)
+}
+
+// How a job is streamed. The stream reads the row once a second rather than
+// listening for changes: progress arrives a few times a minute, and a second
+// of delay is nothing next to the minutes a translation takes.
+//
+// Each response ends after streamWindow. The server's write timeout (15 s by
+// default) would cut a longer one mid-event, and EventSource reconnects by
+// itself after streamRetry, so the browser sees one stream.
+const (
+	streamPoll   = time.Second
+	streamWindow = 10 * time.Second
+	streamRetry  = time.Second
+)
+
+// StreamJob writes job id as server-sent events: a "job" event carrying the
+// job as GET /jobs/{id} returns it, first at once and then whenever it
+// changes, until it is done, failed or cancelled, when the stream ends with
+// an "end" event. A missing job is a 404. Other modules serve it behind their
+// own checks — the studio streams an author's translation this way.
+func StreamJob(w http.ResponseWriter, r *http.Request, store *Store, id uuid.UUID, userID *uuid.UUID) {
+	ctx := r.Context()
+	job, err := store.Get(ctx, id, userID)
+	if errors.Is(err, ErrJobNotFound) {
+		respond.Error(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		respond.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respond.Error(w, http.StatusInternalServerError, errors.New("streaming unsupported"))
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", streamRetry.Milliseconds())
+
+	ticker := time.NewTicker(streamPoll)
+	defer ticker.Stop()
+	deadline := time.After(streamWindow)
+	var last []byte
+	for {
+		data, err := json.Marshal(job)
+		if err != nil {
+			return
+		}
+		if !bytes.Equal(data, last) {
+			fmt.Fprintf(w, "event: job\ndata: %s\n\n", data)
+			last = data
+		}
+		if finished(job.Status) {
+			fmt.Fprint(w, "event: end\ndata: {}\n\n")
+			flusher.Flush()
+			return
+		}
+		flusher.Flush()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-deadline:
+			return
+		case <-ticker.C:
+		}
+		if job, err = store.Get(ctx, id, userID); err != nil {
+			return
+		}
+	}
+}
+
+// finished reports whether a job of this status will change no more.
+func finished(status string) bool {
+	switch status {
+	case "done", "failed", "cancelled":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	pgxmock "github.com/pashagolub/pgxmock/v4"
+)
+
+func TestReportProgressAndResult(t *testing.T) {
+	m, mock := testWorker(t)
+	m.HandleFunc("translate", func(ctx context.Context, _ Job) error {
+		if err := ReportProgress(ctx, Progress{Message: "kz done, en 3/7 blocks", Done: 3, Total: 7}); err != nil {
+			return err
+		}
+		return SetResult(ctx, map[string][]string{"translated": {"kz", "en"}})
+	})
+	job := Job{ID: uuid.New(), Name: "translate", Attempts: 1, MaxAttempts: 3, LockedBy: "host:1/ai/0"}
+
+	mock.ExpectExec("UPDATE job_queue\\s+SET progress = \\$3,\\s+locked_until = NOW\\(\\) \\+ make_interval\\(secs => \\$4\\)").
+		WithArgs(job.ID, "host:1/ai/0", pgxmock.AnyArg(), DefaultLease.Seconds()).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectExec("UPDATE job_queue\\s+SET result = \\$3").
+		WithArgs(job.ID, "host:1/ai/0", json.RawMessage(`{"translated":["kz","en"]}`)).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectExec("UPDATE job_queue\\s+SET status = 'done'").
+		WithArgs(job.ID, "host:1/ai/0").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectExec("INSERT INTO job_attempts").
+		WithArgs(job.ID, 1, OutcomeDone, pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	m.processJob(context.Background(), job, 0)
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestReportProgressOutsideJobIsNoop(t *testing.T) {
+	if err := ReportProgress(context.Background(), Progress{Message: "x"}); err != nil {
+		t.Fatalf("progress outside a job = %v", err)
+	}
+	if err := SetResult(context.Background(), 1); err != nil {
+		t.Fatalf("result outside a job = %v", err)
+	}
+}
+
+var detailColumns = append(append([]string{}, jobColumns...), "progress", "result")
+
+func TestHandleGetReturnsProgressAndResult(t *testing.T) {
+	m, mock := testWorker(t)
+	id, now := uuid.New(), time.Now()
+	mock.ExpectQuery("FROM job_queue WHERE id = \\$1").
+		WithArgs(id).
+		WillReturnRows(pgxmock.NewRows(detailColumns).
+			AddRow(id, nil, "ai_translate", []byte(`{}`), now, 1, 3, "running", nil, now, now, "ai", 0, nil,
+				[]byte(`{"message":"en 3/7 blocks","done":3,"total":7,"at":"2025-11-08T09:00:00Z"}`), nil))
+
+	rec := httptest.NewRecorder()
+	mount(m).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs/"+id.String(), nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d (%s)", rec.Code, rec.Body.String())
+	}
+	var got Job
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.Progress == nil || got.Progress.Message != "en 3/7 blocks" || got.Progress.Done != 3 || got.Result != nil {
+		t.Fatalf("job = %+v", got)
+	}
+}
+
+func TestHandleGetMissingJob(t *testing.T) {
+	m, mock := testWorker(t)
+	id := uuid.New()
+	mock.ExpectQuery("FROM job_queue WHERE id = \\$1").WithArgs(id).WillReturnError(pgx.ErrNoRows)
+
+	rec := httptest.NewRecorder()
+	mount(m).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs/"+id.String(), nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", rec.Code)
+	}
+}
+
+// A finished job streams once and ends, rather than holding the connection
+// open for changes that will never come.
+func TestStreamEndsWithFinishedJob(t *testing.T) {
+	m, mock := testWorker(t)
+	id, now := uuid.New(), time.Now()
+	mock.ExpectQuery("FROM job_queue WHERE id = \\$1").
+		WithArgs(id).
+		WillReturnRows(pgxmock.NewRows(detailColumns).
+			AddRow(id, nil, "ai_translate", []byte(`{}`), now, 1, 3, "done", nil, now, now, "ai", 0, nil,
+				nil, []byte(`{"translated":["kz"]}`)))
+
+	rec := httptest.NewRecorder()
+	mount(m).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs/"+id.String()+"/stream", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("status = %d, content type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	body := rec.Body.String()
+	if strings.Count(body, "event: job\n") != 1 || !strings.Contains(body, `"result":{"translated":["kz"]}`) || !strings.HasSuffix(body, "event: end\ndata: {}\n\n") {
+		t.Fatalf("stream = %q", body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
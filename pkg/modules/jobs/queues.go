@@ -0,0 +1,191 @@
+package jobs
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"shanraq.org/pkg/transport/respond"
+)
+
+// DefaultQueue is the queue of jobs enqueued without one. Its pool also runs
+// every queue that has no pool of its own on this instance, so a job sent to
+// a queue nobody configured is late at worst, never stranded.
+const DefaultQueue = "default"
+
+// QueueConfig sizes a named queue's worker pool.
+type QueueConfig struct {
+	// Workers is how many of the queue's jobs run at once on one instance.
+	Workers int
+	// Rate caps how many jobs per second the pool starts on one instance;
+	// zero means no cap. It is for upstreams that meter calls rather than
+	// concurrency. Each instance paces itself, so the cluster's rate is this
+	// times the number of instances.
+	Rate float64
+}
+
+// WithQueue gives queue a worker pool of its own, so its jobs neither wait
+// behind other queues' backlogs nor hold up theirs. A burst of translations,
+// minutes each, used to occupy every worker while a welcome e-mail waited
+// behind them; with the AI jobs in their own queue of one, the rest keep
+// moving. The default queue is sized by WithWorkerCount.
+func WithQueue(name string, cfg QueueConfig) Option {
+	return func(m *Module) {
+		if name == "" || name == DefaultQueue || cfg.Workers <= 0 {
+			return
+		}
+		m.queues[name] = cfg
+	}
+}
+
+// pool is the workers of one queue.
+type pool struct {
+	name    string
+	from    QueueFilter
+	workers int
+	rate    float64
+	// ids are the names the workers claim jobs under (see workerID).
+	ids []string
+	// wake has one slot per worker; the listener fills it.
+	wake  chan struct{}
+	pacer *pacer
+}
+
+// buildPools lays out the default pool and one per configured queue, in name
+// order so worker names are stable across restarts.
+func (m *Module) buildPools() {
+	names := make([]string, 0, len(m.queues))
+	for name := range m.queues {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	m.pools = []*pool{newPool(DefaultQueue, QueueFilter{Except: names}, m.workerCount, 0)}
+	for _, name := range names {
+		cfg := m.queues[name]
+		m.pools = append(m.pools, newPool(name, QueueFilter{Queue: name}, cfg.Workers, cfg.Rate))
+	}
+}
+
+func newPool(name string, from QueueFilter, workers int, rate float64) *pool {
+	p := &pool{name: name, from: from, workers: workers, rate: rate, wake: make(chan struct{}, workers)}
+	for i := range workers {
+		p.ids = append(p.ids, workerID(name, i))
+	}
+	if rate > 0 {
+		p.pacer = &pacer{every: time.Duration(float64(time.Second) / rate)}
+	}
+	return p
+}
+
+// poolFor is the pool that runs queue on this instance.
+func (m *Module) poolFor(queue string) *pool {
+	for _, p := range m.pools[1:] {
+		if p.name == queue {
+			return p
+		}
+	}
+	return m.pools[0]
+}
+
+// totalWorkers counts the workers of every pool.
+func (m *Module) totalWorkers() int {
+	n := 0
+	for _, p := range m.pools {
+		n += p.workers
+	}
+	return n
+}
+
+// pacer spaces a pool's job starts at least every apart.
+type pacer struct {
+	every time.Duration
+	mu    sync.Mutex
+	next  time.Time
+}
+
+// wait blocks until the pool may start another job and reserves that slot.
+// It reports false if ctx ended or stop closed first.
+func (p *pacer) wait(ctx context.Context, stop <-chan struct{}) bool {
+	p.mu.Lock()
+	now := time.Now()
+	at := now
+	if p.next.After(now) {
+		at = p.next
+	}
+	p.next = at.Add(p.every)
+	p.mu.Unlock()
+	if !at.After(now) {
+		return true
+	}
+	t := time.NewTimer(at.Sub(now))
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-stop:
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// QueueStat is one queue's depth and throughput, as the console shows it.
+type QueueStat struct {
+	Queue string `json:"queue"`
+	// Workers is this instance's pool size for the queue; zero for a queue
+	// with no pool, whose jobs the default pool runs.
+	Workers int     `json:"workers"`
+	Rate    float64 `json:"rate,omitempty"`
+	Due     int     `json:"due"`
+	Waiting int     `json:"waiting"`
+	Running int     `json:"running"`
+	// DoneLastHour and FailedLastHour are the queue's throughput.
+	DoneLastHour   int `json:"done_last_hour"`
+	FailedLastHour int `json:"failed_last_hour"`
+	// OldestDueSeconds is how long the longest-waiting due job has waited.
+	OldestDueSeconds float64 `json:"oldest_due_seconds"`
+}
+
+// handleQueues lists every queue with jobs in it or a pool for it.
+func (m *Module) handleQueues(w http.ResponseWriter, r *http.Request) {
+	var tenantPtr *uuid.UUID
+	if tenantID, ok := m.resolveTenant(r); ok {
+		tenantPtr = &tenantID
+	}
+	stats, err := m.store.QueueStats(r.Context(), tenantPtr)
+	if err != nil {
+		respond.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	seen := map[string]bool{}
+	for i := range stats {
+		seen[stats[i].Queue] = true
+		if p := m.poolFor(stats[i].Queue); p.name == stats[i].Queue {
+			stats[i].Workers, stats[i].Rate = p.workers, p.rate
+		}
+	}
+	for _, p := range m.pools {
+		if !seen[p.name] {
+			stats = append(stats, QueueStat{Queue: p.name, Workers: p.workers, Rate: p.rate})
+		}
+	}
+	slices.SortFunc(stats, func(a, b QueueStat) int {
+		// The default queue first, the rest by name.
+		switch {
+		case a.Queue == b.Queue:
+			return 0
+		case a.Queue == DefaultQueue:
+			return -1
+		case b.Queue == DefaultQueue:
+			return 1
+		case a.Queue < b.Queue:
+			return -1
+		}
+		return 1
+	})
+	respond.JSON(w, http.StatusOK, stats)
+}
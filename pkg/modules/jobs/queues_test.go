@@ -0,0 +1,138 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	pgxmock "github.com/pashagolub/pgxmock/v4"
+)
+
+// The default pool claims from every queue but the ones with a pool of their
+// own, so a job sent to a queue nobody configured still runs.
+func TestBuildPoolsDefaultTakesUnconfiguredQueues(t *testing.T) {
+	m := New(
+		WithWorkerCount(4),
+		WithQueue("mail", QueueConfig{Workers: 2}),
+		WithQueue("ai", QueueConfig{Workers: 1, Rate: 0.5}),
+		WithQueue("nobody", QueueConfig{}),
+	)
+	if len(m.pools) != 3 {
+		t.Fatalf("%d pools, want default, ai and mail", len(m.pools))
+	}
+	def, ai, mail := m.pools[0], m.pools[1], m.pools[2]
+	if def.name != DefaultQueue || !slices.Equal(def.from.Except, []string{"ai", "mail"}) || def.workers != 4 {
+		t.Fatalf("default pool = %+v", def)
+	}
+	if ai.from.Queue != "ai" || ai.workers != 1 || ai.pacer == nil || ai.pacer.every != 2*time.Second {
+		t.Fatalf("ai pool = %+v", ai)
+	}
+	if mail.pacer != nil || len(mail.ids) != 2 || cap(mail.wake) != 2 {
+		t.Fatalf("mail pool = %+v", mail)
+	}
+	if m.poolFor("reports") != def || m.poolFor("mail") != mail {
+		t.Fatal("poolFor routed a queue to the wrong pool")
+	}
+	if m.totalWorkers() != 7 {
+		t.Fatalf("total workers = %d, want 7", m.totalWorkers())
+	}
+}
+
+func TestClaimFromNamedQueue(t *testing.T) {
+	m, mock := testWorker(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery("AND queue = \\$1\\s+ORDER BY priority DESC, run_at").
+		WithArgs("ai").
+		WillReturnRows(pgxmock.NewRows(jobColumns))
+	mock.ExpectRollback()
+
+	if _, err := m.store.ClaimNextJob(context.Background(), QueueFilter{Queue: "ai"}, "host:1/ai/0", m.leaseFor); err != ErrNoJobs {
+		t.Fatalf("claim = %v, want ErrNoJobs", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// queueListener hands out one notification per payload, then fails.
+type queueListener struct{ payloads []string }
+
+func (q *queueListener) WaitForNotification(context.Context) (*pgconn.Notification, error) {
+	if len(q.payloads) == 0 {
+		return nil, context.Canceled
+	}
+	p := q.payloads[0]
+	q.payloads = q.payloads[1:]
+	return &pgconn.Notification{Channel: notifyChannel, Payload: p}, nil
+}
+
+func (q *queueListener) Close(context.Context) error { return nil }
+
+func TestRelayWakesTheNotifiedQueue(t *testing.T) {
+	m := New(WithWorkerCount(2), WithQueue("ai", QueueConfig{Workers: 1}))
+	m.relay(context.Background(), &queueListener{payloads: []string{"ai", "reports"}})
+	if len(m.pools[0].wake) != 1 || len(m.pools[1].wake) != 1 {
+		t.Fatalf("wake-ups = default %d, ai %d; want one each", len(m.pools[0].wake), len(m.pools[1].wake))
+	}
+	// An empty payload is for everyone.
+	m.relay(context.Background(), &queueListener{payloads: []string{""}})
+	if len(m.pools[0].wake) != 2 || len(m.pools[1].wake) != 1 {
+		t.Fatalf("wake-ups = default %d, ai %d; want every slot", len(m.pools[0].wake), len(m.pools[1].wake))
+	}
+}
+
+func TestPacerSpacesStarts(t *testing.T) {
+	p := &pacer{every: 50 * time.Millisecond}
+	stop := make(chan struct{})
+	start := time.Now()
+	for range 3 {
+		if !p.wait(context.Background(), stop) {
+			t.Fatal("wait gave up")
+		}
+	}
+	if took := time.Since(start); took < 100*time.Millisecond {
+		t.Fatalf("three starts took %s, want at least two intervals", took)
+	}
+	close(stop)
+	if p.wait(context.Background(), stop) {
+		t.Fatal("wait went ahead after stop")
+	}
+}
+
+func TestHandleQueuesMergesPools(t *testing.T) {
+	m, mock := testWorker(t)
+	m.queues["ai"] = QueueConfig{Workers: 1, Rate: 2}
+	m.queues["mail"] = QueueConfig{Workers: 4}
+	m.buildPools()
+	mock.ExpectQuery("FROM job_queue\\s+GROUP BY queue ORDER BY queue").
+		WillReturnRows(pgxmock.NewRows([]string{
+			"queue", "due", "waiting", "running", "done_last_hour", "failed_last_hour", "oldest_due",
+		}).
+			AddRow("ai", 12, 0, 1, 3, 0, 340.0).
+			AddRow("default", 0, 2, 0, 40, 1, 0.0).
+			AddRow("reports", 1, 0, 0, 0, 0, 5.0))
+
+	rec := httptest.NewRecorder()
+	mount(m).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs/queues", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d (%s)", rec.Code, rec.Body.String())
+	}
+	var got []QueueStat
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	want := []QueueStat{
+		{Queue: "default", Workers: m.workerCount, Waiting: 2, DoneLastHour: 40, FailedLastHour: 1},
+		{Queue: "ai", Workers: 1, Rate: 2, Due: 12, Running: 1, DoneLastHour: 3, OldestDueSeconds: 340},
+		{Queue: "mail", Workers: 4},
+		{Queue: "reports", Due: 1, OldestDueSeconds: 5},
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("queues = %+v\nwant %+v", got, want)
+	}
+}
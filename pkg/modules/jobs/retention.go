@@ -0,0 +1,334 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.uber.org/zap"
+	"shanraq.org/internal/config"
+	"shanraq.org/pkg/shanraq"
+	"shanraq.org/pkg/transport/respond"
+)
+
+// JobPrune is the schedule that deletes finished jobs past their retention,
+// archiving failed ones first when the configuration asks for it.
+const JobPrune = "jobs_prune"
+
+const pruneSpec = "*/10 * * * *"
+
+// pruneBatch is how many rows one statement deletes. Each batch is its own
+// short transaction: a single delete of a year of jobs would hold its locks,
+// and bloat the WAL, for as long as it ran.
+const pruneBatch = 1000
+
+// pruneMaxBatches bounds one run. What is left over the next run takes; a
+// first run after years without pruning catches up over a few hours instead
+// of keeping a worker busy all afternoon.
+const pruneMaxBatches = 50
+
+// workflowGrace keeps an emptied workflow around a little: its jobs may still
+// be on their way in when it is created.
+const workflowGrace = 24 * time.Hour
+
+// PruneStats is what one prune did.
+type PruneStats struct {
+	Deleted  int `json:"deleted"`
+	Archived int `json:"archived"`
+	// Expired counts archived jobs deleted past ArchiveFor.
+	Expired   int `json:"expired"`
+	Workflows int `json:"workflows"`
+}
+
+// statusRetention is the retention of one finished status: keep for the
+// status, and the override of a job name's rule for it.
+type statusRetention struct {
+	status  string
+	keep    time.Duration
+	perName func(config.JobRetentionRule) time.Duration
+}
+
+func statusRetentions(r config.JobRetentionConfig) []statusRetention {
+	return []statusRetention{
+		{"done", r.Done, func(rule config.JobRetentionRule) time.Duration { return rule.Done }},
+		{"failed", r.Failed, func(rule config.JobRetentionRule) time.Duration { return rule.Failed }},
+		{"cancelled", r.Cancelled, func(rule config.JobRetentionRule) time.Duration { return rule.Cancelled }},
+	}
+}
+
+// Prune deletes the finished jobs r no longer keeps, oldest first, in batches.
+// With r.Archive, failed jobs are moved to job_archive with their attempts
+// rather than deleted. It stops after pruneMaxBatches batches per rule and
+// reports what it did; a partial run is not an error.
+func (s *Store) Prune(ctx context.Context, r config.JobRetentionConfig) (PruneStats, error) {
+	var stats PruneStats
+	names := make([]string, 0, len(r.Jobs))
+	for name := range r.Jobs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, st := range statusRetentions(r) {
+		archive := r.Archive && st.status == "failed"
+		// Names with a rule of their own for this status are pruned by it,
+		// and left out of the status's rule.
+		var own []string
+		for _, name := range names {
+			keep := st.perName(r.Jobs[name])
+			if keep == 0 {
+				continue
+			}
+			own = append(own, name)
+			if keep < 0 {
+				continue
+			}
+			n, err := s.pruneBatches(ctx, st.status, keep, "name = ANY($3)", []string{name}, archive)
+			if err != nil {
+				return stats, err
+			}
+			stats.add(n, archive)
+		}
+		if st.keep <= 0 {
+			continue
+		}
+		n, err := s.pruneBatches(ctx, st.status, st.keep, "name <> ALL($3)", append([]string{}, own...), archive)
+		if err != nil {
+			return stats, err
+		}
+		stats.add(n, archive)
+	}
+
+	if r.ArchiveFor > 0 {
+		for range pruneMaxBatches {
+			tag, err := s.db.Exec(ctx, `
+				DELETE FROM job_archive
+				WHERE id IN (
+					SELECT id FROM job_archive
+					WHERE archived_at < NOW() - make_interval(secs => $1)
+					LIMIT $2
+				)
+			`, r.ArchiveFor.Seconds(), pruneBatch)
+			if err != nil {
+				return stats, fmt.Errorf("expire job archive: %w", err)
+			}
+			stats.Expired += int(tag.RowsAffected())
+			if tag.RowsAffected() < pruneBatch {
+				break
+			}
+		}
+	}
+
+	tag, err := s.db.Exec(ctx, `
+		DELETE FROM job_workflows w
+		WHERE created_at < NOW() - make_interval(secs => $1)
+		  AND NOT EXISTS (SELECT 1 FROM job_queue q WHERE q.workflow_id = w.id)
+	`, workflowGrace.Seconds())
+	if err != nil {
+		return stats, fmt.Errorf("prune workflows: %w", err)
+	}
+	stats.Workflows = int(tag.RowsAffected())
+	return stats, nil
+}
+
+func (p *PruneStats) add(n int, archived bool) {
+	if archived {
+		p.Archived += n
+		return
+	}
+	p.Deleted += n
+}
+
+// pruneBatches deletes, or archives, the jobs in status that finished more
+// than keep ago and whose names match byName over $3.
+func (s *Store) pruneBatches(ctx context.Context, status string, keep time.Duration, byName string, names []string, archive bool) (int, error) {
+	// SKIP LOCKED leaves a row someone is retrying by hand to them; it is
+	// not finished any more by the time they commit.
+	doomed := `
+		WITH doomed AS (
+			SELECT id FROM job_queue
+			WHERE status = $1::job_status
+			  AND updated_at < NOW() - make_interval(secs => $2)
+			  AND ` + byName + `
+			ORDER BY updated_at
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		)`
+	stmt := doomed + `
+		DELETE FROM job_queue q USING doomed WHERE q.id = doomed.id`
+	if archive {
+		// The attempts are read from the statement's snapshot, before the
+		// delete cascades to them.
+		stmt = doomed + `,
+		gone AS (
+			DELETE FROM job_queue q USING doomed WHERE q.id = doomed.id
+			RETURNING q.id, q.user_id, q.name, q.queue, q.payload, q.status, q.attempts,
+			          q.max_attempts, q.last_error, q.result, q.created_at, q.updated_at
+		)
+		INSERT INTO job_archive (id, user_id, name, queue, payload, status, attempts, max_attempts,
+		                         last_error, result, history, created_at, finished_at)
+		SELECT g.id, g.user_id, g.name, g.queue, g.payload, g.status::text, g.attempts, g.max_attempts,
+		       g.last_error, g.result,
+		       COALESCE((
+		           SELECT jsonb_agg(jsonb_build_object(
+		                      'job_id', a.job_id, 'attempt', a.attempt, 'outcome', a.outcome,
+		                      'error', a.error, 'started_at', a.started_at, 'duration_ms', a.duration_ms)
+		                  ORDER BY a.started_at)
+		           FROM job_attempts a WHERE a.job_id = g.id), '[]'::jsonb),
+		       g.created_at, g.updated_at
+		FROM gone g
+		ON CONFLICT (id) DO NOTHING`
+	}
+
+	total := 0
+	for range pruneMaxBatches {
+		tag, err := s.db.Exec(ctx, stmt, status, keep.Seconds(), names, pruneBatch)
+		if err != nil {
+			return total, fmt.Errorf("prune %s jobs: %w", status, err)
+		}
+		total += int(tag.RowsAffected())
+		if tag.RowsAffected() < pruneBatch {
+			break
+		}
+	}
+	return total, nil
+}
+
+// ArchivedJob is a failed job moved out of the queue by the pruner.
+type ArchivedJob struct {
+	ID          uuid.UUID       `json:"id"`
+	UserID      *uuid.UUID      `json:"user_id,omitempty"`
+	Name        string          `json:"name"`
+	Queue       string          `json:"queue"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	LastError   *string         `json:"last_error,omitempty"`
+	Result      json.RawMessage `json:"result,omitempty"`
+	History     []Attempt       `json:"attempts_history"`
+	CreatedAt   time.Time       `json:"created_at"`
+	FinishedAt  time.Time       `json:"finished_at"`
+	ArchivedAt  time.Time       `json:"archived_at"`
+}
+
+// Archived returns up to limit archived jobs matching opts, most recently
+// finished first. opts.Status is ignored: the archive holds failures only.
+func (s *Store) Archived(ctx context.Context, opts ListOptions, limit int) ([]ArchivedJob, error) {
+	var conditions []string
+	args := []any{}
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, strings.ReplaceAll(cond, "$?", "$"+strconv.Itoa(len(args))))
+	}
+	if opts.UserID != nil && *opts.UserID != uuid.Nil {
+		add("user_id = $?", *opts.UserID)
+	}
+	if opts.Name != "" {
+		add("name = $?", opts.Name)
+	}
+	if opts.Error != "" {
+		add("last_error ILIKE '%' || $? || '%'", escapeLike(opts.Error))
+	}
+	if !opts.Since.IsZero() {
+		add("finished_at >= $?", opts.Since)
+	}
+	query := `
+		SELECT id, user_id, name, queue, payload, status, attempts, max_attempts,
+		       last_error, result, history, created_at, finished_at, archived_at
+		FROM job_archive`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, limit)
+	query += " ORDER BY finished_at DESC LIMIT $" + strconv.Itoa(len(args))
+
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list archived jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var out []ArchivedJob
+	for rows.Next() {
+		var a ArchivedJob
+		var user pgtype.UUID
+		var history []byte
+		if err := rows.Scan(&a.ID, &user, &a.Name, &a.Queue, &a.Payload, &a.Status, &a.Attempts, &a.MaxAttempts,
+			&a.LastError, &a.Result, &history, &a.CreatedAt, &a.FinishedAt, &a.ArchivedAt); err != nil {
+			return nil, fmt.Errorf("scan archived job: %w", err)
+		}
+		if user.Valid {
+			id := uuid.UUID(user.Bytes)
+			a.UserID = &id
+		}
+		if err := json.Unmarshal(history, &a.History); err != nil {
+			return nil, fmt.Errorf("decode archived attempts: %w", err)
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+// Reconfigure applies a new retention on reload; the next prune uses it.
+func (m *Module) Reconfigure(_ context.Context, cfg config.Config, changes config.Changes) error {
+	if changes.Touches("jobs.retention") {
+		r := cfg.Jobs.Retention
+		m.retention.Store(&r)
+	}
+	return nil
+}
+
+// handlePrune is the JobPrune schedule's handler.
+func (m *Module) handlePrune(ctx context.Context, _ *shanraq.Runtime, _ Job) error {
+	r := m.retention.Load()
+	if r == nil {
+		return nil
+	}
+	stats, err := m.store.Prune(ctx, *r)
+	if err != nil {
+		return err
+	}
+	if stats != (PruneStats{}) {
+		m.rt.Logger.Info("pruned finished jobs",
+			zap.Int("deleted", stats.Deleted),
+			zap.Int("archived", stats.Archived),
+			zap.Int("archive_expired", stats.Expired),
+			zap.Int("workflows", stats.Workflows))
+	}
+	return SetResult(ctx, stats)
+}
+
+// handleArchive exports archived jobs as JSON lines, one job with its
+// attempts per line: the format log tools and jq read a line at a time.
+func (m *Module) handleArchive(w http.ResponseWriter, r *http.Request) {
+	opts, err := listFilters(r.URL.Query())
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	if tenantID, ok := m.resolveTenant(r); ok {
+		opts.UserID = &tenantID
+	}
+	archived, err := m.store.Archived(r.Context(), opts, exportLimit)
+	if err != nil {
+		respond.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	name := "jobs-archive-" + time.Now().UTC().Format("20060102-150405") + ".jsonl"
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	w.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(w)
+	for _, a := range archived {
+		if err := enc.Encode(a); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	pgxmock "github.com/pashagolub/pgxmock/v4"
+	"shanraq.org/internal/config"
+)
+
+// A name with its own rule is pruned by it and left out of the status's
+// rule; a negative rule keeps the name's jobs for good; failed jobs are
+// archived, not deleted; a full batch is followed by another.
+func TestPruneAppliesRetentionPerStatusAndName(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("pgxmock: %v", err)
+	}
+	defer mock.Close()
+
+	r := config.JobRetentionConfig{
+		Done:    time.Hour,
+		Failed:  2 * time.Hour,
+		Archive: true,
+		Jobs: map[string]config.JobRetentionRule{
+			"event_delivery": {Done: 10 * time.Minute},
+			"payout":         {Failed: -1},
+		},
+	}
+	mock.ExpectExec(`WHERE status = \$1::job_status.*name = ANY\(\$3\).*DELETE FROM job_queue q USING doomed`).
+		WithArgs("done", float64(600), []string{"event_delivery"}, pruneBatch).
+		WillReturnResult(pgxmock.NewResult("DELETE", 3))
+	mock.ExpectExec(`name <> ALL\(\$3\)`).
+		WithArgs("done", float64(3600), []string{"event_delivery"}, pruneBatch).
+		WillReturnResult(pgxmock.NewResult("DELETE", pruneBatch))
+	mock.ExpectExec(`name <> ALL\(\$3\)`).
+		WithArgs("done", float64(3600), []string{"event_delivery"}, pruneBatch).
+		WillReturnResult(pgxmock.NewResult("DELETE", 5))
+	mock.ExpectExec(`INSERT INTO job_archive .*FROM job_attempts a WHERE a.job_id = g.id`).
+		WithArgs("failed", float64(7200), []string{"payout"}, pruneBatch).
+		WillReturnResult(pgxmock.NewResult("INSERT", 2))
+	mock.ExpectExec(`DELETE FROM job_workflows w`).
+		WithArgs(workflowGrace.Seconds()).
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+
+	stats, err := newStoreWithPool(mock).Prune(context.Background(), r)
+	if err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+	want := PruneStats{Deleted: 3 + pruneBatch + 5, Archived: 2, Workflows: 1}
+	if stats != want {
+		t.Fatalf("prune = %+v, want %+v", stats, want)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestHandleArchiveWritesJSONLines(t *testing.T) {
+	m, mock := testWorker(t)
+	now := time.Now().UTC().Truncate(time.Second)
+	a, b := uuid.New(), uuid.New()
+	history := []byte(`[{"job_id":"` + a.String() + `","attempt":1,"outcome":"failed","error":"quota","started_at":"2026-01-02T03:04:05Z","duration_ms":12}]`)
+	msg := "quota"
+	mock.ExpectQuery(`FROM job_archive WHERE name = \$1 ORDER BY finished_at DESC LIMIT \$2`).
+		WithArgs("ai_translate", exportLimit).
+		WillReturnRows(pgxmock.NewRows([]string{
+			"id", "user_id", "name", "queue", "payload", "status", "attempts", "max_attempts",
+			"last_error", "result", "history", "created_at", "finished_at", "archived_at",
+		}).
+			AddRow(a, nil, "ai_translate", "ai", json.RawMessage(`{}`), "failed", 1, 1, &msg, json.RawMessage(nil), history, now, now, now).
+			AddRow(b, nil, "ai_translate", "ai", json.RawMessage(`{}`), "failed", 3, 3, &msg, json.RawMessage(nil), []byte(`[]`), now, now, now))
+
+	rec := httptest.NewRecorder()
+	mount(m).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs/archive?name=ai_translate", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d (%s)", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Fatalf("content type = %q", ct)
+	}
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2:\n%s", len(lines), rec.Body.String())
+	}
+	var first ArchivedJob
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatalf("decode line: %v", err)
+	}
+	if first.ID != a || len(first.History) != 1 || first.History[0].Outcome != OutcomeFailed {
+		t.Fatalf("first line = %+v", first)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
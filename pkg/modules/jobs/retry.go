@@ -0,0 +1,171 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy decides when a failed job runs again. The delay doubles from
+// Base with every attempt up to Cap, and is spread by Jitter so a hundred jobs
+// failing on the same outage do not come back in the same second and fail on
+// it again.
+//
+// The fixed fifteen seconds this replaced burned a job's five attempts in
+// about a minute — less than a Telegram flood wait, far less than a provider
+// outage.
+type RetryPolicy struct {
+	// Base is the delay after the first failed attempt.
+	Base time.Duration
+	// Cap bounds the doubling; zero means DefaultRetryPolicy's. A handler's
+	// RetryAfter is not capped: the upstream knows better than we do when it
+	// will take the call.
+	Cap time.Duration
+	// Jitter spreads each delay by up to this fraction either way (0.2 =
+	// ±20%).
+	Jitter float64
+	// MaxAttempts, when set, overrides the max_attempts stored with the job,
+	// so the handler rather than each enqueuer decides how patient to be.
+	MaxAttempts int
+	// NonRetryable lists errors (matched with errors.Is) that fail the job on
+	// the spot: a payload that does not decode will not decode next time.
+	NonRetryable []error
+	// Lease is how long one attempt may go without a Heartbeat before the
+	// reaper returns the job to the queue; zero means DefaultLease. It lives
+	// here because this is where a handler's options live, not because it
+	// is about retrying.
+	Lease time.Duration
+}
+
+// DefaultRetryPolicy applies to handlers registered without options.
+var DefaultRetryPolicy = RetryPolicy{
+	Base:   15 * time.Second,
+	Cap:    30 * time.Minute,
+	Jitter: 0.2,
+}
+
+// HandleOption adjusts the retry policy — and the lease — of one handler.
+type HandleOption func(*RetryPolicy)
+
+// WithBackoff sets the first retry delay and the most it may grow to.
+func WithBackoff(base, limit time.Duration) HandleOption {
+	return func(p *RetryPolicy) {
+		p.Base, p.Cap = base, limit
+	}
+}
+
+// WithJitter sets the fraction delays are spread by; 0 turns it off.
+func WithJitter(fraction float64) HandleOption {
+	return func(p *RetryPolicy) {
+		p.Jitter = fraction
+	}
+}
+
+// WithMaxAttempts overrides the attempts stored with each job.
+func WithMaxAttempts(n int) HandleOption {
+	return func(p *RetryPolicy) {
+		p.MaxAttempts = n
+	}
+}
+
+// NonRetryable fails the job at once when the handler returns one of errs.
+func NonRetryable(errs ...error) HandleOption {
+	return func(p *RetryPolicy) {
+		p.NonRetryable = append(p.NonRetryable, errs...)
+	}
+}
+
+// ErrPermanent marks an error no retry can fix. Wrap one with Permanent.
+var ErrPermanent = errors.New("permanent failure")
+
+// Permanent wraps err so the job fails without further attempts, whatever its
+// handler's policy.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("%w: %w", ErrPermanent, err)
+}
+
+// RetryAfterError asks for the next attempt no sooner than After — what a
+// handler returns when the upstream said how long to wait (an HTTP 429 with
+// Retry-After, Telegram's retry_after). The attempt still counts.
+type RetryAfterError struct {
+	After time.Duration
+	Err   error
+}
+
+// RetryAfter wraps err with the delay the upstream asked for.
+func RetryAfter(after time.Duration, err error) error {
+	return &RetryAfterError{After: after, Err: err}
+}
+
+func (e *RetryAfterError) Error() string {
+	return fmt.Sprintf("%v (retry after %s)", e.Err, e.After)
+}
+
+func (e *RetryAfterError) Unwrap() error { return e.Err }
+
+// RetryAfter reports the delay; any error with this method is honoured the
+// same way, so a client package need not import jobs to ask for a wait.
+func (e *RetryAfterError) RetryAfter() time.Duration { return e.After }
+
+// retryAfterer is what the worker looks for in a handler's error chain.
+type retryAfterer interface {
+	RetryAfter() time.Duration
+}
+
+func newRetryPolicy(opts []HandleOption) RetryPolicy {
+	p := DefaultRetryPolicy
+	p.NonRetryable = append([]error(nil), p.NonRetryable...)
+	for _, opt := range opts {
+		opt(&p)
+	}
+	return p
+}
+
+// retryable reports whether err may be retried at all.
+func (p RetryPolicy) retryable(err error) bool {
+	if errors.Is(err, ErrPermanent) {
+		return false
+	}
+	for _, target := range p.NonRetryable {
+		if errors.Is(err, target) {
+			return false
+		}
+	}
+	return true
+}
+
+// maxAttempts is the policy's override or the job's own limit.
+func (p RetryPolicy) maxAttempts(job Job) int {
+	if p.MaxAttempts > 0 {
+		return p.MaxAttempts
+	}
+	return job.MaxAttempts
+}
+
+// delay is how long to wait after the given attempt (1-based) failed with err.
+func (p RetryPolicy) delay(attempt int, err error) time.Duration {
+	var ra retryAfterer
+	if errors.As(err, &ra) && ra.RetryAfter() > 0 {
+		return ra.RetryAfter()
+	}
+	d, limit := p.Base, p.Cap
+	if d <= 0 {
+		d = DefaultRetryPolicy.Base
+	}
+	if limit <= 0 {
+		limit = DefaultRetryPolicy.Cap
+	}
+	for i := 1; i < attempt && d < limit; i++ {
+		d *= 2
+	}
+	d = min(d, limit)
+	if p.Jitter > 0 {
+		spread := float64(d) * p.Jitter
+		d += time.Duration(spread * (2*rand.Float64() - 1))
+	}
+	return d
+}
@@ -0,0 +1,107 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	pgxmock "github.com/pashagolub/pgxmock/v4"
+	"go.uber.org/zap"
+	"shanraq.org/pkg/shanraq"
+)
+
+func TestRetryDelayDoublesUpToCap(t *testing.T) {
+	p := newRetryPolicy([]HandleOption{WithBackoff(10*time.Second, time.Minute), WithJitter(0)})
+	want := []time.Duration{10 * time.Second, 20 * time.Second, 40 * time.Second, time.Minute, time.Minute}
+	for i, w := range want {
+		if got := p.delay(i+1, errors.New("boom")); got != w {
+			t.Fatalf("attempt %d: delay = %s, want %s", i+1, got, w)
+		}
+	}
+}
+
+func TestRetryDelayJitterStaysInBand(t *testing.T) {
+	p := newRetryPolicy([]HandleOption{WithBackoff(time.Minute, time.Hour), WithJitter(0.2)})
+	for i := 0; i < 200; i++ {
+		d := p.delay(1, errors.New("boom"))
+		if d < 48*time.Second || d > 72*time.Second {
+			t.Fatalf("delay %s outside ±20%% of a minute", d)
+		}
+	}
+}
+
+// The upstream's own wait wins over the backoff, even past the cap: retrying
+// a flood-controlled bot sooner only extends the ban.
+func TestRetryAfterOverridesBackoff(t *testing.T) {
+	p := newRetryPolicy([]HandleOption{WithBackoff(time.Second, time.Minute)})
+	err := RetryAfter(90*time.Second, errors.New("telegram api status 429"))
+	if got := p.delay(1, err); got != 90*time.Second {
+		t.Fatalf("delay = %s, want 90s", got)
+	}
+}
+
+func TestNonRetryableErrors(t *testing.T) {
+	errGone := errors.New("article gone")
+	p := newRetryPolicy([]HandleOption{NonRetryable(errGone)})
+	if p.retryable(errGone) || p.retryable(Permanent(errors.New("bad payload"))) {
+		t.Fatal("non-retryable error would be retried")
+	}
+	if !p.retryable(errors.New("timeout")) {
+		t.Fatal("ordinary error would not be retried")
+	}
+}
+
+func testWorker(t *testing.T) (*Module, pgxmock.PgxPoolIface) {
+	t.Helper()
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("pgxmock: %v", err)
+	}
+	t.Cleanup(mock.Close)
+	m := New()
+	m.rt = &shanraq.Runtime{Logger: zap.NewNop()}
+	m.store = newStoreWithPool(mock)
+	return m, mock
+}
+
+func TestProcessJobSchedulesRetryFromPolicy(t *testing.T) {
+	m, mock := testWorker(t)
+	m.HandleFunc("flaky", func(context.Context, Job) error {
+		return RetryAfter(2*time.Minute, errors.New("rate limited"))
+	})
+	job := Job{ID: uuid.New(), Name: "flaky", Attempts: 1, MaxAttempts: 3, LockedBy: "host:1/0"}
+
+	mock.ExpectExec("UPDATE job_queue\\s+SET status = 'retry'").
+		WithArgs(job.ID, pgxmock.AnyArg(), float64(120), "host:1/0").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectExec("INSERT INTO job_attempts").
+		WithArgs(job.ID, 1, OutcomeRetry, pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	m.processJob(context.Background(), job, 0)
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestProcessJobFailsPermanentErrorsAtOnce(t *testing.T) {
+	m, mock := testWorker(t)
+	m.HandleFunc("broken", func(context.Context, Job) error {
+		return Permanent(errors.New("payload does not decode"))
+	}, WithMaxAttempts(10))
+	job := Job{ID: uuid.New(), Name: "broken", Attempts: 1, MaxAttempts: 3, LockedBy: "host:1/0"}
+
+	mock.ExpectExec("UPDATE job_queue\\s+SET status = 'failed'").
+		WithArgs(job.ID, pgxmock.AnyArg(), "host:1/0").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectExec("INSERT INTO job_attempts").
+		WithArgs(job.ID, 1, OutcomeFailed, pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	m.processJob(context.Background(), job, 0)
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
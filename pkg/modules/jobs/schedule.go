@@ -0,0 +1,261 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"shanraq.org/pkg/modules/auth"
+	"shanraq.org/pkg/shanraq"
+	"shanraq.org/pkg/transport/respond"
+)
+
+// scheduleTick is how often each instance looks for due schedules. Specs have
+// minute resolution, so a run fires at most this late.
+const scheduleTick = 15 * time.Second
+
+// scheduleMaxAttempts is what a scheduled job gets unless its handler's policy
+// says otherwise. A sweep that fails three times will be along again at its
+// next run anyway.
+const scheduleMaxAttempts = 3
+
+// SchedulePayload is the payload of a job a schedule enqueued.
+type SchedulePayload struct {
+	Schedule string `json:"schedule"`
+	// DueAt is the run this job stands for; "run now" from the console leaves
+	// it zero.
+	DueAt time.Time `json:"due_at,omitzero"`
+}
+
+// Schedule registers a recurring job: handler runs under name whenever spec
+// comes round. spec is five cron fields in UTC ("0 4 * * 1" is Mondays at
+// 04:00 UTC), one of @hourly, @daily, @weekly, @monthly, or "@every 6h".
+//
+// The spec given here is the default. It is stored with the schedule on first
+// start, and the operator console can switch the schedule off, run it now or
+// give it another spec without a deploy. Each run is an ordinary job — retried
+// under opts like any other, visible in the queue explorer — and fires on one
+// instance however many are running.
+//
+// An invalid spec panics: it is a constant in the caller's code, and a
+// schedule silently never firing is worse than a boot that does not get far.
+func (m *Module) Schedule(name, spec string, handler Handler, opts ...HandleOption) {
+	if _, err := parseSpec(spec); err != nil {
+		panic(fmt.Sprintf("jobs: %s: %v", name, err))
+	}
+	m.Handle(name, handler, opts...)
+	m.schedules[name] = spec
+}
+
+// ScheduleFunc is Schedule for a handler that needs no runtime.
+func (m *Module) ScheduleFunc(name, spec string, fn func(context.Context, Job) error, opts ...HandleOption) {
+	m.Schedule(name, spec, func(ctx context.Context, _ *shanraq.Runtime, job Job) error {
+		return fn(ctx, job)
+	}, opts...)
+}
+
+// scheduleNames lists the schedules this process can run. Only these are
+// fired here: a schedule left in the table by a newer deploy, or by code since
+// removed, would enqueue a job no handler of ours can take.
+func (m *Module) scheduleNames() []string {
+	names := make([]string, 0, len(m.schedules))
+	for name := range m.schedules {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// syncSchedules writes the registered schedules to the table.
+func (m *Module) syncSchedules(ctx context.Context) error {
+	now := time.Now()
+	for _, name := range m.scheduleNames() {
+		spec := m.schedules[name]
+		parsed, _ := parseSpec(spec) // checked by Schedule
+		if err := m.store.SyncSchedule(ctx, name, spec, parsed.next(now)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fireSchedules enqueues whatever is due.
+func (m *Module) fireSchedules(ctx context.Context) (int, error) {
+	return m.store.FireDueSchedules(ctx, m.scheduleNames(), func(sched ScheduleInfo) (Job, time.Time, error) {
+		parsed, err := parseSpec(sched.Spec)
+		if err != nil {
+			return Job{}, time.Time{}, err
+		}
+		next := parsed.next(time.Now())
+		if next.IsZero() {
+			return Job{}, time.Time{}, fmt.Errorf("spec %q has no next run", sched.Spec)
+		}
+		job, err := m.scheduledJob(sched.Name, sched.NextRunAt)
+		return job, next, err
+	})
+}
+
+func (m *Module) scheduledJob(name string, dueAt time.Time) (Job, error) {
+	payload, err := json.Marshal(SchedulePayload{Schedule: name, DueAt: dueAt})
+	if err != nil {
+		return Job{}, fmt.Errorf("encode schedule payload: %w", err)
+	}
+	attempts := scheduleMaxAttempts
+	if p, ok := m.policies[name]; ok && p.MaxAttempts > 0 {
+		attempts = p.MaxAttempts
+	}
+	return Job{
+		ID:          uuid.New(),
+		Name:        name,
+		Payload:     payload,
+		RunAt:       time.Now(),
+		MaxAttempts: attempts,
+	}, nil
+}
+
+// schedulerLoop fires due schedules until ctx ends or Stop is called. The
+// table is synced on the first tick that reaches the database, so a database
+// that is briefly away at boot delays the schedules rather than losing them.
+func (m *Module) schedulerLoop(ctx context.Context) {
+	ticker := time.NewTicker(scheduleTick)
+	defer ticker.Stop()
+
+	synced := false
+	for {
+		if !synced {
+			if err := m.syncSchedules(ctx); err != nil {
+				m.rt.Logger.Error("sync job schedules", zap.Error(err))
+			} else {
+				synced = true
+			}
+		}
+		if synced {
+			if n, err := m.fireSchedules(ctx); err != nil {
+				m.rt.Logger.Error("fire job schedules", zap.Error(err))
+			} else if n > 0 {
+				m.rt.Logger.Debug("job schedules fired", zap.Int("count", n))
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-m.stopping:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Module) scheduleRoutes(r chi.Router) {
+	r.Get("/schedules", m.handleListSchedules)
+	r.With(m.requires(auth.PermJobsSchedule)).Put("/schedules/{name}", m.handleUpdateSchedule)
+	r.With(m.requires(auth.PermJobsSchedule)).Post("/schedules/{name}/enable", m.handleToggleSchedule(true))
+	r.With(m.requires(auth.PermJobsSchedule)).Post("/schedules/{name}/disable", m.handleToggleSchedule(false))
+	r.With(m.requires(auth.PermJobsSchedule)).Post("/schedules/{name}/run", m.handleRunSchedule)
+}
+
+func (m *Module) handleListSchedules(w http.ResponseWriter, r *http.Request) {
+	scheds, err := m.store.ListSchedules(r.Context())
+	if err != nil {
+		respond.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	if scheds == nil {
+		scheds = []ScheduleInfo{}
+	}
+	respond.JSON(w, http.StatusOK, scheds)
+}
+
+func (m *Module) handleUpdateSchedule(w http.ResponseWriter, r *http.Request) {
+	var req scheduleUpdateRequest
+	if err := respond.Decode(r, &req); err != nil {
+		respond.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	sched, err := m.store.GetSchedule(r.Context(), chi.URLParam(r, "name"))
+	if err != nil {
+		m.scheduleError(w, err)
+		return
+	}
+	spec := strings.TrimSpace(req.Spec)
+	if spec == "" {
+		spec = sched.DefaultSpec
+	}
+	parsed, err := parseSpec(spec)
+	if err != nil {
+		respond.Validation(w, map[string]string{"spec": err.Error()})
+		return
+	}
+	next := parsed.next(time.Now())
+	if next.IsZero() {
+		respond.Validation(w, map[string]string{"spec": "never comes round"})
+		return
+	}
+	if err := m.store.UpdateSchedule(r.Context(), sched.Name, spec, sched.Enabled, next); err != nil {
+		m.scheduleError(w, err)
+		return
+	}
+	m.record(r, auth.AuditRecord{Action: "schedule.update", TargetType: "schedule", TargetID: sched.Name,
+		Before: map[string]string{"spec": sched.Spec}, After: map[string]string{"spec": spec}})
+	respond.JSON(w, http.StatusOK, map[string]any{"spec": spec, "next_run_at": next})
+}
+
+func (m *Module) handleToggleSchedule(enabled bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sched, err := m.store.GetSchedule(r.Context(), chi.URLParam(r, "name"))
+		if err != nil {
+			m.scheduleError(w, err)
+			return
+		}
+		next := sched.NextRunAt
+		if enabled && !sched.Enabled {
+			// Back on from now, not from whenever it was switched off.
+			if parsed, err := parseSpec(sched.Spec); err == nil {
+				next = parsed.next(time.Now())
+			}
+		}
+		if err := m.store.UpdateSchedule(r.Context(), sched.Name, sched.Spec, enabled, next); err != nil {
+			m.scheduleError(w, err)
+			return
+		}
+		m.record(r, auth.AuditRecord{Action: "schedule.toggle", TargetType: "schedule", TargetID: sched.Name,
+			Before: map[string]bool{"enabled": sched.Enabled}, After: map[string]bool{"enabled": enabled}})
+		respond.JSON(w, http.StatusOK, map[string]any{"enabled": enabled, "next_run_at": next})
+	}
+}
+
+func (m *Module) handleRunSchedule(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if _, ok := m.schedules[name]; !ok {
+		m.scheduleError(w, ErrScheduleNotFound)
+		return
+	}
+	job, err := m.scheduledJob(name, time.Time{})
+	if err != nil {
+		respond.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	if err := m.store.RunScheduleNow(r.Context(), job); err != nil {
+		m.scheduleError(w, err)
+		return
+	}
+	m.record(r, auth.AuditRecord{Action: "schedule.run", TargetType: "schedule", TargetID: name,
+		After: map[string]string{"job": job.ID.String()}})
+	respond.JSON(w, http.StatusAccepted, map[string]string{"id": job.ID.String()})
+}
+
+func (m *Module) scheduleError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrScheduleNotFound) {
+		respond.Error(w, http.StatusNotFound, err)
+		return
+	}
+	respond.Error(w, http.StatusInternalServerError, err)
+}
@@ -0,0 +1,119 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	pgxmock "github.com/pashagolub/pgxmock/v4"
+)
+
+func TestScheduleRejectsBadSpec(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Schedule accepted a spec that never parses")
+		}
+	}()
+	New().ScheduleFunc("broken", "every day", func(context.Context, Job) error { return nil })
+}
+
+// One transaction locks the due rows, enqueues, and moves next_run_at on —
+// which is what keeps a second instance from firing the same run.
+func TestFireSchedulesEnqueuesAndAdvances(t *testing.T) {
+	m, mock := testWorker(t)
+	m.ScheduleFunc("sweep", "*/5 * * * *", func(context.Context, Job) error { return nil }, WithMaxAttempts(2))
+	due := time.Now().Add(-time.Minute)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT s.name, s.spec, s.next_run_at\\s+FROM job_schedules s").
+		WithArgs([]string{"sweep"}).
+		WillReturnRows(pgxmock.NewRows([]string{"name", "spec", "next_run_at"}).AddRow("sweep", "*/5 * * * *", due))
+	mock.ExpectExec("INSERT INTO job_queue").
+		WithArgs(pgxmock.AnyArg(), "sweep", pgxmock.AnyArg(), 2).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec("UPDATE job_schedules\\s+SET next_run_at = \\$2").
+		WithArgs("sweep", pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(`SELECT pg_notify`).
+		WithArgs(notifyChannel, DefaultQueue).
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+
+	n, err := m.fireSchedules(context.Background())
+	if err != nil {
+		t.Fatalf("fire: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("fired %d, want 1", n)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestFireSchedulesWithoutSchedulesSkipsTheDatabase(t *testing.T) {
+	m, mock := testWorker(t)
+	if n, err := m.fireSchedules(context.Background()); err != nil || n != 0 {
+		t.Fatalf("fire = %d, %v", n, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestScheduledJobPayload(t *testing.T) {
+	m := New()
+	m.ScheduleFunc("digest", "@weekly", func(context.Context, Job) error { return nil })
+	due := time.Date(2025, 11, 10, 4, 0, 0, 0, time.UTC)
+	job, err := m.scheduledJob("digest", due)
+	if err != nil {
+		t.Fatalf("scheduled job: %v", err)
+	}
+	var p SchedulePayload
+	if err := json.Unmarshal(job.Payload, &p); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if p.Schedule != "digest" || !p.DueAt.Equal(due) || job.MaxAttempts != scheduleMaxAttempts {
+		t.Fatalf("job = %+v, payload = %+v", job, p)
+	}
+}
+
+// "Run now" is for schedules this build knows; anything else would enqueue a
+// job nobody handles.
+func TestRunScheduleUnknownIsNotFound(t *testing.T) {
+	m, mock := testWorker(t)
+	r := mount(m)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/jobs/schedules/nope/run", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", rec.Code)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestUpdateScheduleRejectsBadSpec(t *testing.T) {
+	m, mock := testWorker(t)
+	now := time.Now()
+	mock.ExpectQuery("FROM job_schedules s\\s+LEFT JOIN job_queue q").
+		WithArgs("sweep").
+		WillReturnRows(pgxmock.NewRows([]string{
+			"name", "spec", "default_spec", "enabled", "next_run_at", "last_run_at", "last_job_id", "status", "updated_at",
+		}).AddRow("sweep", "@hourly", "@hourly", true, now, nil, nil, nil, now))
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPut, "/jobs/schedules/sweep", strings.NewReader(`{"spec":"61 * * * *"}`))
+	req.Header.Set("Content-Type", "application/json")
+	mount(m).ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d (%s)", rec.Code, rec.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
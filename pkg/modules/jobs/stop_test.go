@@ -0,0 +1,35 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// Stop must not return while a worker is still in the middle of a job, and
+// must give up at its deadline rather than hang the shutdown.
+func TestStopWaitsForWorkersUntilDeadline(t *testing.T) {
+	m := New()
+	release := make(chan struct{})
+	m.workers.Add(1)
+	go func() {
+		defer m.workers.Done()
+		<-release
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := m.Stop(ctx); err == nil {
+		t.Fatal("Stop returned nil with a worker still busy past the deadline")
+	}
+	select {
+	case <-m.stopping:
+	default:
+		t.Fatal("Stop did not tell the workers to stop claiming")
+	}
+
+	close(release)
+	if err := m.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop after the worker finished: %v", err)
+	}
+}
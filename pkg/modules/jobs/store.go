@@ -3,7 +3,10 @@ package jobs
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -62,6 +65,7 @@ type MetricsSnapshot struct {
 	Running         int
 	Retry           int
 	Failed          int
+	Cancelled       int
 	Done            int
 	DoneLastHour    int
 	FailedLastHour  int
@@ -69,19 +73,176 @@ type MetricsSnapshot struct {
 	NextScheduledOk bool
 }
 
-func (s *Store) Enqueue(ctx context.Context, job Job) error {
+// Enqueue queues job and returns it. A job with a UniqueKey that another job
+// of the same name still holds is not queued: Enqueue returns the holder
+// instead, and the caller tells the two apart by ID. The partial unique
+// indexes on job_queue decide what "holds" means, so two instances enqueueing
+// at once still end up with one job.
+//
+// A job with After waits for those jobs (see Workflow): it is queued blocked,
+// in their workflow, and released as they finish.
+func (s *Store) Enqueue(ctx context.Context, job Job) (Job, error) {
+	if len(job.After) > 0 {
+		return s.enqueueAfter(ctx, job)
+	}
+	queued, inserted, err := insertJob(ctx, s.db, job)
+	if err != nil {
+		return Job{}, err
+	}
+	if inserted {
+		s.notifyDue(ctx, queued)
+	}
+	return queued, nil
+}
+
+// querier is what inserting a job needs, from the pool or a transaction.
+type querier interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// insertJob is Enqueue without the notification: it reports whether job was
+// inserted or the holder of its unique key returned instead.
+func insertJob(ctx context.Context, db querier, job Job) (Job, bool, error) {
 	var userID any
 	if job.UserID != uuid.Nil {
 		userID = job.UserID
 	}
-	_, err := s.db.Exec(ctx, `
-		INSERT INTO job_queue (id, user_id, name, payload, run_at, max_attempts)
-		VALUES ($1, $2, $3, $4, $5, $6)
-	`, job.ID, userID, job.Name, job.Payload, job.RunAt, job.MaxAttempts)
+	if job.Queue == "" {
+		job.Queue = DefaultQueue
+	}
+	// The job's attempts continue the trace it was enqueued in.
+	var trace any
+	if raw := traceContext(ctx); raw != nil {
+		trace = raw
+	}
+	if job.UniqueKey == "" {
+		_, err := db.Exec(ctx, `
+			INSERT INTO job_queue (id, user_id, name, payload, run_at, max_attempts, queue, priority, trace_context)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		`, job.ID, userID, job.Name, job.Payload, job.RunAt, job.MaxAttempts, job.Queue, job.Priority, trace)
+		if err != nil {
+			return Job{}, false, fmt.Errorf("enqueue job: %w", err)
+		}
+		job.Status = "pending"
+		jobsEnqueued.WithLabelValues(job.Name, job.Queue).Inc()
+		return job, true, nil
+	}
+
+	if job.UniqueScope == "" {
+		job.UniqueScope = ScopeActive
+	}
+	switch job.UniqueScope {
+	case ScopePending, ScopeActive:
+	case ScopeWindow:
+		if job.UniqueFor <= 0 {
+			return Job{}, false, errors.New("enqueue job: the window scope needs UniqueFor")
+		}
+	default:
+		return Job{}, false, fmt.Errorf("enqueue job: unknown unique scope %q", job.UniqueScope)
+	}
+
+	// The holder can finish between the insert that lost to it and the read
+	// that looks it up; the key is free again by then, so the next round
+	// queues the job. Three rounds is more than a real race ever takes.
+	for range 3 {
+		if job.UniqueScope == ScopeWindow {
+			// A window outlives its job, so nothing but time releases the
+			// key, and an index predicate cannot mention NOW(). Expired keys
+			// are dropped here, by the next enqueue that wants them.
+			if _, err := db.Exec(ctx, `
+				UPDATE job_queue
+				SET unique_key = NULL
+				WHERE name = $1
+				  AND unique_key = $2
+				  AND unique_scope = 'window'
+				  AND unique_until <= NOW()
+			`, job.Name, job.UniqueKey); err != nil {
+				return Job{}, false, fmt.Errorf("release unique key: %w", err)
+			}
+		}
+		tag, err := db.Exec(ctx, `
+			INSERT INTO job_queue (id, user_id, name, payload, run_at, max_attempts, unique_key, unique_scope, unique_until, queue, priority, trace_context)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8,
+			        CASE WHEN $8 = 'window' THEN NOW() + make_interval(secs => $9) END, $10, $11, $12)
+			ON CONFLICT DO NOTHING
+		`, job.ID, userID, job.Name, job.Payload, job.RunAt, job.MaxAttempts, job.UniqueKey, string(job.UniqueScope), job.UniqueFor.Seconds(), job.Queue, job.Priority, trace)
+		if err != nil {
+			return Job{}, false, fmt.Errorf("enqueue job: %w", err)
+		}
+		if tag.RowsAffected() == 1 {
+			job.Status = "pending"
+			jobsEnqueued.WithLabelValues(job.Name, job.Queue).Inc()
+			return job, true, nil
+		}
+		holder, err := scanJob(db.QueryRow(ctx, `
+			SELECT `+jobFields+`
+			FROM job_queue
+			WHERE name = $1
+			  AND unique_key = $2
+			  AND unique_scope = $3
+			  AND CASE unique_scope
+			        WHEN 'pending' THEN status IN ('pending', 'retry', 'blocked')
+			        WHEN 'active' THEN status IN ('pending', 'retry', 'running', 'blocked')
+			        ELSE TRUE
+			      END
+			LIMIT 1
+		`, job.Name, job.UniqueKey, string(job.UniqueScope)))
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return Job{}, false, fmt.Errorf("find job holding unique key: %w", err)
+		}
+		holder.UniqueKey = job.UniqueKey
+		holder.UniqueScope = job.UniqueScope
+		return holder, false, nil
+	}
+	return Job{}, false, fmt.Errorf("enqueue job: unique key %q kept changing hands", job.UniqueKey)
+}
+
+// notifyDue wakes a worker of the job's queue for a job that is due now. One
+// that runs later is left to the workers' safety-net poll: waking them now
+// would find nothing.
+func (s *Store) notifyDue(ctx context.Context, job Job) {
+	if job.RunAt.After(time.Now()) {
+		return
+	}
+	s.notify(ctx, job.Queue)
+}
+
+// notify tells the listening workers a job is ready in queue. An empty queue
+// wakes every idle worker of every queue: what changed could be anywhere. A
+// lost notification costs at most one poll interval, so a failure is not the
+// caller's failure: the job is queued either way.
+func (s *Store) notify(ctx context.Context, queue string) {
+	_, _ = s.db.Exec(ctx, `SELECT pg_notify($1, $2)`, notifyChannel, queue)
+}
+
+// QueueLag describes the work that is due and not yet picked up.
+type QueueLag struct {
+	Due    int
+	Oldest time.Duration
+}
+
+// Lag reports how many jobs are due now and how long the oldest of them has
+// been waiting. A healthy queue keeps Oldest near the poll interval; minutes
+// mean the workers are wedged or outnumbered.
+func (s *Store) Lag(ctx context.Context) (QueueLag, error) {
+	var lag QueueLag
+	var seconds float64
+	err := s.db.QueryRow(ctx, `
+		SELECT COUNT(*),
+		       COALESCE(EXTRACT(EPOCH FROM NOW() - MIN(run_at)), 0)::float8
+		FROM job_queue
+		WHERE status IN ('pending', 'retry')
+		  AND run_at <= NOW()
+	`).Scan(&lag.Due, &seconds)
 	if err != nil {
-		return fmt.Errorf("enqueue job: %w", err)
+		return QueueLag{}, fmt.Errorf("queue lag: %w", err)
 	}
-	return nil
+	lag.Oldest = time.Duration(seconds * float64(time.Second))
+	return lag, nil
 }
 
 // Metrics returns aggregate queue statistics for dashboards.
@@ -95,6 +256,7 @@ func (s *Store) Metrics(ctx context.Context, userID *uuid.UUID) (MetricsSnapshot
 			COUNT(*) FILTER (WHERE status = 'running') AS running,
 			COUNT(*) FILTER (WHERE status = 'retry') AS retry,
 			COUNT(*) FILTER (WHERE status = 'failed') AS failed,
+			COUNT(*) FILTER (WHERE status = 'cancelled') AS cancelled,
 			COUNT(*) FILTER (WHERE status = 'done') AS done,
 			COUNT(*) FILTER (WHERE status = 'done' AND updated_at >= NOW() - INTERVAL '1 hour') AS done_last_hour,
 			COUNT(*) FILTER (WHERE status = 'failed' AND updated_at >= NOW() - INTERVAL '1 hour') AS failed_last_hour,
@@ -113,6 +275,7 @@ func (s *Store) Metrics(ctx context.Context, userID *uuid.UUID) (MetricsSnapshot
 		&snap.Running,
 		&snap.Retry,
 		&snap.Failed,
+		&snap.Cancelled,
 		&snap.Done,
 		&snap.DoneLastHour,
 		&snap.FailedLastHour,
@@ -128,7 +291,57 @@ func (s *Store) Metrics(ctx context.Context, userID *uuid.UUID) (MetricsSnapshot
 	return snap, nil
 }
 
-func (s *Store) ClaimNextJob(ctx context.Context) (Job, error) {
+// QueueStats is Metrics per queue: how deep each queue is and how fast it
+// drains. Pool sizes are this instance's business and left to the caller.
+func (s *Store) QueueStats(ctx context.Context, userID *uuid.UUID) ([]QueueStat, error) {
+	query := `
+		SELECT
+			queue,
+			COUNT(*) FILTER (WHERE status IN ('pending', 'retry') AND run_at <= NOW()) AS due,
+			COUNT(*) FILTER (WHERE status IN ('pending', 'retry') AND run_at > NOW()) AS waiting,
+			COUNT(*) FILTER (WHERE status = 'running') AS running,
+			COUNT(*) FILTER (WHERE status = 'done' AND updated_at >= NOW() - INTERVAL '1 hour') AS done_last_hour,
+			COUNT(*) FILTER (WHERE status = 'failed' AND updated_at >= NOW() - INTERVAL '1 hour') AS failed_last_hour,
+			COALESCE(EXTRACT(EPOCH FROM NOW() - MIN(run_at) FILTER (WHERE status IN ('pending', 'retry') AND run_at <= NOW())), 0)::float8 AS oldest_due
+		FROM job_queue
+	`
+	args := []any{}
+	if userID != nil && *userID != uuid.Nil {
+		query += " WHERE user_id = $1"
+		args = append(args, *userID)
+	}
+	query += " GROUP BY queue ORDER BY queue"
+
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("queue stats: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []QueueStat
+	for rows.Next() {
+		var st QueueStat
+		if err := rows.Scan(&st.Queue, &st.Due, &st.Waiting, &st.Running, &st.DoneLastHour, &st.FailedLastHour, &st.OldestDueSeconds); err != nil {
+			return nil, fmt.Errorf("scan queue stats: %w", err)
+		}
+		stats = append(stats, st)
+	}
+	return stats, rows.Err()
+}
+
+// QueueFilter picks the queues a claim takes from: Queue alone when set,
+// otherwise every queue but those in Except. The default pool claims the
+// latter way, so a job enqueued to a queue this instance runs no pool for
+// still runs.
+type QueueFilter struct {
+	Queue  string
+	Except []string
+}
+
+// ClaimNextJob takes the due job of the highest priority — the longest
+// waiting among equals — from the queues from selects, for worker, and leases
+// it for as long as lease says a job of its name may run without a heartbeat.
+func (s *Store) ClaimNextJob(ctx context.Context, from QueueFilter, worker string, lease func(name string) time.Duration) (Job, error) {
 	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
@@ -138,15 +351,21 @@ func (s *Store) ClaimNextJob(ctx context.Context) (Job, error) {
 	}
 	defer tx.Rollback(ctx)
 
+	queue, arg := "queue <> ALL($1)", any(append([]string{}, from.Except...))
+	if from.Queue != "" {
+		queue, arg = "queue = $1", from.Queue
+	}
+	var trace []byte
 	job, err := scanJob(tx.QueryRow(ctx, `
-		SELECT id, user_id, name, payload, run_at, attempts, max_attempts, status, last_error, created_at, updated_at
+		SELECT `+jobFields+`, trace_context
 		FROM job_queue
 		WHERE status IN ('pending', 'retry')
 		  AND run_at <= NOW()
-		ORDER BY run_at
+		  AND `+queue+`
+		ORDER BY priority DESC, run_at
 		FOR UPDATE SKIP LOCKED
 		LIMIT 1
-	`))
+	`, arg), &trace)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return Job{}, ErrNoJobs
@@ -158,14 +377,20 @@ func (s *Store) ClaimNextJob(ctx context.Context) (Job, error) {
 		UPDATE job_queue
 		SET status = 'running',
 		    attempts = attempts + 1,
+		    locked_by = $2,
+		    locked_until = NOW() + make_interval(secs => $3),
+		    progress = NULL,
+		    result = NULL,
 		    updated_at = NOW()
 		WHERE id = $1
-	`, job.ID)
+	`, job.ID, worker, lease(job.Name).Seconds())
 	if err != nil {
 		return Job{}, fmt.Errorf("mark running: %w", err)
 	}
 	job.Attempts++
 	job.Status = "running"
+	job.LockedBy = worker
+	job.traceContext = trace
 
 	if err := tx.Commit(ctx); err != nil {
 		return Job{}, fmt.Errorf("commit: %w", err)
@@ -173,76 +398,121 @@ func (s *Store) ClaimNextJob(ctx context.Context) (Job, error) {
 	return job, nil
 }
 
-func (s *Store) MarkDone(ctx context.Context, id uuid.UUID) error {
-	_, err := s.db.Exec(ctx, `
+// MarkDone records worker's attempt at the job as a success. Like ExtendLease,
+// SetProgress and SetResult, it only touches a job still running under worker,
+// and returns ErrLeaseLost otherwise: a worker whose lease ran out must not
+// finish a job the reaper has requeued, another worker has claimed, or an
+// operator has cancelled.
+func (s *Store) MarkDone(ctx context.Context, id uuid.UUID, worker string) error {
+	tag, err := s.db.Exec(ctx, `
 		UPDATE job_queue
 		SET status = 'done',
 		    last_error = NULL,
+		    locked_by = NULL,
+		    locked_until = NULL,
 		    updated_at = NOW()
 		WHERE id = $1
-	`, id)
+		  AND status = 'running'
+		  AND locked_by = $2
+	`, id, worker)
 	if err != nil {
 		return fmt.Errorf("mark done: %w", err)
 	}
+	if tag.RowsAffected() == 0 {
+		return ErrLeaseLost
+	}
 	return nil
 }
 
-func (s *Store) MarkFailed(ctx context.Context, id uuid.UUID, reason string) error {
-	_, err := s.db.Exec(ctx, `
+// MarkFailed records worker's attempt as the job's last, failed one. It
+// returns ErrLeaseLost when the job is no longer running under worker.
+func (s *Store) MarkFailed(ctx context.Context, id uuid.UUID, worker, reason string) error {
+	tag, err := s.db.Exec(ctx, `
 		UPDATE job_queue
 		SET status = 'failed',
 		    last_error = $2,
+		    locked_by = NULL,
+		    locked_until = NULL,
 		    updated_at = NOW()
 		WHERE id = $1
-	`, id, reason)
+		  AND status = 'running'
+		  AND locked_by = $3
+	`, id, reason, worker)
 	if err != nil {
 		return fmt.Errorf("mark failed: %w", err)
 	}
+	if tag.RowsAffected() == 0 {
+		return ErrLeaseLost
+	}
 	return nil
 }
 
-func (s *Store) MarkRetry(ctx context.Context, id uuid.UUID, reason string, userID *uuid.UUID) error {
+// MarkRetry schedules the job's next attempt delay from now. The delay is
+// added on the database's clock, the one ClaimNextJob compares run_at with.
+// It returns ErrLeaseLost when the job is no longer running under worker.
+func (s *Store) MarkRetry(ctx context.Context, id uuid.UUID, worker, reason string, delay time.Duration, userID *uuid.UUID) error {
 	query := `
 		UPDATE job_queue
 		SET status = 'retry',
 		    last_error = $2,
-		    run_at = NOW() + INTERVAL '15 seconds',
+		    run_at = NOW() + make_interval(secs => $3),
+		    locked_by = NULL,
+		    locked_until = NULL,
 		    updated_at = NOW()
 		WHERE id = $1
+		  AND status = 'running'
+		  AND locked_by = $4
 	`
-	args := []any{id, reason}
+	args := []any{id, reason, delay.Seconds(), worker}
 	query = addUserFilter(query, &args, userID)
-	_, err := s.db.Exec(ctx, query, args...)
+	tag, err := s.db.Exec(ctx, query, args...)
 	if err != nil {
 		return fmt.Errorf("mark retry: %w", err)
 	}
+	if tag.RowsAffected() == 0 {
+		return ErrLeaseLost
+	}
 	return nil
 }
 
+// MarkPending sends a job back to the queue with its full allowance of
+// attempts; job_attempts keeps the history of the ones it used up. It returns
+// ErrDuplicateJob when a newer job already holds the job's unique key.
 func (s *Store) MarkPending(ctx context.Context, id uuid.UUID, userID *uuid.UUID) error {
 	query := `
 		UPDATE job_queue
-		SET status = 'pending',
+		SET status = ` + pendingOrBlocked + `,
+		    attempts = 0,
 		    run_at = NOW(),
 		    updated_at = NOW()
 		WHERE id = $1
 	`
 	args := []any{id}
 	query = addUserFilter(query, &args, userID)
-	_, err := s.db.Exec(ctx, query, args...)
+	tag, err := s.db.Exec(ctx, query, args...)
 	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrDuplicateJob
+		}
 		return fmt.Errorf("mark pending: %w", err)
 	}
+	if tag.RowsAffected() > 0 {
+		s.notify(ctx, "")
+	}
 	return nil
 }
 
+// Cancel stops a job that has not finished. It is 'cancelled', not 'failed':
+// replaying the dead letters after an outage must not bring back what an
+// operator deliberately stopped.
 func (s *Store) Cancel(ctx context.Context, id uuid.UUID, reason string, userID *uuid.UUID) error {
 	query := `
 		UPDATE job_queue
-		SET status = 'failed',
+		SET status = 'cancelled',
 		    last_error = $2,
 		    updated_at = NOW()
 		WHERE id = $1
+		  AND status IN ('pending', 'retry', 'running', 'blocked')
 	`
 	args := []any{id, reason}
 	query = addUserFilter(query, &args, userID)
@@ -268,25 +538,14 @@ func (s *Store) List(ctx context.Context, opts ListOptions) ([]Job, error) {
 	}
 
 	var builder strings.Builder
-	builder.WriteString("SELECT id, user_id, name, payload, run_at, attempts, max_attempts, status, last_error, created_at, updated_at FROM job_queue")
+	builder.WriteString("SELECT " + jobFields + " FROM job_queue")
 
-	args := make([]any, 0, 3)
-	idx := 1
-	conditions := make([]string, 0, 2)
-	if opts.UserID != nil && *opts.UserID != uuid.Nil {
-		conditions = append(conditions, "user_id = $"+strconv.Itoa(idx))
-		args = append(args, *opts.UserID)
-		idx++
-	}
-	if opts.Status != "" {
-		conditions = append(conditions, "status = $"+strconv.Itoa(idx))
-		args = append(args, opts.Status)
-		idx++
-	}
-	if len(conditions) > 0 {
+	args := make([]any, 0, 6)
+	if conditions := filterConditions(opts, &args); len(conditions) > 0 {
 		builder.WriteString(" WHERE ")
 		builder.WriteString(strings.Join(conditions, " AND "))
 	}
+	idx := len(args) + 1
 
 	builder.WriteString(" ORDER BY created_at DESC LIMIT $")
 	builder.WriteString(strconv.Itoa(idx))
@@ -353,11 +612,53 @@ func addUserFilter(query string, args *[]any, userID *uuid.UUID) string {
 	return query
 }
 
-func scanJob(row pgx.Row) (Job, error) {
+// filterConditions turns the list filters into WHERE conditions, appending
+// their arguments.
+func filterConditions(opts ListOptions, args *[]any) []string {
+	var conditions []string
+	add := func(cond string, arg any) {
+		*args = append(*args, arg)
+		conditions = append(conditions, strings.ReplaceAll(cond, "$?", "$"+strconv.Itoa(len(*args))))
+	}
+	if opts.UserID != nil && *opts.UserID != uuid.Nil {
+		add("user_id = $?", *opts.UserID)
+	}
+	if opts.Status != "" {
+		add("status = $?", opts.Status)
+	}
+	if opts.Name != "" {
+		add("name = $?", opts.Name)
+	}
+	if opts.Error != "" {
+		add("last_error ILIKE '%' || $? || '%'", escapeLike(opts.Error))
+	}
+	if !opts.Since.IsZero() {
+		add("updated_at >= $?", opts.Since)
+	}
+	return conditions
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// escapeLike makes % and _ in a search term match themselves.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// jobFields are the columns scanJob reads, in its order.
+const jobFields = "id, user_id, name, payload, run_at, attempts, max_attempts, status, last_error, created_at, updated_at, queue, priority, workflow_id"
+
+// scanJob reads the jobFields of a row, then into extra whatever columns the
+// query selected after them.
+func scanJob(row pgx.Row, extra ...any) (Job, error) {
 	var job Job
 	var lastError *string
-	var user pgtype.UUID
-	if err := row.Scan(&job.ID, &user, &job.Name, &job.Payload, &job.RunAt, &job.Attempts, &job.MaxAttempts, &job.Status, &lastError, &job.CreatedAt, &job.UpdatedAt); err != nil {
+	var user, workflow pgtype.UUID
+	dest := []any{&job.ID, &user, &job.Name, &job.Payload, &job.RunAt, &job.Attempts, &job.MaxAttempts, &job.Status, &lastError, &job.CreatedAt, &job.UpdatedAt, &job.Queue, &job.Priority, &workflow}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
 		if err == pgx.ErrNoRows {
 			return Job{}, err
 		}
@@ -369,6 +670,520 @@ func scanJob(row pgx.Row) (Job, error) {
 			job.UserID = u
 		}
 	}
+	if workflow.Valid {
+		job.WorkflowID = workflow.Bytes
+	}
 	job.LastError = lastError
 	return job, nil
 }
+
+// ExtendLease renews a running job's lease for worker. It returns
+// ErrLeaseLost when the job is no longer running under that worker — reaped,
+// cancelled, or finished by someone else.
+func (s *Store) ExtendLease(ctx context.Context, id uuid.UUID, worker string, lease time.Duration) error {
+	tag, err := s.db.Exec(ctx, `
+		UPDATE job_queue
+		SET locked_until = NOW() + make_interval(secs => $3)
+		WHERE id = $1
+		  AND locked_by = $2
+		  AND status = 'running'
+	`, id, worker, lease.Seconds())
+	if err != nil {
+		return fmt.Errorf("extend lease: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// SetProgress records a running job's progress for worker and renews its
+// lease, since reporting progress is proof of life. Like ExtendLease it
+// returns ErrLeaseLost when the job is no longer the worker's. updated_at is
+// left alone: it still says when the attempt started.
+func (s *Store) SetProgress(ctx context.Context, id uuid.UUID, worker string, p Progress, lease time.Duration) error {
+	raw, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("encode progress: %w", err)
+	}
+	tag, err := s.db.Exec(ctx, `
+		UPDATE job_queue
+		SET progress = $3,
+		    locked_until = NOW() + make_interval(secs => $4)
+		WHERE id = $1
+		  AND locked_by = $2
+		  AND status = 'running'
+	`, id, worker, raw, lease.Seconds())
+	if err != nil {
+		return fmt.Errorf("set progress: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// SetResult records a running job's result for worker. It returns
+// ErrLeaseLost when the job is no longer the worker's.
+func (s *Store) SetResult(ctx context.Context, id uuid.UUID, worker string, result json.RawMessage) error {
+	tag, err := s.db.Exec(ctx, `
+		UPDATE job_queue
+		SET result = $3
+		WHERE id = $1
+		  AND locked_by = $2
+		  AND status = 'running'
+	`, id, worker, result)
+	if err != nil {
+		return fmt.Errorf("set result: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// Get returns one job with its progress and result. A non-nil userID limits
+// the lookup to that tenant's jobs; a job of anyone else's is ErrJobNotFound,
+// as is one that does not exist.
+func (s *Store) Get(ctx context.Context, id uuid.UUID, userID *uuid.UUID) (Job, error) {
+	query := `SELECT ` + jobFields + `, progress, result FROM job_queue WHERE id = $1`
+	args := []any{id}
+	if userID != nil && *userID != uuid.Nil {
+		query += " AND user_id = $2"
+		args = append(args, *userID)
+	}
+	var progress, result []byte
+	job, err := scanJob(s.db.QueryRow(ctx, query, args...), &progress, &result)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Job{}, ErrJobNotFound
+	}
+	if err != nil {
+		return Job{}, err
+	}
+	if len(progress) > 0 {
+		job.Progress = &Progress{}
+		if err := json.Unmarshal(progress, job.Progress); err != nil {
+			return Job{}, fmt.Errorf("decode progress: %w", err)
+		}
+	}
+	job.Result = result
+	return job, nil
+}
+
+// ReapExpiredLeases returns running jobs whose lease ran out to the queue —
+// to 'retry' if they have attempts left, 'failed' if not, so a job that kills
+// its worker every time cannot do so forever — and records the lost attempt
+// in the job's history. It reports how many it reaped.
+//
+// The attempt is taken to have started when the job was claimed: a heartbeat
+// moves locked_until and nothing else, so updated_at still says when.
+func (s *Store) ReapExpiredLeases(ctx context.Context) (int, error) {
+	var n int
+	err := s.db.QueryRow(ctx, `
+		WITH expired AS (
+			SELECT id, locked_by, updated_at AS started_at
+			FROM job_queue
+			WHERE status = 'running'
+			  AND locked_until < NOW()
+			FOR UPDATE SKIP LOCKED
+		), lost AS (
+			UPDATE job_queue q
+			SET status = CASE WHEN q.attempts >= q.max_attempts THEN 'failed'::job_status ELSE 'retry'::job_status END,
+			    last_error = 'worker lost: ' || COALESCE(e.locked_by, 'unknown') || ' stopped renewing its lease',
+			    run_at = NOW(),
+			    locked_by = NULL,
+			    locked_until = NULL,
+			    updated_at = NOW()
+			FROM expired e
+			WHERE q.id = e.id
+			RETURNING q.id, q.attempts, q.status, q.last_error, e.started_at
+		), history AS (
+			INSERT INTO job_attempts (job_id, attempt, outcome, error, started_at, duration_ms)
+			SELECT id, attempts, status::text, last_error, started_at,
+			       (EXTRACT(EPOCH FROM NOW() - started_at) * 1000)::bigint
+			FROM lost
+		)
+		SELECT COUNT(*) FROM lost
+	`).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("reap job leases: %w", err)
+	}
+	if n > 0 {
+		s.notify(ctx, "")
+	}
+	return n, nil
+}
+
+// RecordAttempt appends one run to the job's history.
+func (s *Store) RecordAttempt(ctx context.Context, a Attempt) error {
+	_, err := s.db.Exec(ctx, `
+		INSERT INTO job_attempts (job_id, attempt, outcome, error, started_at, duration_ms)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, a.JobID, a.Attempt, a.Outcome, a.Error, a.StartedAt, a.DurationMS)
+	if err != nil {
+		return fmt.Errorf("record attempt: %w", err)
+	}
+	return nil
+}
+
+// Attempts returns a job's history, oldest first.
+func (s *Store) Attempts(ctx context.Context, jobID uuid.UUID, userID *uuid.UUID) ([]Attempt, error) {
+	query := `
+		SELECT a.job_id, a.attempt, a.outcome, a.error, a.started_at, a.duration_ms
+		FROM job_attempts a
+		JOIN job_queue q ON q.id = a.job_id
+		WHERE a.job_id = $1
+	`
+	args := []any{jobID}
+	if userID != nil && *userID != uuid.Nil {
+		query += " AND q.user_id = $2"
+		args = append(args, *userID)
+	}
+	query += " ORDER BY a.started_at, a.id"
+	return s.queryAttempts(ctx, query, args...)
+}
+
+func (s *Store) queryAttempts(ctx context.Context, query string, args ...any) ([]Attempt, error) {
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list attempts: %w", err)
+	}
+	defer rows.Close()
+
+	var out []Attempt
+	for rows.Next() {
+		var a Attempt
+		if err := rows.Scan(&a.JobID, &a.Attempt, &a.Outcome, &a.Error, &a.StartedAt, &a.DurationMS); err != nil {
+			return nil, fmt.Errorf("scan attempt: %w", err)
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+// replayable and purgeable are the statuses the bulk actions may touch. A
+// replay of running or pending jobs would run them twice; a purge of them
+// would lose work nobody has done yet.
+var (
+	replayable = []string{"failed", "cancelled"}
+	purgeable  = []string{"failed", "cancelled", "done"}
+)
+
+// Replay sends every failed or cancelled job matching opts back to the queue
+// with a fresh allowance of attempts, and reports how many. Of the jobs
+// sharing a unique key only the newest is replayed, and none while a live job
+// holds the key: the replay would be the very duplicate the key is there to
+// stop, and the unique index would fail the whole statement over it. A job
+// with a dependency that has not succeeded goes back to blocked, to wait for
+// it again.
+func (s *Store) Replay(ctx context.Context, opts ListOptions) (int, error) {
+	n, err := s.bulk(ctx, `
+		UPDATE job_queue
+		SET status = `+pendingOrBlocked+`,
+		    attempts = 0,
+		    run_at = NOW(),
+		    last_error = NULL,
+		    updated_at = NOW()
+	`, replayable, opts, `(unique_key IS NULL OR unique_scope = 'window' OR (
+		NOT EXISTS (
+			SELECT 1 FROM job_queue live
+			WHERE live.name = job_queue.name
+			  AND live.unique_key = job_queue.unique_key
+			  AND live.status IN ('pending', 'retry', 'running', 'blocked'))
+		AND NOT EXISTS (
+			SELECT 1 FROM job_queue newer
+			WHERE newer.name = job_queue.name
+			  AND newer.unique_key = job_queue.unique_key
+			  AND newer.status::text = ANY($1)
+			  AND (newer.updated_at, newer.id) > (job_queue.updated_at, job_queue.id))))`)
+	if err != nil {
+		return 0, err
+	}
+	if n > 0 {
+		s.notify(ctx, "")
+	}
+	return n, nil
+}
+
+// Purge deletes every finished job matching opts, with its history.
+func (s *Store) Purge(ctx context.Context, opts ListOptions) (int, error) {
+	return s.bulk(ctx, `DELETE FROM job_queue`, purgeable, opts)
+}
+
+// bulk runs stmt over the jobs in the allowed statuses matching opts. extra
+// conditions may refer to the allowed statuses as $1.
+func (s *Store) bulk(ctx context.Context, stmt string, allowed []string, opts ListOptions, extra ...string) (int, error) {
+	if opts.Status != "" && !slices.Contains(allowed, opts.Status) {
+		return 0, fmt.Errorf("%w: %q", ErrBulkStatus, opts.Status)
+	}
+	args := []any{allowed}
+	conditions := append([]string{"status::text = ANY($1)"}, filterConditions(opts, &args)...)
+	conditions = append(conditions, extra...)
+	tag, err := s.db.Exec(ctx, stmt+" WHERE "+strings.Join(conditions, " AND "), args...)
+	if err != nil {
+		return 0, fmt.Errorf("bulk jobs: %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// Export returns up to limit jobs matching opts, newest first, each with its
+// attempts.
+func (s *Store) Export(ctx context.Context, opts ListOptions, limit int) ([]ExportedJob, error) {
+	var builder strings.Builder
+	builder.WriteString("SELECT " + jobFields + " FROM job_queue")
+	args := make([]any, 0, 6)
+	if conditions := filterConditions(opts, &args); len(conditions) > 0 {
+		builder.WriteString(" WHERE ")
+		builder.WriteString(strings.Join(conditions, " AND "))
+	}
+	args = append(args, limit)
+	builder.WriteString(" ORDER BY updated_at DESC LIMIT $" + strconv.Itoa(len(args)))
+
+	rows, err := s.db.Query(ctx, builder.String(), args...)
+	if err != nil {
+		return nil, fmt.Errorf("export jobs: %w", err)
+	}
+	var out []ExportedJob
+	index := map[uuid.UUID]int{}
+	ids := []uuid.UUID{}
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		index[job.ID] = len(out)
+		ids = append(ids, job.ID)
+		out = append(out, ExportedJob{Job: job, History: []Attempt{}})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("export jobs: %w", err)
+	}
+	if len(ids) == 0 {
+		return out, nil
+	}
+
+	attempts, err := s.queryAttempts(ctx, `
+		SELECT job_id, attempt, outcome, error, started_at, duration_ms
+		FROM job_attempts
+		WHERE job_id = ANY($1)
+		ORDER BY started_at, id
+	`, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range attempts {
+		if i, ok := index[a.JobID]; ok {
+			out[i].History = append(out[i].History, a)
+		}
+	}
+	return out, nil
+}
+
+// SyncSchedule records a schedule the code registered. A new one is inserted
+// to fire at next. An existing one keeps its enabled flag and, if an operator
+// has changed its spec, that spec; otherwise a changed default replaces it and
+// next takes effect.
+func (s *Store) SyncSchedule(ctx context.Context, name, spec string, next time.Time) error {
+	_, err := s.db.Exec(ctx, `
+		INSERT INTO job_schedules (name, spec, default_spec, next_run_at)
+		VALUES ($1, $2, $2, $3)
+		ON CONFLICT (name) DO UPDATE
+		SET spec = CASE WHEN job_schedules.spec = job_schedules.default_spec
+		                THEN EXCLUDED.spec ELSE job_schedules.spec END,
+		    next_run_at = CASE WHEN job_schedules.spec = job_schedules.default_spec
+		                        AND job_schedules.spec <> EXCLUDED.spec
+		                       THEN EXCLUDED.next_run_at ELSE job_schedules.next_run_at END,
+		    default_spec = EXCLUDED.default_spec,
+		    updated_at = CASE WHEN job_schedules.default_spec <> EXCLUDED.default_spec
+		                      THEN NOW() ELSE job_schedules.updated_at END
+	`, name, spec, next)
+	if err != nil {
+		return fmt.Errorf("sync schedule %s: %w", name, err)
+	}
+	return nil
+}
+
+// scheduleFire is what the caller of FireDueSchedules decides for one due
+// schedule: the job to enqueue and when the schedule is next due.
+type scheduleFire func(sched ScheduleInfo) (Job, time.Time, error)
+
+// FireDueSchedules enqueues a job for every enabled schedule among names that
+// is due, and moves each to its next run, in one transaction.
+//
+// The rows are locked with SKIP LOCKED, so a second instance ticking at the
+// same moment passes over them, and by the time the lock is released
+// next_run_at is in the future: each run fires once however many instances
+// there are. A schedule whose previous job is still queued or running is left
+// due rather than stacking another behind it; it fires once that job is done.
+func (s *Store) FireDueSchedules(ctx context.Context, names []string, fire scheduleFire) (int, error) {
+	if len(names) == 0 {
+		return 0, nil
+	}
+	tx, err := s.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		SELECT s.name, s.spec, s.next_run_at
+		FROM job_schedules s
+		WHERE s.enabled
+		  AND s.next_run_at <= NOW()
+		  AND s.name = ANY($1)
+		  AND NOT EXISTS (
+		      SELECT 1 FROM job_queue q
+		      WHERE q.id = s.last_job_id
+		        AND q.status IN ('pending', 'running', 'retry')
+		  )
+		ORDER BY s.next_run_at
+		FOR UPDATE OF s SKIP LOCKED
+	`, names)
+	if err != nil {
+		return 0, fmt.Errorf("due schedules: %w", err)
+	}
+	var due []ScheduleInfo
+	for rows.Next() {
+		var sched ScheduleInfo
+		if err := rows.Scan(&sched.Name, &sched.Spec, &sched.NextRunAt); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scan schedule: %w", err)
+		}
+		due = append(due, sched)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("due schedules: %w", err)
+	}
+
+	fired := 0
+	for _, sched := range due {
+		job, next, err := fire(sched)
+		if err != nil {
+			return 0, fmt.Errorf("schedule %s: %w", sched.Name, err)
+		}
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO job_queue (id, name, payload, run_at, max_attempts)
+			VALUES ($1, $2, $3, NOW(), $4)
+		`, job.ID, job.Name, job.Payload, job.MaxAttempts); err != nil {
+			return 0, fmt.Errorf("enqueue schedule %s: %w", sched.Name, err)
+		}
+		if _, err := tx.Exec(ctx, `
+			UPDATE job_schedules
+			SET next_run_at = $2,
+			    last_run_at = NOW(),
+			    last_job_id = $3,
+			    updated_at = NOW()
+			WHERE name = $1
+		`, sched.Name, next, job.ID); err != nil {
+			return 0, fmt.Errorf("advance schedule %s: %w", sched.Name, err)
+		}
+		fired++
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("commit: %w", err)
+	}
+	for range fired {
+		s.notify(ctx, DefaultQueue)
+	}
+	return fired, nil
+}
+
+// RunScheduleNow enqueues the schedule's job at once, outside its timetable.
+// next_run_at is left alone: the regular run still happens.
+func (s *Store) RunScheduleNow(ctx context.Context, job Job) error {
+	tag, err := s.db.Exec(ctx, `
+		WITH s AS (
+			UPDATE job_schedules
+			SET last_run_at = NOW(),
+			    last_job_id = $1,
+			    updated_at = NOW()
+			WHERE name = $2
+			RETURNING name
+		)
+		INSERT INTO job_queue (id, name, payload, run_at, max_attempts)
+		SELECT $1, s.name, $3, NOW(), $4 FROM s
+	`, job.ID, job.Name, job.Payload, job.MaxAttempts)
+	if err != nil {
+		return fmt.Errorf("run schedule %s: %w", job.Name, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrScheduleNotFound
+	}
+	s.notify(ctx, DefaultQueue)
+	return nil
+}
+
+// GetSchedule loads one schedule.
+func (s *Store) GetSchedule(ctx context.Context, name string) (ScheduleInfo, error) {
+	sched, err := scanSchedule(s.db.QueryRow(ctx, scheduleSelect+` WHERE s.name = $1`, name))
+	if err == pgx.ErrNoRows {
+		return ScheduleInfo{}, ErrScheduleNotFound
+	}
+	return sched, err
+}
+
+// ListSchedules returns every schedule, by name.
+func (s *Store) ListSchedules(ctx context.Context) ([]ScheduleInfo, error) {
+	rows, err := s.db.Query(ctx, scheduleSelect+` ORDER BY s.name`)
+	if err != nil {
+		return nil, fmt.Errorf("list schedules: %w", err)
+	}
+	defer rows.Close()
+
+	var out []ScheduleInfo
+	for rows.Next() {
+		sched, err := scanSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, sched)
+	}
+	return out, rows.Err()
+}
+
+// UpdateSchedule sets a schedule's spec, enabled flag and next run together,
+// so a schedule switched back on after a week off does not fire at once for
+// the run it missed.
+func (s *Store) UpdateSchedule(ctx context.Context, name, spec string, enabled bool, next time.Time) error {
+	tag, err := s.db.Exec(ctx, `
+		UPDATE job_schedules
+		SET spec = $2,
+		    enabled = $3,
+		    next_run_at = $4,
+		    updated_at = NOW()
+		WHERE name = $1
+	`, name, spec, enabled, next)
+	if err != nil {
+		return fmt.Errorf("update schedule %s: %w", name, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrScheduleNotFound
+	}
+	return nil
+}
+
+const scheduleSelect = `
+	SELECT s.name, s.spec, s.default_spec, s.enabled, s.next_run_at, s.last_run_at,
+	       s.last_job_id, q.status::text, s.updated_at
+	FROM job_schedules s
+	LEFT JOIN job_queue q ON q.id = s.last_job_id`
+
+func scanSchedule(row pgx.Row) (ScheduleInfo, error) {
+	var sched ScheduleInfo
+	var lastJob pgtype.UUID
+	if err := row.Scan(&sched.Name, &sched.Spec, &sched.DefaultSpec, &sched.Enabled, &sched.NextRunAt,
+		&sched.LastRunAt, &lastJob, &sched.LastStatus, &sched.UpdatedAt); err != nil {
+		if err == pgx.ErrNoRows {
+			return ScheduleInfo{}, err
+		}
+		return ScheduleInfo{}, fmt.Errorf("scan schedule: %w", err)
+	}
+	if lastJob.Valid {
+		id := uuid.UUID(lastJob.Bytes)
+		sched.LastJobID = &id
+	}
+	return sched, nil
+}
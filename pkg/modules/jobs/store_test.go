@@ -47,11 +47,11 @@ func TestStoreListRecent(t *testing.T) {
 
 	now := time.Now()
 	rows := pgxmock.NewRows([]string{
-		"id", "user_id", "name", "payload", "run_at", "attempts", "max_attempts", "status", "last_error", "created_at", "updated_at",
+		"id", "user_id", "name", "payload", "run_at", "attempts", "max_attempts", "status", "last_error", "created_at", "updated_at", "queue", "priority", "workflow_id",
 	}).
-		AddRow(uuid.New(), uuid.New().String(), "demo", []byte(`{"foo":"bar"}`), now, 1, 3, "done", nil, now, now)
+		AddRow(uuid.New(), uuid.New().String(), "demo", []byte(`{"foo":"bar"}`), now, 1, 3, "done", nil, now, now, DefaultQueue, 0, nil)
 
-	mock.ExpectQuery("SELECT id, user_id, name, payload, run_at, attempts, max_attempts, status, last_error, created_at, updated_at FROM job_queue").
+	mock.ExpectQuery("SELECT id, user_id, name, payload, run_at, attempts, max_attempts, status, last_error, created_at, updated_at, queue, priority, workflow_id FROM job_queue").
 		WithArgs(8, 0).
 		WillReturnRows(rows)
 
@@ -81,9 +81,9 @@ func TestStoreMetrics(t *testing.T) {
 
 	next := time.Now().Add(5 * time.Minute)
 	rows := pgxmock.NewRows([]string{
-		"total", "pending", "running", "retry", "failed", "done",
+		"total", "pending", "running", "retry", "failed", "cancelled", "done",
 		"done_last_hour", "failed_last_hour", "next_scheduled",
-	}).AddRow(10, 3, 2, 1, 0, 4, 2, 0, next)
+	}).AddRow(10, 3, 2, 1, 0, 0, 4, 2, 0, next)
 
 	mock.ExpectQuery("SELECT\\s+COUNT\\(\\*\\) AS total").
 		WillReturnRows(rows)
@@ -104,3 +104,25 @@ func TestStoreMetrics(t *testing.T) {
 		t.Fatalf("unmet expectations: %v", err)
 	}
 }
+
+func TestStoreLag(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("pgxmock: %v", err)
+	}
+	defer mock.Close()
+
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\),\\s+COALESCE\\(EXTRACT\\(EPOCH FROM NOW\\(\\) - MIN\\(run_at\\)\\), 0\\)::float8\\s+FROM job_queue").
+		WillReturnRows(pgxmock.NewRows([]string{"count", "oldest"}).AddRow(4, 90.5))
+
+	lag, err := newStoreWithPool(mock).Lag(context.Background())
+	if err != nil {
+		t.Fatalf("lag: %v", err)
+	}
+	if lag.Due != 4 || lag.Oldest != 90500*time.Millisecond {
+		t.Fatalf("unexpected lag: %+v", lag)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.uber.org/zap"
+)
+
+// The queue's metrics, in the registry the telemetry module serves. The
+// counters and the histogram are kept by whichever instance ran the job; the
+// gauges describe the whole table, so every instance reports the same values
+// and dashboards should take the max, not the sum.
+var (
+	jobsEnqueued = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "shanraq",
+		Subsystem: "jobs",
+		Name:      "enqueued_total",
+		Help:      "Jobs enqueued by this instance, by job name and queue. Deduplicated enqueues are not counted.",
+	}, []string{"name", "queue"})
+	jobsProcessed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "shanraq",
+		Subsystem: "jobs",
+		Name:      "processed_total",
+		Help:      "Job attempts finished by this instance, by job name, queue and outcome (done, retry, failed, lease_lost).",
+	}, []string{"name", "queue", "outcome"})
+	jobsDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "shanraq",
+		Subsystem: "jobs",
+		Name:      "duration_seconds",
+		Help:      "How long job attempts ran, by job name, queue and outcome.",
+		// 50 ms to about 7 minutes: a welcome mail to a long translation.
+		Buckets: prometheus.ExponentialBuckets(0.05, 2, 14),
+	}, []string{"name", "queue", "outcome"})
+	queueDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "shanraq",
+		Subsystem: "jobs",
+		Name:      "queue_depth",
+		Help:      "Jobs per queue that are due, scheduled for later, or running.",
+	}, []string{"queue", "state"})
+	queueLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "shanraq",
+		Subsystem: "jobs",
+		Name:      "queue_lag_seconds",
+		Help:      "How long the longest-waiting due job of each queue has waited.",
+	}, []string{"queue"})
+)
+
+// outcomeLeaseLost labels the attempts whose outcome another party wrote:
+// the reaper, or an operator who cancelled the job. It is a metric label
+// only; job_attempts has the reaper's row instead.
+const outcomeLeaseLost = "lease_lost"
+
+// gaugeInterval is how often the queue gauges are refreshed. Prometheus
+// scrapes every 15 to 60 seconds; fresher values would not be seen.
+const gaugeInterval = 15 * time.Second
+
+// observe records a finished attempt.
+func observe(job Job, outcome string, took time.Duration) {
+	queue := job.Queue
+	if queue == "" {
+		queue = DefaultQueue
+	}
+	jobsProcessed.WithLabelValues(job.Name, queue, outcome).Inc()
+	jobsDuration.WithLabelValues(job.Name, queue, outcome).Observe(took.Seconds())
+}
+
+// gaugeLoop keeps the depth and lag gauges current. The numbers come from
+// the same query as the console's queue table.
+func (m *Module) gaugeLoop(ctx context.Context) {
+	ticker := time.NewTicker(gaugeInterval)
+	defer ticker.Stop()
+	for {
+		if stats, err := m.store.QueueStats(ctx, nil); err != nil {
+			if ctx.Err() == nil {
+				m.rt.Logger.Warn("refresh job queue gauges", zap.Error(err))
+			}
+		} else {
+			// Reset first, so a queue that has emptied out of the table
+			// stops being reported instead of freezing at its last value.
+			queueDepth.Reset()
+			queueLag.Reset()
+			for _, q := range stats {
+				queueDepth.WithLabelValues(q.Queue, "due").Set(float64(q.Due))
+				queueDepth.WithLabelValues(q.Queue, "scheduled").Set(float64(q.Waiting))
+				queueDepth.WithLabelValues(q.Queue, "running").Set(float64(q.Running))
+				queueLag.WithLabelValues(q.Queue).Set(q.OldestDueSeconds)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-m.stopping:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// traceContext captures the W3C trace context of ctx for storing with a job:
+// nil when ctx carries no span, or tracing is off and the propagator is the
+// no-op default.
+func traceContext(ctx context.Context) []byte {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return nil
+	}
+	raw, err := json.Marshal(carrier)
+	if err != nil {
+		return nil
+	}
+	return raw
+}
+
+// withTraceContext makes the span stored with a job the parent of ctx's next
+// span. A malformed or missing context leaves ctx as it is, and the attempt
+// starts a trace of its own.
+func withTraceContext(ctx context.Context, raw []byte) context.Context {
+	if len(raw) == 0 {
+		return ctx
+	}
+	var carrier propagation.MapCarrier
+	if err := json.Unmarshal(raw, &carrier); err != nil {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, carrier)
+}
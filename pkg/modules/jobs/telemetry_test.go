@@ -0,0 +1,66 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	pgxmock "github.com/pashagolub/pgxmock/v4"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The request that enqueues a job and the attempts that run it are one
+// trace: what is stored at enqueue is what the worker continues from.
+func TestTraceContextRoundTrip(t *testing.T) {
+	prev := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTextMapPropagator(prev) })
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		SpanID:     trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+		TraceFlags: trace.FlagsSampled,
+	})
+	raw := traceContext(trace.ContextWithSpanContext(context.Background(), sc))
+	if raw == nil {
+		t.Fatal("no trace context captured from a context with a span")
+	}
+
+	got := trace.SpanContextFromContext(withTraceContext(context.Background(), raw))
+	if got.TraceID() != sc.TraceID() || got.SpanID() != sc.SpanID() || !got.IsRemote() {
+		t.Fatalf("restored span context = %+v, want the enqueuing span %+v", got, sc)
+	}
+
+	if traceContext(context.Background()) != nil {
+		t.Fatal("a context without a span stored a trace context")
+	}
+	if ctx := withTraceContext(context.Background(), []byte(`not json`)); trace.SpanContextFromContext(ctx).IsValid() {
+		t.Fatal("a malformed trace context produced a parent")
+	}
+}
+
+func TestProcessJobCountsOutcome(t *testing.T) {
+	m, mock := testWorker(t)
+	m.HandleFunc("metered", func(context.Context, Job) error { return Permanent(errors.New("bad input")) })
+	job := Job{ID: uuid.New(), Name: "metered", Queue: "reports", Attempts: 1, MaxAttempts: 3, LockedBy: "host:1/reports/0"}
+
+	mock.ExpectExec("UPDATE job_queue\\s+SET status = 'failed'").
+		WithArgs(job.ID, pgxmock.AnyArg(), "host:1/reports/0").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectExec("INSERT INTO job_attempts").
+		WithArgs(job.ID, 1, OutcomeFailed, pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	before := testutil.ToFloat64(jobsProcessed.WithLabelValues("metered", "reports", OutcomeFailed))
+	m.processJob(context.Background(), job, 0)
+	if got := testutil.ToFloat64(jobsProcessed.WithLabelValues("metered", "reports", OutcomeFailed)) - before; got != 1 {
+		t.Fatalf("processed_total{outcome=failed} grew by %v, want 1", got)
+	}
+	if n := testutil.CollectAndCount(jobsDuration, "shanraq_jobs_duration_seconds"); n == 0 {
+		t.Fatal("no duration observed")
+	}
+}
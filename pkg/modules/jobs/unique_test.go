@@ -0,0 +1,159 @@
+package jobs
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	pgxmock "github.com/pashagolub/pgxmock/v4"
+)
+
+var jobColumns = []string{
+	"id", "user_id", "name", "payload", "run_at", "attempts", "max_attempts", "status", "last_error", "created_at", "updated_at", "queue", "priority", "workflow_id",
+}
+
+func TestEnqueueDuplicateReturnsHolder(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("pgxmock: %v", err)
+	}
+	defer mock.Close()
+
+	now := time.Now()
+	holder := uuid.New()
+	mock.ExpectExec(`INSERT INTO job_queue .*unique_key, unique_scope, unique_until.*ON CONFLICT DO NOTHING`).
+		WithArgs(pgxmock.AnyArg(), nil, "ai_translate", pgxmock.AnyArg(), pgxmock.AnyArg(), 3, "article:1", "pending", float64(0), DefaultQueue, 0, nil).
+		WillReturnResult(pgxmock.NewResult("INSERT", 0))
+	mock.ExpectQuery(`FROM job_queue\s+WHERE name = \$1\s+AND unique_key = \$2\s+AND unique_scope = \$3`).
+		WithArgs("ai_translate", "article:1", "pending").
+		WillReturnRows(pgxmock.NewRows(jobColumns).
+			AddRow(holder, nil, "ai_translate", []byte(`{}`), now, 0, 3, "pending", nil, now, now, DefaultQueue, 0, nil))
+
+	job := Job{ID: uuid.New(), Name: "ai_translate", Payload: []byte(`{}`), RunAt: now, MaxAttempts: 3, UniqueKey: "article:1", UniqueScope: ScopePending}
+	got, err := newStoreWithPool(mock).Enqueue(context.Background(), job)
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if got.ID != holder || got.UniqueKey != "article:1" {
+		t.Fatalf("enqueue = %+v, want the holder %s", got, holder)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// The holder finished between the insert that lost to it and the lookup: the
+// key is free, and the next round queues the job.
+func TestEnqueueRetriesWhenHolderFinished(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("pgxmock: %v", err)
+	}
+	defer mock.Close()
+
+	insert := []any{pgxmock.AnyArg(), nil, "sync", pgxmock.AnyArg(), pgxmock.AnyArg(), 1, "k", "active", float64(0), DefaultQueue, 0, nil}
+	mock.ExpectExec(`INSERT INTO job_queue`).WithArgs(insert...).WillReturnResult(pgxmock.NewResult("INSERT", 0))
+	mock.ExpectQuery(`FROM job_queue\s+WHERE name = \$1`).WithArgs("sync", "k", "active").WillReturnError(pgx.ErrNoRows)
+	mock.ExpectExec(`INSERT INTO job_queue`).WithArgs(insert...).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec(`SELECT pg_notify`).WithArgs(notifyChannel, DefaultQueue).WillReturnResult(pgxmock.NewResult("SELECT", 1))
+
+	job := Job{ID: uuid.New(), Name: "sync", Payload: []byte(`{}`), RunAt: time.Now(), MaxAttempts: 1, UniqueKey: "k"}
+	got, err := newStoreWithPool(mock).Enqueue(context.Background(), job)
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if got.ID != job.ID || got.UniqueScope != ScopeActive {
+		t.Fatalf("enqueue = %+v, want the new job with the default scope", got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestEnqueueWindowReleasesExpiredKey(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("pgxmock: %v", err)
+	}
+	defer mock.Close()
+
+	mock.ExpectExec(`UPDATE job_queue\s+SET unique_key = NULL.*unique_until <= NOW\(\)`).
+		WithArgs("digest", "user:1").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectExec(`INSERT INTO job_queue`).
+		WithArgs(pgxmock.AnyArg(), nil, "digest", pgxmock.AnyArg(), pgxmock.AnyArg(), 1, "user:1", "window", float64(86400), DefaultQueue, 0, nil).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec(`SELECT pg_notify`).WithArgs(notifyChannel, DefaultQueue).WillReturnResult(pgxmock.NewResult("SELECT", 1))
+
+	store := newStoreWithPool(mock)
+	job := Job{ID: uuid.New(), Name: "digest", Payload: []byte(`{}`), RunAt: time.Now(), MaxAttempts: 1, UniqueKey: "user:1", UniqueScope: ScopeWindow, UniqueFor: 24 * time.Hour}
+	if _, err := store.Enqueue(context.Background(), job); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	job.UniqueFor = 0
+	if _, err := store.Enqueue(context.Background(), job); err == nil {
+		t.Fatal("a window key without UniqueFor was accepted")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// Replaying a dead letter whose key a live job holds would fail the whole
+// statement on the unique index; the replay leaves it out instead.
+func TestReplaySkipsHeldKeys(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("pgxmock: %v", err)
+	}
+	defer mock.Close()
+
+	mock.ExpectExec(`WHERE status::text = ANY\(\$1\) AND status = \$2 AND \(unique_key IS NULL OR unique_scope = 'window' OR`).
+		WithArgs(replayable, "failed").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 2))
+	mock.ExpectExec(`SELECT pg_notify`).WithArgs(notifyChannel, "").WillReturnResult(pgxmock.NewResult("SELECT", 1))
+
+	if _, err := newStoreWithPool(mock).Replay(context.Background(), ListOptions{Status: "failed"}); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestRetryHeldKeyIsConflict(t *testing.T) {
+	m, mock := testWorker(t)
+	id := uuid.New()
+	mock.ExpectExec(`UPDATE job_queue\s+SET status = CASE WHEN EXISTS`).
+		WithArgs(id).
+		WillReturnError(&pgconn.PgError{Code: "23505", ConstraintName: "job_queue_unique_pending_idx"})
+
+	rec := httptest.NewRecorder()
+	mount(m).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/jobs/"+id.String()+"/retry", nil))
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("status = %d (%s)", rec.Code, rec.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestEnqueueWindowNeedsDuration(t *testing.T) {
+	m, mock := testWorker(t)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/jobs", strings.NewReader(`{"name":"digest","unique_key":"k","unique_scope":"window"}`))
+	req.Header.Set("Content-Type", "application/json")
+	mount(m).ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "unique_for") {
+		t.Fatalf("status = %d (%s)", rec.Code, rec.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
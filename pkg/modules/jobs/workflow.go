@@ -0,0 +1,544 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.uber.org/zap"
+	"shanraq.org/pkg/transport/respond"
+)
+
+// AfterFailure is what a blocked job does when a job it waits for fails or
+// is cancelled.
+type AfterFailure string
+
+const (
+	// FailAfterFailure fails the job too, and so everything waiting on it:
+	// the chain stops. Replaying the failed jobs puts it back, each waiting
+	// for the one before again. It is the default.
+	FailAfterFailure AfterFailure = "fail"
+	// SkipAfterFailure cancels the job instead, for work that is pointless
+	// without its input but not an error in its own right.
+	SkipAfterFailure AfterFailure = "skip"
+	// RunAfterFailure runs the job anyway once the others have finished, one
+	// way or the other: the dependency was about order, not input.
+	RunAfterFailure AfterFailure = "run"
+)
+
+// ErrWorkflowNotFound is returned for a workflow that does not exist or is
+// not the caller's.
+var ErrWorkflowNotFound = errors.New("workflow not found")
+
+// Workflow is a group of jobs with dependencies between them, enqueued
+// together. A job runs once every job in its After has finished — "run B
+// after A", or with several, "run C after all of these" — and a job nobody
+// waits for runs as soon as it is due, so fan-out is jobs sharing a
+// dependency and fan-in is a job with several.
+//
+//	wf := jobs.NewWorkflow("publish")
+//	tr := wf.Add(translateJob)
+//	wf.Add(telegramJob, tr)
+//	err := store.EnqueueWorkflow(ctx, wf)
+//
+// A job must be added after the jobs it waits for, which is what keeps a
+// workflow free of cycles. After may also name jobs already queued outside
+// the workflow.
+type Workflow struct {
+	ID        uuid.UUID `json:"id"`
+	UserID    uuid.UUID `json:"user_id,omitempty"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	Jobs      []Job     `json:"jobs"`
+}
+
+// NewWorkflow starts an empty workflow.
+func NewWorkflow(name string) *Workflow {
+	return &Workflow{ID: uuid.New(), Name: name}
+}
+
+// Add puts job in the workflow, waiting for after, and returns it with its ID
+// set, to be named in the After of jobs added later.
+func (w *Workflow) Add(job Job, after ...Job) Job {
+	if job.ID == uuid.Nil {
+		job.ID = uuid.New()
+	}
+	for _, a := range after {
+		job.After = append(job.After, a.ID)
+	}
+	w.Jobs = append(w.Jobs, job)
+	return job
+}
+
+// After is what the jobs enqueued through a context made by WithAfter wait
+// for.
+type After struct {
+	Jobs      []uuid.UUID
+	OnFailure AfterFailure
+}
+
+type afterKey struct{}
+
+// WithAfter makes the jobs enqueued through ctx by code that does not build
+// them itself — durable event deliveries, above all — wait for a.Jobs. It is
+// how a publisher orders the reactions to its event after work it knows is
+// still running.
+func WithAfter(ctx context.Context, a After) context.Context {
+	if len(a.Jobs) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, afterKey{}, a)
+}
+
+func afterFromContext(ctx context.Context) (After, bool) {
+	a, ok := ctx.Value(afterKey{}).(After)
+	return a, ok
+}
+
+// pendingOrBlocked is the status a retried or replayed job goes back to: a
+// job with a dependency that has not succeeded waits for it again.
+const pendingOrBlocked = `CASE WHEN EXISTS (
+			SELECT 1 FROM job_dependencies d
+			JOIN job_queue p ON p.id = d.depends_on
+			WHERE d.job_id = job_queue.id AND p.status <> 'done'
+		) THEN 'blocked'::job_status ELSE 'pending'::job_status END`
+
+// EnqueueWorkflow queues every job of wf in one transaction: none of them
+// runs unless all of them are queued. A job whose unique key another job
+// holds is not queued, and the jobs that would have waited for it wait for
+// the holder. wf.Jobs are updated to the jobs as queued.
+func (s *Store) EnqueueWorkflow(ctx context.Context, wf *Workflow) error {
+	if wf.ID == uuid.Nil {
+		wf.ID = uuid.New()
+	}
+	tx, err := s.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var userID any
+	if wf.UserID != uuid.Nil {
+		userID = wf.UserID
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO job_workflows (id, user_id, name) VALUES ($1, $2, $3)
+	`, wf.ID, userID, wf.Name); err != nil {
+		return fmt.Errorf("create workflow: %w", err)
+	}
+
+	queuedAs := map[uuid.UUID]uuid.UUID{}
+	var deps []uuid.UUID
+	ready := false
+	for i, job := range wf.Jobs {
+		for j, dep := range job.After {
+			if id, ok := queuedAs[dep]; ok {
+				job.After[j] = id
+			}
+		}
+		job.WorkflowID = wf.ID
+		queued, inserted, err := insertJob(ctx, tx, job)
+		if err != nil {
+			return err
+		}
+		queuedAs[job.ID] = queued.ID
+		if inserted {
+			if err := linkJob(ctx, tx, queued.ID, wf.ID, job.AfterFailure, job.After); err != nil {
+				return err
+			}
+			if len(job.After) > 0 {
+				queued.Status = "blocked"
+			} else {
+				ready = true
+			}
+		}
+		queued.After, queued.AfterFailure = job.After, job.AfterFailure
+		deps = append(deps, job.After...)
+		wf.Jobs[i] = queued
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	// Dependencies from outside the workflow may have finished already.
+	if err := s.Advance(ctx, deps); err != nil {
+		return err
+	}
+	if ready {
+		s.notify(ctx, "")
+	}
+	return nil
+}
+
+// enqueueAfter queues a job with dependencies on its own. It joins the
+// workflow of the jobs it waits for, or starts one with them, so every chain
+// shows up in the console.
+func (s *Store) enqueueAfter(ctx context.Context, job Job) (Job, error) {
+	tx, err := s.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return Job{}, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if job.WorkflowID == uuid.Nil {
+		if job.WorkflowID, err = joinWorkflow(ctx, tx, job); err != nil {
+			return Job{}, err
+		}
+	}
+	queued, inserted, err := insertJob(ctx, tx, job)
+	if err != nil {
+		return Job{}, err
+	}
+	if inserted {
+		if err := linkJob(ctx, tx, queued.ID, job.WorkflowID, job.AfterFailure, job.After); err != nil {
+			return Job{}, err
+		}
+		queued.Status = "blocked"
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return Job{}, fmt.Errorf("commit: %w", err)
+	}
+	// The jobs it waits for may have finished already.
+	if err := s.Advance(ctx, job.After); err != nil {
+		return Job{}, err
+	}
+	return queued, nil
+}
+
+// joinWorkflow finds the workflow of the jobs job waits for, or makes one of
+// them, named for the first of them and job.
+func joinWorkflow(ctx context.Context, tx pgx.Tx, job Job) (uuid.UUID, error) {
+	var workflow pgtype.UUID
+	var first string
+	err := tx.QueryRow(ctx, `
+		SELECT workflow_id, name FROM job_queue
+		WHERE id = ANY($1)
+		ORDER BY workflow_id IS NULL, created_at
+		LIMIT 1
+	`, job.After).Scan(&workflow, &first)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return uuid.Nil, fmt.Errorf("enqueue job: a job it waits for: %w", ErrJobNotFound)
+	}
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("find workflow: %w", err)
+	}
+	if workflow.Valid {
+		return workflow.Bytes, nil
+	}
+
+	id := uuid.New()
+	var userID any
+	if job.UserID != uuid.Nil {
+		userID = job.UserID
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO job_workflows (id, user_id, name) VALUES ($1, $2, $3)
+	`, id, userID, first+" → "+job.Name); err != nil {
+		return uuid.Nil, fmt.Errorf("create workflow: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE job_queue SET workflow_id = $1 WHERE id = ANY($2) AND workflow_id IS NULL
+	`, id, job.After); err != nil {
+		return uuid.Nil, fmt.Errorf("join workflow: %w", err)
+	}
+	return id, nil
+}
+
+// linkJob puts a just-inserted job in its workflow and, when it waits for
+// others, blocks it and records what it waits for.
+func linkJob(ctx context.Context, tx querier, id, workflow uuid.UUID, onFailure AfterFailure, after []uuid.UUID) error {
+	switch onFailure {
+	case "":
+		onFailure = FailAfterFailure
+	case FailAfterFailure, SkipAfterFailure, RunAfterFailure:
+	default:
+		return fmt.Errorf("enqueue job: unknown after-failure %q", onFailure)
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE job_queue
+		SET workflow_id = $2,
+		    after_failure = $3,
+		    status = CASE WHEN cardinality($4::uuid[]) > 0 THEN 'blocked'::job_status ELSE status END
+		WHERE id = $1
+	`, id, workflow, string(onFailure), after); err != nil {
+		return fmt.Errorf("link job: %w", err)
+	}
+	if len(after) == 0 {
+		return nil
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO job_dependencies (job_id, depends_on)
+		SELECT $1, unnest($2::uuid[])
+		ON CONFLICT DO NOTHING
+	`, id, after); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return fmt.Errorf("enqueue job: a job it waits for: %w", ErrJobNotFound)
+		}
+		return fmt.Errorf("record dependencies: %w", err)
+	}
+	return nil
+}
+
+// maxCascade bounds how many levels of a chain one Advance fails or skips.
+// Chains are a few jobs long; the bound is there so a bad graph costs a few
+// statements, not a loop. Whatever is left the next sweep picks up.
+const maxCascade = 32
+
+// Advance moves blocked jobs on once the jobs they wait for have finished.
+// Those waiting on a failed or cancelled job are failed or skipped as their
+// AfterFailure says, and so on down the chain; the rest whose dependencies
+// have all finished become pending, and their workers are woken.
+//
+// from limits it to the jobs waiting on those, which is what a worker does
+// when it finishes a job in a workflow. nil sweeps every blocked job, which
+// the reaper does every tick: a cancelled dependency, a reaped one, or a
+// worker that died between finishing a job and advancing its workflow leaves
+// blocked jobs only that long.
+func (s *Store) Advance(ctx context.Context, from []uuid.UUID) error {
+	if from != nil && len(from) == 0 {
+		return nil
+	}
+	scope := from
+	for range maxCascade {
+		rows, err := s.db.Query(ctx, `
+			UPDATE job_queue w
+			SET status = CASE w.after_failure WHEN 'skip' THEN 'cancelled'::job_status ELSE 'failed'::job_status END,
+			    last_error = CASE w.after_failure WHEN 'skip' THEN 'skipped: ' ELSE '' END
+			        || 'dependency ' || p.name || ' ' || p.id::text || ' ' || p.status::text,
+			    updated_at = NOW()
+			FROM (
+				SELECT DISTINCT ON (d.job_id) d.job_id, q.id, q.name, q.status
+				FROM job_dependencies d
+				JOIN job_queue q ON q.id = d.depends_on
+				WHERE q.status IN ('failed', 'cancelled')
+				ORDER BY d.job_id, q.updated_at
+			) p
+			WHERE w.id = p.job_id
+			  AND w.status = 'blocked'
+			  AND w.after_failure <> 'run'
+			  AND ($1::uuid[] IS NULL OR w.id IN (SELECT job_id FROM job_dependencies WHERE depends_on = ANY($1)))
+			RETURNING w.id
+		`, scope)
+		if err != nil {
+			return fmt.Errorf("fail blocked jobs: %w", err)
+		}
+		var failed []uuid.UUID
+		for rows.Next() {
+			var id uuid.UUID
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return fmt.Errorf("scan blocked job: %w", err)
+			}
+			failed = append(failed, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("fail blocked jobs: %w", err)
+		}
+		if len(failed) == 0 {
+			break
+		}
+		if scope != nil {
+			scope = append(scope[:len(scope):len(scope)], failed...)
+		}
+	}
+
+	rows, err := s.db.Query(ctx, `
+		UPDATE job_queue w
+		SET status = 'pending',
+		    run_at = GREATEST(w.run_at, NOW()),
+		    updated_at = NOW()
+		WHERE w.status = 'blocked'
+		  AND ($1::uuid[] IS NULL OR w.id IN (SELECT job_id FROM job_dependencies WHERE depends_on = ANY($1)))
+		  AND NOT EXISTS (
+			SELECT 1 FROM job_dependencies d
+			JOIN job_queue p ON p.id = d.depends_on
+			WHERE d.job_id = w.id
+			  AND p.status <> 'done'
+			  AND NOT (w.after_failure = 'run' AND p.status IN ('failed', 'cancelled')))
+		RETURNING w.queue
+	`, scope)
+	if err != nil {
+		return fmt.Errorf("release blocked jobs: %w", err)
+	}
+	queues := map[string]bool{}
+	for rows.Next() {
+		var queue string
+		if err := rows.Scan(&queue); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan released job: %w", err)
+		}
+		queues[queue] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("release blocked jobs: %w", err)
+	}
+	for queue := range queues {
+		s.notify(ctx, queue)
+	}
+	return nil
+}
+
+// WorkflowSummary is one row of the console's workflow list.
+type WorkflowSummary struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	Jobs      int       `json:"jobs"`
+	Done      int       `json:"done"`
+	// Failed counts failed and cancelled jobs, skipped ones included.
+	Failed int `json:"failed"`
+	// Active counts the jobs still to finish, blocked ones included.
+	Active int `json:"active"`
+}
+
+// ListWorkflows returns the most recent workflows, newest first.
+func (s *Store) ListWorkflows(ctx context.Context, limit int, userID *uuid.UUID) ([]WorkflowSummary, error) {
+	if limit <= 0 || limit > 200 {
+		limit = 20
+	}
+	query := `
+		SELECT w.id, w.name, w.created_at,
+		       COUNT(q.id),
+		       COUNT(q.id) FILTER (WHERE q.status = 'done'),
+		       COUNT(q.id) FILTER (WHERE q.status IN ('failed', 'cancelled')),
+		       COUNT(q.id) FILTER (WHERE q.status IN ('pending', 'retry', 'running', 'blocked'))
+		FROM job_workflows w
+		LEFT JOIN job_queue q ON q.workflow_id = w.id
+	`
+	args := []any{limit}
+	if userID != nil && *userID != uuid.Nil {
+		query += " WHERE w.user_id = $2"
+		args = append(args, *userID)
+	}
+	query += " GROUP BY w.id ORDER BY w.created_at DESC LIMIT $1"
+
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list workflows: %w", err)
+	}
+	defer rows.Close()
+
+	var out []WorkflowSummary
+	for rows.Next() {
+		var w WorkflowSummary
+		if err := rows.Scan(&w.ID, &w.Name, &w.CreatedAt, &w.Jobs, &w.Done, &w.Failed, &w.Active); err != nil {
+			return nil, fmt.Errorf("scan workflow: %w", err)
+		}
+		out = append(out, w)
+	}
+	return out, rows.Err()
+}
+
+// GetWorkflow returns a workflow with its jobs, each with the jobs it waits
+// for in After: the graph the console draws.
+func (s *Store) GetWorkflow(ctx context.Context, id uuid.UUID, userID *uuid.UUID) (Workflow, error) {
+	query := `SELECT id, user_id, name, created_at FROM job_workflows WHERE id = $1`
+	args := []any{id}
+	if userID != nil && *userID != uuid.Nil {
+		query += " AND user_id = $2"
+		args = append(args, *userID)
+	}
+	var wf Workflow
+	var user pgtype.UUID
+	err := s.db.QueryRow(ctx, query, args...).Scan(&wf.ID, &user, &wf.Name, &wf.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Workflow{}, ErrWorkflowNotFound
+	}
+	if err != nil {
+		return Workflow{}, fmt.Errorf("get workflow: %w", err)
+	}
+	if user.Valid {
+		wf.UserID = user.Bytes
+	}
+
+	rows, err := s.db.Query(ctx, `
+		SELECT `+jobFields+`, after_failure,
+		       ARRAY(SELECT depends_on::text FROM job_dependencies d WHERE d.job_id = job_queue.id ORDER BY depends_on)
+		FROM job_queue
+		WHERE workflow_id = $1
+		ORDER BY created_at, id
+	`, id)
+	if err != nil {
+		return Workflow{}, fmt.Errorf("workflow jobs: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var onFailure string
+		var after []string
+		job, err := scanJob(rows, &onFailure, &after)
+		if err != nil {
+			return Workflow{}, err
+		}
+		job.AfterFailure = AfterFailure(onFailure)
+		for _, a := range after {
+			if dep, err := uuid.Parse(a); err == nil {
+				job.After = append(job.After, dep)
+			}
+		}
+		wf.Jobs = append(wf.Jobs, job)
+	}
+	return wf, rows.Err()
+}
+
+// advance moves on the workflow of a job that has just finished. A failure
+// here is left to the reaper's sweep.
+func (m *Module) advance(ctx context.Context, job Job) {
+	if job.WorkflowID == uuid.Nil {
+		return
+	}
+	if err := m.store.Advance(ctx, []uuid.UUID{job.ID}); err != nil {
+		m.rt.Logger.Warn("advance workflow", zap.String("job_id", job.ID.String()), zap.Error(err))
+	}
+}
+
+func (m *Module) handleWorkflows(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	var tenantPtr *uuid.UUID
+	if tenantID, ok := m.resolveTenant(r); ok {
+		tenantPtr = &tenantID
+	}
+	list, err := m.store.ListWorkflows(r.Context(), limit, tenantPtr)
+	if err != nil {
+		respond.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	if list == nil {
+		list = []WorkflowSummary{}
+	}
+	respond.JSON(w, http.StatusOK, list)
+}
+
+func (m *Module) handleWorkflow(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respond.Error(w, http.StatusBadRequest, errors.New("invalid workflow id"))
+		return
+	}
+	var tenantPtr *uuid.UUID
+	if tenantID, ok := m.resolveTenant(r); ok {
+		tenantPtr = &tenantID
+	}
+	wf, err := m.store.GetWorkflow(r.Context(), id, tenantPtr)
+	if errors.Is(err, ErrWorkflowNotFound) {
+		respond.Error(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		respond.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	if wf.Jobs == nil {
+		wf.Jobs = []Job{}
+	}
+	respond.JSON(w, http.StatusOK, wf)
+}
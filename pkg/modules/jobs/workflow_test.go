@@ -0,0 +1,153 @@
+package jobs
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	pgxmock "github.com/pashagolub/pgxmock/v4"
+)
+
+func TestWorkflowAddWaitsForEarlierJobs(t *testing.T) {
+	wf := NewWorkflow("publish")
+	a := wf.Add(Job{Name: "ai_translate"})
+	b := wf.Add(Job{Name: "render"})
+	c := wf.Add(Job{Name: "telegram"}, a, b)
+
+	if a.ID == uuid.Nil || b.ID == uuid.Nil || c.ID == uuid.Nil {
+		t.Fatalf("Add left a job without an id: %v %v %v", a.ID, b.ID, c.ID)
+	}
+	if len(c.After) != 2 || c.After[0] != a.ID || c.After[1] != b.ID {
+		t.Fatalf("c.After = %v, want [%s %s]", c.After, a.ID, b.ID)
+	}
+	if len(wf.Jobs) != 3 || len(wf.Jobs[0].After) != 0 {
+		t.Fatalf("workflow jobs = %+v", wf.Jobs)
+	}
+}
+
+// A finished job in a workflow releases what waited for it, and wakes the
+// queue the released job is in, without waiting for the reaper's sweep.
+func TestProcessJobAdvancesItsWorkflow(t *testing.T) {
+	m, mock := testWorker(t)
+	m.HandleFunc("ai_translate", func(context.Context, Job) error { return nil })
+	job := Job{ID: uuid.New(), Name: "ai_translate", Attempts: 1, MaxAttempts: 3, WorkflowID: uuid.New(), LockedBy: "host:1/ai/0"}
+
+	mock.ExpectExec("UPDATE job_queue\\s+SET status = 'done'").
+		WithArgs(job.ID, "host:1/ai/0").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectExec("INSERT INTO job_attempts").
+		WithArgs(job.ID, 1, OutcomeDone, pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectQuery(`UPDATE job_queue w\s+SET status = CASE w.after_failure`).
+		WithArgs([]uuid.UUID{job.ID}).
+		WillReturnRows(pgxmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`UPDATE job_queue w\s+SET status = 'pending'`).
+		WithArgs([]uuid.UUID{job.ID}).
+		WillReturnRows(pgxmock.NewRows([]string{"queue"}).AddRow(DefaultQueue))
+	mock.ExpectExec(`SELECT pg_notify`).WithArgs(notifyChannel, DefaultQueue).WillReturnResult(pgxmock.NewResult("SELECT", 1))
+
+	m.processJob(context.Background(), job, 0)
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// A failure goes down the chain one level per statement: what waited for
+// the job fails, then what waited for that.
+func TestAdvanceCascadesDownTheChain(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("pgxmock: %v", err)
+	}
+	defer mock.Close()
+
+	a, b := uuid.New(), uuid.New()
+	mock.ExpectQuery(`SET status = CASE w.after_failure WHEN 'skip'.*AND w.after_failure <> 'run'`).
+		WithArgs([]uuid.UUID{a}).
+		WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(b))
+	mock.ExpectQuery(`SET status = CASE w.after_failure WHEN 'skip'`).
+		WithArgs([]uuid.UUID{a, b}).
+		WillReturnRows(pgxmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`SET status = 'pending'`).
+		WithArgs([]uuid.UUID{a, b}).
+		WillReturnRows(pgxmock.NewRows([]string{"queue"}))
+
+	if err := newStoreWithPool(mock).Advance(context.Background(), []uuid.UUID{a}); err != nil {
+		t.Fatalf("advance: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// A job enqueued after one that is in no workflow starts one with it, so the
+// pair shows up in the console.
+func TestEnqueueAfterStartsWorkflow(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("pgxmock: %v", err)
+	}
+	defer mock.Close()
+
+	dep := uuid.New()
+	job := Job{ID: uuid.New(), Name: "telegram", Payload: []byte(`{}`), RunAt: time.Now(), MaxAttempts: 5, After: []uuid.UUID{dep}}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT workflow_id, name FROM job_queue`).
+		WithArgs([]uuid.UUID{dep}).
+		WillReturnRows(pgxmock.NewRows([]string{"workflow_id", "name"}).AddRow(nil, "ai_translate"))
+	mock.ExpectExec(`INSERT INTO job_workflows`).
+		WithArgs(pgxmock.AnyArg(), nil, "ai_translate → telegram").
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec(`UPDATE job_queue SET workflow_id = \$1 WHERE id = ANY\(\$2\)`).
+		WithArgs(pgxmock.AnyArg(), []uuid.UUID{dep}).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectExec(`INSERT INTO job_queue`).
+		WithArgs(job.ID, nil, "telegram", pgxmock.AnyArg(), pgxmock.AnyArg(), 5, DefaultQueue, 0, nil).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec(`SET workflow_id = \$2,\s+after_failure = \$3`).
+		WithArgs(job.ID, pgxmock.AnyArg(), "fail", []uuid.UUID{dep}).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectExec(`INSERT INTO job_dependencies`).
+		WithArgs(job.ID, []uuid.UUID{dep}).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SET status = CASE w.after_failure`).
+		WithArgs([]uuid.UUID{dep}).
+		WillReturnRows(pgxmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`SET status = 'pending'`).
+		WithArgs([]uuid.UUID{dep}).
+		WillReturnRows(pgxmock.NewRows([]string{"queue"}))
+
+	got, err := newStoreWithPool(mock).Enqueue(context.Background(), job)
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if got.Status != "blocked" || got.WorkflowID == uuid.Nil {
+		t.Fatalf("enqueue = %+v, want a blocked job in a new workflow", got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestHandleWorkflowNotFound(t *testing.T) {
+	m, mock := testWorker(t)
+	id := uuid.New()
+	mock.ExpectQuery(`SELECT id, user_id, name, created_at FROM job_workflows WHERE id = \$1`).
+		WithArgs(id).
+		WillReturnError(pgx.ErrNoRows)
+
+	rec := httptest.NewRecorder()
+	mount(m).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs/workflows/"+id.String(), nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404 (%s)", rec.Code, rec.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"shanraq.org/pkg/shanraq"
+)
+
+// healthProbeKey is written and removed by the health check. Outside the
+// content-hash namespace, so it can never collide with a real upload, and
+// not in the ledger, so the orphan sweep never sees it.
+const healthProbeKey = ".health/probe"
+
+// headroomWarn is the share of MaxTotalBytes at which the store reports itself
+// degraded: early enough to add disk before uploads start failing.
+const headroomWarn = 0.9
+
+// HealthChecks reports whether the store accepts writes and how close it is
+// to the configured ceiling.
+func (m *Module) HealthChecks() []shanraq.HealthCheck {
+	return []shanraq.HealthCheck{{
+		Name: "media",
+		Run: func(ctx context.Context) (string, error) {
+			if m.store == nil {
+				return "", errors.New("media store uninitialized")
+			}
+			if err := m.store.Put(ctx, healthProbeKey, []byte("ok"), "text/plain"); err != nil {
+				return "", fmt.Errorf("store not writable: %w", err)
+			}
+			_ = m.store.Delete(ctx, healthProbeKey)
+
+			limit := m.cfg.MaxTotalBytes
+			if m.ledger == nil || limit <= 0 {
+				return "writable", nil
+			}
+			used, err := m.ledger.total(ctx)
+			if err != nil {
+				return "writable", err
+			}
+			detail := fmt.Sprintf("writable · %d of %d MiB", used>>20, limit>>20)
+			if float64(used) >= headroomWarn*float64(limit) {
+				return detail, fmt.Errorf("store at %d%% of media.max_total_bytes", used*100/limit)
+			}
+			return detail, nil
+		},
+	}}
+}
@@ -5,11 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"strings"
-	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"go.uber.org/zap"
+	"shanraq.org/pkg/modules/jobs"
 	"shanraq.org/pkg/shanraq"
 )
 
@@ -217,37 +217,28 @@ func (l *ledger) forget(ctx context.Context, keys []string) error {
 	return nil
 }
 
-// sweepInterval is how often the orphan sweep runs. Litter is not urgent; the
-// point is that it is collected at all, not that it is collected promptly.
-const sweepInterval = 6 * time.Hour
+// JobSweep is the scheduled job that collects orphaned uploads.
+const JobSweep = "media_sweep"
+
+// sweepSpec runs the sweep four times a day. Litter is not urgent; the point
+// is that it is collected at all, not that it is collected promptly.
+const sweepSpec = "30 */6 * * *"
 
 // orphanBatch bounds one sweep. A first run on a store that has never been
 // swept should not turn into a single enormous transaction.
 const orphanBatch = 500
 
-// Start runs the orphan sweep in the background. Media is the one module whose
-// storage grows from user action and shrinks from nothing: an upload that never
-// made it into a saved listing is invisible to every screen on the site and
-// still occupies the disk forever.
-func (m *Module) Start(ctx context.Context, _ *shanraq.Runtime) error {
-	if m.ledger == nil {
-		return nil
-	}
-	go m.sweepLoop(ctx)
-	return nil
-}
-
-func (m *Module) sweepLoop(ctx context.Context) {
-	t := time.NewTicker(sweepInterval)
-	defer t.Stop()
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-t.C:
-			m.sweepOrphans(ctx)
+// RegisterJobs schedules the orphan sweep on the queue. Media is the one
+// module whose storage grows from user action and shrinks from nothing: an
+// upload that never made it into a saved listing is invisible to every screen
+// on the site and still occupies the disk forever.
+func (m *Module) RegisterJobs(j *jobs.Module) {
+	j.Schedule(JobSweep, sweepSpec, func(ctx context.Context, _ *shanraq.Runtime, _ jobs.Job) error {
+		if m.ledger == nil {
+			return nil
 		}
-	}
+		return m.sweepOrphans(ctx)
+	})
 }
 
 // sweepOrphans deletes stored files nothing refers to any more, oldest first.
@@ -255,18 +246,17 @@ func (m *Module) sweepLoop(ctx context.Context) {
 // It deliberately never deletes a file it cannot account for: only objects in
 // the ledger are candidates, so anything uploaded before this existed — or
 // written by hand into the media directory — is left where it is.
-func (m *Module) sweepOrphans(ctx context.Context) {
+func (m *Module) sweepOrphans(ctx context.Context) error {
 	grace := m.cfg.OrphanGraceHours
 	if grace <= 0 {
-		return // sweeping disabled
+		return nil // sweeping disabled
 	}
 	found, err := m.ledger.orphans(ctx, m.cfg.PublicPrefix, grace, orphanBatch)
 	if err != nil {
-		m.logger.Warn("media sweep", zap.Error(err))
-		return
+		return fmt.Errorf("media sweep: %w", err)
 	}
 	if len(found) == 0 {
-		return
+		return nil
 	}
 	keys := make([]string, 0, len(found))
 	var freed int64
@@ -281,9 +271,9 @@ func (m *Module) sweepOrphans(ctx context.Context) {
 		freed += o.bytes
 	}
 	if err := m.ledger.forget(ctx, keys); err != nil {
-		m.logger.Warn("media sweep forget", zap.Error(err))
-		return
+		return fmt.Errorf("media sweep forget: %w", err)
 	}
 	m.logger.Info("media sweep collected unreferenced uploads",
 		zap.Int("files", len(keys)), zap.Int64("bytes", freed))
+	return nil
 }
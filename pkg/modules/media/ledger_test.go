@@ -167,7 +167,9 @@ func TestSweepCollectsOnlyWhatNothingRefers(t *testing.T) {
 	}
 	t.Cleanup(func() { _, _ = pool.Exec(ctx, `DELETE FROM content_pages WHERE page_key=$1`, pageKey) })
 
-	m.sweepOrphans(ctx)
+	if err := m.sweepOrphans(ctx); err != nil {
+		t.Fatalf("sweep: %v", err)
+	}
 
 	gone := func(key string) bool {
 		_, err := os.Stat(dir + "/" + key)
@@ -40,6 +40,9 @@ func New(authModule *auth.Module) *Module { return &Module{auth: authModule} }
 
 func (m *Module) Name() string { return "media" }
 
+// DependsOn names auth, whose session check guards the upload endpoint.
+func (m *Module) DependsOn() []string { return []string{"auth"} }
+
 // Init selects the storage backend and rasterizes the brand watermark once.
 func (m *Module) Init(_ context.Context, rt *shanraq.Runtime) error {
 	m.cfg = rt.Config.Media
@@ -385,5 +388,6 @@ var _ interface {
 	shanraq.Module
 	shanraq.RouterModule
 	shanraq.InitializerModule
-	shanraq.StarterModule
+	shanraq.DependentModule
+	shanraq.HealthChecker
 } = (*Module)(nil)
@@ -0,0 +1,42 @@
+-- +goose Up
+-- Recurring work moves onto the queue.
+--
+-- The sweeps — listing reminders and purges, lapsed ad-slot holds, the end of a
+-- maintenance window, orphaned uploads, the weekly digest — each ran in a
+-- goroutine of its own with a ticker compiled in. Two instances meant two of
+-- everything, and the digest, which guarded itself with digest_state, was the
+-- only one that noticed. None of them showed up in the console.
+--
+-- A row per schedule is what makes one instance fire it: the scheduler locks
+-- the due rows with SKIP LOCKED, enqueues the job and moves next_run_at in the
+-- same transaction, so the instance that comes second finds nothing due.
+--
+-- spec is what fires; default_spec is what the code registered. While they are
+-- equal a deploy may change both. Once an operator has set spec from the
+-- console, their schedule stands until they reset it.
+CREATE TABLE IF NOT EXISTS job_schedules (
+    name TEXT PRIMARY KEY,
+    spec TEXT NOT NULL,
+    default_spec TEXT NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    next_run_at TIMESTAMPTZ NOT NULL,
+    last_run_at TIMESTAMPTZ,
+    last_job_id UUID,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS job_schedules_due_idx ON job_schedules (next_run_at) WHERE enabled;
+
+-- The digest's own record of its last send. Its schedule keeps that now, and
+-- an instance still on the old code that finds the table gone reads "not due"
+-- and sends nothing.
+DROP TABLE IF EXISTS digest_state;
+
+-- +goose Down
+CREATE TABLE IF NOT EXISTS digest_state (
+    id INT PRIMARY KEY DEFAULT 1,
+    last_sent_at TIMESTAMPTZ,
+    CONSTRAINT digest_state_singleton CHECK (id = 1)
+);
+INSERT INTO digest_state (id, last_sent_at) VALUES (1, NULL) ON CONFLICT (id) DO NOTHING;
+DROP TABLE IF EXISTS job_schedules;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- A job an operator cancelled was written as 'failed', next to the jobs that
+-- ran out of attempts, with the reason in last_error as the only difference.
+-- After an outage the failed list is what gets replayed, and replaying it
+-- brought the cancelled ones back too. The value is added on its own: a new
+-- enum label cannot be used in the transaction that adds it.
+ALTER TYPE job_status ADD VALUE IF NOT EXISTS 'cancelled';
+
+-- +goose Down
+-- Postgres cannot drop an enum label. The next migration's Down turns
+-- cancelled jobs back into failed ones, which leaves the label unused.
+SELECT 1;
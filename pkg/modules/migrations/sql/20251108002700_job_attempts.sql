@@ -0,0 +1,30 @@
+-- +goose Up
+-- Every attempt at a job, not only the last error.
+--
+-- A job that failed five times kept the fifth error and nothing else; whether
+-- the first four were the same timeout or five different faults, and how long
+-- each ran, was gone. That is the question after an outage — which of these
+-- are worth replaying — and the queue could not answer it.
+CREATE TABLE IF NOT EXISTS job_attempts (
+    id BIGSERIAL PRIMARY KEY,
+    job_id UUID NOT NULL REFERENCES job_queue(id) ON DELETE CASCADE,
+    attempt INT NOT NULL,
+    outcome TEXT NOT NULL,
+    error TEXT,
+    started_at TIMESTAMPTZ NOT NULL,
+    duration_ms BIGINT NOT NULL,
+    CONSTRAINT job_attempts_outcome_chk CHECK (outcome IN ('done', 'retry', 'failed'))
+);
+
+CREATE INDEX IF NOT EXISTS job_attempts_job_idx ON job_attempts (job_id, started_at);
+CREATE INDEX IF NOT EXISTS job_queue_name_status_idx ON job_queue (name, status, updated_at);
+
+-- The console is the one caller whose cancellations can be told apart after
+-- the fact; jobs cancelled through the API with another reason stay failed.
+UPDATE job_queue SET status = 'cancelled'
+WHERE status = 'failed' AND last_error = 'Cancelled via console';
+
+-- +goose Down
+UPDATE job_queue SET status = 'failed' WHERE status = 'cancelled';
+DROP INDEX IF EXISTS job_queue_name_status_idx;
+DROP TABLE IF EXISTS job_attempts;
@@ -0,0 +1,43 @@
+-- +goose Up
+-- Unique keys for jobs that must not be queued twice.
+--
+-- Every enqueue was a new row. A double click on "translate" paid the model
+-- twice; a listing edited three times in a minute was screened three times; an
+-- article published, unpublished and published again was announced on Telegram
+-- twice. A key is unique per job name and scope, and each scope is a partial
+-- index over the rows that still hold it:
+--
+--   pending  until a worker picks the job up
+--   active   until the job finishes, whatever the outcome
+--   window   until unique_until; the enqueuer clears expired keys itself,
+--            since an index predicate cannot mention NOW()
+ALTER TABLE job_queue
+    ADD COLUMN IF NOT EXISTS unique_key TEXT,
+    ADD COLUMN IF NOT EXISTS unique_scope TEXT,
+    ADD COLUMN IF NOT EXISTS unique_until TIMESTAMPTZ;
+
+ALTER TABLE job_queue
+    ADD CONSTRAINT job_queue_unique_scope_chk
+    CHECK (unique_scope IS NULL OR unique_scope IN ('pending', 'active', 'window'));
+
+CREATE UNIQUE INDEX IF NOT EXISTS job_queue_unique_pending_idx
+    ON job_queue (name, unique_key)
+    WHERE unique_scope = 'pending' AND status IN ('pending', 'retry');
+
+CREATE UNIQUE INDEX IF NOT EXISTS job_queue_unique_active_idx
+    ON job_queue (name, unique_key)
+    WHERE unique_scope = 'active' AND status IN ('pending', 'retry', 'running');
+
+CREATE UNIQUE INDEX IF NOT EXISTS job_queue_unique_window_idx
+    ON job_queue (name, unique_key)
+    WHERE unique_scope = 'window';
+
+-- +goose Down
+DROP INDEX IF EXISTS job_queue_unique_window_idx;
+DROP INDEX IF EXISTS job_queue_unique_active_idx;
+DROP INDEX IF EXISTS job_queue_unique_pending_idx;
+ALTER TABLE job_queue DROP CONSTRAINT IF EXISTS job_queue_unique_scope_chk;
+ALTER TABLE job_queue
+    DROP COLUMN IF EXISTS unique_until,
+    DROP COLUMN IF EXISTS unique_scope,
+    DROP COLUMN IF EXISTS unique_key;
@@ -0,0 +1,27 @@
+-- +goose Up
+-- Leases on running jobs.
+--
+-- A claim set status = 'running' and nothing ever looked at the row again. A
+-- worker killed mid-job — a deploy past its drain deadline, the OOM killer, a
+-- lost host — left its job running for good, where no retry and no replay
+-- would touch it. A claim now holds a lease: locked_by names the worker,
+-- locked_until is when it expires unless the worker renews it, and a reaper
+-- sends jobs with expired leases back to the queue.
+ALTER TABLE job_queue
+    ADD COLUMN IF NOT EXISTS locked_by TEXT,
+    ADD COLUMN IF NOT EXISTS locked_until TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS job_queue_lease_idx
+    ON job_queue (locked_until)
+    WHERE status = 'running';
+
+-- Jobs already running were claimed without a lease. Give them an hour from
+-- their claim: anything older than that is one of the ones left behind.
+UPDATE job_queue SET locked_until = updated_at + INTERVAL '1 hour'
+WHERE status = 'running';
+
+-- +goose Down
+DROP INDEX IF EXISTS job_queue_lease_idx;
+ALTER TABLE job_queue
+    DROP COLUMN IF EXISTS locked_until,
+    DROP COLUMN IF EXISTS locked_by;
@@ -0,0 +1,22 @@
+-- +goose Up
+-- Named queues and priorities.
+--
+-- Every job shared one pool of workers in run_at order, so a burst of slow
+-- jobs held up everything behind it. A job now names its queue, which a
+-- worker pool of its own can serve, and a priority that orders the due jobs
+-- of a queue before run_at does. Existing jobs land in the default queue at
+-- priority zero, which is where and how they ran before.
+ALTER TABLE job_queue
+    ADD COLUMN IF NOT EXISTS queue TEXT NOT NULL DEFAULT 'default',
+    ADD COLUMN IF NOT EXISTS priority INT NOT NULL DEFAULT 0;
+
+-- The claim: due jobs of a queue, highest priority first, oldest among equals.
+CREATE INDEX IF NOT EXISTS job_queue_claim_idx
+    ON job_queue (queue, priority DESC, run_at)
+    WHERE status IN ('pending', 'retry');
+
+-- +goose Down
+DROP INDEX IF EXISTS job_queue_claim_idx;
+ALTER TABLE job_queue
+    DROP COLUMN IF EXISTS priority,
+    DROP COLUMN IF EXISTS queue;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- Progress and results reported by running jobs.
+--
+-- A job could say nothing but that it succeeded or the text of its error, so
+-- the studio guessed at a translation's state from the article rows it had
+-- written so far. Handlers now report progress while they run and a result
+-- when they have one; both are cleared when an attempt starts.
+ALTER TABLE job_queue
+    ADD COLUMN IF NOT EXISTS progress JSONB,
+    ADD COLUMN IF NOT EXISTS result JSONB;
+
+-- +goose Down
+ALTER TABLE job_queue
+    DROP COLUMN IF EXISTS result,
+    DROP COLUMN IF EXISTS progress;
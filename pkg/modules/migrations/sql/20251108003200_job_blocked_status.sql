@@ -0,0 +1,10 @@
+-- +goose Up
+-- A job waiting on other jobs is 'blocked' until they finish. The value is
+-- added on its own: a new enum label cannot be used in the transaction that
+-- adds it, and the next migration's indexes name it.
+ALTER TYPE job_status ADD VALUE IF NOT EXISTS 'blocked';
+
+-- +goose Down
+-- Postgres cannot drop an enum label. The next migration's Down releases the
+-- blocked jobs, which leaves the label unused.
+SELECT 1;
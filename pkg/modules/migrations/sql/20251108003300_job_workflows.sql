@@ -0,0 +1,68 @@
+-- +goose Up
+-- Dependencies between jobs, and the workflows they make.
+--
+-- Every job ran as soon as it was due, so work that only makes sense after
+-- other work had no way to wait: a Telegram post went out before the
+-- translations it links to existed. A job can now depend on others. It is
+-- 'blocked' until they have all finished, then becomes pending — or, when one
+-- of them failed, is failed or skipped in turn as its after_failure says
+-- ('fail', 'skip', or 'run' to go ahead anyway). Jobs linked this way share a
+-- workflow, which the console draws as a graph.
+CREATE TABLE IF NOT EXISTS job_workflows (
+    id UUID PRIMARY KEY,
+    user_id UUID REFERENCES auth_users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS job_workflows_created_idx ON job_workflows (created_at DESC);
+
+ALTER TABLE job_queue
+    ADD COLUMN IF NOT EXISTS workflow_id UUID REFERENCES job_workflows(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS after_failure TEXT NOT NULL DEFAULT 'fail';
+
+ALTER TABLE job_queue
+    ADD CONSTRAINT job_queue_after_failure_chk
+    CHECK (after_failure IN ('fail', 'skip', 'run'));
+
+CREATE INDEX IF NOT EXISTS job_queue_workflow_idx ON job_queue (workflow_id) WHERE workflow_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS job_queue_blocked_idx ON job_queue (id) WHERE status = 'blocked';
+
+CREATE TABLE IF NOT EXISTS job_dependencies (
+    job_id UUID NOT NULL REFERENCES job_queue(id) ON DELETE CASCADE,
+    depends_on UUID NOT NULL REFERENCES job_queue(id) ON DELETE CASCADE,
+    PRIMARY KEY (job_id, depends_on)
+);
+
+CREATE INDEX IF NOT EXISTS job_dependencies_depends_on_idx ON job_dependencies (depends_on);
+
+-- A blocked job holds its unique key like a pending one: the second request
+-- for the same Telegram post must find the first, not queue beside it.
+DROP INDEX IF EXISTS job_queue_unique_pending_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS job_queue_unique_pending_idx
+    ON job_queue (name, unique_key)
+    WHERE unique_scope = 'pending' AND status IN ('pending', 'retry', 'blocked');
+
+DROP INDEX IF EXISTS job_queue_unique_active_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS job_queue_unique_active_idx
+    ON job_queue (name, unique_key)
+    WHERE unique_scope = 'active' AND status IN ('pending', 'retry', 'running', 'blocked');
+
+-- +goose Down
+UPDATE job_queue SET status = 'pending', updated_at = NOW() WHERE status = 'blocked';
+DROP INDEX IF EXISTS job_queue_unique_active_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS job_queue_unique_active_idx
+    ON job_queue (name, unique_key)
+    WHERE unique_scope = 'active' AND status IN ('pending', 'retry', 'running');
+DROP INDEX IF EXISTS job_queue_unique_pending_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS job_queue_unique_pending_idx
+    ON job_queue (name, unique_key)
+    WHERE unique_scope = 'pending' AND status IN ('pending', 'retry');
+DROP TABLE IF EXISTS job_dependencies;
+DROP INDEX IF EXISTS job_queue_blocked_idx;
+DROP INDEX IF EXISTS job_queue_workflow_idx;
+ALTER TABLE job_queue DROP CONSTRAINT IF EXISTS job_queue_after_failure_chk;
+ALTER TABLE job_queue
+    DROP COLUMN IF EXISTS after_failure,
+    DROP COLUMN IF EXISTS workflow_id;
+DROP TABLE IF EXISTS job_workflows;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- The trace a job was enqueued in.
+--
+-- A publish request's trace ended at the enqueue: the translation, the
+-- Telegram post and the e-mail it set off were traces of their own, with
+-- nothing to tie them back. The W3C trace context (traceparent, tracestate)
+-- of the enqueuing code is now stored with the job, and each attempt runs
+-- as a span in that trace. NULL for jobs enqueued outside one.
+ALTER TABLE job_queue ADD COLUMN IF NOT EXISTS trace_context JSONB;
+
+-- +goose Down
+ALTER TABLE job_queue DROP COLUMN IF EXISTS trace_context;
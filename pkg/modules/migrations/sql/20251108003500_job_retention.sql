@@ -0,0 +1,38 @@
+-- +goose Up
+-- Retention for finished jobs.
+--
+-- Nothing ever deleted a finished job, so job_queue grew by every welcome
+-- mail and Telegram post since the first deploy, and the queue's own
+-- statements grew with it. A scheduled pruner now deletes finished jobs past
+-- their retention, a batch at a time. Failed jobs can be archived on the way
+-- out: job_archive keeps the row and its attempts for post-mortems, out of
+-- the way of the queue.
+CREATE INDEX IF NOT EXISTS job_queue_finished_idx
+    ON job_queue (status, updated_at)
+    WHERE status IN ('done', 'failed', 'cancelled');
+
+CREATE TABLE IF NOT EXISTS job_archive (
+    id UUID PRIMARY KEY,
+    user_id UUID REFERENCES auth_users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    queue TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    status TEXT NOT NULL,
+    attempts INT NOT NULL,
+    max_attempts INT NOT NULL,
+    last_error TEXT,
+    result JSONB,
+    -- Every attempt, as the console's history shows them.
+    history JSONB NOT NULL DEFAULT '[]'::jsonb,
+    created_at TIMESTAMPTZ NOT NULL,
+    finished_at TIMESTAMPTZ NOT NULL,
+    archived_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS job_archive_name_idx ON job_archive (name, finished_at DESC);
+CREATE INDEX IF NOT EXISTS job_archive_archived_idx ON job_archive (archived_at);
+CREATE INDEX IF NOT EXISTS job_archive_user_idx ON job_archive (user_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS job_archive;
+DROP INDEX IF EXISTS job_queue_finished_idx;
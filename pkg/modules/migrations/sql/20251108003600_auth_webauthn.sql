@@ -0,0 +1,39 @@
+-- +goose Up
+-- Passkeys (WebAuthn) as a second factor and as a sign-in of their own.
+--
+-- An account may register several: a phone, a laptop, a key on the key ring.
+-- Losing one is then an inconvenience rather than a lockout. The credential
+-- itself is kept as the library hands it over; the columns beside it are the
+-- ones looked up by or shown in the profile.
+CREATE TABLE IF NOT EXISTS auth_webauthn_credentials (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES auth_users(id) ON DELETE CASCADE,
+    credential_id BYTEA NOT NULL,
+    name TEXT NOT NULL,
+    credential JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMPTZ,
+    CONSTRAINT auth_webauthn_credentials_credential_unique UNIQUE (credential_id)
+);
+
+CREATE INDEX IF NOT EXISTS auth_webauthn_credentials_user_idx ON auth_webauthn_credentials (user_id);
+
+-- A ceremony's challenge, between the options the browser is given and the
+-- response it sends back. Kept here rather than in memory so the two halves
+-- may land on different instances, and deleted as it is used: a challenge
+-- answers once. user_id is empty for a passkey sign-in, where the account is
+-- only known from the response.
+CREATE TABLE IF NOT EXISTS auth_webauthn_sessions (
+    id UUID PRIMARY KEY,
+    user_id UUID REFERENCES auth_users(id) ON DELETE CASCADE,
+    purpose TEXT NOT NULL CHECK (purpose IN ('register', 'login', 'passkey')),
+    session JSONB NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS auth_webauthn_sessions_expires_idx ON auth_webauthn_sessions (expires_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS auth_webauthn_sessions;
+DROP TABLE IF EXISTS auth_webauthn_credentials;
@@ -0,0 +1,18 @@
+-- +goose Up
+-- One-time recovery codes for TOTP.
+--
+-- Issued when an authenticator app is confirmed and shown exactly once; only
+-- the hash is kept, like a refresh token's. A code is spent by setting used_at
+-- rather than deleting the row, so "3 of 10 left" can be answered and a second
+-- use of the same code is told apart from a typo in the logs.
+CREATE TABLE IF NOT EXISTS auth_mfa_recovery_codes (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES auth_users(id) ON DELETE CASCADE,
+    code_hash TEXT NOT NULL,
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT auth_mfa_recovery_codes_unique UNIQUE (user_id, code_hash)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS auth_mfa_recovery_codes;
@@ -0,0 +1,44 @@
+-- +goose Up
+-- Refresh tokens become sessions a person can see and end.
+--
+-- A session is a family of refresh tokens: rotation inserts a new row and
+-- revokes the old one, and session_id is what the rows of one sign-in have in
+-- common. Access tokens carry it as the "sid" claim, so revoking the rows of a
+-- session is enough to refuse its access tokens as well.
+--
+-- Browser sign-ins get a row too (kind = 'browser'). Its token_hash belongs to
+-- a secret nobody is ever given — the cookie holds the access token — so the
+-- row can be listed and revoked but never refreshed.
+--
+-- Only the coarse description of the client is kept: device class, browser
+-- and OS family, country. Never the User-Agent itself or the address.
+-- Existing rows each become a session of their own.
+ALTER TABLE auth_refresh_tokens
+    ADD COLUMN IF NOT EXISTS session_id UUID NOT NULL DEFAULT gen_random_uuid(),
+    ADD COLUMN IF NOT EXISTS kind TEXT NOT NULL DEFAULT 'api',
+    ADD COLUMN IF NOT EXISTS device TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS browser TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS os TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS country TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    ADD COLUMN IF NOT EXISTS last_used_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+UPDATE auth_refresh_tokens SET started_at = created_at, last_used_at = created_at;
+
+ALTER TABLE auth_refresh_tokens
+    ADD CONSTRAINT auth_refresh_tokens_kind_check CHECK (kind IN ('api', 'browser'));
+
+CREATE INDEX IF NOT EXISTS auth_refresh_tokens_session_idx ON auth_refresh_tokens(session_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS auth_refresh_tokens_session_idx;
+ALTER TABLE auth_refresh_tokens
+    DROP CONSTRAINT IF EXISTS auth_refresh_tokens_kind_check,
+    DROP COLUMN IF EXISTS last_used_at,
+    DROP COLUMN IF EXISTS started_at,
+    DROP COLUMN IF EXISTS country,
+    DROP COLUMN IF EXISTS os,
+    DROP COLUMN IF EXISTS browser,
+    DROP COLUMN IF EXISTS device,
+    DROP COLUMN IF EXISTS kind,
+    DROP COLUMN IF EXISTS session_id;
@@ -0,0 +1,39 @@
+-- +goose Up
+-- Rate limits shared by every instance and surviving a deploy.
+--
+-- auth_rate_counters holds the sliding windows: requests let through per
+-- action, key and fixed window; a decision reads the current window and the
+-- one before it. Rows older than two of the longest window are deleted by the
+-- limiter itself.
+--
+-- auth_rate_lockouts holds the refusals: how many in a row, the lockout they
+-- earned, and until when the key is turned away at the latest — which is what
+-- the admin view of throttled keys lists. key_label is the key as an
+-- administrator may see it (an address, an e-mail, an account ID); keys that
+-- are secrets themselves are only ever stored hashed.
+CREATE TABLE IF NOT EXISTS auth_rate_counters (
+    action TEXT NOT NULL,
+    key_hash TEXT NOT NULL,
+    window_start TIMESTAMPTZ NOT NULL,
+    hits INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (action, key_hash, window_start)
+);
+
+CREATE INDEX IF NOT EXISTS auth_rate_counters_window_idx ON auth_rate_counters(window_start);
+
+CREATE TABLE IF NOT EXISTS auth_rate_lockouts (
+    action TEXT NOT NULL,
+    key_hash TEXT NOT NULL,
+    key_label TEXT NOT NULL DEFAULT '',
+    strikes INTEGER NOT NULL DEFAULT 0,
+    last_strike_at TIMESTAMPTZ NOT NULL,
+    locked_until TIMESTAMPTZ,
+    blocked_until TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (action, key_hash)
+);
+
+CREATE INDEX IF NOT EXISTS auth_rate_lockouts_blocked_idx ON auth_rate_lockouts(blocked_until);
+
+-- +goose Down
+DROP TABLE IF EXISTS auth_rate_lockouts;
+DROP TABLE IF EXISTS auth_rate_counters;
@@ -0,0 +1,44 @@
+-- +goose Up
+-- Roles become sets of named permissions.
+--
+-- Routes used to list the roles allowed in, so what a role could do was
+-- scattered over every guard in the code. A route now names the permission it
+-- needs, and this table says which roles hold it. admin is not listed: it
+-- holds every permission in code, including ones added after this migration.
+--
+-- The seed reproduces the role lists as they were: director everything in the
+-- admin panel but the config reload, which was admin's alone; manager the
+-- dashboard and finance; editor the dashboard and moderation; operator the
+-- console and the job queue.
+CREATE TABLE IF NOT EXISTS auth_role_permissions (
+    role_id UUID NOT NULL REFERENCES auth_roles(id) ON DELETE CASCADE,
+    permission TEXT NOT NULL,
+    PRIMARY KEY (role_id, permission)
+);
+
+INSERT INTO auth_roles (name, description) VALUES
+    ('operator', 'Operational management of jobs, telemetry, and console.'),
+    ('director', 'Superadmin: full access — users, roles, finance, content, settings.'),
+    ('manager',  'Analytics, advertising/listings, finance (view). No role management.'),
+    ('editor',   'Content and comment moderation, rubrics. No finance.')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO auth_role_permissions (role_id, permission)
+SELECT r.id, p.permission
+FROM auth_roles r
+JOIN (VALUES
+    ('director', 'admin.view'), ('director', 'users.manage'), ('director', 'roles.manage'),
+    ('director', 'finance.view'), ('director', 'payments.configure'), ('director', 'tariffs.edit'),
+    ('director', 'comments.hide'), ('director', 'articles.decide'), ('director', 'appeals.resolve'),
+    ('director', 'partners.decide'), ('director', 'services.manage'), ('director', 'ai.configure'),
+    ('director', 'pages.edit'), ('director', 'predictions.edit'),
+    ('manager', 'admin.view'), ('manager', 'finance.view'),
+    ('editor', 'admin.view'), ('editor', 'comments.hide'), ('editor', 'articles.decide'),
+    ('editor', 'appeals.resolve'),
+    ('operator', 'console.view'), ('operator', 'jobs.view'), ('operator', 'jobs.enqueue'),
+    ('operator', 'jobs.retry'), ('operator', 'jobs.purge'), ('operator', 'jobs.schedule')
+) AS p(role, permission) ON p.role = r.name
+ON CONFLICT (role_id, permission) DO NOTHING;
+
+-- +goose Down
+DROP TABLE IF EXISTS auth_role_permissions;
@@ -0,0 +1,56 @@
+-- +goose Up
+-- The audit log: one row per privileged action, each carrying the hash of the
+-- row before it (see pkg/modules/auth/audit.go).
+--
+-- actor_id has no foreign key on purpose: the record of what an administrator
+-- did must outlive their account. before/after are json, not jsonb — jsonb
+-- rewrites the text, and the hash is over the text as written.
+CREATE TABLE IF NOT EXISTS auth_audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    at TIMESTAMPTZ NOT NULL,
+    actor_id UUID,
+    actor_email TEXT NOT NULL DEFAULT '',
+    action TEXT NOT NULL,
+    target_type TEXT NOT NULL DEFAULT '',
+    target_id TEXT NOT NULL DEFAULT '',
+    before JSON,
+    after JSON,
+    ip TEXT NOT NULL DEFAULT '',
+    request_id TEXT NOT NULL DEFAULT '',
+    prev_hash TEXT NOT NULL,
+    hash TEXT NOT NULL UNIQUE
+);
+
+CREATE INDEX IF NOT EXISTS auth_audit_log_at_idx ON auth_audit_log (at);
+CREATE INDEX IF NOT EXISTS auth_audit_log_action_idx ON auth_audit_log (action text_pattern_ops);
+CREATE INDEX IF NOT EXISTS auth_audit_log_target_idx ON auth_audit_log (target_id);
+CREATE INDEX IF NOT EXISTS auth_audit_log_actor_idx ON auth_audit_log (actor_id);
+
+-- Append-only. The chain would show an edit anyway; this refuses it first.
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION auth_audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'auth_audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+DROP TRIGGER IF EXISTS auth_audit_log_no_change ON auth_audit_log;
+CREATE TRIGGER auth_audit_log_no_change
+    BEFORE UPDATE OR DELETE ON auth_audit_log
+    FOR EACH ROW EXECUTE FUNCTION auth_audit_log_append_only();
+
+DROP TRIGGER IF EXISTS auth_audit_log_no_truncate ON auth_audit_log;
+CREATE TRIGGER auth_audit_log_no_truncate
+    BEFORE TRUNCATE ON auth_audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION auth_audit_log_append_only();
+
+-- Reading it is director's, like everything else in the panel.
+INSERT INTO auth_role_permissions (role_id, permission)
+SELECT id, 'audit.view' FROM auth_roles WHERE name = 'director'
+ON CONFLICT (role_id, permission) DO NOTHING;
+
+-- +goose Down
+DELETE FROM auth_role_permissions WHERE permission = 'audit.view';
+DROP TABLE IF EXISTS auth_audit_log;
+DROP FUNCTION IF EXISTS auth_audit_log_append_only();
@@ -0,0 +1,30 @@
+-- +goose Up
+-- Personal data exports: the archive an account holder (or an administrator
+-- answering a data-subject request for them) asked for, built by a job and
+-- fetched through an e-mailed link (see pkg/modules/articles/data_export.go).
+--
+-- The archive lives here rather than with the media: it is the account's
+-- personal data in one file, and that stays in this database, inside the
+-- country. It is gone, row and all, once the link expires.
+CREATE TABLE IF NOT EXISTS data_exports (
+    id            UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id       UUID NOT NULL REFERENCES auth_users(id) ON DELETE CASCADE,
+    -- The administrator who asked on the account's behalf; NULL when the
+    -- account holder asked themselves.
+    requested_by  UUID REFERENCES auth_users(id) ON DELETE SET NULL,
+    send_to       TEXT NOT NULL,
+    status        TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'ready', 'failed')),
+    token_hash    TEXT UNIQUE,
+    archive       BYTEA,
+    size          BIGINT NOT NULL DEFAULT 0,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    ready_at      TIMESTAMPTZ,
+    expires_at    TIMESTAMPTZ NOT NULL,
+    downloaded_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS data_exports_user_idx ON data_exports (user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS data_exports_expiry_idx ON data_exports (expires_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS data_exports;
@@ -0,0 +1,35 @@
+-- +goose Up
+-- Passwordless e-mail sign-in reuses the verification-token table: a row with
+-- purpose 'signin' is a link token and a six-digit code mailed together to an
+-- address, either of which proves the reader holds that inbox (see
+-- pkg/modules/auth/email_signin.go).
+--
+-- A sign-in row names the address, not an account: the address may have none
+-- yet — proving it is then the first step of registering — and the account
+-- it belongs to is looked up when the proof is used.
+ALTER TABLE email_verification_tokens
+    ADD COLUMN IF NOT EXISTS purpose   TEXT NOT NULL DEFAULT 'verify',
+    ADD COLUMN IF NOT EXISTS email     TEXT,
+    ADD COLUMN IF NOT EXISTS code_hash TEXT,
+    -- Wrong codes typed against this row; at the limit the row is spent.
+    ADD COLUMN IF NOT EXISTS attempts  INT NOT NULL DEFAULT 0,
+    ALTER COLUMN user_id DROP NOT NULL;
+
+ALTER TABLE email_verification_tokens
+    ADD CONSTRAINT email_verification_purpose_chk CHECK (
+        (purpose = 'verify' AND user_id IS NOT NULL) OR
+        (purpose = 'signin' AND email IS NOT NULL AND code_hash IS NOT NULL));
+
+CREATE INDEX IF NOT EXISTS email_signin_email_idx
+    ON email_verification_tokens (email, created_at DESC) WHERE purpose = 'signin';
+
+-- +goose Down
+DELETE FROM email_verification_tokens WHERE purpose = 'signin';
+DROP INDEX IF EXISTS email_signin_email_idx;
+ALTER TABLE email_verification_tokens DROP CONSTRAINT IF EXISTS email_verification_purpose_chk;
+ALTER TABLE email_verification_tokens
+    DROP COLUMN IF EXISTS attempts,
+    DROP COLUMN IF EXISTS code_hash,
+    DROP COLUMN IF EXISTS email,
+    DROP COLUMN IF EXISTS purpose,
+    ALTER COLUMN user_id SET NOT NULL;
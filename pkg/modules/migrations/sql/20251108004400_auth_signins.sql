@@ -0,0 +1,31 @@
+-- +goose Up
+-- Sign-in history: one row per successful sign-in, kept 90 days, against
+-- which the next one is compared (see pkg/modules/auth/signin_alerts.go).
+--
+-- A session row says where an account is signed in now; this says where it
+-- signed in from before, which outlives the session it opened. Like the
+-- session it holds the coarse client only — device class, browser and OS
+-- family, country — plus whether the address was a datacenter's. Neither the
+-- address nor the User-Agent is stored.
+CREATE TABLE IF NOT EXISTS auth_signins (
+    id               UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id          UUID NOT NULL REFERENCES auth_users(id) ON DELETE CASCADE,
+    at               TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    kind             TEXT NOT NULL DEFAULT 'browser' CHECK (kind IN ('browser', 'api')),
+    device           TEXT NOT NULL DEFAULT '',
+    browser          TEXT NOT NULL DEFAULT '',
+    os               TEXT NOT NULL DEFAULT '',
+    country          TEXT NOT NULL DEFAULT '',
+    datacenter       BOOLEAN NOT NULL DEFAULT FALSE,
+    -- What was new about it: country, device, datacenter. Empty when nothing
+    -- was, and no alert went out.
+    signals          TEXT[] NOT NULL DEFAULT '{}',
+    -- The "this wasn't me" link of the alert mail; NULL when none was sent.
+    alert_token_hash TEXT UNIQUE,
+    disowned_at      TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS auth_signins_user_idx ON auth_signins (user_id, at DESC);
+
+-- +goose Down
+DROP TABLE IF EXISTS auth_signins;
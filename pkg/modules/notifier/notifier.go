@@ -5,10 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"mime"
+	"net"
 	"net/mail"
 	"net/smtp"
 	"sort"
 	"strings"
+	"sync"
 
 	"go.uber.org/zap"
 	"shanraq.org/internal/config"
@@ -29,10 +31,13 @@ type HeaderMailer interface {
 	SendWithHeaders(ctx context.Context, to, subject, body string, headers map[string]string) error
 }
 
-// Module wires an SMTP-backed mailer based on configuration.
+// Module wires an SMTP-backed mailer based on configuration. The sender is
+// replaced on a config reload, so it is read under mu.
 type Module struct {
-	sender Mailer
 	logger *zap.Logger
+
+	mu     sync.RWMutex
+	sender Mailer
 	cfg    config.SMTPConfig
 }
 
@@ -42,42 +47,90 @@ func (m *Module) Name() string { return "notifier" }
 
 func (m *Module) Init(_ context.Context, rt *shanraq.Runtime) error {
 	m.logger = rt.Logger
-	m.cfg = rt.Config.Notifications.SMTP
-	if m.cfg.Host == "" || m.cfg.From == "" {
-		rt.Logger.Info("notifier: smtp disabled (host/from not configured)")
-		return nil
-	}
-	if m.cfg.Port == 0 {
-		m.cfg.Port = 587
+	m.configure(rt.Config.Notifications.SMTP)
+	return nil
+}
+
+// Reconfigure swaps the SMTP relay — a rotated password, a new provider —
+// without a restart. Mail already being sent finishes on the old sender.
+func (m *Module) Reconfigure(_ context.Context, cfg config.Config, changes config.Changes) error {
+	if changes.Touches("notifications.smtp") {
+		m.configure(cfg.Notifications.SMTP)
 	}
-	m.sender = &smtpSender{cfg: m.cfg}
-	rt.Logger.Info("notifier: smtp configured", zap.String("host", m.cfg.Host), zap.Int("port", m.cfg.Port))
 	return nil
 }
 
+func (m *Module) configure(cfg config.SMTPConfig) {
+	var sender Mailer
+	if cfg.Host == "" || cfg.From == "" {
+		m.logger.Info("notifier: smtp disabled (host/from not configured)")
+	} else {
+		if cfg.Port == 0 {
+			cfg.Port = 587
+		}
+		sender = &smtpSender{cfg: cfg}
+		m.logger.Info("notifier: smtp configured", zap.String("host", cfg.Host), zap.Int("port", cfg.Port))
+	}
+	m.mu.Lock()
+	m.cfg, m.sender = cfg, sender
+	m.mu.Unlock()
+}
+
 // Sender returns the configured mailer or nil if smtp is disabled.
-func (m *Module) Sender() Mailer { return m.sender }
+func (m *Module) Sender() Mailer {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.sender
+}
 
 // Send allows the notifier module itself to satisfy the Mailer interface.
 func (m *Module) Send(ctx context.Context, to, subject, body string) error {
-	if m.sender == nil {
+	sender := m.Sender()
+	if sender == nil {
 		return errors.New("mailer not configured")
 	}
-	return m.sender.Send(ctx, to, subject, body)
+	return sender.Send(ctx, to, subject, body)
 }
 
 // SendWithHeaders satisfies HeaderMailer, forwarding to the SMTP sender.
 func (m *Module) SendWithHeaders(ctx context.Context, to, subject, body string, headers map[string]string) error {
-	s, ok := m.sender.(HeaderMailer)
+	s, ok := m.Sender().(HeaderMailer)
 	if !ok {
 		return m.Send(ctx, to, subject, body)
 	}
 	return s.SendWithHeaders(ctx, to, subject, body, headers)
 }
 
+// HealthChecks dials the SMTP relay. A TCP connect is enough to catch the
+// usual failures — relay down, port blocked by the host's firewall, DNS gone —
+// without logging in to the relay every few seconds.
+func (m *Module) HealthChecks() []shanraq.HealthCheck {
+	return []shanraq.HealthCheck{{
+		Name: "smtp",
+		Run: func(ctx context.Context) (string, error) {
+			m.mu.RLock()
+			sender, cfg := m.sender, m.cfg
+			m.mu.RUnlock()
+			if sender == nil {
+				return "disabled", nil
+			}
+			addr := net.JoinHostPort(cfg.Host, fmt.Sprint(cfg.Port))
+			var d net.Dialer
+			conn, err := d.DialContext(ctx, "tcp", addr)
+			if err != nil {
+				return addr, err
+			}
+			_ = conn.Close()
+			return addr, nil
+		},
+	}}
+}
+
 var _ interface {
 	shanraq.Module
 	shanraq.InitializerModule
+	shanraq.HealthChecker
+	shanraq.Reconfigurable
 } = (*Module)(nil)
 
 type smtpSender struct {
@@ -7,13 +7,18 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
-	"time"
 
 	"go.uber.org/zap"
+	"shanraq.org/pkg/modules/jobs"
+	"shanraq.org/pkg/shanraq"
 )
 
-// digestInterval is how often the weekly digest may go out.
-const digestInterval = 7 * 24 * time.Hour
+// JobDigest is the scheduled job that sends the weekly digest.
+const JobDigest = "syndicate_digest"
+
+// digestSpec is Monday 04:00 UTC — nine in the morning in Astana, the start of
+// the reader's week rather than the end of ours.
+const digestSpec = "0 4 * * 1"
 
 // subscriber is one confirmed newsletter recipient.
 type subscriber struct {
@@ -484,7 +489,7 @@ func (m *Module) renderDigest(lang string, entries []feedEntry, token string) (s
 // to confirm to, so the request is reported as failed rather than left pending
 // forever.
 func (m *Module) sendConfirmation(ctx context.Context, email, lang, token string) error {
-	if !m.emailEnabled || m.mailer == nil {
+	if !m.settings().emailEnabled || m.mailer == nil {
 		return fmt.Errorf("email not configured")
 	}
 	link := m.baseURL + "/subscribe/confirm?token=" + token
@@ -495,7 +500,7 @@ func (m *Module) sendConfirmation(ctx context.Context, email, lang, token string
 // SendDigest emails the weekly digest to every confirmed subscriber in their
 // language. Returns how many messages were sent. A no-op without SMTP.
 func (m *Module) SendDigest(ctx context.Context) (int, error) {
-	if !m.emailEnabled || m.mailer == nil {
+	if !m.settings().emailEnabled || m.mailer == nil {
 		return 0, nil
 	}
 	subs, err := m.listSubscribers(ctx)
@@ -553,18 +558,23 @@ func (m *Module) unsubURL(token string) string {
 	return m.baseURL + "/unsubscribe?token=" + token
 }
 
-func (m *Module) digestDue(ctx context.Context) bool {
-	var last *time.Time
-	if err := m.db.QueryRow(ctx, `SELECT last_sent_at FROM digest_state WHERE id = 1`).Scan(&last); err != nil {
-		return false
-	}
-	return last == nil || time.Since(*last) >= digestInterval
-}
-
-func (m *Module) markDigestSent(ctx context.Context) {
-	if _, err := m.db.Exec(ctx, `UPDATE digest_state SET last_sent_at = NOW() WHERE id = 1`); err != nil {
-		m.log.Warn("mark digest sent", zap.Error(err))
+// handleDigestJob sends the digest when its schedule comes round. It is not
+// retried: a failure part-way has already reached some subscribers, and a
+// second attempt would mail them twice. "Run now" in the console is there for
+// an operator who has checked.
+//
+// The schedule replaced digest_state, which a loop on every instance read to
+// decide whether a week had passed; the schedule's last run is that record now.
+func (m *Module) handleDigestJob(ctx context.Context, _ *shanraq.Runtime, _ jobs.Job) error {
+	if !m.settings().emailEnabled {
+		return nil
+	}
+	sent, err := m.SendDigest(ctx)
+	if err != nil {
+		return fmt.Errorf("weekly digest: %w", err)
 	}
+	m.log.Info("weekly digest sent", zap.Int("recipients", sent))
+	return nil
 }
 
 func randomToken() (string, error) {
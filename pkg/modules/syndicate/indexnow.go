@@ -32,12 +32,13 @@ const (
 // handleIndexNowKey serves the ownership key as plain text. Without it every
 // submission is rejected.
 func (m *Module) handleIndexNowKey(w http.ResponseWriter, r *http.Request) {
-	if m.indexNowKey == "" {
+	key := m.settings().indexNowKey
+	if key == "" {
 		http.NotFound(w, r)
 		return
 	}
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-	_, _ = w.Write([]byte(m.indexNowKey))
+	_, _ = w.Write([]byte(key))
 }
 
 // submitIndexNow pushes one article's three language URLs to IndexNow.
@@ -47,7 +48,8 @@ func (m *Module) handleIndexNowKey(w http.ResponseWriter, r *http.Request) {
 // logged and dropped — the sitemap remains the reliable path, this is only the
 // fast one.
 func (m *Module) submitIndexNow(slug string) {
-	if m.indexNowKey == "" || slug == "" {
+	key := m.settings().indexNowKey
+	if key == "" || slug == "" {
 		return
 	}
 	host := m.baseURL
@@ -60,7 +62,7 @@ func (m *Module) submitIndexNow(slug string) {
 	}
 	body, err := json.Marshal(map[string]any{
 		"host":        host,
-		"key":         m.indexNowKey,
+		"key":         key,
 		"keyLocation": m.baseURL + indexNowKeyPath,
 		"urlList":     urls,
 	})
@@ -4,8 +4,9 @@
 //   - optional Telegram auto-posting on publish (activated by config).
 //
 // This is the resilience layer of the platform. It reads article data with raw
-// SQL rather than importing the articles package, keeping the dependency graph
-// acyclic (articles -> syndicate -> jobs).
+// SQL rather than importing the articles package, and hears about publishes
+// through the event bus (events.ArticlePublished) rather than being called by
+// it — neither module imports the other.
 package syndicate
 
 import (
@@ -15,12 +16,16 @@ import (
 	"html/template"
 	"net/http"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"go.uber.org/zap"
+	"shanraq.org/internal/config"
+	"shanraq.org/pkg/events"
 	"shanraq.org/pkg/modules/jobs"
 	"shanraq.org/pkg/shanraq"
 	"shanraq.org/web"
@@ -44,64 +49,132 @@ var noticeTmpl = template.Must(template.New("notice").
 
 // Module implements the RSS route, Telegram publish job, and email digest.
 type Module struct {
-	rt           *shanraq.Runtime
-	db           *pgxpool.Pool
-	log          *zap.Logger
-	http         *http.Client
-	baseURL      string
+	rt      *shanraq.Runtime
+	db      *pgxpool.Pool
+	log     *zap.Logger
+	http    *http.Client
+	baseURL string
+	mailer  Mailer
+
+	// live holds the channel settings a config reload may replace; read them
+	// through settings(), never cache them across a request.
+	live atomic.Pointer[settings]
+
+	subMu       sync.Mutex
+	subIndexNow bool
+	subTelegram bool
+}
+
+// settings are the reloadable parts of the configuration: which channels are
+// on and their credentials.
+type settings struct {
 	tgEnabled    bool
 	tgBotToken   string
 	tgChatID     string
-	mailer       Mailer
 	emailEnabled bool
 	indexNowKey  string
 }
 
+func (m *Module) settings() settings {
+	if s := m.live.Load(); s != nil {
+		return *s
+	}
+	return settings{}
+}
+
+func (m *Module) use(s settings) { m.live.Store(&s) }
+
+// Option customizes the module.
+type Option func(*Module)
+
+// WithHTTPClient sends the Telegram and IndexNow calls through c instead of a
+// plain client with a ten-second timeout. Tests use it to catch the calls.
+func WithHTTPClient(c *http.Client) Option {
+	return func(m *Module) {
+		m.http = c
+	}
+}
+
 // New returns a module. mailer (the notifier) powers the email digest; pass nil
 // to disable email entirely.
-func New(mailer Mailer) *Module { return &Module{mailer: mailer} }
+func New(mailer Mailer, opts ...Option) *Module {
+	m := &Module{mailer: mailer}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
 
 func (m *Module) Name() string { return "syndicate" }
 
+// DependsOn names the mailer when it is a module, so the digest never runs
+// against a notifier that has not been initialized.
+func (m *Module) DependsOn() []string {
+	if mod, ok := m.mailer.(shanraq.Module); ok {
+		return []string{mod.Name()}
+	}
+	return nil
+}
+
 // Init reads config and prepares the HTTP client for Telegram.
 func (m *Module) Init(_ context.Context, rt *shanraq.Runtime) error {
-	cfg := rt.Config.Syndicate
 	m.rt = rt
 	m.db = rt.DB
 	m.log = rt.Logger
-	m.http = &http.Client{Timeout: 10 * time.Second}
+	if m.http == nil {
+		m.http = &http.Client{Timeout: 10 * time.Second}
+	}
 	// Prefer the single canonical origin so RSS/Telegram links match the site.
 	m.baseURL = rt.Config.PublicBase()
 	if m.baseURL == "" {
 		m.baseURL = "http://localhost:8080"
 	}
-	m.tgBotToken = strings.TrimSpace(cfg.Telegram.BotToken)
-	m.tgChatID = strings.TrimSpace(cfg.Telegram.ChatID)
-	m.tgEnabled = cfg.Telegram.Enabled && m.tgBotToken != "" && m.tgChatID != ""
+	m.configure(rt.Config)
+	return nil
+}
+
+// Reconfigure applies new Telegram credentials, a new IndexNow key, or an SMTP
+// relay appearing or going away. A channel switched on subscribes to the bus
+// then; one switched off keeps its subscription but does nothing, since the
+// bus has no unsubscribe and deliveries already queued must still resolve.
+func (m *Module) Reconfigure(_ context.Context, cfg config.Config, changes config.Changes) error {
+	if changes.Touches("syndicate") || changes.Touches("notifications.smtp") {
+		m.configure(cfg)
+	}
+	return nil
+}
+
+func (m *Module) configure(cfg config.Config) {
+	var s settings
+	s.tgBotToken = strings.TrimSpace(cfg.Syndicate.Telegram.BotToken)
+	s.tgChatID = strings.TrimSpace(cfg.Syndicate.Telegram.ChatID)
+	s.tgEnabled = cfg.Syndicate.Telegram.Enabled && s.tgBotToken != "" && s.tgChatID != ""
 
-	smtp := rt.Config.Notifications.SMTP
-	m.emailEnabled = m.mailer != nil && strings.TrimSpace(smtp.Host) != "" && strings.TrimSpace(smtp.From) != ""
+	smtp := cfg.Notifications.SMTP
+	s.emailEnabled = m.mailer != nil && strings.TrimSpace(smtp.Host) != "" && strings.TrimSpace(smtp.From) != ""
 
-	if key, ok := normalizeIndexNowKey(cfg.IndexNowKey); !ok {
+	if key, ok := normalizeIndexNowKey(cfg.Syndicate.IndexNowKey); !ok {
 		m.log.Warn("indexnow key rejected: needs 8-128 hex characters or dashes")
 	} else if key != "" {
-		m.indexNowKey = key
+		s.indexNowKey = key
 		m.log.Info("indexnow enabled", zap.String("key_url", m.baseURL+indexNowKeyPath))
 	} else {
 		m.log.Info("indexnow disabled (set syndicate.indexnow_key to notify Bing and Yandex on publish)")
 	}
 
-	if m.tgEnabled {
-		m.log.Info("syndicate telegram enabled", zap.String("chat", m.tgChatID))
+	m.use(s)
+	m.subscribeEvents(m.rt.Events)
+
+	if s.tgEnabled {
+		m.log.Info("syndicate telegram enabled", zap.String("chat", s.tgChatID))
 	} else {
 		m.log.Info("syndicate telegram disabled (RSS still active at /feed.xml)")
 	}
-	if m.emailEnabled {
+	if s.emailEnabled {
 		m.log.Info("syndicate email digest enabled (weekly)")
 	} else {
 		m.log.Info("syndicate email digest disabled (configure SMTP to enable); subscriptions still stored")
 	}
-	return nil
 }
 
 // Routes exposes the RSS feed and subscription endpoints.
@@ -118,32 +191,8 @@ func (m *Module) Routes(r chi.Router) {
 	r.Post("/unsubscribe", m.handleUnsubscribe)
 }
 
-// Start runs the weekly digest scheduler. It checks a few times a day whether a
-// week has elapsed since the last send; the send itself is a no-op without SMTP.
-func (m *Module) Start(ctx context.Context, _ *shanraq.Runtime) error {
-	ticker := time.NewTicker(6 * time.Hour)
-	defer ticker.Stop()
-	for {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case <-ticker.C:
-			if !m.emailEnabled || !m.digestDue(ctx) {
-				continue
-			}
-			sent, err := m.SendDigest(ctx)
-			if err != nil {
-				m.log.Error("weekly digest", zap.Error(err))
-				continue
-			}
-			m.markDigestSent(ctx)
-			m.log.Info("weekly digest sent", zap.Int("recipients", sent))
-		}
-	}
-}
-
 // TelegramEnabled reports whether Telegram auto-posting is configured.
-func (m *Module) TelegramEnabled() bool { return m.tgEnabled }
+func (m *Module) TelegramEnabled() bool { return m.settings().tgEnabled }
 
 // articleHasPlace reports whether an article was addressed to one place rather
 // than to everyone.
@@ -157,51 +206,67 @@ func (m *Module) articleHasPlace(ctx context.Context, id uuid.UUID) (bool, error
 	return has, nil
 }
 
-// RegisterJobs attaches the Telegram publish handler to the queue.
+// RegisterJobs attaches the Telegram publish handler to the queue and
+// schedules the weekly digest.
 func (m *Module) RegisterJobs(j *jobs.Module) {
 	j.Handle(JobTelegram, m.handleTelegramJob)
+	j.Schedule(JobDigest, digestSpec, m.handleDigestJob, jobs.WithMaxAttempts(1))
+}
+
+// subscribeEvents attaches the publish reactions to the event bus. Each one is
+// subscribed only once configured, so an unconfigured site queues nothing, and
+// at most once, since it runs again on every reload.
+func (m *Module) subscribeEvents(bus *shanraq.EventBus) {
+	s := m.settings()
+	m.subMu.Lock()
+	defer m.subMu.Unlock()
+	if s.indexNowKey != "" && !m.subIndexNow {
+		shanraq.Subscribe(bus, m.onPublishedIndexNow)
+		m.subIndexNow = true
+	}
+	if s.tgEnabled && !m.subTelegram {
+		shanraq.SubscribeDurable(bus, subscriberTelegram, m.onPublishedTelegram,
+			shanraq.DedupeBy(func(ev events.ArticlePublished) string { return ev.ArticleID.String() }, telegramRepostWindow))
+		m.subTelegram = true
+	}
 }
 
-// EnqueuePublish schedules a Telegram announcement for a newly published
-// article. It is a no-op when Telegram is not configured.
-func (m *Module) EnqueuePublish(ctx context.Context, store *jobs.Store, articleID uuid.UUID) error {
-	// Every publish path funnels through here, so IndexNow rides along and no
-	// caller has to remember it. Independent of Telegram: a site with no channel
-	// configured still wants search engines told. Guarded on the key so an
-	// unconfigured site does not pay for a lookup it will not use.
-	if m.indexNowKey != "" {
-		if slug, err := m.articleSlug(ctx, articleID); err != nil {
-			m.log.Warn("indexnow slug lookup", zap.Error(err))
-		} else {
-			m.submitIndexNow(slug)
-		}
-	}
-	if !m.tgEnabled || store == nil {
+// onPublishedIndexNow tells search engines about the article. In-line rather
+// than durable: the submission is already detached and fire-and-forget, and
+// a lost one costs nothing the sitemap will not make up.
+func (m *Module) onPublishedIndexNow(ctx context.Context, ev events.ArticlePublished) error {
+	if m.settings().indexNowKey == "" {
 		return nil
 	}
-	// A channel has one audience: everybody subscribed to it. Material written
-	// for one town is not written for them, so it stays on its place page.
-	if local, err := m.articleHasPlace(ctx, articleID); err != nil {
-		m.log.Warn("place lookup before telegram", zap.Error(err))
-	} else if local {
+	slug, err := m.articleSlug(ctx, ev.ArticleID)
+	if err != nil {
+		return fmt.Errorf("indexnow slug lookup: %w", err)
+	}
+	m.submitIndexNow(slug)
+	return nil
+}
+
+// onPublishedTelegram announces the article on the channel. It runs from the
+// queue, so a Telegram outage is retried rather than lost.
+func (m *Module) onPublishedTelegram(ctx context.Context, ev events.ArticlePublished) error {
+	if !m.TelegramEnabled() {
 		return nil
 	}
-	payload, err := TelegramPayload(articleID)
+	// A channel has one audience: everybody subscribed to it. Material written
+	// for one town is not written for them, so it stays on its place page.
+	local, err := m.articleHasPlace(ctx, ev.ArticleID)
 	if err != nil {
 		return err
 	}
-	return store.Enqueue(ctx, jobs.Job{
-		ID:          uuid.New(),
-		Name:        JobTelegram,
-		Payload:     payload,
-		RunAt:       time.Now(),
-		MaxAttempts: 3,
-	})
+	if local {
+		return nil
+	}
+	return m.announceTelegram(ctx, ev.ArticleID)
 }
 
 var _ interface {
 	shanraq.Module
 	shanraq.RouterModule
 	shanraq.InitializerModule
-	shanraq.StarterModule
+	shanraq.Reconfigurable
 } = (*Module)(nil)
@@ -12,6 +12,9 @@ import (
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"go.uber.org/zap"
+	"shanraq.org/internal/config"
+	"shanraq.org/pkg/events"
+	"shanraq.org/pkg/shanraq"
 )
 
 func testModule() *Module {
@@ -58,12 +61,79 @@ func TestTelegramDisabledByDefault(t *testing.T) {
 	if m.TelegramEnabled() {
 		t.Fatal("telegram should be disabled without config")
 	}
-	// EnqueuePublish must be a safe no-op when disabled (nil store tolerated).
-	if err := m.EnqueuePublish(context.Background(), nil, uuid.New()); err != nil {
-		t.Fatalf("EnqueuePublish no-op: %v", err)
+	// Unconfigured, nothing subscribes: a publish queues no work at all.
+	bus := shanraq.NewEventBus(zap.NewNop())
+	var queue recordingQueue
+	bus.UseQueue(&queue)
+	m.subscribeEvents(bus)
+	if err := bus.Publish(context.Background(), events.ArticlePublished{ArticleID: uuid.New()}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if len(queue) != 0 {
+		t.Fatalf("disabled telegram queued %d deliveries", len(queue))
+	}
+}
+
+func TestTelegramSubscribesDurably(t *testing.T) {
+	m := testModule()
+	m.use(settings{tgEnabled: true})
+	bus := shanraq.NewEventBus(zap.NewNop())
+	var queue recordingQueue
+	bus.UseQueue(&queue)
+	m.subscribeEvents(bus)
+
+	id := uuid.New()
+	if err := bus.Publish(context.Background(), events.ArticlePublished{ArticleID: id}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if len(queue) != 1 {
+		t.Fatalf("got %d deliveries, want 1", len(queue))
+	}
+	d := queue[0]
+	if d.Event != events.NameArticlePublished || d.Subscriber != subscriberTelegram {
+		t.Fatalf("unexpected delivery: %+v", d)
+	}
+	if !strings.Contains(string(d.Payload), id.String()) {
+		t.Fatalf("payload lost the article id: %s", d.Payload)
+	}
+}
+
+func TestReconfigureEnablesTelegramOnce(t *testing.T) {
+	bus := shanraq.NewEventBus(zap.NewNop())
+	var queue recordingQueue
+	bus.UseQueue(&queue)
+	m := testModule()
+	m.rt = &shanraq.Runtime{Events: bus}
+	m.configure(config.Config{})
+
+	var cfg config.Config
+	cfg.Syndicate.Telegram = config.TelegramConfig{Enabled: true, BotToken: "123:abc", ChatID: "@shanraq"}
+	changes := config.Diff(config.Config{}, cfg)
+	// Twice: a second reload must not register the subscriber again, which
+	// the bus would refuse with a panic.
+	for i := 0; i < 2; i++ {
+		if err := m.Reconfigure(context.Background(), cfg, changes); err != nil {
+			t.Fatalf("reconfigure: %v", err)
+		}
+	}
+	if !m.TelegramEnabled() {
+		t.Fatal("telegram should be enabled after reload")
+	}
+	if err := bus.Publish(context.Background(), events.ArticlePublished{ArticleID: uuid.New()}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if len(queue) != 1 {
+		t.Fatalf("got %d deliveries, want 1", len(queue))
 	}
 }
 
+type recordingQueue []shanraq.EventDelivery
+
+func (q *recordingQueue) EnqueueEvent(_ context.Context, d shanraq.EventDelivery) error {
+	*q = append(*q, d)
+	return nil
+}
+
 func TestRenderDigest(t *testing.T) {
 	m := testModule()
 	when := time.Date(2026, 7, 13, 10, 0, 0, 0, time.UTC)
@@ -178,7 +248,8 @@ func TestDigestIntegration(t *testing.T) {
 	}
 
 	fm := &fakeMailer{}
-	m := &Module{db: pool, baseURL: "https://shanraq.org", log: zap.NewNop(), mailer: fm, emailEnabled: true}
+	m := &Module{db: pool, baseURL: "https://shanraq.org", log: zap.NewNop(), mailer: fm}
+	m.use(settings{emailEnabled: true})
 
 	confirmTok, err := m.subscribe(ctx, email, "ru")
 	if err != nil {
@@ -403,7 +474,8 @@ func TestNormalizeIndexNowKey(t *testing.T) {
 
 // The key file proves domain ownership; without it every submission is refused.
 func TestIndexNowKeyEndpoint(t *testing.T) {
-	m := &Module{log: zap.NewNop(), indexNowKey: "a1b2c3d4e5f6"}
+	m := &Module{log: zap.NewNop()}
+	m.use(settings{indexNowKey: "a1b2c3d4e5f6"})
 	w := httptest.NewRecorder()
 	m.handleIndexNowKey(w, httptest.NewRequest(http.MethodGet, indexNowKeyPath, nil))
 	if w.Code != http.StatusOK || w.Body.String() != "a1b2c3d4e5f6" {
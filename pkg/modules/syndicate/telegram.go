@@ -8,6 +8,7 @@ import (
 	"html"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
@@ -17,8 +18,19 @@ import (
 )
 
 // JobTelegram is the queue job that posts a published article to Telegram.
+// New announcements arrive through the event bus (subscriberTelegram); the job
+// stays registered for ones queued before that, and for re-posting an article
+// by hand from the console.
 const JobTelegram = "syndicate_telegram"
 
+// subscriberTelegram names the durable article.published subscriber.
+const subscriberTelegram = "syndicate.telegram"
+
+// telegramRepostWindow is how long an article is announced at most once. An
+// author who unpublishes to fix a typo and publishes again, or a moderator
+// approving twice, would otherwise post it to the channel twice.
+const telegramRepostWindow = 24 * time.Hour
+
 // TelegramJobPayload carries the article to announce.
 type TelegramJobPayload struct {
 	ArticleID string `json:"article_id"`
@@ -30,18 +42,22 @@ func TelegramPayload(articleID uuid.UUID) (json.RawMessage, error) {
 }
 
 func (m *Module) handleTelegramJob(ctx context.Context, _ *shanraq.Runtime, job jobs.Job) error {
-	if !m.tgEnabled {
+	if !m.TelegramEnabled() {
 		return nil
 	}
 	var payload TelegramJobPayload
 	if err := job.Decode(&payload); err != nil {
-		return fmt.Errorf("decode payload: %w", err)
+		return jobs.Permanent(fmt.Errorf("decode payload: %w", err))
 	}
 	id, err := uuid.Parse(payload.ArticleID)
 	if err != nil {
-		return fmt.Errorf("bad article id: %w", err)
+		return jobs.Permanent(fmt.Errorf("bad article id: %w", err))
 	}
+	return m.announceTelegram(ctx, id)
+}
 
+// announceTelegram posts one published article to the channel.
+func (m *Module) announceTelegram(ctx context.Context, id uuid.UUID) error {
 	slug, title, summary, lang, err := m.loadAnnouncement(ctx, id)
 	if err != nil {
 		return err
@@ -52,7 +68,7 @@ func (m *Module) handleTelegramJob(ctx context.Context, _ *shanraq.Runtime, job
 	if err := m.sendTelegram(ctx, text); err != nil {
 		return err
 	}
-	m.log.Info("telegram announced article", zap.String("article_id", payload.ArticleID))
+	m.log.Info("telegram announced article", zap.String("article_id", id.String()))
 	return nil
 }
 
@@ -89,13 +105,14 @@ func buildTelegramMessage(title, summary, url string) string {
 }
 
 func (m *Module) sendTelegram(ctx context.Context, text string) error {
+	s := m.settings()
 	body, _ := json.Marshal(map[string]any{
-		"chat_id":                  m.tgChatID,
+		"chat_id":                  s.tgChatID,
 		"text":                     text,
 		"parse_mode":               "HTML",
 		"disable_web_page_preview": false,
 	})
-	url := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", m.tgBotToken)
+	url := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", s.tgBotToken)
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
 	if err != nil {
 		return fmt.Errorf("build telegram request: %w", err)
@@ -110,7 +127,29 @@ func (m *Module) sendTelegram(ctx context.Context, text string) error {
 	if resp.StatusCode >= 300 {
 		buf := new(bytes.Buffer)
 		_, _ = buf.ReadFrom(resp.Body)
-		return fmt.Errorf("telegram api status %d: %s", resp.StatusCode, strings.TrimSpace(buf.String()))
+		err := fmt.Errorf("telegram api status %d: %s", resp.StatusCode, strings.TrimSpace(buf.String()))
+		if wait := telegramRetryAfter(resp.StatusCode, buf.Bytes()); wait > 0 {
+			return jobs.RetryAfter(wait, err)
+		}
+		return err
 	}
 	return nil
 }
+
+// telegramRetryAfter reads the flood-control wait out of a 429. The Bot API
+// puts it in the body (parameters.retry_after, seconds), and a post retried
+// sooner is refused again and extends the ban.
+func telegramRetryAfter(status int, body []byte) time.Duration {
+	if status != http.StatusTooManyRequests {
+		return 0
+	}
+	var out struct {
+		Parameters struct {
+			RetryAfter int `json:"retry_after"`
+		} `json:"parameters"`
+	}
+	if json.Unmarshal(body, &out) != nil || out.Parameters.RetryAfter <= 0 {
+		return 0
+	}
+	return time.Duration(out.Parameters.RetryAfter) * time.Second
+}
@@ -2,8 +2,10 @@ package syndicate
 
 import (
 	"encoding/json"
+	"net/http"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -39,3 +41,14 @@ func TestBuildTelegramMessage(t *testing.T) {
 		t.Errorf("empty summary should be skipped: %q", noSummary)
 	}
 }
+
+func TestTelegramRetryAfter(t *testing.T) {
+	body := []byte(`{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 37","parameters":{"retry_after":37}}`)
+	if got := telegramRetryAfter(http.StatusTooManyRequests, body); got != 37*time.Second {
+		t.Fatalf("retry after = %s, want 37s", got)
+	}
+	// Only flood control carries a wait; a bad token is just an error.
+	if got := telegramRetryAfter(http.StatusUnauthorized, []byte(`{"ok":false,"error_code":401}`)); got != 0 {
+		t.Fatalf("401 produced a wait of %s", got)
+	}
+}
@@ -2,15 +2,15 @@ package telemetry
 
 import (
 	"context"
-	"crypto/subtle"
+	"fmt"
 	"net/http"
 	"strings"
-	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/riandyrn/otelchi"
 	"go.uber.org/zap"
+	"shanraq.org/internal/httpserver"
 	internaltelemetry "shanraq.org/internal/telemetry"
 	"shanraq.org/pkg/shanraq"
 )
@@ -89,10 +89,7 @@ func (m *Module) guardMetrics(next http.Handler) http.Handler {
 			next.ServeHTTP(w, r)
 			return
 		}
-		const prefix = "Bearer "
-		got := r.Header.Get("Authorization")
-		if len(got) > len(prefix) && strings.EqualFold(got[:len(prefix)], prefix) &&
-			subtle.ConstantTimeCompare([]byte(got[len(prefix):]), []byte(m.metricsToken)) == 1 {
+		if httpserver.BearerAuthorized(r, m.metricsToken) {
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -101,22 +98,22 @@ func (m *Module) guardMetrics(next http.Handler) http.Handler {
 	})
 }
 
-func (m *Module) Start(ctx context.Context, rt *shanraq.Runtime) error {
-	<-ctx.Done()
-
-	if m.tracingShutdown != nil {
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		if err := m.tracingShutdown(shutdownCtx); err != nil && rt.Logger != nil {
-			rt.Logger.Warn("telemetry tracing shutdown", zap.Error(err))
-		}
+// Stop flushes the spans still buffered in the exporter. Other modules stop
+// before this one (it is registered early), so the spans of the jobs drained
+// during shutdown are among those flushed.
+func (m *Module) Stop(ctx context.Context) error {
+	if m.tracingShutdown == nil {
+		return nil
 	}
-	return ctx.Err()
+	if err := m.tracingShutdown(ctx); err != nil {
+		return fmt.Errorf("telemetry tracing shutdown: %w", err)
+	}
+	return nil
 }
 
 var _ interface {
 	shanraq.Module
 	shanraq.RouterModule
 	shanraq.InitializerModule
-	shanraq.StarterModule
+	shanraq.StopperModule
 } = (*Module)(nil)
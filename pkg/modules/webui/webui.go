@@ -5,7 +5,6 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"fmt"
 	"net/http"
 	"strings"
 	"time"
@@ -79,8 +78,9 @@ func WithTenantResolver(resolver TenantResolver) func(*Module) {
 }
 
 // WithAuthGuard injects the middleware that protects the operator console
-// (typically auth.RequireSession(loginPath, "admin", "operator")). Without it,
-// the console and its data partial are not registered at all — fail closed.
+// (typically auth.RequireSessionPermission(loginPath, auth.PermConsoleView)).
+// Without it, the console and its data partial are not registered at all —
+// fail closed.
 func WithAuthGuard(guard func(http.Handler) http.Handler) func(*Module) {
 	return func(m *Module) {
 		m.authGuard = guard
@@ -182,6 +182,7 @@ func (m *Module) handleDashboard(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	data.PageID = "dashboard"
+	data.Health = m.healthSnapshot(r.Context())
 
 	if err := m.renderer.Render(w, "dashboard.html", *data); err != nil {
 		m.rt.Logger.Error("render dashboard", zap.Error(err))
@@ -197,6 +198,7 @@ func (m *Module) handleDashboardPartial(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	data.PageID = "dashboard"
+	data.Health = m.healthSnapshot(r.Context())
 
 	buf := new(bytes.Buffer)
 	if err := m.renderer.Template().ExecuteTemplate(buf, "dashboard-content", *data); err != nil {
@@ -226,8 +228,9 @@ func (m *Module) buildDashboardData(parent context.Context, tenantID *uuid.UUID)
 		"pending": metrics.Pending,
 		"retry":   metrics.Retry,
 		"running": metrics.Running,
-		"failed":  metrics.Failed,
-		"done":    metrics.Done,
+		"failed":    metrics.Failed,
+		"cancelled": metrics.Cancelled,
+		"done":      metrics.Done,
 	}
 	if metrics.Total == 0 && m.jobsData != nil {
 		if fallback, err := m.jobsData.CountByStatus(ctx, tenantID); err == nil {
@@ -236,6 +239,7 @@ func (m *Module) buildDashboardData(parent context.Context, tenantID *uuid.UUID)
 			metrics.Retry = fallback["retry"]
 			metrics.Running = fallback["running"]
 			metrics.Failed = fallback["failed"]
+			metrics.Cancelled = fallback["cancelled"]
 			metrics.Done = fallback["done"]
 			metrics.Total = totalJobs(fallback)
 		}
@@ -451,73 +455,36 @@ func loadAboutContent(db *pgxpool.Pool) *AboutContent {
 	return &content
 }
 
-func (m *Module) healthSnapshot(ctx context.Context, counts map[string]int) []HealthIndicator {
-	indicators := make([]HealthIndicator, 0, 4)
-	status := "Operational"
-	level := "success"
-	description := "database not configured"
-	if m.rt.DB != nil {
-		stat := m.rt.DB.Stat()
-		description = fmt.Sprintf("%d total · %d idle · %d in-use", stat.TotalConns(), stat.IdleConns(), stat.AcquiredConns())
-		if err := m.rt.DB.Ping(ctx); err != nil {
-			status = "Degraded"
-			level = "danger"
-			description = err.Error()
-		}
-	} else {
-		status = "Unknown"
-		level = "warning"
-	}
-	if description == "" {
-		description = "no statistics available"
-	}
-	indicators = append(indicators, HealthIndicator{
-		Name:        "PostgreSQL",
-		Status:      status,
-		Level:       level,
-		Description: description,
-	})
-
-	workerStatus := "Operational"
-	workerLevel := "info"
-	if m.workers == 0 {
-		workerStatus = "Paused"
-		workerLevel = "warning"
-	}
-	indicators = append(indicators, HealthIndicator{
-		Name:        "Workers",
-		Status:      workerStatus,
-		Level:       workerLevel,
-		Description: fmt.Sprintf("%d workers · poll %s", m.workers, m.pollInterval),
-	})
-
-	failed := counts["failed"]
-	if failed > 0 {
-		status = "Degraded"
-		indicators = append(indicators, HealthIndicator{
-			Name:        "Failed Jobs",
-			Status:      fmt.Sprintf("%d pending attention", failed),
-			Level:       "warning",
-			Description: "Investigate recent failures via Jobs API.",
-			Link:        "/jobs",
-		})
-	} else {
-		indicators = append(indicators, HealthIndicator{
-			Name:        "Failed Jobs",
-			Status:      "None",
+// consoleCheckTimeout bounds each health check on the console. Shorter than
+// the readiness probe's: the page renders on a timer and should not stall on
+// one slow dependency.
+const consoleCheckTimeout = 1500 * time.Millisecond
+
+// healthSnapshot shows the same checks /readyz runs, so the console and the
+// orchestrator can never disagree about what is broken. Error text is shown in
+// full: the console is staff-only, unlike the public readiness answer.
+func (m *Module) healthSnapshot(ctx context.Context) []HealthIndicator {
+	results := m.rt.CheckHealth(ctx, consoleCheckTimeout)
+	indicators := make([]HealthIndicator, 0, len(results))
+	for _, res := range results {
+		ind := HealthIndicator{
+			Name:        res.Name,
+			Status:      "Operational",
 			Level:       "success",
-			Description: "Queue is healthy.",
-		})
+			Description: res.Detail,
+		}
+		if !res.OK {
+			ind.Status, ind.Level = "Degraded", "warning"
+			if res.Critical {
+				ind.Status, ind.Level = "Down", "danger"
+			}
+			ind.Description = strings.TrimPrefix(strings.Join([]string{res.Detail, res.Error}, " · "), " · ")
+		}
+		if ind.Description == "" {
+			ind.Description = "no detail"
+		}
+		indicators = append(indicators, ind)
 	}
-
-	indicators = append(indicators, HealthIndicator{
-		Name:        "Telemetry",
-		Status:      "Scrape ready",
-		Level:       "info",
-		Description: "Prometheus endpoint exposed.",
-		Link:        metricsPath(m.rt.Config.Telemetry),
-	})
-
 	return indicators
 }
 
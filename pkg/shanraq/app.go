@@ -2,7 +2,9 @@ package shanraq
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"shanraq.org/internal/config"
 	"shanraq.org/internal/db"
@@ -39,23 +41,67 @@ type InitializerModule interface {
 	Init(ctx context.Context, rt *Runtime) error
 }
 
+// StopperModule is stopped during shutdown, after the HTTP server has drained
+// and in the reverse of the init order, so a module is still running while
+// anything that depends on it is being stopped. The context carries the drain
+// deadline: work not finished by then should be abandoned, recorded where it
+// can be resumed.
+type StopperModule interface {
+	Module
+	Stop(ctx context.Context) error
+}
+
+// DependentModule names the modules that must be initialized before it and
+// stopped after it. Names are those returned by Name.
+type DependentModule interface {
+	Module
+	DependsOn() []string
+}
+
+// defaultShutdownTimeout bounds the HTTP drain and each module's Stop when the
+// configuration leaves server.shutdown_timeout unset.
+const defaultShutdownTimeout = 15 * time.Second
+
 // Runtime is provided to modules with the fully bootstrapped dependencies.
 type Runtime struct {
 	Config config.Config
 	Logger *zap.Logger
 	DB     *pgxpool.Pool
 	Router chi.Router
+	// Events carries domain events between modules; see EventBus.
+	Events *EventBus
+
+	// modules is every registered module in init order, for the hooks that
+	// gather across modules (health checks, Modules).
+	modules []Module
+	reload  *reloader
+}
+
+// Modules returns every registered module in init order. A module that
+// consumes something another one offers — a check, a gate — finds its
+// provider here by the interface it implements, instead of the application
+// handing one to the other by hand.
+func (rt *Runtime) Modules() []Module {
+	if rt == nil {
+		return nil
+	}
+	return rt.modules
 }
 
 // Application wires together configuration, dependencies, and modules.
 type Application struct {
-	cfg     config.Config
-	modules []Module
+	cfg        config.Config
+	modules    []Module
+	loadConfig func() (config.Config, error)
 }
 
 // New builds the application with the provided configuration.
-func New(cfg config.Config) *Application {
-	return &Application{cfg: cfg}
+func New(cfg config.Config, opts ...Option) *Application {
+	a := &Application{cfg: cfg}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
 }
 
 // Register attaches a module to the application.
@@ -64,13 +110,25 @@ func (a *Application) Register(mod Module) {
 }
 
 // Run boots all dependencies and blocks until the context is canceled or an error occurs.
+//
+// Shutdown runs in a fixed order. The HTTP server stops accepting connections
+// and drains the requests already in flight first, because those requests may
+// still enqueue jobs and bump counters. Then StopperModules are stopped in
+// reverse init order, each with its own deadline, and only after that are the
+// Start contexts cancelled. A module's background work therefore outlives the
+// signal long enough to finish what it has started.
 func (a *Application) Run(ctx context.Context) error {
-	logger, err := logging.Build(a.cfg.Logging)
+	logger, level, err := logging.Build(a.cfg.Logging)
 	if err != nil {
 		return err
 	}
 	defer logger.Sync() //nolint:errcheck
 
+	modules, err := orderModules(a.modules)
+	if err != nil {
+		return err
+	}
+
 	pool, err := db.Connect(ctx, a.cfg.Database, logger)
 	if err != nil {
 		return err
@@ -79,10 +137,20 @@ func (a *Application) Run(ctx context.Context) error {
 
 	server := httpserver.New(a.cfg.Server, logger)
 	rt := &Runtime{
-		Config: a.cfg,
-		Logger: logger,
-		DB:     pool,
-		Router: server.Router(),
+		Config:  a.cfg,
+		Logger:  logger,
+		DB:      pool,
+		Router:  server.Router(),
+		Events:  NewEventBus(logger),
+		modules: modules,
+	}
+	rt.reload = &reloader{
+		load:    a.loadConfig,
+		current: a.cfg,
+		level:   level,
+		server:  server,
+		modules: modules,
+		logger:  logger,
 	}
 
 	// 5xx responses hide their cause from the caller (it tends to be raw driver
@@ -92,32 +160,41 @@ func (a *Application) Run(ctx context.Context) error {
 		logger.Error("internal response", zap.Int("status", status), zap.Error(err))
 	}
 
-	for _, mod := range a.modules {
-		if initializer, ok := mod.(InitializerModule); ok {
-			if err := initializer.Init(ctx, rt); err != nil {
-				return fmt.Errorf("%s init: %w", mod.Name(), err)
-			}
-		}
+	timeout := a.cfg.Server.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
 
-		if router, ok := mod.(RouterModule); ok {
-			router.Routes(rt.Router)
-		}
+	if err := initModules(ctx, rt, modules, timeout); err != nil {
+		return err
 	}
 
-	group, groupCtx := errgroup.WithContext(ctx)
+	// Starters run on a context the signal does not reach. It is cancelled by
+	// hand once the drain is over, so a job worker is not torn down while the
+	// request that enqueued its job is still being answered.
+	runCtx, cancelRun := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelRun()
+	group, groupCtx := errgroup.WithContext(runCtx)
 
 	group.Go(func() error {
-		if err := server.Start(groupCtx); err != nil {
+		if err := server.Start(groupCtx); err != nil && !errors.Is(err, context.Canceled) {
 			return fmt.Errorf("http server: %w", err)
 		}
 		return nil
 	})
 
-	for _, mod := range a.modules {
+	if a.loadConfig != nil {
+		group.Go(func() error {
+			rt.watchSIGHUP(groupCtx)
+			return nil
+		})
+	}
+
+	for _, mod := range modules {
 		module := mod
 		if starter, ok := module.(StarterModule); ok {
 			group.Go(func() error {
-				if err := starter.Start(groupCtx, rt); err != nil {
+				if err := starter.Start(groupCtx, rt); err != nil && !errors.Is(err, context.Canceled) {
 					return fmt.Errorf("%s start: %w", module.Name(), err)
 				}
 				return nil
@@ -125,8 +202,98 @@ func (a *Application) Run(ctx context.Context) error {
 		}
 	}
 
+	// Either the caller asked us to stop, or something in the group failed.
+	select {
+	case <-ctx.Done():
+	case <-groupCtx.Done():
+	}
+
+	logger.Info("shutting down", zap.Duration("timeout", timeout))
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), timeout)
+	if err := server.Shutdown(drainCtx); err != nil {
+		logger.Warn("http drain incomplete", zap.Error(err))
+	}
+	cancelDrain()
+
+	stopModules(modules, timeout, logger)
+	cancelRun()
+
 	if err := group.Wait(); err != nil {
 		return err
 	}
+	return ctx.Err()
+}
+
+// Boot is the first half of Run for a caller that brings its own Runtime: it
+// orders the registered modules, initializes them against rt and mounts their
+// routes on rt.Router, and stops there — no database connection of its own, no
+// HTTP server, no Start. rt needs a Logger and a Router; an event bus is added
+// if it has none. The returned stop runs the StopperModules the way shutdown
+// does.
+//
+// It exists for tests that want the application as it is assembled in
+// production without the process around it; package shanraqtest is built on it.
+func (a *Application) Boot(ctx context.Context, rt *Runtime) (stop func(), err error) {
+	modules, err := orderModules(a.modules)
+	if err != nil {
+		return nil, err
+	}
+	if rt.Events == nil {
+		rt.Events = NewEventBus(rt.Logger)
+	}
+	rt.modules = modules
+	timeout := rt.Config.Server.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	if err := initModules(ctx, rt, modules, timeout); err != nil {
+		return nil, err
+	}
+	return func() { stopModules(modules, timeout, rt.Logger) }, nil
+}
+
+// initModules runs Init on each module in order and mounts the routes of each
+// one initialized.
+func initModules(ctx context.Context, rt *Runtime, modules []Module, timeout time.Duration) error {
+	initialized := make([]Module, 0, len(modules))
+	for _, mod := range modules {
+		if initializer, ok := mod.(InitializerModule); ok {
+			if err := initializer.Init(ctx, rt); err != nil {
+				// Modules already up may hold resources (exporters, open
+				// files); give them their stop before giving up.
+				stopModules(initialized, timeout, rt.Logger)
+				return fmt.Errorf("%s init: %w", mod.Name(), err)
+			}
+		}
+		initialized = append(initialized, mod)
+
+		if router, ok := mod.(RouterModule); ok {
+			router.Routes(rt.Router)
+		}
+	}
 	return nil
 }
+
+// stopModules calls Stop on every StopperModule in reverse order, each bounded
+// by its own timeout so one module overrunning its budget cannot eat another's.
+// Failures are logged, not returned: a shutdown that stops at the first error
+// leaves everything after it unstopped.
+func stopModules(mods []Module, timeout time.Duration, logger *zap.Logger) {
+	for i := len(mods) - 1; i >= 0; i-- {
+		stopper, ok := mods[i].(StopperModule)
+		if !ok {
+			continue
+		}
+		stopCtx, cancel := context.WithTimeout(context.Background(), timeout)
+		start := time.Now()
+		err := stopper.Stop(stopCtx)
+		cancel()
+		if err != nil {
+			logger.Warn("module stop", zap.String("module", mods[i].Name()),
+				zap.Duration("took", time.Since(start)), zap.Error(err))
+			continue
+		}
+		logger.Info("module stopped", zap.String("module", mods[i].Name()),
+			zap.Duration("took", time.Since(start)))
+	}
+}
@@ -0,0 +1,276 @@
+package shanraq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Event is a fact one module announces and any number of others react to:
+// "article published", "user deleted". EventName is the stable wire name
+// ("article.published") — durable deliveries are stored under it, so it must
+// not change once events of that type have been queued.
+type Event interface {
+	EventName() string
+}
+
+// EventQueue carries durable deliveries to a queue and back. The jobs module
+// provides one; without it durable subscribers are called in-line like any
+// other (see EventBus.UseQueue).
+type EventQueue interface {
+	EnqueueEvent(ctx context.Context, d EventDelivery) error
+}
+
+// EventDelivery is one event on its way to one durable subscriber. It is what
+// the queue stores, and what it hands back to EventBus.Deliver when the job
+// runs.
+//
+// Key and Window come from the subscriber's DedupeBy: a queue that supports
+// it keeps one delivery per key — while one is waiting or running, or for
+// Window after the first when Window is set. A queue that does not may
+// ignore both.
+type EventDelivery struct {
+	Event      string          `json:"event"`
+	Subscriber string          `json:"subscriber"`
+	Payload    json.RawMessage `json:"payload"`
+	Key        string          `json:"key,omitempty"`
+	Window     time.Duration   `json:"-"`
+}
+
+// ErrUnknownSubscriber is returned by Deliver for a delivery whose subscriber
+// no longer exists — typically one queued before a deploy that removed it.
+var ErrUnknownSubscriber = errors.New("events: no such subscriber")
+
+// EventBus connects publishers to subscribers without either knowing the
+// other. A publisher calls Publish with a typed event; every subscriber to that
+// event's name is called.
+//
+// Two kinds of subscriber:
+//
+//   - Subscribe runs in the publisher's goroutine, before Publish returns. It
+//     is for cheap, in-memory reactions — dropping a cache entry, firing a
+//     detached ping — where a lost call costs nothing.
+//   - SubscribeDurable goes through the queue: Publish stores one delivery per
+//     durable subscriber and returns, and a worker calls the subscriber later,
+//     with the queue's retries. It is for anything that talks to the outside
+//     world and must survive a restart or a flaky upstream — a Telegram post,
+//     a webhook, an e-mail.
+//
+// A nil *EventBus drops everything, so a Runtime built by hand in a test does
+// not need one.
+type EventBus struct {
+	log *zap.Logger
+
+	mu    sync.RWMutex
+	subs  map[string][]subscription
+	queue EventQueue
+}
+
+type subscription struct {
+	// name identifies a durable subscriber across restarts; empty for sync.
+	name   string
+	handle func(ctx context.Context, ev Event) error
+	decode func(payload []byte) (Event, error)
+	// key and window are set by DedupeBy.
+	key    func(ev Event) string
+	window time.Duration
+}
+
+// DurableOption tunes a durable subscriber.
+type DurableOption func(*subscription)
+
+// DedupeBy collapses deliveries of events that key maps to the same string.
+// With a zero window a second delivery is dropped while the first is still
+// waiting or running; with a window, for that long after the first whatever
+// became of it. "Announce an article once, however many times it is
+// published in a day" is DedupeBy(articleID, 24*time.Hour). An empty key
+// leaves that event undeduplicated.
+func DedupeBy[E Event](key func(ev E) string, window time.Duration) DurableOption {
+	return func(s *subscription) {
+		s.key = func(ev Event) string {
+			e, ok := ev.(E)
+			if !ok {
+				return ""
+			}
+			return key(e)
+		}
+		s.window = window
+	}
+}
+
+// NewEventBus returns an empty bus.
+func NewEventBus(log *zap.Logger) *EventBus {
+	if log == nil {
+		log = zap.NewNop()
+	}
+	return &EventBus{log: log, subs: map[string][]subscription{}}
+}
+
+// UseQueue makes q the transport for durable subscribers. Until it is called
+// they are delivered in-line, which keeps an application without a job queue
+// working; it just loses the retries.
+func (b *EventBus) UseQueue(q EventQueue) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	b.queue = q
+	b.mu.Unlock()
+}
+
+// Subscribe calls handler for every published E, in the publisher's goroutine.
+// Modules subscribe from Init. A handler error is logged and reported to the
+// publisher; it never stops the remaining subscribers.
+func Subscribe[E Event](b *EventBus, handler func(ctx context.Context, ev E) error) {
+	b.add(subscription{handle: typed(handler)}, eventName[E]())
+}
+
+// SubscribeDurable calls handler for every published E through the queue.
+// subscriber names the handler in stored deliveries ("syndicate.telegram") and
+// must be unique per event; like a job name it should outlive refactors.
+func SubscribeDurable[E Event](b *EventBus, subscriber string, handler func(ctx context.Context, ev E) error, opts ...DurableOption) {
+	if subscriber == "" {
+		panic("shanraq: durable subscriber needs a name")
+	}
+	sub := subscription{
+		name:   subscriber,
+		handle: typed(handler),
+		decode: func(payload []byte) (Event, error) {
+			var ev E
+			if err := json.Unmarshal(payload, &ev); err != nil {
+				return nil, err
+			}
+			return ev, nil
+		},
+	}
+	for _, opt := range opts {
+		opt(&sub)
+	}
+	b.add(sub, eventName[E]())
+}
+
+func (b *EventBus) add(sub subscription, name string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if sub.name != "" {
+		for _, s := range b.subs[name] {
+			if s.name == sub.name {
+				// Two handlers under one name would share every stored
+				// delivery, and only one of them would ever see it.
+				panic(fmt.Sprintf("shanraq: durable subscriber %q registered twice for %s", sub.name, name))
+			}
+		}
+	}
+	b.subs[name] = append(b.subs[name], sub)
+}
+
+// Publish announces ev. Sync subscribers have run by the time it returns;
+// durable ones have been queued. The error joins every subscriber failure and
+// every failed enqueue — publishers treat it as a side effect to log, never
+// as a reason to undo what they just committed.
+func (b *EventBus) Publish(ctx context.Context, ev Event) error {
+	if b == nil {
+		return nil
+	}
+	name := ev.EventName()
+	b.mu.RLock()
+	subs := b.subs[name]
+	queue := b.queue
+	b.mu.RUnlock()
+	if len(subs) == 0 {
+		return nil
+	}
+
+	var payload json.RawMessage
+	var errs []error
+	for _, sub := range subs {
+		if sub.name == "" || queue == nil {
+			if err := b.call(ctx, name, sub, ev); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		if payload == nil {
+			p, err := json.Marshal(ev)
+			if err != nil {
+				return fmt.Errorf("events: encode %s: %w", name, err)
+			}
+			payload = p
+		}
+		d := EventDelivery{Event: name, Subscriber: sub.name, Payload: payload}
+		if sub.key != nil {
+			d.Key, d.Window = sub.key(ev), sub.window
+		}
+		if err := queue.EnqueueEvent(ctx, d); err != nil {
+			errs = append(errs, fmt.Errorf("events: queue %s for %s: %w", name, sub.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Deliver runs one stored delivery. The queue calls it from its worker; the
+// error is the subscriber's, for the queue to retry on.
+func (b *EventBus) Deliver(ctx context.Context, d EventDelivery) error {
+	if b == nil {
+		return ErrUnknownSubscriber
+	}
+	var sub subscription
+	b.mu.RLock()
+	for _, s := range b.subs[d.Event] {
+		if s.name == d.Subscriber {
+			sub = s
+			break
+		}
+	}
+	b.mu.RUnlock()
+	if sub.decode == nil {
+		return fmt.Errorf("%w: %s for %s", ErrUnknownSubscriber, d.Subscriber, d.Event)
+	}
+	ev, err := sub.decode(d.Payload)
+	if err != nil {
+		return fmt.Errorf("events: decode %s: %w", d.Event, err)
+	}
+	return b.call(ctx, d.Event, sub, ev)
+}
+
+// call runs one handler, turning a panic into an error: one broken subscriber
+// must not take the publisher's request down with it.
+func (b *EventBus) call(ctx context.Context, name string, sub subscription, ev Event) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("events: %s subscriber panicked: %v", name, r)
+		}
+		if err != nil {
+			b.log.Warn("event subscriber failed",
+				zap.String("event", name),
+				zap.String("subscriber", sub.name),
+				zap.Error(err))
+		}
+	}()
+	return sub.handle(ctx, ev)
+}
+
+func typed[E Event](handler func(ctx context.Context, ev E) error) func(context.Context, Event) error {
+	return func(ctx context.Context, ev Event) error {
+		e, ok := ev.(E)
+		if !ok {
+			return fmt.Errorf("events: %s delivered as %T", ev.EventName(), ev)
+		}
+		return handler(ctx, e)
+	}
+}
+
+// eventName reads the wire name off E's zero value, so event types must
+// implement EventName on the value receiver.
+func eventName[E Event]() string {
+	var zero E
+	return zero.EventName()
+}
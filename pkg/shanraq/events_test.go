@@ -0,0 +1,149 @@
+package shanraq
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type testPublished struct {
+	ID string `json:"id"`
+}
+
+func (testPublished) EventName() string { return "test.published" }
+
+type testOther struct{}
+
+func (testOther) EventName() string { return "test.other" }
+
+type memQueue []EventDelivery
+
+func (q *memQueue) EnqueueEvent(_ context.Context, d EventDelivery) error {
+	*q = append(*q, d)
+	return nil
+}
+
+func TestEventBusSyncSubscribers(t *testing.T) {
+	bus := NewEventBus(nil)
+	var got []string
+	Subscribe(bus, func(_ context.Context, ev testPublished) error {
+		got = append(got, "first:"+ev.ID)
+		return errors.New("boom")
+	})
+	Subscribe(bus, func(_ context.Context, ev testPublished) error {
+		got = append(got, "second:"+ev.ID)
+		return nil
+	})
+	Subscribe(bus, func(context.Context, testOther) error {
+		t.Fatal("subscriber of another event was called")
+		return nil
+	})
+
+	err := bus.Publish(context.Background(), testPublished{ID: "a1"})
+	if err == nil {
+		t.Fatal("a failing subscriber should be reported to the publisher")
+	}
+	if len(got) != 2 || got[0] != "first:a1" || got[1] != "second:a1" {
+		t.Fatalf("subscribers ran as %v; a failure must not stop the rest", got)
+	}
+}
+
+func TestEventBusDurableRoundTrip(t *testing.T) {
+	bus := NewEventBus(nil)
+	var queue memQueue
+	bus.UseQueue(&queue)
+	var delivered []string
+	SubscribeDurable(bus, "test.sink", func(_ context.Context, ev testPublished) error {
+		delivered = append(delivered, ev.ID)
+		return nil
+	})
+
+	if err := bus.Publish(context.Background(), testPublished{ID: "b2"}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if len(delivered) != 0 {
+		t.Fatal("durable subscriber ran in the publisher's goroutine")
+	}
+	if len(queue) != 1 || queue[0].Subscriber != "test.sink" || queue[0].Event != "test.published" {
+		t.Fatalf("unexpected queue: %+v", queue)
+	}
+
+	if err := bus.Deliver(context.Background(), queue[0]); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+	if len(delivered) != 1 || delivered[0] != "b2" {
+		t.Fatalf("delivered %v, want [b2]", delivered)
+	}
+
+	stale := EventDelivery{Event: "test.published", Subscriber: "gone", Payload: queue[0].Payload}
+	if err := bus.Deliver(context.Background(), stale); !errors.Is(err, ErrUnknownSubscriber) {
+		t.Fatalf("stale delivery: got %v, want ErrUnknownSubscriber", err)
+	}
+}
+
+func TestEventBusDurableDedupeKey(t *testing.T) {
+	bus := NewEventBus(nil)
+	var queue memQueue
+	bus.UseQueue(&queue)
+	SubscribeDurable(bus, "test.keyed", func(context.Context, testPublished) error { return nil },
+		DedupeBy(func(ev testPublished) string { return ev.ID }, time.Hour))
+	SubscribeDurable(bus, "test.plain", func(context.Context, testPublished) error { return nil })
+
+	if err := bus.Publish(context.Background(), testPublished{ID: "c3"}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if len(queue) != 2 {
+		t.Fatalf("unexpected queue: %+v", queue)
+	}
+	if queue[0].Key != "c3" || queue[0].Window != time.Hour {
+		t.Fatalf("keyed delivery = %+v", queue[0])
+	}
+	if queue[1].Key != "" {
+		t.Fatalf("a subscriber without DedupeBy got key %q", queue[1].Key)
+	}
+}
+
+func TestEventBusDurableWithoutQueueRunsInline(t *testing.T) {
+	bus := NewEventBus(nil)
+	ran := false
+	SubscribeDurable(bus, "test.sink", func(context.Context, testPublished) error {
+		ran = true
+		return nil
+	})
+	if err := bus.Publish(context.Background(), testPublished{}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if !ran {
+		t.Fatal("without a queue a durable subscriber should run in-line")
+	}
+}
+
+func TestEventBusRecoversSubscriberPanic(t *testing.T) {
+	bus := NewEventBus(nil)
+	Subscribe(bus, func(context.Context, testPublished) error { panic("nil map") })
+	if err := bus.Publish(context.Background(), testPublished{}); err == nil {
+		t.Fatal("a panicking subscriber should surface as an error")
+	}
+}
+
+func TestEventBusRejectsDuplicateDurableName(t *testing.T) {
+	bus := NewEventBus(nil)
+	handler := func(context.Context, testPublished) error { return nil }
+	SubscribeDurable(bus, "test.sink", handler)
+	defer func() {
+		if recover() == nil {
+			t.Fatal("registering the same durable subscriber twice should panic")
+		}
+	}()
+	SubscribeDurable(bus, "test.sink", handler)
+}
+
+func TestNilEventBusIsInert(t *testing.T) {
+	var bus *EventBus
+	Subscribe(bus, func(context.Context, testPublished) error { return nil })
+	bus.UseQueue(&memQueue{})
+	if err := bus.Publish(context.Background(), testPublished{}); err != nil {
+		t.Fatalf("nil bus publish: %v", err)
+	}
+}
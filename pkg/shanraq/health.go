@@ -0,0 +1,105 @@
+package shanraq
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// HealthChecker contributes readiness checks. The health module aggregates
+// them into /readyz and the operator console shows the same results, so a
+// module states once what "working" means for it.
+type HealthChecker interface {
+	Module
+	HealthChecks() []HealthCheck
+}
+
+// HealthCheck is one named probe.
+//
+// A failing Critical check takes the instance out of rotation (/readyz answers
+// 503): the database is critical, because nothing works without it. Everything
+// else — a wedged queue, an unreachable SMTP relay, an AI provider answering
+// 401 — reports degraded and keeps serving, since readers can still read.
+type HealthCheck struct {
+	Name     string
+	Critical bool
+	// Run returns a short operator-facing detail ("3 due, oldest 4m") and an
+	// error when the thing checked is not healthy. It must honour ctx.
+	Run func(ctx context.Context) (string, error)
+}
+
+// HealthResult is the outcome of one HealthCheck.
+type HealthResult struct {
+	Name     string        `json:"name"`
+	Critical bool          `json:"critical"`
+	OK       bool          `json:"ok"`
+	Detail   string        `json:"detail,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration_ns"`
+}
+
+// HealthChecks collects the checks of every registered module that offers any,
+// in module order.
+func (rt *Runtime) HealthChecks() []HealthCheck {
+	var checks []HealthCheck
+	for _, mod := range rt.modules {
+		if checker, ok := mod.(HealthChecker); ok {
+			checks = append(checks, checker.HealthChecks()...)
+		}
+	}
+	return checks
+}
+
+// CheckHealth runs every module's checks; see RunHealthChecks.
+func (rt *Runtime) CheckHealth(ctx context.Context, timeout time.Duration) []HealthResult {
+	return RunHealthChecks(ctx, rt.HealthChecks(), timeout)
+}
+
+// RunHealthChecks runs the checks concurrently, each bounded by timeout, and
+// returns the results in the order given. A check that overruns is reported
+// failed with the context error rather than holding up the others: a readiness
+// probe that hangs is read by the orchestrator as a failed one anyway.
+func RunHealthChecks(ctx context.Context, checks []HealthCheck, timeout time.Duration) []HealthResult {
+	results := make([]HealthResult, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = runHealthCheck(ctx, check, timeout)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+func runHealthCheck(parent context.Context, check HealthCheck, timeout time.Duration) HealthResult {
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+
+	type outcome struct {
+		detail string
+		err    error
+	}
+	done := make(chan outcome, 1)
+	start := time.Now()
+	go func() {
+		detail, err := check.Run(ctx)
+		done <- outcome{detail, err}
+	}()
+
+	res := HealthResult{Name: check.Name, Critical: check.Critical}
+	select {
+	case out := <-done:
+		res.Detail = out.detail
+		if out.err != nil {
+			res.Error = out.err.Error()
+		} else {
+			res.OK = true
+		}
+	case <-ctx.Done():
+		res.Error = ctx.Err().Error()
+	}
+	res.Duration = time.Since(start)
+	return res
+}
@@ -0,0 +1,71 @@
+package shanraq
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type checkerModule struct {
+	stubModule
+	checks []HealthCheck
+}
+
+func (m *checkerModule) HealthChecks() []HealthCheck { return m.checks }
+
+func TestRunHealthChecksKeepsOrderAndBoundsSlowChecks(t *testing.T) {
+	checks := []HealthCheck{
+		{Name: "slow", Run: func(ctx context.Context) (string, error) {
+			<-ctx.Done()
+			return "", ctx.Err()
+		}},
+		{Name: "broken", Critical: true, Run: func(context.Context) (string, error) {
+			return "db01:5432", errors.New("connection refused")
+		}},
+		{Name: "fine", Run: func(context.Context) (string, error) { return "3 due", nil }},
+	}
+
+	start := time.Now()
+	results := RunHealthChecks(context.Background(), checks, 50*time.Millisecond)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("checks took %s; the slow one should have been cut off", elapsed)
+	}
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+	for i, name := range []string{"slow", "broken", "fine"} {
+		if results[i].Name != name {
+			t.Fatalf("result %d is %q, want %q", i, results[i].Name, name)
+		}
+	}
+	if results[0].OK || results[0].Error == "" {
+		t.Fatalf("slow check should fail with the deadline: %+v", results[0])
+	}
+	if results[1].OK || !results[1].Critical || results[1].Detail != "db01:5432" {
+		t.Fatalf("broken check lost its detail or criticality: %+v", results[1])
+	}
+	if !results[2].OK || results[2].Detail != "3 due" {
+		t.Fatalf("fine check: %+v", results[2])
+	}
+}
+
+func TestRuntimeHealthChecksFollowModuleOrder(t *testing.T) {
+	first := &checkerModule{stubModule: stubModule{name: "a"}, checks: []HealthCheck{{Name: "a1"}, {Name: "a2"}}}
+	second := &checkerModule{stubModule: stubModule{name: "b"}, checks: []HealthCheck{{Name: "b1"}}}
+	rt := &Runtime{modules: []Module{first, &stubModule{name: "plain"}, second}}
+
+	var got []string
+	for _, c := range rt.HealthChecks() {
+		got = append(got, c.Name)
+	}
+	want := []string{"a1", "a2", "b1"}
+	if len(got) != len(want) {
+		t.Fatalf("checks = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("checks = %v, want %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package shanraq
+
+import (
+	"fmt"
+	"strings"
+)
+
+// orderModules returns the modules in an order where every module comes after
+// the modules it depends on. Among modules free to go at the same point the
+// registration order wins, so an application that declares nothing boots
+// exactly as it did before dependencies existed.
+//
+// A dependency on a name nobody registered is an error rather than something to
+// skip: a module that says it needs the mailer and boots without one fails in a
+// request hours later, not at startup where it can be read.
+func orderModules(mods []Module) ([]Module, error) {
+	index := make(map[string]int, len(mods))
+	for i, mod := range mods {
+		name := mod.Name()
+		if _, dup := index[name]; dup {
+			return nil, fmt.Errorf("module %q registered twice", name)
+		}
+		index[name] = i
+	}
+
+	deps := make([][]int, len(mods))
+	for i, mod := range mods {
+		dependent, ok := mod.(DependentModule)
+		if !ok {
+			continue
+		}
+		for _, name := range dependent.DependsOn() {
+			j, found := index[name]
+			if !found {
+				return nil, fmt.Errorf("module %q depends on %q, which is not registered", mod.Name(), name)
+			}
+			if j == i {
+				return nil, fmt.Errorf("module %q depends on itself", name)
+			}
+			deps[i] = append(deps[i], j)
+		}
+	}
+
+	placed := make([]bool, len(mods))
+	ordered := make([]Module, 0, len(mods))
+	for len(ordered) < len(mods) {
+		progress := false
+		for i, mod := range mods {
+			if placed[i] || !allPlaced(deps[i], placed) {
+				continue
+			}
+			placed[i] = true
+			ordered = append(ordered, mod)
+			progress = true
+			// Restart from the top so an earlier-registered module that has
+			// just become free goes before a later one.
+			break
+		}
+		if !progress {
+			var stuck []string
+			for i, mod := range mods {
+				if !placed[i] {
+					stuck = append(stuck, mod.Name())
+				}
+			}
+			return nil, fmt.Errorf("module dependency cycle among: %s", strings.Join(stuck, ", "))
+		}
+	}
+	return ordered, nil
+}
+
+func allPlaced(deps []int, placed []bool) bool {
+	for _, j := range deps {
+		if !placed[j] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,72 @@
+package shanraq
+
+import (
+	"strings"
+	"testing"
+)
+
+type stubModule struct {
+	name string
+	deps []string
+}
+
+func (s stubModule) Name() string        { return s.name }
+func (s stubModule) DependsOn() []string { return s.deps }
+
+func names(mods []Module) string {
+	out := make([]string, len(mods))
+	for i, m := range mods {
+		out[i] = m.Name()
+	}
+	return strings.Join(out, ",")
+}
+
+// With nothing declared, the order is the registration order: an application
+// that predates dependencies must boot exactly as it used to.
+func TestOrderModulesKeepsRegistrationOrder(t *testing.T) {
+	got, err := orderModules([]Module{stubModule{name: "a"}, stubModule{name: "b"}, stubModule{name: "c"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names(got) != "a,b,c" {
+		t.Fatalf("order = %s, want a,b,c", names(got))
+	}
+}
+
+// A module registered before what it needs is moved after it, and nothing else
+// moves: articles was registered first here, so it goes as soon as its last
+// dependency is placed, ahead of modules registered after it.
+func TestOrderModulesMovesDependentsAfterDependencies(t *testing.T) {
+	got, err := orderModules([]Module{
+		stubModule{name: "articles", deps: []string{"auth", "media"}},
+		stubModule{name: "auth"},
+		stubModule{name: "media", deps: []string{"auth"}},
+		stubModule{name: "webui"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names(got) != "auth,media,articles,webui" {
+		t.Fatalf("order = %s, want auth,media,articles,webui", names(got))
+	}
+}
+
+func TestOrderModulesRejectsBadGraphs(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		mods []Module
+		want string
+	}{
+		{"missing", []Module{stubModule{name: "a", deps: []string{"mailer"}}}, "not registered"},
+		{"cycle", []Module{stubModule{name: "a", deps: []string{"b"}}, stubModule{name: "b", deps: []string{"a"}}}, "cycle"},
+		{"self", []Module{stubModule{name: "a", deps: []string{"a"}}}, "itself"},
+		{"duplicate", []Module{stubModule{name: "a"}, stubModule{name: "a"}}, "twice"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := orderModules(c.mods)
+			if err == nil || !strings.Contains(err.Error(), c.want) {
+				t.Fatalf("err = %v, want it to mention %q", err, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package shanraq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+
+	"go.uber.org/zap"
+	"shanraq.org/internal/config"
+	"shanraq.org/internal/httpserver"
+	"shanraq.org/internal/logging"
+)
+
+// Reconfigurable modules apply configuration changes while running. A reload
+// calls Reconfigure on each of them, in init order, with the new configuration
+// and the list of what changed; a module looks for its own keys with
+// changes.Touches and ignores the rest.
+//
+// Runtime.Config keeps the configuration the process booted with — it is read
+// from every request handler, and swapping it underneath them would be a data
+// race. A module that wants a setting to be live keeps its own copy and
+// replaces it here, safely for its concurrent readers.
+type Reconfigurable interface {
+	Module
+	Reconfigure(ctx context.Context, cfg config.Config, changes config.Changes) error
+}
+
+// ErrRestartRequired rejects a reload that changes a setting tagged
+// reload:"restart". Nothing is applied.
+var ErrRestartRequired = errors.New("restart required")
+
+// ErrReloadDisabled is returned when the application was built without
+// WithConfigReload.
+var ErrReloadDisabled = errors.New("config reload not enabled")
+
+// Option customizes an Application.
+type Option func(*Application)
+
+// WithConfigReload enables SIGHUP and Runtime.ReloadConfig. load re-reads the
+// configuration the way the process first did — file plus environment — and
+// must validate it; config.Load does both.
+func WithConfigReload(load func() (config.Config, error)) Option {
+	return func(a *Application) {
+		a.loadConfig = load
+	}
+}
+
+// reloader holds what a reload needs and serializes reloads: two SIGHUPs in a
+// row must not diff against the same running config.
+type reloader struct {
+	mu      sync.Mutex
+	load    func() (config.Config, error)
+	current config.Config
+	level   zap.AtomicLevel
+	server  *httpserver.Server
+	modules []Module
+	logger  *zap.Logger
+}
+
+// ReloadConfig re-reads the configuration, and if it is valid and changes
+// nothing that needs a restart, applies it: the log level and trusted proxies
+// here, the rest through the Reconfigurable modules. It returns what changed.
+//
+// A module that fails to apply its part does not stop the others, and the new
+// configuration still becomes the one later reloads diff against: the file is
+// the truth, and the next reload should not re-announce settings that were
+// already handed out.
+func (rt *Runtime) ReloadConfig(ctx context.Context) (config.Changes, error) {
+	r := rt.reload
+	if r == nil || r.load == nil {
+		return nil, ErrReloadDisabled
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+	changes := config.Diff(r.current, next)
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	if restart := changes.RestartOnly(); len(restart) > 0 {
+		return changes, fmt.Errorf("%w to change %s", ErrRestartRequired, strings.Join(restart, ", "))
+	}
+	// Checked before anything is applied, so a typo in the level does not
+	// leave the reload half done.
+	level, err := logging.ParseLevel(next.Logging.Level)
+	if err != nil {
+		return changes, fmt.Errorf("logging.level: %w", err)
+	}
+
+	if changes.Touches("logging.level") {
+		r.level.SetLevel(level)
+	}
+	if changes.Touches("server.trusted_proxies") && r.server != nil {
+		r.server.SetTrustedProxies(next.Server.TrustedProxies)
+	}
+	var errs []error
+	for _, mod := range r.modules {
+		rc, ok := mod.(Reconfigurable)
+		if !ok {
+			continue
+		}
+		if err := rc.Reconfigure(ctx, next, changes); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", mod.Name(), err))
+		}
+	}
+	r.current = next
+	r.logger.Info("configuration reloaded", zap.Strings("changed", changes.Paths()))
+	return changes, errors.Join(errs...)
+}
+
+// watchSIGHUP reloads on every SIGHUP until ctx ends. The outcome only goes to
+// the log: a signal has nobody to answer.
+func (rt *Runtime) watchSIGHUP(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			changes, err := rt.ReloadConfig(ctx)
+			switch {
+			case err != nil:
+				rt.Logger.Error("configuration reload failed", zap.Error(err))
+			case len(changes) == 0:
+				rt.Logger.Info("configuration reload: nothing changed")
+			}
+		}
+	}
+}
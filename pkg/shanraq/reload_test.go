@@ -0,0 +1,98 @@
+package shanraq
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"shanraq.org/internal/config"
+)
+
+type reconfigModule struct {
+	stubModule
+	calls   int
+	changes config.Changes
+}
+
+func (m *reconfigModule) Reconfigure(_ context.Context, _ config.Config, changes config.Changes) error {
+	m.calls++
+	m.changes = changes
+	return nil
+}
+
+func reloadRuntime(current config.Config, next *config.Config, mods ...Module) (*Runtime, zap.AtomicLevel) {
+	level := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	rt := &Runtime{Logger: zap.NewNop()}
+	rt.reload = &reloader{
+		load:    func() (config.Config, error) { return *next, nil },
+		current: current,
+		level:   level,
+		modules: mods,
+		logger:  zap.NewNop(),
+	}
+	return rt, level
+}
+
+func TestReloadAppliesLevelAndNotifiesModules(t *testing.T) {
+	var running config.Config
+	running.Logging.Level = "info"
+	next := running
+	next.Logging.Level = "debug"
+	next.Syndicate.Telegram.ChatID = "@shanraq"
+
+	mod := &reconfigModule{stubModule: stubModule{name: "syndicate"}}
+	rt, level := reloadRuntime(running, &next, mod)
+
+	changes, err := rt.ReloadConfig(context.Background())
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if level.Level() != zapcore.DebugLevel {
+		t.Fatalf("level = %s, want debug", level.Level())
+	}
+	if mod.calls != 1 || !mod.changes.Touches("syndicate.telegram.chat_id") {
+		t.Fatalf("module saw %d calls with %v", mod.calls, mod.changes.Paths())
+	}
+	if len(changes) != 2 {
+		t.Fatalf("changes = %v", changes.Paths())
+	}
+
+	// The applied config is the new baseline: reloading again changes nothing.
+	changes, err = rt.ReloadConfig(context.Background())
+	if err != nil || len(changes) != 0 || mod.calls != 1 {
+		t.Fatalf("second reload: changes=%v err=%v calls=%d", changes.Paths(), err, mod.calls)
+	}
+}
+
+// One restart-only key poisons the whole reload: nothing is applied, not even
+// the settings that could have been.
+func TestReloadRejectsRestartOnlyChanges(t *testing.T) {
+	var running config.Config
+	running.Logging.Level = "info"
+	next := running
+	next.Logging.Level = "debug"
+	next.Database.URL = "postgres://elsewhere/shanraq"
+
+	mod := &reconfigModule{stubModule: stubModule{name: "notifier"}}
+	rt, level := reloadRuntime(running, &next, mod)
+
+	changes, err := rt.ReloadConfig(context.Background())
+	if !errors.Is(err, ErrRestartRequired) {
+		t.Fatalf("err = %v, want ErrRestartRequired", err)
+	}
+	if got := changes.RestartOnly(); len(got) != 1 || got[0] != "database.url" {
+		t.Fatalf("restart-only = %v", got)
+	}
+	if level.Level() != zapcore.InfoLevel || mod.calls != 0 {
+		t.Fatalf("rejected reload applied something: level=%s calls=%d", level.Level(), mod.calls)
+	}
+}
+
+func TestReloadDisabledWithoutLoader(t *testing.T) {
+	rt := &Runtime{Logger: zap.NewNop()}
+	if _, err := rt.ReloadConfig(context.Background()); !errors.Is(err, ErrReloadDisabled) {
+		t.Fatalf("err = %v, want ErrReloadDisabled", err)
+	}
+}
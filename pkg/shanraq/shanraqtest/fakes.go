@@ -0,0 +1,172 @@
+package shanraqtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"shanraq.org/pkg/modules/ai"
+)
+
+// Mail is one captured e-mail.
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+	Headers map[string]string
+}
+
+// Mailbox captures mail instead of sending it. It satisfies the Mailer
+// interface of every module that sends mail, and the notifier's HeaderMailer.
+type Mailbox struct {
+	mu   sync.Mutex
+	sent []Mail
+}
+
+func (b *Mailbox) Send(ctx context.Context, to, subject, body string) error {
+	return b.SendWithHeaders(ctx, to, subject, body, nil)
+}
+
+func (b *Mailbox) SendWithHeaders(_ context.Context, to, subject, body string, headers map[string]string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sent = append(b.sent, Mail{To: to, Subject: subject, Body: body, Headers: headers})
+	return nil
+}
+
+// Sent returns every message so far, oldest first.
+func (b *Mailbox) Sent() []Mail {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]Mail(nil), b.sent...)
+}
+
+// To returns the messages sent to one address, compared case-insensitively.
+func (b *Mailbox) To(addr string) []Mail {
+	var out []Mail
+	for _, m := range b.Sent() {
+		if strings.EqualFold(m.To, addr) {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// SMS is one captured text message.
+type SMS struct {
+	Phone string
+	Text  string
+}
+
+// SMSOutbox captures text messages; it satisfies auth.SMSSender.
+type SMSOutbox struct {
+	mu   sync.Mutex
+	sent []SMS
+}
+
+func (o *SMSOutbox) SendSMS(_ context.Context, phone, text string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.sent = append(o.sent, SMS{Phone: phone, Text: text})
+	return nil
+}
+
+// Sent returns every message so far, oldest first.
+func (o *SMSOutbox) Sent() []SMS {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]SMS(nil), o.sent...)
+}
+
+// TelegramMessage is one captured Bot API sendMessage call.
+type TelegramMessage struct {
+	ChatID string
+	Text   string
+}
+
+// TelegramOutbox stands in for the Telegram Bot API. Hand its Client to a
+// module (syndicate.WithHTTPClient) and every sendMessage lands here. Any other
+// outbound request fails, so a test never reaches the network by accident.
+type TelegramOutbox struct {
+	mu   sync.Mutex
+	sent []TelegramMessage
+}
+
+// Client returns an HTTP client that routes through the outbox.
+func (o *TelegramOutbox) Client() *http.Client {
+	return &http.Client{Transport: o}
+}
+
+// RoundTrip answers sendMessage the way the Bot API does and refuses
+// everything else.
+func (o *TelegramOutbox) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.URL.Host != "api.telegram.org" || !strings.HasSuffix(r.URL.Path, "/sendMessage") {
+		return nil, fmt.Errorf("shanraqtest: unexpected outbound request to %s", r.URL.Redacted())
+	}
+	var msg struct {
+		ChatID json.RawMessage `json:"chat_id"`
+		Text   string          `json:"text"`
+	}
+	if r.Body != nil {
+		defer r.Body.Close()
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			return nil, fmt.Errorf("shanraqtest: decode sendMessage: %w", err)
+		}
+	}
+	// chat_id is a number or an @channel name.
+	chat := strings.Trim(string(msg.ChatID), `"`)
+	o.mu.Lock()
+	o.sent = append(o.sent, TelegramMessage{ChatID: chat, Text: msg.Text})
+	o.mu.Unlock()
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader([]byte(`{"ok":true,"result":{"message_id":1}}`))),
+		Request:    r,
+	}, nil
+}
+
+// Sent returns every message so far, oldest first.
+func (o *TelegramOutbox) Sent() []TelegramMessage {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]TelegramMessage(nil), o.sent...)
+}
+
+// ErrNoReply is what FakeAI returns when Reply is unset.
+var ErrNoReply = errors.New("shanraqtest: FakeAI has no reply scripted")
+
+// FakeAI is a scripted model; pass it to ai.New(ai.WithCompleter(h.AI)). Set
+// Reply before the first request. Every request is recorded.
+type FakeAI struct {
+	Reply func(req ai.Request) (string, error)
+
+	mu    sync.Mutex
+	calls []ai.Request
+}
+
+func (f *FakeAI) Complete(_ context.Context, req ai.Request) (string, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, req)
+	reply := f.Reply
+	f.mu.Unlock()
+	if reply == nil {
+		return "", ErrNoReply
+	}
+	return reply(req)
+}
+
+// Calls returns every request so far, oldest first.
+func (f *FakeAI) Calls() []ai.Request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]ai.Request(nil), f.calls...)
+}
+
+var _ ai.Completer = (*FakeAI)(nil)
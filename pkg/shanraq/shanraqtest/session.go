@@ -0,0 +1,105 @@
+package shanraqtest
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+	"shanraq.org/pkg/modules/auth"
+)
+
+// Session is a signed-in test account.
+type Session struct {
+	UserID uuid.UUID
+	Email  string
+	Role   string
+	// Cookie is the browser session, as the studio login sets it.
+	Cookie *http.Cookie
+	// Token is the same access token, for API calls with a Bearer header.
+	Token string
+}
+
+// AsUser sends the request with the session's cookie.
+func AsUser(s Session) RequestOption {
+	return WithCookie(s.Cookie)
+}
+
+// LoginAs signs in an account with the given primary role ("user", "editor",
+// "admin", ...). It needs the auth module registered and booted.
+//
+// With a database the account is real — registered through the auth module,
+// role set through its store — so role checks that go back to the database
+// see it as they would in production. It is deleted, with everything
+// cascading from it, when the test ends.
+//
+// Without one the account exists only in its token, signed with the
+// runtime's secret: handlers that read the claims (LoadSession,
+// ClaimsFromContext) see the role, but the guards that ask the database
+// whether the account still holds it (RequireSession with roles,
+// RequireRoles, RequirePermission) turn it away, as they would an account
+// deleted since.
+func (h *Harness) LoginAs(role string) Session {
+	h.T.Helper()
+	var authM *auth.Module
+	for _, mod := range h.modules {
+		if m, ok := mod.(*auth.Module); ok {
+			authM = m
+			break
+		}
+	}
+	if authM == nil || !h.booted {
+		h.T.Fatal("shanraqtest: LoginAs needs the auth module registered and booted")
+	}
+	email := "shanraqtest-" + uuid.NewString()[:12] + "@example.test"
+	if h.Runtime.DB == nil {
+		return h.tokenOnly(email, role)
+	}
+
+	ctx := context.Background()
+	password := "pw-" + uuid.NewString()
+	user, _, err := authM.RegisterPassword(ctx, email, password, "Тест", "Пользователь", "")
+	if err != nil {
+		h.T.Fatalf("LoginAs(%s): register: %v", role, err)
+	}
+	h.T.Cleanup(func() {
+		_, _ = h.Runtime.DB.Exec(context.Background(), `DELETE FROM auth_users WHERE id = $1`, user.ID)
+	})
+	if role != "" && role != "user" {
+		if _, err := auth.NewStore(h.Runtime.DB).SetPrimaryRole(ctx, email, role); err != nil {
+			h.T.Fatalf("LoginAs(%s): set role: %v", role, err)
+		}
+	}
+	// Signed in after the role change, so the token carries the role and the
+	// account's current revocation version.
+	user, token, err := authM.LoginPassword(ctx, email, password)
+	if err != nil {
+		h.T.Fatalf("LoginAs(%s): login: %v", role, err)
+	}
+	return Session{
+		UserID: user.ID,
+		Email:  email,
+		Role:   user.Role,
+		Cookie: &http.Cookie{Name: auth.SessionCookieName, Value: token},
+		Token:  token,
+	}
+}
+
+// tokenOnly signs a token for an account that is in no database.
+func (h *Harness) tokenOnly(email, role string) Session {
+	if role == "" {
+		role = "user"
+	}
+	user := auth.User{ID: uuid.New(), Email: email, Role: role, Roles: []string{role}, AuthVersion: 1}
+	cfg := h.Runtime.Config.Auth
+	token, err := auth.NewTokenService(cfg.TokenSecret, cfg.TokenTTL).Generate(user)
+	if err != nil {
+		h.T.Fatalf("LoginAs(%s): sign token: %v", role, err)
+	}
+	return Session{
+		UserID: user.ID,
+		Email:  email,
+		Role:   role,
+		Cookie: &http.Cookie{Name: auth.SessionCookieName, Value: token},
+		Token:  token,
+	}
+}
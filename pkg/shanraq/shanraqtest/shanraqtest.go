@@ -0,0 +1,279 @@
+// Package shanraqtest boots shanraq modules inside a test: the same Init
+// order, the same routes, the same event bus as production, but no process, no
+// listening socket and no outside world. Mail, SMS and Telegram posts are
+// captured instead of sent, the AI answers from a script, and jobs run when the
+// test says so rather than when a worker polls.
+//
+// A harness is assembled the way cmd/app/main.go assembles the application —
+// the test constructs the modules, hands them the harness's fakes, registers
+// them and boots:
+//
+//	h := shanraqtest.New(t, shanraqtest.WithTestDB())
+//	authM := auth.New(auth.WithMailer(h.Mail), auth.WithSMSSender(h.SMS))
+//	jobsM := jobs.New()
+//	h.Register(authM, jobsM, mymodule.New(authM))
+//	h.Boot()
+//
+//	editor := h.LoginAs("editor")
+//	w := h.Do(http.MethodPost, "/things", url.Values{"title": {"x"}}, shanraqtest.AsUser(editor))
+//	h.RunJobs()
+//	// h.Mail.Sent() now holds what the job mailed.
+//
+// The runtime is not built around a pgxmock. Runtime.DB is a *pgxpool.Pool,
+// and the modules hand it to stores that take one, so a mock cannot stand in
+// for it: a harness booted without a database runs modules whose Init does
+// not query (auth does not) and routes that do not reach a store, and
+// LoginAs signs in token-only accounts. Anything past that wants a real
+// database: WithTestDB connects to the one named by SHANRAQ_TEST_DB (already
+// migrated) and skips the test when it is unset. MockPool is for testing, on
+// its own, a store written against the pgx interface, as the jobs store is.
+//
+// Modules inside this repository that shanraqtest imports — auth, ai — cannot
+// use it from their own package's tests; Go forbids the cycle.
+package shanraqtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	pgxmock "github.com/pashagolub/pgxmock/v4"
+	"go.uber.org/zap/zaptest"
+	"shanraq.org/internal/config"
+	"shanraq.org/pkg/shanraq"
+)
+
+// Origin is the site address the harness pretends to serve. Requests made with
+// Do carry it as their Origin, so the CSRF guards on browser forms let them
+// through.
+const Origin = "http://localhost:8080"
+
+// Harness is one booted application. Its fakes are ready from New, before the
+// modules that will use them are constructed.
+type Harness struct {
+	T testing.TB
+	// Runtime is what the modules are initialized with. Adjust
+	// Runtime.Config between New and Boot; changes after Boot reach only
+	// code that reads the config per request.
+	Runtime *shanraq.Runtime
+
+	Mail     *Mailbox
+	SMS      *SMSOutbox
+	Telegram *TelegramOutbox
+	AI       *FakeAI
+
+	modules []shanraq.Module
+	booted  bool
+}
+
+// Option configures a harness.
+type Option func(*Harness)
+
+// WithPool gives the runtime an existing pool. The caller keeps ownership.
+func WithPool(pool *pgxpool.Pool) Option {
+	return func(h *Harness) {
+		h.Runtime.DB = pool
+	}
+}
+
+// WithTestDB connects to the database named by SHANRAQ_TEST_DB and closes it
+// when the test ends. Without the variable the test is skipped; a name without
+// "test" in it fails the test, so a mistyped variable cannot write fixtures
+// into live data.
+func WithTestDB() Option {
+	return func(h *Harness) {
+		h.T.Helper()
+		dsn := os.Getenv("SHANRAQ_TEST_DB")
+		if dsn == "" {
+			h.T.Skip("set SHANRAQ_TEST_DB to run this integration test")
+		}
+		if !strings.Contains(dsn, "test") {
+			h.T.Fatalf("SHANRAQ_TEST_DB must name a test database (contain \"test\"); refusing %q to avoid writing into live data", dsn)
+		}
+		pool, err := pgxpool.New(context.Background(), dsn)
+		if err != nil {
+			h.T.Fatalf("connect test database: %v", err)
+		}
+		h.T.Cleanup(pool.Close)
+		h.Runtime.DB = pool
+	}
+}
+
+// New returns a harness with a test configuration: environment "test", the
+// public base URL set to Origin, a token secret long enough to pass
+// validation, and file-system media under a temporary directory.
+func New(t testing.TB, opts ...Option) *Harness {
+	t.Helper()
+	cfg := config.Config{
+		Environment:   "test",
+		PublicBaseURL: Origin,
+		Auth: config.AuthConfig{
+			TokenSecret: "shanraqtest-token-secret-that-is-long-enough-0123456789",
+			TokenTTL:    time.Hour,
+		},
+		Media: config.MediaConfig{
+			Backend: "fs", Dir: t.TempDir(), PublicPrefix: "media",
+			MaxDimension: 1600, MaxUploadBytes: 10 << 20,
+		},
+	}
+	logger := zaptest.NewLogger(t)
+	h := &Harness{
+		T: t,
+		Runtime: &shanraq.Runtime{
+			Config: cfg,
+			Logger: logger,
+			Router: chi.NewRouter(),
+			Events: shanraq.NewEventBus(logger),
+		},
+		Mail:     &Mailbox{},
+		SMS:      &SMSOutbox{},
+		Telegram: &TelegramOutbox{},
+		AI:       &FakeAI{},
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// Register adds modules, in the order main.go would register them; Boot
+// still orders them by their dependencies.
+func (h *Harness) Register(mods ...shanraq.Module) {
+	if h.booted {
+		h.T.Fatal("shanraqtest: Register after Boot")
+	}
+	h.modules = append(h.modules, mods...)
+}
+
+// Boot initializes the registered modules and mounts their routes. Their
+// Stop runs when the test ends. Start is never called: background loops stay
+// off, and the test drives jobs with RunJobs.
+func (h *Harness) Boot() {
+	h.T.Helper()
+	if h.booted {
+		h.T.Fatal("shanraqtest: Boot called twice")
+	}
+	app := shanraq.New(h.Runtime.Config)
+	for _, mod := range h.modules {
+		app.Register(mod)
+	}
+	stop, err := app.Boot(context.Background(), h.Runtime)
+	if err != nil {
+		h.T.Fatalf("boot: %v", err)
+	}
+	h.booted = true
+	h.T.Cleanup(stop)
+}
+
+// jobRunner is the jobs module, seen without importing it.
+type jobRunner interface {
+	RunDue(ctx context.Context) (int, error)
+}
+
+// RunJobs runs every due job now, in the test's goroutine, and returns how
+// many ran. Durable event deliveries are jobs too, so this is also how a test
+// gets a SubscribeDurable handler to run. It needs the jobs module registered.
+//
+// The queue is the database's, shared with anything else using it: tests that
+// call RunJobs should not run in parallel with each other.
+func (h *Harness) RunJobs() int {
+	h.T.Helper()
+	for _, mod := range h.modules {
+		if r, ok := mod.(jobRunner); ok {
+			n, err := r.RunDue(context.Background())
+			if err != nil {
+				h.T.Fatalf("run jobs: %v", err)
+			}
+			return n
+		}
+	}
+	h.T.Fatal("shanraqtest: RunJobs needs the jobs module registered")
+	return 0
+}
+
+// RequestOption adjusts a request made with Do.
+type RequestOption func(*http.Request)
+
+// WithHeader sets a request header.
+func WithHeader(key, value string) RequestOption {
+	return func(r *http.Request) { r.Header.Set(key, value) }
+}
+
+// WithCookie adds a cookie; nil adds nothing.
+func WithCookie(c *http.Cookie) RequestOption {
+	return func(r *http.Request) {
+		if c != nil {
+			r.AddCookie(c)
+		}
+	}
+}
+
+// WithJSON sends v as a JSON body in place of a form.
+func WithJSON(v any) RequestOption {
+	return func(r *http.Request) {
+		body, err := json.Marshal(v)
+		if err != nil {
+			panic("shanraqtest: encode JSON body: " + err.Error())
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		r.ContentLength = int64(len(body))
+		r.Header.Set("Content-Type", "application/json")
+	}
+}
+
+// Do sends a request through the router and returns the recorded response.
+// A non-nil form is the urlencoded body, or the query string for GET. The
+// request comes from Origin, as a browser on the site would send it.
+func (h *Harness) Do(method, path string, form url.Values, opts ...RequestOption) *httptest.ResponseRecorder {
+	h.T.Helper()
+	var r *http.Request
+	if form != nil && method != http.MethodGet {
+		r = httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		if form != nil {
+			sep := "?"
+			if strings.Contains(path, "?") {
+				sep = "&"
+			}
+			path += sep + form.Encode()
+		}
+		r = httptest.NewRequest(method, path, nil)
+	}
+	u, _ := url.Parse(Origin)
+	r.Host = u.Host
+	r.Header.Set("Origin", Origin)
+	for _, opt := range opts {
+		opt(r)
+	}
+	w := httptest.NewRecorder()
+	h.Runtime.Router.ServeHTTP(w, r)
+	return w
+}
+
+// MockPool returns a pgxmock pool whose expectations are checked when the test
+// ends, for stores written against an interface rather than *pgxpool.Pool.
+func MockPool(t testing.TB) pgxmock.PgxPoolIface {
+	t.Helper()
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("pgxmock: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+		mock.Close()
+	})
+	return mock
+}
@@ -0,0 +1,132 @@
+package shanraqtest
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"shanraq.org/pkg/modules/auth"
+	"shanraq.org/pkg/shanraq"
+)
+
+// contactModule mails whatever is posted to it, standing in for a downstream
+// module under test.
+type contactModule struct {
+	mailer auth.Mailer
+	inited bool
+}
+
+func (m *contactModule) Name() string { return "contact" }
+
+func (m *contactModule) Init(context.Context, *shanraq.Runtime) error {
+	m.inited = true
+	return nil
+}
+
+func (m *contactModule) Routes(r chi.Router) {
+	r.Post("/contact", func(w http.ResponseWriter, r *http.Request) {
+		if err := m.mailer.Send(r.Context(), "desk@shanraq.org", "Contact", r.FormValue("message")); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+}
+
+func TestBootServesRoutesAndCapturesMail(t *testing.T) {
+	h := New(t)
+	mod := &contactModule{mailer: h.Mail}
+	h.Register(mod)
+	h.Boot()
+	if !mod.inited {
+		t.Fatal("Boot did not run Init")
+	}
+
+	w := h.Do(http.MethodPost, "/contact", url.Values{"message": {"сәлем"}})
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d (%s)", w.Code, w.Body.String())
+	}
+	got := h.Mail.To("DESK@shanraq.org")
+	if len(got) != 1 || got[0].Body != "сәлем" {
+		t.Fatalf("captured mail = %+v", h.Mail.Sent())
+	}
+}
+
+func TestTelegramOutboxCapturesSendMessage(t *testing.T) {
+	var box TelegramOutbox
+	client := box.Client()
+	resp, err := client.Post("https://api.telegram.org/bot123:abc/sendMessage", "application/json",
+		strings.NewReader(`{"chat_id":"@shanraq","text":"📰 <b>Жаңалық</b>"}`))
+	if err != nil {
+		t.Fatalf("sendMessage: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+	sent := box.Sent()
+	if len(sent) != 1 || sent[0].ChatID != "@shanraq" || !strings.Contains(sent[0].Text, "Жаңалық") {
+		t.Fatalf("captured = %+v", sent)
+	}
+
+	// Anything else is refused rather than sent.
+	if _, err := client.Get("https://www.bing.com/indexnow"); err == nil {
+		t.Fatal("outbox let a request through to the network")
+	}
+}
+
+// A role route sees the harness session exactly as it would a real login —
+// including the database check behind every role decision.
+func TestLoginAsPassesRoleChecks(t *testing.T) {
+	h := New(t, WithTestDB())
+	authM := auth.New(auth.WithMailer(h.Mail), auth.WithSMSSender(h.SMS))
+	h.Register(authM)
+	h.Boot()
+	h.Runtime.Router.With(authM.RequireSession("/studio/login", "editor")).
+		Get("/desk", func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusNoContent) })
+
+	if w := h.Do(http.MethodGet, "/desk", nil, AsUser(h.LoginAs("editor"))); w.Code != http.StatusNoContent {
+		t.Fatalf("editor: status = %d", w.Code)
+	}
+	if w := h.Do(http.MethodGet, "/desk", nil, AsUser(h.LoginAs("user"))); w.Code != http.StatusSeeOther {
+		t.Fatalf("reader: status = %d, want a redirect to login", w.Code)
+	}
+}
+
+// Without a database LoginAs still signs in: a handler reading the claims sees
+// the role, and a guard that asks the database whether the account holds it
+// turns the session away rather than trusting the token.
+func TestLoginAsWithoutDatabase(t *testing.T) {
+	h := New(t)
+	authM := auth.New(auth.WithMailer(h.Mail), auth.WithSMSSender(h.SMS))
+	h.Register(authM)
+	h.Boot()
+	h.Runtime.Router.With(authM.LoadSession).Get("/whoami", func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := auth.ClaimsFromContext(r.Context())
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(claims.PrimaryRole + " " + claims.Email))
+	})
+	h.Runtime.Router.With(authM.RequireSession("/studio/login", "editor")).
+		Get("/desk", func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusNoContent) })
+
+	editor := h.LoginAs("editor")
+	if editor.Role != "editor" || editor.Token == "" || editor.Cookie == nil {
+		t.Fatalf("session = %+v", editor)
+	}
+	w := h.Do(http.MethodGet, "/whoami", nil, AsUser(editor))
+	if w.Code != http.StatusOK || w.Body.String() != "editor "+editor.Email {
+		t.Fatalf("whoami = %d %q", w.Code, w.Body.String())
+	}
+	if w := h.Do(http.MethodGet, "/desk", nil, AsUser(editor)); w.Code != http.StatusSeeOther {
+		t.Fatalf("desk = %d, want a redirect: no database backs the role", w.Code)
+	}
+	if h.LoginAs("").Role != "user" {
+		t.Fatal("an empty role did not sign in a reader")
+	}
+}
@@ -28,6 +28,8 @@ func NewRenderer() (*Renderer, error) {
 				return "primary"
 			case "retry":
 				return "info"
+			case "blocked":
+				return "dark"
 			case "failed":
 				return "danger"
 			case "done":
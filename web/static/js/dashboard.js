@@ -26,6 +26,8 @@
         return 'warning';
       case 'retry':
         return 'info';
+      case 'blocked':
+        return 'dark';
       case 'running':
         return 'primary';
       case 'failed':
@@ -62,13 +64,14 @@
         const attempts = `${esc(job.attempts)}/${esc(job.max_attempts)}`;
         const runAt = new Date(job.run_at).toLocaleString();
         const actions = [];
-        if (['failed', 'retry'].includes(job.status)) {
+        if (['failed', 'retry', 'cancelled'].includes(job.status)) {
           actions.push(`<button type="button" class="btn btn-sm btn-outline-primary jobs-action" data-action="retry" data-id="${esc(job.id)}">Retry</button>`);
         }
-        if (['pending', 'retry', 'running'].includes(job.status)) {
+        if (['pending', 'retry', 'running', 'blocked'].includes(job.status)) {
           actions.push(`<button type="button" class="btn btn-sm btn-outline-danger jobs-action" data-action="cancel" data-id="${esc(job.id)}">Cancel</button>`);
         }
-        const actionsHtml = actions.length ? actions.join(' ') : '<span class="text-muted small">—</span>';
+        actions.push(`<button type="button" class="btn btn-sm btn-outline-secondary jobs-action" data-action="history" data-id="${esc(job.id)}">History</button>`);
+        const actionsHtml = actions.join(' ');
         return `
           <tr>
             <td class="fw-medium">${esc(job.name)}</td>
@@ -83,9 +86,25 @@
     table.innerHTML = rows;
   };
 
+  // The search form's filters, shared by the list and the bulk actions so an
+  // operator acts on exactly what they are looking at.
+  const searchFilters = () => {
+    const form = document.getElementById('jobs-search-form');
+    const filters = {};
+    if (!form) return filters;
+    const data = new FormData(form);
+    ['name', 'error'].forEach((key) => {
+      const value = String(data.get(key) || '').trim();
+      if (value) filters[key] = value;
+    });
+    const since = String(data.get('since') || '');
+    if (since) filters.since = new Date(since).toISOString();
+    return filters;
+  };
+
   const fetchJobs = async (status) => {
     try {
-      const params = new URLSearchParams();
+      const params = new URLSearchParams(searchFilters());
       if (status) params.set('status', status);
       params.set('limit', '50');
       // /console/jobs is the same queue behind the credential this page has:
@@ -108,6 +127,81 @@
     }
   };
 
+  const toggleHistory = async (row, id) => {
+    const open = row.nextElementSibling;
+    if (open && open.classList.contains('jobs-history')) {
+      open.remove();
+      return;
+    }
+    try {
+      const base = `/console/jobs/${encodeURIComponent(id)}`;
+      const [response, detail] = await Promise.all([
+        fetch(`${base}/attempts`, { headers: { Accept: 'application/json' } }),
+        fetch(base, { headers: { Accept: 'application/json' } }),
+      ]);
+      if (!response.ok) {
+        throw new Error(`History request failed: ${response.status}`);
+      }
+      const attempts = await response.json();
+      // What the handler reported: where a running job has got, and what a
+      // finished one produced.
+      const job = detail.ok ? await detail.json() : {};
+      const progress = job.progress
+        ? `<div class="small mb-1">Progress: ${esc(job.progress.message)}${job.progress.total ? ` (${esc(job.progress.done)}/${esc(job.progress.total)})` : ''}</div>`
+        : '';
+      const result = job.result ? `<pre class="small mb-1 text-break">${esc(JSON.stringify(job.result))}</pre>` : '';
+      const items = attempts.length
+        ? attempts
+            .map(
+              (a) => `<li><span class="badge text-bg-${esc(statusColor(a.outcome))}">${esc(a.outcome)}</span>
+                #${esc(a.attempt)} · ${esc(new Date(a.started_at).toLocaleString())} · ${esc(a.duration_ms)} ms
+                ${a.error ? `<div class="text-danger small text-break">${esc(a.error)}</div>` : ''}</li>`,
+            )
+            .join('')
+        : '<li class="text-muted">No attempts recorded.</li>';
+      row.insertAdjacentHTML('afterend', `<tr class="jobs-history"><td colspan="5">${progress}${result}<ol class="small mb-0">${items}</ol></td></tr>`);
+    } catch (err) {
+      console.warn('job history failed', err);
+      setAlert('danger', err.message || 'Unable to load job history.');
+    }
+  };
+
+  const runBulkAction = async (action) => {
+    const filters = searchFilters();
+    const status = activeStatus || 'failed';
+    if (action === 'export') {
+      const params = new URLSearchParams({ ...filters, status });
+      window.location.href = `/console/jobs/export?${params.toString()}`;
+      return;
+    }
+    if (action === 'archive') {
+      window.location.href = `/console/jobs/archive?${new URLSearchParams(filters).toString()}`;
+      return;
+    }
+    const what = [status, filters.name, filters.error && `matching “${filters.error}”`, filters.since && `since ${new Date(filters.since).toLocaleString()}`]
+      .filter(Boolean)
+      .join(' ');
+    const verb = action === 'replay' ? 'Replay' : 'Permanently delete';
+    if (!window.confirm(`${verb} every ${what} job?`)) return;
+    try {
+      const response = await fetch(`/console/jobs/${action}`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ ...filters, status }),
+      });
+      if (!response.ok) {
+        const text = await response.text();
+        throw new Error(text || `Bulk ${action} failed: ${response.status}`);
+      }
+      const data = await response.json();
+      setAlert('success', action === 'replay' ? `${data.replayed} job(s) re-queued.` : `${data.purged} job(s) deleted.`);
+      await fetchJobs(activeStatus);
+    } catch (err) {
+      console.warn('bulk action failed', err);
+      setAlert('danger', err.message || 'Bulk action failed.');
+    }
+  };
+
   const postJobAction = async (id, action) => {
     try {
       const endpoint = action === 'retry' ? `/console/jobs/${id}/retry` : `/console/jobs/${id}/cancel`;
@@ -129,6 +223,265 @@
     }
   };
 
+  const renderQueues = (queues) => {
+    const table = document.querySelector('#queues-table tbody');
+    if (!table) return;
+    if (!queues || queues.length === 0) {
+      table.innerHTML = '<tr><td colspan="7" class="text-center text-muted py-4">No queues yet.</td></tr>';
+      return;
+    }
+    table.innerHTML = queues
+      .map((q) => {
+        const workers = q.workers
+          ? `${esc(q.workers)}${q.rate ? ` <span class="text-muted small">(${esc(q.rate)}/s)</span>` : ''}`
+          : '<span class="text-muted small">default pool</span>';
+        const oldest = q.due ? `${esc(Math.round(q.oldest_due_seconds))}s` : '<span class="text-muted small">—</span>';
+        return `
+          <tr>
+            <td class="fw-medium">${esc(q.queue)}</td>
+            <td>${workers}</td>
+            <td>${esc(q.due)}</td>
+            <td>${oldest}</td>
+            <td>${esc(q.waiting)}</td>
+            <td>${esc(q.running)}</td>
+            <td>${esc(q.done_last_hour)} / ${esc(q.failed_last_hour)}</td>
+          </tr>`;
+      })
+      .join('');
+  };
+
+  const fetchQueues = async () => {
+    if (!document.getElementById('queues-table')) return;
+    try {
+      const response = await fetch('/console/jobs/queues', {
+        headers: { Accept: 'application/json' },
+      });
+      if (!response.ok) {
+        throw new Error(`Queues request failed: ${response.status}`);
+      }
+      renderQueues(await response.json());
+    } catch (err) {
+      console.warn('queues fetch failed', err);
+    }
+  };
+
+  const renderWorkflows = (workflows) => {
+    const table = document.querySelector('#workflows-table tbody');
+    if (!table) return;
+    if (!workflows || workflows.length === 0) {
+      table.innerHTML = '<tr><td colspan="7" class="text-center text-muted py-4">No workflows yet.</td></tr>';
+      return;
+    }
+    table.innerHTML = workflows
+      .map(
+        (w) => `
+          <tr>
+            <td class="fw-medium">${esc(w.name)}</td>
+            <td>${esc(new Date(w.created_at).toLocaleString())}</td>
+            <td>${esc(w.jobs)}</td>
+            <td>${esc(w.done)}</td>
+            <td>${esc(w.failed)}</td>
+            <td>${esc(w.active)}</td>
+            <td class="text-end"><button type="button" class="btn btn-sm btn-outline-secondary workflow-action" data-id="${esc(w.id)}">Show</button></td>
+          </tr>`,
+      )
+      .join('');
+  };
+
+  const fetchWorkflows = async () => {
+    if (!document.getElementById('workflows-table')) return;
+    try {
+      const response = await fetch('/console/jobs/workflows', {
+        headers: { Accept: 'application/json' },
+      });
+      if (!response.ok) {
+        throw new Error(`Workflows request failed: ${response.status}`);
+      }
+      renderWorkflows(await response.json());
+    } catch (err) {
+      console.warn('workflows fetch failed', err);
+    }
+  };
+
+  // The graph is drawn as columns: a job sits one column right of the last
+  // job it waits for, so every arrow points rightwards and the names of what
+  // a job waits for, listed under it, are enough to read the edges.
+  const renderWorkflowGraph = (wf) => {
+    const el = document.getElementById('workflow-graph');
+    if (!el) return;
+    const byId = new Map((wf.jobs || []).map((j) => [j.id, j]));
+    const depth = new Map();
+    const depthOf = (job, seen = new Set()) => {
+      if (depth.has(job.id)) return depth.get(job.id);
+      if (seen.has(job.id)) return 0;
+      seen.add(job.id);
+      let d = 0;
+      (job.after || []).forEach((id) => {
+        const dep = byId.get(id);
+        if (dep) d = Math.max(d, depthOf(dep, seen) + 1);
+      });
+      depth.set(job.id, d);
+      return d;
+    };
+    const columns = [];
+    (wf.jobs || []).forEach((job) => {
+      const d = depthOf(job);
+      (columns[d] = columns[d] || []).push(job);
+    });
+    const node = (job) => {
+      const waits = (job.after || [])
+        .map((id) => (byId.get(id) ? byId.get(id).name : id.slice(0, 8)))
+        .join(', ');
+      const policy = job.after_failure && job.after_failure !== 'fail' ? ` <span class="text-muted">(${esc(job.after_failure)} on failure)</span>` : '';
+      const error = job.last_error ? `<div class="small text-danger text-break">${esc(job.last_error)}</div>` : '';
+      return `
+        <div class="border rounded-3 p-2 mb-2 bg-white">
+          <div class="fw-medium">${esc(job.name)}</div>
+          <span class="badge text-bg-${esc(statusColor(job.status))} text-capitalize">${esc(job.status)}</span>
+          ${waits ? `<div class="small text-muted">after ${esc(waits)}${policy}</div>` : ''}
+          ${error}
+        </div>`;
+    };
+    el.innerHTML = `
+      <h6 class="mb-2">${esc(wf.name)}</h6>
+      <div class="d-flex gap-3 overflow-auto">
+        ${columns.map((col) => `<div class="flex-shrink-0" style="min-width: 12rem">${col.map(node).join('')}</div>`).join('<div class="align-self-center text-muted">→</div>')}
+      </div>`;
+    el.classList.remove('d-none');
+  };
+
+  const showWorkflow = async (id) => {
+    try {
+      const response = await fetch(`/console/jobs/workflows/${encodeURIComponent(id)}`, {
+        headers: { Accept: 'application/json' },
+      });
+      if (!response.ok) {
+        throw new Error(`Workflow request failed: ${response.status}`);
+      }
+      renderWorkflowGraph(await response.json());
+    } catch (err) {
+      console.warn('workflow fetch failed', err);
+    }
+  };
+
+  const bindWorkflows = () => {
+    const table = document.querySelector('#workflows-table');
+    if (!table) return;
+    table.addEventListener('click', (event) => {
+      const target = event.target;
+      if (!(target instanceof HTMLElement)) return;
+      if (!target.classList.contains('workflow-action')) return;
+      if (target.dataset.id) showWorkflow(target.dataset.id);
+    });
+  };
+
+  const setScheduleAlert = (type, message) => {
+    const el = document.getElementById('schedules-alert');
+    if (!el) return;
+    if (!message) {
+      el.classList.add('d-none');
+      el.textContent = '';
+      return;
+    }
+    el.textContent = message;
+    el.className = `alert alert-${type}`;
+  };
+
+  const renderSchedules = (schedules) => {
+    const table = document.querySelector('#schedules-table tbody');
+    if (!table) return;
+    if (!schedules || schedules.length === 0) {
+      table.innerHTML = '<tr><td colspan="5" class="text-center text-muted py-4">No schedules registered.</td></tr>';
+      return;
+    }
+    table.innerHTML = schedules
+      .map((s) => {
+        const last = s.last_run_at
+          ? `${esc(new Date(s.last_run_at).toLocaleString())} <span class="badge text-bg-${esc(statusColor(s.last_status))} text-capitalize">${esc(s.last_status || 'gone')}</span>`
+          : '<span class="text-muted small">never</span>';
+        const next = s.enabled ? esc(new Date(s.next_run_at).toLocaleString()) : '<span class="text-muted small">off</span>';
+        const custom = s.spec !== s.default_spec ? ` <span class="text-muted small">(default ${esc(s.default_spec)})</span>` : '';
+        const toggle = s.enabled
+          ? `<button type="button" class="btn btn-sm btn-outline-secondary schedule-action" data-action="disable" data-name="${esc(s.name)}">Disable</button>`
+          : `<button type="button" class="btn btn-sm btn-outline-success schedule-action" data-action="enable" data-name="${esc(s.name)}">Enable</button>`;
+        return `
+          <tr>
+            <td class="fw-medium">${esc(s.name)}</td>
+            <td><code>${esc(s.spec)}</code>${custom}</td>
+            <td>${last}</td>
+            <td>${next}</td>
+            <td>
+              <button type="button" class="btn btn-sm btn-outline-primary schedule-action" data-action="run" data-name="${esc(s.name)}">Run now</button>
+              ${toggle}
+              <button type="button" class="btn btn-sm btn-outline-secondary schedule-action" data-action="edit" data-name="${esc(s.name)}" data-spec="${esc(s.spec)}">Edit</button>
+            </td>
+          </tr>`;
+      })
+      .join('');
+  };
+
+  const fetchSchedules = async () => {
+    if (!document.getElementById('schedules-table')) return;
+    try {
+      const response = await fetch('/console/jobs/schedules', {
+        headers: { Accept: 'application/json' },
+      });
+      if (!response.ok) {
+        throw new Error(`Schedules request failed: ${response.status}`);
+      }
+      renderSchedules(await response.json());
+    } catch (err) {
+      console.warn('schedules fetch failed', err);
+      setScheduleAlert('danger', err.message || 'Unable to load schedules.');
+    }
+  };
+
+  const postScheduleAction = async (name, action, spec) => {
+    const base = `/console/jobs/schedules/${encodeURIComponent(name)}`;
+    const request =
+      action === 'edit'
+        ? { url: base, method: 'PUT', body: JSON.stringify({ spec }) }
+        : { url: `${base}/${action}`, method: 'POST' };
+    try {
+      const response = await fetch(request.url, {
+        method: request.method,
+        headers: request.body ? { 'Content-Type': 'application/json' } : undefined,
+        body: request.body,
+      });
+      if (!response.ok) {
+        const text = await response.text();
+        throw new Error(text || `Action failed: ${response.status}`);
+      }
+      const done = { run: 'Run queued.', enable: 'Schedule enabled.', disable: 'Schedule disabled.', edit: 'Schedule saved.' };
+      setScheduleAlert('success', done[action]);
+      await fetchSchedules();
+      if (action === 'run') await fetchJobs(activeStatus);
+    } catch (err) {
+      console.warn('schedule action failed', err);
+      setScheduleAlert('danger', err.message || 'Schedule action failed.');
+    }
+  };
+
+  const bindSchedules = () => {
+    const table = document.querySelector('#schedules-table');
+    if (!table) return;
+    table.addEventListener('click', (event) => {
+      const target = event.target;
+      if (!(target instanceof HTMLElement)) return;
+      if (!target.classList.contains('schedule-action')) return;
+      const { name, action } = target.dataset;
+      if (!name || !action) return;
+      if (action === 'edit') {
+        // Empty restores the schedule the code registered.
+        const spec = window.prompt(`Cron spec for ${name} (UTC). Leave empty for the default.`, target.dataset.spec || '');
+        if (spec === null) return;
+        postScheduleAction(name, action, spec);
+        return;
+      }
+      postScheduleAction(name, action);
+    });
+  };
+
   const bindFilters = () => {
     const buttons = document.querySelectorAll('.jobs-filter');
     buttons.forEach((button) => {
@@ -149,9 +502,25 @@
         const jobId = target.dataset.id;
         const action = target.dataset.action;
         if (!jobId || !action) return;
+        if (action === 'history') {
+          const row = target.closest('tr');
+          if (row) toggleHistory(row, jobId);
+          return;
+        }
         postJobAction(jobId, action);
       });
     }
+
+    const search = document.getElementById('jobs-search-form');
+    if (search) {
+      search.addEventListener('submit', (event) => {
+        event.preventDefault();
+        fetchJobs(activeStatus);
+      });
+      search.querySelectorAll('.jobs-bulk').forEach((button) => {
+        button.addEventListener('click', () => runBulkAction(button.dataset.action));
+      });
+    }
   };
 
   const bindJobForm = () => {
@@ -255,7 +624,12 @@
   const initialize = () => {
     bindJobForm();
     bindFilters();
+    bindSchedules();
+    bindWorkflows();
     fetchJobs(activeStatus);
+    fetchSchedules();
+    fetchQueues();
+    fetchWorkflows();
   };
 
   const setupAutoRefresh = () => {
@@ -0,0 +1,160 @@
+// Passkeys in the studio: signing in with one on /studio/login, the passkey
+// step after a password, and adding one in /studio/profile.
+//
+// The server speaks the WebAuthn JSON of the spec, with binary fields as
+// base64url; the browser API wants ArrayBuffers. Everything here is that
+// translation and a fetch either side of it — the checks themselves happen on
+// the server.
+(function () {
+  if (!window.PublicKeyCredential || !navigator.credentials) return;
+
+  function toBuffer(s) {
+    s = s.replace(/-/g, '+').replace(/_/g, '/');
+    while (s.length % 4) s += '=';
+    var bin = atob(s);
+    var out = new Uint8Array(bin.length);
+    for (var i = 0; i < bin.length; i++) out[i] = bin.charCodeAt(i);
+    return out.buffer;
+  }
+
+  function toBase64url(buf) {
+    var bytes = new Uint8Array(buf);
+    var bin = '';
+    for (var i = 0; i < bytes.length; i++) bin += String.fromCharCode(bytes[i]);
+    return btoa(bin).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '');
+  }
+
+  function withIDs(list) {
+    return (list || []).map(function (c) {
+      return Object.assign({}, c, { id: toBuffer(c.id) });
+    });
+  }
+
+  // The server wraps the options as {publicKey: {...}}, ready for
+  // navigator.credentials; only the binary fields need converting.
+  function requestOptions(options) {
+    var pk = Object.assign({}, options.publicKey);
+    pk.challenge = toBuffer(pk.challenge);
+    if (pk.allowCredentials) pk.allowCredentials = withIDs(pk.allowCredentials);
+    return { publicKey: pk };
+  }
+
+  function creationOptions(options) {
+    var pk = Object.assign({}, options.publicKey);
+    pk.challenge = toBuffer(pk.challenge);
+    pk.user = Object.assign({}, pk.user, { id: toBuffer(pk.user.id) });
+    if (pk.excludeCredentials) pk.excludeCredentials = withIDs(pk.excludeCredentials);
+    return { publicKey: pk };
+  }
+
+  // credentialJSON serialises what the browser returned. Newer browsers do it
+  // themselves; the rest get the same shape by hand.
+  function credentialJSON(cred) {
+    if (typeof cred.toJSON === 'function') return cred.toJSON();
+    var r = cred.response;
+    var response = { clientDataJSON: toBase64url(r.clientDataJSON) };
+    if (r.attestationObject) {
+      response.attestationObject = toBase64url(r.attestationObject);
+      if (typeof r.getTransports === 'function') response.transports = r.getTransports();
+    } else {
+      response.authenticatorData = toBase64url(r.authenticatorData);
+      response.signature = toBase64url(r.signature);
+      if (r.userHandle) response.userHandle = toBase64url(r.userHandle);
+    }
+    return {
+      id: cred.id,
+      rawId: toBase64url(cred.rawId),
+      type: cred.type,
+      response: response,
+      clientExtensionResults: cred.getClientExtensionResults ? cred.getClientExtensionResults() : {},
+      authenticatorAttachment: cred.authenticatorAttachment || undefined
+    };
+  }
+
+  function post(url, body) {
+    return fetch(url, {
+      method: 'POST',
+      credentials: 'same-origin',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(body)
+    }).then(function (res) {
+      return res.json().catch(function () { return {}; }).then(function (data) {
+        if (!res.ok) {
+          var err = new Error(data.error || '');
+          err.server = true;
+          throw err;
+        }
+        return data;
+      });
+    });
+  }
+
+  function fail(root, err) {
+    var box = root.querySelector('[data-passkey-error]');
+    if (!box) return;
+    // The server's refusals are already in the reader's language. A cancelled
+    // prompt gets the generic line: the browser's own wording is for developers.
+    box.textContent = (err && err.server && err.message) || root.dataset.passkeyFailed || '';
+    box.hidden = !box.textContent;
+  }
+
+  function busy(el, on) {
+    if (el) el.disabled = on;
+  }
+
+  // Sign-in: with a challenge in the page it is the step after a password,
+  // without one a sign-in with the passkey alone.
+  document.querySelectorAll('[data-passkey-login]').forEach(function (root) {
+    var btn = root.querySelector('[data-passkey-start]');
+    root.hidden = false;
+    if (!btn) return;
+    btn.addEventListener('click', function () {
+      busy(btn, true);
+      var inline = root.dataset.passkeyChallenge;
+      var answered = false;
+      var start = inline ? Promise.resolve(JSON.parse(inline)) : post('/studio/login/passkey/options', {});
+      start.then(function (ch) {
+        return navigator.credentials.get(requestOptions(ch.options)).then(function (cred) {
+          answered = true;
+          return post('/studio/login/passkey', {
+            challenge_id: ch.challenge_id,
+            credential: credentialJSON(cred),
+            next: root.dataset.next || ''
+          });
+        });
+      }).then(function (res) {
+        window.location.assign(res.redirect || '/studio');
+      }).catch(function (err) {
+        busy(btn, false);
+        fail(root, err);
+        // A challenge is good for one answer. Once the server has seen it,
+        // another try asks for a fresh one: a sign-in with the passkey alone.
+        if (answered) root.dataset.passkeyChallenge = '';
+      });
+    });
+  });
+
+  // Profile: the password first, then the browser creates the passkey.
+  document.querySelectorAll('[data-passkey-register]').forEach(function (form) {
+    form.hidden = false;
+    form.addEventListener('submit', function (e) {
+      e.preventDefault();
+      var btn = form.querySelector('button[type=submit]');
+      busy(btn, true);
+      post('/studio/passkeys/options', { password: form.elements.password.value }).then(function (ch) {
+        return navigator.credentials.create(creationOptions(ch.options)).then(function (cred) {
+          return post('/studio/passkeys', {
+            challenge_id: ch.challenge_id,
+            name: form.elements.name.value,
+            credential: credentialJSON(cred)
+          });
+        });
+      }).then(function (res) {
+        window.location.assign(res.redirect || '/studio/profile');
+      }).catch(function (err) {
+        busy(btn, false);
+        fail(form, err);
+      });
+    });
+  });
+})();